@@ -6,7 +6,8 @@ CREATE TABLE IF NOT EXISTS robots (
     public_key   TEXT         NOT NULL,
     device_type  VARCHAR(100) NOT NULL,
     is_blacklisted BOOLEAN    NOT NULL DEFAULT FALSE,
-    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    command_schema JSONB
 );
 
 CREATE INDEX IF NOT EXISTS idx_robots_device_type ON robots(device_type);
@@ -18,3 +19,51 @@ CREATE TABLE IF NOT EXISTS users (
     password_hash TEXT         NOT NULL,
     created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
+
+CREATE TABLE IF NOT EXISTS robot_energy (
+    uuid         VARCHAR(255) NOT NULL,
+    day          DATE         NOT NULL,
+    device_type  VARCHAR(100) NOT NULL DEFAULT '',
+    reported_wh  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    estimated_wh DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (uuid, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_robot_energy_day ON robot_energy(day);
+
+-- Announce every change to the robots table on the robot_changes channel, so
+-- servers with database.postgres.watch_changes pick up writes made outside
+-- the API (admin scripts, other services). application_name lets a server
+-- ignore its own writes.
+CREATE OR REPLACE FUNCTION notify_robot_change() RETURNS trigger AS $$
+DECLARE
+    rec    robots%ROWTYPE;
+    change TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+        change := 'deleted';
+    ELSE
+        rec := NEW;
+        IF TG_OP = 'INSERT' THEN
+            change := 'registered';
+        ELSIF OLD.is_blacklisted IS DISTINCT FROM NEW.is_blacklisted THEN
+            change := 'blacklist';
+        ELSE
+            change := 'updated';
+        END IF;
+    END IF;
+    PERFORM pg_notify('robot_changes', json_build_object(
+        'uuid', rec.uuid,
+        'change', change,
+        'blacklisted', rec.is_blacklisted,
+        'application', current_setting('application_name', true)
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS robots_notify_change ON robots;
+CREATE TRIGGER robots_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON robots
+    FOR EACH ROW EXECUTE FUNCTION notify_robot_change();
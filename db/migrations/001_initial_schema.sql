@@ -8,8 +8,8 @@ CREATE TABLE IF NOT EXISTS robots (
     created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
 
-CREATE INDEX idx_robots_device_type ON robots(device_type);
-CREATE INDEX idx_robots_blacklisted ON robots(is_blacklisted) WHERE is_blacklisted = TRUE;
+CREATE INDEX IF NOT EXISTS idx_robots_device_type ON robots(device_type);
+CREATE INDEX IF NOT EXISTS idx_robots_blacklisted ON robots(is_blacklisted) WHERE is_blacklisted = TRUE;
 
 CREATE TABLE IF NOT EXISTS users (
     id           SERIAL PRIMARY KEY,
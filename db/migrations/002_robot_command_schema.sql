@@ -0,0 +1,8 @@
+-- migrate:up
+
+-- Declarative command/state schema for generic_actuator robots (NULL for other types).
+ALTER TABLE robots ADD COLUMN IF NOT EXISTS command_schema JSONB;
+
+-- migrate:down
+
+ALTER TABLE robots DROP COLUMN IF EXISTS command_schema;
@@ -0,0 +1,18 @@
+-- migrate:up
+
+-- Energy used per robot per UTC day, in watt-hours. No foreign key to robots:
+-- ephemeral (Redis-only) robots are metered too.
+CREATE TABLE IF NOT EXISTS robot_energy (
+    uuid         VARCHAR(255) NOT NULL,
+    day          DATE         NOT NULL,
+    device_type  VARCHAR(100) NOT NULL DEFAULT '',
+    reported_wh  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    estimated_wh DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (uuid, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_robot_energy_day ON robot_energy(day);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS robot_energy;
@@ -0,0 +1,43 @@
+-- migrate:up
+
+-- Announce every change to the robots table on the robot_changes channel, so
+-- servers with database.postgres.watch_changes pick up writes made outside
+-- the API (admin scripts, other services). application_name lets a server
+-- ignore its own writes.
+CREATE OR REPLACE FUNCTION notify_robot_change() RETURNS trigger AS $$
+DECLARE
+    rec    robots%ROWTYPE;
+    change TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+        change := 'deleted';
+    ELSE
+        rec := NEW;
+        IF TG_OP = 'INSERT' THEN
+            change := 'registered';
+        ELSIF OLD.is_blacklisted IS DISTINCT FROM NEW.is_blacklisted THEN
+            change := 'blacklist';
+        ELSE
+            change := 'updated';
+        END IF;
+    END IF;
+    PERFORM pg_notify('robot_changes', json_build_object(
+        'uuid', rec.uuid,
+        'change', change,
+        'blacklisted', rec.is_blacklisted,
+        'application', current_setting('application_name', true)
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS robots_notify_change ON robots;
+CREATE TRIGGER robots_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON robots
+    FOR EACH ROW EXECUTE FUNCTION notify_robot_change();
+
+-- migrate:down
+
+DROP TRIGGER IF EXISTS robots_notify_change ON robots;
+DROP FUNCTION IF EXISTS notify_robot_change();
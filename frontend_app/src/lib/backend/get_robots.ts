@@ -10,6 +10,7 @@ function activeToBase(r: ActiveRobot): BaseRobot {
         robot_type: r.device_type,
         status: "online",
         last_seen: r.connected_at,
+        maintenance: r.maintenance,
     };
 }
 
@@ -5,6 +5,14 @@ export interface BaseRobot {
   robot_type: string;
   status: string;
   last_seen: number;
+  maintenance?: MaintenanceInfo;
+}
+
+export interface MaintenanceInfo {
+  uuid: string;
+  reason?: string;
+  by?: string;
+  since: number;
 }
 
 export interface RegisteringRobotEvent {
@@ -28,6 +36,7 @@ export interface ActiveRobot {
   session_jwt: string;
   pid: number;
   connected_at: number;
+  maintenance?: MaintenanceInfo;
 }
 
 export interface PendingRobot {
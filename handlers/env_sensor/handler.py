@@ -0,0 +1,313 @@
+#!/usr/bin/env python3
+"""
+Environment sensor handler — temperature, humidity and air quality.
+
+The robot sends readings as JSON messages:
+
+    {"type": "reading", "metrics": {"temperature": 21.4, "humidity": 43, "aqi": 18}}
+
+Each metric is checked against its threshold; crossing a bound publishes
+env_sensor.{uuid}.threshold (and env_sensor.{uuid}.threshold_cleared once the
+value is back in range). Readings are rolled up into hourly min/max/avg
+buckets; finished hours are appended to the "history" data key, readable via
+GET /robot/{uuid}/data/history.
+
+Commands (POST /robot/{uuid}/message):
+
+    {"command": "get_state"}
+    {"command": "set_threshold", "metric": "temperature", "min": 15, "max": 28}
+    {"command": "history", "hours": 24}
+"""
+import json
+import os
+import sys
+import time
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+METRICS = ("temperature", "humidity", "aqi")
+HISTORY_HOURS = 24 * 7
+# Rewritten every hour while the sensor reports, so this only bites once a
+# sensor has gone quiet for longer than the retained window.
+HISTORY_TTL = (HISTORY_HOURS + 24) * 3600
+
+# Per-metric bounds; None means unbounded on that side.
+DEFAULT_THRESHOLDS = {
+    "temperature": {"min": 10.0, "max": 32.0},
+    "humidity": {"min": 20.0, "max": 70.0},
+    "aqi": {"min": None, "max": 100.0},
+}
+
+state = {
+    "connected": False,
+    "latest": {},         # metric -> last value
+    "last_reading": None,
+    "thresholds": {m: dict(b) for m, b in DEFAULT_THRESHOLDS.items()},
+    "breached": {},       # metric -> "low" | "high"
+}
+
+# Current hour's running aggregates: metric -> {min, max, sum, count}
+bucket = {"hour": None, "metrics": {}}
+history = []  # finished hourly buckets, oldest first
+
+msg_counter = 0
+pending = {}  # request id -> callback(data, error)
+
+
+def next_id():
+    global msg_counter
+    msg_counter += 1
+    return str(msg_counter)
+
+
+def send(target, method="", data=None, callback=None):
+    """Send a JSON-RPC message to the roboserver."""
+    msg = {"target": target, "id": next_id()}
+    if method:
+        msg["method"] = method
+    if data is not None:
+        msg["data"] = data
+    if callback:
+        pending[msg["id"]] = callback
+    print(json.dumps(msg), flush=True)
+
+
+def publish_event(suffix, data):
+    send("event_bus", method=f"env_sensor.{UUID}.{suffix}", data=data)
+
+
+def store(key, value, ttl=None):
+    data = {"key": key, "value": value}
+    if ttl is not None:
+        data["ttl"] = ttl
+    send("database", method="store_data", data=data)
+
+
+def log(text):
+    print(f"[env_sensor] {text}", file=sys.stderr, flush=True)
+
+
+# --- Thresholds --------------------------------------------------------------
+
+def check_threshold(metric, value):
+    bounds = state["thresholds"].get(metric, {})
+    lo, hi = bounds.get("min"), bounds.get("max")
+    if lo is not None and value < lo:
+        level = "low"
+    elif hi is not None and value > hi:
+        level = "high"
+    else:
+        level = None
+
+    previous = state["breached"].get(metric)
+    if level == previous:
+        return  # only transitions generate events
+    if level:
+        state["breached"][metric] = level
+        publish_event("threshold", {
+            "uuid": UUID, "metric": metric, "value": value,
+            "level": level, "min": lo, "max": hi,
+        })
+    else:
+        state["breached"].pop(metric, None)
+        publish_event("threshold_cleared", {"uuid": UUID, "metric": metric, "value": value})
+
+
+# --- Hourly aggregation -------------------------------------------------------
+
+def hour_of(ts):
+    return int(ts) // 3600 * 3600
+
+
+def aggregate(ts, metric, value):
+    hour = hour_of(ts)
+    if bucket["hour"] is not None and hour != bucket["hour"]:
+        close_bucket()
+    bucket["hour"] = hour
+
+    agg = bucket["metrics"].setdefault(metric, {"min": value, "max": value, "sum": 0.0, "count": 0})
+    agg["min"] = min(agg["min"], value)
+    agg["max"] = max(agg["max"], value)
+    agg["sum"] += value
+    agg["count"] += 1
+
+
+def merge_into(existing, entry):
+    for metric, new in entry["metrics"].items():
+        old = existing["metrics"].get(metric)
+        if not old:
+            existing["metrics"][metric] = new
+            continue
+        count = old["count"] + new["count"]
+        old["avg"] = round((old["avg"] * old["count"] + new["avg"] * new["count"]) / count, 2)
+        old["min"] = min(old["min"], new["min"])
+        old["max"] = max(old["max"], new["max"])
+        old["count"] = count
+
+
+def close_bucket():
+    """Finalize the current hour into history and persist it."""
+    if bucket["hour"] is None or not bucket["metrics"]:
+        return
+    entry = {"hour": bucket["hour"], "metrics": {}}
+    for metric, agg in bucket["metrics"].items():
+        entry["metrics"][metric] = {
+            "min": agg["min"],
+            "max": agg["max"],
+            "avg": round(agg["sum"] / agg["count"], 2),
+            "count": agg["count"],
+        }
+    if history and history[-1]["hour"] == entry["hour"]:
+        merge_into(history[-1], entry)  # same hour resumed after a restart
+    else:
+        history.append(entry)
+    del history[:-HISTORY_HOURS]
+    store("history", history, ttl=HISTORY_TTL)
+    publish_event("hourly", dict(entry, uuid=UUID))
+
+    bucket["hour"] = None
+    bucket["metrics"] = {}
+
+
+# --- Readings and commands -----------------------------------------------------
+
+def handle_reading(payload):
+    ts = payload.get("ts") or time.time()
+    metrics = payload.get("metrics") or {}
+    accepted = {}
+    for metric in METRICS:
+        value = metrics.get(metric)
+        if isinstance(value, bool) or not isinstance(value, (int, float)):
+            continue
+        accepted[metric] = value
+        state["latest"][metric] = value
+        check_threshold(metric, value)
+        aggregate(ts, metric, value)
+
+    if accepted:
+        state["last_reading"] = int(ts)
+        publish_event("reading", {"uuid": UUID, "ts": int(ts), "metrics": accepted})
+
+
+def reply(payload, data=None, error=""):
+    publish_event("reply", {
+        "uuid": UUID, "request_id": payload.get("request_id", ""),
+        "data": data, "error": error,
+    })
+
+
+def cmd_get_state(payload):
+    reply(payload, state)
+
+
+def cmd_set_threshold(payload):
+    metric = payload.get("metric")
+    if metric not in METRICS:
+        reply(payload, error=f"metric must be one of {list(METRICS)}")
+        return
+    bounds = state["thresholds"][metric]
+    for side in ("min", "max"):
+        if side in payload:
+            value = payload[side]
+            if value is not None and (isinstance(value, bool) or not isinstance(value, (int, float))):
+                reply(payload, error=f"{side} must be a number or null")
+                return
+            bounds[side] = value
+    if bounds["min"] is not None and bounds["max"] is not None and bounds["min"] >= bounds["max"]:
+        reply(payload, error="min must be below max")
+        return
+
+    store("thresholds", state["thresholds"])
+    # Re-evaluate against the latest value so a tightened bound fires immediately.
+    if metric in state["latest"]:
+        check_threshold(metric, state["latest"][metric])
+    reply(payload, {metric: bounds})
+
+
+def cmd_history(payload):
+    hours = payload.get("hours", 24)
+    if not isinstance(hours, int) or hours <= 0:
+        hours = 24
+    reply(payload, history[-hours:])
+
+
+COMMANDS = {
+    "get_state": cmd_get_state,
+    "set_threshold": cmd_set_threshold,
+    "history": cmd_history,
+}
+
+
+def handle_incoming(msg):
+    raw = msg.get("payload", "")
+    try:
+        payload = json.loads(raw) if isinstance(raw, str) else raw
+    except json.JSONDecodeError:
+        log(f"ignoring non-JSON payload: {raw!r}")
+        return
+    if not isinstance(payload, dict):
+        return
+
+    if payload.get("type") == "reading":
+        handle_reading(payload)
+        return
+    handler = COMMANDS.get(payload.get("command", ""))
+    if handler:
+        handler(payload)
+    else:
+        reply(payload, error=f"unknown command; available: {sorted(COMMANDS)}")
+
+
+# --- Startup ---------------------------------------------------------------------
+
+def restore():
+    def on_thresholds(data, error):
+        if not error and isinstance(data, dict):
+            for metric, bounds in data.items():
+                if metric in state["thresholds"] and isinstance(bounds, dict):
+                    state["thresholds"][metric].update(bounds)
+
+    def on_history(data, error):
+        if not error and isinstance(data, list):
+            history[:0] = data
+            del history[:-HISTORY_HOURS]
+
+    send("database", method="get_data", data="thresholds", callback=on_thresholds)
+    send("database", method="get_data", data="history", callback=on_history)
+
+
+def main():
+    restore()
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        if msg.get("target") == "response":
+            callback = pending.pop(msg.get("id", ""), None)
+            if callback:
+                callback(msg.get("data"), msg.get("error", ""))
+            continue
+
+        msg_type = msg.get("type", "")
+        if msg_type == "connect":
+            state["connected"] = True
+            log(f"robot {msg.get('uuid')} connected from {msg.get('ip')}")
+        elif msg_type == "incoming":
+            handle_incoming(msg)
+        elif msg_type == "disconnect":
+            state["connected"] = False
+            log(f"robot disconnected: {msg.get('reason', 'unknown')}")
+
+    # Keep the partial hour rather than losing it on shutdown.
+    close_bucket()
+
+
+if __name__ == "__main__":
+    main()
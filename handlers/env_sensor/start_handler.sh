@@ -0,0 +1,9 @@
+#!/bin/bash
+# Handler entry point for robot type: env_sensor
+#
+# An environment sensor reporting temperature, humidity and air quality.
+# Raises threshold events, rolls readings up into hourly min/max/avg buckets,
+# and keeps a week of them under the "history" data key
+# (GET /robot/{uuid}/data/history).
+
+exec python3 "$(dirname "$0")/handler.py"
@@ -0,0 +1,367 @@
+#!/usr/bin/env python3
+"""
+Example robot handler — the reference implementation for new robot types.
+
+Copy this file (or handlers/_template/ for a minimal start) when adding a type.
+Everything here uses only the stdin/stdout protocol described in
+start_handler.sh, so the same structure works in any language.
+
+Commands arrive as the "payload" of incoming messages, either from the robot
+itself or from the HTTP API (POST /robot/{uuid}/message). Payloads are JSON:
+
+    {"command": "get_state"}                          - read state (HTTP GET-style)
+    {"command": "set", "field": "led", "value": "on"} - write a field (HTTP POST-style)
+    {"command": "quick_action", "action": "beep", "request_id": "abc"}
+    {"command": "hello", "reverse_port": 8888}        - robot announces a listening port
+
+Desired state set with PATCH /robot/{uuid}/shadow arrives as shadow_delta
+messages (also on every connect, for changes made while the robot was away).
+Writable fields are applied like "set" and reported back to the shadow.
+
+Replies to HTTP-originated commands are published on the event bus as
+example_robot.{uuid}.reply (with the caller's request_id), so the frontend can
+subscribe over SSE/WebSocket and match them up.
+"""
+import json
+import os
+import sys
+import threading
+import time
+from collections import deque
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+# --- Custom state -----------------------------------------------------------
+# Fields a robot type adds on top of the common uuid/ip/device_type. WRITABLE
+# lists the ones the "set" command may change.
+state = {
+    "uuid": UUID,
+    "connected": False,
+    "led": "off",
+    "mode": "idle",
+    "battery": None,
+    "messages_received": 0,
+    "last_seen": None,
+}
+WRITABLE = {"led": {"on", "off"}, "mode": {"idle", "active", "sleep"}}
+STATE_KEY = "state"  # handler:{uuid}:data:state in Redis
+
+# --- JSON-RPC output --------------------------------------------------------
+
+lock = threading.RLock()  # guards state; the reconnect timer runs on its own thread
+_out_lock = threading.Lock()  # timers write from other threads
+_msg_counter = 0
+_pending = {}  # request id -> callback(data, error)
+
+
+def next_id():
+    global _msg_counter
+    with _out_lock:
+        _msg_counter += 1
+        return str(_msg_counter)
+
+
+def send(target, method="", data=None, msg_id=""):
+    """Write a JSON-RPC envelope to the roboserver."""
+    msg = {"target": target, "id": msg_id or next_id()}
+    if method:
+        msg["method"] = method
+    if data is not None:
+        msg["data"] = data
+    with _out_lock:
+        print(json.dumps(msg), flush=True)
+    return msg["id"]
+
+
+def request(target, method="", data=None, callback=None):
+    """Send a request and invoke callback(data, error) when the response arrives.
+
+    Responses come back on stdin as {"target":"response","id":...}. Handlers
+    must not block the stdin loop waiting for them, so replies are delivered
+    through callbacks instead.
+    """
+    msg_id = next_id()
+    if callback:
+        _pending[msg_id] = callback
+    send(target, method, data, msg_id)
+    return msg_id
+
+
+def handle_response(msg):
+    callback = _pending.pop(msg.get("id", ""), None)
+    if callback:
+        callback(msg.get("data"), msg.get("error", ""))
+
+
+def publish_event(suffix, data):
+    request("event_bus", method=f"example_robot.{UUID}.{suffix}", data=data)
+
+
+def log(text):
+    print(f"[example_robot] {text}", file=sys.stderr, flush=True)
+
+
+# --- Outbound queue ----------------------------------------------------------
+# Messages to the robot are queued while it is offline and flushed when it
+# reconnects. When the queue is full the oldest message is dropped and a
+# queue_full event tells operators that commands are being lost.
+
+OUTBOX_LIMIT = 100
+outbox = deque()
+
+
+def send_to_robot(data):
+    if not state["connected"]:
+        enqueue(data)
+        return
+
+    def on_sent(_, error):
+        # The connection can drop between our check and delivery; keep the message.
+        if error:
+            log(f"send failed ({error}), queueing")
+            enqueue(data)
+
+    request("robot", data=data, callback=on_sent)
+
+
+def enqueue(data):
+    if len(outbox) >= OUTBOX_LIMIT:
+        dropped = outbox.popleft()
+        publish_event("queue_full", {"uuid": UUID, "limit": OUTBOX_LIMIT, "dropped": dropped})
+    outbox.append(data)
+
+
+def flush_outbox():
+    while outbox and state["connected"]:
+        send_to_robot(outbox.popleft())
+
+
+# --- State persistence -------------------------------------------------------
+
+def save_state():
+    persisted = {k: state[k] for k in ("led", "mode", "battery")}
+    request("database", method="store_data", data={"key": STATE_KEY, "value": json.dumps(persisted)})
+
+
+def restore_state():
+    def on_loaded(value, error):
+        if error or not value:
+            return
+        try:
+            state.update(json.loads(value))
+            log("restored saved state")
+        except (TypeError, json.JSONDecodeError):
+            pass
+
+    request("database", method="get_data", data=STATE_KEY, callback=on_loaded)
+
+
+# --- Connection handling ----------------------------------------------------
+# If the robot announced a listening port ("hello"), a dropped connection is
+# retried by asking the roboserver to dial the robot, with exponential backoff.
+
+RECONNECT_BASE = 1.0
+RECONNECT_MAX = 60.0
+RECONNECT_ATTEMPTS = 8
+
+reconnect = {"port": None, "attempt": 0, "timer": None}
+
+
+def schedule_reconnect():
+    if reconnect["port"] is None or state["connected"]:
+        return
+    if reconnect["attempt"] >= RECONNECT_ATTEMPTS:
+        log("giving up on reverse connect")
+        publish_event("unreachable", {"uuid": UUID, "attempts": reconnect["attempt"]})
+        return
+    delay = min(RECONNECT_MAX, RECONNECT_BASE * (2 ** reconnect["attempt"]))
+    reconnect["attempt"] += 1
+    timer = threading.Timer(delay, try_reconnect)
+    timer.daemon = True
+    reconnect["timer"] = timer
+    timer.start()
+
+
+def try_reconnect():
+    def on_result(data, error):
+        if error:
+            log(f"reverse connect failed: {error}")
+            schedule_reconnect()
+        else:
+            mark_connected("reverse_connect")
+
+    with lock:
+        if threading.current_thread() is not reconnect["timer"]:
+            return  # cancelled or replaced while waiting for the lock
+        request("connect_robot", data={"port": reconnect["port"], "protocol": "tcp"}, callback=on_result)
+
+
+def cancel_reconnect():
+    if reconnect["timer"]:
+        reconnect["timer"].cancel()
+        reconnect["timer"] = None
+    reconnect["attempt"] = 0
+
+
+def mark_connected(via):
+    state["connected"] = True
+    state["last_seen"] = int(time.time())
+    cancel_reconnect()
+    publish_event("connected", {"uuid": UUID, "via": via})
+    flush_outbox()
+
+
+# --- Commands ----------------------------------------------------------------
+
+def reply(request_id, data=None, error=""):
+    """Publish a reply for an HTTP/frontend-originated command."""
+    publish_event("reply", {"uuid": UUID, "request_id": request_id, "data": data, "error": error})
+
+
+def cmd_get_state(payload):
+    reply(payload.get("request_id", ""), dict(state, queued=len(outbox)))
+
+
+def cmd_set(payload):
+    field, value = payload.get("field"), payload.get("value")
+    allowed = WRITABLE.get(field)
+    if allowed is None:
+        reply(payload.get("request_id", ""), error=f"field {field!r} is not writable")
+        return
+    if value not in allowed:
+        reply(payload.get("request_id", ""), error=f"{field} must be one of {sorted(allowed)}")
+        return
+    state[field] = value
+    save_state()
+    send_to_robot({"type": "set", "field": field, "value": value})
+    publish_event("state_changed", {"uuid": UUID, "field": field, "value": value})
+    reply(payload.get("request_id", ""), {field: value})
+
+
+def cmd_quick_action(payload):
+    """Forward a one-shot action to the robot and report whether it was delivered."""
+    action = payload.get("action", "")
+    request_id = payload.get("request_id", "")
+    if not action:
+        reply(request_id, error="action is required")
+        return
+    if not state["connected"]:
+        reply(request_id, error="robot offline")
+        return
+
+    def on_sent(_, error):
+        reply(request_id, None if error else {"action": action, "status": "sent"}, error)
+
+    request("robot", data={"type": "action", "action": action, "request_id": request_id}, callback=on_sent)
+
+
+def cmd_hello(payload):
+    port = payload.get("reverse_port")
+    if isinstance(port, int) and 0 < port < 65536:
+        reconnect["port"] = port
+    if "battery" in payload:
+        state["battery"] = payload["battery"]
+
+
+COMMANDS = {
+    "get_state": cmd_get_state,
+    "set": cmd_set,
+    "quick_action": cmd_quick_action,
+    "hello": cmd_hello,
+}
+
+
+# --- Protocol messages ------------------------------------------------------
+
+def handle_connect(msg):
+    log(f"robot {msg.get('uuid')} connected from {msg.get('ip')}")
+    send("config", method="forward_heartbeats", data=True)
+    mark_connected("robot")
+
+
+def handle_incoming(msg):
+    payload_raw = msg.get("payload", "")
+    state["messages_received"] += 1
+    state["last_seen"] = int(time.time())
+
+    try:
+        payload = json.loads(payload_raw) if isinstance(payload_raw, str) else payload_raw
+    except json.JSONDecodeError:
+        payload = None
+    if not isinstance(payload, dict):
+        payload = {"command": str(payload_raw)}
+
+    handler = COMMANDS.get(payload.get("command", ""))
+    if handler:
+        handler(payload)
+    else:
+        reply(payload.get("request_id", ""), error=f"unknown command; available: {sorted(COMMANDS)}")
+
+
+def handle_disconnect(msg):
+    reason = msg.get("reason", "unknown")
+    state["connected"] = False
+    log(f"robot disconnected: {reason}")
+    publish_event("disconnected", {"uuid": UUID, "reason": reason})
+    schedule_reconnect()
+
+
+def handle_shadow_delta(msg):
+    """Apply desired state the robot hasn't reported yet, then report it."""
+    applied = {}
+    for field, value in (msg.get("state") or {}).items():
+        if isinstance(value, str) and value in WRITABLE.get(field, ()):
+            state[field] = value
+            send_to_robot({"type": "set", "field": field, "value": value})
+            applied[field] = value
+        else:
+            log(f"shadow: ignoring {field}={value!r}")
+    if applied:
+        save_state()
+        send("shadow", method="report", data=applied)
+        publish_event("state_changed", {"uuid": UUID, "shadow_version": msg.get("version"), **applied})
+
+
+def handle_heartbeat(msg):
+    extra = (msg.get("data") or {}).get("extra_data") or {}
+    if "battery" in extra:
+        state["battery"] = extra["battery"]
+    state["last_seen"] = int(time.time())
+
+
+def main():
+    with lock:
+        restore_state()
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        with lock:
+            if msg.get("target") == "response":
+                handle_response(msg)
+                continue
+
+            msg_type = msg.get("type", "")
+            if msg_type == "connect":
+                handle_connect(msg)
+            elif msg_type == "incoming":
+                handle_incoming(msg)
+            elif msg_type == "disconnect":
+                handle_disconnect(msg)
+            elif msg_type == "heartbeat":
+                handle_heartbeat(msg)
+            elif msg_type == "shadow_delta":
+                handle_shadow_delta(msg)
+            elif msg_type == "event":
+                log(f"event: {msg.get('event_type')}")
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,14 @@
+#!/bin/bash
+# Handler entry point for robot type: example_robot
+#
+# Reference implementation for new robot types. handler.py demonstrates every
+# part of the handler protocol end to end:
+#   - custom per-robot state, persisted via the database target
+#   - an outbound queue to the robot with queue-full handling
+#   - request/reply correlation for JSON-RPC calls (quick actions)
+#   - HTTP-driven commands (POST /robot/{uuid}/message) for reads and writes
+#   - connection handling with reverse-connect retry and backoff
+#
+# The seeded robot example-001 (db/seed.sql) uses this type.
+
+exec python3 "$(dirname "$0")/handler.py"
@@ -0,0 +1,226 @@
+#!/usr/bin/env python3
+"""
+Generic actuator handler — behaviour defined entirely by the robot's schema.
+
+On start the handler loads the schema via the database "get_schema" method
+(see roboserver/shared/actuator for the format, which the server validates on
+upload). Operators then send any declared command:
+
+    {"command": "set_speed", "params": {"rpm": 1200}, "request_id": "a1"}
+
+Params are checked for type, range, enum membership and required-ness before
+anything reaches the device, which receives:
+
+    {"type": "command", "command": "set_speed", "params": {"rpm": 1200}}
+
+The device reports state as {"type": "state", "fields": {"rpm": 1180}}; only
+fields declared under "state" are kept, and each change publishes
+generic_actuator.{uuid}.state_changed. Built-in commands: get_state, describe.
+"""
+import json
+import os
+import sys
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+BUILTINS = ("get_state", "describe")
+
+schema = {"commands": {}, "state": {}}
+loaded = {"ok": False, "error": "schema not loaded yet"}
+state = {"connected": False, "fields": {}}
+
+msg_counter = 0
+pending = {}
+
+
+def send(target, method="", data=None, callback=None):
+    """Send a JSON-RPC message to the roboserver."""
+    global msg_counter
+    msg_counter += 1
+    msg = {"target": target, "id": str(msg_counter)}
+    if method:
+        msg["method"] = method
+    if data is not None:
+        msg["data"] = data
+    if callback:
+        pending[msg["id"]] = callback
+    print(json.dumps(msg), flush=True)
+
+
+def publish_event(suffix, data):
+    send("event_bus", method=f"generic_actuator.{UUID}.{suffix}", data=data)
+
+
+def log(text):
+    print(f"[generic_actuator] {text}", file=sys.stderr, flush=True)
+
+
+# --- Validation --------------------------------------------------------------------
+# Mirrors roboserver/shared/actuator; the server has already rejected malformed
+# schemas, so only values are checked here.
+
+def check_value(name, spec, value):
+    kind = spec.get("type")
+    if kind in ("number", "integer"):
+        if isinstance(value, bool) or not isinstance(value, (int, float)):
+            return f"{name} must be a {kind}"
+        if kind == "integer" and not float(value).is_integer():
+            return f"{name} must be an integer"
+        if spec.get("min") is not None and value < spec["min"]:
+            return f"{name} must be >= {spec['min']}"
+        if spec.get("max") is not None and value > spec["max"]:
+            return f"{name} must be <= {spec['max']}"
+    elif kind == "boolean":
+        if not isinstance(value, bool):
+            return f"{name} must be a boolean"
+    elif kind == "string":
+        if not isinstance(value, str):
+            return f"{name} must be a string"
+    elif kind == "enum":
+        if value not in spec.get("values", []):
+            return f"{name} must be one of {spec.get('values', [])}"
+    return ""
+
+
+def check_params(command, params):
+    specs = schema["commands"][command].get("params") or {}
+    unknown = sorted(set(params) - set(specs))
+    if unknown:
+        return f"unknown params for {command}: {unknown}"
+    for name, spec in specs.items():
+        if name not in params:
+            if spec.get("required"):
+                return f"{name} is required"
+            continue
+        error = check_value(name, spec, params[name])
+        if error:
+            return error
+    return ""
+
+
+# --- Commands -----------------------------------------------------------------------
+
+def reply(payload, data=None, error=""):
+    publish_event("reply", {
+        "uuid": UUID, "request_id": payload.get("request_id", ""),
+        "data": data, "error": error,
+    })
+
+
+def run_command(payload):
+    name = payload.get("command", "")
+    if name == "get_state":
+        reply(payload, dict(state, schema_loaded=loaded["ok"]))
+        return
+    if name == "describe":
+        reply(payload, schema if loaded["ok"] else None, "" if loaded["ok"] else loaded["error"])
+        return
+
+    if not loaded["ok"]:
+        reply(payload, error=loaded["error"])
+        return
+    if name not in schema["commands"]:
+        reply(payload, error=f"unknown command; available: {sorted(schema['commands']) + list(BUILTINS)}")
+        return
+    params = payload.get("params") or {}
+    if not isinstance(params, dict):
+        reply(payload, error="params must be an object")
+        return
+    error = check_params(name, params)
+    if error:
+        reply(payload, error=error)
+        return
+    if not state["connected"]:
+        reply(payload, error="device offline")
+        return
+
+    def on_sent(_, send_error):
+        reply(payload, None if send_error else {"command": name, "status": "sent"}, send_error)
+
+    send("robot", data={"type": "command", "command": name, "params": params}, callback=on_sent)
+
+
+def handle_state(fields):
+    if not isinstance(fields, dict):
+        return
+    declared = schema["state"]
+    for name, value in fields.items():
+        spec = declared.get(name)
+        if spec is None:
+            continue  # undeclared fields are dropped rather than trusted
+        error = check_value(name, spec, value)
+        if error:
+            log(f"ignoring reported {error}")
+            continue
+        previous = state["fields"].get(name)
+        if previous == value and name in state["fields"]:
+            continue
+        state["fields"][name] = value
+        publish_event("state_changed", {"uuid": UUID, "field": name, "value": value, "previous": previous})
+
+
+def handle_incoming(msg):
+    raw = msg.get("payload", "")
+    try:
+        payload = json.loads(raw) if isinstance(raw, str) else raw
+    except json.JSONDecodeError:
+        payload = None
+    if not isinstance(payload, dict):
+        return
+
+    if payload.get("type") == "state":
+        handle_state(payload.get("fields"))
+    else:
+        run_command(payload)
+
+
+# --- Startup --------------------------------------------------------------------------
+
+def on_schema(data, error):
+    if error:
+        loaded["error"] = f"failed to load schema: {error}"
+        log(loaded["error"])
+        return
+    if not isinstance(data, dict):
+        loaded["error"] = "no schema uploaded; PUT /provision/{uuid}/schema"
+        log(loaded["error"])
+        return
+    schema["commands"] = data.get("commands") or {}
+    schema["state"] = data.get("state") or {}
+    loaded["ok"] = True
+    loaded["error"] = ""
+    log(f"loaded schema with {len(schema['commands'])} commands")
+
+
+def main():
+    send("database", method="get_schema", callback=on_schema)
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        if msg.get("target") == "response":
+            callback = pending.pop(msg.get("id", ""), None)
+            if callback:
+                callback(msg.get("data"), msg.get("error", ""))
+            continue
+
+        msg_type = msg.get("type", "")
+        if msg_type == "connect":
+            state["connected"] = True
+            log(f"device {msg.get('uuid')} connected from {msg.get('ip')}")
+        elif msg_type == "incoming":
+            handle_incoming(msg)
+        elif msg_type == "disconnect":
+            state["connected"] = False
+            log(f"device disconnected: {msg.get('reason', 'unknown')}")
+
+
+if __name__ == "__main__":
+    main()
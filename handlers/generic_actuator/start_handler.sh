@@ -0,0 +1,9 @@
+#!/bin/bash
+# Handler entry point for robot type: generic_actuator
+#
+# Drives one-off devices from the declarative schema uploaded at provisioning
+# (POST /provision with "schema", or PUT /provision/{uuid}/schema). Commands
+# and reported state are validated against it, so no device-specific handler
+# code is needed.
+
+exec python3 "$(dirname "$0")/handler.py"
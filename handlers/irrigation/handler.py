@@ -0,0 +1,447 @@
+#!/usr/bin/env python3
+"""
+Irrigation controller handler — zone valves, watering plans, rain skip.
+
+The controller opens one zone valve at a time. Runs are queued and executed
+in order; a run ends when its duration elapses, when the zone's soil reaches
+its moisture target, or on "stop".
+
+Robot -> handler:
+
+    {"type": "moisture", "zone": 2, "percent": 41}
+    {"type": "valve", "zone": 2, "open": false}     - valve state confirmation
+
+Commands (POST /robot/{uuid}/message):
+
+    {"command": "get_state"}
+    {"command": "run_zone", "zone": 2, "minutes": 10}
+    {"command": "stop"}
+    {"command": "set_schedule", "plans": [{"zone": 1, "at": "06:30", "days": [0, 2, 4], "minutes": 15}]}
+    {"command": "set_zone", "zone": 1, "moisture_target": 60}
+    {"command": "set_rain", "events": ["env_sensor.garden-1.rain"], "skip_hours": 24}
+    {"command": "skip", "hours": 12}                - manual rain delay; 0 clears it
+
+Days use Python's weekday numbering (Monday = 0). Plans are stored under the
+"schedule" data key and run history under "runs", both readable via
+GET /robot/{uuid}/data/{key}.
+"""
+import json
+import os
+import sys
+import threading
+import time
+from collections import deque
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+MAX_ZONES = 16
+MAX_RUN_MINUTES = 180
+RUN_HISTORY = 200
+TICK_SECONDS = 20
+DEFAULT_RAIN_EVENT = "weather.rain"
+
+state = {
+    "connected": False,
+    "active": None,          # {"zone", "started", "ends_at", "source"}
+    "skip_until": 0,
+    "moisture": {},          # zone -> percent
+    "valves": {},            # zone -> open (as confirmed by the controller)
+}
+config = {
+    "zones": {},             # zone -> {"moisture_target": percent}
+    "rain_events": [],
+    "skip_hours": 24,
+}
+schedule = []                # plans: {"zone", "at", "days", "minutes"}
+queue = deque()              # pending runs: {"zone", "minutes", "source"}
+runs = []                    # finished runs, oldest first
+last_fired = {}              # plan index -> "YYYY-MM-DD HH:MM" it last ran
+subscribed = set()
+
+# The stdin loop and the scheduler thread both mutate handler state.
+lock = threading.RLock()
+_out_lock = threading.Lock()
+msg_counter = 0
+pending = {}
+
+
+def send(target, method="", data=None, callback=None):
+    """Send a JSON-RPC message to the roboserver."""
+    global msg_counter
+    with _out_lock:
+        msg_counter += 1
+        msg = {"target": target, "id": str(msg_counter)}
+        if method:
+            msg["method"] = method
+        if data is not None:
+            msg["data"] = data
+        if callback:
+            pending[msg["id"]] = callback
+        print(json.dumps(msg), flush=True)
+
+
+def publish_event(suffix, data):
+    send("event_bus", method=f"irrigation.{UUID}.{suffix}", data=data)
+
+
+def store(key, value):
+    send("database", method="store_data", data={"key": key, "value": value})
+
+
+def log(text):
+    print(f"[irrigation] {text}", file=sys.stderr, flush=True)
+
+
+def valid_zone(zone):
+    return isinstance(zone, int) and not isinstance(zone, bool) and 1 <= zone <= MAX_ZONES
+
+
+def moisture_target(zone):
+    return config["zones"].get(str(zone), {}).get("moisture_target")
+
+
+# --- Runs ------------------------------------------------------------------------
+
+def enqueue_run(zone, minutes, source):
+    queue.append({"zone": zone, "minutes": minutes, "source": source})
+    start_next()
+
+
+def start_next():
+    if state["active"] or not queue or not state["connected"]:
+        return
+    run = queue.popleft()
+    now = time.time()
+    state["active"] = {
+        "zone": run["zone"],
+        "started": int(now),
+        "ends_at": int(now + run["minutes"] * 60),
+        "source": run["source"],
+    }
+    send("robot", data={"type": "valve", "zone": run["zone"], "open": True})
+    publish_event("zone_started", dict(state["active"], uuid=UUID))
+
+
+def finish_active(reason):
+    active = state["active"]
+    if not active:
+        return
+    state["active"] = None
+    send("robot", data={"type": "valve", "zone": active["zone"], "open": False})
+
+    entry = dict(active, ended=int(time.time()), reason=reason)
+    entry["minutes"] = round((entry["ended"] - entry["started"]) / 60, 1)
+    runs.append(entry)
+    del runs[:-RUN_HISTORY]
+    store("runs", runs)
+    publish_event("zone_finished", dict(entry, uuid=UUID))
+    start_next()
+
+
+def rain_delay_active():
+    return time.time() < state["skip_until"]
+
+
+# --- Scheduler ---------------------------------------------------------------------
+
+def tick():
+    """Runs every TICK_SECONDS: ends timed-out runs and fires due plans."""
+    now = time.time()
+    active = state["active"]
+    if active and now >= active["ends_at"]:
+        finish_active("completed")
+
+    local = time.localtime(now)
+    hhmm = time.strftime("%H:%M", local)
+    stamp = time.strftime("%Y-%m-%d %H:%M", local)
+    for i, plan in enumerate(schedule):
+        if plan["at"] != hhmm or local.tm_wday not in plan["days"]:
+            continue
+        if last_fired.get(i) == stamp:
+            continue
+        last_fired[i] = stamp
+        fire_plan(plan)
+
+
+def fire_plan(plan):
+    zone = plan["zone"]
+    if rain_delay_active():
+        record_skip(zone, "rain_delay")
+        return
+    target = moisture_target(zone)
+    level = state["moisture"].get(str(zone))
+    if target is not None and level is not None and level >= target:
+        record_skip(zone, "soil_moist")
+        return
+    enqueue_run(zone, plan["minutes"], "schedule")
+
+
+def record_skip(zone, reason):
+    log(f"skipping zone {zone}: {reason}")
+    publish_event("run_skipped", {"uuid": UUID, "zone": zone, "reason": reason})
+
+
+def scheduler_loop():
+    while True:
+        time.sleep(TICK_SECONDS)
+        with lock:
+            tick()
+
+
+# --- Rain ------------------------------------------------------------------------
+
+def subscribe_rain_events():
+    for event in [DEFAULT_RAIN_EVENT] + config["rain_events"]:
+        if event not in subscribed:
+            subscribed.add(event)
+            send("config", method="subscribe", data=event)
+
+
+def handle_rain(event_type, data):
+    # A sensor can clear the delay early by publishing {"raining": false}.
+    if isinstance(data, dict) and data.get("raining") is False:
+        state["skip_until"] = 0
+        publish_event("rain_delay", {"uuid": UUID, "until": 0, "source": event_type})
+        return
+    state["skip_until"] = int(time.time() + config["skip_hours"] * 3600)
+    publish_event("rain_delay", {"uuid": UUID, "until": state["skip_until"], "source": event_type})
+    if state["active"] and state["active"]["source"] == "schedule":
+        finish_active("rain")
+    queue_kept = [r for r in queue if r["source"] != "schedule"]
+    queue.clear()
+    queue.extend(queue_kept)
+
+
+# --- Commands ----------------------------------------------------------------------
+
+def reply(payload, data=None, error=""):
+    publish_event("reply", {
+        "uuid": UUID, "request_id": payload.get("request_id", ""),
+        "data": data, "error": error,
+    })
+
+
+def cmd_get_state(payload):
+    reply(payload, dict(state, queue=list(queue), schedule=schedule, config=config,
+                        rain_delay=rain_delay_active()))
+
+
+def cmd_run_zone(payload):
+    zone, minutes = payload.get("zone"), payload.get("minutes")
+    if not valid_zone(zone):
+        reply(payload, error=f"zone must be 1-{MAX_ZONES}")
+        return
+    if not isinstance(minutes, (int, float)) or isinstance(minutes, bool) or not 0 < minutes <= MAX_RUN_MINUTES:
+        reply(payload, error=f"minutes must be between 0 and {MAX_RUN_MINUTES}")
+        return
+    enqueue_run(zone, minutes, "manual")
+    reply(payload, {"queued": len(queue), "active": state["active"]})
+
+
+def cmd_stop(payload):
+    queue.clear()
+    finish_active("stopped")
+    reply(payload, {"stopped": True})
+
+
+def parse_plan(plan):
+    if not isinstance(plan, dict) or not valid_zone(plan.get("zone")):
+        return None, f"each plan needs a zone between 1 and {MAX_ZONES}"
+    at = plan.get("at", "")
+    try:
+        parsed = time.strptime(at, "%H:%M")
+    except (TypeError, ValueError):
+        return None, "at must be HH:MM"
+    days = plan.get("days", list(range(7)))
+    if not isinstance(days, list) or not all(isinstance(d, int) and 0 <= d <= 6 for d in days):
+        return None, "days must be a list of weekdays 0-6"
+    minutes = plan.get("minutes")
+    if not isinstance(minutes, (int, float)) or isinstance(minutes, bool) or not 0 < minutes <= MAX_RUN_MINUTES:
+        return None, f"minutes must be between 0 and {MAX_RUN_MINUTES}"
+    return {
+        "zone": plan["zone"],
+        "at": time.strftime("%H:%M", parsed),
+        "days": sorted(set(days)),
+        "minutes": minutes,
+    }, ""
+
+
+def cmd_set_schedule(payload):
+    plans = payload.get("plans")
+    if not isinstance(plans, list):
+        reply(payload, error="plans must be a list")
+        return
+    parsed = []
+    for plan in plans:
+        p, error = parse_plan(plan)
+        if error:
+            reply(payload, error=error)
+            return
+        parsed.append(p)
+    schedule[:] = parsed
+    last_fired.clear()
+    store("schedule", schedule)
+    reply(payload, {"plans": schedule})
+
+
+def cmd_set_zone(payload):
+    zone, target = payload.get("zone"), payload.get("moisture_target")
+    if not valid_zone(zone):
+        reply(payload, error=f"zone must be 1-{MAX_ZONES}")
+        return
+    if target is not None and (not isinstance(target, (int, float)) or not 0 < target <= 100):
+        reply(payload, error="moisture_target must be a percentage or null")
+        return
+    config["zones"][str(zone)] = {"moisture_target": target}
+    store("config", config)
+    reply(payload, {"zone": zone, "moisture_target": target})
+
+
+def cmd_set_rain(payload):
+    events = payload.get("events", config["rain_events"])
+    hours = payload.get("skip_hours", config["skip_hours"])
+    if not isinstance(events, list) or not all(isinstance(e, str) and e for e in events):
+        reply(payload, error="events must be a list of event names")
+        return
+    if not isinstance(hours, (int, float)) or not 0 < hours <= 168:
+        reply(payload, error="skip_hours must be between 0 and 168")
+        return
+    # Subscriptions can't be withdrawn individually; removed names are ignored on arrival.
+    config["rain_events"] = events
+    config["skip_hours"] = hours
+    store("config", config)
+    subscribe_rain_events()
+    reply(payload, {"events": events, "skip_hours": hours})
+
+
+def cmd_skip(payload):
+    hours = payload.get("hours")
+    if not isinstance(hours, (int, float)) or not 0 <= hours <= 168:
+        reply(payload, error="hours must be between 0 and 168")
+        return
+    state["skip_until"] = int(time.time() + hours * 3600) if hours else 0
+    publish_event("rain_delay", {"uuid": UUID, "until": state["skip_until"], "source": "manual"})
+    reply(payload, {"skip_until": state["skip_until"]})
+
+
+COMMANDS = {
+    "get_state": cmd_get_state,
+    "run_zone": cmd_run_zone,
+    "stop": cmd_stop,
+    "set_schedule": cmd_set_schedule,
+    "set_zone": cmd_set_zone,
+    "set_rain": cmd_set_rain,
+    "skip": cmd_skip,
+}
+
+
+# --- Robot reports -------------------------------------------------------------------
+
+def handle_moisture(payload):
+    zone, percent = payload.get("zone"), payload.get("percent")
+    if not valid_zone(zone) or not isinstance(percent, (int, float)):
+        return
+    state["moisture"][str(zone)] = percent
+    publish_event("moisture", {"uuid": UUID, "zone": zone, "percent": percent})
+
+    active = state["active"]
+    target = moisture_target(zone)
+    if active and active["zone"] == zone and target is not None and percent >= target:
+        finish_active("moisture_reached")
+
+
+def handle_incoming(msg):
+    raw = msg.get("payload", "")
+    try:
+        payload = json.loads(raw) if isinstance(raw, str) else raw
+    except json.JSONDecodeError:
+        payload = None
+    if not isinstance(payload, dict):
+        return
+
+    kind = payload.get("type")
+    if kind == "moisture":
+        handle_moisture(payload)
+    elif kind == "valve" and valid_zone(payload.get("zone")):
+        state["valves"][str(payload["zone"])] = bool(payload.get("open"))
+    else:
+        handler = COMMANDS.get(payload.get("command", ""))
+        if handler:
+            handler(payload)
+        else:
+            reply(payload, error=f"unknown command; available: {sorted(COMMANDS)}")
+
+
+def handle_event(msg):
+    event_type = msg.get("event_type", "")
+    if event_type == DEFAULT_RAIN_EVENT or event_type in config["rain_events"]:
+        handle_rain(event_type, msg.get("data"))
+
+
+# --- Startup ---------------------------------------------------------------------------
+
+def restore():
+    def on_schedule(data, error):
+        if error or not isinstance(data, list):
+            return
+        for plan in data:
+            p, err = parse_plan(plan)
+            if not err:
+                schedule.append(p)
+
+    def on_config(data, error):
+        if not error and isinstance(data, dict):
+            config.update({k: data[k] for k in config if k in data})
+            subscribe_rain_events()
+
+    def on_runs(data, error):
+        if not error and isinstance(data, list):
+            runs[:0] = data
+            del runs[:-RUN_HISTORY]
+
+    send("database", method="get_data", data="schedule", callback=on_schedule)
+    send("database", method="get_data", data="config", callback=on_config)
+    send("database", method="get_data", data="runs", callback=on_runs)
+
+
+def main():
+    with lock:
+        restore()
+        subscribe_rain_events()
+    threading.Thread(target=scheduler_loop, daemon=True).start()
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        with lock:
+            if msg.get("target") == "response":
+                callback = pending.pop(msg.get("id", ""), None)
+                if callback:
+                    callback(msg.get("data"), msg.get("error", ""))
+                continue
+
+            msg_type = msg.get("type", "")
+            if msg_type == "connect":
+                state["connected"] = True
+                log(f"controller {msg.get('uuid')} connected from {msg.get('ip')}")
+                start_next()
+            elif msg_type == "incoming":
+                handle_incoming(msg)
+            elif msg_type == "event":
+                handle_event(msg)
+            elif msg_type == "disconnect":
+                state["connected"] = False
+                log(f"controller disconnected: {msg.get('reason', 'unknown')}")
+                # The controller closes its own valves on link loss; record the run as cut short.
+                finish_active("disconnected")
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,9 @@
+#!/bin/bash
+# Handler entry point for robot type: irrigation
+#
+# A multi-zone irrigation controller. Watering plans are persisted server-side
+# (handler data key "schedule") and run by this handler one zone at a time.
+# Runs end early on moisture feedback, and planned runs are skipped while a
+# rain event (weather.rain, or any configured sensor event) is in effect.
+
+exec python3 "$(dirname "$0")/handler.py"
@@ -0,0 +1,290 @@
+#!/usr/bin/env python3
+"""
+Smart lock handler — locked / unlocked / jammed with an audit trail.
+
+Actuations (lock, unlock, set_relock) are only accepted from messages that
+carry a verified "actor", i.e. sent via POST /robot/{uuid}/control with the
+operator's password. Plain /message requests can only read state.
+
+Every actuation, whether by a user or by the auto-relock rule, is recorded
+before the command goes to the lock. Entries are kept under the "audit" data
+key (GET /robot/{uuid}/data/audit) and published as smart_lock.{uuid}.audit.
+
+The lock reports its physical state back as:
+
+    {"type": "state", "state": "locked" | "unlocked" | "jammed"}
+
+Operator commands (JSON in the message body):
+
+    {"command": "lock"}
+    {"command": "unlock"}
+    {"command": "set_relock", "seconds": 30}   - 0 disables auto-relock
+    {"command": "get_state"}
+"""
+import json
+import os
+import sys
+import threading
+import time
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+STATES = ("locked", "unlocked", "jammed")
+AUDIT_LIMIT = 500
+RELOCK_RULE = "rule:auto_relock"
+MAX_RELOCK_SECONDS = 24 * 3600
+
+state = {
+    "connected": False,
+    "state": "unknown",      # last state reported by the lock
+    "requested": None,       # last commanded state, awaiting confirmation
+    "relock_seconds": 30,
+    "relock_at": None,
+}
+audit = []
+relock_timer = None
+
+lock = threading.RLock()  # guards state; the relock timer runs on its own thread
+_out_lock = threading.Lock()  # the relock timer writes from its own thread
+msg_counter = 0
+pending = {}
+
+
+def send(target, method="", data=None, callback=None):
+    """Send a JSON-RPC message to the roboserver."""
+    global msg_counter
+    with _out_lock:
+        msg_counter += 1
+        msg = {"target": target, "id": str(msg_counter)}
+        if method:
+            msg["method"] = method
+        if data is not None:
+            msg["data"] = data
+        if callback:
+            pending[msg["id"]] = callback
+        print(json.dumps(msg), flush=True)
+
+
+def publish_event(suffix, data):
+    send("event_bus", method=f"smart_lock.{UUID}.{suffix}", data=data)
+
+
+def log(text):
+    print(f"[smart_lock] {text}", file=sys.stderr, flush=True)
+
+
+# --- Audit -----------------------------------------------------------------------
+
+def record(action, actor, result, **extra):
+    """Append an audit entry. Called before the lock is actuated, never after."""
+    entry = {
+        "ts": int(time.time()),
+        "action": action,
+        "actor": actor,
+        "result": result,
+        "state": state["state"],
+    }
+    entry.update(extra)
+    audit.append(entry)
+    del audit[:-AUDIT_LIMIT]
+    send("database", method="store_data", data={"key": "audit", "value": audit})
+    publish_event("audit", dict(entry, uuid=UUID))
+    return entry
+
+
+# --- Actuation -------------------------------------------------------------------
+
+def actuate(target_state, actor):
+    action = "lock" if target_state == "locked" else "unlock"
+    if not state["connected"]:
+        record(action, actor, "rejected", reason="lock offline")
+        return "lock offline"
+    if state["state"] == "jammed":
+        # A jammed bolt may still move; allow it but flag it in the trail.
+        record(action, actor, "sent", note="lock was jammed")
+    else:
+        record(action, actor, "sent")
+
+    state["requested"] = target_state
+
+    def on_sent(_, error):
+        if error:
+            record(action, actor, "failed", reason=error)
+
+    send("robot", data={"type": "actuate", "state": target_state}, callback=on_sent)
+    return ""
+
+
+def schedule_relock():
+    global relock_timer
+    cancel_relock()
+    seconds = state["relock_seconds"]
+    if not seconds:
+        return
+    state["relock_at"] = int(time.time()) + seconds
+    relock_timer = threading.Timer(seconds, relock)
+    relock_timer.daemon = True
+    relock_timer.start()
+
+
+def cancel_relock():
+    global relock_timer
+    if relock_timer:
+        relock_timer.cancel()
+        relock_timer = None
+    state["relock_at"] = None
+
+
+def relock():
+    with lock:
+        if threading.current_thread() is not relock_timer:
+            return  # cancelled or replaced while waiting for the lock
+        state["relock_at"] = None
+        if state["state"] == "unlocked":
+            actuate("locked", RELOCK_RULE)
+
+
+def handle_state_report(new_state):
+    if new_state not in STATES:
+        return
+    previous = state["state"]
+    state["state"] = new_state
+    if state["requested"] == new_state:
+        state["requested"] = None
+    if new_state == previous:
+        return
+
+    publish_event("state_changed", {"uuid": UUID, "from": previous, "to": new_state})
+    if new_state == "unlocked":
+        schedule_relock()
+    else:
+        cancel_relock()
+    if new_state == "jammed":
+        record("jammed", "device", "reported", previous=previous)
+        publish_event("jammed", {"uuid": UUID, "previous": previous})
+
+
+# --- Commands --------------------------------------------------------------------
+
+def reply(payload, data=None, error=""):
+    publish_event("reply", {
+        "uuid": UUID, "request_id": payload.get("request_id", ""),
+        "data": data, "error": error,
+    })
+
+
+def cmd_get_state(payload, actor):
+    reply(payload, dict(state, audit_entries=len(audit)))
+
+
+def cmd_lock(payload, actor):
+    error = actuate("locked", actor)
+    reply(payload, None if error else {"requested": "locked"}, error)
+
+
+def cmd_unlock(payload, actor):
+    error = actuate("unlocked", actor)
+    reply(payload, None if error else {"requested": "unlocked"}, error)
+
+
+def cmd_set_relock(payload, actor):
+    seconds = payload.get("seconds")
+    if isinstance(seconds, bool) or not isinstance(seconds, int) or not 0 <= seconds <= MAX_RELOCK_SECONDS:
+        reply(payload, error=f"seconds must be an integer between 0 and {MAX_RELOCK_SECONDS}")
+        return
+    record("set_relock", actor, "applied", seconds=seconds, previous_seconds=state["relock_seconds"])
+    state["relock_seconds"] = seconds
+    send("database", method="store_data", data={"key": "config", "value": {"relock_seconds": seconds}})
+    if state["state"] == "unlocked":
+        schedule_relock()
+    reply(payload, {"relock_seconds": seconds})
+
+
+# name -> (function, requires verified actor)
+COMMANDS = {
+    "get_state": (cmd_get_state, False),
+    "lock": (cmd_lock, True),
+    "unlock": (cmd_unlock, True),
+    "set_relock": (cmd_set_relock, True),
+}
+
+
+def handle_incoming(msg):
+    raw = msg.get("payload", "")
+    actor = msg.get("actor", "")
+    try:
+        payload = json.loads(raw) if isinstance(raw, str) else raw
+    except json.JSONDecodeError:
+        payload = None
+    if not isinstance(payload, dict):
+        return
+
+    if payload.get("type") == "state" and not actor:
+        handle_state_report(payload.get("state"))
+        return
+
+    name = payload.get("command", "")
+    entry = COMMANDS.get(name)
+    if entry is None:
+        reply(payload, error=f"unknown command; available: {sorted(COMMANDS)}")
+        return
+    func, needs_actor = entry
+    if needs_actor and not actor:
+        record(name, "unverified", "rejected", reason="re-authentication required")
+        reply(payload, error="use POST /robot/{uuid}/control with your password for this command")
+        return
+    func(payload, actor)
+
+
+# --- Startup ---------------------------------------------------------------------
+
+def restore():
+    def on_config(data, error):
+        if not error and isinstance(data, dict) and isinstance(data.get("relock_seconds"), int):
+            state["relock_seconds"] = data["relock_seconds"]
+
+    def on_audit(data, error):
+        if not error and isinstance(data, list):
+            audit[:0] = data
+            del audit[:-AUDIT_LIMIT]
+
+    send("database", method="get_data", data="config", callback=on_config)
+    send("database", method="get_data", data="audit", callback=on_audit)
+
+
+def main():
+    with lock:
+        restore()
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        with lock:
+            if msg.get("target") == "response":
+                callback = pending.pop(msg.get("id", ""), None)
+                if callback:
+                    callback(msg.get("data"), msg.get("error", ""))
+                continue
+
+            msg_type = msg.get("type", "")
+            if msg_type == "connect":
+                state["connected"] = True
+                log(f"lock {msg.get('uuid')} connected from {msg.get('ip')}")
+                send("robot", data={"type": "get_state"})
+            elif msg_type == "incoming":
+                handle_incoming(msg)
+            elif msg_type == "disconnect":
+                state["connected"] = False
+                log(f"lock disconnected: {msg.get('reason', 'unknown')}")
+                publish_event("offline", {"uuid": UUID, "state": state["state"]})
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,9 @@
+#!/bin/bash
+# Handler entry point for robot type: smart_lock
+#
+# A door lock with locked/unlocked/jammed states. Every actuation is written
+# to an audit trail naming the user or rule that triggered it, and an unlocked
+# door relocks itself after a per-device delay. Lock/unlock must come through
+# POST /robot/{uuid}/control, which re-checks the operator's password.
+
+exec python3 "$(dirname "$0")/handler.py"
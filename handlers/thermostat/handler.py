@@ -0,0 +1,348 @@
+#!/usr/bin/env python3
+"""
+Thermostat handler — setpoint, heat/cool/off mode, schedules and holds.
+
+The device reports its sensor reading; the handler decides the setpoint and
+pushes it down:
+
+    robot -> handler  {"type": "temperature", "celsius": 20.7}
+    handler -> robot  {"type": "apply", "mode": "heat", "setpoint": 21.0}
+
+Setpoint sources, highest priority first:
+  1. a hold (permanent, or until a timestamp)
+  2. the schedule entry in effect for the current weekday/time
+  3. the last manually set setpoint
+
+Commands (POST /robot/{uuid}/message):
+
+    {"command": "get_state"}
+    {"command": "set_mode", "mode": "heat" | "cool" | "off"}
+    {"command": "set_setpoint", "celsius": 21.5}
+    {"command": "hold", "celsius": 23, "minutes": 120}   - omit minutes for a permanent hold
+    {"command": "resume"}                                - drop the hold, follow the schedule
+    {"command": "set_schedule", "entries": [{"days": [0,1,2,3,4], "at": "06:30", "celsius": 21}]}
+
+Temperature changes of at least REPORT_DELTA publish
+thermostat.{uuid}.temperature_changed with the current and previous values.
+"""
+import json
+import os
+import sys
+import threading
+import time
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+MODES = ("heat", "cool", "off")
+MIN_SETPOINT, MAX_SETPOINT = 5.0, 35.0
+REPORT_DELTA = 0.2
+TICK_SECONDS = 30
+
+state = {
+    "connected": False,
+    "current": None,        # last reported temperature
+    "reported_at": None,
+    "mode": "off",
+    "setpoint": 20.0,       # manual setpoint, used when no hold or schedule applies
+    "hold": None,           # {"celsius", "until"} - until None means permanent
+    "effective": None,      # {"celsius", "source"} last pushed to the device
+}
+schedule = []               # {"days", "at", "celsius"}
+last_published = {"celsius": None}
+
+lock = threading.RLock()
+_out_lock = threading.Lock()
+msg_counter = 0
+pending = {}
+
+
+def send(target, method="", data=None, callback=None):
+    """Send a JSON-RPC message to the roboserver."""
+    global msg_counter
+    with _out_lock:
+        msg_counter += 1
+        msg = {"target": target, "id": str(msg_counter)}
+        if method:
+            msg["method"] = method
+        if data is not None:
+            msg["data"] = data
+        if callback:
+            pending[msg["id"]] = callback
+        print(json.dumps(msg), flush=True)
+
+
+def publish_event(suffix, data):
+    send("event_bus", method=f"thermostat.{UUID}.{suffix}", data=data)
+
+
+def log(text):
+    print(f"[thermostat] {text}", file=sys.stderr, flush=True)
+
+
+def valid_celsius(value):
+    return isinstance(value, (int, float)) and not isinstance(value, bool) and MIN_SETPOINT <= value <= MAX_SETPOINT
+
+
+def save():
+    send("database", method="store_data", data={"key": "settings", "value": {
+        "mode": state["mode"], "setpoint": state["setpoint"], "hold": state["hold"],
+    }})
+
+
+# --- Setpoint resolution ---------------------------------------------------------
+
+def scheduled_setpoint(now):
+    """Return the schedule entry in effect: the latest one at or before now this
+    week, wrapping around to last week's final entry."""
+    if not schedule:
+        return None
+    local = time.localtime(now)
+    minute_of_week = local.tm_wday * 1440 + local.tm_hour * 60 + local.tm_min
+    best, best_key = None, None
+    for entry in schedule:
+        h, m = map(int, entry["at"].split(":"))
+        for day in entry["days"]:
+            key = day * 1440 + h * 60 + m
+            # Shift entries later in the week back by a week so "latest before now" wraps.
+            if key > minute_of_week:
+                key -= 7 * 1440
+            if best_key is None or key > best_key:
+                best, best_key = entry, key
+    return best
+
+
+def resolve(now):
+    hold = state["hold"]
+    if hold and hold["until"] is not None and now >= hold["until"]:
+        state["hold"] = None
+        save()
+        publish_event("hold_expired", {"uuid": UUID})
+        hold = None
+    if hold:
+        return hold["celsius"], "hold"
+    entry = scheduled_setpoint(now)
+    if entry:
+        return entry["celsius"], "schedule"
+    return state["setpoint"], "manual"
+
+
+def apply(force=False):
+    """Push the mode and effective setpoint to the device if either changed."""
+    celsius, source = resolve(time.time())
+    effective = {"celsius": celsius, "source": source, "mode": state["mode"]}
+    if effective == state["effective"] and not force:
+        return
+    state["effective"] = effective
+    if state["connected"]:
+        send("robot", data={"type": "apply", "mode": state["mode"], "setpoint": celsius})
+    publish_event("setpoint_changed", dict(effective, uuid=UUID))
+
+
+def scheduler_loop():
+    while True:
+        time.sleep(TICK_SECONDS)
+        with lock:
+            apply()
+
+
+# --- Device reports ----------------------------------------------------------------
+
+def handle_temperature(celsius):
+    if isinstance(celsius, bool) or not isinstance(celsius, (int, float)):
+        return
+    state["current"] = celsius
+    state["reported_at"] = int(time.time())
+    previous = last_published["celsius"]
+    if previous is None or abs(celsius - previous) >= REPORT_DELTA:
+        last_published["celsius"] = celsius
+        publish_event("temperature_changed", {
+            "uuid": UUID,
+            "celsius": celsius,
+            "previous": previous,
+            "setpoint": (state["effective"] or {}).get("celsius"),
+            "mode": state["mode"],
+        })
+
+
+# --- Commands ----------------------------------------------------------------------
+
+def reply(payload, data=None, error=""):
+    publish_event("reply", {
+        "uuid": UUID, "request_id": payload.get("request_id", ""),
+        "data": data, "error": error,
+    })
+
+
+def cmd_get_state(payload):
+    reply(payload, dict(state, schedule=schedule))
+
+
+def cmd_set_mode(payload):
+    mode = payload.get("mode")
+    if mode not in MODES:
+        reply(payload, error=f"mode must be one of {list(MODES)}")
+        return
+    state["mode"] = mode
+    save()
+    apply()
+    reply(payload, {"mode": mode})
+
+
+def cmd_set_setpoint(payload):
+    celsius = payload.get("celsius")
+    if not valid_celsius(celsius):
+        reply(payload, error=f"celsius must be between {MIN_SETPOINT} and {MAX_SETPOINT}")
+        return
+    state["setpoint"] = celsius
+    save()
+    apply()
+    # A manual setpoint is overridden by an active hold or schedule; say which applies.
+    reply(payload, {"setpoint": celsius, "effective": state["effective"]})
+
+
+def cmd_hold(payload):
+    celsius, minutes = payload.get("celsius"), payload.get("minutes")
+    if not valid_celsius(celsius):
+        reply(payload, error=f"celsius must be between {MIN_SETPOINT} and {MAX_SETPOINT}")
+        return
+    if minutes is not None and (not isinstance(minutes, (int, float)) or isinstance(minutes, bool) or minutes <= 0):
+        reply(payload, error="minutes must be positive, or omitted for a permanent hold")
+        return
+    until = int(time.time() + minutes * 60) if minutes is not None else None
+    state["hold"] = {"celsius": celsius, "until": until}
+    save()
+    apply()
+    reply(payload, {"hold": state["hold"]})
+
+
+def cmd_resume(payload):
+    state["hold"] = None
+    save()
+    apply()
+    reply(payload, {"effective": state["effective"]})
+
+
+def parse_entry(entry):
+    if not isinstance(entry, dict) or not valid_celsius(entry.get("celsius")):
+        return None, f"each entry needs celsius between {MIN_SETPOINT} and {MAX_SETPOINT}"
+    try:
+        at = time.strftime("%H:%M", time.strptime(entry.get("at", ""), "%H:%M"))
+    except (TypeError, ValueError):
+        return None, "at must be HH:MM"
+    days = entry.get("days", list(range(7)))
+    if not isinstance(days, list) or not days or not all(isinstance(d, int) and 0 <= d <= 6 for d in days):
+        return None, "days must be a non-empty list of weekdays 0-6 (Monday = 0)"
+    return {"days": sorted(set(days)), "at": at, "celsius": entry["celsius"]}, ""
+
+
+def cmd_set_schedule(payload):
+    entries = payload.get("entries")
+    if not isinstance(entries, list):
+        reply(payload, error="entries must be a list")
+        return
+    parsed = []
+    for entry in entries:
+        e, error = parse_entry(entry)
+        if error:
+            reply(payload, error=error)
+            return
+        parsed.append(e)
+    schedule[:] = parsed
+    send("database", method="store_data", data={"key": "schedule", "value": schedule})
+    apply()
+    reply(payload, {"entries": schedule, "effective": state["effective"]})
+
+
+COMMANDS = {
+    "get_state": cmd_get_state,
+    "set_mode": cmd_set_mode,
+    "set_setpoint": cmd_set_setpoint,
+    "hold": cmd_hold,
+    "resume": cmd_resume,
+    "set_schedule": cmd_set_schedule,
+}
+
+
+def handle_incoming(msg):
+    raw = msg.get("payload", "")
+    try:
+        payload = json.loads(raw) if isinstance(raw, str) else raw
+    except json.JSONDecodeError:
+        payload = None
+    if not isinstance(payload, dict):
+        return
+
+    if payload.get("type") == "temperature":
+        handle_temperature(payload.get("celsius"))
+        return
+    handler = COMMANDS.get(payload.get("command", ""))
+    if handler:
+        handler(payload)
+    else:
+        reply(payload, error=f"unknown command; available: {sorted(COMMANDS)}")
+
+
+# --- Startup --------------------------------------------------------------------------
+
+def restore():
+    def on_settings(data, error):
+        if error or not isinstance(data, dict):
+            return
+        if data.get("mode") in MODES:
+            state["mode"] = data["mode"]
+        if valid_celsius(data.get("setpoint")):
+            state["setpoint"] = data["setpoint"]
+        hold = data.get("hold")
+        if isinstance(hold, dict) and valid_celsius(hold.get("celsius")):
+            state["hold"] = {"celsius": hold["celsius"], "until": hold.get("until")}
+        apply()
+
+    def on_schedule(data, error):
+        if error or not isinstance(data, list):
+            return
+        for entry in data:
+            e, err = parse_entry(entry)
+            if not err:
+                schedule.append(e)
+        apply()
+
+    send("database", method="get_data", data="settings", callback=on_settings)
+    send("database", method="get_data", data="schedule", callback=on_schedule)
+
+
+def main():
+    with lock:
+        restore()
+    threading.Thread(target=scheduler_loop, daemon=True).start()
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        with lock:
+            if msg.get("target") == "response":
+                callback = pending.pop(msg.get("id", ""), None)
+                if callback:
+                    callback(msg.get("data"), msg.get("error", ""))
+                continue
+
+            msg_type = msg.get("type", "")
+            if msg_type == "connect":
+                state["connected"] = True
+                log(f"thermostat {msg.get('uuid')} connected from {msg.get('ip')}")
+                apply(force=True)  # the device may have rebooted with stale settings
+            elif msg_type == "incoming":
+                handle_incoming(msg)
+            elif msg_type == "disconnect":
+                state["connected"] = False
+                log(f"thermostat disconnected: {msg.get('reason', 'unknown')}")
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,8 @@
+#!/bin/bash
+# Handler entry point for robot type: thermostat
+#
+# A heat/cool thermostat. The handler owns the setpoint: it applies a
+# persisted weekly schedule, supports temporary or permanent holds, and
+# publishes thermostat.{uuid}.temperature_changed for dashboards and rules.
+
+exec python3 "$(dirname "$0")/handler.py"
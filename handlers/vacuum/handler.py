@@ -0,0 +1,264 @@
+#!/usr/bin/env python3
+"""
+Vacuum handler — start/stop/dock with job tracking.
+
+The vacuum is modelled as a state machine; both operator commands and device
+reports move it along TRANSITIONS, and anything else is rejected (commands)
+or logged and accepted (device reports — the device is the source of truth).
+
+    docked --start--> cleaning --stop--> idle --dock--> returning --arrived--> docked
+                          |                                 ^
+                          +------------dock-----------------+
+    any --fault--> error --start/dock--> cleaning/returning
+
+Robot -> handler:
+
+    {"type": "status", "state": "cleaning", "area_m2": 14.2}
+    {"type": "fault", "code": "brush_stuck"}
+
+Commands (POST /robot/{uuid}/message):
+
+    {"command": "start"}  {"command": "stop"}  {"command": "dock"}
+    {"command": "get_state"}
+    {"command": "jobs", "limit": 20}
+
+A job opens on "start" and closes when the vacuum stops, docks or faults.
+Jobs are stored under the "jobs" data key.
+"""
+import json
+import os
+import sys
+import time
+
+UUID = os.environ.get("ROBOT_UUID", "")
+
+JOB_HISTORY = 200
+
+# state -> {event: next state}
+TRANSITIONS = {
+    "docked": {"start": "cleaning"},
+    "idle": {"start": "cleaning", "dock": "returning"},
+    "cleaning": {"stop": "idle", "dock": "returning", "fault": "error"},
+    "returning": {"arrived": "docked", "stop": "idle", "fault": "error"},
+    "error": {"start": "cleaning", "dock": "returning", "cleared": "idle"},
+}
+COMMAND_EVENTS = ("start", "stop", "dock")
+
+# Server-side lifecycle status (robot.{uuid}.status) for each vacuum state.
+ROBOT_STATUS = {"docked": "online", "idle": "online", "cleaning": "busy", "returning": "busy", "error": "error"}
+
+state = {
+    "connected": False,
+    "state": "docked",
+    "since": int(time.time()),
+    "job": None,          # open job record
+    "last_error": None,
+}
+jobs = []
+
+msg_counter = 0
+pending = {}
+
+
+def send(target, method="", data=None, callback=None):
+    """Send a JSON-RPC message to the roboserver."""
+    global msg_counter
+    msg_counter += 1
+    msg = {"target": target, "id": str(msg_counter)}
+    if method:
+        msg["method"] = method
+    if data is not None:
+        msg["data"] = data
+    if callback:
+        pending[msg["id"]] = callback
+    print(json.dumps(msg), flush=True)
+
+
+def publish_event(suffix, data):
+    send("event_bus", method=f"vacuum.{UUID}.{suffix}", data=data)
+
+
+def log(text):
+    print(f"[vacuum] {text}", file=sys.stderr, flush=True)
+
+
+# --- State machine ------------------------------------------------------------------
+
+def transition(event, **detail):
+    """Apply an event. Returns the new state, or None if not allowed from here."""
+    current = state["state"]
+    target = TRANSITIONS.get(current, {}).get(event)
+    if target is None:
+        return None
+    enter(target, event, **detail)
+    return target
+
+
+def enter(target, event, **detail):
+    previous = state["state"]
+    state["state"] = target
+    state["since"] = int(time.time())
+    publish_event("state_changed", {"uuid": UUID, "from": previous, "to": target, "event": event})
+    send("config", method="set_status", data=ROBOT_STATUS[target])
+
+    if target == "cleaning" and state["job"] is None:
+        open_job()
+    elif target in ("idle", "returning", "docked", "error") and state["job"] is not None:
+        close_job(outcome=event, error=detail.get("error"))
+
+
+# --- Jobs ---------------------------------------------------------------------------
+
+def open_job():
+    now = time.time()
+    state["job"] = {"id": str(int(now * 1000)), "started": int(now), "area_m2": 0.0}
+    publish_event("job_started", dict(state["job"], uuid=UUID))
+
+
+def close_job(outcome, error=None):
+    job = state["job"]
+    state["job"] = None
+    job["ended"] = int(time.time())
+    job["duration_s"] = job["ended"] - job["started"]
+    job["outcome"] = outcome
+    job["error"] = error
+    jobs.append(job)
+    del jobs[:-JOB_HISTORY]
+    send("database", method="store_data", data={"key": "jobs", "value": jobs})
+    publish_event("job_finished", dict(job, uuid=UUID))
+
+
+# --- Device reports -------------------------------------------------------------------
+
+def handle_status(payload):
+    reported = payload.get("state")
+    area = payload.get("area_m2")
+    if state["job"] is not None and isinstance(area, (int, float)) and not isinstance(area, bool):
+        state["job"]["area_m2"] = max(state["job"]["area_m2"], float(area))
+
+    if reported not in TRANSITIONS or reported == state["state"]:
+        return
+    # Map the report to the event that explains it; fall back to forcing the
+    # state so the handler never disagrees with the device.
+    event = {"docked": "arrived", "idle": "stop", "cleaning": "start", "returning": "dock"}.get(reported)
+    if TRANSITIONS[state["state"]].get(event) == reported:
+        enter(reported, event)
+    else:
+        log(f"device reported {reported} from {state['state']}; resyncing")
+        enter(reported, "resync")
+
+
+def handle_fault(payload):
+    code = str(payload.get("code", "unknown"))
+    state["last_error"] = {"code": code, "at": int(time.time())}
+    publish_event("fault", {"uuid": UUID, "code": code, "state": state["state"]})
+    if state["state"] != "error":
+        enter("error", "fault", error=code)
+
+
+# --- Commands -----------------------------------------------------------------------
+
+def reply(payload, data=None, error=""):
+    publish_event("reply", {
+        "uuid": UUID, "request_id": payload.get("request_id", ""),
+        "data": data, "error": error,
+    })
+
+
+def run_command(payload, event):
+    if not state["connected"]:
+        reply(payload, error="vacuum offline")
+        return
+    current = state["state"]
+    allowed = sorted(e for e in TRANSITIONS.get(current, {}) if e in COMMAND_EVENTS)
+    if transition(event) is None:
+        reply(payload, error=f"cannot {event} while {current}; allowed: {allowed}")
+        return
+    send("robot", data={"type": "command", "command": event})
+    reply(payload, {"state": state["state"], "job": state["job"]})
+
+
+def cmd_get_state(payload):
+    current = state["state"]
+    allowed = sorted(e for e in TRANSITIONS.get(current, {}) if e in COMMAND_EVENTS)
+    reply(payload, dict(state, allowed_commands=allowed))
+
+
+def cmd_jobs(payload):
+    limit = payload.get("limit", 20)
+    if not isinstance(limit, int) or limit <= 0:
+        limit = 20
+    reply(payload, list(reversed(jobs[-limit:])))
+
+
+COMMANDS = {
+    "start": lambda p: run_command(p, "start"),
+    "stop": lambda p: run_command(p, "stop"),
+    "dock": lambda p: run_command(p, "dock"),
+    "get_state": cmd_get_state,
+    "jobs": cmd_jobs,
+}
+
+
+def handle_incoming(msg):
+    raw = msg.get("payload", "")
+    try:
+        payload = json.loads(raw) if isinstance(raw, str) else raw
+    except json.JSONDecodeError:
+        payload = None
+    if not isinstance(payload, dict):
+        return
+
+    kind = payload.get("type")
+    if kind == "status":
+        handle_status(payload)
+    elif kind == "fault":
+        handle_fault(payload)
+    else:
+        handler = COMMANDS.get(payload.get("command", ""))
+        if handler:
+            handler(payload)
+        else:
+            reply(payload, error=f"unknown command; available: {sorted(COMMANDS)}")
+
+
+def main():
+    def on_jobs(data, error):
+        if not error and isinstance(data, list):
+            jobs[:0] = data
+            del jobs[:-JOB_HISTORY]
+
+    send("database", method="get_data", data="jobs", callback=on_jobs)
+
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        try:
+            msg = json.loads(line)
+        except json.JSONDecodeError:
+            continue
+
+        if msg.get("target") == "response":
+            callback = pending.pop(msg.get("id", ""), None)
+            if callback:
+                callback(msg.get("data"), msg.get("error", ""))
+            continue
+
+        msg_type = msg.get("type", "")
+        if msg_type == "connect":
+            state["connected"] = True
+            log(f"vacuum {msg.get('uuid')} connected from {msg.get('ip')}")
+            send("robot", data={"type": "get_status"})
+        elif msg_type == "incoming":
+            handle_incoming(msg)
+        elif msg_type == "disconnect":
+            state["connected"] = False
+            log(f"vacuum disconnected: {msg.get('reason', 'unknown')}")
+            if state["job"] is not None:
+                close_job(outcome="disconnected", error="connection lost")
+
+
+if __name__ == "__main__":
+    main()
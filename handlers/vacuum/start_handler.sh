@@ -0,0 +1,9 @@
+#!/bin/bash
+# Handler entry point for robot type: vacuum
+#
+# A robot vacuum driven by start/stop/dock commands. The handler tracks the
+# vacuum through an explicit state machine and records each cleaning job
+# (start, end, area, error) under the "jobs" data key
+# (GET /robot/{uuid}/data/jobs).
+
+exec python3 "$(dirname "$0")/handler.py"
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix marks a bearer token as a user API key rather than a login JWT.
+const APIKeyPrefix = "rmk_"
+
+// GenerateAPIKey creates a new API key "rmk_<id>_<secret>". Only the id and
+// HashAPIKeySecret(secret) are stored; the full key is shown to the user once.
+func GenerateAPIKey() (id, secret, key string, err error) {
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+	id = hex.EncodeToString(idBytes)
+	secret = base64.RawURLEncoding.EncodeToString(secretBytes)
+	return id, secret, APIKeyPrefix + id + "_" + secret, nil
+}
+
+// IsAPIKey reports whether a bearer token looks like an API key.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// ParseAPIKey splits an API key into its id and secret.
+func ParseAPIKey(key string) (id, secret string, ok bool) {
+	if !IsAPIKey(key) {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !ok || len(id) != 16 || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// HashAPIKeySecret returns the stored form of an API key secret. The secret
+// is 256 random bits, so a plain SHA-256 is enough (no bcrypt cost per request).
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKeySecret compares a presented secret with a stored hash in
+// constant time.
+func CheckAPIKeySecret(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKeySecret(secret)), []byte(hash)) == 1
+}
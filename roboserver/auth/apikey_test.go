@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAndParseAPIKey(t *testing.T) {
+	id, secret, key, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if !IsAPIKey(key) || !strings.Contains(key, id) {
+		t.Fatalf("Unexpected key format: %q", key)
+	}
+
+	gotID, gotSecret, ok := ParseAPIKey(key)
+	if !ok || gotID != id || gotSecret != secret {
+		t.Errorf("Expected %s/%s, got %s/%s ok=%v", id, secret, gotID, gotSecret, ok)
+	}
+	if !CheckAPIKeySecret(gotSecret, HashAPIKeySecret(secret)) {
+		t.Error("Expected the secret to match its hash")
+	}
+	if CheckAPIKeySecret("wrong", HashAPIKeySecret(secret)) {
+		t.Error("Expected a wrong secret to be rejected")
+	}
+}
+
+func TestParseAPIKeyRejectsMalformed(t *testing.T) {
+	for _, key := range []string{"", "eyJhbGciOi.jwt.token", "rmk_", "rmk_short_secret", "rmk_0123456789abcdef_"} {
+		if _, _, ok := ParseAPIKey(key); ok {
+			t.Errorf("Expected %q to be rejected", key)
+		}
+	}
+}
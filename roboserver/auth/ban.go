@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"roboserver/database"
+	"roboserver/shared"
+	"time"
+)
+
+// ErrBanned is returned (wrapped) for a robot ID or IP under a kick
+// cooldown.
+var ErrBanned = errors.New("device is banned")
+
+// CheckBan refuses a session for robot uuid from ip while either is
+// banned. A Redis error lets the session through, like a missing ban.
+func CheckBan(ctx context.Context, rds *database.RedisHandler, uuid, ip string) error {
+	if rds == nil {
+		return nil
+	}
+	for _, check := range []struct{ kind, value string }{
+		{database.BanUUID, uuid},
+		{database.BanIP, shared.CanonicalIP(ip)},
+	} {
+		if check.value == "" {
+			continue
+		}
+		ban, err := rds.GetBan(ctx, check.kind, check.value)
+		if err != nil {
+			shared.DebugPrint("Ban check for %s %s skipped: %v", check.kind, check.value, err)
+			continue
+		}
+		if ban != nil {
+			return fmt.Errorf("%w: %s %s until %s", ErrBanned, ban.Kind, ban.Value, time.Unix(ban.Until, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
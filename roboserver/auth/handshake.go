@@ -7,6 +7,7 @@ import (
 	"net"
 	"roboserver/database"
 	"roboserver/shared"
+	"roboserver/shared/ipconflict"
 	"strings"
 	"time"
 )
@@ -18,11 +19,14 @@ type HandshakeResult struct {
 	IP         string
 	SessionJWT string
 	SessionID  string
+	// IPConflict is set when another active robot held IP and the
+	// ip_conflict policy let this session through anyway.
+	IPConflict *ipconflict.Conflict
 }
 
 // PerformHandshake executes the full challenge-response authentication flow:
 //  1. Robot sends UUID
-//  2. Server looks up robot in PostgreSQL, checks blacklist
+//  2. Server looks up robot in PostgreSQL, checks blacklist and kick bans
 //  3. Server generates and sends a random Nonce
 //  4. Robot signs the Nonce with its private key and returns the signature
 //  5. Server verifies signature against stored public key
@@ -37,7 +41,7 @@ func PerformHandshake(ctx context.Context, conn net.Conn, db *database.PostgresH
 func PerformHandshakeWithScanner(ctx context.Context, conn net.Conn, scanner *bufio.Scanner, db *database.PostgresHandler, rds *database.RedisHandler) (*HandshakeResult, error) {
 	if scanner == nil {
 		scanner = bufio.NewScanner(conn)
-		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 64*1024)
+		shared.AppConfig.Limits.LimitScanner(scanner)
 	}
 	ip := connIP(conn)
 
@@ -57,11 +61,15 @@ func PerformHandshakeWithScanner(ctx context.Context, conn net.Conn, scanner *bu
 	robot, err := db.GetRobotByUUID(ctx, uuid)
 	if err != nil {
 		conn.Write([]byte("ERROR UNKNOWN_ROBOT\n"))
-		return nil, fmt.Errorf("robot not found: %s", uuid)
+		return nil, shared.NewRobotError("handshake", uuid, ip, shared.ErrRobotNotFound)
 	}
 	if robot.IsBlacklisted {
 		conn.Write([]byte("ERROR BLACKLISTED\n"))
-		return nil, fmt.Errorf("robot is blacklisted: %s", uuid)
+		return nil, shared.NewRobotError("handshake", uuid, ip, shared.ErrRobotBlacklisted)
+	}
+	if err := CheckBan(ctx, rds, uuid, ip); err != nil {
+		conn.Write([]byte("ERROR BANNED\n"))
+		return nil, shared.NewRobotError("handshake", uuid, ip, err)
 	}
 
 	// Step 3: Generate and send Nonce
@@ -86,10 +94,15 @@ func PerformHandshakeWithScanner(ctx context.Context, conn net.Conn, scanner *bu
 	// Step 5: Verify signature
 	if err := VerifyRobotSignature(robot.PublicKey, nonce, signature); err != nil {
 		conn.Write([]byte("ERROR INVALID_SIGNATURE\n"))
-		return nil, fmt.Errorf("signature verification failed for %s: %w", uuid, err)
+		return nil, shared.NewRobotError("handshake", uuid, ip, fmt.Errorf("%w: %v", shared.ErrInvalidSignature, err))
 	}
 
-	// Step 6: Issue JWT and register in Redis
+	// Step 6: Apply the IP conflict policy, issue JWT and register in Redis
+	conflict, err := CheckIPConflict(ctx, db, rds, uuid, ip, robot.PublicKey)
+	if err != nil {
+		conn.Write([]byte("ERROR IP_CONFLICT\n"))
+		return nil, shared.NewRobotError("handshake", uuid, ip, err)
+	}
 	sessionID := GenerateSessionID()
 	jwt, err := IssueSessionJWT(uuid, robot.DeviceType, ip, sessionID)
 	if err != nil {
@@ -119,24 +132,19 @@ func PerformHandshakeWithScanner(ctx context.Context, conn net.Conn, scanner *bu
 		IP:         ip,
 		SessionJWT: jwt,
 		SessionID:  sessionID,
+		IPConflict: conflict,
 	}, nil
 }
 
-// connIP extracts the client IP from a net.Conn, gracefully handling
-// non-TCP addresses (e.g. TLS-wrapped connections in tests).
+// connIP extracts the client IP from a net.Conn in shared.CanonicalIP
+// form, gracefully handling non-TCP addresses (e.g. TLS-wrapped connections
+// in tests).
 func connIP(conn net.Conn) string {
 	addr := conn.RemoteAddr()
 	if addr == nil {
 		return ""
 	}
-	if tcp, ok := addr.(*net.TCPAddr); ok {
-		return tcp.IP.String()
-	}
-	host, _, err := net.SplitHostPort(addr.String())
-	if err != nil {
-		return addr.String()
-	}
-	return host
+	return shared.CanonicalIP(addr.String())
 }
 
 // VerifyRobotSignature tries PEM first, then raw hex Ed25519.
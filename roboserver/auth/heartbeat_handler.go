@@ -32,14 +32,17 @@ type HeartbeatResult struct {
 // ProcessHeartbeat verifies a signed heartbeat and updates Redis state.
 // The heartbeat format is: UUID + signed JSON payload.
 // The signature is verified against the robot's public key from PostgreSQL.
+// ip may carry a port; a robot heartbeating from several interfaces has
+// each address remembered.
 func ProcessHeartbeat(ctx context.Context, uuid, payloadJSON, signature, ip string, pg *database.PostgresHandler, rds *database.RedisHandler) (*HeartbeatResult, error) {
+	ip = shared.CanonicalIP(ip)
 	// Look up the robot's public key
 	robot, err := pg.GetRobotByUUID(ctx, uuid)
 	if err != nil {
-		return nil, fmt.Errorf("unknown robot: %s", uuid)
+		return nil, shared.NewRobotError("heartbeat", uuid, ip, shared.ErrRobotNotFound)
 	}
 	if robot.IsBlacklisted {
-		return nil, fmt.Errorf("robot is blacklisted: %s", uuid)
+		return nil, shared.NewRobotError("heartbeat", uuid, ip, shared.ErrRobotBlacklisted)
 	}
 
 	// Verify the signature over the payload.
@@ -47,19 +50,19 @@ func ProcessHeartbeat(ctx context.Context, uuid, payloadJSON, signature, ip stri
 	// hex-encode the raw JSON bytes so they round-trip correctly.
 	payloadHex := hex.EncodeToString([]byte(payloadJSON))
 	if err := VerifyRobotSignature(robot.PublicKey, payloadHex, signature); err != nil {
-		return nil, fmt.Errorf("invalid heartbeat signature for %s: %w", uuid, err)
+		return nil, shared.NewRobotError("heartbeat", uuid, ip, fmt.Errorf("%w: %v", shared.ErrInvalidSignature, err))
 	}
 
 	// Parse the payload (raw JSON text)
 	var payload HeartbeatPayload
 	if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
-		return nil, fmt.Errorf("failed to parse heartbeat payload: %w", err)
+		return nil, shared.NewRobotError("heartbeat", uuid, ip, fmt.Errorf("failed to parse heartbeat payload: %w", err))
 	}
 
 	// Check sequence number (must be greater than last seen)
 	existing, _ := rds.GetHeartbeat(ctx, uuid)
 	if existing != nil && payload.Seq <= existing.LastSeq {
-		return nil, fmt.Errorf("stale heartbeat sequence for %s: got %d, last was %d", uuid, payload.Seq, existing.LastSeq)
+		return nil, shared.NewRobotError("heartbeat", uuid, ip, fmt.Errorf("%w: got %d, last was %d", shared.ErrStaleHeartbeat, payload.Seq, existing.LastSeq))
 	}
 
 	// Determine TTL, capped to prevent misbehaving robots from pinning Redis state.
@@ -79,13 +82,17 @@ func ProcessHeartbeat(ctx context.Context, uuid, payloadJSON, signature, ip stri
 		LastSeq:  payload.Seq,
 		LastSeen: time.Now().Unix(),
 	}
+	if existing != nil {
+		state.IPs = existing.Addresses()
+	}
+	state.IPs = shared.AddIP(state.IPs, ip, database.MaxRobotIPs)
 	if err := rds.SetHeartbeat(ctx, state, ttl); err != nil {
 		return nil, fmt.Errorf("failed to store heartbeat: %w", err)
 	}
 
 	// Also refresh the active robot session if one exists
 	if active, _ := rds.GetActiveRobot(ctx, uuid); active != nil {
-		active.IP = ip
+		active.AddIP(ip)
 		if err := rds.SetActiveRobot(ctx, active, ttl); err != nil {
 			shared.DebugPrint("Failed to refresh active session for %s: %v", uuid, err)
 		}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/ipconflict"
+	"slices"
+	"time"
+)
+
+// CheckIPConflict applies ip_conflict.policy before robot uuid starts a
+// session from ip. It returns nil when no other active robot holds the
+// address, as its latest or any other of its addresses. Otherwise it returns the Conflict to publish on
+// events.IPConflict, together with an *ipconflict.Error if the new session
+// must be refused. An evicted robot's session is removed from Redis here;
+// its handler is stopped by handler_engine.WatchIPConflicts. publicKey is
+// the new robot's key ("" if it has none), compared with the old robot's
+// under require-token-match. pg may be nil.
+func CheckIPConflict(ctx context.Context, pg *database.PostgresHandler, rds *database.RedisHandler, uuid, ip, publicKey string) (*ipconflict.Conflict, error) {
+	host := shared.CanonicalIP(ip)
+	if rds == nil || host == "" {
+		return nil, nil
+	}
+	robots, err := rds.GetAllActiveRobots(ctx)
+	if err != nil {
+		shared.DebugPrint("IP conflict check for %s skipped: %v", uuid, err)
+		return nil, nil
+	}
+	var existing *database.ActiveRobot
+	for _, r := range robots {
+		if r.UUID != uuid && slices.Contains(r.Addresses(), host) {
+			existing = r
+			break
+		}
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	policy, _ := ipconflict.ParsePolicy(shared.AppConfig.IPConflict.Policy)
+	keysMatch := false
+	if policy == ipconflict.RequireTokenMatch && publicKey != "" {
+		keysMatch = robotPublicKey(ctx, pg, rds, existing.UUID) == publicKey
+	}
+	c := &ipconflict.Conflict{
+		IP:       host,
+		UUID:     uuid,
+		Existing: existing.UUID,
+		Policy:   policy,
+		Action:   ipconflict.Resolve(policy, keysMatch),
+		Time:     time.Now().Unix(),
+	}
+	shared.DebugPrint("IP conflict: %s claims %s held by %s (%s)", uuid, shared.RedactIP(host), existing.UUID, c.Action)
+
+	switch c.Action {
+	case ipconflict.Rejected:
+		return c, &ipconflict.Error{Conflict: *c}
+	case ipconflict.Evicted:
+		if err := rds.RemoveActiveRobot(ctx, existing.UUID); err != nil {
+			shared.DebugPrint("Failed to evict %s: %v", existing.UUID, err)
+		}
+	}
+	return c, nil
+}
+
+// robotPublicKey returns a robot's public key from PostgreSQL, or from Redis
+// for a robot registered but not yet persisted.
+func robotPublicKey(ctx context.Context, pg *database.PostgresHandler, rds *database.RedisHandler, uuid string) string {
+	if pg != nil {
+		if robot, err := pg.GetRobotByUUID(ctx, uuid); err == nil && robot != nil {
+			return robot.PublicKey
+		}
+	}
+	key, _ := rds.GetRobotPublicKey(ctx, uuid)
+	return key
+}
@@ -103,6 +103,20 @@ func ValidateSessionJWT(tokenStr string) (*JWTClaims, error) {
 	return &claims, nil
 }
 
+// ValidateRobotJWT validates a session JWT presented as robot uuid from ip.
+// Failures are *shared.RobotError values for op; a valid token issued to
+// another robot matches shared.ErrRobotMismatch.
+func ValidateRobotJWT(op, tokenStr, uuid, ip string) (*JWTClaims, error) {
+	claims, err := ValidateSessionJWT(tokenStr)
+	if err != nil {
+		return nil, shared.NewRobotError(op, uuid, ip, err)
+	}
+	if claims.Sub != uuid {
+		return nil, shared.NewRobotError(op, uuid, ip, fmt.Errorf("%w: token issued to %s", shared.ErrRobotMismatch, claims.Sub))
+	}
+	return claims, nil
+}
+
 func signHS256(input, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write([]byte(input))
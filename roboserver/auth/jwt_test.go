@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"roboserver/shared"
 	"testing"
 	"time"
@@ -92,6 +93,34 @@ func TestJWTMalformed(t *testing.T) {
 	}
 }
 
+func TestValidateRobotJWTMismatch(t *testing.T) {
+	token, err := IssueSessionJWT("robot-a", "proximity_sensor", "10.0.0.7", "sess_a")
+	if err != nil {
+		t.Fatalf("Failed to issue JWT: %v", err)
+	}
+
+	if _, err := ValidateRobotJWT("udp message", token, "robot-a", "10.0.0.7"); err != nil {
+		t.Fatalf("Token for its own robot rejected: %v", err)
+	}
+
+	_, err = ValidateRobotJWT("udp message", token, "robot-b", "10.0.0.8")
+	if !errors.Is(err, shared.ErrRobotMismatch) {
+		t.Fatalf("Expected ErrRobotMismatch, got %v", err)
+	}
+	var re *shared.RobotError
+	if !errors.As(err, &re) {
+		t.Fatalf("Expected *shared.RobotError, got %T", err)
+	}
+	if re.DeviceID != "robot-b" || re.IP != "10.0.0.8" || re.Op != "udp message" {
+		t.Errorf("Unexpected error context: %+v", re)
+	}
+
+	_, err = ValidateRobotJWT("udp message", "garbage", "robot-a", "10.0.0.7")
+	if !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("Expected ErrTokenInvalid, got %v", err)
+	}
+}
+
 func TestGenerateSessionID(t *testing.T) {
 	id1 := GenerateSessionID()
 	id2 := GenerateSessionID()
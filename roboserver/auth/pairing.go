@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// pairingAlphabet leaves out characters that are easy to misread on a label
+// or screen (0/O, 1/I/L).
+const pairingAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// PairingCodeLength is the number of characters in a pairing code
+// (about 50 bits of entropy).
+const PairingCodeLength = 10
+
+// GeneratePairingCode returns a new one-time pairing code for
+// pre-approved robot registration.
+func GeneratePairingCode() (string, error) {
+	b := make([]byte, PairingCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = pairingAlphabet[int(b[i])%len(pairingAlphabet)]
+	}
+	return string(b), nil
+}
+
+// NormalizePairingCode uppercases a code typed by a person and drops the
+// separators they may have copied along ("abcde-fghjk" → "ABCDEFGHJK").
+func NormalizePairingCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
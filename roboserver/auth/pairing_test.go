@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePairingCode(t *testing.T) {
+	code, err := GeneratePairingCode()
+	if err != nil {
+		t.Fatalf("GeneratePairingCode failed: %v", err)
+	}
+	if len(code) != PairingCodeLength {
+		t.Errorf("Expected %d characters, got %q", PairingCodeLength, code)
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(pairingAlphabet, r) {
+			t.Errorf("Unexpected character %q in %q", r, code)
+		}
+	}
+	if NormalizePairingCode(code) != code {
+		t.Errorf("Expected a generated code to already be normalized: %q", code)
+	}
+}
+
+func TestNormalizePairingCode(t *testing.T) {
+	if got := NormalizePairingCode(" abcde-fghjk "); got != "ABCDEFGHJK" {
+		t.Errorf("Expected ABCDEFGHJK, got %q", got)
+	}
+}
@@ -37,7 +37,7 @@ func IssueUserJWT(username string) (string, error) {
 	claims := UserJWTClaims{
 		Sub:     username,
 		Iat:     now,
-		Exp:     now + int64(shared.AppConfig.Auth.JWTExpiry),
+		Exp:     now + int64(shared.AppConfig.Auth.MaxSessionAge().Seconds()),
 		TokenID: hex.EncodeToString(tokenID),
 	}
 
@@ -0,0 +1,196 @@
+// Package automation runs user Lua scripts for rules too involved for
+// trigger/condition JSON. Each *.lua file in automation.dir is one script:
+//
+//	robomesh.on("robot.*.telemetry", function(event_type, data)
+//	  if data.metrics.temp_c > 80 then
+//	    robomesh.send_message(data.uuid, "cooldown")
+//	  end
+//	end)
+//
+// Scripts run sandboxed: only the base (without file or module loading),
+// string, table and math libraries, plus the robomesh API (see lua.go).
+// Every call into a script, including loading it, gets automation.timeout
+// of CPU before it is aborted, and the Lua call depth and value stack are
+// capped. Each script handles its events one at a time on its own
+// goroutine; events that arrive while its queue is full are dropped.
+//
+// Scripts only see events published on this node, so in a cluster each
+// event triggers them once, on the node that published it.
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/event_bus"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDisabled is returned by Reload when automation is off.
+var ErrDisabled = errors.New("automation is disabled")
+
+// Status describes a loaded script.
+type Status struct {
+	Name        string   `json:"name"`
+	Events      []string `json:"events"` // patterns passed to robomesh.on
+	Runs        int64    `json:"runs"`   // callbacks completed
+	Errors      int64    `json:"errors"` // callbacks that raised or timed out
+	Dropped     int64    `json:"dropped"`
+	LastError   string   `json:"last_error,omitempty"`
+	LastErrorAt int64    `json:"last_error_at,omitempty"` // Unix seconds
+	LoadError   string   `json:"load_error,omitempty"`    // set when the script failed to load
+}
+
+type event struct {
+	eventType string
+	data      any
+}
+
+// Runner holds the loaded scripts.
+type Runner struct {
+	reload  sync.Mutex // one Reload at a time
+	mu      sync.RWMutex
+	ctx     context.Context
+	bus     comms.Bus
+	db      database.DBManager
+	scripts []*script
+	failed  []Status // scripts that did not load
+	started atomic.Bool
+}
+
+// Default is the process-wide runner started by Start.
+var Default = &Runner{}
+
+// Start loads the scripts in automation.dir and feeds them events from eb
+// until ctx is cancelled. Scripts act through bus and db. It returns
+// immediately when automation is disabled.
+func Start(ctx context.Context, eb event_bus.EventBus, bus comms.Bus, db database.DBManager) error {
+	if !shared.AppConfig.Automation.Enabled {
+		return nil
+	}
+	r := Default
+	r.mu.Lock()
+	r.ctx, r.bus, r.db = ctx, bus, db
+	r.mu.Unlock()
+	r.started.Store(true)
+	if _, err := r.Reload(); err != nil {
+		return err
+	}
+
+	cancel := eb.Tap(r.handle)
+	<-ctx.Done()
+	cancel()
+	r.mu.Lock()
+	r.stopScripts()
+	r.mu.Unlock()
+	return nil
+}
+
+// Reload replaces the loaded scripts with the contents of automation.dir.
+// A script that fails to load is reported in Status and skipped; the
+// others run. It returns the number of scripts loaded.
+//
+// The new scripts load without r.mu held: a top-level robomesh.publish
+// reaches handle on the same goroutine, and publishers must not wait for a
+// reload. The old scripts keep running until the new set is swapped in.
+func (r *Runner) Reload() (int, error) {
+	if !r.started.Load() {
+		return 0, ErrDisabled
+	}
+	dir := shared.AppConfig.Automation.Dir
+	paths, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return 0, fmt.Errorf("automation: %w", err)
+	}
+	sort.Strings(paths)
+
+	r.reload.Lock()
+	defer r.reload.Unlock()
+	r.mu.RLock()
+	ctx := r.ctx
+	r.mu.RUnlock()
+
+	var scripts []*script
+	var failed []Status
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".lua")
+		src, err := os.ReadFile(path)
+		if err == nil {
+			var s *script
+			if s, err = loadScript(ctx, name, string(src), r); err == nil {
+				scripts = append(scripts, s)
+				continue
+			}
+		}
+		shared.DebugPrint("Automation script %s failed to load: %v", name, err)
+		failed = append(failed, Status{Name: name, LoadError: err.Error()})
+	}
+
+	r.mu.Lock()
+	r.stopScripts()
+	r.scripts, r.failed = scripts, failed
+	r.mu.Unlock()
+	shared.DebugPrint("Loaded %d automation script(s) from %s", len(scripts), dir)
+	return len(scripts), nil
+}
+
+// Status lists the scripts, loaded or not, by name.
+func (r *Runner) Status() []Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Status, 0, len(r.scripts)+len(r.failed))
+	for _, s := range r.scripts {
+		out = append(out, s.status())
+	}
+	out = append(out, r.failed...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// handle queues a published event for every script listening to it. It
+// runs on the publisher's goroutine, so it never waits.
+func (r *Runner) handle(ev event_bus.Event) {
+	if event_bus.IsRemote(ev) {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.scripts {
+		s.offer(event{eventType: ev.GetType(), data: ev.GetData()})
+	}
+}
+
+// stopScripts ends every script's goroutine. Caller holds r.mu.
+func (r *Runner) stopScripts() {
+	for _, s := range r.scripts {
+		s.stop()
+	}
+	r.scripts = nil
+}
+
+// redis returns the Redis handler, or nil without a database.
+func (r *Runner) redis() *database.RedisHandler {
+	if r.db == nil {
+		return nil
+	}
+	return r.db.Redis()
+}
+
+// recordError notes a failed callback.
+func (s *script) recordError(err error) {
+	s.errors.Add(1)
+	s.mu.Lock()
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now().Unix()
+	s.mu.Unlock()
+	shared.DebugPrint("Automation script %s: %v", s.name, err)
+}
@@ -0,0 +1,170 @@
+package automation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"roboserver/comms"
+	"roboserver/shared"
+	"roboserver/shared/event_bus"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRunner(t *testing.T) (*Runner, *comms.LocalBus) {
+	t.Helper()
+	shared.AppConfig.Automation = shared.AutomationConfig{Timeout: "50ms", CallStack: 200, MaxStack: 65536, Queue: 16}
+	bus := comms.NewLocalBus(event_bus.NewEventBus(), nil)
+	return &Runner{ctx: context.Background(), bus: bus}, bus
+}
+
+func TestScriptHandlesEvents(t *testing.T) {
+	r, bus := testRunner(t)
+	var got atomic.Value
+	bus.SubscribeEvent("automation.hot", func(_ string, data any) { got.Store(data) })
+
+	s, err := loadScript(r.ctx, "hot", `
+		robomesh.on("robot.*", function(event_type, data)
+			if data.metrics.temp_c > 80 then
+				robomesh.publish("automation.hot", {uuid = data.uuid, event = event_type})
+			end
+		end)`, r)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	defer s.stop()
+
+	s.offer(event{"robot.r1.telemetry", map[string]any{"uuid": "r1", "metrics": map[string]float64{"temp_c": 20}}})
+	s.offer(event{"robot.r2.telemetry", map[string]any{"uuid": "r2", "metrics": map[string]float64{"temp_c": 90}}})
+	s.offer(event{"job.updated", map[string]any{}})
+
+	deadline := time.Now().Add(time.Second)
+	for got.Load() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	data, _ := got.Load().(map[string]any)
+	if data["uuid"] != "r2" || data["event"] != "robot.r2.telemetry" {
+		t.Fatalf("Expected automation.hot for r2, got %v", got.Load())
+	}
+	if st := s.status(); st.Runs != 2 || st.Errors != 0 || len(st.Events) != 1 {
+		t.Errorf("Unexpected status %+v", st)
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	r, _ := testRunner(t)
+	_, err := loadScript(r.ctx, "spin", `while true do end`, r)
+	if err == nil || !strings.Contains(err.Error(), "exceeded") {
+		t.Errorf("Expected the script to be stopped after its timeout, got %v", err)
+	}
+}
+
+func TestScriptSandbox(t *testing.T) {
+	r, _ := testRunner(t)
+	s, err := loadScript(r.ctx, "sandbox", `
+		assert(dofile == nil and loadfile == nil and loadstring == nil and require == nil)
+		assert(io == nil and os == nil and debug == nil)
+		assert(string.upper("ok") == "OK" and math.max(1, 2) == 2)
+		assert(string.rep("ab", 3) == "ababab" and string.rep("x", 0) == "")
+		local s = ("x"):rep(2)
+		assert(s == "xx")`, r)
+	if err != nil {
+		t.Fatalf("Expected only safe libraries, got %v", err)
+	}
+	s.stop()
+
+	for name, src := range map[string]string{
+		"publish outside namespace": `robomesh.publish("handler.r1.incoming", {})`,
+		"deep recursion":            `local function f() return 1 + f() end f()`,
+		"publish cyclic table":      `local t = {} t.self = t robomesh.publish("automation.x", t)`,
+		"huge string.rep":           `local s = string.rep("x", 1e10)`,
+		"publish deep table":        `local t = {} for i = 1, 40 do t = {t} end robomesh.publish("automation.x", t)`,
+	} {
+		if _, err := loadScript(r.ctx, "bad", src, r); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScriptCannotSubscribeLate(t *testing.T) {
+	r, _ := testRunner(t)
+	s, err := loadScript(r.ctx, "late", `
+		robomesh.on("job.updated", function()
+			robomesh.on("robot.*", function() end)
+		end)`, r)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	defer s.stop()
+	s.dispatch(r.ctx, event{"job.updated", map[string]any{}})
+	if st := s.status(); st.Errors != 1 || len(st.Events) != 1 {
+		t.Errorf("Expected robomesh.on to fail after load, got %+v", st)
+	}
+}
+
+func TestReloadWithTopLevelPublish(t *testing.T) {
+	testRunner(t)
+	eb := event_bus.NewEventBus()
+	r := &Runner{ctx: context.Background(), bus: comms.NewLocalBus(eb, nil)}
+	r.started.Store(true)
+	cancel := eb.Tap(r.handle)
+	defer cancel()
+	dir := t.TempDir()
+	shared.AppConfig.Automation.Dir = dir
+	if err := os.WriteFile(filepath.Join(dir, "boot.lua"), []byte(`robomesh.publish("automation.boot", {})`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second reload publishes while the first set of scripts is live.
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Reload()
+		if err == nil {
+			_, err = r.Reload()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Reload deadlocked on a top-level publish")
+	}
+	if st := r.Status(); len(st) != 1 || st[0].LoadError != "" {
+		t.Errorf("Expected boot loaded, got %+v", st)
+	}
+	r.mu.Lock()
+	r.stopScripts()
+	r.mu.Unlock()
+}
+
+func TestFromLuaSharedTable(t *testing.T) {
+	r, _ := testRunner(t)
+	// A table reached twice without a cycle is fine.
+	s, err := loadScript(r.ctx, "shared", `local p = {x = 1} robomesh.publish("automation.x", {a = p, b = p})`, r)
+	if err != nil {
+		t.Fatalf("Expected a shared table to publish, got %v", err)
+	}
+	s.stop()
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	r, _ := testRunner(t)
+	s, err := loadScript(r.ctx, "spin", `robomesh.on("job.updated", function() while true do end end)`, r)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	defer s.stop()
+	start := time.Now()
+	s.dispatch(r.ctx, event{"job.updated", map[string]any{}})
+	if st := s.status(); st.Errors != 1 || !strings.Contains(st.LastError, "exceeded") {
+		t.Errorf("Expected the callback stopped after its timeout, got %+v", st)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Callback ran for %v", time.Since(start))
+	}
+}
@@ -0,0 +1,435 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/robot_status"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Sandbox limits that apply whatever the configuration says. maxStringLen
+// caps string.rep, the one library call that builds an arbitrarily large
+// string in a single step; defaultMaxStack applies when
+// automation.max_stack is unset, so the value stack never grows unbounded.
+const (
+	maxStringLen    = 1 << 20
+	defaultMaxStack = 65536
+)
+
+// publishPrefix is the namespace scripts may publish events in, so a script
+// can't impersonate robots or handlers.
+const publishPrefix = "automation."
+
+func init() {
+	events.Describe(events.Info{
+		Type:        publishPrefix + "*",
+		Description: "Published by automation scripts with robomesh.publish; the payload is the script's.",
+		Example:     map[string]any{"rule": "overheat", "uuid": "rover-7"},
+	})
+}
+
+// script is one loaded Lua file with its own interpreter. An LState is not
+// safe for concurrent use, so every call into it happens on run's
+// goroutine (or during loadScript, before run starts).
+type script struct {
+	name     string
+	L        *lua.LState
+	runner   *Runner
+	handlers []listener // fixed once loaded
+	loaded   bool
+	match    func(eventType string) bool
+	queue    chan event
+	done     chan struct{}
+	stopOnce sync.Once
+
+	runs, errors, dropped atomic.Int64
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt int64
+}
+
+type listener struct {
+	pattern string
+	match   func(eventType string) bool
+	fn      *lua.LFunction
+}
+
+// loadScript runs src's top level, which registers its listeners, and
+// starts handling events for them.
+func loadScript(ctx context.Context, name, src string, r *Runner) (*script, error) {
+	cfg := &shared.AppConfig.Automation
+	s := &script{
+		name:   name,
+		runner: r,
+		queue:  make(chan event, max(cfg.Queue, 1)),
+		done:   make(chan struct{}),
+	}
+	s.L = newSandbox(cfg.CallStack, cfg.MaxStack)
+	s.L.SetGlobal("robomesh", s.api())
+
+	fn, err := s.L.LoadString(src)
+	if err == nil {
+		err = s.call(ctx, fn)
+	}
+	if err != nil {
+		s.L.Close()
+		return nil, err
+	}
+	patterns := make([]string, len(s.handlers))
+	for i, h := range s.handlers {
+		patterns[i] = h.pattern
+	}
+	s.match = events.Matcher(patterns)
+	s.loaded = true
+
+	go s.run(ctx)
+	return s, nil
+}
+
+// newSandbox returns an interpreter with only the safe standard libraries
+// and a bounded call depth and value stack. The CPU budget is set per call.
+func newSandbox(callStack, maxStack int) *lua.LState {
+	if maxStack <= 0 {
+		maxStack = defaultMaxStack
+	}
+	regSize := min(lua.RegistrySize, maxStack)
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   max(callStack, 16),
+		RegistrySize:    regSize,
+		RegistryMaxSize: maxStack,
+	})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// No file access, code loading or interpreter internals.
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module",
+		"collectgarbage", "getfenv", "setfenv", "newproxy", "_printregs", "print"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	if str, ok := L.GetGlobal(lua.StringLibName).(*lua.LTable); ok {
+		str.RawSetString("rep", L.NewFunction(luaStringRep))
+	}
+	return L
+}
+
+// luaStringRep is string.rep with its result capped at maxStringLen bytes.
+func luaStringRep(L *lua.LState) int {
+	str := L.CheckString(1)
+	n := L.CheckInt(2)
+	if n <= 0 || str == "" {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if n > maxStringLen/len(str) {
+		L.RaiseError("string.rep result longer than %d bytes", maxStringLen)
+		return 0
+	}
+	L.Push(lua.LString(strings.Repeat(str, n)))
+	return 1
+}
+
+// call runs fn with args within automation.timeout.
+func (s *script) call(ctx context.Context, fn *lua.LFunction, args ...lua.LValue) error {
+	callCtx, cancel := context.WithTimeout(ctx, shared.AppConfig.Automation.CallTimeout())
+	defer cancel()
+	s.L.SetContext(callCtx)
+	defer s.L.RemoveContext()
+	err := s.L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, args...)
+	if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("exceeded %v: %w", shared.AppConfig.Automation.CallTimeout(), err)
+	}
+	return err
+}
+
+// offer queues an event if the script listens to it.
+func (s *script) offer(ev event) {
+	if !s.match(ev.eventType) {
+		return
+	}
+	select {
+	case <-s.done:
+	case s.queue <- ev:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *script) run(ctx context.Context) {
+	defer s.L.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case ev := <-s.queue:
+			s.dispatch(ctx, ev)
+		}
+	}
+}
+
+// dispatch calls every listener of the event's type.
+func (s *script) dispatch(ctx context.Context, ev event) {
+	var data lua.LValue = lua.LNil
+	for _, h := range s.handlers {
+		if !h.match(ev.eventType) {
+			continue
+		}
+		if data == lua.LNil {
+			data = toLua(s.L, ev.data)
+		}
+		if err := s.call(ctx, h.fn, lua.LString(ev.eventType), data); err != nil {
+			s.recordError(err)
+			continue
+		}
+		s.runs.Add(1)
+	}
+}
+
+func (s *script) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+func (s *script) status() Status {
+	st := Status{
+		Name:    s.name,
+		Events:  make([]string, len(s.handlers)),
+		Runs:    s.runs.Load(),
+		Errors:  s.errors.Load(),
+		Dropped: s.dropped.Load(),
+	}
+	for i, h := range s.handlers {
+		st.Events[i] = h.pattern
+	}
+	s.mu.Lock()
+	st.LastError, st.LastErrorAt = s.lastError, s.lastErrorAt
+	s.mu.Unlock()
+	return st
+}
+
+// api builds the robomesh table scripts call into.
+func (s *script) api() *lua.LTable {
+	return s.L.SetFuncs(s.L.NewTable(), map[string]lua.LGFunction{
+		"on":           s.luaOn,
+		"get_robot":    s.luaGetRobot,
+		"send_message": s.luaSendMessage,
+		"publish":      s.luaPublish,
+		"log":          s.luaLog,
+	})
+}
+
+// robomesh.on(pattern, fn): call fn(event_type, data) for events matching
+// pattern (an exact type or "prefix.*"). Only allowed while the script
+// loads.
+func (s *script) luaOn(L *lua.LState) int {
+	pattern := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	if s.loaded {
+		L.RaiseError("robomesh.on must be called while the script loads")
+		return 0
+	}
+	s.handlers = append(s.handlers, listener{
+		pattern: pattern,
+		match:   events.Matcher([]string{pattern}),
+		fn:      fn,
+	})
+	return 0
+}
+
+// robomesh.get_robot(uuid): the robot's session and status, or nil if it
+// is not connected.
+func (s *script) luaGetRobot(L *lua.LState) int {
+	uuid := L.CheckString(1)
+	robot := map[string]any{
+		"uuid":   uuid,
+		"status": robot_status.Tracker.Get(uuid).String(),
+	}
+	found := false
+	if rds := s.runner.redis(); rds != nil {
+		if active, err := rds.GetActiveRobot(L.Context(), uuid); err == nil && active != nil {
+			found = true
+			robot["ip"] = active.IP
+			robot["device_type"] = active.DeviceType
+			robot["connected_at"] = active.ConnectedAt
+			robot["node"] = active.Node
+		}
+	}
+	if hp, ok := handler_engine.HandlerManager.Get(uuid); ok {
+		found = true
+		snap := hp.Snapshot()
+		robot["device_type"] = snap.DeviceType
+		robot["connected"] = snap.Connected
+	}
+	if !found {
+		L.Push(lua.LNil)
+		return 1
+	}
+	L.Push(toLua(L, robot))
+	return 1
+}
+
+// robomesh.send_message(uuid, message): deliver message to the robot's
+// handler like an automated API message. Returns true, or nil and an error
+// string.
+func (s *script) luaSendMessage(L *lua.LState) int {
+	uuid := L.CheckString(1)
+	message := L.CheckString(2)
+	if err := s.sendMessage(L.Context(), uuid, message); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
+
+func (s *script) sendMessage(ctx context.Context, uuid, message string) error {
+	return handler_engine.SendAutomated(ctx, s.runner.bus, s.runner.redis(), uuid, message, "automation:"+s.name)
+}
+
+// robomesh.publish(event_type, data): publish an event. event_type must
+// start with "automation.".
+func (s *script) luaPublish(L *lua.LState) int {
+	eventType := L.CheckString(1)
+	if !strings.HasPrefix(eventType, publishPrefix) || len(eventType) == len(publishPrefix) {
+		L.ArgError(1, "event type must start with \""+publishPrefix+"\"")
+		return 0
+	}
+	data, err := fromLua(L.Get(2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	if bus := s.runner.bus; bus != nil {
+		bus.PublishEvent(eventType, data)
+	}
+	return 0
+}
+
+// robomesh.log(...): write the arguments to the debug log.
+func (s *script) luaLog(L *lua.LState) int {
+	parts := make([]string, L.GetTop())
+	for i := range parts {
+		parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+	}
+	shared.DebugPrint("Automation %s: %s", s.name, strings.Join(parts, " "))
+	return 0
+}
+
+// toLua converts an event payload to Lua values through its JSON form, so
+// structs appear as tables keyed by their JSON field names.
+func toLua(L *lua.LState, v any) lua.LValue {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return lua.LNil
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return lua.LNil
+	}
+	return fromGeneric(L, generic)
+}
+
+func fromGeneric(L *lua.LState, v any) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []any:
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(fromGeneric(L, item))
+		}
+		return t
+	case map[string]any:
+		t := L.CreateTable(0, len(v))
+		for k, item := range v {
+			t.RawSetString(k, fromGeneric(L, item))
+		}
+		return t
+	}
+	return lua.LNil
+}
+
+// maxTableDepth bounds how deeply tables passed to publish may nest.
+const maxTableDepth = 32
+
+// fromLua converts a Lua value to its Go form: tables with a sequence part
+// become slices, other tables maps with string keys. A table that contains
+// itself, or nests deeper than maxTableDepth, is an error.
+func fromLua(v lua.LValue) (any, error) {
+	return fromLuaTable(v, make(map[*lua.LTable]bool), 0)
+}
+
+// fromLuaTable converts v; path holds the tables v is nested in.
+func fromLuaTable(v lua.LValue, path map[*lua.LTable]bool, depth int) (any, error) {
+	switch v := v.(type) {
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if path[v] {
+			return nil, errors.New("table contains itself")
+		}
+		if depth >= maxTableDepth {
+			return nil, fmt.Errorf("tables nested more than %d deep", maxTableDepth)
+		}
+		path[v] = true
+		defer delete(path, v)
+		if n := v.MaxN(); n > 0 {
+			out := make([]any, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := fromLuaTable(v.RawGetInt(i), path, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, item)
+			}
+			return out, nil
+		}
+		out := make(map[string]any)
+		var err error
+		v.ForEach(func(k, item lua.LValue) {
+			if err == nil {
+				out[k.String()], err = fromLuaTable(item, path, depth+1)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"roboserver/shared"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An archive file is a header followed by the AES-256-GCM sealed,
+// gzip-compressed JSON Archive:
+//
+//	"RMBK" | format (1 byte) | scrypt salt (16) | GCM nonce (12) | ciphertext
+//
+// The key is derived from the passphrase with scrypt, and the header is
+// authenticated along with the contents.
+const (
+	magic      = "RMBK"
+	formatV1   = 1
+	saltSize   = 16
+	headerSize = len(magic) + 1 + saltSize
+)
+
+// scrypt cost parameters (the 2017 interactive-login recommendation).
+var scryptN, scryptR, scryptP = 1 << 15, 8, 1
+
+var (
+	ErrNoPassphrase  = errors.New("a backup passphrase is required (backup.passphrase or BACKUP_PASSPHRASE)")
+	ErrNotArchive    = errors.New("not a backup archive")
+	ErrBadPassphrase = errors.New("wrong passphrase or corrupted archive")
+)
+
+// Passphrase returns given, or backup.passphrase when given is empty.
+func Passphrase(given string) (string, error) {
+	if given == "" {
+		given = shared.AppConfig.Backup.Passphrase
+	}
+	if given == "" {
+		return "", ErrNoPassphrase
+	}
+	return given, nil
+}
+
+// Seal encrypts a into an archive file with passphrase.
+func Seal(a *Archive, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(zw).Encode(a); err != nil {
+		return nil, fmt.Errorf("failed to encode archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[len(magic)] = formatV1
+	salt := header[len(magic)+1:]
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, plain.Bytes(), header), nil
+}
+
+// Open decrypts an archive file sealed with passphrase.
+func Open(data []byte, passphrase string) (*Archive, error) {
+	if passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+	if len(data) < headerSize || string(data[:len(magic)]) != magic {
+		return nil, ErrNotArchive
+	}
+	if v := data[len(magic)]; v != formatV1 {
+		return nil, fmt.Errorf("unsupported backup format %d", v)
+	}
+	header, rest := data[:headerSize], data[headerSize:]
+	gcm, err := newGCM(passphrase, header[len(magic)+1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, ErrNotArchive
+	}
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	a := &Archive{}
+	if err := json.NewDecoder(zr).Decode(a); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode archive: %w", err)
+	}
+	if a.Version > Version {
+		return nil, fmt.Errorf("archive version %d is newer than this server supports (%d)", a.Version, Version)
+	}
+	return a, nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive backup key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
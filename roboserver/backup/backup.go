@@ -0,0 +1,285 @@
+// Package backup dumps the server's durable state to a single encrypted
+// archive and restores it, for disaster recovery and moving to a new
+// server. An archive holds:
+//
+//   - robots: registry records with their command schema, labels and ACL
+//   - users (with password hashes; API keys and sessions are not kept)
+//   - macros
+//   - automation scripts (the *.lua files of automation.dir)
+//   - scheduled messages pending on the node that made the backup
+//   - config.yaml
+//
+// Restore merges into what is there: records in the archive overwrite ones
+// with the same key, and nothing else is deleted. config.yaml is only
+// written when asked for, since a new server usually has its own.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"roboserver/automation"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/macro"
+	"strings"
+	"time"
+)
+
+// Version is the archive layout this server writes.
+const Version = 1
+
+// ConfigPath is the config file included in archives.
+var ConfigPath = "config.yaml"
+
+// Archive is the contents of a backup.
+type Archive struct {
+	Version     int                               `json:"version"`
+	CreatedAt   int64                             `json:"created_at"` // Unix seconds
+	Node        string                            `json:"node,omitempty"`
+	Robots      []Robot                           `json:"robots"`
+	Users       []*database.User                  `json:"users"`
+	Macros      []*macro.Macro                    `json:"macros"`
+	Automations map[string]string                 `json:"automations"` // file name → Lua source
+	Schedules   []handler_engine.ScheduledMessage `json:"schedules"`
+	Config      string                            `json:"config,omitempty"`
+}
+
+// Robot is a registered robot and the state kept alongside its record.
+type Robot struct {
+	UUID          string                `json:"uuid"`
+	PublicKey     string                `json:"public_key"`
+	DeviceType    string                `json:"device_type"`
+	IsBlacklisted bool                  `json:"is_blacklisted"`
+	CreatedAt     time.Time             `json:"created_at"`
+	CommandSchema []byte                `json:"command_schema,omitempty"`
+	Labels        *database.RobotLabels `json:"labels,omitempty"`
+	ACL           []string              `json:"acl,omitempty"`
+}
+
+// Summary counts what a backup holds or a restore wrote.
+type Summary struct {
+	Robots      int  `json:"robots"`
+	Users       int  `json:"users"`
+	Macros      int  `json:"macros"`
+	Automations int  `json:"automations"`
+	Schedules   int  `json:"schedules"`
+	Skipped     int  `json:"skipped,omitempty"` // schedules not restored
+	Config      bool `json:"config"`
+}
+
+// Summary counts the archive's contents.
+func (a *Archive) Summary() Summary {
+	return Summary{
+		Robots:      len(a.Robots),
+		Users:       len(a.Users),
+		Macros:      len(a.Macros),
+		Automations: len(a.Automations),
+		Schedules:   len(a.Schedules),
+		Config:      a.Config != "",
+	}
+}
+
+// RestoreOptions choose what Restore writes besides the database state.
+type RestoreOptions struct {
+	Config bool // overwrite ConfigPath with the archived config.yaml
+}
+
+// Create reads the server's state into an archive. Scheduled messages are
+// only those of this process, so a backup made by the CLI has none.
+func Create(ctx context.Context, db database.DBManager) (*Archive, error) {
+	pg, rds := db.Postgres(), db.Redis()
+	if pg == nil || rds == nil {
+		return nil, errors.New("backup needs both PostgreSQL and Redis")
+	}
+	a := &Archive{
+		Version:     Version,
+		CreatedAt:   time.Now().Unix(),
+		Node:        shared.NodeID(),
+		Robots:      []Robot{},
+		Automations: make(map[string]string),
+		Schedules:   handler_engine.Scheduler.List(""),
+	}
+
+	err := pg.EachRobot(ctx, "", func(rec *database.RobotRecord) error {
+		a.Robots = append(a.Robots, Robot{
+			UUID:          rec.UUID,
+			PublicKey:     rec.PublicKey,
+			DeviceType:    rec.DeviceType,
+			IsBlacklisted: rec.IsBlacklisted,
+			CreatedAt:     rec.CreatedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read robots: %w", err)
+	}
+	for i := range a.Robots {
+		r := &a.Robots[i]
+		if r.CommandSchema, err = pg.GetRobotSchema(ctx, r.UUID); err != nil {
+			return nil, fmt.Errorf("failed to read the command schema of %s: %w", r.UUID, err)
+		}
+		labels, err := rds.GetRobotLabels(ctx, r.UUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the labels of %s: %w", r.UUID, err)
+		}
+		if len(labels.Tags) > 0 || labels.Zone != "" {
+			r.Labels = labels
+		}
+		if r.ACL, err = rds.GetRobotACL(ctx, r.UUID); err != nil {
+			return nil, fmt.Errorf("failed to read the ACL of %s: %w", r.UUID, err)
+		}
+	}
+
+	if a.Users, err = rds.ListUsers(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	if a.Macros, err = rds.ListMacros(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read macros: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(shared.AppConfig.Automation.Dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read automation script: %w", err)
+		}
+		a.Automations[filepath.Base(path)] = string(src)
+	}
+
+	if cfg, err := os.ReadFile(ConfigPath); err == nil {
+		a.Config = string(cfg)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", ConfigPath, err)
+	}
+	return a, nil
+}
+
+// Restore writes an archive's contents. bus (may be nil) is told about
+// every restored robot record so cached copies are dropped. Scheduled
+// messages are only restored while the scheduler runs (not from the CLI):
+// one-off messages whose time has passed are skipped, and repeating ones
+// resume at their next interval.
+func Restore(ctx context.Context, db database.DBManager, bus comms.Bus, a *Archive, opts RestoreOptions) (Summary, error) {
+	var sum Summary
+	pg, rds := db.Postgres(), db.Redis()
+	if pg == nil || rds == nil {
+		return sum, errors.New("restore needs both PostgreSQL and Redis")
+	}
+	if a.Version > Version {
+		return sum, fmt.Errorf("archive version %d is newer than this server supports (%d)", a.Version, Version)
+	}
+
+	for i := range a.Robots {
+		r := &a.Robots[i]
+		rec := &database.RobotRecord{
+			UUID:          r.UUID,
+			PublicKey:     r.PublicKey,
+			DeviceType:    r.DeviceType,
+			IsBlacklisted: r.IsBlacklisted,
+			CreatedAt:     r.CreatedAt,
+		}
+		if err := pg.RestoreRobot(ctx, rec, r.CommandSchema); err != nil {
+			return sum, fmt.Errorf("failed to restore robot %s: %w", r.UUID, err)
+		}
+		if r.Labels != nil {
+			if err := rds.SetRobotLabels(ctx, r.UUID, r.Labels); err != nil {
+				return sum, fmt.Errorf("failed to restore the labels of %s: %w", r.UUID, err)
+			}
+		}
+		for _, principal := range r.ACL {
+			if err := rds.GrantRobotAccess(ctx, r.UUID, principal); err != nil {
+				return sum, fmt.Errorf("failed to restore the ACL of %s: %w", r.UUID, err)
+			}
+		}
+		if bus != nil {
+			bus.PublishEvent(events.RobotRecord(r.UUID), events.RecordChange{UUID: r.UUID, Change: "restored"})
+		}
+		sum.Robots++
+	}
+
+	for _, u := range a.Users {
+		if err := rds.SetUser(ctx, u); err != nil {
+			return sum, fmt.Errorf("failed to restore user %s: %w", u.Username, err)
+		}
+		sum.Users++
+	}
+	for _, m := range a.Macros {
+		if err := rds.SetMacro(ctx, m); err != nil {
+			return sum, fmt.Errorf("failed to restore macro %s: %w", m.Name, err)
+		}
+		sum.Macros++
+	}
+
+	if len(a.Automations) > 0 {
+		dir := shared.AppConfig.Automation.Dir
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return sum, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		for name, src := range a.Automations {
+			if name != filepath.Base(name) || !strings.HasSuffix(name, ".lua") {
+				return sum, fmt.Errorf("invalid automation script name %q", name)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+				return sum, fmt.Errorf("failed to restore automation script %s: %w", name, err)
+			}
+			sum.Automations++
+		}
+		if _, err := automation.Default.Reload(); err != nil && !errors.Is(err, automation.ErrDisabled) {
+			return sum, err
+		}
+	}
+
+	restoreSchedules(a.Schedules, &sum)
+
+	if opts.Config && a.Config != "" {
+		if err := os.WriteFile(ConfigPath, []byte(a.Config), 0o644); err != nil {
+			return sum, fmt.Errorf("failed to restore %s: %w", ConfigPath, err)
+		}
+		sum.Config = true
+	}
+	return sum, nil
+}
+
+// restoreSchedules reschedules archived messages that are still due and not
+// already pending.
+func restoreSchedules(schedules []handler_engine.ScheduledMessage, sum *Summary) {
+	pending := make(map[string]bool)
+	key := func(m handler_engine.ScheduledMessage) string {
+		return fmt.Sprintf("%s\x00%s\x00%d", m.UUID, m.Message, m.EveryMs)
+	}
+	for _, m := range handler_engine.Scheduler.List("") {
+		pending[key(m)] = true
+	}
+
+	now := time.Now()
+	for _, m := range schedules {
+		at := time.UnixMilli(m.At)
+		every := time.Duration(m.EveryMs) * time.Millisecond
+		if at.Before(now) {
+			if every <= 0 {
+				sum.Skipped++
+				continue
+			}
+			at = at.Add((now.Sub(at)/every + 1) * every)
+		}
+		if pending[key(m)] {
+			sum.Skipped++
+			continue
+		}
+		if _, err := handler_engine.Scheduler.SendMessageAt(m.UUID, m.Message, m.CreatedBy, at, every); err != nil {
+			sum.Skipped++
+			continue
+		}
+		pending[key(m)] = true
+		sum.Schedules++
+	}
+}
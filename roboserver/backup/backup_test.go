@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"errors"
+	"roboserver/database"
+	"roboserver/shared"
+	"testing"
+	"time"
+)
+
+func testArchive() *Archive {
+	return &Archive{
+		Version:   Version,
+		CreatedAt: 1718000000,
+		Robots: []Robot{{
+			UUID:          "rover-7",
+			PublicKey:     "04ab",
+			DeviceType:    "rover",
+			CreatedAt:     time.Unix(1717000000, 0).UTC(),
+			CommandSchema: []byte(`{"commands":[]}`),
+			Labels:        &database.RobotLabels{Tags: []string{"yard"}, Zone: "north"},
+			ACL:           []string{"alice", "role:operator"},
+		}},
+		Users:       []*database.User{{Username: "alice", PasswordHash: "$2a$10$x", Role: "operator"}},
+		Automations: map[string]string{"lights.lua": "robomesh.log('hi')"},
+		Config:      "server:\n  port: 8080\n",
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	data, err := Seal(testArchive(), "correct horse")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if string(data[:4]) != "RMBK" {
+		t.Fatalf("Expected the archive magic, got %q", data[:4])
+	}
+
+	a, err := Open(data, "correct horse")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	r := a.Robots[0]
+	if r.UUID != "rover-7" || string(r.CommandSchema) != `{"commands":[]}` || r.Labels.Zone != "north" || len(r.ACL) != 2 {
+		t.Errorf("Robot did not round-trip: %+v", r)
+	}
+	if a.Users[0].PasswordHash != "$2a$10$x" || a.Automations["lights.lua"] == "" || a.Config == "" {
+		t.Errorf("Archive did not round-trip: %+v", a)
+	}
+	if sum := a.Summary(); sum.Robots != 1 || sum.Users != 1 || sum.Automations != 1 || !sum.Config {
+		t.Errorf("Unexpected summary: %+v", sum)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	data, err := Seal(testArchive(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(data, "wrong"); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("Expected a wrong passphrase to fail, got %v", err)
+	}
+
+	tampered := append([]byte(nil), data...)
+	tampered[len(magic)+1] ^= 1 // salt is authenticated
+	if _, err := Open(tampered, "correct horse"); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("Expected a modified header to fail, got %v", err)
+	}
+	if _, err := Open([]byte("PK\x03\x04 not a backup"), "x"); !errors.Is(err, ErrNotArchive) {
+		t.Errorf("Expected a foreign file to be refused, got %v", err)
+	}
+	if _, err := Seal(testArchive(), ""); !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("Expected an empty passphrase to be refused, got %v", err)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	saved := shared.AppConfig.Backup
+	defer func() { shared.AppConfig.Backup = saved }()
+
+	shared.AppConfig.Backup.Passphrase = ""
+	if _, err := Passphrase(""); !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("Expected no passphrase to be an error, got %v", err)
+	}
+	shared.AppConfig.Backup.Passphrase = "from-config"
+	if p, _ := Passphrase(""); p != "from-config" {
+		t.Errorf("Expected backup.passphrase as the default, got %q", p)
+	}
+	if p, _ := Passphrase("given"); p != "given" {
+		t.Errorf("Expected a given passphrase to win, got %q", p)
+	}
+}
@@ -0,0 +1,109 @@
+// Package cluster runs leader election between roboserver instances that
+// share one Redis. Exactly one instance is leader at a time; cluster-wide
+// periodic work (schedules, rules, cleanup) runs only where IsLeader is true
+// so it isn't repeated on every node.
+package cluster
+
+import (
+	"context"
+	"roboserver/shared"
+	"sync/atomic"
+	"time"
+)
+
+// LeaderStore holds the leader lock (implemented by database.RedisHandler).
+type LeaderStore interface {
+	CampaignLeader(ctx context.Context, node string, ttl time.Duration) (bool, error)
+	ResignLeader(ctx context.Context, node string) error
+}
+
+// Elector keeps this node campaigning for leadership. The lock is renewed
+// every ttl/3, so a crashed leader is replaced within ttl.
+type Elector struct {
+	store    LeaderStore
+	node     string
+	ttl      time.Duration
+	leader   atomic.Bool
+	onChange func(leader bool)
+}
+
+// NewElector creates an elector for node. onChange (optional) is called
+// whenever this node gains or loses leadership.
+func NewElector(store LeaderStore, node string, ttl time.Duration, onChange func(leader bool)) *Elector {
+	return &Elector{store: store, node: node, ttl: ttl, onChange: onChange}
+}
+
+// Node returns the node id this elector campaigns as.
+func (e *Elector) Node() string {
+	return e.node
+}
+
+// IsLeader reports whether this node currently holds the leader lock.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is cancelled, then resigns so a peer can take
+// over immediately (zero-downtime upgrades restart one node at a time).
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				resignCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				if err := e.store.ResignLeader(resignCtx, e.node); err != nil {
+					shared.DebugPrint("Cluster: failed to resign leadership: %v", err)
+				}
+				cancel()
+				e.setLeader(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign takes or renews the lock once. A Redis error counts as lost
+// leadership: if we can't renew, a peer may already have taken over.
+func (e *Elector) campaign(ctx context.Context) {
+	won, err := e.store.CampaignLeader(ctx, e.node, e.ttl)
+	if err != nil {
+		if ctx.Err() == nil {
+			shared.DebugPrint("Cluster: leader campaign failed: %v", err)
+		}
+		won = false
+	}
+	e.setLeader(won)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		shared.DebugPrint("Cluster: node %s is now leader", e.node)
+	} else {
+		shared.DebugPrint("Cluster: node %s is no longer leader", e.node)
+	}
+	if e.onChange != nil {
+		e.onChange(leader)
+	}
+}
+
+var current atomic.Pointer[Elector]
+
+// SetElector installs the process-wide elector used by IsLeader.
+func SetElector(e *Elector) {
+	current.Store(e)
+}
+
+// IsLeader reports whether this node should run cluster-wide work. Without
+// clustering every node is its own leader.
+func IsLeader() bool {
+	e := current.Load()
+	return e == nil || e.IsLeader()
+}
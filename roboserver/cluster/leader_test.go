@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore is an in-memory leader lock shared by several electors.
+type fakeStore struct {
+	mu     sync.Mutex
+	holder string
+	fail   bool
+}
+
+func (f *fakeStore) CampaignLeader(ctx context.Context, node string, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return false, errors.New("redis down")
+	}
+	if f.holder == "" {
+		f.holder = node
+	}
+	return f.holder == node, nil
+}
+
+func (f *fakeStore) ResignLeader(ctx context.Context, node string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.holder == node {
+		f.holder = ""
+	}
+	return nil
+}
+
+func TestOnlyOneLeader(t *testing.T) {
+	store := &fakeStore{}
+	a := NewElector(store, "a", time.Minute, nil)
+	b := NewElector(store, "b", time.Minute, nil)
+
+	a.campaign(context.Background())
+	b.campaign(context.Background())
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("Expected a to lead alone, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	store.ResignLeader(context.Background(), "a")
+	b.campaign(context.Background())
+	if !b.IsLeader() {
+		t.Error("Expected b to take over after a resigned")
+	}
+}
+
+func TestCampaignErrorLosesLeadership(t *testing.T) {
+	store := &fakeStore{}
+	var changes []bool
+	e := NewElector(store, "a", time.Minute, func(leader bool) { changes = append(changes, leader) })
+
+	e.campaign(context.Background())
+	store.fail = true
+	e.campaign(context.Background())
+
+	if e.IsLeader() {
+		t.Error("Expected leadership to be dropped when the lock can't be renewed")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("Expected onChange(true) then onChange(false), got %v", changes)
+	}
+}
+
+func TestRunResignsOnShutdown(t *testing.T) {
+	store := &fakeStore{}
+	e := NewElector(store, "a", time.Minute, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !e.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if e.IsLeader() || store.holder != "" {
+		t.Errorf("Expected the lock to be released, holder=%q", store.holder)
+	}
+}
+
+func TestIsLeaderWithoutClustering(t *testing.T) {
+	SetElector(nil)
+	if !IsLeader() {
+		t.Error("Expected a standalone node to be its own leader")
+	}
+	store := &fakeStore{holder: "other"}
+	e := NewElector(store, "a", time.Minute, nil)
+	SetElector(e)
+	defer SetElector(nil)
+	e.campaign(context.Background())
+	if IsLeader() {
+		t.Error("Expected IsLeader to follow the installed elector")
+	}
+}
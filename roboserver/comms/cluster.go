@@ -0,0 +1,143 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"roboserver/shared"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"time"
+)
+
+// clusterQueueSize bounds events waiting to be relayed to Redis; beyond it
+// new events are not relayed (they are still delivered locally).
+const clusterQueueSize = 1024
+
+// clusterEnvelope is one relayed event on the Redis channel.
+type clusterEnvelope struct {
+	Node        string          `json:"node"`
+	Type        string          `json:"type"`
+	Data        json.RawMessage `json:"data"`
+	TraceParent string          `json:"traceparent,omitempty"`
+	ExpiresAt   int64           `json:"expires_at,omitempty"` // Unix ms, for events published with a TTL
+}
+
+// clusterRelay fans events out to other instances over Redis pub/sub.
+// Relaying is best effort, like the exporter: a slow Redis never stalls
+// PublishEvent.
+type clusterRelay struct {
+	node    string
+	channel string
+	match   func(eventType string) bool
+	queue   chan []byte
+}
+
+// EnableCluster relays events published on this bus to every other instance
+// subscribed to the same Redis channel, and delivers theirs locally as
+// event_bus.RemoteEvent. patterns select which event types are relayed
+// (empty = all). It runs until ctx is cancelled.
+func (b *LocalBus) EnableCluster(ctx context.Context, channel string, patterns []string) error {
+	if b.rds == nil {
+		return fmt.Errorf("cluster fan-out needs Redis")
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	relay := &clusterRelay{
+		node:    shared.NodeID(),
+		channel: channel,
+		match:   clusterMatcher(patterns),
+		queue:   make(chan []byte, clusterQueueSize),
+	}
+
+	// Subscribe before relaying so this node never misses its peers' events
+	// published after startup.
+	sub := b.rds.Client.Subscribe(ctx, channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", channel, err)
+	}
+	b.cluster.Store(relay)
+
+	go func() {
+		defer sub.Close()
+		for msg := range sub.Channel() {
+			b.deliverRemote(relay, []byte(msg.Payload))
+		}
+	}()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data := <-relay.queue:
+				if err := b.rds.Client.Publish(ctx, channel, data).Err(); err != nil {
+					shared.DebugPrint("Cluster relay failed: %v", err)
+				}
+			}
+		}
+	}()
+
+	shared.DebugPrint("Cluster event fan-out on Redis channel %s (node %s)", channel, relay.node)
+	return nil
+}
+
+// clusterMatcher selects relayed events. Messages routed to a handler on
+// another node (events.HandlerIncoming), kicks, IP conflicts and PING
+// requests act on the node holding the robot, and request replies go back
+// to the requester's node, so they are always relayed.
+func clusterMatcher(patterns []string) func(eventType string) bool {
+	match := events.Matcher(patterns)
+	return func(eventType string) bool {
+		return match(eventType) || events.IsHandlerIncoming(eventType) ||
+			eventType == events.RobotKicked || eventType == events.IPConflict ||
+			events.IsRobotPing(eventType) || IsReplyTopic(eventType)
+	}
+}
+
+// relay queues a locally published event for the other instances.
+func (r *clusterRelay) relay(eventType string, data any, traceParent string, expires time.Time) {
+	if !r.match(eventType) {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		shared.DebugPrint("Cluster relay: cannot encode %s: %v", eventType, err)
+		return
+	}
+	env := clusterEnvelope{Node: r.node, Type: eventType, Data: raw, TraceParent: traceParent}
+	if !expires.IsZero() {
+		env.ExpiresAt = expires.UnixMilli()
+	}
+	encoded, _ := json.Marshal(env)
+	select {
+	case r.queue <- encoded:
+	default:
+		shared.DebugPrint("Cluster relay queue full, not relaying event: %s", eventType)
+	}
+}
+
+// deliverRemote publishes a peer's event on the local bus only. An event
+// that expired in transit (by this node's clock) is dropped by the bus.
+func (b *LocalBus) deliverRemote(relay *clusterRelay, payload []byte) {
+	var env clusterEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil || env.Type == "" {
+		return
+	}
+	if env.Node == relay.node {
+		return // our own event, already delivered locally
+	}
+	var data any
+	if err := json.Unmarshal(env.Data, &data); err != nil || data == nil {
+		return
+	}
+	event := &event_bus.RemoteEvent{
+		DefaultEvent: event_bus.DefaultEvent{Type: env.Type, Data: data, TraceParent: env.TraceParent},
+		Node:         env.Node,
+	}
+	if env.ExpiresAt != 0 {
+		event.ExpiresAt = time.UnixMilli(env.ExpiresAt)
+	}
+	b.eb.Publish(event)
+}
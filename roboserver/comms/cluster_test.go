@@ -0,0 +1,123 @@
+package comms
+
+import (
+	"encoding/json"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"testing"
+	"time"
+)
+
+func TestClusterRelayEncodesMatchingEvents(t *testing.T) {
+	relay := &clusterRelay{node: "node-a", match: events.Matcher([]string{"robot.*"}), queue: make(chan []byte, 4)}
+
+	relay.relay("robot.r1.status", map[string]string{"status": "online"}, "", time.Time{})
+	relay.relay("handler.r1.log", "ignored", "", time.Time{})
+
+	if len(relay.queue) != 1 {
+		t.Fatalf("Expected one relayed event, got %d", len(relay.queue))
+	}
+	var env clusterEnvelope
+	if err := json.Unmarshal(<-relay.queue, &env); err != nil {
+		t.Fatalf("Bad envelope: %v", err)
+	}
+	if env.Node != "node-a" || env.Type != "robot.r1.status" || string(env.Data) != `{"status":"online"}` {
+		t.Errorf("Unexpected envelope: %+v", env)
+	}
+}
+
+func TestDeliverRemoteSkipsOwnEvents(t *testing.T) {
+	bus := newTestBus()
+	relay := &clusterRelay{node: "node-a"}
+
+	received := make(chan event_bus.Event, 2)
+	cancel := bus.eb.Tap(func(e event_bus.Event) { received <- e })
+	defer cancel()
+
+	own, _ := json.Marshal(clusterEnvelope{Node: "node-a", Type: "robot.r1.status", Data: json.RawMessage(`"x"`)})
+	peer, _ := json.Marshal(clusterEnvelope{Node: "node-b", Type: "robot.r2.status", Data: json.RawMessage(`{"status":"busy"}`)})
+	bus.deliverRemote(relay, own)
+	bus.deliverRemote(relay, peer)
+
+	select {
+	case e := <-received:
+		if e.GetType() != "robot.r2.status" || !event_bus.IsRemote(e) {
+			t.Errorf("Expected the peer's event as a RemoteEvent, got %s remote=%v", e.GetType(), event_bus.IsRemote(e))
+		}
+		if data, ok := e.GetData().(map[string]any); !ok || data["status"] != "busy" {
+			t.Errorf("Expected decoded payload, got %#v", e.GetData())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Peer event was not delivered")
+	}
+	if len(received) != 0 {
+		t.Error("Own event must not be delivered twice")
+	}
+}
+
+func TestClusterMatcherAlwaysRelaysRoutedMessages(t *testing.T) {
+	match := clusterMatcher([]string{"robot.*"})
+	if !match(events.HandlerIncoming("r1")) {
+		t.Error("Expected routed handler messages to be relayed regardless of patterns")
+	}
+	if !match(events.RobotPing("r1")) || !match(ReplyTopic(events.RobotPing("r1"), "abc")) {
+		t.Error("Expected ping requests and their replies to be relayed regardless of patterns")
+	}
+	if match(events.HandlerLog("r1")) {
+		t.Error("Expected unmatched events not to be relayed")
+	}
+}
+
+func TestClusterRelayCarriesTraceParent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	relay := &clusterRelay{node: "node-a", match: events.Matcher([]string{"*"}), queue: make(chan []byte, 1)}
+	relay.relay("robot.r1.status", "online", tp, time.Time{})
+
+	var env clusterEnvelope
+	raw := <-relay.queue
+	if json.Unmarshal(raw, &env) != nil || env.TraceParent != tp {
+		t.Fatalf("Expected the traceparent in the envelope, got %s", raw)
+	}
+
+	bus := newTestBus()
+	received := make(chan event_bus.Event, 1)
+	cancel := bus.eb.Tap(func(e event_bus.Event) { received <- e })
+	defer cancel()
+	bus.deliverRemote(&clusterRelay{node: "node-b"}, raw)
+	select {
+	case e := <-received:
+		if got := event_bus.TraceParentOf(e); got != tp {
+			t.Errorf("Expected the remote event to keep its traceparent, got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Peer event was not delivered")
+	}
+}
+
+func TestClusterRelayCarriesExpiry(t *testing.T) {
+	relay := &clusterRelay{node: "node-a", match: events.Matcher([]string{"*"}), queue: make(chan []byte, 2)}
+	expires := time.Now().Add(time.Minute)
+	relay.relay("door.r1.prompt", "open", "", expires)
+	relay.relay("door.r1.prompt", "stale", "", time.Now().Add(-time.Second))
+
+	var env clusterEnvelope
+	raw := <-relay.queue
+	if json.Unmarshal(raw, &env) != nil || env.ExpiresAt != expires.UnixMilli() {
+		t.Fatalf("Expected the expiry in the envelope, got %s", raw)
+	}
+
+	bus := newTestBus()
+	received := make(chan event_bus.Event, 2)
+	cancel := bus.eb.Tap(func(e event_bus.Event) { received <- e })
+	defer cancel()
+	peer := &clusterRelay{node: "node-b"}
+	bus.deliverRemote(peer, raw)
+	bus.deliverRemote(peer, <-relay.queue)
+
+	if len(received) != 1 {
+		t.Fatalf("Expected only the unexpired event delivered, got %d", len(received))
+	}
+	if got := event_bus.ExpiresAt(<-received); got.UnixMilli() != expires.UnixMilli() {
+		t.Errorf("Expected the remote event to keep its expiry, got %v", got)
+	}
+}
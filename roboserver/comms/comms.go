@@ -9,7 +9,10 @@
 // at initialization — no service code changes required.
 package comms
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Bus is the single abstraction all services use for communication.
 // Services import comms.Bus instead of depending on each other.
@@ -22,6 +25,11 @@ type Bus interface {
 	// asynchronously — long-running handlers should be aware of concurrency.
 	SubscribeEvent(eventType string, handler EventHandler) (cancel func(), err error)
 
+	// SubscribeMatching registers a handler for every event whose type
+	// satisfies match, e.g. all events scoped to one robot. The handler runs
+	// on the publisher's goroutine and must not block.
+	SubscribeMatching(match func(eventType string) bool, handler EventHandler) (cancel func(), err error)
+
 	// PublishToGroup sends an event that only ONE subscriber in the named
 	// group will receive (competing consumers). In a single-instance deployment,
 	// this behaves like round-robin across subscribers. For Kafka/NATS migration,
@@ -29,7 +37,8 @@ type Bus interface {
 	PublishToGroup(group string, eventType string, data any) error
 
 	// SubscribeAsGroup joins a consumer group. Only one member per group
-	// receives each published event.
+	// receives each published event, whether it was sent with PublishToGroup
+	// or PublishEvent.
 	SubscribeAsGroup(group string, eventType string, handler EventHandler) (cancel func(), err error)
 
 	// PublishRegistrationResponse sends an accept/reject decision for a
@@ -49,4 +58,52 @@ type EventHandler func(eventType string, data any)
 type Event struct {
 	Type string
 	Data any
+	// ExpiresAt is when the event goes stale (see PublishTTL); zero if never.
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the event has gone stale by now.
+func (e *Event) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// PublishContext publishes an event as part of ctx's trace when bus
+// supports it (LocalBus.PublishEventContext), and with PublishEvent
+// otherwise.
+func PublishContext(ctx context.Context, bus Bus, eventType string, data any) error {
+	if traced, ok := bus.(interface {
+		PublishEventContext(ctx context.Context, eventType string, data any) error
+	}); ok {
+		return traced.PublishEventContext(ctx, eventType, data)
+	}
+	return bus.PublishEvent(eventType, data)
+}
+
+// PublishTTL publishes an event that is only worth delivering for ttl, such
+// as a "door is open" prompt: once it expires, bus queues and subscribers
+// that queue events (SubscribeExpiring) drop it instead of delivering it
+// late. Buses without TTL support (LocalBus.PublishEventTTL) publish it
+// with PublishEvent.
+func PublishTTL(bus Bus, eventType string, data any, ttl time.Duration) error {
+	if expiring, ok := bus.(interface {
+		PublishEventTTL(eventType string, data any, ttl time.Duration) error
+	}); ok && ttl > 0 {
+		return expiring.PublishEventTTL(eventType, data, ttl)
+	}
+	return bus.PublishEvent(eventType, data)
+}
+
+// SubscribeExpiring is SubscribeEvent for subscribers that queue events
+// before delivering them: the handler gets each event with its expiry, so
+// the queue can drop it once stale. With buses that don't support it
+// (LocalBus.SubscribeEventExpiring) events never expire.
+func SubscribeExpiring(bus Bus, eventType string, handler func(Event)) (cancel func(), err error) {
+	if expiring, ok := bus.(interface {
+		SubscribeEventExpiring(eventType string, handler func(Event)) (func(), error)
+	}); ok {
+		return expiring.SubscribeEventExpiring(eventType, handler)
+	}
+	return bus.SubscribeEvent(eventType, func(et string, data any) {
+		handler(Event{Type: et, Data: data})
+	})
 }
@@ -2,11 +2,13 @@ package comms
 
 import (
 	"context"
-	"fmt"
 	"roboserver/database"
+	"roboserver/shared"
 	"roboserver/shared/event_bus"
+	"roboserver/shared/tracing"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // LocalBus implements Bus using the in-process event bus and Redis pub/sub.
@@ -16,15 +18,27 @@ type LocalBus struct {
 	eb  event_bus.EventBus
 	rds *database.RedisHandler
 
-	// Consumer groups for point-to-point delivery (round-robin in single-instance)
+	// Consumer groups for point-to-point delivery (round-robin in
+	// single-instance), by event type then group name. groupTap feeds them
+	// events published with PublishEvent once the first group exists.
 	groupsMu sync.RWMutex
-	groups   map[string]*consumerGroup
+	groups   map[string]map[string]*consumerGroup
+	groupTap sync.Once
+
+	// Set by EnableCluster to relay events to other instances
+	cluster atomic.Pointer[clusterRelay]
 }
 
+// groupMemberLimit bounds the published events one group member handles
+// at once. Busier members are skipped; an event no member can take is
+// dropped.
+const groupMemberLimit = 64
+
 // consumerGroupEntry wraps a handler with a stable ID for safe removal.
 type consumerGroupEntry struct {
-	id      uint64
-	handler EventHandler
+	id       uint64
+	handler  EventHandler
+	inFlight atomic.Int64
 }
 
 // consumerGroup tracks handlers for competing-consumer delivery.
@@ -40,15 +54,44 @@ func NewLocalBus(eb event_bus.EventBus, rds *database.RedisHandler) *LocalBus {
 	return &LocalBus{
 		eb:     eb,
 		rds:    rds,
-		groups: make(map[string]*consumerGroup),
+		groups: make(map[string]map[string]*consumerGroup),
 	}
 }
 
 func (b *LocalBus) PublishEvent(eventType string, data any) error {
-	b.eb.PublishData(eventType, data)
+	b.publish(eventType, data, "", time.Time{})
 	return nil
 }
 
+// PublishEventTTL is PublishEvent for an event that goes stale after ttl
+// (see PublishTTL).
+func (b *LocalBus) PublishEventTTL(eventType string, data any, ttl time.Duration) error {
+	b.publish(eventType, data, "", time.Now().Add(ttl))
+	return nil
+}
+
+// PublishEventContext is PublishEvent as part of ctx's trace: it records a
+// producer span, which handler spans on this and other nodes continue (see
+// event_bus.TraceHandlers).
+func (b *LocalBus) PublishEventContext(ctx context.Context, eventType string, data any) error {
+	ctx, span := tracing.StartSpan(ctx, tracing.KindProducer, "bus.publish")
+	span.SetAttr("event.type", eventType)
+	defer span.End()
+	b.publish(eventType, data, tracing.Inject(ctx), time.Time{})
+	return nil
+}
+
+func (b *LocalBus) publish(eventType string, data any, traceParent string, expires time.Time) {
+	if traceParent == "" && expires.IsZero() {
+		b.eb.PublishData(eventType, data)
+	} else if data != nil {
+		b.eb.Publish(&event_bus.DefaultEvent{Type: eventType, Data: data, TraceParent: traceParent, ExpiresAt: expires})
+	}
+	if relay := b.cluster.Load(); relay != nil && eventType != "" && data != nil {
+		relay.relay(eventType, data, traceParent, expires)
+	}
+}
+
 func (b *LocalBus) SubscribeEvent(eventType string, handler EventHandler) (func(), error) {
 	sub := event_bus.NewSubscriber()
 	b.eb.Subscribe(eventType, sub, func(event event_bus.Event) {
@@ -60,45 +103,118 @@ func (b *LocalBus) SubscribeEvent(eventType string, handler EventHandler) (func(
 	return cancel, nil
 }
 
-func groupKey(group, eventType string) string {
-	return fmt.Sprintf("%s::%s", group, eventType)
+// SubscribeEventExpiring is SubscribeEvent passing each event's expiry (see
+// SubscribeExpiring).
+func (b *LocalBus) SubscribeEventExpiring(eventType string, handler func(Event)) (func(), error) {
+	sub := event_bus.NewSubscriber()
+	b.eb.Subscribe(eventType, sub, func(event event_bus.Event) {
+		handler(Event{Type: event.GetType(), Data: event.GetData(), ExpiresAt: event_bus.ExpiresAt(event)})
+	})
+	cancel := func() {
+		b.eb.Unsubscribe(eventType, sub)
+	}
+	return cancel, nil
 }
 
-func (b *LocalBus) PublishToGroup(group string, eventType string, data any) error {
-	key := groupKey(group, eventType)
+func (b *LocalBus) SubscribeMatching(match func(eventType string) bool, handler EventHandler) (func(), error) {
+	cancel := b.eb.Tap(func(event event_bus.Event) {
+		if match(event.GetType()) {
+			handler(event.GetType(), event.GetData())
+		}
+	})
+	return cancel, nil
+}
 
+// next picks the member to receive an event, round-robin. With skipBusy,
+// members already handling groupMemberLimit events are passed over. It
+// returns nil if no member can take the event, along with the group's size.
+func (cg *consumerGroup) next(skipBusy bool) (*consumerGroupEntry, int) {
+	// len(cg.handlers) must be read under the group lock — concurrent
+	// SubscribeAsGroup / cancel can mutate the slice.
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	n := uint64(len(cg.handlers))
+	if n == 0 {
+		return nil, 0
+	}
+	start := cg.counter.Add(1) - 1
+	for i := range n {
+		entry := cg.handlers[(start+i)%n]
+		if !skipBusy || entry.inFlight.Load() < groupMemberLimit {
+			return entry, int(n)
+		}
+	}
+	return nil, int(n)
+}
+
+func (b *LocalBus) PublishToGroup(group string, eventType string, data any) error {
 	b.groupsMu.RLock()
-	cg, ok := b.groups[key]
+	cg, ok := b.groups[eventType][group]
 	b.groupsMu.RUnlock()
 
 	if !ok {
 		return nil // no group registered
 	}
+	if entry, _ := cg.next(false); entry != nil {
+		entry.handler(eventType, data)
+	}
+	return nil
+}
 
-	// len(cg.handlers) must be read under the group lock — concurrent
-	// SubscribeAsGroup / cancel can mutate the slice.
-	cg.mu.Lock()
-	n := len(cg.handlers)
-	if n == 0 {
-		cg.mu.Unlock()
-		return nil
+// deliverToGroups hands an event published with PublishEvent to one member
+// of each group subscribed to its type, on a goroutine of its own; a
+// handler panic is logged and doesn't bring the server down. Events
+// relayed from other cluster nodes are skipped: groups are node-local, and
+// the publishing node's groups already got the event.
+func (b *LocalBus) deliverToGroups(event event_bus.Event) {
+	if event_bus.IsRemote(event) {
+		return
 	}
-	idx := cg.counter.Add(1) - 1
-	entry := cg.handlers[idx%uint64(n)]
-	cg.mu.Unlock()
+	eventType := event.GetType()
+	b.groupsMu.RLock()
+	groups := b.groups[eventType]
+	members := make([]*consumerGroupEntry, 0, len(groups))
+	for name, cg := range groups {
+		entry, size := cg.next(true)
+		if entry == nil {
+			if size > 0 {
+				shared.DebugPrint("Consumer group %s saturated, dropping event: %s", name, eventType)
+			}
+			continue
+		}
+		members = append(members, entry)
+	}
+	b.groupsMu.RUnlock()
 
-	entry.handler(eventType, data)
-	return nil
+	for _, entry := range members {
+		entry.inFlight.Add(1)
+		go func() {
+			defer entry.inFlight.Add(-1)
+			// This goroutine is not the event bus's, so its recovery doesn't
+			// cover a panicking group member.
+			defer func() {
+				if r := recover(); r != nil {
+					shared.DebugErrorf("Consumer group handler panic on %s: %v", eventType, r)
+				}
+			}()
+			entry.handler(eventType, event.GetData())
+		}()
+	}
 }
 
 func (b *LocalBus) SubscribeAsGroup(group string, eventType string, handler EventHandler) (func(), error) {
-	key := groupKey(group, eventType)
+	b.groupTap.Do(func() {
+		b.eb.Tap(b.deliverToGroups)
+	})
 
 	b.groupsMu.Lock()
-	cg, ok := b.groups[key]
+	if b.groups[eventType] == nil {
+		b.groups[eventType] = make(map[string]*consumerGroup)
+	}
+	cg, ok := b.groups[eventType][group]
 	if !ok {
 		cg = &consumerGroup{}
-		b.groups[key] = cg
+		b.groups[eventType][group] = cg
 	}
 	b.groupsMu.Unlock()
 
@@ -87,6 +87,74 @@ func TestPublishToGroup_RoundRobin(t *testing.T) {
 	}
 }
 
+func TestPublishEvent_DeliversOncePerGroup(t *testing.T) {
+	bus := newTestBus()
+	var writers, auditors [2]atomic.Int32
+	for i := 0; i < 2; i++ {
+		idx := i
+		bus.SubscribeAsGroup("db-writers", "robot.r1.telemetry", func(string, any) { writers[idx].Add(1) })
+		bus.SubscribeAsGroup("auditors", "robot.r1.telemetry", func(string, any) { auditors[idx].Add(1) })
+	}
+
+	for i := 0; i < 10; i++ {
+		bus.PublishEvent("robot.r1.telemetry", i)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for name, counts := range map[string]*[2]atomic.Int32{"db-writers": &writers, "auditors": &auditors} {
+		if a, b := counts[0].Load(), counts[1].Load(); a+b != 10 || a == 0 || b == 0 {
+			t.Errorf("%s: expected 10 events shared by both members, got %d and %d", name, a, b)
+		}
+	}
+}
+
+func TestPublishEvent_SkipsRemoteEventsForGroups(t *testing.T) {
+	eb := event_bus.NewEventBus()
+	bus := NewLocalBus(eb, nil)
+	var count atomic.Int32
+	bus.SubscribeAsGroup("db-writers", "robot.r1.telemetry", func(string, any) { count.Add(1) })
+
+	eb.Publish(&event_bus.RemoteEvent{DefaultEvent: *event_bus.NewDefaultEvent("robot.r1.telemetry", 1), Node: "other"})
+	time.Sleep(50 * time.Millisecond)
+	if count.Load() != 0 {
+		t.Error("Expected an event relayed from another node to skip consumer groups")
+	}
+}
+
+func TestPublishEvent_GroupHandlerPanicRecovered(t *testing.T) {
+	bus := newTestBus()
+	var calls atomic.Int32
+	bus.SubscribeAsGroup("db-writers", "robot.r1.telemetry", func(string, any) {
+		calls.Add(1)
+		panic("boom")
+	})
+
+	bus.PublishEvent("robot.r1.telemetry", 1)
+	bus.PublishEvent("robot.r1.telemetry", 2)
+	time.Sleep(50 * time.Millisecond)
+	if calls.Load() != 2 {
+		t.Errorf("Expected the member to keep receiving events after a panic, got %d calls", calls.Load())
+	}
+}
+
+func TestConsumerGroupSkipsBusyMembers(t *testing.T) {
+	cg := &consumerGroup{}
+	busy := &consumerGroupEntry{id: 1}
+	idle := &consumerGroupEntry{id: 2}
+	busy.inFlight.Store(groupMemberLimit)
+	cg.handlers = []*consumerGroupEntry{busy, idle}
+
+	for i := 0; i < 4; i++ {
+		if entry, _ := cg.next(true); entry != idle {
+			t.Fatalf("Expected the idle member, got %d", entry.id)
+		}
+	}
+	idle.inFlight.Store(groupMemberLimit)
+	if entry, size := cg.next(true); entry != nil || size != 2 {
+		t.Errorf("Expected no member for a saturated group, got %v (size %d)", entry, size)
+	}
+}
+
 func TestPublishToGroup_NoSubscribers(t *testing.T) {
 	bus := newTestBus()
 	// Should not panic
@@ -143,3 +211,62 @@ func TestPublishToGroup_Concurrent(t *testing.T) {
 		t.Errorf("Expected 100 total events, got %d", total.Load())
 	}
 }
+
+func TestSubscribeMatching(t *testing.T) {
+	bus := newTestBus()
+	var got []string
+	var mu sync.Mutex
+
+	cancel, err := bus.SubscribeMatching(func(eventType string) bool {
+		return eventType != "other.event"
+	}, func(eventType string, data any) {
+		mu.Lock()
+		got = append(got, eventType)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	bus.PublishEvent("robot.a.status", "x")
+	bus.PublishEvent("other.event", "x")
+	bus.PublishEvent("handler.a.log", "x")
+	cancel()
+	bus.PublishEvent("robot.a.heartbeat", "x")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0] != "robot.a.status" || got[1] != "handler.a.log" {
+		t.Errorf("Expected [robot.a.status handler.a.log], got %v", got)
+	}
+}
+
+func TestPublishTTL(t *testing.T) {
+	bus := newTestBus()
+	received := make(chan Event, 2)
+	cancel, err := SubscribeExpiring(bus, "door.prompt", func(e Event) { received <- e })
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer cancel()
+
+	before := time.Now()
+	PublishTTL(bus, "door.prompt", "open", 5*time.Second)
+	PublishTTL(bus, "door.prompt", "stale", -time.Second)
+
+	got := make(map[any]Event)
+	for range 2 {
+		select {
+		case e := <-received:
+			got[e.Data] = e
+		case <-time.After(time.Second):
+			t.Fatal("Event handler was not called")
+		}
+	}
+	if e := got["open"]; e.ExpiresAt.Before(before.Add(5*time.Second)) || e.Expired(time.Now()) {
+		t.Errorf("Expected the event with its expiry, got %+v", e)
+	}
+	if e, ok := got["stale"]; !ok || !e.ExpiresAt.IsZero() {
+		t.Errorf("Expected a non-positive TTL to publish without expiry, got %+v", e)
+	}
+}
@@ -0,0 +1,100 @@
+package comms
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrRequestTimeout is returned by Request and Await when no reply arrives
+// within the timeout.
+var ErrRequestTimeout = errors.New("timed out waiting for reply")
+
+// RequestEvent is the payload Request publishes. Responders answer with
+// Reply, which publishes on ReplyTo.
+type RequestEvent struct {
+	ID      string `json:"id"`
+	ReplyTo string `json:"reply_to"`
+	Data    any    `json:"data"`
+}
+
+// ReplyTopic is the event type replies to request id on eventType use.
+func ReplyTopic(eventType, id string) string {
+	return eventType + ".reply." + id
+}
+
+// Request publishes payload on eventType wrapped in a RequestEvent with a
+// fresh correlation ID, then waits for the matching reply (see Reply) until
+// timeout or ctx ends. It returns the reply's data.
+func Request(ctx context.Context, bus Bus, eventType string, payload any, timeout time.Duration) (any, error) {
+	req := RequestEvent{ID: uuid.New().String(), Data: payload}
+	req.ReplyTo = ReplyTopic(eventType, req.ID)
+	return Await(ctx, bus, req.ReplyTo, timeout, func() error {
+		return bus.PublishEvent(eventType, req)
+	}, nil)
+}
+
+// DecodeRequestEvent reads a RequestEvent published locally or relayed from
+// another node (where it arrives as decoded JSON).
+func DecodeRequestEvent(data any) (RequestEvent, bool) {
+	switch v := data.(type) {
+	case RequestEvent:
+		return v, v.ReplyTo != ""
+	case map[string]any:
+		id, _ := v["id"].(string)
+		replyTo, _ := v["reply_to"].(string)
+		return RequestEvent{ID: id, ReplyTo: replyTo, Data: v["data"]}, replyTo != ""
+	}
+	return RequestEvent{}, false
+}
+
+// IsReplyTopic reports whether eventType is a ReplyTopic.
+func IsReplyTopic(eventType string) bool {
+	return strings.Contains(eventType, ".reply.")
+}
+
+// Reply answers a RequestEvent received from Request.
+func Reply(bus Bus, req RequestEvent, data any) error {
+	if req.ReplyTo == "" {
+		return errors.New("request has no reply topic")
+	}
+	return bus.PublishEvent(req.ReplyTo, data)
+}
+
+// Await subscribes to eventType, calls send, and returns the data of the
+// first event accepted by match (nil accepts any). Subscribing before send
+// means a fast reply can't be missed. An error from send is returned as-is.
+func Await(ctx context.Context, bus Bus, eventType string, timeout time.Duration, send func() error, match func(data any) bool) (any, error) {
+	replies := make(chan any, 1)
+	cancel, err := bus.SubscribeEvent(eventType, func(_ string, data any) {
+		if match != nil && !match(data) {
+			return
+		}
+		select {
+		case replies <- data:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	if err := send(); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case data := <-replies:
+		return data, nil
+	case <-timer.C:
+		return nil, ErrRequestTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
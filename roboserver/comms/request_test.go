@@ -0,0 +1,51 @@
+package comms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestReply(t *testing.T) {
+	bus := newTestBus()
+	cancel, _ := bus.SubscribeEvent("math.double", func(eventType string, data any) {
+		req := data.(RequestEvent)
+		Reply(bus, req, req.Data.(int)*2)
+	})
+	defer cancel()
+
+	reply, err := Request(context.Background(), bus, "math.double", 21, time.Second)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if reply != 42 {
+		t.Errorf("Expected 42, got %v", reply)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	bus := newTestBus()
+	_, err := Request(context.Background(), bus, "nobody.listens", "ping", 20*time.Millisecond)
+	if !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("Expected ErrRequestTimeout, got %v", err)
+	}
+}
+
+func TestAwaitMatchAndSendError(t *testing.T) {
+	bus := newTestBus()
+
+	data, err := Await(context.Background(), bus, "answers", time.Second, func() error {
+		bus.PublishEvent("answers", "wrong")
+		bus.PublishEvent("answers", "right")
+		return nil
+	}, func(data any) bool { return data == "right" })
+	if err != nil || data != "right" {
+		t.Errorf("Expected the matching event, got %v (%v)", data, err)
+	}
+
+	sendErr := errors.New("not connected")
+	if _, err := Await(context.Background(), bus, "answers", time.Second, func() error { return sendErr }, nil); err != sendErr {
+		t.Errorf("Expected the send error, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"roboserver/shared"
 
@@ -17,20 +18,69 @@ type DBManager_t struct {
 
 // Start initializes PostgreSQL and Redis connections and returns a DBManager.
 func Start(ctx context.Context) (DBManager, error) {
+	return start(ctx, false)
+}
+
+// StartJournaled is Start for the server: with database.postgres.journal.path
+// set it starts even when PostgreSQL is unreachable, buffering registry and
+// energy writes in the journal until the database answers again. Reads fail
+// while it is away.
+func StartJournaled(ctx context.Context) (DBManager, error) {
+	return start(ctx, shared.AppConfig.Database.Postgres.Journal.Path != "")
+}
+
+func start(ctx context.Context, journaled bool) (DBManager, error) {
 	dbCtx, cancel := context.WithCancel(ctx)
 	manager := &DBManager_t{
 		ctx:    dbCtx,
 		cancel: cancel,
 	}
+	cfg := shared.AppConfig.Database.Postgres
 
 	// Initialize PostgreSQL
+	online := true
 	pg, err := NewPostgresHandler(dbCtx)
+	if err != nil && journaled {
+		shared.DebugPrint("Starting without PostgreSQL (%v); journaling writes to %s", err, cfg.Journal.Path)
+		online = false
+		pg, err = openPostgres()
+	}
 	if err != nil {
 		cancel()
 		return nil, err
 	}
 	manager.postgres = pg
 
+	if cfg.AutoMigrate && online {
+		ran, err := pg.Migrate(dbCtx, cfg.MigrationsDir)
+		if err != nil {
+			pg.Close()
+			cancel()
+			return nil, fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		for _, name := range ran {
+			shared.DebugPrint("Applied migration %s", name)
+		}
+	}
+
+	if journaled {
+		j, err := openJournal(cfg.Journal.Path, cfg.Journal.MaxEntries)
+		if err != nil {
+			pg.Close()
+			cancel()
+			return nil, err
+		}
+		// Writes left by an earlier run go first, so new ones queue behind them.
+		if n := j.Pending(); n > 0 {
+			shared.DebugPrint("%d journaled writes from an earlier run wait for PostgreSQL", n)
+		}
+		if !online || j.Pending() > 0 {
+			j.SetOffline()
+		}
+		pg.journal = j
+		go pg.syncJournal(dbCtx, cfg.Journal.SyncEvery(), cfg.AutoMigrate && !online)
+	}
+
 	// Initialize Redis
 	rds, err := NewRedisHandler(dbCtx)
 	if err != nil {
@@ -0,0 +1,287 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"roboserver/shared"
+	"sync"
+	"time"
+)
+
+// ErrJournalFull is returned for a write made while PostgreSQL is offline
+// and the journal already holds database.postgres.journal.max_entries.
+var ErrJournalFull = errors.New("postgres offline and write journal full")
+
+// Journaled write operations.
+const (
+	opRegisterRobot  = "register_robot"
+	opSetRobotSchema = "set_robot_schema"
+	opBlacklistRobot = "blacklist_robot"
+	opAddEnergyUsage = "add_energy_usage"
+)
+
+// journalEntry is one buffered PostgreSQL write, stored as a JSON line.
+type journalEntry struct {
+	Op          string          `json:"op"`
+	At          int64           `json:"at"` // Unix ms when the write was made
+	UUID        string          `json:"uuid,omitempty"`
+	PublicKey   string          `json:"public_key,omitempty"`
+	DeviceType  string          `json:"device_type,omitempty"`
+	Blacklisted bool            `json:"blacklisted,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Energy      []EnergyUsage   `json:"energy,omitempty"`
+}
+
+// journal is an append-only file of writes made while PostgreSQL was
+// unreachable, replayed in order once it is back. Only one process may use
+// a journal file, so CLI commands (migrate, backup) never open it.
+type journal struct {
+	mu      sync.Mutex
+	path    string
+	max     int
+	pending int
+	offline bool
+}
+
+// openJournal opens the journal at path, counting the writes a previous run
+// left behind. The file is rewritten without unreadable lines, so a line
+// torn by a crash can't swallow the next write.
+func openJournal(path string, max int) (*journal, error) {
+	j := &journal{path: path, max: max}
+	entries, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := j.rewrite(entries); err != nil {
+		return nil, err
+	}
+	j.pending = len(entries)
+	return j, nil
+}
+
+// Offline reports whether writes currently go to the journal.
+func (j *journal) Offline() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.offline
+}
+
+// SetOffline sends further writes to the journal until the next Replay
+// empties it.
+func (j *journal) SetOffline() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.offline = true
+}
+
+// Pending returns the number of writes waiting for PostgreSQL.
+func (j *journal) Pending() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pending
+}
+
+// Append stores e and syncs the file.
+func (j *journal) Append(e journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.max > 0 && j.pending >= j.max {
+		return ErrJournalFull
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	j.pending++
+	return nil
+}
+
+// Replay passes the buffered writes to apply, oldest first, and drops those
+// it accepts. It stops at the first error, keeping that write and the ones
+// after it for the next attempt. apply runs without the lock, so writes made
+// meanwhile are appended behind the ones being replayed; once the journal is
+// empty they go to PostgreSQL again. Only one Replay may run at a time.
+func (j *journal) Replay(apply func(journalEntry) error) (int, error) {
+	j.mu.Lock()
+	entries, err := j.read()
+	j.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	var applyErr error
+	for _, e := range entries {
+		if applyErr = apply(e); applyErr != nil {
+			break
+		}
+		done++
+	}
+
+	// Appends only add to the end, so the first done entries are still the
+	// ones just applied.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	current, err := j.read()
+	if err != nil {
+		return done, err
+	}
+	if done > 0 || len(current) != j.pending {
+		if err := j.rewrite(current[done:]); err != nil {
+			return done, err
+		}
+	}
+	j.pending = len(current) - done
+	if j.pending == 0 {
+		j.offline = false
+	}
+	return done, applyErr
+}
+
+// read loads every entry. A line that doesn't decode, such as one cut short
+// by a power loss, is logged and skipped.
+func (j *journal) read() ([]journalEntry, error) {
+	data, err := os.ReadFile(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	var entries []journalEntry
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			shared.DebugPrint("Skipping unreadable journal line %d in %s: %v", n+1, j.path, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// rewrite replaces the file with entries, or removes it when there are none.
+func (j *journal) rewrite(entries []journalEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clear journal: %w", err)
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to rewrite journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("failed to rewrite journal: %w", err)
+	}
+	return nil
+}
+
+// write applies e, or journals it while PostgreSQL is unreachable. A write
+// that fails because the database went away takes the handler offline; one
+// that fails with the database up returns its error as before.
+//
+// A write whose caller gave up (ctx cancelled or past its deadline) returns
+// ctx's error and is never journaled.
+func (h *PostgresHandler) write(ctx context.Context, e journalEntry) error {
+	if h.journal == nil {
+		return h.apply(ctx, e)
+	}
+	if !h.journal.Offline() {
+		err := h.apply(ctx, e)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil || h.reachable(ctx) {
+			return err
+		}
+		shared.DebugPrint("PostgreSQL unreachable (%v); journaling writes to %s", err, h.journal.path)
+		h.journal.SetOffline()
+	}
+	e.At = time.Now().UnixMilli()
+	return h.journal.Append(e)
+}
+
+// reachable pings PostgreSQL on a context of its own, so a caller's
+// cancelled request can't make the database look down.
+func (h *PostgresHandler) reachable(ctx context.Context) bool {
+	return h.IsHealthy(context.WithoutCancel(ctx))
+}
+
+// syncJournal retries PostgreSQL every interval while writes are journaled,
+// and replays them once it answers. migrate applies pending migrations
+// first, for a server that started offline with auto_migrate on. A write
+// PostgreSQL rejects while up (a robot registered twice, say) is logged and
+// dropped so it can't hold back the rest.
+func (h *PostgresHandler) syncJournal(ctx context.Context, interval time.Duration, migrate bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !h.journal.Offline() && h.journal.Pending() == 0 {
+			continue
+		}
+		if !h.reachable(ctx) {
+			continue
+		}
+		if migrate {
+			ran, err := h.Migrate(ctx, shared.AppConfig.Database.Postgres.MigrationsDir)
+			if err != nil {
+				shared.DebugPrint("Failed to apply migrations after reconnecting: %v", err)
+				continue
+			}
+			for _, name := range ran {
+				shared.DebugPrint("Applied migration %s", name)
+			}
+			migrate = false
+		}
+		n, err := h.journal.Replay(func(e journalEntry) error {
+			err := h.apply(ctx, e)
+			if err != nil && ctx.Err() != nil {
+				return ctx.Err() // shutting down: keep it for the next run
+			}
+			if err != nil && h.reachable(ctx) {
+				shared.DebugPrint("Dropping journaled %s for %q: %v", e.Op, e.UUID, err)
+				return nil
+			}
+			return err
+		})
+		if n > 0 {
+			shared.DebugPrint("Replayed %d journaled writes to PostgreSQL", n)
+		}
+		if err != nil {
+			shared.DebugPrint("Journal replay stopped, %d writes left: %v", h.journal.Pending(), err)
+		} else if !h.journal.Offline() {
+			shared.DebugPrint("PostgreSQL is back; writes go to the database again")
+		}
+	}
+}
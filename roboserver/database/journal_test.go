@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pg.journal")
+	j, err := openJournal(path, 0)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	h := &PostgresHandler{journal: j}
+	j.SetOffline()
+
+	ctx := context.Background()
+	if err := h.RegisterRobot(ctx, "rover-1", "pk", "rover"); err != nil {
+		t.Fatalf("RegisterRobot: %v", err)
+	}
+	if err := h.BlacklistRobot(ctx, "rover-1", true); err != nil {
+		t.Fatalf("BlacklistRobot: %v", err)
+	}
+	if err := h.AddEnergyUsage(ctx, []EnergyUsage{{UUID: "rover-1", Day: "2024-06-10", ReportedWh: 12.5}}); err != nil {
+		t.Fatalf("AddEnergyUsage: %v", err)
+	}
+	if j.Pending() != 3 {
+		t.Fatalf("Expected 3 pending writes, got %d", j.Pending())
+	}
+
+	// A restart finds the writes again.
+	j, err = openJournal(path, 0)
+	if err != nil || j.Pending() != 3 {
+		t.Fatalf("Expected 3 writes after reopening, got %d (%v)", j.Pending(), err)
+	}
+	j.SetOffline()
+
+	down := errors.New("connection refused")
+	var seen []string
+	n, err := j.Replay(func(e journalEntry) error {
+		if e.Op == opBlacklistRobot {
+			return down
+		}
+		seen = append(seen, e.Op)
+		return nil
+	})
+	if n != 1 || !errors.Is(err, down) || j.Pending() != 2 || !j.Offline() {
+		t.Fatalf("Expected replay to stop at the second write, got n=%d err=%v pending=%d", n, err, j.Pending())
+	}
+
+	n, err = j.Replay(func(e journalEntry) error {
+		seen = append(seen, e.Op)
+		if e.Op == opAddEnergyUsage && (len(e.Energy) != 1 || e.Energy[0].ReportedWh != 12.5) {
+			t.Errorf("Energy not kept: %+v", e.Energy)
+		}
+		if e.At == 0 {
+			t.Errorf("Expected %s to carry its time", e.Op)
+		}
+		return nil
+	})
+	if n != 2 || err != nil || j.Pending() != 0 || j.Offline() {
+		t.Fatalf("Expected the rest replayed and the journal online, got n=%d err=%v pending=%d", n, err, j.Pending())
+	}
+	want := []string{opRegisterRobot, opBlacklistRobot, opAddEnergyUsage}
+	for i, op := range want {
+		if i >= len(seen) || seen[i] != op {
+			t.Fatalf("Expected writes in order %v, got %v", want, seen)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected an empty journal to be removed, got %v", err)
+	}
+}
+
+func TestJournalFullAndTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pg.journal")
+	if err := os.WriteFile(path, []byte(`{"op":"blacklist_robot","uuid":"a","blacklisted":true}`+"\n"+`{"op":"regis`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := openJournal(path, 2)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	if j.Pending() != 1 {
+		t.Fatalf("Expected the torn line skipped, got %d pending", j.Pending())
+	}
+	if err := j.Append(journalEntry{Op: opBlacklistRobot, UUID: "b"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := j.Append(journalEntry{Op: opBlacklistRobot, UUID: "c"}); !errors.Is(err, ErrJournalFull) {
+		t.Errorf("Expected ErrJournalFull, got %v", err)
+	}
+	if j, err = openJournal(path, 2); err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	if j.Pending() != 2 {
+		t.Errorf("Expected the write after the torn line kept, got %d pending", j.Pending())
+	}
+}
+
+func TestJournalSkipsCancelledWrites(t *testing.T) {
+	j, err := openJournal(filepath.Join(t.TempDir(), "pg.journal"), 0)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	db, err := sql.Open("postgres", "postgres://nobody@127.0.0.1:1/none?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	h := &PostgresHandler{DB: db, journal: j}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.BlacklistRobot(ctx, "rover-1", true); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if j.Offline() || j.Pending() != 0 {
+		t.Errorf("Expected a cancelled write neither journaled nor taking the handler offline, got offline=%v pending=%d", j.Offline(), j.Pending())
+	}
+}
+
+func TestJournalAppendDuringReplay(t *testing.T) {
+	j, err := openJournal(filepath.Join(t.TempDir(), "pg.journal"), 0)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	j.SetOffline()
+	for _, uuid := range []string{"a", "b"} {
+		if err := j.Append(journalEntry{Op: opBlacklistRobot, UUID: uuid}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	n, err := j.Replay(func(e journalEntry) error {
+		if e.UUID == "a" {
+			// A write arriving mid-replay must not wait for it, nor be lost.
+			if err := j.Append(journalEntry{Op: opBlacklistRobot, UUID: "c"}); err != nil {
+				t.Errorf("Append during replay: %v", err)
+			}
+		}
+		return nil
+	})
+	if n != 2 || err != nil {
+		t.Fatalf("Expected 2 replayed, got %d (%v)", n, err)
+	}
+	if j.Pending() != 1 || !j.Offline() {
+		t.Fatalf("Expected the write made during replay kept, got pending=%d offline=%v", j.Pending(), j.Offline())
+	}
+	var left []string
+	j.Replay(func(e journalEntry) error {
+		left = append(left, e.UUID)
+		return nil
+	})
+	if len(left) != 1 || left[0] != "c" || j.Offline() {
+		t.Errorf("Expected c replayed last, got %v", left)
+	}
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Migrations ---
+//
+// Migrations are the dbmate-format files in db/migrations ("NNN_name.sql" with
+// "-- migrate:up" / "-- migrate:down" sections). Applied versions are recorded
+// in dbmate's schema_migrations table, so the built-in runner and the dbmate
+// CLI can be used interchangeably against the same database.
+
+const (
+	migrateUpMarker   = "-- migrate:up"
+	migrateDownMarker = "-- migrate:down"
+
+	// migrationLockID is the pg_advisory_lock key held while migrating, so
+	// several server instances starting at once don't race each other.
+	migrationLockID = 727_001
+)
+
+type Migration struct {
+	Version string // numeric filename prefix, e.g. "002"
+	Name    string // filename
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// LoadMigrations reads and parses all migrations in dir, ordered by version.
+func LoadMigrations(dir string) ([]Migration, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]string)
+	migrations := make([]Migration, 0, len(paths))
+	for _, path := range paths {
+		name := filepath.Base(path)
+		version, _, ok := strings.Cut(name, "_")
+		if !ok || version == "" || strings.Trim(version, "0123456789") != "" {
+			return nil, fmt.Errorf("migration %s: filename must start with a numeric version and '_'", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %s", other, name, version)
+		}
+		seen[version] = name
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := parseMigration(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, Up: up, Down: down})
+	}
+
+	// dbmate orders by version string; zero-padded versions sort the same
+	// numerically, and timestamp versions (dbmate's default) have equal length.
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseMigration splits a dbmate file into its up and down sections.
+func parseMigration(raw string) (up, down string, err error) {
+	upIdx := strings.Index(raw, migrateUpMarker)
+	if upIdx < 0 {
+		return "", "", fmt.Errorf("missing %q section", migrateUpMarker)
+	}
+	body := raw[upIdx+len(migrateUpMarker):]
+	up, down, _ = strings.Cut(body, migrateDownMarker)
+	up = strings.TrimSpace(up)
+	if up == "" {
+		return "", "", fmt.Errorf("empty %q section", migrateUpMarker)
+	}
+	return up, strings.TrimSpace(down), nil
+}
+
+func (h *PostgresHandler) ensureMigrationsTable(ctx context.Context) error {
+	_, err := h.DB.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version VARCHAR(128) PRIMARY KEY)`)
+	return err
+}
+
+func (h *PostgresHandler) appliedVersions(ctx context.Context) (map[string]bool, error) {
+	rows, err := h.DB.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatuses reports which migrations in dir have been applied.
+func (h *PostgresHandler) MigrationStatuses(ctx context.Context, dir string) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.ensureMigrationsTable(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := h.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name, Applied: applied[m.Version]}
+	}
+	return statuses, nil
+}
+
+// Migrate applies pending migrations from dir in order, each in its own
+// transaction, and returns the names of those applied. It stops at the first
+// failure; earlier migrations stay applied.
+func (h *PostgresHandler) Migrate(ctx context.Context, dir string) ([]string, error) {
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := h.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	// Read applied versions only after taking the lock, so a concurrent
+	// migrator's work is visible.
+	if err := h.ensureMigrationsTable(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := h.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []string
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return ran, err
+		}
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			tx.Rollback()
+			return ran, fmt.Errorf("migration %s failed: %w", m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version); err != nil {
+			tx.Rollback()
+			return ran, fmt.Errorf("migration %s: failed to record version: %w", m.Name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return ran, fmt.Errorf("migration %s: commit failed: %w", m.Name, err)
+		}
+		ran = append(ran, m.Name)
+	}
+	return ran, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMigrationsFromRepo(t *testing.T) {
+	migrations, err := LoadMigrations("../../db/migrations")
+	if err != nil {
+		t.Fatalf("Failed to load repo migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected at least one migration in db/migrations")
+	}
+	if migrations[0].Version != "001" {
+		t.Errorf("Expected first version 001, got %s", migrations[0].Version)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i-1].Version >= migrations[i].Version {
+			t.Errorf("Migrations out of order: %s before %s", migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+}
+
+func TestLoadMigrationsOrderAndSections(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "002_second.sql"), []byte("-- migrate:up\nSELECT 2;\n-- migrate:down\nSELECT -2;\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "001_first.sql"), []byte("-- comment\n-- migrate:up\nSELECT 1;\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a migration"), 0o644)
+
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		t.Fatalf("LoadMigrations failed: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Name != "001_first.sql" || migrations[1].Name != "002_second.sql" {
+		t.Errorf("Unexpected order: %s, %s", migrations[0].Name, migrations[1].Name)
+	}
+	if migrations[0].Up != "SELECT 1;" || migrations[0].Down != "" {
+		t.Errorf("Unexpected sections for 001: up=%q down=%q", migrations[0].Up, migrations[0].Down)
+	}
+	if migrations[1].Up != "SELECT 2;" || migrations[1].Down != "SELECT -2;" {
+		t.Errorf("Unexpected sections for 002: up=%q down=%q", migrations[1].Up, migrations[1].Down)
+	}
+}
+
+func TestLoadMigrationsRejectsBadFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"non-numeric version", map[string]string{"abc_init.sql": "-- migrate:up\nSELECT 1;"}},
+		{"missing underscore", map[string]string{"001.sql": "-- migrate:up\nSELECT 1;"}},
+		{"duplicate version", map[string]string{
+			"001_a.sql": "-- migrate:up\nSELECT 1;",
+			"001_b.sql": "-- migrate:up\nSELECT 1;",
+		}},
+		{"missing up section", map[string]string{"001_a.sql": "SELECT 1;"}},
+		{"empty up section", map[string]string{"001_a.sql": "-- migrate:up\n-- migrate:down\nSELECT 1;"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, body := range tt.files {
+				os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644)
+			}
+			if _, err := LoadMigrations(dir); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"roboserver/shared"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ApplicationName is the application_name of the server's own PostgreSQL
+// connections. The robots change trigger reports it, so WatchRobotChanges
+// can leave out writes the server has already announced.
+const ApplicationName = "roboserver"
+
+// RobotChangesChannel is the NOTIFY channel of the robots change trigger
+// (db/migrations/004_robot_change_notify.sql).
+const RobotChangesChannel = "robot_changes"
+
+// RobotChange is one row change announced by the robots trigger.
+type RobotChange struct {
+	UUID string `json:"uuid"`
+	// Change is "registered", "blacklist" (is_blacklisted flipped),
+	// "updated" or "deleted".
+	Change      string `json:"change"`
+	Blacklisted bool   `json:"blacklisted"`
+	// Application is the application_name of the connection that made the
+	// change.
+	Application string `json:"application"`
+}
+
+// parseRobotChange decodes a robot_changes notification payload.
+func parseRobotChange(payload string) (RobotChange, error) {
+	var c RobotChange
+	if err := json.Unmarshal([]byte(payload), &c); err != nil {
+		return c, err
+	}
+	if c.UUID == "" || c.Change == "" {
+		return c, fmt.Errorf("incomplete robot change %q", payload)
+	}
+	return c, nil
+}
+
+// WatchRobotChanges listens on RobotChangesChannel on a dedicated connection
+// and calls fn for every change made by another application, until ctx is
+// cancelled. The connection is re-established on failure; changes made
+// while it was down are not replayed. fn runs on the listener's goroutine.
+// A server that started without PostgreSQL (see StartJournaled) starts
+// listening once the database answers.
+func WatchRobotChanges(ctx context.Context, fn func(RobotChange)) error {
+	listener := pq.NewListener(postgresDSN(), time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			shared.DebugPrint("Robot change listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			shared.DebugPrint("Robot change listener reconnected; changes made meanwhile were missed")
+		case pq.ListenerEventConnectionAttemptFailed:
+			shared.DebugPrint("Robot change listener failed to connect: %v", err)
+		}
+	})
+	// Listen blocks until the listener has a connection; closing it on
+	// shutdown releases a Listen still waiting for one.
+	listening := make(chan error, 1)
+	go func() {
+		listening <- listener.Listen(RobotChangesChannel)
+	}()
+	select {
+	case err := <-listening:
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on %s: %w", RobotChangesChannel, err)
+		}
+	case <-time.After(10 * time.Second):
+		shared.DebugPrint("PostgreSQL unreachable; robot change listener will start when it answers")
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-listening:
+				if err != nil {
+					shared.DebugPrint("Failed to listen on %s: %v", RobotChangesChannel, err)
+					return
+				}
+			case n := <-listener.Notify:
+				if n == nil {
+					continue // reconnected
+				}
+				change, err := parseRobotChange(n.Extra)
+				if err != nil {
+					shared.DebugPrint("Ignoring robot change notification: %v", err)
+					continue
+				}
+				if change.Application == ApplicationName {
+					continue
+				}
+				fn(change)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,19 @@
+package database
+
+import "testing"
+
+func TestParseRobotChange(t *testing.T) {
+	c, err := parseRobotChange(`{"uuid":"r1","change":"blacklist","blacklisted":true,"application":"psql"}`)
+	if err != nil {
+		t.Fatalf("parseRobotChange failed: %v", err)
+	}
+	if c.UUID != "r1" || c.Change != "blacklist" || !c.Blacklisted || c.Application != "psql" {
+		t.Errorf("Unexpected change: %+v", c)
+	}
+
+	for _, payload := range []string{`not json`, `{"uuid":"r1"}`, `{"change":"deleted"}`} {
+		if _, err := parseRobotChange(payload); err == nil {
+			t.Errorf("Expected %q to be rejected", payload)
+		}
+	}
+}
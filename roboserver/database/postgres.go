@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"roboserver/shared"
+	"roboserver/shared/tracing"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -12,34 +13,61 @@ import (
 
 type PostgresHandler struct {
 	DB *sql.DB
+
+	// journal, when set, takes the registry and energy writes made while
+	// PostgreSQL is unreachable (see StartJournaled).
+	journal *journal
 }
 
 func NewPostgresHandler(ctx context.Context) (*PostgresHandler, error) {
+	h, err := openPostgres()
+	if err != nil {
+		return nil, err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := h.DB.PingContext(pingCtx); err != nil {
+		h.DB.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
+	}
+
+	shared.DebugPrint("Successfully connected to PostgreSQL database: %s", shared.AppConfig.Database.Postgres.Database)
+	return h, nil
+}
+
+// openPostgres sets up the connection pool without connecting.
+func openPostgres() (*PostgresHandler, error) {
 	cfg := shared.AppConfig.Database.Postgres
-	dsn := cfg.DSN()
+	dsn := postgresDSN()
 
 	shared.DebugPrint("Connecting to PostgreSQL at %s:%d", cfg.Host, cfg.Port)
 
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+	var db *sql.DB
+	if tracing.Enabled() {
+		connector, err := newTracedConnector(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+		}
+		db = sql.OpenDB(connector)
+	} else {
+		var err error
+		db, err = sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+		}
 	}
 
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
 	db.SetConnMaxLifetime(cfg.ConnLifetime())
-
-	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	if err := db.PingContext(pingCtx); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping postgres: %w", err)
-	}
-
-	shared.DebugPrint("Successfully connected to PostgreSQL database: %s", cfg.Database)
 	return &PostgresHandler{DB: db}, nil
 }
 
+// postgresDSN is the configured DSN tagged with ApplicationName.
+func postgresDSN() string {
+	return shared.AppConfig.Database.Postgres.DSN() + "&application_name=" + ApplicationName
+}
+
 func (h *PostgresHandler) Close() {
 	if h.DB != nil {
 		h.DB.Close()
@@ -55,6 +83,36 @@ func (h *PostgresHandler) IsHealthy(ctx context.Context) bool {
 	return h.DB.PingContext(pingCtx) == nil
 }
 
+// apply runs a registry or energy write against PostgreSQL.
+func (h *PostgresHandler) apply(ctx context.Context, e journalEntry) error {
+	switch e.Op {
+	case opRegisterRobot:
+		_, err := h.DB.ExecContext(ctx,
+			`INSERT INTO robots (uuid, public_key, device_type) VALUES ($1, $2, $3)`,
+			e.UUID, e.PublicKey, e.DeviceType)
+		return err
+	case opSetRobotSchema:
+		res, err := h.DB.ExecContext(ctx,
+			`UPDATE robots SET command_schema = $1 WHERE uuid = $2`,
+			[]byte(e.Schema), e.UUID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	case opBlacklistRobot:
+		_, err := h.DB.ExecContext(ctx,
+			`UPDATE robots SET is_blacklisted = $1 WHERE uuid = $2`,
+			e.Blacklisted, e.UUID)
+		return err
+	case opAddEnergyUsage:
+		return h.addEnergyUsage(ctx, e.Energy)
+	}
+	return fmt.Errorf("unknown write %q", e.Op)
+}
+
 // --- Robot Registry Queries ---
 
 type RobotRecord struct {
@@ -78,17 +136,41 @@ func (h *PostgresHandler) GetRobotByUUID(ctx context.Context, uuid string) (*Rob
 }
 
 func (h *PostgresHandler) RegisterRobot(ctx context.Context, uuid, publicKey, deviceType string) error {
+	return h.write(ctx, journalEntry{Op: opRegisterRobot, UUID: uuid, PublicKey: publicKey, DeviceType: deviceType})
+}
+
+// RestoreRobot inserts a robot or overwrites an existing one with the same
+// uuid, blacklist flag and command schema included. Used by backup restore.
+func (h *PostgresHandler) RestoreRobot(ctx context.Context, r *RobotRecord, schema []byte) error {
 	_, err := h.DB.ExecContext(ctx,
-		`INSERT INTO robots (uuid, public_key, device_type) VALUES ($1, $2, $3)`,
-		uuid, publicKey, deviceType)
+		`INSERT INTO robots (uuid, public_key, device_type, is_blacklisted, command_schema, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (uuid) DO UPDATE SET public_key = EXCLUDED.public_key, device_type = EXCLUDED.device_type,
+		   is_blacklisted = EXCLUDED.is_blacklisted, command_schema = EXCLUDED.command_schema`,
+		r.UUID, r.PublicKey, r.DeviceType, r.IsBlacklisted, schema, r.CreatedAt)
 	return err
 }
 
+// SetRobotSchema stores a generic_actuator command schema (raw JSON, already
+// validated by the caller). Returns sql.ErrNoRows if the robot isn't
+// registered, unless the write was journaled.
+func (h *PostgresHandler) SetRobotSchema(ctx context.Context, uuid string, schema []byte) error {
+	return h.write(ctx, journalEntry{Op: opSetRobotSchema, UUID: uuid, Schema: schema})
+}
+
+// GetRobotSchema returns a robot's command schema, or nil if none was uploaded.
+func (h *PostgresHandler) GetRobotSchema(ctx context.Context, uuid string) ([]byte, error) {
+	var schema []byte
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT command_schema FROM robots WHERE uuid = $1`, uuid).Scan(&schema)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
 func (h *PostgresHandler) BlacklistRobot(ctx context.Context, uuid string, blacklisted bool) error {
-	_, err := h.DB.ExecContext(ctx,
-		`UPDATE robots SET is_blacklisted = $1 WHERE uuid = $2`,
-		blacklisted, uuid)
-	return err
+	return h.write(ctx, journalEntry{Op: opBlacklistRobot, UUID: uuid, Blacklisted: blacklisted})
 }
 
 func (h *PostgresHandler) GetRobotsByType(ctx context.Context, deviceType string) ([]*RobotRecord, error) {
@@ -128,3 +210,86 @@ func (h *PostgresHandler) GetAllRobots(ctx context.Context) ([]*RobotRecord, err
 	}
 	return robots, rows.Err()
 }
+
+// EachRobot calls fn for every registered robot, oldest first, or only
+// those of deviceType when it is not "". Rows are read as fn consumes them,
+// so the registry is never held in memory. An error from fn stops the scan
+// and is returned.
+func (h *PostgresHandler) EachRobot(ctx context.Context, deviceType string, fn func(*RobotRecord) error) error {
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT uuid, public_key, device_type, is_blacklisted, created_at FROM robots
+		 WHERE $1 = '' OR device_type = $1 ORDER BY created_at`, deviceType)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &RobotRecord{}
+		if err := rows.Scan(&r.UUID, &r.PublicKey, &r.DeviceType, &r.IsBlacklisted, &r.CreatedAt); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EnergyUsage is a robot's energy use on one UTC day, in watt-hours.
+type EnergyUsage struct {
+	UUID        string  `json:"uuid"`
+	Day         string  `json:"day"` // YYYY-MM-DD
+	DeviceType  string  `json:"device_type"`
+	ReportedWh  float64 `json:"reported_wh"`  // integrated from power telemetry
+	EstimatedWh float64 `json:"estimated_wh"` // energy.estimate_watts × time online
+}
+
+// AddEnergyUsage adds each entry to the robot's total for its day.
+func (h *PostgresHandler) AddEnergyUsage(ctx context.Context, usage []EnergyUsage) error {
+	return h.write(ctx, journalEntry{Op: opAddEnergyUsage, Energy: usage})
+}
+
+func (h *PostgresHandler) addEnergyUsage(ctx context.Context, usage []EnergyUsage) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, u := range usage {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO robot_energy (uuid, day, device_type, reported_wh, estimated_wh)
+			 VALUES ($1, $2, $3, $4, $5)
+			 ON CONFLICT (uuid, day) DO UPDATE SET
+			   device_type = EXCLUDED.device_type,
+			   reported_wh = robot_energy.reported_wh + EXCLUDED.reported_wh,
+			   estimated_wh = robot_energy.estimated_wh + EXCLUDED.estimated_wh`,
+			u.UUID, u.Day, u.DeviceType, u.ReportedWh, u.EstimatedWh); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetEnergyUsage returns daily usage from day since (YYYY-MM-DD) on, oldest
+// first, for one robot or every robot (uuid "").
+func (h *PostgresHandler) GetEnergyUsage(ctx context.Context, uuid, since string) ([]EnergyUsage, error) {
+	rows, err := h.DB.QueryContext(ctx,
+		`SELECT uuid, to_char(day, 'YYYY-MM-DD'), device_type, reported_wh, estimated_wh
+		 FROM robot_energy WHERE day >= $1 AND ($2 = '' OR uuid = $2)
+		 ORDER BY day, uuid`, since, uuid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []EnergyUsage
+	for rows.Next() {
+		var u EnergyUsage
+		if err := rows.Scan(&u.UUID, &u.Day, &u.DeviceType, &u.ReportedWh, &u.EstimatedWh); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
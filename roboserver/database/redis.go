@@ -5,6 +5,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"roboserver/shared"
+	"roboserver/shared/autoaccept"
+	"roboserver/shared/macro"
+	"roboserver/shared/maintenance"
+	"roboserver/shared/notify"
+	"roboserver/shared/registrations"
+	"roboserver/shared/shadow"
+	"roboserver/shared/telemetry"
+	"roboserver/shared/timesync"
+	"roboserver/shared/tokencrypt"
+	"roboserver/shared/tracing"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -12,6 +26,10 @@ import (
 
 type RedisHandler struct {
 	Client *redis.Client
+
+	// activeVersion changes whenever this node writes or removes an active
+	// session, so callers can cache the active robot list.
+	activeVersion atomic.Uint64
 }
 
 func NewRedisHandler(ctx context.Context) (*RedisHandler, error) {
@@ -24,6 +42,9 @@ func NewRedisHandler(ctx context.Context) (*RedisHandler, error) {
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
+	if tracing.Enabled() {
+		client.AddHook(redisTracing{})
+	}
 
 	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -56,41 +77,204 @@ func (h *RedisHandler) IsHealthy(ctx context.Context) bool {
 // ActiveRobot represents the ephemeral state of a connected robot in Redis.
 type ActiveRobot struct {
 	UUID       string `json:"uuid"`
-	IP         string `json:"ip"`
+	IP         string `json:"ip"` // most recent address
+	// IPs lists every address seen this session (wifi and ethernet, IPv4
+	// and IPv6), most recent first; at most MaxRobotIPs.
+	IPs        []string `json:"ips,omitempty"`
 	DeviceType string `json:"device_type"`
 	SessionJWT string `json:"session_jwt"`
 	PID        int    `json:"pid,omitempty"`
 	ConnectedAt int64 `json:"connected_at"`
+	Node       string `json:"node,omitempty"` // cluster node holding the connection
+	// Gateway is the API key ID of the gateway that reports for a virtual
+	// robot created by POST /ingest; empty for robots with their own session.
+	Gateway    string `json:"gateway,omitempty"`
+
+	// Maintenance is filled in for API responses from maintenance.Registry;
+	// it is never stored with the session.
+	Maintenance *maintenance.Info `json:"maintenance,omitempty"`
+}
+
+// MaxRobotIPs bounds the addresses remembered for one robot.
+const MaxRobotIPs = 4
+
+// AddIP records ip as the robot's most recent address.
+func (r *ActiveRobot) AddIP(ip string) {
+	r.IPs = shared.AddIP(r.Addresses(), ip, MaxRobotIPs)
+	if len(r.IPs) > 0 {
+		r.IP = r.IPs[0]
+	}
+}
+
+// Addresses returns the robot's known addresses, most recent first.
+// Sessions stored before IPs existed list only IP.
+func (r *ActiveRobot) Addresses() []string {
+	return addresses(r.IPs, r.IP)
+}
+
+func addresses(ips []string, ip string) []string {
+	if len(ips) > 0 {
+		return ips
+	}
+	if ip != "" {
+		return []string{shared.CanonicalIP(ip)}
+	}
+	return nil
 }
 
 func robotKey(uuid string) string {
 	return fmt.Sprintf("robot:%s:active", uuid)
 }
 
-// SetActiveRobot stores a robot's active session in Redis with TTL.
+// SetActiveRobot stores a robot's active session in Redis with TTL. The
+// session JWT is sealed with shared/tokencrypt when a key is configured.
 func (h *RedisHandler) SetActiveRobot(ctx context.Context, robot *ActiveRobot, ttl time.Duration) error {
-	data, err := json.Marshal(robot)
+	data, err := marshalActiveRobot(robot)
 	if err != nil {
-		return fmt.Errorf("failed to marshal active robot: %w", err)
+		return err
 	}
+	defer h.activeVersion.Add(1)
 	return h.Client.Set(ctx, robotKey(robot.UUID), data, ttl).Err()
 }
 
-// GetActiveRobot retrieves a robot's active session from Redis.
+// AddActiveRobot stores a robot's active session like SetActiveRobot, but
+// only if it has none. It reports false, storing nothing, when another
+// session got there first.
+func (h *RedisHandler) AddActiveRobot(ctx context.Context, robot *ActiveRobot, ttl time.Duration) (bool, error) {
+	data, err := marshalActiveRobot(robot)
+	if err != nil {
+		return false, err
+	}
+	ok, err := h.Client.SetNX(ctx, robotKey(robot.UUID), data, ttl).Result()
+	if ok {
+		h.activeVersion.Add(1)
+	}
+	return ok, err
+}
+
+// activeRobotRetries bounds how often UpdateActiveRobot retries after a
+// concurrent write to the same session.
+const activeRobotRetries = 8
+
+// UpdateActiveRobot applies update to a robot's active session and stores
+// it with ttl, atomically against concurrent writers (WATCH). It returns
+// redis.Nil when the robot has no session; an error from update aborts
+// without writing.
+func (h *RedisHandler) UpdateActiveRobot(ctx context.Context, uuid string, ttl time.Duration, update func(*ActiveRobot) error) error {
+	key := robotKey(uuid)
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			return err
+		}
+		robot, _, err := h.openActiveRobot(ctx, key, data)
+		if err != nil {
+			return err
+		}
+		if err := update(robot); err != nil {
+			return err
+		}
+		data, err = marshalActiveRobot(robot)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.Set(ctx, key, data, ttl).Err()
+		})
+		return err
+	}
+	for range activeRobotRetries {
+		err := h.Client.Watch(ctx, txf, key)
+		if err != redis.TxFailedErr {
+			if err == nil {
+				h.activeVersion.Add(1)
+			}
+			return err
+		}
+	}
+	return fmt.Errorf("session of %s is changing too fast, try again", uuid)
+}
+
+// ActiveRobotsVersion changes whenever this node stores or removes an
+// active session. Changes made by other cluster nodes, and sessions expiring
+// by TTL, don't bump it.
+func (h *RedisHandler) ActiveRobotsVersion() uint64 {
+	return h.activeVersion.Load()
+}
+
+// GetActiveRobot retrieves a robot's active session from Redis. A session
+// stored in plaintext or under an old key is re-sealed in place.
 func (h *RedisHandler) GetActiveRobot(ctx context.Context, uuid string) (*ActiveRobot, error) {
-	data, err := h.Client.Get(ctx, robotKey(uuid)).Bytes()
+	r, _, err := h.loadActiveRobot(ctx, robotKey(uuid))
+	return r, err
+}
+
+func marshalActiveRobot(robot *ActiveRobot) ([]byte, error) {
+	stored := *robot
+	stored.Maintenance = nil
+	if stored.Node == "" {
+		stored.Node = shared.NodeID()
+	}
+	sealed, err := tokencrypt.Seal(robot.SessionJWT)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to encrypt session token: %w", err)
+	}
+	stored.SessionJWT = sealed
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal active robot: %w", err)
+	}
+	return data, nil
+}
+
+// loadActiveRobot reads and decrypts an active session, rewriting it
+// (keeping its TTL) when tokencrypt reports the stored token as stale.
+// resealed reports whether that rewrite happened.
+func (h *RedisHandler) loadActiveRobot(ctx context.Context, key string) (r *ActiveRobot, resealed bool, err error) {
+	data, err := h.Client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, false, err
 	}
-	r := &ActiveRobot{}
+	return h.openActiveRobot(ctx, key, data)
+}
+
+// openActiveRobot decodes a stored session read from key, re-sealing it as
+// loadActiveRobot does.
+func (h *RedisHandler) openActiveRobot(ctx context.Context, key string, data []byte) (r *ActiveRobot, resealed bool, err error) {
+	r = &ActiveRobot{}
 	if err := json.Unmarshal(data, r); err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return r, nil
+	jwt, stale, err := tokencrypt.Open(r.SessionJWT)
+	if err != nil {
+		return nil, false, fmt.Errorf("session token for %s: %w", r.UUID, err)
+	}
+	r.SessionJWT = jwt
+	if stale {
+		if data, err := marshalActiveRobot(r); err == nil {
+			resealed = h.Client.SetArgs(ctx, key, data, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err() == nil
+		}
+	}
+	return r, resealed, nil
+}
+
+// ResealActiveRobots re-encrypts every stored session token under the
+// current key, for use after rotating TOKEN_ENCRYPTION_KEY. It returns how
+// many sessions were rewritten.
+func (h *RedisHandler) ResealActiveRobots(ctx context.Context) (int, error) {
+	count := 0
+	iter := h.Client.Scan(ctx, 0, "robot:*:active", 100).Iterator()
+	for iter.Next(ctx) {
+		if _, resealed, err := h.loadActiveRobot(ctx, iter.Val()); err == nil && resealed {
+			count++
+		}
+	}
+	return count, iter.Err()
 }
 
 // RemoveActiveRobot deletes a robot's active session from Redis.
 func (h *RedisHandler) RemoveActiveRobot(ctx context.Context, uuid string) error {
+	defer h.activeVersion.Add(1)
 	return h.Client.Del(ctx, robotKey(uuid)).Err()
 }
 
@@ -100,25 +284,42 @@ func (h *RedisHandler) IsRobotActive(ctx context.Context, uuid string) (bool, er
 	return n > 0, err
 }
 
-// GetAllActiveRobots returns all robots with active sessions.
+// activeRobotsBatch is how many sessions GetAllActiveRobots reads per
+// SCAN + MGET round trip.
+const activeRobotsBatch = 500
+
+// GetAllActiveRobots returns all robots with active sessions. Sessions are
+// fetched a SCAN page at a time with MGET rather than one GET per robot.
 func (h *RedisHandler) GetAllActiveRobots(ctx context.Context) ([]*ActiveRobot, error) {
 	var robots []*ActiveRobot
-	iter := h.Client.Scan(ctx, 0, "robot:*:active", 100).Iterator()
-	for iter.Next(ctx) {
-		data, err := h.Client.Get(ctx, iter.Val()).Bytes()
+	var cursor uint64
+	for {
+		keys, next, err := h.Client.Scan(ctx, cursor, "robot:*:active", activeRobotsBatch).Result()
 		if err != nil {
-			continue
+			return nil, err
 		}
-		r := &ActiveRobot{}
-		if err := json.Unmarshal(data, r); err != nil {
-			continue
+		if len(keys) > 0 {
+			values, err := h.Client.MGet(ctx, keys...).Result()
+			if err != nil {
+				return nil, err
+			}
+			for i, v := range values {
+				data, ok := v.(string)
+				if !ok {
+					continue // expired between SCAN and MGET
+				}
+				r, _, err := h.openActiveRobot(ctx, keys[i], []byte(data))
+				if err != nil {
+					continue
+				}
+				robots = append(robots, r)
+			}
 		}
-		robots = append(robots, r)
-	}
-	if err := iter.Err(); err != nil {
-		return nil, err
+		if next == 0 {
+			return robots, nil
+		}
+		cursor = next
 	}
-	return robots, nil
 }
 
 // --- Robot Public Key Storage (for PERSIST flow) ---
@@ -225,14 +426,288 @@ func (h *RedisHandler) GetAllPendingRobots(ctx context.Context) ([]*PendingRobot
 	return robots, nil
 }
 
+// --- Failed Registrations ---
+
+// RegistrationFailuresKey is a list of JSON registrations.Failure, newest
+// first, capped at registrations.MaxFailures.
+const RegistrationFailuresKey = "registration_failures"
+
+// AddRegistrationFailure records a failed registration attempt.
+func (h *RedisHandler) AddRegistrationFailure(ctx context.Context, f registrations.Failure) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	pipe := h.Client.TxPipeline()
+	pipe.LPush(ctx, RegistrationFailuresKey, data)
+	pipe.LTrim(ctx, RegistrationFailuresKey, 0, registrations.MaxFailures-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetRegistrationFailures returns up to limit failed attempts, newest first
+// (limit <= 0 = all kept).
+func (h *RedisHandler) GetRegistrationFailures(ctx context.Context, limit int) ([]registrations.Failure, error) {
+	stop := int64(limit) - 1
+	if limit <= 0 {
+		stop = -1
+	}
+	entries, err := h.Client.LRange(ctx, RegistrationFailuresKey, 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]registrations.Failure, 0, len(entries))
+	for _, data := range entries {
+		var f registrations.Failure
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// --- Pairing Codes ---
+
+// PairingCode pre-approves one robot registration. A robot that presents
+// it in "REGISTER <code>" is accepted without waiting for an operator.
+type PairingCode struct {
+	Code       string `json:"code"`
+	DeviceType string `json:"device_type,omitempty"` // only robots of this type, if set
+	CreatedBy  string `json:"created_by"`
+	ExpiresAt  int64  `json:"expires_at"`
+	// Onboard is set on codes from POST /robot/onboard: the robot that
+	// redeems the code is set up with it as it registers.
+	Onboard *OnboardPlan `json:"onboard,omitempty"`
+}
+
+// OnboardPlan is how an onboarded robot is set up: its labels, and desired
+// state merged into its shadow so the handler receives it on connect.
+type OnboardPlan struct {
+	Name   string         `json:"name"`
+	Zone   string         `json:"zone,omitempty"`
+	Tags   []string       `json:"tags,omitempty"`
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// OnboardClaim records which robot redeemed an onboarding code, so the
+// wizard that created it can tell the setup finished.
+type OnboardClaim struct {
+	Code       string `json:"code"`
+	UUID       string `json:"uuid"`
+	DeviceType string `json:"device_type"`
+	Name       string `json:"name"`
+	ClaimedAt  int64  `json:"claimed_at"`
+	// Error is set when the robot registered but part of its setup failed.
+	Error string `json:"error,omitempty"`
+}
+
+func pairingCodeKey(code string) string {
+	return fmt.Sprintf("pairing:%s", code)
+}
+
+// SetPairingCode stores a new pairing code until it expires. It fails if
+// the code already exists.
+func (h *RedisHandler) SetPairingCode(ctx context.Context, pc *PairingCode, ttl time.Duration) error {
+	data, err := json.Marshal(pc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pairing code: %w", err)
+	}
+	ok, err := h.Client.SetNX(ctx, pairingCodeKey(pc.Code), data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("pairing code already exists")
+	}
+	return nil
+}
+
+// GetPairingCode returns an unused pairing code, or nil for an unknown or
+// expired one.
+func (h *RedisHandler) GetPairingCode(ctx context.Context, code string) (*PairingCode, error) {
+	data, err := h.Client.Get(ctx, pairingCodeKey(code)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pc := &PairingCode{}
+	if err := json.Unmarshal(data, pc); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// ConsumePairingCode reads and deletes a pairing code atomically, so each
+// code admits at most one robot. Returns redis.Nil for an unknown or
+// expired code.
+func (h *RedisHandler) ConsumePairingCode(ctx context.Context, code string) (*PairingCode, error) {
+	data, err := h.Client.GetDel(ctx, pairingCodeKey(code)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	pc := &PairingCode{}
+	if err := json.Unmarshal(data, pc); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// ListPairingCodes returns unused, unexpired pairing codes, soonest to
+// expire first.
+func (h *RedisHandler) ListPairingCodes(ctx context.Context) ([]*PairingCode, error) {
+	var codes []*PairingCode
+	iter := h.Client.Scan(ctx, 0, "pairing:*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := h.Client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		pc := &PairingCode{}
+		if err := json.Unmarshal(data, pc); err != nil {
+			continue
+		}
+		codes = append(codes, pc)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].ExpiresAt < codes[j].ExpiresAt })
+	return codes, nil
+}
+
+// DeletePairingCode revokes an unused pairing code. Returns false if it
+// didn't exist.
+func (h *RedisHandler) DeletePairingCode(ctx context.Context, code string) (bool, error) {
+	n, err := h.Client.Del(ctx, pairingCodeKey(code)).Result()
+	return n > 0, err
+}
+
+func onboardClaimKey(code string) string {
+	return fmt.Sprintf("onboarded:%s", code)
+}
+
+// SetOnboardClaim records the robot that redeemed an onboarding code.
+func (h *RedisHandler) SetOnboardClaim(ctx context.Context, claim *OnboardClaim, ttl time.Duration) error {
+	data, err := json.Marshal(claim)
+	if err != nil {
+		return fmt.Errorf("failed to marshal onboarding claim: %w", err)
+	}
+	return h.Client.Set(ctx, onboardClaimKey(claim.Code), data, ttl).Err()
+}
+
+// GetOnboardClaim returns the claim of an onboarding code, or nil if no
+// robot has redeemed it (or the record expired).
+func (h *RedisHandler) GetOnboardClaim(ctx context.Context, code string) (*OnboardClaim, error) {
+	data, err := h.Client.Get(ctx, onboardClaimKey(code)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	claim := &OnboardClaim{}
+	if err := json.Unmarshal(data, claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// --- Device Bans ---
+
+// Ban kinds.
+const (
+	BanUUID = "uuid"
+	BanIP   = "ip"
+)
+
+// Ban keeps a robot ID or IP address from starting sessions until it
+// expires (set by a kick with a cooldown).
+type Ban struct {
+	Kind   string `json:"kind"` // BanUUID or BanIP
+	Value  string `json:"value"`
+	Reason string `json:"reason,omitempty"`
+	By     string `json:"by,omitempty"`
+	Until  int64  `json:"until"` // Unix seconds
+}
+
+func banKey(kind, value string) string {
+	return fmt.Sprintf("ban:%s:%s", kind, value)
+}
+
+// SetBan stores a ban until b.Until, replacing any ban on the same value.
+func (h *RedisHandler) SetBan(ctx context.Context, b *Ban) error {
+	ttl := time.Until(time.Unix(b.Until, 0))
+	if ttl <= 0 {
+		return fmt.Errorf("ban has already expired")
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ban: %w", err)
+	}
+	return h.Client.Set(ctx, banKey(b.Kind, b.Value), data, ttl).Err()
+}
+
+// GetBan returns the ban on value, or nil if there is none.
+func (h *RedisHandler) GetBan(ctx context.Context, kind, value string) (*Ban, error) {
+	data, err := h.Client.Get(ctx, banKey(kind, value)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := &Ban{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ListBans returns the bans in force, soonest to expire first.
+func (h *RedisHandler) ListBans(ctx context.Context) ([]*Ban, error) {
+	bans := []*Ban{}
+	iter := h.Client.Scan(ctx, 0, "ban:*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := h.Client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		b := &Ban{}
+		if err := json.Unmarshal(data, b); err != nil {
+			continue
+		}
+		bans = append(bans, b)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until < bans[j].Until })
+	return bans, nil
+}
+
+// RemoveBan lifts a ban early. Returns false if there was none.
+func (h *RedisHandler) RemoveBan(ctx context.Context, kind, value string) (bool, error) {
+	n, err := h.Client.Del(ctx, banKey(kind, value)).Result()
+	return n > 0, err
+}
+
 // --- Heartbeat Tracking ---
 
 // HeartbeatState represents a robot's heartbeat state in Redis, independent of handler sessions.
 type HeartbeatState struct {
-	UUID     string `json:"uuid"`
-	IP       string `json:"ip"`
-	LastSeq  int64  `json:"last_seq"`
-	LastSeen int64  `json:"last_seen"`
+	UUID     string   `json:"uuid"`
+	IP       string   `json:"ip"`            // address of the latest heartbeat
+	IPs      []string `json:"ips,omitempty"` // addresses heartbeats came from, most recent first
+	LastSeq  int64    `json:"last_seq"`
+	LastSeen int64    `json:"last_seen"`
+}
+
+// Addresses returns the addresses heartbeats came from, most recent first.
+func (s *HeartbeatState) Addresses() []string {
+	return addresses(s.IPs, s.IP)
 }
 
 func heartbeatKey(uuid string) string {
@@ -293,85 +768,1070 @@ func (h *RedisHandler) GetAllOnlineRobots(ctx context.Context) ([]*HeartbeatStat
 	return states, nil
 }
 
-// --- User Authentication ---
+// --- Link Latency ---
 
-// User represents a user account stored in Redis.
-type User struct {
-	Username     string `json:"username"`
-	PasswordHash string `json:"password_hash"`
+// MaxLatencySamples is the size of the rolling RTT window kept per robot.
+const MaxLatencySamples = 50
+
+// LatencyStats summarizes a robot's recent PING round-trip times.
+type LatencyStats struct {
+	Samples  int     `json:"samples"`
+	LastMs   float64 `json:"last_ms"`
+	MinMs    float64 `json:"min_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	AvgMs    float64 `json:"avg_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	Degraded bool    `json:"degraded"`
+	// Maintenance is set in latency events while the robot is in
+	// maintenance mode, so a degraded link doesn't raise an alert.
+	Maintenance bool `json:"maintenance,omitempty"`
 }
 
-func userKey(username string) string {
-	return fmt.Sprintf("user:%s", username)
+func latencyKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:latency", uuid)
 }
 
-// SetUser stores a user in Redis (no TTL — permanent until deleted).
-func (h *RedisHandler) SetUser(ctx context.Context, user *User) error {
-	data, err := json.Marshal(user)
-	if err != nil {
-		return fmt.Errorf("failed to marshal user: %w", err)
-	}
-	return h.Client.Set(ctx, userKey(user.Username), data, 0).Err()
+// RecordLatency pushes an RTT sample (newest first) and trims the window.
+func (h *RedisHandler) RecordLatency(ctx context.Context, uuid string, rtt time.Duration, ttl time.Duration) error {
+	key := latencyKey(uuid)
+	pipe := h.Client.TxPipeline()
+	pipe.LPush(ctx, key, rtt.Microseconds())
+	pipe.LTrim(ctx, key, 0, MaxLatencySamples-1)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// GetUser retrieves a user from Redis by username.
-func (h *RedisHandler) GetUser(ctx context.Context, username string) (*User, error) {
-	data, err := h.Client.Get(ctx, userKey(username)).Bytes()
+// GetLatencySamples returns recent RTT samples, newest first.
+func (h *RedisHandler) GetLatencySamples(ctx context.Context, uuid string) ([]time.Duration, error) {
+	vals, err := h.Client.LRange(ctx, latencyKey(uuid), 0, -1).Result()
 	if err != nil {
 		return nil, err
 	}
-	u := &User{}
-	if err := json.Unmarshal(data, u); err != nil {
-		return nil, err
+	samples := make([]time.Duration, 0, len(vals))
+	for _, v := range vals {
+		us, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, time.Duration(us)*time.Microsecond)
 	}
-	return u, nil
+	return samples, nil
 }
 
-// --- User Session Management ---
+// SummarizeLatency computes stats over samples (newest first). A link is
+// degraded when its average RTT exceeds the threshold.
+func SummarizeLatency(samples []time.Duration, degradedThreshold time.Duration) *LatencyStats {
+	stats := &LatencyStats{Samples: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
 
-func userSessionKey(token string) string {
-	return fmt.Sprintf("session:%s", token)
-}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
 
-// SetUserSession stores a user session token in Redis with TTL.
-func (h *RedisHandler) SetUserSession(ctx context.Context, token, username string, ttl time.Duration) error {
-	return h.Client.Set(ctx, userSessionKey(token), username, ttl).Err()
-}
+	var total time.Duration
+	for _, s := range samples {
+		total += s
+	}
+	avg := total / time.Duration(len(samples))
+	p95 := sorted[(len(sorted)*95-1)/100]
 
-// GetUserSession retrieves the username associated with a session token.
-func (h *RedisHandler) GetUserSession(ctx context.Context, token string) (string, error) {
-	return h.Client.Get(ctx, userSessionKey(token)).Result()
+	toMs := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	stats.LastMs = toMs(samples[0])
+	stats.MinMs = toMs(sorted[0])
+	stats.MaxMs = toMs(sorted[len(sorted)-1])
+	stats.AvgMs = toMs(avg)
+	stats.P95Ms = toMs(p95)
+	stats.Degraded = avg > degradedThreshold
+	return stats
 }
 
-// RemoveUserSession deletes a user session from Redis.
-func (h *RedisHandler) RemoveUserSession(ctx context.Context, token string) error {
-	return h.Client.Del(ctx, userSessionKey(token)).Err()
+// --- Clock Offset ---
+
+func clockKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:clock", uuid)
 }
 
-// --- SSE Ticket Management ---
+// GetClockOffset returns what is known about a robot's clock, or nil when
+// it has not synced within the key's TTL.
+func (h *RedisHandler) GetClockOffset(ctx context.Context, uuid string) (*timesync.Offset, error) {
+	data, err := h.Client.Get(ctx, clockKey(uuid)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var o timesync.Offset
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
 
-func ticketKey(ticket string) string {
-	return fmt.Sprintf("ticket:%s", ticket)
+// RecordClockSample folds an offset sample into the robot's clock offset,
+// stores it for ttl and returns it. Only the robot's own connection writes
+// the key, so the read-modify-write is not guarded.
+func (h *RedisHandler) RecordClockSample(ctx context.Context, uuid string, sample int64, rtt time.Duration, ttl time.Duration) (*timesync.Offset, error) {
+	o, err := h.GetClockOffset(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o = &timesync.Offset{}
+	}
+	o.Add(sample, rtt, time.Now())
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Client.Set(ctx, clockKey(uuid), data, ttl).Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
 }
 
-// SetTicket stores a single-use SSE ticket in Redis with a short TTL.
-func (h *RedisHandler) SetTicket(ctx context.Context, ticket, username string, ttl time.Duration) error {
-	return h.Client.Set(ctx, ticketKey(ticket), username, ttl).Err()
+// --- Robot Telemetry ---
+
+// TelemetryKey is the list of a robot's DATA envelopes (JSON), newest first.
+func TelemetryKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:telemetry", uuid)
 }
 
-// ConsumeTicket retrieves and deletes a ticket atomically (single-use).
-// Returns the username associated with the ticket, or error if not found/expired.
-func (h *RedisHandler) ConsumeTicket(ctx context.Context, ticket string) (string, error) {
-	key := ticketKey(ticket)
-	username, err := h.Client.GetDel(ctx, key).Result()
+// AddTelemetry pushes an envelope and keeps the newest history entries.
+// ttl refreshes the key's expiry; 0 keeps it forever.
+func (h *RedisHandler) AddTelemetry(ctx context.Context, uuid string, env telemetry.Envelope, history int, ttl time.Duration) error {
+	data, err := json.Marshal(env)
 	if err != nil {
-		return "", err
+		return err
+	}
+	key := TelemetryKey(uuid)
+	pipe := h.Client.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, int64(history)-1)
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
 	}
-	return username, nil
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
-// PublishRegistrationResponse publishes an accept/reject response for a pending robot.
-func (h *RedisHandler) PublishRegistrationResponse(ctx context.Context, uuid string, accepted bool) error {
+// GetTelemetry returns a robot's stored envelopes, newest first.
+func (h *RedisHandler) GetTelemetry(ctx context.Context, uuid string) ([]telemetry.Envelope, error) {
+	entries, err := h.Client.LRange(ctx, TelemetryKey(uuid), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]telemetry.Envelope, 0, len(entries))
+	for _, data := range entries {
+		var env telemetry.Envelope
+		if json.Unmarshal([]byte(data), &env) == nil {
+			out = append(out, env)
+		}
+	}
+	return out, nil
+}
+
+// DeleteTelemetry removes a robot's stored envelopes.
+func (h *RedisHandler) DeleteTelemetry(ctx context.Context, uuid string) error {
+	return h.Client.Del(ctx, TelemetryKey(uuid)).Err()
+}
+
+// GetTelemetryRobots returns the UUIDs of every robot with stored telemetry.
+func (h *RedisHandler) GetTelemetryRobots(ctx context.Context) ([]string, error) {
+	var uuids []string
+	iter := h.Client.Scan(ctx, 0, "robot:*:telemetry", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		uuids = append(uuids, strings.TrimSuffix(strings.TrimPrefix(key, "robot:"), ":telemetry"))
+	}
+	return uuids, iter.Err()
+}
+
+// --- User Authentication ---
+
+// User represents a user account stored in Redis.
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role,omitempty"` // Empty or RoleAdmin = unrestricted robot access
+}
+
+// RoleAdmin grants access to every robot regardless of ACL entries.
+const RoleAdmin = "admin"
+
+// IsAdmin reports whether the user bypasses per-robot ACLs. Accounts created
+// before roles existed have no role and keep full access.
+func (u *User) IsAdmin() bool {
+	return u.Role == "" || u.Role == RoleAdmin
+}
+
+func userKey(username string) string {
+	return fmt.Sprintf("user:%s", username)
+}
+
+// SetUser stores a user in Redis (no TTL — permanent until deleted).
+func (h *RedisHandler) SetUser(ctx context.Context, user *User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user: %w", err)
+	}
+	return h.Client.Set(ctx, userKey(user.Username), data, 0).Err()
+}
+
+// GetUser retrieves a user from Redis by username.
+func (h *RedisHandler) GetUser(ctx context.Context, username string) (*User, error) {
+	data, err := h.Client.Get(ctx, userKey(username)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	u := &User{}
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ListUsers returns every user account, sorted by username.
+func (h *RedisHandler) ListUsers(ctx context.Context) ([]*User, error) {
+	var users []*User
+	iter := h.Client.Scan(ctx, 0, "user:*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if strings.Contains(strings.TrimPrefix(key, "user:"), ":") {
+			continue // user:{name}:apikeys, user:{name}:sessions
+		}
+		data, err := h.Client.Get(ctx, key).Bytes()
+		if err != nil {
+			continue // deleted between SCAN and GET
+		}
+		u := &User{}
+		if err := json.Unmarshal(data, u); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// --- User API Keys ---
+
+// APIKey is a long-lived credential for machine clients (dashboards,
+// scripts). Only a hash of the secret is stored.
+type APIKey struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	Role      string `json:"role,omitempty"` // Empty = act with the owner's role
+	Hash      string `json:"hash"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Apply returns the user a request made with this key acts as: the owner,
+// with the key's role in place of theirs when one is set.
+func (k *APIKey) Apply(owner *User) *User {
+	u := *owner
+	if k.Role != "" {
+		u.Role = k.Role
+	}
+	return &u
+}
+
+func apiKeyKey(id string) string {
+	return fmt.Sprintf("apikey:%s", id)
+}
+
+func userAPIKeysKey(username string) string {
+	return fmt.Sprintf("user:%s:apikeys", username)
+}
+
+// SetAPIKey stores an API key and indexes it under its owner (no TTL).
+func (h *RedisHandler) SetAPIKey(ctx context.Context, key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal API key: %w", err)
+	}
+	pipe := h.Client.TxPipeline()
+	pipe.Set(ctx, apiKeyKey(key.ID), data, 0)
+	pipe.SAdd(ctx, userAPIKeysKey(key.Username), key.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetAPIKey retrieves an API key by id.
+func (h *RedisHandler) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	data, err := h.Client.Get(ctx, apiKeyKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	key := &APIKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns a user's API keys, oldest first. Index entries whose
+// key has gone are skipped.
+func (h *RedisHandler) ListAPIKeys(ctx context.Context, username string) ([]*APIKey, error) {
+	ids, err := h.Client.SMembers(ctx, userAPIKeysKey(username)).Result()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*APIKey, 0, len(ids))
+	for _, id := range ids {
+		key, err := h.GetAPIKey(ctx, id)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt < keys[j].CreatedAt })
+	return keys, nil
+}
+
+// DeleteAPIKey revokes an API key.
+func (h *RedisHandler) DeleteAPIKey(ctx context.Context, key *APIKey) error {
+	pipe := h.Client.TxPipeline()
+	pipe.Del(ctx, apiKeyKey(key.ID))
+	pipe.SRem(ctx, userAPIKeysKey(key.Username), key.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// --- Push Notifications ---
+
+// PushUsersKey is the set of users with notification preferences.
+const PushUsersKey = "push:users"
+
+func pushDevicesKey(username string) string {
+	return fmt.Sprintf("user:%s:push_devices", username)
+}
+
+func notifyPrefsKey(username string) string {
+	return fmt.Sprintf("user:%s:notify", username)
+}
+
+func pushSentKey(username, key string) string {
+	return fmt.Sprintf("push:sent:%s:%s", username, key)
+}
+
+// AddPushDevice registers a device for a user's notifications, replacing
+// the entry for the same token.
+func (h *RedisHandler) AddPushDevice(ctx context.Context, username string, d notify.Device) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return h.Client.HSet(ctx, pushDevicesKey(username), d.Token, data).Err()
+}
+
+// ListPushDevices returns a user's registered devices, oldest first.
+func (h *RedisHandler) ListPushDevices(ctx context.Context, username string) ([]notify.Device, error) {
+	entries, err := h.Client.HGetAll(ctx, pushDevicesKey(username)).Result()
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]notify.Device, 0, len(entries))
+	for _, raw := range entries {
+		var d notify.Device
+		if json.Unmarshal([]byte(raw), &d) == nil {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].AddedAt < devices[j].AddedAt })
+	return devices, nil
+}
+
+// RemovePushDevice unregisters a device. It reports whether it was registered.
+func (h *RedisHandler) RemovePushDevice(ctx context.Context, username, token string) (bool, error) {
+	n, err := h.Client.HDel(ctx, pushDevicesKey(username), token).Result()
+	return n > 0, err
+}
+
+// SetNotificationPrefs stores a user's notification preferences.
+func (h *RedisHandler) SetNotificationPrefs(ctx context.Context, username string, prefs *notify.Prefs) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	pipe := h.Client.TxPipeline()
+	pipe.Set(ctx, notifyPrefsKey(username), data, 0)
+	pipe.SAdd(ctx, PushUsersKey, username)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetNotificationPrefs returns a user's notification preferences, or nil
+// if they have none.
+func (h *RedisHandler) GetNotificationPrefs(ctx context.Context, username string) (*notify.Prefs, error) {
+	data, err := h.Client.Get(ctx, notifyPrefsKey(username)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	prefs := &notify.Prefs{}
+	if err := json.Unmarshal(data, prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// ListNotificationUsers returns the users with notification preferences.
+func (h *RedisHandler) ListNotificationUsers(ctx context.Context) ([]string, error) {
+	return h.Client.SMembers(ctx, PushUsersKey).Result()
+}
+
+// MarkPushSent records a notification for deduplication. It reports false
+// if one with the same key was already sent to the user within window, on
+// any node.
+func (h *RedisHandler) MarkPushSent(ctx context.Context, username, key string, window time.Duration) (bool, error) {
+	if window <= 0 {
+		return true, nil
+	}
+	return h.Client.SetNX(ctx, pushSentKey(username, key), 1, window).Result()
+}
+
+// --- Robot Access Control ---
+
+// ACL principals are either a username or "role:<name>".
+func robotACLKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:acl", uuid)
+}
+
+// RolePrincipal returns the ACL principal string for a role.
+func RolePrincipal(role string) string {
+	return "role:" + role
+}
+
+// GrantRobotAccess allows a principal (username or role:<name>) to control a robot.
+func (h *RedisHandler) GrantRobotAccess(ctx context.Context, uuid, principal string) error {
+	return h.Client.SAdd(ctx, robotACLKey(uuid), principal).Err()
+}
+
+// RevokeRobotAccess removes a principal from a robot's ACL.
+func (h *RedisHandler) RevokeRobotAccess(ctx context.Context, uuid, principal string) error {
+	return h.Client.SRem(ctx, robotACLKey(uuid), principal).Err()
+}
+
+// GetRobotACL lists the principals allowed to control a robot.
+func (h *RedisHandler) GetRobotACL(ctx context.Context, uuid string) ([]string, error) {
+	return h.Client.SMembers(ctx, robotACLKey(uuid)).Result()
+}
+
+// CanAccessRobot reports whether a user may view or control a robot.
+// Admins always can; other users need their username or role in the ACL.
+func (h *RedisHandler) CanAccessRobot(ctx context.Context, user *User, uuid string) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.IsAdmin() {
+		return true, nil
+	}
+	ok, err := h.Client.SIsMember(ctx, robotACLKey(uuid), user.Username).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+	return h.Client.SIsMember(ctx, robotACLKey(uuid), RolePrincipal(user.Role)).Result()
+}
+
+// --- Robot Labels ---
+
+// RobotLabels are operator-assigned tags and zone used to address groups of
+// robots (e.g. POST /robot/quick_action filters).
+type RobotLabels struct {
+	Name string   `json:"name,omitempty"` // display name
+	Tags []string `json:"tags,omitempty"`
+	Zone string   `json:"zone,omitempty"`
+}
+
+// HasTags reports whether every tag in want is present.
+func (l *RobotLabels) HasTags(want []string) bool {
+	for _, tag := range want {
+		found := false
+		for _, t := range l.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func robotLabelsKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:labels", uuid)
+}
+
+// SetRobotLabels stores a robot's labels (no TTL — they outlive sessions).
+func (h *RedisHandler) SetRobotLabels(ctx context.Context, uuid string, labels *RobotLabels) error {
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	return h.Client.Set(ctx, robotLabelsKey(uuid), data, 0).Err()
+}
+
+// GetRobotLabels returns a robot's labels, empty if none were set.
+func (h *RedisHandler) GetRobotLabels(ctx context.Context, uuid string) (*RobotLabels, error) {
+	labels := &RobotLabels{}
+	data, err := h.Client.Get(ctx, robotLabelsKey(uuid)).Bytes()
+	if err == redis.Nil {
+		return labels, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// --- Robot Shadows ---
+
+// shadowRetries bounds how often UpdateShadow retries when another writer
+// changed the shadow between its read and write.
+const shadowRetries = 8
+
+func robotShadowKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:shadow", uuid)
+}
+
+// GetShadow returns a robot's shadow, empty (version 0) if none was stored.
+func (h *RedisHandler) GetShadow(ctx context.Context, uuid string) (*shadow.Shadow, error) {
+	return h.readShadow(ctx, h.Client, uuid)
+}
+
+func (h *RedisHandler) readShadow(ctx context.Context, c redis.Cmdable, uuid string) (*shadow.Shadow, error) {
+	data, err := c.Get(ctx, robotShadowKey(uuid)).Bytes()
+	if err == redis.Nil {
+		return shadow.New(uuid), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := shadow.New(uuid)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shadow: %w", err)
+	}
+	if s.Desired == nil {
+		s.Desired = map[string]any{}
+	}
+	if s.Reported == nil {
+		s.Reported = map[string]any{}
+	}
+	return s, nil
+}
+
+// UpdateShadow applies update to a robot's shadow and stores it with its
+// version incremented, atomically against concurrent updates (WATCH). An
+// error from update aborts without writing.
+func (h *RedisHandler) UpdateShadow(ctx context.Context, uuid string, update func(*shadow.Shadow) error) (*shadow.Shadow, error) {
+	key := robotShadowKey(uuid)
+	var updated *shadow.Shadow
+	txf := func(tx *redis.Tx) error {
+		s, err := h.readShadow(ctx, tx, uuid)
+		if err != nil {
+			return err
+		}
+		if err := update(s); err != nil {
+			return err
+		}
+		s.Version++
+		data, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("failed to marshal shadow: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return pipe.Set(ctx, key, data, 0).Err()
+		})
+		if err == nil {
+			updated = s
+		}
+		return err
+	}
+	for range shadowRetries {
+		err := h.Client.Watch(ctx, txf, key)
+		if err != redis.TxFailedErr {
+			return updated, err
+		}
+	}
+	return nil, fmt.Errorf("shadow of %s is changing too fast, try again", uuid)
+}
+
+// DeleteShadow removes a robot's shadow and reports whether it had one.
+func (h *RedisHandler) DeleteShadow(ctx context.Context, uuid string) (bool, error) {
+	n, err := h.Client.Del(ctx, robotShadowKey(uuid)).Result()
+	return n > 0, err
+}
+
+// --- Registration Auto-Accept ---
+
+// AutoAcceptKey holds the JSON autoaccept.Settings set at runtime, which
+// replace the configured auto_accept settings until cleared (no TTL).
+const AutoAcceptKey = "registration:auto_accept"
+
+// GetAutoAccept returns the auto-accept settings set at runtime, or nil if
+// the configured ones apply.
+func (h *RedisHandler) GetAutoAccept(ctx context.Context) (*autoaccept.Settings, error) {
+	data, err := h.Client.Get(ctx, AutoAcceptKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s autoaccept.Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal auto-accept settings: %w", err)
+	}
+	return &s, nil
+}
+
+// SetAutoAccept stores runtime auto-accept settings.
+func (h *RedisHandler) SetAutoAccept(ctx context.Context, s *autoaccept.Settings) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auto-accept settings: %w", err)
+	}
+	return h.Client.Set(ctx, AutoAcceptKey, data, 0).Err()
+}
+
+// ClearAutoAccept drops the runtime auto-accept settings and reports
+// whether there were any.
+func (h *RedisHandler) ClearAutoAccept(ctx context.Context) (bool, error) {
+	n, err := h.Client.Del(ctx, AutoAcceptKey).Result()
+	return n > 0, err
+}
+
+// --- Maintenance Mode ---
+
+// MaintenanceKey is a hash of robot uuid -> JSON maintenance.Info for every
+// robot in maintenance mode (no TTL; maintenance lasts until ended).
+const MaintenanceKey = "maintenance"
+
+func maintenanceQueueKey(uuid string) string {
+	return fmt.Sprintf("robot:%s:maintenance_queue", uuid)
+}
+
+// SetMaintenance puts a robot into maintenance, replacing any earlier info.
+func (h *RedisHandler) SetMaintenance(ctx context.Context, info maintenance.Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return h.Client.HSet(ctx, MaintenanceKey, info.UUID, data).Err()
+}
+
+// ClearMaintenance ends a robot's maintenance and reports whether it was in it.
+func (h *RedisHandler) ClearMaintenance(ctx context.Context, uuid string) (bool, error) {
+	n, err := h.Client.HDel(ctx, MaintenanceKey, uuid).Result()
+	return n > 0, err
+}
+
+// ListMaintenance returns every robot in maintenance.
+func (h *RedisHandler) ListMaintenance(ctx context.Context) ([]maintenance.Info, error) {
+	entries, err := h.Client.HGetAll(ctx, MaintenanceKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]maintenance.Info, 0, len(entries))
+	for _, data := range entries {
+		var info maintenance.Info
+		if json.Unmarshal([]byte(data), &info) == nil {
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+// QueueMaintenanceMessage holds an automated message for a robot in
+// maintenance, keeping the newest limit messages.
+func (h *RedisHandler) QueueMaintenanceMessage(ctx context.Context, uuid, msg string, limit int) error {
+	key := maintenanceQueueKey(uuid)
+	pipe := h.Client.TxPipeline()
+	pipe.RPush(ctx, key, msg)
+	pipe.LTrim(ctx, key, int64(-limit), -1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TakeMaintenanceQueue removes and returns a robot's held messages, oldest
+// first.
+func (h *RedisHandler) TakeMaintenanceQueue(ctx context.Context, uuid string) ([]string, error) {
+	key := maintenanceQueueKey(uuid)
+	pipe := h.Client.TxPipeline()
+	msgs := pipe.LRange(ctx, key, 0, -1)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return msgs.Val(), nil
+}
+
+// --- Handler Data ---
+
+// HandlerDataKey is where a handler's store_data values live. Values are JSON.
+func HandlerDataKey(uuid, key string) string {
+	return fmt.Sprintf("handler:%s:data:%s", uuid, key)
+}
+
+// GetHandlerData returns the raw JSON a handler stored under key, or ""
+// if the handler never stored it.
+func (h *RedisHandler) GetHandlerData(ctx context.Context, uuid, key string) (string, error) {
+	val, err := h.Client.Get(ctx, HandlerDataKey(uuid, key)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return val, err
+}
+
+// --- Message Macros ---
+
+// MacrosKey is a hash of macro name -> JSON macro.Macro, shared by all robots.
+const MacrosKey = "macros"
+
+// SetMacro stores or replaces a macro (no TTL).
+func (h *RedisHandler) SetMacro(ctx context.Context, m *macro.Macro) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal macro: %w", err)
+	}
+	return h.Client.HSet(ctx, MacrosKey, m.Name, data).Err()
+}
+
+// GetMacro returns a macro by name, or nil if it doesn't exist.
+func (h *RedisHandler) GetMacro(ctx context.Context, name string) (*macro.Macro, error) {
+	data, err := h.Client.HGet(ctx, MacrosKey, name).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &macro.Macro{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DeleteMacro removes a macro; it reports whether one existed.
+func (h *RedisHandler) DeleteMacro(ctx context.Context, name string) (bool, error) {
+	n, err := h.Client.HDel(ctx, MacrosKey, name).Result()
+	return n > 0, err
+}
+
+// ListMacros returns all macros sorted by name.
+func (h *RedisHandler) ListMacros(ctx context.Context) ([]*macro.Macro, error) {
+	entries, err := h.Client.HGetAll(ctx, MacrosKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	macros := make([]*macro.Macro, 0, len(entries))
+	for _, data := range entries {
+		m := &macro.Macro{}
+		if err := json.Unmarshal([]byte(data), m); err != nil {
+			continue
+		}
+		macros = append(macros, m)
+	}
+	sort.Slice(macros, func(i, j int) bool { return macros[i].Name < macros[j].Name })
+	return macros, nil
+}
+
+// --- User Session Management ---
+
+func userSessionKey(token string) string {
+	return fmt.Sprintf("session:%s", token)
+}
+
+// SetUserSession stores a user session token in Redis with TTL.
+func (h *RedisHandler) SetUserSession(ctx context.Context, token, username string, ttl time.Duration) error {
+	return h.Client.Set(ctx, userSessionKey(token), username, ttl).Err()
+}
+
+// GetUserSession retrieves the username associated with a session token.
+func (h *RedisHandler) GetUserSession(ctx context.Context, token string) (string, error) {
+	return h.Client.Get(ctx, userSessionKey(token)).Result()
+}
+
+// RemoveUserSession deletes a user session from Redis.
+func (h *RedisHandler) RemoveUserSession(ctx context.Context, token string) error {
+	return h.Client.Del(ctx, userSessionKey(token)).Err()
+}
+
+// UserSession describes a login session for GET /auth/sessions. Its ID is
+// the JWT's token_id, never the token itself.
+type UserSession struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	CreatedAt int64  `json:"created_at"` // Unix seconds
+	LastSeen  int64  `json:"last_seen"`
+	ExpiresAt int64  `json:"expires_at"` // absolute: the session's maximum age
+	// IdleExpiresAt is when the session ends unless used again (0 = no
+	// idle timeout). Activity moves it forward, never past ExpiresAt.
+	IdleExpiresAt int64 `json:"idle_expires_at,omitempty"`
+}
+
+// Deadline returns when the session ends if nothing renews it, and why:
+// "idle" or "absolute".
+func (s *UserSession) Deadline() (time.Time, string) {
+	if s.IdleExpiresAt > 0 && s.IdleExpiresAt < s.ExpiresAt {
+		return time.Unix(s.IdleExpiresAt, 0), "idle"
+	}
+	return time.Unix(s.ExpiresAt, 0), "absolute"
+}
+
+// userSessionRecord is a UserSession as stored in the user's index, with
+// the token needed to revoke it.
+type userSessionRecord struct {
+	UserSession
+	Token string `json:"token"`
+}
+
+func userSessionsKey(username string) string {
+	return fmt.Sprintf("user:%s:sessions", username)
+}
+
+// AddUserSession stores a user session token with TTL, like
+// SetUserSession, and indexes it under the user for ListUserSessions. The
+// index outlives ttl when the session may be renewed up to ExpiresAt.
+func (h *RedisHandler) AddUserSession(ctx context.Context, token string, s *UserSession, ttl time.Duration) error {
+	data, err := json.Marshal(userSessionRecord{UserSession: *s, Token: token})
+	if err != nil {
+		return fmt.Errorf("failed to marshal user session: %w", err)
+	}
+	indexTTL := max(ttl, time.Until(time.Unix(s.ExpiresAt, 0)))
+	pipe := h.Client.TxPipeline()
+	pipe.Set(ctx, userSessionKey(token), s.Username, ttl)
+	pipe.HSet(ctx, userSessionsKey(s.Username), s.ID, data)
+	pipe.Expire(ctx, userSessionsKey(s.Username), indexTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListUserSessions returns a user's live sessions, oldest first. Index
+// entries whose session has expired or was removed are dropped.
+func (h *RedisHandler) ListUserSessions(ctx context.Context, username string) ([]*UserSession, error) {
+	records, err := h.userSessionRecords(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*UserSession, 0, len(records))
+	for _, rec := range records {
+		sessions = append(sessions, &rec.UserSession)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt < sessions[j].CreatedAt })
+	return sessions, nil
+}
+
+// userSessionRecords loads a user's indexed sessions, pruning stale ones.
+func (h *RedisHandler) userSessionRecords(ctx context.Context, username string) ([]*userSessionRecord, error) {
+	entries, err := h.Client.HGetAll(ctx, userSessionsKey(username)).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*userSessionRecord, 0, len(entries))
+	var stale []string
+	for id, data := range entries {
+		rec := &userSessionRecord{}
+		if err := json.Unmarshal([]byte(data), rec); err != nil {
+			stale = append(stale, id)
+			continue
+		}
+		n, err := h.Client.Exists(ctx, userSessionKey(rec.Token)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			stale = append(stale, id)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if len(stale) > 0 {
+		h.Client.HDel(ctx, userSessionsKey(username), stale...)
+	}
+	return records, nil
+}
+
+// RevokeUserSession ends one of a user's sessions by ID. It reports whether
+// the session existed.
+func (h *RedisHandler) RevokeUserSession(ctx context.Context, username, id string) (bool, error) {
+	data, err := h.Client.HGet(ctx, userSessionsKey(username), id).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	rec := &userSessionRecord{}
+	pipe := h.Client.TxPipeline()
+	if json.Unmarshal(data, rec) == nil {
+		pipe.Del(ctx, userSessionKey(rec.Token))
+	}
+	pipe.HDel(ctx, userSessionsKey(username), id)
+	pipe.Del(ctx, sseSubscriptionsKey(id))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeOtherUserSessions ends every session of a user except keep (an ID,
+// "" to end them all) and returns the IDs it ended.
+func (h *RedisHandler) RevokeOtherUserSessions(ctx context.Context, username, keep string) ([]string, error) {
+	records, err := h.userSessionRecords(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	revoked := []string{}
+	for _, rec := range records {
+		if rec.ID == keep {
+			continue
+		}
+		if ok, err := h.RevokeUserSession(ctx, username, rec.ID); err != nil {
+			return revoked, err
+		} else if ok {
+			revoked = append(revoked, rec.ID)
+		}
+	}
+	return revoked, nil
+}
+
+// GetUserSessionInfo returns one of a user's indexed sessions, or nil if
+// it isn't indexed.
+func (h *RedisHandler) GetUserSessionInfo(ctx context.Context, username, id string) (*UserSession, error) {
+	data, err := h.Client.HGet(ctx, userSessionsKey(username), id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &userSessionRecord{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, err
+	}
+	return &rec.UserSession, nil
+}
+
+// TouchUserSession records activity on a session at now. With an idle
+// timeout, it also slides the session's expiry to now+idle, capped at its
+// absolute ExpiresAt.
+func (h *RedisHandler) TouchUserSession(ctx context.Context, username, id string, now time.Time, idle time.Duration) error {
+	data, err := h.Client.HGet(ctx, userSessionsKey(username), id).Bytes()
+	if err != nil {
+		return err
+	}
+	rec := &userSessionRecord{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return err
+	}
+	rec.LastSeen = now.Unix()
+	pipe := h.Client.TxPipeline()
+	if idle > 0 && rec.ExpiresAt > 0 {
+		deadline := min(now.Add(idle).Unix(), rec.ExpiresAt)
+		rec.IdleExpiresAt = deadline
+		pipe.ExpireAt(ctx, userSessionKey(rec.Token), time.Unix(deadline, 0))
+	}
+	if data, err = json.Marshal(rec); err != nil {
+		return err
+	}
+	pipe.HSet(ctx, userSessionsKey(username), id, data)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// --- SSE Ticket Management ---
+
+func ticketKey(ticket string) string {
+	return fmt.Sprintf("ticket:%s", ticket)
+}
+
+// SetTicket stores a single-use SSE ticket in Redis with a short TTL.
+// The value is pipe-delimited: username|sessionID, so the SSE stream opened
+// with the ticket is tied to the issuing session.
+func (h *RedisHandler) SetTicket(ctx context.Context, ticket, username, sessionID string, ttl time.Duration) error {
+	return h.Client.Set(ctx, ticketKey(ticket), username+"|"+sessionID, ttl).Err()
+}
+
+// ConsumeTicket retrieves and deletes a ticket atomically (single-use).
+// Returns the username and session ID associated with the ticket, or error if not found/expired.
+func (h *RedisHandler) ConsumeTicket(ctx context.Context, ticket string) (string, string, error) {
+	key := ticketKey(ticket)
+	value, err := h.Client.GetDel(ctx, key).Result()
+	if err != nil {
+		return "", "", err
+	}
+	username, sessionID, _ := strings.Cut(value, "|")
+	return username, sessionID, nil
+}
+
+// --- SSE Subscription Persistence ---
+
+func sseSubscriptionsKey(sessionID string) string {
+	return fmt.Sprintf("sse_subs:%s", sessionID)
+}
+
+// AddSSESubscriptions records event types a user session is subscribed to,
+// so they can be restored when the SSE stream reconnects. The TTL is
+// refreshed on every change.
+func (h *RedisHandler) AddSSESubscriptions(ctx context.Context, sessionID string, eventTypes []string, ttl time.Duration) error {
+	if len(eventTypes) == 0 {
+		return nil
+	}
+	key := sseSubscriptionsKey(sessionID)
+	members := make([]interface{}, len(eventTypes))
+	for i, et := range eventTypes {
+		members[i] = et
+	}
+	pipe := h.Client.TxPipeline()
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RemoveSSESubscriptions drops event types from a session's persisted set.
+func (h *RedisHandler) RemoveSSESubscriptions(ctx context.Context, sessionID string, eventTypes []string) error {
+	if len(eventTypes) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(eventTypes))
+	for i, et := range eventTypes {
+		members[i] = et
+	}
+	return h.Client.SRem(ctx, sseSubscriptionsKey(sessionID), members...).Err()
+}
+
+// GetSSESubscriptions returns the persisted event types for a session.
+func (h *RedisHandler) GetSSESubscriptions(ctx context.Context, sessionID string) ([]string, error) {
+	return h.Client.SMembers(ctx, sseSubscriptionsKey(sessionID)).Result()
+}
+
+// ClearSSESubscriptions deletes a session's persisted subscriptions (e.g. on logout).
+func (h *RedisHandler) ClearSSESubscriptions(ctx context.Context, sessionID string) error {
+	return h.Client.Del(ctx, sseSubscriptionsKey(sessionID)).Err()
+}
+
+// PublishRegistrationResponse publishes an accept/reject response for a pending robot.
+func (h *RedisHandler) PublishRegistrationResponse(ctx context.Context, uuid string, accepted bool) error {
 	msg := "reject"
 	if accepted {
 		msg = "accept"
@@ -398,3 +1858,55 @@ func (h *RedisHandler) WaitForRegistrationResponse(ctx context.Context, uuid str
 		return false, ctx.Err()
 	}
 }
+
+// --- Cluster Leader Election ---
+
+// LeaderKey holds the node id of the current cluster leader.
+const LeaderKey = "cluster:leader"
+
+// campaignScript takes the leader lock if it is free, or renews it if this
+// node already holds it. Returns 1 when the caller is leader.
+var campaignScript = redis.NewScript(`
+local cur = redis.call("GET", KEYS[1])
+if cur == false then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if cur == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// resignScript releases the leader lock only if this node holds it.
+var resignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// CampaignLeader tries to become (or stay) cluster leader for ttl.
+func (h *RedisHandler) CampaignLeader(ctx context.Context, node string, ttl time.Duration) (bool, error) {
+	won, err := campaignScript.Run(ctx, h.Client, []string{LeaderKey}, node, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return won == 1, nil
+}
+
+// ResignLeader gives up leadership so another node can take over without
+// waiting for the lock to expire.
+func (h *RedisHandler) ResignLeader(ctx context.Context, node string) error {
+	return resignScript.Run(ctx, h.Client, []string{LeaderKey}, node).Err()
+}
+
+// GetLeader returns the current leader's node id, or "" if there is none.
+func (h *RedisHandler) GetLeader(ctx context.Context) (string, error) {
+	node, err := h.Client.Get(ctx, LeaderKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return node, err
+}
@@ -2,7 +2,10 @@ package database
 
 import (
 	"encoding/json"
+	"roboserver/shared/tokencrypt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestActiveRobotSerialization(t *testing.T) {
@@ -64,6 +67,51 @@ func TestActiveRobotPIDOmitempty(t *testing.T) {
 	}
 }
 
+func TestActiveRobotAddresses(t *testing.T) {
+	// Sessions stored before ips existed list their one address.
+	old := &ActiveRobot{UUID: "r1", IP: "[::ffff:10.0.0.5]:4312"}
+	if got := old.Addresses(); len(got) != 1 || got[0] != "10.0.0.5" {
+		t.Fatalf("Expected the canonical session IP, got %v", got)
+	}
+
+	old.AddIP("2001:db8::7")
+	old.AddIP("10.0.0.5")
+	if old.IP != "10.0.0.5" || len(old.IPs) != 2 || old.IPs[1] != "2001:db8::7" {
+		t.Errorf("Expected wifi and ethernet addresses, latest first, got %q %v", old.IP, old.IPs)
+	}
+	for i := 0; i < MaxRobotIPs+2; i++ {
+		old.AddIP("10.1.0." + string(rune('1'+i)))
+	}
+	if len(old.IPs) != MaxRobotIPs {
+		t.Errorf("Expected at most %d addresses, got %v", MaxRobotIPs, old.IPs)
+	}
+}
+
+func TestMarshalActiveRobotSealsJWT(t *testing.T) {
+	if err := tokencrypt.Configure("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", nil); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	defer tokencrypt.Configure("", nil)
+
+	robot := &ActiveRobot{UUID: "robot-003", SessionJWT: "secret-jwt"}
+	data, err := marshalActiveRobot(robot)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if strings.Contains(string(data), "secret-jwt") {
+		t.Errorf("Expected the stored JWT to be encrypted, got %s", data)
+	}
+	if robot.SessionJWT != "secret-jwt" {
+		t.Error("marshalActiveRobot must not modify the caller's robot")
+	}
+
+	var stored ActiveRobot
+	json.Unmarshal(data, &stored)
+	if jwt, stale, err := tokencrypt.Open(stored.SessionJWT); err != nil || jwt != "secret-jwt" || stale {
+		t.Errorf("Expected stored JWT to open, got %q stale=%v err=%v", jwt, stale, err)
+	}
+}
+
 func TestPendingRobotSerialization(t *testing.T) {
 	robot := &PendingRobot{
 		UUID:        "pending-001",
@@ -121,6 +169,36 @@ func TestRedisKeyFormats(t *testing.T) {
 	if ch != "robot:robot-abc:reg_response" {
 		t.Errorf("Expected robot:robot-abc:reg_response, got %s", ch)
 	}
+
+	// Robot ACL
+	aclKey := robotACLKey("lamp-1")
+	if aclKey != "robot:lamp-1:acl" {
+		t.Errorf("Expected robot:lamp-1:acl, got %s", aclKey)
+	}
+
+	// Handler-scoped data
+	dataKey := HandlerDataKey("env-1", "history")
+	if dataKey != "handler:env-1:data:history" {
+		t.Errorf("Expected handler:env-1:data:history, got %s", dataKey)
+	}
+
+	// Persisted SSE subscriptions
+	subsKey := sseSubscriptionsKey("tok-1")
+	if subsKey != "sse_subs:tok-1" {
+		t.Errorf("Expected sse_subs:tok-1, got %s", subsKey)
+	}
+
+	// Login session index
+	sessKey := userSessionsKey("alice")
+	if sessKey != "user:alice:sessions" {
+		t.Errorf("Expected user:alice:sessions, got %s", sessKey)
+	}
+
+	// Pairing codes
+	pairKey := pairingCodeKey("ABCDEFGHJK")
+	if pairKey != "pairing:ABCDEFGHJK" {
+		t.Errorf("Expected pairing:ABCDEFGHJK, got %s", pairKey)
+	}
 }
 
 func TestRedisKeyUniqueness(t *testing.T) {
@@ -141,3 +219,93 @@ func TestRedisKeyUniqueness(t *testing.T) {
 		t.Error("Redis keys for the same UUID should all be unique")
 	}
 }
+
+func TestSummarizeLatency(t *testing.T) {
+	samples := []time.Duration{
+		40 * time.Millisecond, // newest
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+	}
+	stats := SummarizeLatency(samples, 100*time.Millisecond)
+
+	if stats.Samples != 4 {
+		t.Errorf("Expected 4 samples, got %d", stats.Samples)
+	}
+	if stats.LastMs != 40 || stats.MinMs != 10 || stats.MaxMs != 40 {
+		t.Errorf("Unexpected last/min/max: %+v", stats)
+	}
+	if stats.AvgMs != 25 {
+		t.Errorf("Expected avg 25ms, got %f", stats.AvgMs)
+	}
+	if stats.Degraded {
+		t.Error("Expected link not degraded")
+	}
+
+	if !SummarizeLatency(samples, 20*time.Millisecond).Degraded {
+		t.Error("Expected link degraded above threshold")
+	}
+	if empty := SummarizeLatency(nil, time.Second); empty.Samples != 0 || empty.Degraded {
+		t.Errorf("Expected empty stats, got %+v", empty)
+	}
+}
+
+func TestUserIsAdmin(t *testing.T) {
+	cases := []struct {
+		role string
+		want bool
+	}{
+		{"", true}, // accounts created before roles existed
+		{RoleAdmin, true},
+		{"kids", false},
+	}
+	for _, c := range cases {
+		u := &User{Username: "u", Role: c.role}
+		if got := u.IsAdmin(); got != c.want {
+			t.Errorf("Role %q: IsAdmin() = %v, want %v", c.role, got, c.want)
+		}
+	}
+	if RolePrincipal("kids") != "role:kids" {
+		t.Errorf("Expected role:kids, got %s", RolePrincipal("kids"))
+	}
+}
+
+func TestAPIKeyApplyRole(t *testing.T) {
+	owner := &User{Username: "ci", Role: RoleAdmin}
+
+	scoped := (&APIKey{Username: "ci", Role: "viewer"}).Apply(owner)
+	if scoped.Role != "viewer" || scoped.IsAdmin() {
+		t.Errorf("Expected the key's role to replace the owner's, got %+v", scoped)
+	}
+	if owner.Role != RoleAdmin {
+		t.Error("Apply must not modify the owner")
+	}
+
+	inherited := (&APIKey{Username: "ci"}).Apply(owner)
+	if inherited.Role != RoleAdmin {
+		t.Errorf("Expected a key without a role to keep the owner's, got %q", inherited.Role)
+	}
+}
+
+func TestUserSessionRecordHidesToken(t *testing.T) {
+	rec := userSessionRecord{
+		UserSession: UserSession{ID: "tok-1", Username: "alice", IP: "10.0.0.5", CreatedAt: 100, LastSeen: 160, ExpiresAt: 200},
+		Token:       "header.claims.sig",
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatalf("Failed to marshal record: %v", err)
+	}
+	var decoded userSessionRecord
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal record: %v", err)
+	}
+	if decoded.Token != rec.Token || decoded.UserSession != rec.UserSession {
+		t.Errorf("Record did not round-trip: %+v", decoded)
+	}
+
+	view, _ := json.Marshal(&decoded.UserSession)
+	if strings.Contains(string(view), rec.Token) {
+		t.Errorf("UserSession JSON must not contain the token: %s", view)
+	}
+}
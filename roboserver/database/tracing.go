@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"roboserver/shared/tracing"
+
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// Database spans are only recorded inside an existing trace (an API
+// request, a robot's message), so background work such as the registry
+// cache refresh doesn't start a trace per query.
+
+// startDBSpan starts a client span for a database call, or returns a nil
+// span when ctx isn't part of a trace.
+func startDBSpan(ctx context.Context, name, system string) (context.Context, *tracing.Span) {
+	if !tracing.SpanContextFrom(ctx).IsValid() {
+		return ctx, nil
+	}
+	ctx, span := tracing.StartSpan(ctx, tracing.KindClient, name)
+	span.SetAttr("db.system", system)
+	return ctx, span
+}
+
+// redisTracing is a go-redis hook recording a span per command or pipeline.
+type redisTracing struct{}
+
+func (redisTracing) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := startDBSpan(ctx, "redis dial", "redis")
+		conn, err := next(ctx, network, addr)
+		span.SetError(err)
+		span.End()
+		return conn, err
+	}
+}
+
+func (redisTracing) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startDBSpan(ctx, "redis "+cmd.Name(), "redis")
+		err := next(ctx, cmd)
+		if !errors.Is(err, redis.Nil) {
+			span.SetError(err)
+		}
+		span.End()
+		return err
+	}
+}
+
+func (redisTracing) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startDBSpan(ctx, "redis pipeline", "redis")
+		span.SetAttr("db.redis.commands", len(cmds))
+		err := next(ctx, cmds)
+		if !errors.Is(err, redis.Nil) {
+			span.SetError(err)
+		}
+		span.End()
+		return err
+	}
+}
+
+// tracedConnector opens lib/pq connections whose queries record spans.
+type tracedConnector struct {
+	*pq.Connector
+}
+
+func newTracedConnector(dsn string) (driver.Connector, error) {
+	c, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return tracedConnector{c}, nil
+}
+
+func (c tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+// tracedConn records a span for QueryContext and ExecContext and forwards
+// the other optional driver interfaces to the underlying connection, so
+// database/sql treats it like the unwrapped one.
+type tracedConn struct {
+	driver.Conn
+}
+
+func querySpan(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	ctx, span := startDBSpan(ctx, "postgres query", "postgresql")
+	span.SetAttr("db.statement", query)
+	return ctx, span
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := querySpan(ctx, query)
+	defer span.End()
+	rows, err := q.QueryContext(ctx, query, args)
+	span.SetError(err)
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := querySpan(ctx, query)
+	defer span.End()
+	res, err := e.ExecContext(ctx, query, args)
+	span.SetError(err)
+	return res, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,318 @@
+// Package discovery finds robots on the local network before they are
+// registered. A scan probes every address of the configured subnets two
+// ways:
+//
+//   - UDP beacon: the datagram {"type":"discover"} is sent to
+//     discovery.udp_port on each address (one socket for the whole scan).
+//   - TCP: a connection to each of discovery.tcp_ports gets the line
+//     "DISCOVER".
+//
+// A device that speaks the Robomesh protocol answers either probe with one
+// JSON object (a line over TCP):
+//
+//	{"type":"discover_response","uuid":"lamp-7","device_type":"lamp","public_key":"<hex>","name":"Hall lamp"}
+//
+// Answers become Candidates, kept for discovery.keep and listed at
+// GET /robot/discovered. One that reported its uuid, device type and public
+// key can be provisioned without retyping them.
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"roboserver/comms"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/jobs"
+	"roboserver/shared/utils"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Probe and answer types of the discovery protocol.
+const (
+	probeType    = "discover"
+	responseType = "discover_response"
+	tcpProbe     = "DISCOVER\n"
+)
+
+// Candidate is a device that answered a discovery probe.
+type Candidate struct {
+	IP         string `json:"ip"`
+	Port       int    `json:"port"`
+	Via        string `json:"via"` // "udp" or "tcp"
+	UUID       string `json:"uuid,omitempty"`
+	DeviceType string `json:"device_type,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
+	Name       string `json:"name,omitempty"`
+	FirstSeen  int64  `json:"first_seen"` // unix seconds
+	LastSeen   int64  `json:"last_seen"`
+}
+
+// Provisionable reports whether the device reported everything
+// POST /provision needs.
+func (c *Candidate) Provisionable() bool {
+	return c.UUID != "" && c.DeviceType != "" && c.PublicKey != ""
+}
+
+// response is a device's answer to a probe.
+type response struct {
+	Type       string `json:"type"`
+	UUID       string `json:"uuid"`
+	DeviceType string `json:"device_type"`
+	PublicKey  string `json:"public_key"`
+	Name       string `json:"name"`
+}
+
+// Options describe one scan.
+type Options struct {
+	CIDRs       []string // empty = LocalSubnets()
+	UDPPort     int      // 0 = no beacon
+	TCPPorts    []int
+	Timeout     time.Duration
+	Concurrency int
+	MaxHosts    int
+}
+
+// OptionsFromConfig returns the scan options of shared.AppConfig.Discovery.
+func OptionsFromConfig() Options {
+	cfg := &shared.AppConfig.Discovery
+	return Options{
+		CIDRs:       cfg.CIDRs,
+		UDPPort:     cfg.UDPPort,
+		TCPPorts:    cfg.TCPPorts,
+		Timeout:     cfg.ProbeTimeout(),
+		Concurrency: cfg.Concurrency,
+		MaxHosts:    cfg.MaxHosts,
+	}
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotDiscovered,
+		Description: "A network scan found a device that was not listed at GET /robot/discovered.",
+		Example:     Candidate{IP: "192.168.1.40", Port: 5002, Via: "udp", UUID: "lamp-7", DeviceType: "lamp", PublicKey: "04ab…", Name: "Hall lamp", FirstSeen: 1718000000, LastSeen: 1718000000},
+	})
+}
+
+// LocalSubnets returns the /24 around each local IPv4 address: the default
+// scan, small enough to probe quickly whatever the interface's mask.
+func LocalSubnets() []string {
+	var cidrs []string
+	for _, ip := range utils.GetLocalIPs() {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		prefix, _ := addr.Prefix(24)
+		if cidr := prefix.String(); !slices.Contains(cidrs, cidr) {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+// Hosts expands IPv4 CIDRs (or single addresses) into the addresses to
+// probe, without network and broadcast addresses. It fails if the CIDRs
+// hold more than limit addresses.
+func Hosts(cidrs []string, limit int) ([]netip.Addr, error) {
+	seen := make(map[netip.Addr]bool)
+	var hosts []netip.Addr
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", cidr)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if !prefix.Addr().Is4() {
+			return nil, fmt.Errorf("only IPv4 subnets can be scanned, got %q", cidr)
+		}
+		prefix = prefix.Masked()
+		bits := 32 - prefix.Bits()
+		if uint64(1)<<bits > uint64(limit)+2 {
+			return nil, fmt.Errorf("%s has more than the %d addresses discovery.max_hosts allows", cidr, limit)
+		}
+		first, last := prefix.Addr(), lastAddr(prefix)
+		if bits >= 2 { // skip the network and broadcast addresses
+			first, last = first.Next(), last.Prev()
+		}
+		for a := first; a.Compare(last) <= 0; a = a.Next() {
+			if !seen[a] {
+				seen[a] = true
+				hosts = append(hosts, a)
+			}
+		}
+		if len(hosts) > limit {
+			return nil, fmt.Errorf("the subnets hold more than the %d addresses discovery.max_hosts allows", limit)
+		}
+	}
+	return hosts, nil
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Addr().As4()
+	host := uint32(1)<<(32-p.Bits()) - 1
+	v := uint32(a[0])<<24 | uint32(a[1])<<16 | uint32(a[2])<<8 | uint32(a[3]) | host
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+// Scan probes the hosts of opts and returns the devices that answered, one
+// per address and port. p (may be nil) counts probed hosts.
+func Scan(ctx context.Context, opts Options, p *jobs.Progress) ([]Candidate, error) {
+	cidrs := opts.CIDRs
+	if len(cidrs) == 0 {
+		cidrs = LocalSubnets()
+	}
+	hosts, err := Hosts(cidrs, opts.MaxHosts)
+	if err != nil {
+		return nil, err
+	}
+	p.SetTotal(len(hosts))
+
+	var (
+		mu    sync.Mutex
+		found []Candidate
+	)
+	add := func(c Candidate) {
+		mu.Lock()
+		found = append(found, c)
+		mu.Unlock()
+	}
+
+	var beacon sync.WaitGroup
+	if opts.UDPPort > 0 {
+		conn, err := net.ListenPacket("udp4", ":0")
+		if err != nil {
+			return nil, fmt.Errorf("discovery beacon: %w", err)
+		}
+		defer conn.Close()
+		beacon.Add(1)
+		go func() {
+			defer beacon.Done()
+			readBeaconReplies(conn, add)
+		}()
+		probe, _ := json.Marshal(map[string]string{"type": probeType})
+		for _, h := range hosts {
+			if ctx.Err() != nil {
+				break
+			}
+			conn.WriteTo(probe, net.UDPAddrFromAddrPort(netip.AddrPortFrom(h, uint16(opts.UDPPort))))
+		}
+		// Replies are read until the timeout after the last probe.
+		conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+	}
+
+	work := make(chan netip.Addr)
+	var probes sync.WaitGroup
+	for range max(opts.Concurrency, 1) {
+		probes.Add(1)
+		go func() {
+			defer probes.Done()
+			for h := range work {
+				for _, port := range opts.TCPPorts {
+					if c, ok := probeTCP(ctx, h, port, opts.Timeout); ok {
+						add(c)
+					}
+				}
+				p.Add(1)
+			}
+		}()
+	}
+	for _, h := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- h
+	}
+	close(work)
+	probes.Wait()
+	beacon.Wait()
+
+	slices.SortFunc(found, byAddress)
+	return found, ctx.Err()
+}
+
+// readBeaconReplies collects answers on the beacon socket until its read
+// deadline passes.
+func readBeaconReplies(conn net.PacketConn, add func(Candidate)) {
+	buf := make([]byte, 4096)
+	answered := make(map[string]bool)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		udp, ok := from.(*net.UDPAddr)
+		if !ok || answered[udp.String()] {
+			continue
+		}
+		if c, ok := parseResponse(buf[:n]); ok {
+			answered[udp.String()] = true
+			c.IP, c.Port, c.Via = udp.IP.String(), udp.Port, "udp"
+			add(c)
+		}
+	}
+}
+
+// probeTCP sends DISCOVER to host:port and reads the one-line answer.
+func probeTCP(ctx context.Context, host netip.Addr, port int, timeout time.Duration) (Candidate, bool) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp4", net.JoinHostPort(host.String(), strconv.Itoa(port)))
+	if err != nil {
+		return Candidate{}, false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(tcpProbe)); err != nil {
+		return Candidate{}, false
+	}
+	line, err := bufio.NewReader(conn).ReadSlice('\n')
+	if err != nil {
+		return Candidate{}, false
+	}
+	c, ok := parseResponse(line)
+	c.IP, c.Port, c.Via = host.String(), port, "tcp"
+	return c, ok
+}
+
+func parseResponse(data []byte) (Candidate, bool) {
+	var r response
+	if json.Unmarshal(data, &r) != nil || r.Type != responseType {
+		return Candidate{}, false
+	}
+	return Candidate{UUID: r.UUID, DeviceType: r.DeviceType, PublicKey: r.PublicKey, Name: r.Name}, true
+}
+
+// byAddress orders candidates by IP address, then port.
+func byAddress(a, b Candidate) int {
+	x, errX := netip.ParseAddr(a.IP)
+	y, errY := netip.ParseAddr(b.IP)
+	if errX == nil && errY == nil {
+		if c := x.Compare(y); c != 0 {
+			return c
+		}
+	}
+	return a.Port - b.Port
+}
+
+// Run scans as configured, adds the answers to Found and publishes
+// RobotDiscovered for each device not listed before. It returns this scan's
+// candidates.
+func Run(ctx context.Context, bus comms.Bus, opts Options, p *jobs.Progress) ([]Candidate, error) {
+	found, err := Scan(ctx, opts, p)
+	for _, c := range Found.Merge(found, time.Now()) {
+		if bus != nil {
+			bus.PublishEvent(events.RobotDiscovered, c)
+		}
+	}
+	return found, err
+}
@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHosts(t *testing.T) {
+	hosts, err := Hosts([]string{"10.0.0.0/30", "10.0.0.2", "192.168.1.9"}, 16)
+	if err != nil {
+		t.Fatalf("Hosts failed: %v", err)
+	}
+	if fmt.Sprint(hosts) != "[10.0.0.1 10.0.0.2 192.168.1.9]" {
+		t.Errorf("Expected hosts without network, broadcast or duplicates, got %v", hosts)
+	}
+
+	for _, bad := range [][]string{{"10.0.0.0/24"}, {"fd00::/120"}, {"not-a-cidr"}, {"10.0.0.0/28", "10.0.1.0/28"}} {
+		if _, err := Hosts(bad, 16); err == nil {
+			t.Errorf("Expected %v to be refused", bad)
+		}
+	}
+}
+
+// respond is a robot's answer to the beacon.
+const respond = `{"type":"discover_response","uuid":"lamp-7","device_type":"lamp","public_key":"abcd","name":"Hall lamp"}`
+
+func TestScan(t *testing.T) {
+	udp, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == `{"type":"discover"}` {
+				udp.WriteTo([]byte(respond), from)
+			}
+		}
+	}()
+
+	tcp, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			if line, _ := bufio.NewReader(conn).ReadString('\n'); line == "DISCOVER\n" {
+				conn.Write([]byte(`{"type":"discover_response","uuid":"rover-2","device_type":"rover"}` + "\n"))
+			}
+			conn.Close()
+		}
+	}()
+
+	udpPort := udp.LocalAddr().(*net.UDPAddr).Port
+	tcpPort := tcp.Addr().(*net.TCPAddr).Port
+	found, err := Scan(context.Background(), Options{
+		CIDRs:       []string{"127.0.0.1"},
+		UDPPort:     udpPort,
+		TCPPorts:    []int{tcpPort},
+		Timeout:     500 * time.Millisecond,
+		Concurrency: 4,
+		MaxHosts:    16,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("Expected a UDP and a TCP candidate, got %+v", found)
+	}
+	byVia := map[string]Candidate{found[0].Via: found[0], found[1].Via: found[1]}
+	if c := byVia["udp"]; c.UUID != "lamp-7" || c.Port != udpPort || !c.Provisionable() {
+		t.Errorf("Unexpected beacon candidate: %+v", c)
+	}
+	if c := byVia["tcp"]; c.UUID != "rover-2" || c.Port != tcpPort || c.Provisionable() {
+		t.Errorf("Unexpected TCP candidate: %+v", c)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	lamp := Candidate{IP: "10.0.0.7", Port: 5002, Via: "udp", UUID: "lamp-7", DeviceType: "lamp", PublicKey: "abcd"}
+	probe := Candidate{IP: "10.0.0.7", Port: 5003, Via: "tcp"}
+	if added := s.Merge([]Candidate{lamp, probe}, now.Add(-time.Hour)); len(added) != 2 {
+		t.Fatalf("Expected both candidates to be new, got %+v", added)
+	}
+	if added := s.Merge([]Candidate{lamp}, now); len(added) != 0 {
+		t.Errorf("Expected a seen candidate not to be new, got %+v", added)
+	}
+	got, ok := s.Lookup("10.0.0.7")
+	if !ok || got.UUID != "lamp-7" || got.FirstSeen != now.Add(-time.Hour).Unix() || got.LastSeen != now.Unix() {
+		t.Errorf("Expected the provisionable candidate with its first sighting kept, got %+v", got)
+	}
+
+	s.Merge(nil, now.Add(25*time.Hour))
+	if list := s.List(); len(list) != 0 {
+		t.Errorf("Expected candidates to expire after discovery.keep, got %+v", list)
+	}
+}
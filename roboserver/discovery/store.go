@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"roboserver/shared"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Found holds the candidates of this node's scans.
+var Found = NewStore()
+
+// Store keeps discovered devices by address and port until they have not
+// been seen for discovery.keep.
+type Store struct {
+	mu         sync.Mutex
+	candidates map[string]Candidate
+}
+
+func NewStore() *Store {
+	return &Store{candidates: make(map[string]Candidate)}
+}
+
+func storeKey(c Candidate) string { return c.Via + " " + c.IP + " " + strconv.Itoa(c.Port) }
+
+// Merge records a scan's candidates as seen at now and returns those that
+// were not listed before.
+func (s *Store) Merge(found []Candidate, now time.Time) []Candidate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+	var added []Candidate
+	for _, c := range found {
+		key := storeKey(c)
+		c.LastSeen = now.Unix()
+		if prev, ok := s.candidates[key]; ok {
+			c.FirstSeen = prev.FirstSeen
+		} else {
+			c.FirstSeen = c.LastSeen
+			added = append(added, c)
+		}
+		s.candidates[key] = c
+	}
+	return added
+}
+
+// List returns the candidates seen within discovery.keep, by address.
+func (s *Store) List() []Candidate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	out := make([]Candidate, 0, len(s.candidates))
+	for _, c := range s.candidates {
+		out = append(out, c)
+	}
+	slices.SortFunc(out, byAddress)
+	return out
+}
+
+// Lookup returns a listed candidate at ip (in any notation), preferring one
+// that can be provisioned.
+func (s *Store) Lookup(ip string) (Candidate, bool) {
+	ip = shared.CanonicalIP(ip)
+	var match Candidate
+	found := false
+	for _, c := range s.List() {
+		if shared.CanonicalIP(c.IP) == ip && (!found || (!match.Provisionable() && c.Provisionable())) {
+			match, found = c, true
+		}
+	}
+	return match, found
+}
+
+// Remove drops every candidate at ip, e.g. once it has been provisioned.
+func (s *Store) Remove(ip string) {
+	ip = shared.CanonicalIP(ip)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, c := range s.candidates {
+		if shared.CanonicalIP(c.IP) == ip {
+			delete(s.candidates, key)
+		}
+	}
+}
+
+// prune drops candidates not seen within discovery.keep. Caller holds s.mu.
+func (s *Store) prune(now time.Time) {
+	cutoff := now.Add(-shared.AppConfig.Discovery.KeepFor()).Unix()
+	for key, c := range s.candidates {
+		if c.LastSeen < cutoff {
+			delete(s.candidates, key)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package energy meters how much energy each robot uses per day. Robots
+// that measure their draw report it as telemetry:
+//
+//	DATA {"type":"power","metrics":{"watts":42.5}}
+//
+// and each reading is assumed to hold until the next one. Robots that
+// report nothing are estimated from their device type's nominal draw
+// (energy.estimate_watts) for the time their handler is connected. Usage is
+// summed in memory and added to PostgreSQL's robot_energy table every
+// energy.flush_interval.
+package energy
+
+import (
+	"context"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/telemetry"
+	"sync"
+	"time"
+)
+
+// maxGap caps how long one power reading is assumed to hold, so a robot
+// that goes quiet isn't billed for the whole silence at its last draw.
+const maxGap = 5 * time.Minute
+
+type reading struct {
+	watts float64
+	at    time.Time
+}
+
+type usageKey struct {
+	uuid string
+	day  string
+}
+
+// Meter accumulates energy use until it is drained to the database.
+type Meter struct {
+	mu      sync.Mutex
+	last    map[string]reading // last power reading per robot
+	pending map[usageKey]*database.EnergyUsage
+}
+
+func NewMeter() *Meter {
+	return &Meter{
+		last:    make(map[string]reading),
+		pending: make(map[usageKey]*database.EnergyUsage),
+	}
+}
+
+// Report records a power reading taken at at. The energy since the robot's
+// previous reading (at that reading's draw, for up to maxGap) is added to
+// the day of at.
+func (m *Meter) Report(uuid, deviceType string, watts float64, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.last[uuid]; ok && at.After(prev.at) {
+		gap := min(at.Sub(prev.at), maxGap)
+		m.add(database.EnergyUsage{
+			UUID:       uuid,
+			Day:        day(at),
+			DeviceType: deviceType,
+			ReportedWh: prev.watts * gap.Hours(),
+		})
+	}
+	m.last[uuid] = reading{watts: max(watts, 0), at: at}
+}
+
+// Estimate adds watts drawn for d, ending at at, for a robot that reports
+// no power.
+func (m *Meter) Estimate(uuid, deviceType string, watts float64, d time.Duration, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(database.EnergyUsage{
+		UUID:        uuid,
+		Day:         day(at),
+		DeviceType:  deviceType,
+		EstimatedWh: watts * d.Hours(),
+	})
+}
+
+// Reporting reports whether uuid sent a power reading within maxGap of now.
+func (m *Meter) Reporting(uuid string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.last[uuid]
+	return ok && now.Sub(r.at) < maxGap
+}
+
+// Drain returns the usage accumulated since the last Drain and forgets
+// readings too old to integrate.
+func (m *Meter) Drain(now time.Time) []database.EnergyUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := make([]database.EnergyUsage, 0, len(m.pending))
+	for _, u := range m.pending {
+		usage = append(usage, *u)
+	}
+	clear(m.pending)
+	for uuid, r := range m.last {
+		if now.Sub(r.at) >= maxGap {
+			delete(m.last, uuid)
+		}
+	}
+	return usage
+}
+
+// Restore puts back usage that could not be written, to retry on the next
+// flush.
+func (m *Meter) Restore(usage []database.EnergyUsage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range usage {
+		m.add(u)
+	}
+}
+
+// add merges u into the pending usage. Caller holds m.mu.
+func (m *Meter) add(u database.EnergyUsage) {
+	k := usageKey{u.UUID, u.Day}
+	p, ok := m.pending[k]
+	if !ok {
+		m.pending[k] = &u
+		return
+	}
+	p.ReportedWh += u.ReportedWh
+	p.EstimatedWh += u.EstimatedWh
+	if u.DeviceType != "" {
+		p.DeviceType = u.DeviceType
+	}
+}
+
+func day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// readingQueueSize bounds power readings waiting for the meter.
+const readingQueueSize = 1024
+
+type powerReading struct {
+	uuid  string
+	watts float64
+	at    time.Time
+}
+
+// Watch meters power telemetry published on this node and estimates the
+// rest, flushing to pg every energy.flush_interval until ctx is cancelled.
+// Readings relayed from other cluster nodes are skipped: the node that holds
+// the robot meters it.
+func Watch(ctx context.Context, bus comms.Bus, pg *database.PostgresHandler, m *Meter) error {
+	cfg := &shared.AppConfig.Energy
+	queue := make(chan powerReading, readingQueueSize)
+	// The matching handler runs on the publisher's goroutine, so metering
+	// happens on a worker instead.
+	cancel, err := bus.SubscribeMatching(isTelemetry, func(eventType string, data any) {
+		env, ok := data.(telemetry.Envelope)
+		if !ok || env.Type != cfg.TelemetryType {
+			return
+		}
+		watts, ok := env.Metrics[cfg.Metric]
+		if !ok {
+			return
+		}
+		uuid, _, _ := events.ParseRobotTopic(eventType)
+		select {
+		case queue <- powerReading{uuid: uuid, watts: watts, at: time.Now()}:
+		default:
+			shared.DebugPrint("Energy queue full, dropping a reading for %s", uuid)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	interval := cfg.FlushEvery()
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+				flush(flushCtx, pg, m, time.Now())
+				done()
+				return
+			case r := <-queue:
+				m.Report(r.uuid, deviceType(r.uuid), r.watts, r.at)
+			case now := <-ticker.C:
+				estimate(m, cfg.EstimateWatts, interval, now)
+				flush(ctx, pg, m, now)
+			}
+		}
+	}()
+	return nil
+}
+
+func isTelemetry(eventType string) bool {
+	_, kind, ok := events.ParseRobotTopic(eventType)
+	return ok && kind == "telemetry"
+}
+
+func deviceType(uuid string) string {
+	if hp, ok := handler_engine.HandlerManager.Get(uuid); ok {
+		return hp.DeviceType
+	}
+	return ""
+}
+
+// estimate bills each connected robot without power readings its device
+// type's nominal draw for the last interval.
+func estimate(m *Meter, watts map[string]float64, interval time.Duration, now time.Time) {
+	if len(watts) == 0 {
+		return
+	}
+	for _, s := range handler_engine.HandlerManager.Snapshots() {
+		w := watts[s.DeviceType]
+		if w <= 0 || !s.Connected || m.Reporting(s.UUID, now) {
+			continue
+		}
+		m.Estimate(s.UUID, s.DeviceType, w, interval, now)
+	}
+}
+
+func flush(ctx context.Context, pg *database.PostgresHandler, m *Meter, now time.Time) {
+	usage := m.Drain(now)
+	if len(usage) == 0 || pg == nil {
+		return
+	}
+	if err := pg.AddEnergyUsage(ctx, usage); err != nil {
+		shared.DebugPrint("Failed to store energy usage (will retry): %v", err)
+		m.Restore(usage)
+	}
+}
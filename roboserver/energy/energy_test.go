@@ -0,0 +1,88 @@
+package energy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestReportIntegratesReadings(t *testing.T) {
+	m := NewMeter()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	m.Report("r1", "rover", 60, start) // first reading: nothing to integrate yet
+	if usage := m.Drain(start); len(usage) != 0 {
+		t.Fatalf("Expected no usage after one reading, got %+v", usage)
+	}
+
+	m.Report("r1", "rover", 120, start.Add(time.Minute)) // 60 W for 1 min = 1 Wh
+	m.Report("r1", "rover", 0, start.Add(2*time.Minute)) // 120 W for 1 min = 2 Wh
+	usage := m.Drain(start.Add(2 * time.Minute))
+	if len(usage) != 1 {
+		t.Fatalf("Expected one day of usage, got %+v", usage)
+	}
+	u := usage[0]
+	if u.UUID != "r1" || u.Day != "2026-03-01" || u.DeviceType != "rover" || !near(u.ReportedWh, 3) {
+		t.Errorf("Expected 3 Wh reported for r1 on 2026-03-01, got %+v", u)
+	}
+}
+
+func TestReportCapsGaps(t *testing.T) {
+	m := NewMeter()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.Report("r1", "rover", 60, start)
+	m.Report("r1", "rover", 60, start.Add(time.Hour)) // silence counts as maxGap only
+	usage := m.Drain(start.Add(time.Hour))
+	if len(usage) != 1 || !near(usage[0].ReportedWh, 60*maxGap.Hours()) {
+		t.Errorf("Expected the gap to be capped at %v, got %+v", maxGap, usage)
+	}
+}
+
+func TestEstimateAndReporting(t *testing.T) {
+	m := NewMeter()
+	now := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	if m.Reporting("r1", now) {
+		t.Error("Expected a robot without readings not to be reporting")
+	}
+	m.Estimate("r1", "arm", 30, time.Minute, now)
+	m.Estimate("r1", "arm", 30, time.Minute, now)
+	m.Estimate("r1", "arm", 30, time.Minute, now.Add(2*time.Minute)) // next UTC day
+
+	usage := m.Drain(now)
+	if len(usage) != 2 {
+		t.Fatalf("Expected usage on two days, got %+v", usage)
+	}
+	for _, u := range usage {
+		want := 1.0 // 2 × 30 W for a minute
+		if u.Day == "2026-03-02" {
+			want = 0.5
+		}
+		if !near(u.EstimatedWh, want) || u.ReportedWh != 0 {
+			t.Errorf("%s: expected %v Wh estimated, got %+v", u.Day, want, u)
+		}
+	}
+
+	m.Report("r1", "arm", 10, now)
+	if !m.Reporting("r1", now.Add(time.Minute)) {
+		t.Error("Expected a recent reading to count as reporting")
+	}
+	m.Drain(now.Add(maxGap))
+	if m.Reporting("r1", now.Add(maxGap)) {
+		t.Error("Expected Drain to forget stale readings")
+	}
+}
+
+func TestRestoreMergesUsage(t *testing.T) {
+	m := NewMeter()
+	now := time.Now()
+	m.Estimate("r1", "arm", 60, time.Hour, now)
+	usage := m.Drain(now)
+	m.Estimate("r1", "arm", 60, time.Hour, now)
+	m.Restore(usage)
+	again := m.Drain(now)
+	if len(again) != 1 || !near(again[0].EstimatedWh, 120) {
+		t.Errorf("Expected restored usage merged with new usage, got %+v", again)
+	}
+}
@@ -0,0 +1,31 @@
+package exporter
+
+import "encoding/binary"
+
+// AvroSchema describes exported records in the avro format. Data is the
+// event payload as a JSON string, since event payloads have no fixed shape.
+const AvroSchema = `{"type":"record","name":"Event","namespace":"robomesh","fields":[` +
+	`{"name":"type","type":"string"},` +
+	`{"name":"time","type":"long"},` +
+	`{"name":"data","type":"string"}]}`
+
+// encodeAvro returns the Avro binary encoding of a record (no container or
+// schema header).
+func encodeAvro(rec Record) []byte {
+	buf := make([]byte, 0, len(rec.Type)+len(rec.Data)+3*binary.MaxVarintLen64)
+	buf = appendAvroString(buf, rec.Type)
+	buf = binary.AppendVarint(buf, rec.Time) // Avro longs are zigzag varints, as is AppendVarint
+	buf = appendAvroString(buf, string(rec.Data))
+	return buf
+}
+
+func appendAvroString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+// avroValue is the JSON form of a record that a schema-aware consumer (such
+// as the Kafka REST Proxy) encodes with AvroSchema.
+func avroValue(rec Record) map[string]any {
+	return map[string]any{"type": rec.Type, "time": rec.Time, "data": string(rec.Data)}
+}
@@ -0,0 +1,145 @@
+// Package exporter forwards selected event bus events to an external broker
+// (NATS or Kafka) so data pipelines can consume robot telemetry without
+// querying the server's databases.
+//
+// Export is best effort: events are queued in memory and dropped when the
+// queue is full or the broker rejects a batch, so a slow or unreachable
+// broker never stalls the event bus.
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"roboserver/shared"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatAvro = "avro"
+
+	maxBatch = 100
+)
+
+// Record is one exported event. It is serialized as JSON, or as Avro with
+// AvroSchema (Data then holds the event payload as a JSON string).
+type Record struct {
+	Type string          `json:"type"`
+	Time int64           `json:"time"` // unix milliseconds
+	Data json.RawMessage `json:"data"`
+}
+
+// Sink delivers batches of records to a broker.
+type Sink interface {
+	Send(ctx context.Context, batch []Record) error
+	Close() error
+}
+
+type Exporter_t struct {
+	sink  Sink
+	match func(eventType string) bool
+	queue chan Record
+}
+
+// Start runs the exporter configured in shared.AppConfig.Exporter until ctx
+// is cancelled. It returns immediately when export is disabled.
+func Start(ctx context.Context, eb event_bus.EventBus) error {
+	cfg := shared.AppConfig.Exporter
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Format != FormatJSON && cfg.Format != FormatAvro {
+		return fmt.Errorf("exporter: unknown format %q", cfg.Format)
+	}
+
+	var sink Sink
+	switch cfg.Backend {
+	case "nats":
+		s, err := newNATSSink(cfg.URL, cfg.Topic, cfg.Format)
+		if err != nil {
+			return err
+		}
+		sink = s
+	case "kafka":
+		sink = newKafkaSink(cfg.URL, cfg.Topic, cfg.Format)
+	default:
+		return fmt.Errorf("exporter: unknown backend %q", cfg.Backend)
+	}
+
+	e := New(sink, cfg.Events, cfg.Buffer)
+	cancel := eb.Tap(e.handle)
+	defer cancel()
+
+	shared.DebugPrint("Exporting events %v to %s (%s, %s)", cfg.Events, cfg.Backend, cfg.Topic, cfg.Format)
+	e.Run(ctx)
+	return nil
+}
+
+// New creates an exporter for events matching patterns (exact types or
+// "prefix.*"), queueing up to buffer events.
+func New(sink Sink, patterns []string, buffer int) *Exporter_t {
+	if buffer <= 0 {
+		buffer = 1024
+	}
+	return &Exporter_t{
+		sink:  sink,
+		match: events.Matcher(patterns),
+		queue: make(chan Record, buffer),
+	}
+}
+
+// handle is the event bus tap; it runs on the publisher's goroutine, so it
+// only serializes and enqueues.
+func (e *Exporter_t) handle(event event_bus.Event) {
+	// Events relayed from other instances were exported where they happened.
+	if event_bus.IsRemote(event) || !e.match(event.GetType()) {
+		return
+	}
+	data, err := json.Marshal(event.GetData())
+	if err != nil {
+		return
+	}
+	select {
+	case e.queue <- Record{Type: event.GetType(), Time: time.Now().UnixMilli(), Data: data}:
+	default:
+		shared.DebugPrint("Exporter queue full, dropping event: %s", event.GetType())
+	}
+}
+
+// Run sends queued records in batches until ctx is cancelled, then closes
+// the sink.
+func (e *Exporter_t) Run(ctx context.Context) {
+	defer e.sink.Close()
+
+	batch := make([]Record, 0, maxBatch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case rec := <-e.queue:
+			batch = append(batch[:0], rec)
+		}
+	drain:
+		for len(batch) < maxBatch {
+			select {
+			case rec := <-e.queue:
+				batch = append(batch, rec)
+			default:
+				break drain
+			}
+		}
+
+		if err := e.sink.Send(ctx, batch); err != nil {
+			shared.DebugPrint("Exporter dropped %d events: %v", len(batch), err)
+			// Don't hammer a broker that is down
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
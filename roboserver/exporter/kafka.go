@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kafkaSink produces to Kafka through a Confluent-compatible REST Proxy
+// (POST /topics/{topic}, v2 API), which keeps a Kafka client library out of
+// the server. Records are keyed by event type so one type stays ordered
+// within its partition.
+type kafkaSink struct {
+	endpoint string
+	format   string
+	client   *http.Client
+}
+
+func newKafkaSink(baseURL, topic, format string) *kafkaSink {
+	return &kafkaSink{
+		endpoint: strings.TrimSuffix(baseURL, "/") + "/topics/" + url.PathEscape(topic),
+		format:   format,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+func (k *kafkaSink) Send(ctx context.Context, batch []Record) error {
+	records := make([]kafkaRecord, len(batch))
+	for i, rec := range batch {
+		records[i] = kafkaRecord{Key: rec.Type, Value: rec}
+		if k.format == FormatAvro {
+			records[i].Value = avroValue(rec)
+		}
+	}
+
+	body := map[string]any{"records": records}
+	contentType := "application/vnd.kafka.json.v2+json"
+	if k.format == FormatAvro {
+		body["key_schema"] = `"string"`
+		body["value_schema"] = AvroSchema
+		contentType = "application/vnd.kafka.avro.v2+json"
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka rest proxy: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (k *kafkaSink) Close() error {
+	k.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsSink publishes each record to "<prefix>.<event type>" using the NATS
+// client text protocol (CONNECT / PUB / PING / PONG). The connection is made
+// lazily and re-dialled after any error.
+type natsSink struct {
+	addr   string
+	user   string
+	pass   string
+	prefix string
+	format string
+
+	mu   sync.Mutex // guards conn and w; the reader goroutine writes PONGs
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+func newNATSSink(rawURL, prefix, format string) (*natsSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("exporter: invalid NATS url %q (want nats://host:port)", rawURL)
+	}
+	s := &natsSink{addr: u.Host, prefix: prefix, format: format}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		s.user = u.User.Username()
+		s.pass, _ = u.User.Password()
+	}
+	return s, nil
+}
+
+// subject maps an event type to a NATS subject. Event types are already
+// dot-separated; whitespace is not allowed in subjects.
+func (s *natsSink) subject(eventType string) string {
+	subject := strings.Join(strings.Fields(eventType), "_")
+	if s.prefix != "" {
+		subject = s.prefix + "." + subject
+	}
+	return subject
+}
+
+func (s *natsSink) Send(ctx context.Context, batch []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range batch {
+		payload := []byte(nil)
+		if s.format == FormatAvro {
+			payload = encodeAvro(rec)
+		} else {
+			payload, _ = json.Marshal(rec)
+		}
+		fmt.Fprintf(s.w, "PUB %s %d\r\n", s.subject(rec.Type), len(payload))
+		s.w.Write(payload)
+		s.w.WriteString("\r\n")
+	}
+	if err := s.w.Flush(); err != nil {
+		s.closeLocked()
+		return err
+	}
+	return nil
+}
+
+// connect performs the INFO / CONNECT handshake, confirmed with a PING/PONG
+// round trip, and starts the reader. Called with s.mu held.
+func (s *natsSink) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("nats: expected INFO, got %q (%v)", strings.TrimSpace(line), err)
+	}
+
+	opts := map[string]any{"verbose": false, "pedantic": false, "name": "roboserver", "lang": "go", "version": "1"}
+	if s.user != "" {
+		opts["user"] = s.user
+		opts["pass"] = s.pass
+	}
+	connectJSON, _ := json.Marshal(opts)
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "CONNECT %s\r\nPING\r\n", connectJSON)
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	line, err = r.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "PONG" {
+		conn.Close()
+		return fmt.Errorf("nats: connect rejected: %q (%v)", strings.TrimSpace(line), err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.conn, s.w = conn, w
+	go s.readLoop(conn, r)
+	return nil
+}
+
+// readLoop answers server PINGs and drops the connection on -ERR or EOF so
+// the next Send re-dials.
+func (s *natsSink) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			s.mu.Lock()
+			if s.conn == conn {
+				s.w.WriteString("PONG\r\n")
+				s.w.Flush()
+			}
+			s.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			err = fmt.Errorf("nats: %s", line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	if s.conn == conn {
+		s.closeLocked()
+	}
+	s.mu.Unlock()
+}
+
+func (s *natsSink) closeLocked() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn, s.w = nil, nil
+	}
+}
+
+func (s *natsSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+	return nil
+}
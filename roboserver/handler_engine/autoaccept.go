@@ -0,0 +1,63 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/autoaccept"
+	"time"
+)
+
+// AutoAcceptSettings returns the auto-accept settings in force: those set
+// at runtime, shared by every cluster node through rds, or else the
+// configured auto_accept.
+func AutoAcceptSettings(ctx context.Context, rds *database.RedisHandler) (autoaccept.Settings, error) {
+	if rds != nil {
+		s, err := rds.GetAutoAccept(ctx)
+		if err != nil {
+			return autoaccept.Settings{}, err
+		}
+		if s != nil {
+			s.Source = autoaccept.SourceRuntime
+			return *s, nil
+		}
+	}
+	return autoaccept.Settings{AutoAcceptConfig: shared.AppConfig.AutoAccept, Source: autoaccept.SourceConfig}, nil
+}
+
+// SetAutoAccept replaces the auto-accept settings at runtime, after
+// checking them. They stay until ResetAutoAccept.
+func SetAutoAccept(ctx context.Context, rds *database.RedisHandler, cfg shared.AutoAcceptConfig, by string) (autoaccept.Settings, error) {
+	if _, err := autoaccept.Compile(cfg); err != nil {
+		return autoaccept.Settings{}, err
+	}
+	s := autoaccept.Settings{AutoAcceptConfig: cfg, Source: autoaccept.SourceRuntime, By: by, At: time.Now().Unix()}
+	if err := rds.SetAutoAccept(ctx, &s); err != nil {
+		return autoaccept.Settings{}, err
+	}
+	shared.DebugPrint("Auto-accept policies set to %v by %s", cfg.Policies, by)
+	return s, nil
+}
+
+// ResetAutoAccept drops the runtime auto-accept settings, so the
+// configured ones apply again, and reports whether there were any.
+func ResetAutoAccept(ctx context.Context, rds *database.RedisHandler) (bool, error) {
+	return rds.ClearAutoAccept(ctx)
+}
+
+// AutoAcceptPolicy returns the auto-accept policy that admits a robot
+// registering as uuid and deviceType from ip, or "" if an operator has to
+// approve it. Settings that can't be read or compiled admit no one.
+func AutoAcceptPolicy(ctx context.Context, rds *database.RedisHandler, uuid, deviceType, ip string) string {
+	s, err := AutoAcceptSettings(ctx, rds)
+	if err != nil {
+		shared.DebugPrint("Failed to read auto-accept settings, waiting for approval: %v", err)
+		return ""
+	}
+	p, err := autoaccept.Compile(s.AutoAcceptConfig)
+	if err != nil {
+		shared.DebugPrint("Invalid %s auto-accept settings, waiting for approval: %v", s.Source, err)
+		return ""
+	}
+	return p.Match(uuid, deviceType, ip)
+}
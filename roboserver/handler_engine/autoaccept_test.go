@@ -0,0 +1,35 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/shared"
+	"roboserver/shared/autoaccept"
+	"testing"
+)
+
+func TestAutoAcceptPolicyUsesConfigWithoutRedis(t *testing.T) {
+	saved := shared.AppConfig.AutoAccept
+	defer func() { shared.AppConfig.AutoAccept = saved }()
+	ctx := context.Background()
+
+	shared.AppConfig.AutoAccept = shared.AutoAcceptConfig{
+		Policies:    []string{autoaccept.DeviceTypes},
+		DeviceTypes: []string{"lamp"},
+	}
+	s, err := AutoAcceptSettings(ctx, nil)
+	if err != nil || s.Source != autoaccept.SourceConfig {
+		t.Fatalf("AutoAcceptSettings = %+v, %v; want the config", s, err)
+	}
+	if got := AutoAcceptPolicy(ctx, nil, "lamp-1", "lamp", "10.0.0.5"); got != autoaccept.DeviceTypes {
+		t.Errorf("Expected lamp-1 admitted by device_types, got %q", got)
+	}
+	if got := AutoAcceptPolicy(ctx, nil, "rover-1", "rover", "10.0.0.5"); got != "" {
+		t.Errorf("Expected rover-1 to wait for approval, got %q", got)
+	}
+
+	// Settings that don't compile admit no one.
+	shared.AppConfig.AutoAccept = shared.AutoAcceptConfig{Policies: []string{autoaccept.KnownIDs}}
+	if got := AutoAcceptPolicy(ctx, nil, "lamp-1", "lamp", "10.0.0.5"); got != "" {
+		t.Errorf("Expected invalid settings to admit no one, got %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package handler_engine
+
+import (
+	"context"
+	"sync"
+)
+
+// broadcastWorkers bounds how many handlers a broadcast writes to at once,
+// so one busy handler can't hold up the rest of the fleet.
+const broadcastWorkers = 8
+
+// Broadcast sends payload to every running handler that filter accepts
+// (nil = all), as SendIncomingAsContext would, waiting up to
+// DefaultSendTimeout per handler. The result maps each targeted UUID to
+// its send error, nil on success.
+func (m *handlerManager) Broadcast(ctx context.Context, payload, actor string, filter func(HandlerSnapshot) bool) map[string]error {
+	m.mu.RLock()
+	targets := make([]*HandlerProcess, 0, len(m.handlers))
+	for _, hp := range m.handlers {
+		targets = append(targets, hp)
+	}
+	m.mu.RUnlock()
+
+	if filter != nil {
+		kept := targets[:0]
+		for _, hp := range targets {
+			if filter(hp.Snapshot()) {
+				kept = append(kept, hp)
+			}
+		}
+		targets = kept
+	}
+
+	results := make(map[string]error, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan *HandlerProcess)
+	for i := 0; i < broadcastWorkers && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hp := range jobs {
+				sendCtx, cancel := context.WithTimeout(ctx, DefaultSendTimeout)
+				err := hp.SendIncomingAsContext(sendCtx, payload, actor)
+				cancel()
+				mu.Lock()
+				results[hp.UUID] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, hp := range targets {
+		jobs <- hp
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,38 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBroadcastFiltersAndReportsPerHandler(t *testing.T) {
+	m := &handlerManager{handlers: make(map[string]*HandlerProcess), spawning: make(map[string]bool)}
+	lamp := &HandlerProcess{UUID: "lamp-1", DeviceType: "lamp", writeCh: make(chan []byte, 4)}
+	stopped := &HandlerProcess{UUID: "lamp-2", DeviceType: "lamp", writeCh: make(chan []byte, 4), closed: true}
+	arm := &HandlerProcess{UUID: "arm-1", DeviceType: "arm", writeCh: make(chan []byte, 4)}
+	for _, hp := range []*HandlerProcess{lamp, stopped, arm} {
+		m.Register(hp)
+	}
+
+	results := m.Broadcast(context.Background(), "report", "alice", func(s HandlerSnapshot) bool {
+		return s.DeviceType == "lamp"
+	})
+
+	if len(results) != 2 {
+		t.Fatalf("Expected the two lamps to be targeted, got %v", results)
+	}
+	if err := results["lamp-1"]; err != nil {
+		t.Errorf("Expected lamp-1 to receive the message, got %v", err)
+	}
+	if err := results["lamp-2"]; !errors.Is(err, ErrHandlerStopped) {
+		t.Errorf("Expected ErrHandlerStopped for lamp-2, got %v", err)
+	}
+	if got := string(<-lamp.writeCh); !strings.Contains(got, `"payload":"report"`) || !strings.Contains(got, `"actor":"alice"`) {
+		t.Errorf("Unexpected message written: %s", got)
+	}
+	if len(arm.writeCh) != 0 {
+		t.Error("Expected the filtered-out handler to receive nothing")
+	}
+}
@@ -0,0 +1,90 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"roboserver/comms"
+	"roboserver/shared/utils"
+	"time"
+)
+
+// Reply is what a handler publishes on {device_type}.{uuid}.reply to
+// answer a command that carried a request_id (see the bundled handlers'
+// reply helpers).
+type Reply struct {
+	UUID      string `json:"uuid"`
+	RequestID string `json:"request_id"`
+	Data      any    `json:"data"`
+	Error     string `json:"error"`
+}
+
+// ReplyTopic is the event type a handler of deviceType publishes its
+// replies on.
+func ReplyTopic(deviceType, uuid string) string {
+	return deviceType + "." + uuid + ".reply"
+}
+
+// decodeReply reads a Reply published by a handler (decoded JSON) or by Go
+// code.
+func decodeReply(data any) (Reply, bool) {
+	switch v := data.(type) {
+	case Reply:
+		return v, true
+	case *Reply:
+		if v == nil {
+			return Reply{}, false
+		}
+		return *v, true
+	case map[string]any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return Reply{}, false
+		}
+		var r Reply
+		if json.Unmarshal(raw, &r) != nil {
+			return Reply{}, false
+		}
+		return r, true
+	}
+	return Reply{}, false
+}
+
+// Call sends the handler the command message {"command": command,
+// "request_id": ..., params...} and waits up to timeout for its reply. With
+// timeout <= 0 it only sends and returns a nil Reply. A message the handler
+// accepted but never answered returns comms.ErrRequestTimeout.
+func (hp *HandlerProcess) Call(ctx context.Context, command string, params map[string]any, timeout time.Duration) (*Reply, error) {
+	requestID := "term-" + utils.GenerateRandomString(12)
+	msg := make(map[string]any, len(params)+2)
+	maps.Copy(msg, params)
+	msg["command"] = command
+	msg["request_id"] = requestID
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	send := func() error {
+		sendCtx, cancel := context.WithTimeout(ctx, DefaultSendTimeout)
+		defer cancel()
+		return hp.SendIncomingContext(sendCtx, string(payload))
+	}
+	if timeout <= 0 {
+		return nil, send()
+	}
+	if hp.bus == nil {
+		return nil, errors.New("event bus not available")
+	}
+
+	data, err := comms.Await(ctx, hp.bus, ReplyTopic(hp.DeviceType, hp.UUID), timeout, send, func(data any) bool {
+		r, ok := decodeReply(data)
+		return ok && r.RequestID == requestID
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, _ := decodeReply(data)
+	return &r, nil
+}
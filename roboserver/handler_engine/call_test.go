@@ -0,0 +1,51 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"roboserver/comms"
+	"roboserver/shared/event_bus"
+	"testing"
+	"time"
+)
+
+func TestCallWaitsForMatchingReply(t *testing.T) {
+	bus := comms.NewLocalBus(event_bus.NewEventBus(), nil)
+	hp := &HandlerProcess{UUID: "bot-1", DeviceType: "example_robot", bus: bus, writeCh: make(chan []byte, 4)}
+
+	go func() {
+		var in IncomingMessage
+		json.Unmarshal(<-hp.writeCh, &in)
+		var msg map[string]any
+		json.Unmarshal([]byte(in.Payload), &msg)
+		topic := ReplyTopic("example_robot", "bot-1")
+		// A reply to someone else's request is ignored.
+		bus.PublishEvent(topic, map[string]any{"uuid": "bot-1", "request_id": "other", "data": "wrong"})
+		bus.PublishEvent(topic, map[string]any{"uuid": "bot-1", "request_id": msg["request_id"], "data": map[string]any{"mode": msg["mode"]}})
+	}()
+
+	reply, err := hp.Call(context.Background(), "set_mode", map[string]any{"mode": "eco"}, time.Second)
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if data, _ := reply.Data.(map[string]any); data["mode"] != "eco" {
+		t.Errorf("Expected the matching reply, got %+v", reply)
+	}
+}
+
+func TestCallTimesOutWithoutReply(t *testing.T) {
+	hp := &HandlerProcess{UUID: "bot-1", DeviceType: "example_robot", bus: comms.NewLocalBus(event_bus.NewEventBus(), nil), writeCh: make(chan []byte, 4)}
+
+	_, err := hp.Call(context.Background(), "get_state", nil, 20*time.Millisecond)
+	if !errors.Is(err, comms.ErrRequestTimeout) {
+		t.Fatalf("Expected ErrRequestTimeout, got %v", err)
+	}
+	var in IncomingMessage
+	json.Unmarshal(<-hp.writeCh, &in)
+	var msg map[string]any
+	json.Unmarshal([]byte(in.Payload), &msg)
+	if msg["command"] != "get_state" || msg["request_id"] == "" {
+		t.Errorf("Expected a command message with a request_id, got %s", in.Payload)
+	}
+}
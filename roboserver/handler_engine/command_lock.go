@@ -0,0 +1,153 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"roboserver/shared"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRobotBusy is returned for a command sent while the robot's handler is
+// still working on an earlier one (see SendCommandContext).
+var ErrRobotBusy = errors.New("robot is busy with another command")
+
+// commandLock lets one command at a time through to a handler whose device
+// must not process overlapping commands (doors, valves). The holder is
+// released when the handler reports command_done or the lock times out.
+type commandLock struct {
+	mu        sync.Mutex
+	serialize time.Duration // lock every command for up to this long; 0 = off
+	holder    string        // lock id of the command in progress, "" = free
+	expires   time.Time
+	seq       uint64
+}
+
+// acquire fails with ErrRobotBusy while another command holds the lock.
+// Otherwise it takes the lock for up to timeout, or the serialization
+// timeout if that is longer, and returns "" without locking when neither
+// applies.
+func (l *commandLock) acquire(timeout time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.holder != "" && now.Before(l.expires) {
+		return "", ErrRobotBusy
+	}
+	l.holder = ""
+	timeout = max(timeout, l.serialize)
+	if timeout <= 0 {
+		return "", nil
+	}
+	l.seq++
+	l.holder = strconv.FormatUint(l.seq, 10)
+	l.expires = now.Add(timeout)
+	return l.holder, nil
+}
+
+// release frees the lock if id holds it ("" = whoever holds it).
+func (l *commandLock) release(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == "" || (id != "" && id != l.holder) {
+		return false
+	}
+	l.holder = ""
+	return true
+}
+
+// CommandLock describes a handler's command lock.
+type CommandLock struct {
+	Serialize time.Duration // 0 = commands are only locked on request
+	Holder    string        // lock id of the command in progress, "" = free
+	Expires   time.Time
+}
+
+// CommandLock reports the handler's serialization mode and the command in
+// progress, if any.
+func (hp *HandlerProcess) CommandLock() CommandLock {
+	l := &hp.cmdLock
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cl := CommandLock{Serialize: l.serialize}
+	if l.holder != "" && time.Now().Before(l.expires) {
+		cl.Holder = l.holder
+		cl.Expires = l.expires
+	}
+	return cl
+}
+
+// SetSerialized turns serialization mode on (every command holds the lock
+// for up to timeout) or off (timeout 0).
+func (hp *HandlerProcess) SetSerialized(timeout time.Duration) {
+	hp.cmdLock.mu.Lock()
+	hp.cmdLock.serialize = max(timeout, 0)
+	hp.cmdLock.mu.Unlock()
+}
+
+// ReleaseCommand frees the command lock held by id ("" = any holder) and
+// reports whether it was held.
+func (hp *HandlerProcess) ReleaseCommand(id string) bool {
+	return hp.cmdLock.release(id)
+}
+
+// SendCommandContext is SendIncomingAsContext for actuation requests. If
+// the handler is in serialization mode, or lock > 0, the command holds the
+// handler's command lock until the handler reports command_done (with the
+// message's lock_id) or the lock times out. Any operator message sent
+// meanwhile fails with ErrRobotBusy instead of interleaving. Every operator
+// message passes through here, so this is where handlers.allowed_commands is
+// enforced: a command the device type doesn't accept fails with a
+// *CommandNotAllowedError.
+func (hp *HandlerProcess) SendCommandContext(ctx context.Context, payload, actor string, lock time.Duration) error {
+	if err := checkIncomingSize(payload); err != nil {
+		return err
+	}
+	if err := CheckCommand(hp.DeviceType, payload); err != nil {
+		shared.DebugPrint("Handler %s: rejected command from %q: %v", hp.UUID, actor, err)
+		return err
+	}
+	id, err := hp.cmdLock.acquire(lock)
+	if err != nil {
+		return err
+	}
+	if err := hp.sendIncoming(ctx, payload, actor, id); err != nil {
+		if id != "" {
+			hp.cmdLock.release(id)
+		}
+		return err
+	}
+	return nil
+}
+
+// serializedDeviceType reports whether handlers.serialize lists deviceType.
+func serializedDeviceType(deviceType string) bool {
+	return slices.Contains(shared.AppConfig.Handlers.Serialize, deviceType)
+}
+
+// handleSerializeCommands handles the serialize_commands config method.
+// data: true (lock for handlers.command_timeout), a duration string such as
+// "10s", or false to turn serialization off.
+func (hp *HandlerProcess) handleSerializeCommands(env *JSONRPCEnvelope) {
+	var timeout time.Duration
+	switch v := env.Data.(type) {
+	case bool:
+		if v {
+			timeout = shared.AppConfig.Handlers.CommandLockTimeout()
+		}
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			hp.sendResponse(env.ID, nil, "data must be a positive duration such as \"10s\"")
+			return
+		}
+		timeout = d
+	default:
+		hp.sendResponse(env.ID, nil, "data must be a boolean or a duration string")
+		return
+	}
+	hp.SetSerialized(timeout)
+	hp.sendResponse(env.ID, timeout > 0, "")
+}
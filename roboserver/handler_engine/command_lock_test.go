@@ -0,0 +1,98 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSerializedCommandsReturnBusy(t *testing.T) {
+	hp := &HandlerProcess{UUID: "door-1", writeCh: make(chan []byte, 16)}
+	hp.SetSerialized(time.Minute)
+
+	if err := hp.SendCommandContext(context.Background(), "open", "", 0); err != nil {
+		t.Fatalf("Expected the first command through, got %v", err)
+	}
+	var msg IncomingMessage
+	json.Unmarshal(<-hp.writeCh, &msg)
+	if msg.LockID == "" {
+		t.Fatal("Expected the first command to carry a lock_id")
+	}
+
+	if err := hp.SendIncomingContext(context.Background(), "close"); !errors.Is(err, ErrRobotBusy) {
+		t.Fatalf("Expected ErrRobotBusy while the lock is held, got %v", err)
+	}
+	if len(hp.writeCh) != 0 {
+		t.Error("Expected the rejected command not to reach the handler")
+	}
+
+	if hp.ReleaseCommand("stale") {
+		t.Error("Expected a wrong lock_id not to release the lock")
+	}
+	if !hp.ReleaseCommand(msg.LockID) {
+		t.Fatal("Expected command_done to release the lock")
+	}
+	if err := hp.SendIncomingContext(context.Background(), "close"); err != nil {
+		t.Errorf("Expected the next command through after release, got %v", err)
+	}
+}
+
+func TestCommandLockTimesOut(t *testing.T) {
+	hp := &HandlerProcess{UUID: "valve-1", writeCh: make(chan []byte, 16)}
+
+	// Not serialized: only requests that ask for a lock take it
+	if err := hp.SendCommandContext(context.Background(), "a", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := hp.SendCommandContext(context.Background(), "b", "", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := hp.SendCommandContext(context.Background(), "c", "", 0); !errors.Is(err, ErrRobotBusy) {
+		t.Fatalf("Expected ErrRobotBusy under a requested lock, got %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := hp.SendCommandContext(context.Background(), "d", "", 0); err != nil {
+		t.Errorf("Expected the lock to expire, got %v", err)
+	}
+}
+
+func TestFailedSendReleasesLock(t *testing.T) {
+	hp := &HandlerProcess{UUID: "door-2", writeCh: make(chan []byte, 16), closed: true}
+	if err := hp.SendCommandContext(context.Background(), "open", "", time.Minute); !errors.Is(err, ErrHandlerStopped) {
+		t.Fatalf("Expected ErrHandlerStopped, got %v", err)
+	}
+	if cl := hp.CommandLock(); cl.Holder != "" {
+		t.Errorf("Expected an undelivered command not to hold the lock, got %+v", cl)
+	}
+}
+
+func TestSerializeCommandsConfigRequest(t *testing.T) {
+	hp := &HandlerProcess{UUID: "door-3", writeCh: make(chan []byte, 16)}
+	respond := func(method string, data any) JSONRPCEnvelope {
+		hp.handleConfigRequest(&JSONRPCEnvelope{ID: "1", Target: TargetConfig, Method: method, Data: data})
+		var resp JSONRPCEnvelope
+		json.Unmarshal(<-hp.writeCh, &resp)
+		return resp
+	}
+
+	if resp := respond("serialize_commands", "5s"); resp.Error != "" || resp.Data != true {
+		t.Fatalf("Expected serialization on, got %+v", resp)
+	}
+	if hp.CommandLock().Serialize != 5*time.Second {
+		t.Errorf("Expected a 5s lock, got %v", hp.CommandLock().Serialize)
+	}
+	if resp := respond("serialize_commands", "soon"); resp.Error == "" {
+		t.Error("Expected an invalid duration to be refused")
+	}
+
+	hp.SendIncomingContext(context.Background(), "open")
+	<-hp.writeCh
+	if resp := respond("command_done", nil); resp.Data != true {
+		t.Errorf("Expected command_done to release the lock, got %+v", resp)
+	}
+	if resp := respond("serialize_commands", false); resp.Data != false || hp.CommandLock().Serialize != 0 {
+		t.Errorf("Expected serialization off, got %+v", resp)
+	}
+}
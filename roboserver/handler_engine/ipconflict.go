@@ -0,0 +1,68 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"roboserver/comms"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/ipconflict"
+)
+
+// ReportIPConflict publishes c on events.IPConflict. It does nothing for a
+// nil conflict, so callers can pass auth.CheckIPConflict's result as is.
+func ReportIPConflict(bus comms.Bus, c *ipconflict.Conflict) {
+	if c == nil || bus == nil {
+		return
+	}
+	bus.PublishEvent(events.IPConflict, *c)
+}
+
+// WatchIPConflicts validates ip_conflict.policy and stops the handler of
+// every robot evicted by an IP conflict, on whichever cluster node runs it.
+func WatchIPConflicts(ctx context.Context, bus comms.Bus) error {
+	if _, err := ipconflict.ParsePolicy(shared.AppConfig.IPConflict.Policy); err != nil {
+		return err
+	}
+	if bus == nil {
+		return nil
+	}
+	cancel, err := bus.SubscribeEvent(events.IPConflict, func(_ string, data any) {
+		c, ok := decodeConflict(data)
+		if !ok || c.Action != ipconflict.Evicted {
+			return
+		}
+		if hp, ok := HandlerManager.Get(c.Existing); ok {
+			shared.DebugPrint("Robot %s evicted by %s (IP conflict), stopping its handler", c.Existing, c.UUID)
+			hp.Stop("ip_conflict")
+		}
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return nil
+}
+
+// decodeConflict reads a Conflict published locally or relayed from
+// another node (where it arrives as decoded JSON).
+func decodeConflict(data any) (ipconflict.Conflict, bool) {
+	switch v := data.(type) {
+	case ipconflict.Conflict:
+		return v, true
+	case map[string]any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ipconflict.Conflict{}, false
+		}
+		var c ipconflict.Conflict
+		if json.Unmarshal(raw, &c) != nil || c.Existing == "" {
+			return ipconflict.Conflict{}, false
+		}
+		return c, true
+	}
+	return ipconflict.Conflict{}, false
+}
@@ -0,0 +1,26 @@
+package handler_engine
+
+import (
+	"roboserver/shared/ipconflict"
+	"testing"
+)
+
+func TestDecodeConflict(t *testing.T) {
+	local := ipconflict.Conflict{IP: "10.0.0.5", UUID: "r2", Existing: "r1", Action: ipconflict.Evicted}
+	if c, ok := decodeConflict(local); !ok || c != local {
+		t.Errorf("Expected the typed conflict back, got %+v %v", c, ok)
+	}
+
+	relayed := map[string]any{"ip": "10.0.0.5", "uuid": "r2", "existing": "r1", "action": "evicted", "time": float64(1700000000)}
+	c, ok := decodeConflict(relayed)
+	if !ok || c.Existing != "r1" || c.Action != ipconflict.Evicted || c.Time != 1700000000 {
+		t.Errorf("Expected the relayed conflict decoded, got %+v %v", c, ok)
+	}
+
+	if _, ok := decodeConflict(map[string]any{"ip": "10.0.0.5"}); ok {
+		t.Error("Expected a payload without existing to be ignored")
+	}
+	if _, ok := decodeConflict("r1"); ok {
+		t.Error("Expected an unknown payload type to be ignored")
+	}
+}
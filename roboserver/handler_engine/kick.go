@@ -0,0 +1,93 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"time"
+)
+
+// ErrNotConnected is returned by Kick for a robot with neither a session
+// nor a handler.
+var ErrNotConnected = errors.New("robot is not connected")
+
+// KickOptions describe a forced disconnect.
+type KickOptions struct {
+	Reason string
+	By     string
+	Ban    time.Duration // keep the robot ID out for this long; 0 = no ban
+	BanIP  bool          // with Ban, also keep every address of the robot out
+}
+
+// Kick ends a robot's session on whichever node holds it: the session is
+// removed from Redis, the optional bans are stored, and events.RobotKicked
+// tells the transports to close the connection and the owning node to stop
+// the handler. It returns the bans it set.
+func Kick(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid string, opts KickOptions) ([]*database.Ban, error) {
+	var active *database.ActiveRobot
+	if rds != nil {
+		active, _ = rds.GetActiveRobot(ctx, uuid)
+	}
+	if active == nil && !HandlerManager.Has(uuid) {
+		return nil, ErrNotConnected
+	}
+
+	var bans []*database.Ban
+	if opts.Ban > 0 && rds != nil {
+		until := time.Now().Add(opts.Ban).Unix()
+		bans = append(bans, &database.Ban{Kind: database.BanUUID, Value: uuid, Reason: opts.Reason, By: opts.By, Until: until})
+		if opts.BanIP && active != nil {
+			for _, ip := range active.Addresses() {
+				bans = append(bans, &database.Ban{Kind: database.BanIP, Value: ip, Reason: opts.Reason, By: opts.By, Until: until})
+			}
+		}
+		for _, b := range bans {
+			if err := rds.SetBan(ctx, b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if active != nil {
+		if err := rds.RemoveActiveRobot(ctx, uuid); err != nil {
+			return bans, err
+		}
+	}
+
+	kick := events.Kick{UUID: uuid, Reason: opts.Reason, By: opts.By}
+	if bus != nil {
+		bus.PublishEvent(events.RobotKicked, kick)
+	} else {
+		stopKicked(kick)
+	}
+	return bans, nil
+}
+
+// WatchKicks stops the local handler of every kicked robot.
+func WatchKicks(ctx context.Context, bus comms.Bus) error {
+	cancel, err := bus.SubscribeEvent(events.RobotKicked, func(_ string, data any) {
+		if kick, ok := events.DecodeKick(data); ok {
+			stopKicked(kick)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return nil
+}
+
+func stopKicked(kick events.Kick) {
+	hp, ok := HandlerManager.Get(kick.UUID)
+	if !ok {
+		return
+	}
+	shared.DebugPrint("Robot %s kicked by %s, stopping its handler", kick.UUID, kick.By)
+	// Stop waits for the script to exit; don't hold up the publisher.
+	go hp.Stop("kicked")
+}
@@ -0,0 +1,13 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestKickNotConnected(t *testing.T) {
+	if _, err := Kick(context.Background(), nil, nil, "nobody", KickOptions{}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/maintenance"
+)
+
+// What HoldAutomated did with a message for a robot in maintenance.
+const (
+	HeldQueued     = "queued"
+	HeldSuppressed = "suppressed"
+)
+
+// WatchMaintenance loads the robots in maintenance from Redis and keeps
+// maintenance.Registry in step with robot.{uuid}.maintenance events, which
+// other cluster nodes relay. It returns an error for a bad
+// maintenance.suppress setting.
+func WatchMaintenance(ctx context.Context, bus comms.Bus, rds *database.RedisHandler) error {
+	if _, err := maintenance.ParsePolicy(shared.AppConfig.Maintenance.Suppress); err != nil {
+		return err
+	}
+	if rds != nil {
+		infos, err := rds.ListMaintenance(ctx)
+		if err != nil {
+			return err
+		}
+		maintenance.Registry.Replace(infos)
+	}
+	if bus == nil {
+		return nil
+	}
+	cancel, err := bus.SubscribeMatching(events.IsRobotMaintenance, func(_ string, data any) {
+		if ev, ok := maintenance.DecodeEvent(data); ok {
+			maintenance.Registry.Apply(ev)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return nil
+}
+
+// StartMaintenance puts a robot into maintenance mode.
+func StartMaintenance(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, info maintenance.Info) error {
+	if err := rds.SetMaintenance(ctx, info); err != nil {
+		return err
+	}
+	maintenance.Registry.Set(info)
+	if bus != nil {
+		bus.PublishEvent(events.RobotMaintenance(info.UUID), maintenance.Event{UUID: info.UUID, Active: true, Info: &info})
+	}
+	shared.DebugPrint("Robot %s entered maintenance (%s)", info.UUID, info.Reason)
+	return nil
+}
+
+// EndMaintenance takes a robot out of maintenance mode and delivers the
+// messages held for it, oldest first. found is false if the robot was not
+// in maintenance.
+func EndMaintenance(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid string) (delivered int, found bool, err error) {
+	found, err = rds.ClearMaintenance(ctx, uuid)
+	if err != nil {
+		return 0, false, err
+	}
+	maintenance.Registry.Clear(uuid)
+	if !found {
+		return 0, false, nil
+	}
+
+	held, err := rds.TakeMaintenanceQueue(ctx, uuid)
+	if err != nil {
+		shared.DebugPrint("Failed to read held messages for %s: %v", uuid, err)
+	}
+	for _, msg := range held {
+		if deliverHeld(ctx, bus, rds, uuid, msg) {
+			delivered++
+		}
+	}
+	if bus != nil {
+		bus.PublishEvent(events.RobotMaintenance(uuid), maintenance.Event{UUID: uuid, Delivered: delivered})
+	}
+	shared.DebugPrint("Robot %s left maintenance, delivered %d/%d held messages", uuid, delivered, len(held))
+	return delivered, true, nil
+}
+
+// deliverHeld sends a held message to the robot's handler, here or, in a
+// cluster, on the node holding its connection.
+func deliverHeld(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid, msg string) bool {
+	if hp, ok := HandlerManager.Get(uuid); ok {
+		return hp.SendIncomingContext(ctx, msg) == nil
+	}
+	if !shared.AppConfig.Cluster.Enabled || bus == nil {
+		return false
+	}
+	active, err := rds.GetActiveRobot(ctx, uuid)
+	if err != nil || active.Node == "" || active.Node == shared.NodeID() {
+		return false
+	}
+	return bus.PublishEvent(events.HandlerIncoming(uuid), events.ForwardedMessage{Message: msg}) == nil
+}
+
+// HoldAutomated applies maintenance.suppress to an automated message
+// (broadcast, quick action, rule, schedule) for uuid. It returns "" when the
+// robot is not in maintenance and the message should be sent as usual,
+// otherwise HeldQueued or HeldSuppressed.
+func HoldAutomated(ctx context.Context, rds *database.RedisHandler, uuid, msg string) string {
+	if !maintenance.Registry.Active(uuid) {
+		return ""
+	}
+	policy, _ := maintenance.ParsePolicy(shared.AppConfig.Maintenance.Suppress)
+	if policy == maintenance.Drop || rds == nil {
+		return HeldSuppressed
+	}
+	if err := rds.QueueMaintenanceMessage(ctx, uuid, msg, shared.AppConfig.Maintenance.QueueLen()); err != nil {
+		shared.DebugPrint("Failed to queue message for %s in maintenance: %v", uuid, err)
+		return HeldSuppressed
+	}
+	return HeldQueued
+}
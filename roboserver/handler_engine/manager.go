@@ -87,16 +87,27 @@ func (m *handlerManager) Kill(uuid string) error {
 // StopAll gracefully stops all running handlers.
 // Each Stop() call handles its own unregistration from the map.
 func (m *handlerManager) StopAll(reason string) {
+	for _, hp := range m.all() {
+		hp.Stop(reason)
+	}
+}
+
+// all returns the running handlers.
+func (m *handlerManager) all() []*HandlerProcess {
 	m.mu.RLock()
+	defer m.mu.RUnlock()
 	handlers := make([]*HandlerProcess, 0, len(m.handlers))
 	for _, v := range m.handlers {
 		handlers = append(handlers, v)
 	}
-	m.mu.RUnlock()
+	return handlers
+}
 
-	for _, hp := range handlers {
-		hp.Stop(reason)
-	}
+// Count returns the number of running handlers.
+func (m *handlerManager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.handlers)
 }
 
 // ListAll returns a snapshot of all running handler UUIDs and their PIDs.
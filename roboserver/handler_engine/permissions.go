@@ -0,0 +1,98 @@
+package handler_engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"roboserver/shared"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidCommand is matched (via errors.Is) by every
+// *CommandNotAllowedError.
+var ErrInvalidCommand = errors.New("command not allowed for this device type")
+
+// CommandNotAllowedError rejects an operator message whose command is not
+// in handlers.allowed_commands for the robot's device type.
+type CommandNotAllowedError struct {
+	DeviceType string
+	Command    string   // "" when the message names no command
+	Allowed    []string // the commands the type accepts
+}
+
+func (e *CommandNotAllowedError) Error() string {
+	allowed := "none"
+	if len(e.Allowed) > 0 {
+		allowed = strings.Join(e.Allowed, ", ")
+	}
+	if e.Command == "" {
+		return fmt.Sprintf("message names no command; %s robots accept: %s", e.DeviceType, allowed)
+	}
+	return fmt.Sprintf("command %q is not allowed for %s robots; allowed: %s", e.Command, e.DeviceType, allowed)
+}
+
+func (e *CommandNotAllowedError) Is(target error) bool {
+	return target == ErrInvalidCommand
+}
+
+// CommandName returns the command an operator message carries: the
+// "command" field of a JSON object, otherwise the first word of the text.
+// An object whose command is ambiguous names none, so an allowlist refuses
+// it: the key repeated, spelled in another case (Go's decoder would match
+// "Command", a Python handler's json.loads wouldn't), or not a string.
+func CommandName(payload string) string {
+	if trimmed := strings.TrimSpace(payload); strings.HasPrefix(trimmed, "{") {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal([]byte(trimmed), &fields) == nil {
+			return objectCommand(trimmed)
+		}
+	}
+	if fields := strings.Fields(payload); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// objectCommand reads the exact "command" key of a valid JSON object,
+// walking its keys so repeats are seen.
+func objectCommand(obj string) string {
+	dec := json.NewDecoder(strings.NewReader(obj))
+	if _, err := dec.Token(); err != nil {
+		return ""
+	}
+	command, found := "", false
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return ""
+		}
+		if !strings.EqualFold(key, "command") {
+			continue
+		}
+		if key != "command" || found || json.Unmarshal(value, &command) != nil {
+			return ""
+		}
+		found = true
+	}
+	return command
+}
+
+// CheckCommand returns a *CommandNotAllowedError if handlers.allowed_commands
+// restricts deviceType and payload's command isn't on its list.
+func CheckCommand(deviceType, payload string) error {
+	allowed, restricted := shared.AppConfig.Handlers.CommandsFor(deviceType)
+	if !restricted {
+		return nil
+	}
+	command := CommandName(payload)
+	if command != "" && slices.Contains(allowed, command) {
+		return nil
+	}
+	return &CommandNotAllowedError{DeviceType: deviceType, Command: command, Allowed: slices.Clone(allowed)}
+}
@@ -0,0 +1,107 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"roboserver/shared"
+	"slices"
+	"testing"
+)
+
+func TestCommandName(t *testing.T) {
+	cases := map[string]string{
+		`{"command": "unlock", "seconds": 5}`: "unlock",
+		`  {"command":"status"}`:              "status",
+		`{"speed": 3}`:                        "",
+		"unlock front door":                   "unlock",
+		"  ":                                  "",
+		"{not json":                           "{not",
+		`{"command": 5}`:                      "",
+		// What a handler's json.loads sees must be what was checked.
+		`{"command":"reboot","Command":"status"}`: "",
+		`{"Command":"status"}`:                    "",
+		`{"command":"status","command":"reboot"}`: "",
+		`{"COMMAND":"x","command":"status"}`:      "",
+		`{"\u0063ommand":"status"}`:               "status",
+	}
+	for payload, want := range cases {
+		if got := CommandName(payload); got != want {
+			t.Errorf("CommandName(%q) = %q, want %q", payload, got, want)
+		}
+	}
+}
+
+func TestCheckCommandRefusesAmbiguousKeys(t *testing.T) {
+	saved := shared.AppConfig.Handlers.AllowedCommands
+	defer func() { shared.AppConfig.Handlers.AllowedCommands = saved }()
+	shared.AppConfig.Handlers.AllowedCommands = map[string][]string{"door": {"status"}}
+
+	for _, payload := range []string{
+		`{"command":"reboot","Command":"status"}`,
+		`{"command":"status","command":"reboot"}`,
+	} {
+		if err := CheckCommand("door", payload); !errors.Is(err, ErrInvalidCommand) {
+			t.Errorf("%s: expected ErrInvalidCommand, got %v", payload, err)
+		}
+	}
+	if err := CheckCommand("door", `{"command":"status","Status":1}`); err != nil {
+		t.Errorf("Expected a plain status command allowed, got %v", err)
+	}
+}
+
+func TestAllowedCommandsGuardDirectRobotSends(t *testing.T) {
+	saved := shared.AppConfig.Handlers.AllowedCommands
+	defer func() { shared.AppConfig.Handlers.AllowedCommands = saved }()
+	shared.AppConfig.Handlers.AllowedCommands = map[string][]string{"door": {"lock", "unlock"}}
+
+	var sent []string
+	door := &HandlerProcess{UUID: "door-1", DeviceType: "door", writeCh: make(chan []byte, 1), RobotSend: func(data []byte) error {
+		sent = append(sent, string(data))
+		return nil
+	}}
+	if err := door.SendToRobotContext(context.Background(), []byte(`{"command":"lock"}`)); err != nil {
+		t.Fatalf("Expected an allowed command through, got %v", err)
+	}
+	var notAllowed *CommandNotAllowedError
+	if err := door.SendToRobotContext(context.Background(), []byte(`{"command":"reboot"}`)); !errors.As(err, &notAllowed) || notAllowed.Command != "reboot" {
+		t.Fatalf("Expected CommandNotAllowedError for reboot, got %v", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected only the allowed command to reach the robot, got %v", sent)
+	}
+}
+
+func TestAllowedCommandsAreEnforced(t *testing.T) {
+	saved := shared.AppConfig.Handlers.AllowedCommands
+	defer func() { shared.AppConfig.Handlers.AllowedCommands = saved }()
+	shared.AppConfig.Handlers.AllowedCommands = map[string][]string{
+		"door":       {"lock", "unlock", "status"},
+		"env_sensor": {},
+	}
+
+	door := &HandlerProcess{UUID: "door-1", DeviceType: "door", writeCh: make(chan []byte, 4)}
+	if err := door.SendIncomingContext(context.Background(), `{"command":"unlock"}`); err != nil {
+		t.Fatalf("Expected an allowed command through, got %v", err)
+	}
+	err := door.SendIncomingAsContext(context.Background(), `{"command":"reboot"}`, "mallory")
+	var notAllowed *CommandNotAllowedError
+	if !errors.Is(err, ErrInvalidCommand) || !errors.As(err, &notAllowed) {
+		t.Fatalf("Expected ErrInvalidCommand, got %v", err)
+	}
+	if notAllowed.Command != "reboot" || !slices.Equal(notAllowed.Allowed, []string{"lock", "unlock", "status"}) {
+		t.Errorf("Unexpected error detail %+v", notAllowed)
+	}
+	if len(door.writeCh) != 1 {
+		t.Errorf("Expected only the allowed command to reach the handler, got %d messages", len(door.writeCh))
+	}
+
+	// A type listed without commands accepts none; unlisted types any.
+	sensor := &HandlerProcess{UUID: "env-1", DeviceType: "env_sensor", writeCh: make(chan []byte, 4)}
+	if err := sensor.SendIncomingContext(context.Background(), "status"); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Expected env_sensor to accept no commands, got %v", err)
+	}
+	lamp := &HandlerProcess{UUID: "lamp-1", DeviceType: "lamp", writeCh: make(chan []byte, 4)}
+	if err := lamp.SendIncomingContext(context.Background(), "anything"); err != nil {
+		t.Errorf("Expected an unrestricted type to accept any command, got %v", err)
+	}
+}
@@ -4,13 +4,21 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
 	"roboserver/comms"
 	"roboserver/database"
 	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/metrics"
+	"roboserver/shared/robot_status"
+	"roboserver/shared/telemetry"
+	"roboserver/shared/tracing"
+	"roboserver/shared/tracked"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -38,7 +46,10 @@ type HandlerProcess struct {
 
 	// writeCh buffers messages for the dedicated stdin writer goroutine,
 	// preventing mutex blocking when the handler script stalls (BUG-013).
-	writeCh chan []byte
+	// Its size comes from handlers.queue_size(s); overflows counts messages
+	// that found it full.
+	writeCh   chan []byte
+	overflows atomic.Int64
 
 	// RobotSend is called to send data back to the robot's TCP connection.
 	RobotSend func(data []byte) error
@@ -51,8 +62,44 @@ type HandlerProcess struct {
 
 	// wg tracks background goroutines (e.g., reverse connections) for clean shutdown.
 	wg sync.WaitGroup
+
+	// While a robot is disconnected within timeouts.reconnect_grace,
+	// graceTimer is pending and messages for the robot are held in outbox
+	// until Reattach flushes them (or the timer stops the handler).
+	graceTimer *time.Timer
+	outbox     [][]byte
+
+	// cmdLock serializes operator commands for devices that must not
+	// process overlapping ones (see SendCommandContext).
+	cmdLock commandLock
 }
 
+// Errors returned by sends to a handler or through it to its robot. Callers
+// tell them apart with errors.Is.
+var (
+	// ErrHandlerStopped: the handler has stopped.
+	ErrHandlerStopped = errors.New("handler stopped")
+	// ErrQueueFull: the handler's stdin queue was full and the message was
+	// dropped (non-blocking sends only; context-aware ones wait instead).
+	ErrQueueFull = errors.New("handler queue is full")
+	// ErrRobotOffline: the robot has no connection to send on.
+	ErrRobotOffline = errors.New("no robot connection available")
+	// ErrTimeout: the context's deadline passed before the send finished.
+	// It also matches context.DeadlineExceeded.
+	ErrTimeout = fmt.Errorf("send timed out: %w", context.DeadlineExceeded)
+)
+
+// DefaultSendTimeout bounds how long API requests wait for a busy handler to
+// accept a message.
+const DefaultSendTimeout = 5 * time.Second
+
+// sendRetryInterval is how often a blocked context-aware send retries.
+const sendRetryInterval = 5 * time.Millisecond
+
+// MaxQueuedRobotMessages bounds the outbox kept during a reconnect grace
+// period; the oldest messages are dropped first.
+const MaxQueuedRobotMessages = 256
+
 // SpawnHandlerProcess starts a handler script for an authenticated robot.
 func SpawnHandlerProcess(
 	ctx context.Context,
@@ -121,11 +168,14 @@ func SpawnHandlerProcess(
 		rds:        rds,
 		bus:        bus,
 		RobotSend:  robotSend,
-		writeCh:    make(chan []byte, 256),
+		writeCh:    make(chan []byte, shared.AppConfig.Handlers.QueueSizeFor(deviceType)),
+	}
+	if serializedDeviceType(deviceType) {
+		hp.SetSerialized(shared.AppConfig.Handlers.CommandLockTimeout())
 	}
 
 	// Start dedicated stdin writer goroutine (decouples senders from blocking pipe writes)
-	go hp.stdinWriter()
+	tracked.Go("handler", hp.stdinWriter)
 
 	// Store PID in Redis
 	if rds != nil {
@@ -150,14 +200,19 @@ func SpawnHandlerProcess(
 		SessionID:  sessionID,
 	})
 
+	if robotSend != nil {
+		hp.setStatus(robot_status.Online, "connected")
+		hp.pushShadowDelta()
+	}
+
 	// Subscribe to directed messages on the event bus (e.g., handler.{uuid}.message)
 	hp.setupBusSubscriptions()
 
 	// Start stdout listener (routes JSON-RPC envelopes)
-	go hp.listenStdout(procCtx)
+	tracked.Go("handler", func() { hp.listenStdout(procCtx) })
 
 	// Start stderr listener (publishes handler log lines on the event bus)
-	go hp.listenStderr(procCtx)
+	tracked.Go("handler", func() { hp.listenStderr(procCtx) })
 
 	return hp, nil
 }
@@ -169,7 +224,7 @@ func (hp *HandlerProcess) setupBusSubscriptions() {
 	}
 
 	// Subscribe to messages directed at this handler
-	topic := fmt.Sprintf("handler.%s.message", hp.UUID)
+	topic := events.HandlerMessage(hp.UUID)
 	cancel, err := hp.bus.SubscribeEvent(topic, func(eventType string, data any) {
 		hp.sendToScript(&EventMessage{
 			Type:      MsgTypeEvent,
@@ -182,6 +237,35 @@ func (hp *HandlerProcess) setupBusSubscriptions() {
 		hp.subscriptions = append(hp.subscriptions, cancel)
 		hp.mu.Unlock()
 	}
+
+	// Desired-state changes, made on any node
+	cancel, err = hp.bus.SubscribeEvent(events.RobotShadow(hp.UUID), func(_ string, data any) {
+		hp.onShadowEvent(data)
+	})
+	if err == nil {
+		hp.mu.Lock()
+		hp.subscriptions = append(hp.subscriptions, cancel)
+		hp.mu.Unlock()
+	}
+
+	// API messages routed here from another cluster node
+	cancel, err = hp.bus.SubscribeEvent(events.HandlerIncoming(hp.UUID), func(eventType string, data any) {
+		msg, ok := events.DecodeForwardedMessage(data)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(tracing.Extract(context.Background(), msg.TraceParent), DefaultSendTimeout)
+		defer cancel()
+		lock := time.Duration(msg.LockMS) * time.Millisecond
+		if err := hp.SendCommandContext(ctx, msg.Message, msg.Actor, lock); err != nil {
+			shared.DebugPrint("Handler %s: dropping forwarded message: %v", hp.UUID, err)
+		}
+	})
+	if err == nil {
+		hp.mu.Lock()
+		hp.subscriptions = append(hp.subscriptions, cancel)
+		hp.mu.Unlock()
+	}
 }
 
 // Reattach reconnects a robot's TCP connection to this handler after a disconnect.
@@ -191,8 +275,28 @@ func (hp *HandlerProcess) Reattach(robotSend func(data []byte) error, ip, sessio
 	hp.RobotSend = robotSend
 	hp.IP = ip
 	hp.SessionID = sessionID
+	if hp.graceTimer != nil {
+		hp.graceTimer.Stop()
+		hp.graceTimer = nil
+	}
+	queued := hp.outbox
+	hp.outbox = nil
 	hp.mu.Unlock()
 
+	hp.setStatus(robot_status.Online, "reconnected")
+
+	// Deliver what the handler sent while the robot was away, oldest first,
+	// before the handler hears about the new connection.
+	for i, data := range queued {
+		if err := robotSend(data); err != nil {
+			shared.DebugPrint("Handler %s: failed to flush queued messages (%d left): %v", hp.UUID, len(queued)-i, err)
+			break
+		}
+	}
+	if len(queued) > 0 {
+		shared.DebugPrint("Handler %s: delivered %d messages queued during reconnect", hp.UUID, len(queued))
+	}
+
 	hp.sendToScript(&ConnectMessage{
 		Type:       MsgTypeConnect,
 		UUID:       hp.UUID,
@@ -200,14 +304,100 @@ func (hp *HandlerProcess) Reattach(robotSend func(data []byte) error, ip, sessio
 		IP:         ip,
 		SessionID:  sessionID,
 	})
+	hp.pushShadowDelta()
 }
 
 // SendIncoming forwards a message from the robot TCP connection to the handler's stdin.
-func (hp *HandlerProcess) SendIncoming(payload string) {
-	hp.sendToScript(&IncomingMessage{
+// Payloads over limits.handler_message are dropped with a *shared.PayloadTooLargeError,
+// and messages that don't fit in the queue with ErrQueueFull.
+func (hp *HandlerProcess) SendIncoming(payload string) error {
+	return hp.SendIncomingTraced(context.Background(), payload)
+}
+
+// SendIncomingTraced is SendIncoming for a message that is part of ctx's
+// trace, such as a robot's TCP line; the handler sees its traceparent.
+func (hp *HandlerProcess) SendIncomingTraced(ctx context.Context, payload string) error {
+	if err := checkIncomingSize(payload); err != nil {
+		shared.DebugPrint("Handler %s: dropping incoming message: %v", hp.UUID, err)
+		return err
+	}
+	metrics.RecordMessage()
+	return hp.sendToScript(&IncomingMessage{
+		Type:        MsgTypeIncoming,
+		UUID:        hp.UUID,
+		Payload:     payload,
+		TraceParent: tracing.Inject(ctx),
+	})
+}
+
+// SendTelemetry forwards a robot's DATA report to the handler. Like
+// SendIncoming it never blocks the robot's connection.
+func (hp *HandlerProcess) SendTelemetry(env telemetry.Envelope) {
+	metrics.RecordMessage()
+	hp.sendToScript(&TelemetryMessage{
+		Type: MsgTypeTelemetry,
+		UUID: hp.UUID,
+		Data: env,
+	})
+}
+
+// checkIncomingSize enforces limits.handler_message on a payload bound for
+// a handler's stdin.
+func checkIncomingSize(payload string) error {
+	return shared.CheckPayloadSize("handler", len(payload), shared.AppConfig.Limits.HandlerMessageBytes())
+}
+
+// SendIncomingContext is SendIncoming for API callers: instead of dropping
+// the message when the handler's stdin buffer is full, it waits for space
+// until ctx is done. Returns ErrHandlerStopped if the handler has stopped
+// and ErrTimeout if ctx's deadline passes first.
+func (hp *HandlerProcess) SendIncomingContext(ctx context.Context, payload string) error {
+	return hp.SendIncomingAsContext(ctx, payload, "")
+}
+
+// SendIncomingAsContext is the context-aware form of SendIncomingAs. In
+// serialization mode it takes the command lock like SendCommandContext.
+func (hp *HandlerProcess) SendIncomingAsContext(ctx context.Context, payload, actor string) error {
+	return hp.SendCommandContext(ctx, payload, actor, 0)
+}
+
+// sendIncoming writes an operator message to the handler, waiting for
+// buffer space until ctx is done. lockID is set when it holds the command
+// lock.
+func (hp *HandlerProcess) sendIncoming(ctx context.Context, payload, actor, lockID string) error {
+	ctx, span := tracing.StartSpan(ctx, tracing.KindProducer, "handler.send")
+	span.SetAttr("robot.uuid", hp.UUID)
+	defer span.End()
+	err := hp.sendToScriptContext(ctx, &IncomingMessage{
+		Type:        MsgTypeIncoming,
+		UUID:        hp.UUID,
+		Payload:     payload,
+		Actor:       actor,
+		LockID:      lockID,
+		TraceParent: tracing.Inject(ctx),
+	})
+	if err == nil {
+		metrics.RecordMessage()
+	}
+	span.SetError(err)
+	return err
+}
+
+// SendIncomingAs forwards an operator message tagged with the verified username,
+// so handlers can attribute sensitive actions (e.g. unlocking a door).
+func (hp *HandlerProcess) SendIncomingAs(payload, actor string) error {
+	if err := checkIncomingSize(payload); err != nil {
+		return err
+	}
+	if err := CheckCommand(hp.DeviceType, payload); err != nil {
+		return err
+	}
+	metrics.RecordMessage()
+	return hp.sendToScript(&IncomingMessage{
 		Type:    MsgTypeIncoming,
 		UUID:    hp.UUID,
 		Payload: payload,
+		Actor:   actor,
 	})
 }
 
@@ -222,6 +412,24 @@ func (hp *HandlerProcess) SendDisconnect(reason string) {
 	}
 
 	hp.RobotSend = nil // No longer connected
+	hp.setStatus(robot_status.Offline, reason)
+
+	if grace := shared.AppConfig.Timeouts.ReconnectGraceDuration(); grace > 0 && hp.graceTimer == nil {
+		hp.outbox = nil
+		var timer *time.Timer
+		timer = time.AfterFunc(grace, func() {
+			// A Reattach racing with expiry wins if it got the lock first.
+			hp.mu.Lock()
+			expired := hp.graceTimer == timer
+			hp.mu.Unlock()
+			if !expired {
+				return
+			}
+			shared.DebugPrint("Robot %s did not reconnect within %v, stopping handler", hp.UUID, grace)
+			hp.Stop("reconnect_timeout")
+		})
+		hp.graceTimer = timer
+	}
 
 	msg := &DisconnectMessage{
 		Type:   MsgTypeDisconnect,
@@ -237,6 +445,7 @@ func (hp *HandlerProcess) SendDisconnect(reason string) {
 	select {
 	case hp.writeCh <- data:
 	default:
+		hp.overflow()
 		shared.DebugPrint("Handler %s write buffer full, dropping disconnect message", hp.UUID)
 	}
 }
@@ -249,6 +458,11 @@ func (hp *HandlerProcess) Stop(reason string) {
 		return
 	}
 	hp.closed = true
+	if hp.graceTimer != nil {
+		hp.graceTimer.Stop()
+		hp.graceTimer = nil
+	}
+	hp.outbox = nil
 
 	// Send disconnect message while channel is still open (under lock to
 	// prevent racing with close). This fixes a prior bug where the disconnect
@@ -262,6 +476,7 @@ func (hp *HandlerProcess) Stop(reason string) {
 	select {
 	case hp.writeCh <- data:
 	default:
+		hp.overflow()
 	}
 	hp.mu.Unlock()
 
@@ -305,29 +520,82 @@ func (hp *HandlerProcess) Stop(reason string) {
 
 	// Unregister from global handler map
 	HandlerManager.Unregister(hp.UUID)
+	hp.setStatus(robot_status.Offline, reason)
 }
 
-func (hp *HandlerProcess) sendToScript(msg interface{}) {
+// sendToScript queues a message for the handler's stdin without waiting. A
+// message that doesn't fit is dropped with ErrQueueFull.
+func (hp *HandlerProcess) sendToScript(msg interface{}) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		shared.DebugPrint("Failed to marshal message for handler %s: %v", hp.UUID, err)
-		return
+		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 	data = append(data, '\n')
 
 	hp.mu.Lock()
 	defer hp.mu.Unlock()
 	if hp.closed {
-		return
+		return ErrHandlerStopped
 	}
 
 	select {
 	case hp.writeCh <- data:
+		return nil
 	default:
+		hp.overflow()
 		shared.DebugPrint("Handler %s write buffer full, dropping message", hp.UUID)
+		return ErrQueueFull
+	}
+}
+
+// sendToScriptContext is sendToScript for callers that would rather wait
+// than drop. The lock is only held for each non-blocking attempt, so a
+// stalled handler can't block Stop (BUG-013).
+func (hp *HandlerProcess) sendToScriptContext(ctx context.Context, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	data = append(data, '\n')
+
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return sendContextError(err)
+		}
+		hp.mu.Lock()
+		if hp.closed {
+			hp.mu.Unlock()
+			return ErrHandlerStopped
+		}
+		select {
+		case hp.writeCh <- data:
+			hp.mu.Unlock()
+			return nil
+		default:
+			if attempt == 0 {
+				hp.overflow()
+			}
+		}
+		hp.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return sendContextError(ctx.Err())
+		case <-time.After(sendRetryInterval):
+		}
 	}
 }
 
+// sendContextError reports a send cut short by its context: ErrTimeout for
+// a passed deadline, otherwise the context's error.
+func sendContextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
 // stdinWriter is a dedicated goroutine that drains the write channel and
 // writes to the handler's stdin pipe. This decouples message senders from
 // potentially blocking pipe writes, preventing mutex stalls (BUG-013).
@@ -355,7 +623,7 @@ func (hp *HandlerProcess) listenStderr(ctx context.Context) {
 		shared.DebugPrint("Handler %s stderr: %s", hp.UUID, line)
 
 		if hp.bus != nil {
-			hp.bus.PublishEvent(fmt.Sprintf("handler.%s.log", hp.UUID), map[string]string{
+			hp.bus.PublishEvent(events.HandlerLog(hp.UUID), map[string]string{
 				"uuid":    hp.UUID,
 				"line":    line,
 				"stream":  "stderr",
@@ -381,7 +649,7 @@ func (hp *HandlerProcess) listenStdout(ctx context.Context) {
 			logLine := string(line)
 			shared.DebugPrint("Handler %s stdout: %s", hp.UUID, logLine)
 			if hp.bus != nil {
-				hp.bus.PublishEvent(fmt.Sprintf("handler.%s.log", hp.UUID), map[string]string{
+				hp.bus.PublishEvent(events.HandlerLog(hp.UUID), map[string]string{
 					"uuid":   hp.UUID,
 					"line":   logLine,
 					"stream": "stdout",
@@ -396,6 +664,14 @@ func (hp *HandlerProcess) listenStdout(ctx context.Context) {
 	if err := scanner.Err(); err != nil {
 		shared.DebugPrint("Handler stdout error for %s: %v", hp.UUID, err)
 	}
+
+	// stdout closing without Stop means the handler script died
+	hp.mu.Lock()
+	crashed := !hp.closed
+	hp.mu.Unlock()
+	if crashed {
+		hp.setStatus(robot_status.Error, "handler_exited")
+	}
 }
 
 // routeEnvelope dispatches a JSON-RPC envelope to the appropriate target.
@@ -411,6 +687,8 @@ func (hp *HandlerProcess) routeEnvelope(ctx context.Context, env *JSONRPCEnvelop
 		hp.handleConfigRequest(env)
 	case TargetConnect:
 		hp.handleConnectRobotRequest(ctx, env)
+	case TargetShadow:
+		hp.handleShadowRequest(ctx, env)
 	default:
 		shared.DebugPrint("Unknown target %q from handler %s", env.Target, hp.UUID)
 		hp.sendResponse(env.ID, nil, "unknown target: "+env.Target)
@@ -419,7 +697,20 @@ func (hp *HandlerProcess) routeEnvelope(ctx context.Context, env *JSONRPCEnvelop
 
 // redisDataKey generates the Redis key used to store arbitrary handler data.
 func (hp *HandlerProcess) redisDataKey(key string) string {
-	return fmt.Sprintf("handler:%s:data:%s", hp.UUID, key)
+	return database.HandlerDataKey(hp.UUID, key)
+}
+
+// storeDataTTL returns the expiry for a store_data call: the optional "ttl"
+// param in seconds (0 = keep forever), else handlers.data_ttl. Telemetry such
+// as sensor history should pass a ttl so abandoned robots' data ages out.
+func storeDataTTL(params map[string]interface{}) time.Duration {
+	if raw, ok := params["ttl"]; ok {
+		if secs, ok := raw.(float64); ok && secs > 0 {
+			return time.Duration(secs * float64(time.Second))
+		}
+		return 0
+	}
+	return shared.AppConfig.Handlers.DataExpiry()
 }
 
 func (hp *HandlerProcess) handleDatabaseRequest(ctx context.Context, env *JSONRPCEnvelope) {
@@ -458,6 +749,23 @@ func (hp *HandlerProcess) handleDatabaseRequest(ctx context.Context, env *JSONRP
 		}
 		hp.sendResponse(env.ID, robots, "")
 
+	case "get_schema":
+		// Command schema uploaded for this robot (generic_actuator); null if none.
+		if hp.db == nil {
+			hp.sendResponse(env.ID, nil, "database not available")
+			return
+		}
+		raw, err := hp.db.GetRobotSchema(ctx, hp.UUID)
+		if err != nil {
+			hp.sendResponse(env.ID, nil, err.Error())
+			return
+		}
+		if len(raw) == 0 {
+			hp.sendResponse(env.ID, nil, "")
+			return
+		}
+		hp.sendResponse(env.ID, json.RawMessage(raw), "")
+
 	case "store_data":
 		params, ok := env.Data.(map[string]interface{})
 		if !ok {
@@ -476,7 +784,7 @@ func (hp *HandlerProcess) handleDatabaseRequest(ctx context.Context, env *JSONRP
 		}
 		// Store in Redis with handler-scoped key
 		if hp.rds != nil {
-			if err := hp.rds.Client.Set(ctx, hp.redisDataKey(key), value, 0).Err(); err != nil {
+			if err := hp.rds.Client.Set(ctx, hp.redisDataKey(key), value, storeDataTTL(params)).Err(); err != nil {
 				hp.sendResponse(env.ID, nil, err.Error())
 				return
 			}
@@ -532,23 +840,78 @@ func (hp *HandlerProcess) handleRobotRequest(env *JSONRPCEnvelope) {
 		return
 	}
 
-	if err := hp.SendToRobot(data); err != nil {
+	_, span := tracing.StartSpan(tracing.Extract(context.Background(), env.TraceParent), tracing.KindClient, "robot.send")
+	span.SetAttr("robot.uuid", hp.UUID)
+	queued, err := hp.deliverToRobot(data)
+	span.SetAttr("robot.queued", queued)
+	span.SetError(err)
+	span.End()
+	if err != nil {
 		hp.sendResponse(env.ID, nil, err.Error())
 		return
 	}
+	if queued {
+		hp.sendResponse(env.ID, "queued", "")
+		return
+	}
 	hp.sendResponse(env.ID, "sent", "")
 }
 
 // SendToRobot safely copies the RobotSend callback under lock, then calls it.
 // This prevents a data race with concurrent SendDisconnect/Reattach calls.
+// During a reconnect grace period the message is queued instead. Returns
+// ErrRobotOffline when the robot has no connection.
 func (hp *HandlerProcess) SendToRobot(data []byte) error {
+	_, err := hp.deliverToRobot(data)
+	return err
+}
+
+// SendToRobotContext is SendToRobot bounded by ctx, for operator messages
+// that bypass the handler (WebSocket send_to_robot): data must pass
+// CheckCommand like any other operator message. The robot's transport
+// write can't be interrupted, so when ctx ends first it returns ErrTimeout
+// (or ctx's error) and the write finishes in the background.
+func (hp *HandlerProcess) SendToRobotContext(ctx context.Context, data []byte) error {
+	if err := CheckCommand(hp.DeviceType, string(data)); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return sendContextError(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := hp.deliverToRobot(data)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return sendContextError(ctx.Err())
+	}
+}
+
+// deliverToRobot sends data to the robot, or queues it (queued=true) while
+// the robot is inside its reconnect grace period.
+func (hp *HandlerProcess) deliverToRobot(data []byte) (queued bool, err error) {
 	hp.mu.Lock()
 	send := hp.RobotSend
-	hp.mu.Unlock()
 	if send == nil {
-		return fmt.Errorf("no robot connection available")
+		defer hp.mu.Unlock()
+		if hp.graceTimer == nil || hp.closed {
+			return false, ErrRobotOffline
+		}
+		if len(hp.outbox) >= MaxQueuedRobotMessages {
+			hp.outbox[0] = nil
+			hp.outbox = hp.outbox[1:]
+		}
+		hp.outbox = append(hp.outbox, append([]byte(nil), data...))
+		return true, nil
 	}
-	return send(data)
+	hp.mu.Unlock()
+
+	metrics.RecordMessage()
+	return false, send(data)
 }
 
 func (hp *HandlerProcess) handleEventBusRequest(env *JSONRPCEnvelope) {
@@ -558,9 +921,13 @@ func (hp *HandlerProcess) handleEventBusRequest(env *JSONRPCEnvelope) {
 	}
 	eventType := env.Method
 	if eventType == "" {
-		eventType = "handler_event"
+		eventType = events.HandlerEvent
+	}
+	if env.TTL > 0 {
+		comms.PublishTTL(hp.bus, eventType, env.Data, time.Duration(env.TTL*float64(time.Second)))
+	} else {
+		hp.bus.PublishEvent(eventType, env.Data)
 	}
-	hp.bus.PublishEvent(eventType, env.Data)
 	hp.sendResponse(env.ID, "published", "")
 }
 
@@ -572,12 +939,18 @@ func (hp *HandlerProcess) handleConfigRequest(env *JSONRPCEnvelope) {
 			hp.sendResponse(env.ID, nil, "data must be a boolean")
 			return
 		}
-		if enable && !hp.ForwardHeartbeats {
-			hp.enableHeartbeatForwarding()
-		} else if !enable {
+		hp.mu.Lock()
+		forwarding := hp.ForwardHeartbeats
+		if !enable {
 			hp.ForwardHeartbeats = false
 		}
-		hp.sendResponse(env.ID, hp.ForwardHeartbeats, "")
+		hp.mu.Unlock()
+		if enable && !forwarding {
+			forwarding = hp.enableHeartbeatForwarding()
+		} else if !enable {
+			forwarding = false
+		}
+		hp.sendResponse(env.ID, forwarding, "")
 
 	case "subscribe":
 		// Allow handlers to subscribe to arbitrary event bus topics
@@ -606,18 +979,30 @@ func (hp *HandlerProcess) handleConfigRequest(env *JSONRPCEnvelope) {
 		hp.mu.Unlock()
 		hp.sendResponse(env.ID, "subscribed", "")
 
+	case "set_status":
+		hp.handleSetStatus(env)
+
+	case "serialize_commands":
+		hp.handleSerializeCommands(env)
+
+	case "command_done":
+		// data: the lock_id of the finished command, or omitted
+		id, _ := env.Data.(string)
+		hp.sendResponse(env.ID, hp.ReleaseCommand(id), "")
+
 	default:
 		hp.sendResponse(env.ID, nil, "unknown config method: "+env.Method)
 	}
 }
 
-// enableHeartbeatForwarding subscribes the handler to this robot's heartbeat events.
-func (hp *HandlerProcess) enableHeartbeatForwarding() {
+// enableHeartbeatForwarding subscribes the handler to this robot's heartbeat
+// events and reports whether forwarding is now on.
+func (hp *HandlerProcess) enableHeartbeatForwarding() bool {
 	if hp.bus == nil {
-		return
+		return false
 	}
 
-	topic := fmt.Sprintf("robot.%s.heartbeat", hp.UUID)
+	topic := events.RobotHeartbeat(hp.UUID)
 	cancel, err := hp.bus.SubscribeEvent(topic, func(eventType string, data any) {
 		hp.sendToScript(&EventMessage{
 			Type:      MsgTypeHeartbeat,
@@ -625,13 +1010,15 @@ func (hp *HandlerProcess) enableHeartbeatForwarding() {
 			Data:      data,
 		})
 	})
-	if err == nil {
-		hp.mu.Lock()
-		hp.subscriptions = append(hp.subscriptions, cancel)
-		hp.mu.Unlock()
-		hp.ForwardHeartbeats = true
-		shared.DebugPrint("Heartbeat forwarding enabled for handler %s", hp.UUID)
+	if err != nil {
+		return false
 	}
+	hp.mu.Lock()
+	hp.subscriptions = append(hp.subscriptions, cancel)
+	hp.ForwardHeartbeats = true
+	hp.mu.Unlock()
+	shared.DebugPrint("Heartbeat forwarding enabled for handler %s", hp.UUID)
+	return true
 }
 
 func (hp *HandlerProcess) sendResponse(id string, data interface{}, errMsg string) {
@@ -1,11 +1,18 @@
 package handler_engine
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"roboserver/shared"
+	"roboserver/shared/robot_status"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -43,6 +50,83 @@ func TestResolveHandlerScript(t *testing.T) {
 	}
 }
 
+func TestHandlerPluginDirs(t *testing.T) {
+	for _, dir := range []string{"testdata/test_robot", "testdata/plugins/vendor_bot/dist", "testdata/plugins/test_robot"} {
+		os.MkdirAll(dir, 0o755)
+	}
+	for _, dir := range []string{"testdata/test_robot", "testdata/plugins/vendor_bot", "testdata/plugins/test_robot"} {
+		os.WriteFile(filepath.Join(dir, "start_handler.sh"), []byte("#!/bin/bash\necho ok"), 0o755)
+	}
+	defer os.RemoveAll("testdata")
+	shared.AppConfig.Handlers.PluginDirs = []string{"./testdata/plugins", "./testdata/missing"}
+	defer func() { shared.AppConfig.Handlers.PluginDirs = nil }()
+
+	path, err := ResolveHandlerScript("vendor_bot")
+	if err != nil {
+		t.Fatalf("Failed to resolve a plugin dir handler: %v", err)
+	}
+	if !strings.HasSuffix(path, filepath.Join("testdata", "plugins", "vendor_bot", "start_handler.sh")) {
+		t.Errorf("Unexpected path %s", path)
+	}
+	if path, _ := ResolveHandlerScript("test_robot"); strings.Contains(path, "plugins") {
+		t.Errorf("Expected base_path to win over a plugin dir, got %s", path)
+	}
+
+	if got := ListHandlerTypes(); !slices.Equal(got, []string{"test_robot", "vendor_bot"}) {
+		t.Errorf("Expected each type once, sorted, got %v", got)
+	}
+	want := []RobotType{{Type: "test_robot"}, {Type: "vendor_bot", Plugin: true}}
+	if got := ListRobotTypes(); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestValidateRegistration(t *testing.T) {
+	os.MkdirAll(filepath.Join("testdata", "test_robot"), 0o755)
+	os.WriteFile(filepath.Join("testdata", "test_robot", "start_handler.sh"), []byte("#!/bin/bash\necho ok"), 0o755)
+	defer os.RemoveAll("testdata")
+
+	tests := []struct {
+		name       string
+		uuid       string
+		deviceType string
+		schema     string
+		code       string
+	}{
+		{"valid", "robot-001", "test_robot", "", ""},
+		{"valid with schema", "robot_001", "test_robot", `{"commands": {"stop": {}}}`, ""},
+		{"uuid with slash", "robot/1", "test_robot", "", CodeInvalidUUID},
+		{"uuid with colon", "robot:1", "test_robot", "", CodeInvalidUUID},
+		{"uuid with dot", "robot.1", "test_robot", "", CodeInvalidUUID},
+		{"uuid too long", strings.Repeat("a", 65), "test_robot", "", CodeInvalidUUID},
+		{"malformed device type", "robot-1", "../etc", "", CodeInvalidDeviceType},
+		{"unknown device type", "robot-1", "toaster", "", CodeUnknownDeviceType},
+		{"template device type", "robot-1", TemplateType, "", CodeUnknownDeviceType},
+		{"invalid schema", "robot-1", "test_robot", `{"commands": {}}`, CodeInvalidSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRegistration(tt.uuid, tt.deviceType, []byte(tt.schema))
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			regErr, ok := err.(*RegistrationError)
+			if !ok {
+				t.Fatalf("Expected *RegistrationError, got %T (%v)", err, err)
+			}
+			if regErr.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, regErr.Code)
+			}
+			if tt.code == CodeUnknownDeviceType && !slices.Contains(regErr.ValidTypes, "test_robot") {
+				t.Errorf("Expected the valid types to list test_robot, got %v", regErr.ValidTypes)
+			}
+		})
+	}
+}
+
 func TestJSONRPCEnvelopeSerialization(t *testing.T) {
 	env := JSONRPCEnvelope{
 		ID:     "req-1",
@@ -114,3 +198,218 @@ func TestDisconnectMessageSerialization(t *testing.T) {
 		t.Errorf("Expected reason=heartbeat_expired, got %s", decoded.Reason)
 	}
 }
+
+func TestIncomingMessageActor(t *testing.T) {
+	plain, _ := json.Marshal(IncomingMessage{Type: MsgTypeIncoming, UUID: "lock-1", Payload: "hi"})
+	if strings.Contains(string(plain), "actor") {
+		t.Errorf("Expected no actor field for robot messages, got %s", plain)
+	}
+
+	data, _ := json.Marshal(IncomingMessage{Type: MsgTypeIncoming, UUID: "lock-1", Payload: "unlock", Actor: "alice"})
+	var decoded IncomingMessage
+	json.Unmarshal(data, &decoded)
+	if decoded.Actor != "alice" {
+		t.Errorf("Expected actor=alice, got %s", decoded.Actor)
+	}
+}
+
+func TestStoreDataTTL(t *testing.T) {
+	saved := shared.AppConfig.Handlers.DataTTL
+	defer func() { shared.AppConfig.Handlers.DataTTL = saved }()
+	shared.AppConfig.Handlers.DataTTL = "1h"
+
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		expected time.Duration
+	}{
+		{"config default", map[string]interface{}{"key": "k"}, time.Hour},
+		{"explicit ttl", map[string]interface{}{"key": "k", "ttl": float64(90)}, 90 * time.Second},
+		{"explicit zero keeps forever", map[string]interface{}{"key": "k", "ttl": float64(0)}, 0},
+		{"non-numeric ttl keeps forever", map[string]interface{}{"key": "k", "ttl": "soon"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storeDataTTL(tt.params); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	shared.AppConfig.Handlers.DataTTL = ""
+	if got := storeDataTTL(map[string]interface{}{"key": "k"}); got != 0 {
+		t.Errorf("Expected no expiry when data_ttl is unset, got %v", got)
+	}
+}
+
+func TestReconnectGraceQueuesAndFlushes(t *testing.T) {
+	saved := shared.AppConfig.Timeouts.ReconnectGrace
+	defer func() { shared.AppConfig.Timeouts.ReconnectGrace = saved }()
+	shared.AppConfig.Timeouts.ReconnectGrace = "1h"
+
+	var delivered []string
+	send := func(data []byte) error {
+		delivered = append(delivered, string(data))
+		return nil
+	}
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 16), RobotSend: send}
+
+	hp.SendDisconnect("tcp_closed")
+	if err := hp.SendToRobot([]byte("one")); err != nil {
+		t.Fatalf("Expected message to be queued during grace period, got %v", err)
+	}
+	queued, err := hp.deliverToRobot([]byte("two"))
+	if err != nil || !queued {
+		t.Fatalf("Expected queued=true, got queued=%v err=%v", queued, err)
+	}
+	if len(delivered) != 0 {
+		t.Fatalf("Nothing should be delivered while disconnected, got %v", delivered)
+	}
+
+	hp.Reattach(send, "10.0.0.2", "sess-2")
+	if strings.Join(delivered, ",") != "one,two" {
+		t.Errorf("Expected queued messages flushed in order, got %v", delivered)
+	}
+	if hp.graceTimer != nil {
+		t.Error("Expected grace timer cleared on reattach")
+	}
+
+	queued, err = hp.deliverToRobot([]byte("three"))
+	if err != nil || queued {
+		t.Errorf("Expected direct delivery after reattach, got queued=%v err=%v", queued, err)
+	}
+}
+
+func TestReconnectGraceOutboxBounded(t *testing.T) {
+	saved := shared.AppConfig.Timeouts.ReconnectGrace
+	defer func() { shared.AppConfig.Timeouts.ReconnectGrace = saved }()
+	shared.AppConfig.Timeouts.ReconnectGrace = "1h"
+
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 16), RobotSend: func([]byte) error { return nil }}
+	hp.SendDisconnect("tcp_closed")
+	defer hp.graceTimer.Stop()
+
+	for i := 0; i < MaxQueuedRobotMessages+10; i++ {
+		hp.SendToRobot([]byte(strconv.Itoa(i)))
+	}
+	if len(hp.outbox) != MaxQueuedRobotMessages {
+		t.Fatalf("Expected outbox capped at %d, got %d", MaxQueuedRobotMessages, len(hp.outbox))
+	}
+	if string(hp.outbox[0]) != "10" {
+		t.Errorf("Expected oldest messages dropped first, head is %q", hp.outbox[0])
+	}
+}
+
+func TestNoGraceFailsWhileDisconnected(t *testing.T) {
+	saved := shared.AppConfig.Timeouts.ReconnectGrace
+	defer func() { shared.AppConfig.Timeouts.ReconnectGrace = saved }()
+	shared.AppConfig.Timeouts.ReconnectGrace = "0s"
+
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 16), RobotSend: func([]byte) error { return nil }}
+	hp.SendDisconnect("tcp_closed")
+
+	if err := hp.SendToRobot([]byte("x")); !errors.Is(err, ErrRobotOffline) {
+		t.Errorf("Expected ErrRobotOffline sending to a disconnected robot with no grace period, got %v", err)
+	}
+}
+
+func TestSendIncomingContextWaitsForSpace(t *testing.T) {
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 1)}
+	hp.writeCh <- []byte("backlog\n")
+
+	// Full buffer: the send waits, then gives up at the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := hp.SendIncomingContext(ctx, "late"); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected ErrTimeout (a DeadlineExceeded) while the buffer is full, got %v", err)
+	}
+	if err := hp.SendIncoming("dropped"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull from a non-blocking send, got %v", err)
+	}
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if err := hp.SendIncomingContext(cancelled, "cancelled"); !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected Canceled for a cancelled context, got %v", err)
+	}
+
+	// Space frees up while the send is waiting
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-hp.writeCh
+	}()
+	if err := hp.SendIncomingContext(context.Background(), "next"); err != nil {
+		t.Fatalf("Expected send to succeed once the buffer drained, got %v", err)
+	}
+	if got := string(<-hp.writeCh); !strings.Contains(got, `"payload":"next"`) {
+		t.Errorf("Expected the waiting message to be written, got %s", got)
+	}
+
+	hp.closed = true
+	if err := hp.SendIncomingContext(context.Background(), "gone"); !errors.Is(err, ErrHandlerStopped) {
+		t.Errorf("Expected ErrHandlerStopped, got %v", err)
+	}
+}
+
+func TestSendToRobotContext(t *testing.T) {
+	release := make(chan struct{})
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 1), RobotSend: func([]byte) error {
+		<-release
+		return nil
+	}}
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := hp.SendToRobotContext(ctx, []byte("x")); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected ErrTimeout for a stalled robot write, got %v", err)
+	}
+
+	ok := &HandlerProcess{UUID: "r2", writeCh: make(chan []byte, 1), RobotSend: func([]byte) error { return nil }}
+	if err := ok.SendToRobotContext(context.Background(), []byte("x")); err != nil {
+		t.Errorf("Expected the send to succeed, got %v", err)
+	}
+}
+
+func TestSendIncomingRejectsOversizedPayload(t *testing.T) {
+	shared.AppConfig.Limits.HandlerMessage = 8
+	defer func() { shared.AppConfig.Limits.HandlerMessage = 0 }()
+
+	hp := &HandlerProcess{UUID: "big-robot", writeCh: make(chan []byte, 4)}
+	if err := hp.SendIncoming("way too long"); !errors.Is(err, shared.ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+	if err := hp.SendIncomingContext(context.Background(), "way too long"); !errors.Is(err, shared.ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge from context send, got %v", err)
+	}
+	if len(hp.writeCh) != 0 {
+		t.Errorf("Expected nothing written, got %d messages", len(hp.writeCh))
+	}
+	if err := hp.SendIncoming("ok"); err != nil || len(hp.writeCh) != 1 {
+		t.Errorf("Expected small payload to be written, got %v", err)
+	}
+}
+
+func TestSetStatusConfigRequest(t *testing.T) {
+	hp := &HandlerProcess{UUID: "status-robot", writeCh: make(chan []byte, 16)}
+	robot_status.Tracker.Set("status-robot", robot_status.Online, "connected")
+
+	respond := func(data any) JSONRPCEnvelope {
+		hp.handleConfigRequest(&JSONRPCEnvelope{ID: "1", Target: TargetConfig, Method: "set_status", Data: data})
+		var resp JSONRPCEnvelope
+		json.Unmarshal(<-hp.writeCh, &resp)
+		return resp
+	}
+
+	if resp := respond("busy"); resp.Error != "" || resp.Data != "busy" {
+		t.Errorf("Expected busy accepted, got %+v", resp)
+	}
+	if robot_status.Tracker.Get("status-robot") != robot_status.Busy {
+		t.Errorf("Expected tracker to record busy")
+	}
+	if resp := respond("offline"); resp.Error == "" {
+		t.Error("Expected handlers to be refused the offline status")
+	}
+	if resp := respond("napping"); resp.Error == "" {
+		t.Error("Expected unknown status to be refused")
+	}
+}
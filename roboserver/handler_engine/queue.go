@@ -0,0 +1,114 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/comms"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/metrics"
+	"time"
+)
+
+// queueCheckInterval is how often WatchQueues looks for full handler queues.
+const queueCheckInterval = time.Second
+
+// QueueFullEvent is published on handler.{uuid}.queue_full.
+type QueueFullEvent struct {
+	UUID       string `json:"uuid"`
+	DeviceType string `json:"device_type"`
+	Capacity   int    `json:"capacity"`
+	FullFor    int64  `json:"full_for_s"` // seconds the queue has been full
+	Overflows  int64  `json:"overflows"`  // messages dropped or delayed so far
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.HandlerQueueFull("{uuid}"),
+		Description: "A handler's stdin queue has stayed full for handlers.queue_full_alert.",
+		Example:     QueueFullEvent{UUID: "rover-7", DeviceType: "rover", Capacity: 256, FullFor: 30, Overflows: 12},
+	})
+}
+
+// overflow counts a message that did not fit in the handler's stdin queue.
+func (hp *HandlerProcess) overflow() {
+	hp.overflows.Add(1)
+	metrics.RecordQueueOverflow()
+}
+
+// queueFull reports whether the handler's stdin queue has no free slot.
+func (hp *HandlerProcess) queueFull() bool {
+	return len(hp.writeCh) == cap(hp.writeCh)
+}
+
+// queueWatch tracks how long each handler's queue has been full.
+type queueWatch struct {
+	fullSince map[*HandlerProcess]time.Time
+	alerted   map[*HandlerProcess]bool
+}
+
+// check returns the handlers whose queue has been full for at least after
+// and that were not reported yet in this full spell.
+func (w *queueWatch) check(handlers []*HandlerProcess, after time.Duration, now time.Time) []QueueFullEvent {
+	var out []QueueFullEvent
+	seen := make(map[*HandlerProcess]bool, len(handlers))
+	for _, hp := range handlers {
+		seen[hp] = true
+		if !hp.queueFull() {
+			delete(w.fullSince, hp)
+			delete(w.alerted, hp)
+			continue
+		}
+		since, ok := w.fullSince[hp]
+		if !ok {
+			w.fullSince[hp] = now
+			since = now
+		}
+		if w.alerted[hp] || now.Sub(since) < after {
+			continue
+		}
+		w.alerted[hp] = true
+		out = append(out, QueueFullEvent{
+			UUID:       hp.UUID,
+			DeviceType: hp.DeviceType,
+			Capacity:   cap(hp.writeCh),
+			FullFor:    int64(now.Sub(since).Seconds()),
+			Overflows:  hp.overflows.Load(),
+		})
+	}
+	for hp := range w.fullSince {
+		if !seen[hp] {
+			delete(w.fullSince, hp)
+			delete(w.alerted, hp)
+		}
+	}
+	return out
+}
+
+// WatchQueues publishes handler.{uuid}.queue_full once a handler's stdin
+// queue has stayed full for handlers.queue_full_alert, until ctx is
+// cancelled. It does nothing when the alert is off.
+func WatchQueues(ctx context.Context, bus comms.Bus) {
+	after := shared.AppConfig.Handlers.QueueFullAlertAfter()
+	if after <= 0 || bus == nil {
+		return
+	}
+	go func() {
+		w := &queueWatch{
+			fullSince: make(map[*HandlerProcess]time.Time),
+			alerted:   make(map[*HandlerProcess]bool),
+		}
+		ticker := time.NewTicker(queueCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, ev := range w.check(HandlerManager.all(), after, now) {
+					shared.DebugPrint("Handler %s queue full for %ds (%d overflows)", ev.UUID, ev.FullFor, ev.Overflows)
+					bus.PublishEvent(events.HandlerQueueFull(ev.UUID), ev)
+				}
+			}
+		}
+	}()
+}
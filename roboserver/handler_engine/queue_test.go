@@ -0,0 +1,47 @@
+package handler_engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendToScriptCountsOverflows(t *testing.T) {
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 1)}
+	hp.sendToScript(map[string]string{"type": "a"})
+	hp.sendToScript(map[string]string{"type": "b"})
+	hp.sendToScript(map[string]string{"type": "c"})
+	if n := hp.overflows.Load(); n != 2 {
+		t.Errorf("Expected 2 overflows, got %d", n)
+	}
+	if s := hp.Snapshot(); s.QueueLength != 1 || s.QueueCapacity != 1 || s.QueueOverflows != 2 {
+		t.Errorf("Unexpected snapshot %+v", s)
+	}
+}
+
+func TestQueueWatchAlertsOncePerFullSpell(t *testing.T) {
+	hp := &HandlerProcess{UUID: "r1", DeviceType: "lamp", writeCh: make(chan []byte, 1)}
+	w := &queueWatch{fullSince: map[*HandlerProcess]time.Time{}, alerted: map[*HandlerProcess]bool{}}
+	handlers := []*HandlerProcess{hp}
+	start := time.Now()
+
+	if evs := w.check(handlers, 10*time.Second, start); len(evs) != 0 {
+		t.Fatalf("Expected no alert for an empty queue, got %+v", evs)
+	}
+	hp.writeCh <- []byte("x")
+	if evs := w.check(handlers, 10*time.Second, start); len(evs) != 0 {
+		t.Fatalf("Expected no alert as the queue fills, got %+v", evs)
+	}
+	evs := w.check(handlers, 10*time.Second, start.Add(11*time.Second))
+	if len(evs) != 1 || evs[0].UUID != "r1" || evs[0].Capacity != 1 || evs[0].FullFor != 11 {
+		t.Fatalf("Expected one alert after 11s, got %+v", evs)
+	}
+	if evs := w.check(handlers, 10*time.Second, start.Add(20*time.Second)); len(evs) != 0 {
+		t.Fatalf("Expected no repeat alert, got %+v", evs)
+	}
+
+	<-hp.writeCh
+	w.check(handlers, 10*time.Second, start.Add(21*time.Second))
+	if len(w.fullSince) != 0 || len(w.alerted) != 0 {
+		t.Error("Expected a drained queue to reset the watch")
+	}
+}
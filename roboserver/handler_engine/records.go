@@ -0,0 +1,60 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/cluster"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+)
+
+// WatchRecordChanges stops the local handler of every robot whose registry
+// record was deleted or blacklisted, on whichever node made the change.
+func WatchRecordChanges(ctx context.Context, bus comms.Bus) error {
+	cancel, err := bus.SubscribeMatching(events.IsRobotRecord, func(_ string, data any) {
+		if change, ok := events.DecodeRecordChange(data); ok {
+			stopRevoked(change)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return nil
+}
+
+func stopRevoked(change events.RecordChange) {
+	if change.Change != "deleted" && !(change.Change == "blacklist" && change.Blacklisted) {
+		return
+	}
+	hp, ok := HandlerManager.Get(change.UUID)
+	if !ok {
+		return
+	}
+	shared.DebugPrint("Robot %s %s, stopping its handler", change.UUID, change.Change)
+	// Stop waits for the script to exit; don't hold up the publisher.
+	go hp.Stop(change.Change)
+}
+
+// PublishDatabaseChanges publishes a robot.{uuid}.record event (source
+// "database") for every change another application makes to the robots
+// table, until ctx is cancelled. Every node listens, but only the cluster
+// leader publishes, so each change is announced once.
+func PublishDatabaseChanges(ctx context.Context, bus comms.Bus) error {
+	return database.WatchRobotChanges(ctx, func(c database.RobotChange) {
+		if !cluster.IsLeader() {
+			return
+		}
+		shared.DebugPrint("Robot %s %s outside the server", c.UUID, c.Change)
+		bus.PublishEvent(events.RobotRecord(c.UUID), events.RecordChange{
+			UUID:        c.UUID,
+			Change:      c.Change,
+			Blacklisted: c.Blacklisted,
+			Source:      "database",
+		})
+	})
+}
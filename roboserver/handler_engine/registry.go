@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"roboserver/shared"
+	"sort"
+	"strings"
 )
 
 // validDeviceType matches only safe device type names: alphanumeric, hyphens, underscores, max 64 chars.
@@ -16,68 +18,132 @@ func IsValidDeviceType(dt string) bool {
 	return validDeviceType.MatchString(dt)
 }
 
-// ResolveHandlerScript returns the absolute path to the handler script for a device type.
-// It looks for: {base_path}/{deviceType}/start_handler.sh
-func ResolveHandlerScript(deviceType string) (string, error) {
-	if !IsValidDeviceType(deviceType) {
-		return "", fmt.Errorf("invalid device type %q: must be alphanumeric/hyphens/underscores, max 64 chars", deviceType)
-	}
-	basePath := shared.AppConfig.Handlers.BasePath
-	scriptPath := filepath.Join(basePath, deviceType, "start_handler.sh")
+// handlerRoots returns the directories holding {deviceType}/ handler
+// directories, in search order: handlers.base_path, then
+// handlers.plugin_dirs.
+func handlerRoots() []string {
+	return append([]string{shared.AppConfig.Handlers.BasePath}, shared.AppConfig.Handlers.PluginDirs...)
+}
 
-	absPath, err := filepath.Abs(scriptPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve handler path: %w", err)
+// findHandlerDir returns the first {root}/{deviceType} directory with a
+// start_handler.sh, or "" when no root has one.
+func findHandlerDir(deviceType string) string {
+	for _, root := range handlerRoots() {
+		dir := filepath.Join(root, deviceType)
+		if info, err := os.Stat(filepath.Join(dir, "start_handler.sh")); err == nil && !info.IsDir() {
+			return dir
+		}
 	}
+	return ""
+}
 
-	info, err := os.Stat(absPath)
+// ResolveHandlerScript returns the absolute path to the handler script for a device type.
+// It looks for {root}/{deviceType}/start_handler.sh in base_path, then each plugin dir.
+func ResolveHandlerScript(deviceType string) (string, error) {
+	dir, err := ResolveHandlerDir(deviceType)
 	if err != nil {
-		return "", fmt.Errorf("handler script not found for device type %q: %w", deviceType, err)
+		return "", err
 	}
-	if info.IsDir() {
-		return "", fmt.Errorf("handler path is a directory, not a script: %s", absPath)
-	}
-
-	return absPath, nil
+	return filepath.Join(dir, "start_handler.sh"), nil
 }
 
 // ResolveHandlerDir returns the absolute path to the handler directory for a device type.
 func ResolveHandlerDir(deviceType string) (string, error) {
-	basePath := shared.AppConfig.Handlers.BasePath
-	dirPath := filepath.Join(basePath, deviceType)
-
-	absPath, err := filepath.Abs(dirPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve handler dir: %w", err)
+	if !IsValidDeviceType(deviceType) {
+		return "", fmt.Errorf("invalid device type %q: must be alphanumeric/hyphens/underscores, max 64 chars", deviceType)
+	}
+	dir := findHandlerDir(deviceType)
+	if dir == "" {
+		return "", fmt.Errorf("handler script not found for device type %q in %s", deviceType, strings.Join(handlerRoots(), ", "))
 	}
 
-	info, err := os.Stat(absPath)
+	absPath, err := filepath.Abs(dir)
 	if err != nil {
-		return "", fmt.Errorf("handler directory not found for device type %q: %w", deviceType, err)
-	}
-	if !info.IsDir() {
-		return "", fmt.Errorf("handler path is not a directory: %s", absPath)
+		return "", fmt.Errorf("failed to resolve handler path: %w", err)
 	}
-
 	return absPath, nil
 }
 
-// ListHandlerTypes returns all device types that have handler directories.
+// ListHandlerTypes returns all device types that have handler directories,
+// across base_path and the plugin dirs, sorted by name.
 func ListHandlerTypes() []string {
-	basePath := shared.AppConfig.Handlers.BasePath
-	entries, err := os.ReadDir(basePath)
-	if err != nil {
-		return nil
-	}
-
+	seen := make(map[string]bool)
 	var types []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			scriptPath := filepath.Join(basePath, entry.Name(), "start_handler.sh")
+	for _, root := range handlerRoots() {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || seen[entry.Name()] {
+				continue
+			}
+			scriptPath := filepath.Join(root, entry.Name(), "start_handler.sh")
 			if _, err := os.Stat(scriptPath); err == nil {
+				seen[entry.Name()] = true
 				types = append(types, entry.Name())
 			}
 		}
 	}
+	sort.Strings(types)
+	return types
+}
+
+// LogHandlerTypes reports the installed device types at startup, with the
+// plugin dir each third-party type came from and any type a plugin dir
+// defines that an earlier root already does (the earlier one is used).
+func LogHandlerTypes() {
+	roots := handlerRoots()
+	owner := make(map[string]string)
+	for _, root := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			if root != roots[0] {
+				shared.DebugPrint("Handler plugin dir %s unreadable: %v", root, err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() || !IsValidDeviceType(name) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(root, name, "start_handler.sh")); err != nil {
+				continue
+			}
+			if first, ok := owner[name]; ok {
+				shared.DebugPrint("Device type %s in %s is shadowed by %s", name, root, first)
+				continue
+			}
+			owner[name] = root
+			if root != roots[0] {
+				shared.DebugPrint("Loaded device type %s from plugin dir %s", name, root)
+			}
+		}
+	}
+	shared.DebugPrint("Device types: %s", strings.Join(ListHandlerTypes(), ", "))
+}
+
+// TemplateType is the handler directory new robot types are copied from.
+// It has a handler script but is not a type robots can register as.
+const TemplateType = "_template"
+
+// RobotType is an installed device type, as offered to users picking one.
+type RobotType struct {
+	Type   string `json:"type"`
+	Plugin bool   `json:"plugin"` // a compiled frontend plugin is served under /plugins/{type}/
+}
+
+// ListRobotTypes returns the device types robots may register as: those
+// with handler scripts except TemplateType, sorted by name.
+func ListRobotTypes() []RobotType {
+	types := []RobotType{}
+	for _, t := range ListHandlerTypes() {
+		if t == TemplateType {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(findHandlerDir(t), "dist"))
+		types = append(types, RobotType{Type: t, Plugin: err == nil && info.IsDir()})
+	}
 	return types
 }
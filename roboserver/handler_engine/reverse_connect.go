@@ -8,6 +8,9 @@ import (
 	"net"
 	"roboserver/auth"
 	"roboserver/shared"
+	"roboserver/shared/tracked"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,7 +19,7 @@ import (
 type ReverseConnectRequest struct {
 	Port     int    `json:"port"`               // TCP port on the robot to connect to
 	Protocol string `json:"protocol,omitempty"`  // "tcp" (default) or "udp"
-	IP       string `json:"ip,omitempty"`        // Override IP (optional; defaults to last known)
+	IP       string `json:"ip,omitempty"`        // Override IP (optional; defaults to the robot's known addresses)
 }
 
 // handleConnectRobotRequest processes a handler's request to connect to its robot.
@@ -43,63 +46,79 @@ func (hp *HandlerProcess) handleConnectRobotRequest(ctx context.Context, env *JS
 		protocol = "tcp"
 	}
 
-	// Resolve robot IP
-	ip := req.IP
-	if ip == "" {
-		ip = hp.resolveRobotIP(ctx)
+	// Resolve robot addresses; the handler's override wins
+	ips := hp.resolveRobotIPs(ctx)
+	if req.IP != "" {
+		ips = []string{shared.CanonicalIP(req.IP)}
 	}
-	if ip == "" {
+	if len(ips) == 0 {
 		hp.sendResponse(env.ID, nil, "cannot determine robot IP")
 		return
 	}
-
-	addr := fmt.Sprintf("%s:%d", ip, req.Port)
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, strconv.Itoa(req.Port))
+	}
 
 	switch protocol {
 	case "tcp":
 		hp.wg.Add(1)
-		go func() {
+		tracked.Go("handler.reverse_connect", func() {
 			defer hp.wg.Done()
-			hp.reverseConnectTCP(ctx, env.ID, addr)
-		}()
+			hp.reverseConnectTCP(ctx, env.ID, addrs)
+		})
 	case "udp":
 		hp.wg.Add(1)
-		go func() {
+		tracked.Go("handler.reverse_connect", func() {
 			defer hp.wg.Done()
-			hp.reverseConnectUDP(ctx, env.ID, addr)
-		}()
+			// UDP can't tell a dead address from a live one; use the latest.
+			hp.reverseConnectUDP(ctx, env.ID, addrs[0])
+		})
 	default:
 		hp.sendResponse(env.ID, nil, "unsupported protocol: "+protocol)
 	}
 }
 
-// resolveRobotIP looks up the robot's IP from heartbeat state or active session.
-func (hp *HandlerProcess) resolveRobotIP(ctx context.Context) string {
-	if hp.rds == nil {
-		return hp.IP // Fall back to last known IP from spawn time
-	}
-
-	// Check heartbeat state first (most up-to-date)
-	if hb, _ := hp.rds.GetHeartbeat(ctx, hp.UUID); hb != nil {
-		return hb.IP
+// resolveRobotIPs lists the addresses the robot may be reached at, best
+// first: heartbeat addresses (most up-to-date), then the active session's,
+// then the last IP the handler saw (from spawn or the latest reattach).
+// The robot is identified by its UUID; addresses are only hints, and a
+// robot with wifi and ethernet has several.
+func (hp *HandlerProcess) resolveRobotIPs(ctx context.Context) []string {
+	var ips []string
+	add := func(candidates ...string) {
+		for _, ip := range candidates {
+			if ip = shared.CanonicalIP(ip); ip != "" && !slices.Contains(ips, ip) {
+				ips = append(ips, ip)
+			}
+		}
 	}
-
-	// Check active session
-	if active, _ := hp.rds.GetActiveRobot(ctx, hp.UUID); active != nil {
-		return active.IP
+	if hp.rds != nil {
+		if hb, _ := hp.rds.GetHeartbeat(ctx, hp.UUID); hb != nil {
+			add(hb.Addresses()...)
+		}
+		if active, _ := hp.rds.GetActiveRobot(ctx, hp.UUID); active != nil {
+			add(active.Addresses()...)
+		}
 	}
-
-	return hp.IP
+	add(hp.Snapshot().IP)
+	return ips
 }
 
-// reverseConnectTCP dials the robot, performs a mutual AUTH handshake, then
-// bridges the connection to the handler's stdin/stdout.
-func (hp *HandlerProcess) reverseConnectTCP(ctx context.Context, requestID, addr string) {
-	shared.DebugPrint("Reverse TCP connect to robot %s at %s", hp.UUID, addr)
-
-	conn, err := net.DialTimeout("tcp", addr, shared.AppConfig.Timeouts.ReverseConnectTimeout())
-	if err != nil {
+// reverseConnectTCP dials the robot at the first of addrs that answers,
+// performs a mutual AUTH handshake, then bridges the connection to the
+// handler's stdin/stdout.
+func (hp *HandlerProcess) reverseConnectTCP(ctx context.Context, requestID string, addrs []string) {
+	var conn net.Conn
+	var err error
+	for _, addr := range addrs {
+		shared.DebugPrint("Reverse TCP connect to robot %s at %s", hp.UUID, addr)
+		if conn, err = net.DialTimeout("tcp", addr, shared.AppConfig.Timeouts.ReverseConnectTimeout()); err == nil {
+			break
+		}
 		shared.DebugPrint("Reverse connect failed for %s: %v", hp.UUID, err)
+	}
+	if err != nil {
 		hp.sendResponse(requestID, nil, "connection failed: "+err.Error())
 		return
 	}
@@ -115,7 +134,7 @@ func (hp *HandlerProcess) reverseConnectTCP(ctx context.Context, requestID, addr
 	// Wait for robot acknowledgment
 	conn.SetReadDeadline(time.Now().Add(shared.AppConfig.Timeouts.HandshakeTimeout()))
 	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 64*1024)
+	shared.AppConfig.Limits.LimitScanner(scanner)
 
 	if !scanner.Scan() {
 		hp.sendResponse(requestID, nil, "robot did not respond")
@@ -0,0 +1,244 @@
+package handler_engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/tracing"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrScheduleFull      = errors.New("too many scheduled messages")
+	ErrScheduleNotFound  = errors.New("scheduled message not found")
+	ErrSchedulerStopped  = errors.New("message scheduler is not running")
+	ErrInvalidSchedule   = errors.New("invalid schedule")
+	errNoHandlerForRobot = errors.New("no handler running for this robot")
+)
+
+// ScheduledMessage is a message waiting to be delivered to a robot's
+// handler, once or every EveryMs.
+type ScheduledMessage struct {
+	ID        string `json:"id"`
+	UUID      string `json:"uuid"`
+	Message   string `json:"message"`
+	CreatedBy string `json:"created_by,omitempty"`
+	At        int64  `json:"at"`                 // next delivery, Unix milliseconds
+	EveryMs   int64  `json:"every_ms,omitempty"` // 0 = deliver once
+	Runs      int    `json:"runs"`               // deliveries attempted so far
+	LastError string `json:"last_error,omitempty"`
+	CreatedAt int64  `json:"created_at"` // Unix seconds
+}
+
+type scheduledEntry struct {
+	msg   ScheduledMessage
+	timer *time.Timer
+}
+
+// Scheduler delivers messages to robots' handlers at a later time, like
+// automated API messages: maintenance.suppress applies, and in a cluster a
+// robot connected to another node gets the message there. Schedules live
+// in this node's memory, so they do not survive a restart.
+var Scheduler = &messageScheduler{entries: make(map[string]*scheduledEntry)}
+
+type messageScheduler struct {
+	mu      sync.Mutex
+	ctx     context.Context // nil until Start
+	bus     comms.Bus
+	db      database.DBManager
+	entries map[string]*scheduledEntry
+}
+
+// Start lets the scheduler deliver through bus and db until ctx is
+// cancelled, which drops every pending message.
+func (s *messageScheduler) Start(ctx context.Context, bus comms.Bus, db database.DBManager) {
+	s.mu.Lock()
+	s.ctx, s.bus, s.db = ctx, bus, db
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for id, e := range s.entries {
+			e.timer.Stop()
+			delete(s.entries, id)
+		}
+	}()
+}
+
+// SendMessageAfter schedules message for uuid's handler in d, repeating
+// every `every` if it is not 0. createdBy is the user it is sent for.
+func (s *messageScheduler) SendMessageAfter(uuid, message, createdBy string, d, every time.Duration) (ScheduledMessage, error) {
+	return s.SendMessageAt(uuid, message, createdBy, time.Now().Add(d), every)
+}
+
+// SendMessageAt schedules message for uuid's handler at at (now if it has
+// passed), repeating every `every` if it is not 0.
+func (s *messageScheduler) SendMessageAt(uuid, message, createdBy string, at time.Time, every time.Duration) (ScheduledMessage, error) {
+	cfg := &shared.AppConfig.Schedule
+	if !IsValidUUID(uuid) || message == "" {
+		return ScheduledMessage{}, fmt.Errorf("%w: a robot and a message are required", ErrInvalidSchedule)
+	}
+	if every != 0 && every < cfg.MinEvery() {
+		return ScheduledMessage{}, fmt.Errorf("%w: repeat interval must be at least %v", ErrInvalidSchedule, cfg.MinEvery())
+	}
+	now := time.Now()
+	if at.Sub(now) > cfg.MaxWait() {
+		return ScheduledMessage{}, fmt.Errorf("%w: delivery must be within %v", ErrInvalidSchedule, cfg.MaxWait())
+	}
+	if err := shared.CheckPayloadSize("handler", len(message), shared.AppConfig.Limits.HandlerMessageBytes()); err != nil {
+		return ScheduledMessage{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return ScheduledMessage{}, ErrSchedulerStopped
+	}
+	if len(s.entries) >= max(cfg.MaxPending, 1) {
+		return ScheduledMessage{}, ErrScheduleFull
+	}
+	e := &scheduledEntry{msg: ScheduledMessage{
+		ID:        newScheduleID(),
+		UUID:      uuid,
+		Message:   message,
+		CreatedBy: createdBy,
+		At:        at.UnixMilli(),
+		EveryMs:   every.Milliseconds(),
+		CreatedAt: now.Unix(),
+	}}
+	id := e.msg.ID
+	e.timer = time.AfterFunc(max(at.Sub(now), 0), func() { s.fire(id) })
+	s.entries[id] = e
+	return e.msg, nil
+}
+
+// Get returns a scheduled message by id.
+func (s *messageScheduler) Get(id string) (ScheduledMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return ScheduledMessage{}, false
+	}
+	return e.msg, true
+}
+
+// List returns the pending messages for uuid ("" = every robot), soonest
+// first.
+func (s *messageScheduler) List(uuid string) []ScheduledMessage {
+	s.mu.Lock()
+	out := []ScheduledMessage{}
+	for _, e := range s.entries {
+		if uuid == "" || e.msg.UUID == uuid {
+			out = append(out, e.msg)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].At != out[j].At {
+			return out[i].At < out[j].At
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Cancel stops a scheduled message. A delivery already under way still
+// completes.
+func (s *messageScheduler) Cancel(id string) (ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return ScheduledMessage{}, ErrScheduleNotFound
+	}
+	e.timer.Stop()
+	delete(s.entries, id)
+	return e.msg, nil
+}
+
+// fire delivers a due message, then reschedules it if it repeats. Repeats
+// missed while a delivery was slow are skipped rather than sent in a burst.
+func (s *messageScheduler) fire(id string) {
+	s.mu.Lock()
+	e, ok := s.entries[id]
+	if !ok || s.ctx == nil {
+		s.mu.Unlock()
+		return
+	}
+	msg, ctx, bus, db := e.msg, s.ctx, s.bus, s.db
+	s.mu.Unlock()
+
+	sendCtx, cancel := context.WithTimeout(ctx, DefaultSendTimeout)
+	var rds *database.RedisHandler
+	if db != nil {
+		rds = db.Redis()
+	}
+	err := SendAutomated(sendCtx, bus, rds, msg.UUID, msg.Message, "schedule:"+msg.CreatedBy)
+	cancel()
+	if err != nil {
+		shared.DebugPrint("Scheduled message %s for %s failed: %v", id, msg.UUID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok = s.entries[id]; !ok {
+		return // cancelled meanwhile
+	}
+	e.msg.Runs++
+	e.msg.LastError = ""
+	if err != nil {
+		e.msg.LastError = err.Error()
+	}
+	if e.msg.EveryMs <= 0 {
+		delete(s.entries, id)
+		return
+	}
+	every := time.Duration(e.msg.EveryMs) * time.Millisecond
+	next := time.UnixMilli(e.msg.At).Add(every)
+	if now := time.Now(); next.Before(now) {
+		next = now.Add(every - now.Sub(next)%every)
+	}
+	e.msg.At = next.UnixMilli()
+	e.timer.Reset(time.Until(next))
+}
+
+// SendAutomated delivers an automated message (rule, script or schedule)
+// to uuid's handler, here or, in a cluster, on the node holding the robot.
+// actor is recorded as its sender. A robot in maintenance has the message
+// held or dropped per maintenance.suppress, reported as an error.
+func SendAutomated(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid, message, actor string) error {
+	if status := HoldAutomated(ctx, rds, uuid, message); status != "" {
+		return fmt.Errorf("robot is in maintenance (%s)", status)
+	}
+	if hp, ok := HandlerManager.Get(uuid); ok {
+		return hp.SendIncomingAsContext(ctx, message, actor)
+	}
+	if !shared.AppConfig.Cluster.Enabled || rds == nil || bus == nil {
+		return errNoHandlerForRobot
+	}
+	active, err := rds.GetActiveRobot(ctx, uuid)
+	if err != nil || active == nil || active.Node == "" || active.Node == shared.NodeID() {
+		return errNoHandlerForRobot
+	}
+	if err := shared.CheckPayloadSize("handler", len(message), shared.AppConfig.Limits.HandlerMessageBytes()); err != nil {
+		return err
+	}
+	msg := events.ForwardedMessage{Message: message, Actor: actor, TraceParent: tracing.Inject(ctx)}
+	return comms.PublishContext(ctx, bus, events.HandlerIncoming(uuid), msg)
+}
+
+func newScheduleID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
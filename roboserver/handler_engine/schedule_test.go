@@ -0,0 +1,127 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"roboserver/shared"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startedScheduler(t *testing.T) *messageScheduler {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s := &messageScheduler{entries: make(map[string]*scheduledEntry)}
+	s.Start(ctx, nil, nil)
+	return s
+}
+
+func receive(t *testing.T, hp *HandlerProcess) string {
+	t.Helper()
+	select {
+	case msg := <-hp.writeCh:
+		return string(msg)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the scheduled message")
+		return ""
+	}
+}
+
+func TestSchedulerDeliversOnce(t *testing.T) {
+	hp := &HandlerProcess{UUID: "sched-1", writeCh: make(chan []byte, 4)}
+	HandlerManager.Register(hp)
+	defer HandlerManager.Unregister(hp.UUID)
+
+	s := startedScheduler(t)
+	msg, err := s.SendMessageAfter(hp.UUID, "LOCK", "alice", 10*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("SendMessageAfter failed: %v", err)
+	}
+	if got := s.List(hp.UUID); len(got) != 1 || got[0].ID != msg.ID {
+		t.Fatalf("Expected the message to be pending, got %+v", got)
+	}
+	if got := receive(t, hp); !strings.Contains(got, `"payload":"LOCK"`) || !strings.Contains(got, `"actor":"schedule:alice"`) {
+		t.Errorf("Unexpected message written: %s", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(s.List("")) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s.List(""); len(got) != 0 {
+		t.Errorf("Expected a one-off message to be gone after delivery, got %+v", got)
+	}
+}
+
+func TestSchedulerRepeatsUntilCancelled(t *testing.T) {
+	shared.AppConfig.Schedule.MinInterval = "1ms"
+	defer func() { shared.AppConfig.Schedule.MinInterval = "" }()
+	hp := &HandlerProcess{UUID: "sched-2", writeCh: make(chan []byte, 16)}
+	HandlerManager.Register(hp)
+	defer HandlerManager.Unregister(hp.UUID)
+
+	s := startedScheduler(t)
+	msg, err := s.SendMessageAfter(hp.UUID, "ping", "", 0, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("SendMessageAfter failed: %v", err)
+	}
+	receive(t, hp)
+	receive(t, hp)
+
+	if _, err := s.Cancel(msg.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if _, err := s.Cancel(msg.ID); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("Expected ErrScheduleNotFound on a second cancel, got %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n := len(hp.writeCh); n > 1 { // one delivery may have been under way
+		t.Errorf("Expected deliveries to stop after Cancel, got %d more", n)
+	}
+}
+
+func TestSchedulerRecordsFailures(t *testing.T) {
+	s := startedScheduler(t)
+	msg, err := s.SendMessageAfter("sched-missing", "LOCK", "", time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("SendMessageAfter failed: %v", err)
+	}
+	s.fire(msg.ID)
+	got, ok := s.Get(msg.ID)
+	if !ok || got.Runs != 1 || got.LastError == "" {
+		t.Errorf("Expected a failed run to be recorded and the message kept, got %+v", got)
+	}
+	if got.At <= msg.At {
+		t.Errorf("Expected the next delivery after %d, got %d", msg.At, got.At)
+	}
+}
+
+func TestSchedulerRejects(t *testing.T) {
+	stopped := &messageScheduler{entries: make(map[string]*scheduledEntry)}
+	if _, err := stopped.SendMessageAfter("r1", "x", "", time.Second, 0); !errors.Is(err, ErrSchedulerStopped) {
+		t.Errorf("Expected ErrSchedulerStopped before Start, got %v", err)
+	}
+
+	shared.AppConfig.Schedule.MaxPending = 1
+	defer func() { shared.AppConfig.Schedule.MaxPending = 0 }()
+	s := startedScheduler(t)
+	for name, err := range map[string]error{
+		"bad uuid":       second(s.SendMessageAfter("../r1", "x", "", time.Second, 0)),
+		"empty message":  second(s.SendMessageAfter("r1", "", "", time.Second, 0)),
+		"short interval": second(s.SendMessageAfter("r1", "x", "", time.Second, time.Millisecond)),
+		"too far ahead":  second(s.SendMessageAfter("r1", "x", "", 30*24*time.Hour, 0)),
+	} {
+		if !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s: expected ErrInvalidSchedule, got %v", name, err)
+		}
+	}
+	if _, err := s.SendMessageAfter("r1", "x", "", time.Hour, 0); err != nil {
+		t.Fatalf("SendMessageAfter failed: %v", err)
+	}
+	if _, err := s.SendMessageAfter("r1", "y", "", time.Hour, 0); !errors.Is(err, ErrScheduleFull) {
+		t.Errorf("Expected ErrScheduleFull, got %v", err)
+	}
+}
+
+func second(_ ScheduledMessage, err error) error { return err }
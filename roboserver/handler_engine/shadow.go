@@ -0,0 +1,181 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/shadow"
+	"time"
+)
+
+// shadowLoadTimeout bounds the Redis read that fetches a connecting
+// robot's shadow.
+const shadowLoadTimeout = 2 * time.Second
+
+// UpdateDesired merges patch into a robot's desired state. version, when
+// not 0, must be the shadow's current version or the update fails with
+// shadow.ErrVersionConflict. The change is published on
+// robot.{uuid}.shadow, from which the robot's handler, on whichever node it
+// runs, picks up the delta; an offline robot gets it when it connects.
+func UpdateDesired(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid string, patch map[string]any, version int64) (*shadow.Shadow, error) {
+	if err := shadow.Validate(patch); err != nil {
+		return nil, err
+	}
+	s, err := rds.UpdateShadow(ctx, uuid, func(s *shadow.Shadow) error {
+		if version != 0 && s.Version != version {
+			return shadow.ErrVersionConflict
+		}
+		s.Desired = shadow.Merge(s.Desired, patch)
+		s.DesiredAt = time.Now().Unix()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	publishShadow(bus, s, shadow.SourceDesired)
+	return s, nil
+}
+
+// ReportShadow merges patch into a robot's reported state.
+func ReportShadow(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid string, patch map[string]any) (*shadow.Shadow, error) {
+	if err := shadow.Validate(patch); err != nil {
+		return nil, err
+	}
+	s, err := rds.UpdateShadow(ctx, uuid, func(s *shadow.Shadow) error {
+		s.Reported = shadow.Merge(s.Reported, patch)
+		s.ReportedAt = time.Now().Unix()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	publishShadow(bus, s, shadow.SourceReported)
+	return s, nil
+}
+
+// DeleteShadow forgets a robot's desired and reported state. found is
+// false if it had none.
+func DeleteShadow(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, uuid string) (found bool, err error) {
+	found, err = rds.DeleteShadow(ctx, uuid)
+	if err != nil || !found {
+		return found, err
+	}
+	if bus != nil {
+		bus.PublishEvent(events.RobotShadow(uuid), shadow.Event{UUID: uuid, Source: shadow.SourceDeleted})
+	}
+	return true, nil
+}
+
+func publishShadow(bus comms.Bus, s *shadow.Shadow, source string) {
+	if bus == nil {
+		return
+	}
+	bus.PublishEvent(events.RobotShadow(s.UUID), shadow.Event{
+		UUID:    s.UUID,
+		Source:  source,
+		Version: s.Version,
+		Delta:   s.Delta(),
+		Shadow:  s,
+	})
+}
+
+// onShadowEvent forwards the delta of a desired-state change to the
+// handler while its robot is connected. Disconnected robots get the delta
+// from pushShadowDelta when they come back.
+func (hp *HandlerProcess) onShadowEvent(data any) {
+	ev, ok := shadow.DecodeEvent(data)
+	if !ok || ev.Source != shadow.SourceDesired || len(ev.Delta) == 0 {
+		return
+	}
+	hp.mu.Lock()
+	connected := hp.RobotSend != nil
+	hp.mu.Unlock()
+	if !connected {
+		return
+	}
+	hp.sendToScript(&ShadowDeltaMessage{
+		Type:    MsgTypeShadowDelta,
+		UUID:    hp.UUID,
+		State:   ev.Delta,
+		Version: ev.Version,
+	})
+}
+
+// pushShadowDelta sends the handler whatever desired state its robot has
+// not reported yet. It runs when the robot connects, after the connect
+// message.
+func (hp *HandlerProcess) pushShadowDelta() {
+	if hp.rds == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shadowLoadTimeout)
+	defer cancel()
+	s, err := hp.rds.GetShadow(ctx, hp.UUID)
+	if err != nil {
+		shared.DebugPrint("Handler %s: failed to load shadow: %v", hp.UUID, err)
+		return
+	}
+	delta := s.Delta()
+	if len(delta) == 0 {
+		return
+	}
+	shared.DebugPrint("Handler %s: pushing shadow delta (version %d, %d fields)", hp.UUID, s.Version, len(delta))
+	hp.sendToScript(&ShadowDeltaMessage{
+		Type:    MsgTypeShadowDelta,
+		UUID:    hp.UUID,
+		State:   delta,
+		Version: s.Version,
+	})
+}
+
+// shadowDocument is a shadow as handlers and the API see it: both states
+// plus the delta between them.
+type shadowDocument struct {
+	*shadow.Shadow
+	Delta map[string]any `json:"delta"`
+}
+
+// ShadowDocument returns s with its delta, for handlers and API replies.
+func ShadowDocument(s *shadow.Shadow) any {
+	delta := s.Delta()
+	if delta == nil {
+		delta = map[string]any{}
+	}
+	return shadowDocument{Shadow: s, Delta: delta}
+}
+
+// handleShadowRequest serves the "shadow" target: "get" returns the
+// robot's shadow, "report" merges data into its reported state.
+func (hp *HandlerProcess) handleShadowRequest(ctx context.Context, env *JSONRPCEnvelope) {
+	if hp.rds == nil {
+		hp.sendResponse(env.ID, nil, "redis not available")
+		return
+	}
+	switch env.Method {
+	case "get":
+		s, err := hp.rds.GetShadow(ctx, hp.UUID)
+		if err != nil {
+			hp.sendResponse(env.ID, nil, err.Error())
+			return
+		}
+		hp.sendResponse(env.ID, ShadowDocument(s), "")
+
+	case "report":
+		patch, ok := env.Data.(map[string]interface{})
+		if !ok {
+			hp.sendResponse(env.ID, nil, "data must be an object of reported state")
+			return
+		}
+		s, err := ReportShadow(ctx, hp.bus, hp.rds, hp.UUID, patch)
+		if err != nil {
+			hp.sendResponse(env.ID, nil, err.Error())
+			return
+		}
+		hp.sendResponse(env.ID, ShadowDocument(s), "")
+
+	default:
+		hp.sendResponse(env.ID, nil, "unknown shadow method: "+env.Method)
+	}
+}
@@ -0,0 +1,42 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"roboserver/shared/shadow"
+	"testing"
+)
+
+func TestOnShadowEventPushesDeltaWhileConnected(t *testing.T) {
+	hp := &HandlerProcess{UUID: "thermo-1", writeCh: make(chan []byte, 4)}
+	s := &shadow.Shadow{UUID: "thermo-1", Desired: map[string]any{"target_c": 21.5}, Reported: map[string]any{}, Version: 3}
+	ev := map[string]any{"uuid": "thermo-1", "source": shadow.SourceDesired, "version": 3, "delta": s.Delta()}
+
+	hp.onShadowEvent(ev)
+	if len(hp.writeCh) != 0 {
+		t.Fatal("Expected no delta for a disconnected robot")
+	}
+
+	hp.RobotSend = func([]byte) error { return nil }
+	hp.onShadowEvent(shadow.Event{UUID: "thermo-1", Source: shadow.SourceReported, Version: 4, Delta: s.Delta()})
+	if len(hp.writeCh) != 0 {
+		t.Fatal("Expected reports not to push the delta again")
+	}
+
+	hp.onShadowEvent(ev) // relayed events arrive as decoded JSON
+	var msg ShadowDeltaMessage
+	json.Unmarshal(<-hp.writeCh, &msg)
+	if msg.Type != MsgTypeShadowDelta || msg.Version != 3 || msg.State["target_c"] != 21.5 {
+		t.Errorf("Unexpected delta message: %+v", msg)
+	}
+}
+
+func TestShadowRequestWithoutRedis(t *testing.T) {
+	hp := &HandlerProcess{UUID: "thermo-2", writeCh: make(chan []byte, 4)}
+	hp.handleShadowRequest(context.Background(), &JSONRPCEnvelope{ID: "1", Target: TargetShadow, Method: "report", Data: map[string]any{"on": true}})
+	var resp JSONRPCEnvelope
+	json.Unmarshal(<-hp.writeCh, &resp)
+	if resp.ID != "1" || resp.Error == "" {
+		t.Errorf("Expected an error response, got %+v", resp)
+	}
+}
@@ -0,0 +1,71 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// drainPollInterval is how often Drain checks the handlers' queues.
+const drainPollInterval = 20 * time.Millisecond
+
+// ShutdownNotice is written to every connected robot when the server shuts
+// down, before its handler stops and its connection closes:
+//
+//	{"type":"shutdown","reason":"server_shutdown","downtime_s":30}
+//
+// Firmware should wait about downtime_s (when not 0) before reconnecting
+// instead of retrying at once.
+type ShutdownNotice struct {
+	Type     string `json:"type"`
+	Reason   string `json:"reason,omitempty"`
+	Downtime int64  `json:"downtime_s"` // expected seconds until the server is back; 0 = unknown
+}
+
+// NotifyShutdown sends a ShutdownNotice announcing downtime to the robot of
+// every handler on this node that has a connection, and returns how many
+// were told. Robots inside their reconnect grace period are skipped.
+func (m *handlerManager) NotifyShutdown(reason string, downtime time.Duration) int {
+	data, _ := json.Marshal(ShutdownNotice{
+		Type:     "shutdown",
+		Reason:   reason,
+		Downtime: int64(downtime.Round(time.Second).Seconds()),
+	})
+	notified := 0
+	for _, hp := range m.all() {
+		hp.mu.Lock()
+		send := hp.RobotSend
+		hp.mu.Unlock()
+		if send == nil {
+			continue
+		}
+		if err := send(data); err == nil {
+			notified++
+		}
+	}
+	return notified
+}
+
+// Drain waits until every handler's stdin queue is empty, so messages
+// robots and the API sent before shutdown reach their handler, or until ctx
+// ends. It returns the number of handlers whose queue still held messages.
+func (m *handlerManager) Drain(ctx context.Context) int {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		pending := 0
+		for _, hp := range m.all() {
+			if len(hp.writeCh) > 0 {
+				pending++
+			}
+		}
+		if pending == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return pending
+		case <-ticker.C:
+		}
+	}
+}
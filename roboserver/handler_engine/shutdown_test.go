@@ -0,0 +1,56 @@
+package handler_engine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNotifyShutdown(t *testing.T) {
+	m := &handlerManager{handlers: make(map[string]*HandlerProcess), spawning: make(map[string]bool)}
+	var sent [][]byte
+	online := &HandlerProcess{UUID: "rover-1", writeCh: make(chan []byte, 4), RobotSend: func(data []byte) error {
+		sent = append(sent, data)
+		return nil
+	}}
+	offline := &HandlerProcess{UUID: "rover-2", writeCh: make(chan []byte, 4)}
+	m.Register(online)
+	m.Register(offline)
+
+	if n := m.NotifyShutdown("server_shutdown", 90*time.Second); n != 1 {
+		t.Fatalf("Expected one robot to be notified, got %d", n)
+	}
+	var notice ShutdownNotice
+	if err := json.Unmarshal(sent[0], &notice); err != nil {
+		t.Fatalf("Expected a JSON notice: %v", err)
+	}
+	if notice.Type != "shutdown" || notice.Reason != "server_shutdown" || notice.Downtime != 90 {
+		t.Errorf("Unexpected notice: %+v", notice)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	m := &handlerManager{handlers: make(map[string]*HandlerProcess), spawning: make(map[string]bool)}
+	hp := &HandlerProcess{UUID: "rover-1", writeCh: make(chan []byte, 4)}
+	m.Register(hp)
+	hp.writeCh <- []byte("a\n")
+	hp.writeCh <- []byte("b\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if pending := m.Drain(ctx); pending != 1 {
+		t.Errorf("Expected the undrained queue to be reported, got %d", pending)
+	}
+
+	go func() {
+		for range hp.writeCh {
+		}
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if pending := m.Drain(ctx); pending != 0 {
+		t.Errorf("Expected the queue to drain, got %d pending", pending)
+	}
+	close(hp.writeCh)
+}
@@ -0,0 +1,55 @@
+package handler_engine
+
+// HandlerSnapshot is a point-in-time copy of a handler's state. API code
+// reads and serializes snapshots instead of the live HandlerProcess, whose
+// connection fields change under hp.mu as robots disconnect and reattach.
+type HandlerSnapshot struct {
+	UUID              string `json:"uuid"`
+	DeviceType        string `json:"device_type"`
+	IP                string `json:"ip"`
+	PID               int    `json:"pid"`
+	Connected         bool   `json:"connected"`
+	Reconnecting      bool   `json:"reconnecting"`
+	QueuedMessages    int    `json:"queued_messages"`
+	ForwardHeartbeats bool   `json:"forward_heartbeats"`
+	QueueLength       int    `json:"queue_length"`    // messages waiting for the handler's stdin
+	QueueCapacity     int    `json:"queue_capacity"`  // handlers.queue_size(s)
+	QueueOverflows    int64  `json:"queue_overflows"` // messages that found the queue full
+}
+
+// Snapshot copies the handler's current state under its lock.
+func (hp *HandlerProcess) Snapshot() HandlerSnapshot {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	return HandlerSnapshot{
+		UUID:              hp.UUID,
+		DeviceType:        hp.DeviceType,
+		IP:                hp.IP,
+		PID:               hp.PID,
+		Connected:         hp.RobotSend != nil,
+		Reconnecting:      hp.graceTimer != nil,
+		QueuedMessages:    len(hp.outbox),
+		ForwardHeartbeats: hp.ForwardHeartbeats,
+		QueueLength:       len(hp.writeCh),
+		QueueCapacity:     cap(hp.writeCh),
+		QueueOverflows:    hp.overflows.Load(),
+	}
+}
+
+// Snapshots returns a snapshot of every running handler.
+func (m *handlerManager) Snapshots() []HandlerSnapshot {
+	m.mu.RLock()
+	handlers := make([]*HandlerProcess, 0, len(m.handlers))
+	for _, hp := range m.handlers {
+		handlers = append(handlers, hp)
+	}
+	m.mu.RUnlock()
+
+	// Lock each handler outside the manager lock so a busy handler can't
+	// stall Register/Remove.
+	result := make([]HandlerSnapshot, len(handlers))
+	for i, hp := range handlers {
+		result[i] = hp.Snapshot()
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package handler_engine
+
+import (
+	"roboserver/shared"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotTracksConnection(t *testing.T) {
+	saved := shared.AppConfig.Timeouts.ReconnectGrace
+	defer func() { shared.AppConfig.Timeouts.ReconnectGrace = saved }()
+	shared.AppConfig.Timeouts.ReconnectGrace = "1h"
+
+	send := func([]byte) error { return nil }
+	hp := &HandlerProcess{UUID: "r1", DeviceType: "arm", IP: "10.0.0.1", PID: 42, writeCh: make(chan []byte, 64), RobotSend: send}
+	if snap := hp.Snapshot(); !snap.Connected || snap.Reconnecting || snap.IP != "10.0.0.1" || snap.PID != 42 {
+		t.Fatalf("Unexpected snapshot of a connected handler: %+v", snap)
+	}
+
+	hp.SendDisconnect("tcp_closed")
+	hp.SendToRobot([]byte("queued"))
+	if snap := hp.Snapshot(); snap.Connected || !snap.Reconnecting || snap.QueuedMessages != 1 {
+		t.Errorf("Unexpected snapshot during grace period: %+v", snap)
+	}
+
+	hp.Reattach(send, "10.0.0.2", "sess-2")
+	if snap := hp.Snapshot(); !snap.Connected || snap.Reconnecting || snap.IP != "10.0.0.2" {
+		t.Errorf("Unexpected snapshot after reattach: %+v", snap)
+	}
+}
+
+// Run with -race: snapshots must not race with connection changes.
+func TestSnapshotConcurrentWithReattach(t *testing.T) {
+	saved := shared.AppConfig.Timeouts.ReconnectGrace
+	defer func() { shared.AppConfig.Timeouts.ReconnectGrace = saved }()
+	shared.AppConfig.Timeouts.ReconnectGrace = "1h"
+
+	send := func([]byte) error { return nil }
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 1024), RobotSend: send}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			hp.SendDisconnect("tcp_closed")
+			hp.Reattach(send, "10.0.0.2", "sess")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			hp.Snapshot()
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,54 @@
+package handler_engine
+
+import (
+	"roboserver/comms"
+	"roboserver/shared"
+	"roboserver/shared/maintenance"
+	"roboserver/shared/robot_status"
+)
+
+// SetRobotStatus records a robot's new status and publishes the transition
+// on robot.{uuid}.status. Setting the current status again is a no-op.
+func SetRobotStatus(bus comms.Bus, uuid string, to robot_status.RobotStatus, reason string) error {
+	tr, err := robot_status.Tracker.Set(uuid, to, reason)
+	if err != nil || tr == nil {
+		return err
+	}
+	tr.Maintenance = maintenance.Registry.Active(uuid)
+	if bus != nil {
+		bus.PublishEvent(robot_status.EventType(uuid), tr)
+	}
+	return nil
+}
+
+// setStatus is SetRobotStatus for server-driven changes, which are logged
+// rather than returned when the transition isn't allowed.
+func (hp *HandlerProcess) setStatus(to robot_status.RobotStatus, reason string) {
+	if err := SetRobotStatus(hp.bus, hp.UUID, to, reason); err != nil {
+		shared.DebugPrint("Robot %s status: %v", hp.UUID, err)
+	}
+}
+
+// handleSetStatus lets a handler report busy, online (idle again) or error.
+// The connection-driven states (registering, offline) belong to the server.
+func (hp *HandlerProcess) handleSetStatus(env *JSONRPCEnvelope) {
+	name, ok := env.Data.(string)
+	if !ok {
+		hp.sendResponse(env.ID, nil, "data must be a status string")
+		return
+	}
+	to, err := robot_status.Parse(name)
+	if err != nil {
+		hp.sendResponse(env.ID, nil, err.Error())
+		return
+	}
+	if to != robot_status.Online && to != robot_status.Busy && to != robot_status.Error {
+		hp.sendResponse(env.ID, nil, "handlers may only set online, busy or error")
+		return
+	}
+	if err := SetRobotStatus(hp.bus, hp.UUID, to, "handler"); err != nil {
+		hp.sendResponse(env.ID, nil, err.Error())
+		return
+	}
+	hp.sendResponse(env.ID, to.String(), "")
+}
@@ -0,0 +1,93 @@
+package handler_engine
+
+import (
+	"context"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"time"
+)
+
+// maxRestartBackoff caps the delay between spawn attempts.
+const maxRestartBackoff = 30 * time.Second
+
+// spawnHandler is SpawnHandlerProcess, swappable in tests.
+var spawnHandler = SpawnHandlerProcess
+
+// RestartEvent is published on handler.{uuid}.restart before each retry and
+// on handler.{uuid}.failed when the supervisor gives up.
+type RestartEvent struct {
+	UUID        string `json:"uuid"`
+	Attempt     int    `json:"attempt"`
+	MaxAttempts int    `json:"max_attempts"`
+	DelayMs     int64  `json:"delay_ms,omitempty"`
+	Error       string `json:"error"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.HandlerRestart("{uuid}"),
+		Description: "A handler failed to start and is retried after delay_ms.",
+		Example:     RestartEvent{UUID: "rover-7", Attempt: 1, MaxAttempts: 5, DelayMs: 500, Error: "exec: handler.py: permission denied"},
+	})
+	events.Describe(events.Info{
+		Type:        events.HandlerFailed("{uuid}"),
+		Description: "A handler could not be started within handlers.restart_attempts.",
+		Example:     RestartEvent{UUID: "rover-7", Attempt: 5, MaxAttempts: 5, Error: "exec: handler.py: permission denied"},
+	})
+	events.Describe(events.Info{
+		Type:        events.HandlerLog("{uuid}"),
+		Description: "A line a handler wrote to stderr, or a non-JSON line on stdout; stream says which.",
+		Example:     map[string]string{"uuid": "rover-7", "line": "motor 2 stalled", "stream": "stderr"},
+	})
+}
+
+// SpawnSupervised starts a handler like SpawnHandlerProcess, but retries a
+// failed start with exponential backoff (handlers.restart_backoff, doubling
+// up to 30s) until handlers.restart_attempts is used up or ctx ends. Each
+// retry is announced on handler.{uuid}.restart so flapping devices show up
+// on the event bus; the last error is returned after handler.{uuid}.failed.
+func SpawnSupervised(
+	ctx context.Context,
+	uuid, deviceType, ip, sessionID string,
+	db *database.PostgresHandler,
+	rds *database.RedisHandler,
+	bus comms.Bus,
+	robotSend func(data []byte) error,
+) (*HandlerProcess, error) {
+	attempts := shared.AppConfig.Handlers.RestartAttemptLimit()
+	delay := shared.AppConfig.Handlers.RestartBackoffDuration()
+
+	for attempt := 1; ; attempt++ {
+		hp, err := spawnHandler(ctx, uuid, deviceType, ip, sessionID, db, rds, bus, robotSend)
+		if err == nil {
+			if attempt > 1 {
+				shared.DebugPrint("Handler for %s started on attempt %d", uuid, attempt)
+			}
+			return hp, nil
+		}
+
+		ev := RestartEvent{UUID: uuid, Attempt: attempt, MaxAttempts: attempts, Error: err.Error()}
+		if attempt >= attempts {
+			shared.DebugPrint("Giving up on handler for %s after %d attempts: %v", uuid, attempt, err)
+			if bus != nil {
+				bus.PublishEvent(events.HandlerFailed(uuid), ev)
+			}
+			return nil, err
+		}
+
+		ev.DelayMs = delay.Milliseconds()
+		shared.DebugPrint("Handler for %s failed to start (attempt %d/%d), retrying in %v: %v", uuid, attempt, attempts, delay, err)
+		if bus != nil {
+			bus.PublishEvent(events.HandlerRestart(uuid), ev)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartBackoff)
+	}
+}
@@ -0,0 +1,80 @@
+package handler_engine
+
+import (
+	"context"
+	"errors"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"sync"
+	"testing"
+)
+
+// recordingBus records published event types; other Bus methods are unused.
+type recordingBus struct {
+	comms.Bus
+	mu     sync.Mutex
+	events []string
+}
+
+func (b *recordingBus) PublishEvent(eventType string, data any) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, eventType)
+	return nil
+}
+
+// stubSpawn makes spawnHandler fail the first failures calls.
+func stubSpawn(t *testing.T, failures int) *int {
+	calls := 0
+	orig := spawnHandler
+	spawnHandler = func(ctx context.Context, uuid, deviceType, ip, sessionID string,
+		db *database.PostgresHandler, rds *database.RedisHandler, bus comms.Bus,
+		robotSend func(data []byte) error) (*HandlerProcess, error) {
+		calls++
+		if calls <= failures {
+			return nil, errors.New("exec failed")
+		}
+		return &HandlerProcess{UUID: uuid}, nil
+	}
+	t.Cleanup(func() { spawnHandler = orig })
+	return &calls
+}
+
+func TestSpawnSupervisedRetries(t *testing.T) {
+	shared.AppConfig.Handlers.RestartAttempts = 3
+	shared.AppConfig.Handlers.RestartBackoff = "1ms"
+	defer func() { shared.AppConfig.Handlers = shared.HandlersConfig{BasePath: "./testdata"} }()
+
+	calls := stubSpawn(t, 2)
+	bus := &recordingBus{}
+	hp, err := SpawnSupervised(context.Background(), "flappy", "test_robot", "", "", nil, nil, bus, nil)
+	if err != nil || hp == nil {
+		t.Fatalf("Expected third attempt to succeed, got %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 spawn attempts, got %d", *calls)
+	}
+	if len(bus.events) != 2 || bus.events[0] != "handler.flappy.restart" {
+		t.Errorf("Expected two restart events, got %v", bus.events)
+	}
+}
+
+func TestSpawnSupervisedGivesUp(t *testing.T) {
+	shared.AppConfig.Handlers.RestartAttempts = 2
+	shared.AppConfig.Handlers.RestartBackoff = "1ms"
+	defer func() { shared.AppConfig.Handlers = shared.HandlersConfig{BasePath: "./testdata"} }()
+
+	calls := stubSpawn(t, 10)
+	bus := &recordingBus{}
+	if _, err := SpawnSupervised(context.Background(), "broken", "test_robot", "", "", nil, nil, bus, nil); err == nil {
+		t.Fatal("Expected an error once attempts are used up")
+	}
+	if *calls != 2 {
+		t.Errorf("Expected 2 spawn attempts, got %d", *calls)
+	}
+	want := []string{"handler.broken.restart", "handler.broken.failed"}
+	if len(bus.events) != 2 || bus.events[0] != want[0] || bus.events[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, bus.events)
+	}
+}
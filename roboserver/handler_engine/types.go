@@ -1,5 +1,7 @@
 package handler_engine
 
+import "roboserver/shared/telemetry"
+
 // JSONRPCEnvelope is the standard message format between the Go sidecar and handler scripts.
 type JSONRPCEnvelope struct {
 	ID     string      `json:"id,omitempty"`     // Correlation ID for request-response
@@ -7,6 +9,12 @@ type JSONRPCEnvelope struct {
 	Method string      `json:"method,omitempty"` // Target-specific method
 	Data   interface{} `json:"data,omitempty"`   // Payload
 	Error  string      `json:"error,omitempty"`  // Error message (responses only)
+	// TraceParent, copied by the handler from the message it is acting on,
+	// makes a robot send part of that message's trace.
+	TraceParent string `json:"traceparent,omitempty"`
+	// TTL, in seconds, makes an event published to the event_bus target
+	// expire: it is dropped instead of delivered late (comms.PublishTTL).
+	TTL float64 `json:"ttl,omitempty"`
 }
 
 // Targets for JSON-RPC routing
@@ -17,15 +25,18 @@ const (
 	TargetResponse = "response"
 	TargetConfig   = "config"
 	TargetConnect  = "connect_robot"
+	TargetShadow   = "shadow"
 )
 
 // System messages sent by the Go sidecar to handler scripts
 const (
-	MsgTypeConnect    = "connect"
-	MsgTypeDisconnect = "disconnect"
-	MsgTypeIncoming   = "incoming"
-	MsgTypeEvent      = "event"
-	MsgTypeHeartbeat  = "heartbeat"
+	MsgTypeConnect     = "connect"
+	MsgTypeDisconnect  = "disconnect"
+	MsgTypeIncoming    = "incoming"
+	MsgTypeEvent       = "event"
+	MsgTypeHeartbeat   = "heartbeat"
+	MsgTypeTelemetry   = "telemetry"
+	MsgTypeShadowDelta = "shadow_delta"
 )
 
 // ConnectMessage is sent to the handler script when a robot authenticates.
@@ -45,10 +56,35 @@ type DisconnectMessage struct {
 }
 
 // IncomingMessage wraps a message from the robot to the handler.
+// Actor is set only for operator messages whose sender re-entered their
+// password (POST /robot/{uuid}/control); robots can never set it.
 type IncomingMessage struct {
 	Type    string `json:"type"`
 	UUID    string `json:"uuid"`
 	Payload string `json:"payload"`
+	Actor   string `json:"actor,omitempty"`
+	// LockID is set when the message holds the handler's command lock;
+	// the handler reports command_done with it once the device is idle.
+	LockID string `json:"lock_id,omitempty"`
+	// TraceParent is set when the message is part of a trace, such as an
+	// API request's; handlers echo it on the robot requests it causes.
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+// TelemetryMessage carries a robot's DATA report to the handler.
+type TelemetryMessage struct {
+	Type string             `json:"type"`
+	UUID string             `json:"uuid"`
+	Data telemetry.Envelope `json:"data"`
+}
+
+// ShadowDeltaMessage carries the desired state a robot has not reported
+// yet: sent when the robot connects and when desired state changes.
+type ShadowDeltaMessage struct {
+	Type    string         `json:"type"`
+	UUID    string         `json:"uuid"`
+	State   map[string]any `json:"state"`
+	Version int64          `json:"version"`
 }
 
 // EventMessage wraps a comm bus event forwarded to the handler.
@@ -0,0 +1,94 @@
+package handler_engine
+
+import (
+	"fmt"
+	"regexp"
+	"roboserver/shared/actuator"
+	"strings"
+)
+
+// Registration error codes. TCP clients receive them as "ERROR <code>"; HTTP
+// clients get them in the "code" field of a 400 response.
+const (
+	CodeInvalidUUID       = "INVALID_UUID"
+	CodeInvalidDeviceType = "INVALID_DEVICE_TYPE"
+	CodeUnknownDeviceType = "UNKNOWN_DEVICE_TYPE"
+	CodeInvalidSchema     = "INVALID_SCHEMA"
+)
+
+// validUUID matches robot identifiers. UUIDs end up in URL paths, Redis keys
+// ("robot:{uuid}:..."), MQTT topics and event names ("{type}.{uuid}.{suffix}"),
+// so separators like '/', ':', '.' and whitespace are rejected.
+var validUUID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// RegistrationError is a rejected registration field, carrying a stable code
+// clients can switch on.
+type RegistrationError struct {
+	Code   string
+	Reason string
+	// ValidTypes lists the installed device types when Code is
+	// CodeUnknownDeviceType.
+	ValidTypes []string
+}
+
+func (e *RegistrationError) Error() string {
+	return e.Reason
+}
+
+// IsValidUUID checks whether a robot UUID is safe to use in keys and URLs.
+func IsValidUUID(uuid string) bool {
+	return validUUID.MatchString(uuid)
+}
+
+// ValidateUUID returns a RegistrationError if uuid is malformed.
+func ValidateUUID(uuid string) error {
+	if !IsValidUUID(uuid) {
+		return &RegistrationError{
+			Code:   CodeInvalidUUID,
+			Reason: "uuid must be 1-64 characters of letters, digits, hyphens or underscores",
+		}
+	}
+	return nil
+}
+
+// ValidateDeviceType checks the device type's format and that a handler is
+// installed for it, so robots can't register as a type that will only fail
+// later when the handler is spawned. An unknown type's error lists the
+// valid ones (ListRobotTypes).
+func ValidateDeviceType(deviceType string) error {
+	if !IsValidDeviceType(deviceType) {
+		return &RegistrationError{
+			Code:   CodeInvalidDeviceType,
+			Reason: "device_type must be 1-64 characters of letters, digits, hyphens or underscores",
+		}
+	}
+	if _, err := ResolveHandlerScript(deviceType); err != nil || deviceType == TemplateType {
+		var valid []string
+		for _, t := range ListRobotTypes() {
+			valid = append(valid, t.Type)
+		}
+		return &RegistrationError{
+			Code:       CodeUnknownDeviceType,
+			Reason:     fmt.Sprintf("no handler installed for device type %q (valid types: %s)", deviceType, strings.Join(valid, ", ")),
+			ValidTypes: valid,
+		}
+	}
+	return nil
+}
+
+// ValidateRegistration checks everything a robot supplies when registering.
+// schema is optional; when present it must be a valid actuator schema.
+func ValidateRegistration(uuid, deviceType string, schema []byte) error {
+	if err := ValidateUUID(uuid); err != nil {
+		return err
+	}
+	if err := ValidateDeviceType(deviceType); err != nil {
+		return err
+	}
+	if len(schema) > 0 {
+		if _, err := actuator.Parse(schema); err != nil {
+			return &RegistrationError{Code: CodeInvalidSchema, Reason: err.Error()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package handler_engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"roboserver/shared/events"
+	"strings"
+)
+
+// WebRTC signaling. The server never terminates WebRTC itself: it relays the
+// SDP offer/answer and ICE candidates between a browser and a robot that runs
+// its own WebRTC stack, after which the data channel is peer to peer. Robots
+// without WebRTC never answer, and clients fall back to the normal
+// /robot/{uuid}/message path.
+//
+// Browser → robot signals are written to the robot connection as one JSON
+// line: {"type":"webrtc","kind":"offer","session":"...","sdp":"..."}.
+// Robot → browser signals arrive as "WEBRTC <json>" TCP lines (or are
+// published by a handler script on the same topic) and go out on the event
+// bus as webrtc.{session}.{kind}.
+
+const (
+	SignalOffer     = "offer"
+	SignalAnswer    = "answer"
+	SignalCandidate = "candidate"
+	SignalClose     = "close"
+)
+
+var validSignalSession = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+type Signal struct {
+	Type      string          `json:"type,omitempty"`
+	Kind      string          `json:"kind"`
+	Session   string          `json:"session"`
+	UUID      string          `json:"uuid,omitempty"` // set by the server, never trusted from the robot
+	SDP       string          `json:"sdp,omitempty"`
+	Candidate json.RawMessage `json:"candidate,omitempty"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        SignalTopic("{session}", "{kind}"),
+		Description: "A WebRTC signaling message from a robot: kind is answer, candidate or close.",
+		Example:     Signal{Kind: SignalAnswer, Session: "0123456789abcdef0123456789abcdef", UUID: "rover-7", SDP: "v=0"},
+	})
+}
+
+// NewSignalSession returns a random signaling session ID.
+func NewSignalSession() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func IsValidSignalSession(session string) bool {
+	return validSignalSession.MatchString(session)
+}
+
+// SignalTopic is the event type robot signals for a session are published on.
+func SignalTopic(session, kind string) string {
+	return events.WebRTCSignal(session, kind)
+}
+
+// ParseRobotSignal parses a "WEBRTC <json>" line from a robot. Robots may
+// only send answers, candidates and close notices.
+func ParseRobotSignal(line, uuid string) (*Signal, error) {
+	payload := strings.TrimSpace(strings.TrimPrefix(line, "WEBRTC"))
+	var sig Signal
+	if err := json.Unmarshal([]byte(payload), &sig); err != nil {
+		return nil, fmt.Errorf("invalid signal JSON: %w", err)
+	}
+	switch sig.Kind {
+	case SignalAnswer:
+		if sig.SDP == "" {
+			return nil, fmt.Errorf("answer requires sdp")
+		}
+	case SignalCandidate, SignalClose:
+	default:
+		return nil, fmt.Errorf("unsupported signal kind %q", sig.Kind)
+	}
+	if !IsValidSignalSession(sig.Session) {
+		return nil, fmt.Errorf("invalid signal session")
+	}
+	sig.Type = ""
+	sig.UUID = uuid
+	return &sig, nil
+}
+
+// SendSignal writes a browser signal to the robot. Unlike SendToRobot it
+// never queues during a reconnect grace period — a stale offer is useless.
+func (hp *HandlerProcess) SendSignal(sig Signal) error {
+	hp.mu.Lock()
+	connected := hp.RobotSend != nil
+	hp.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("no robot connection available")
+	}
+
+	sig.Type = "webrtc"
+	sig.UUID = ""
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	return hp.SendToRobot(data)
+}
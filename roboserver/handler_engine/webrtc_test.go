@@ -0,0 +1,80 @@
+package handler_engine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseRobotSignal(t *testing.T) {
+	session := NewSignalSession()
+	if !IsValidSignalSession(session) {
+		t.Fatalf("Generated session %q is not valid", session)
+	}
+
+	sig, err := ParseRobotSignal(`WEBRTC {"kind":"answer","session":"`+session+`","sdp":"v=0","uuid":"spoofed"}`, "r1")
+	if err != nil {
+		t.Fatalf("Expected valid answer, got %v", err)
+	}
+	if sig.UUID != "r1" || sig.SDP != "v=0" {
+		t.Errorf("Expected uuid from the connection and sdp kept, got %+v", sig)
+	}
+
+	bad := []string{
+		`WEBRTC not json`,
+		`WEBRTC {"kind":"offer","session":"` + session + `","sdp":"v=0"}`,
+		`WEBRTC {"kind":"answer","session":"` + session + `"}`,
+		`WEBRTC {"kind":"candidate","session":"../x"}`,
+	}
+	for _, line := range bad {
+		if _, err := ParseRobotSignal(line, "r1"); err == nil {
+			t.Errorf("Expected error for %s", line)
+		}
+	}
+}
+
+func TestSendSignal(t *testing.T) {
+	var sent []byte
+	hp := &HandlerProcess{UUID: "r1", writeCh: make(chan []byte, 16)}
+	if err := hp.SendSignal(Signal{Kind: SignalOffer, Session: NewSignalSession()}); err == nil {
+		t.Error("Expected error without a robot connection")
+	}
+
+	hp.RobotSend = func(data []byte) error {
+		sent = data
+		return nil
+	}
+	if err := hp.SendSignal(Signal{Kind: SignalOffer, Session: "s", SDP: "v=0", UUID: "x"}); err != nil {
+		t.Fatalf("SendSignal failed: %v", err)
+	}
+	var decoded map[string]any
+	json.Unmarshal(sent, &decoded)
+	if decoded["type"] != "webrtc" || decoded["kind"] != "offer" || decoded["uuid"] != nil {
+		t.Errorf("Unexpected wire format: %s", sent)
+	}
+	if strings.Contains(string(sent), "\n") {
+		t.Errorf("Signal must be a single line: %q", sent)
+	}
+}
+
+func FuzzParseRobotSignal(f *testing.F) {
+	session := strings.Repeat("ab", 16)
+	f.Add(`WEBRTC {"kind":"answer","session":"` + session + `","sdp":"v=0"}`)
+	f.Add(`WEBRTC {"kind":"candidate","session":"` + session + `","candidate":{"candidate":"x"}}`)
+	f.Add(`WEBRTC {"kind":"offer","session":"` + session + `","sdp":"v=0"}`)
+	f.Add(`WEBRTC {"kind":"close","session":"../../x","uuid":"spoofed"}`)
+	f.Add(`WEBRTC`)
+
+	f.Fuzz(func(t *testing.T, line string) {
+		sig, err := ParseRobotSignal(line, "r1")
+		if err != nil {
+			return
+		}
+		if sig.UUID != "r1" || sig.Type != "" || !IsValidSignalSession(sig.Session) {
+			t.Fatalf("accepted signal %+v", sig)
+		}
+		if sig.Kind != SignalAnswer && sig.Kind != SignalCandidate && sig.Kind != SignalClose {
+			t.Fatalf("accepted kind %q from a robot", sig.Kind)
+		}
+	})
+}
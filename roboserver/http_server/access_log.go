@@ -0,0 +1,88 @@
+package http_server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"roboserver/shared"
+	"roboserver/shared/requestid"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// accessLogOut receives the access log; tests swap it.
+var accessLogOut io.Writer = os.Stdout
+
+// accessEntry collects what handlers learn about a request after the access
+// log middleware has run, such as who made it.
+type accessEntry struct {
+	user string
+}
+
+type accessEntryCtxKey struct{}
+
+// RequestIDMiddleware gives every request an ID: the caller's X-Request-ID
+// when it is usable, otherwise a new one. The ID is echoed in the response
+// header and carried in the request context for log lines further down.
+func (s *HTTPServer_t) RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.With(r.Context(), id)))
+	})
+}
+
+// AccessLogMiddleware writes one JSON line per request to stdout when
+// server.access_log is on. Streams (SSE, WebSocket) are logged when they
+// close, so their duration is the life of the connection.
+func (s *HTTPServer_t) AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !shared.AppConfig.Server.AccessLog {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		entry := &accessEntry{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessEntryCtxKey{}, entry)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		slog.New(slog.NewJSONHandler(accessLogOut, nil)).LogAttrs(r.Context(), slog.LevelInfo, "http",
+			slog.String("request_id", requestid.From(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("user", entry.user),
+			slog.String("remote", shared.RedactIP(r.RemoteAddr)),
+		)
+	})
+}
+
+// noteUser records the user of an authenticated request for the access log
+// and passes session through.
+func noteUser(r *http.Request, session *shared.Session) *shared.Session {
+	if session == nil {
+		return nil
+	}
+	if entry, ok := r.Context().Value(accessEntryCtxKey{}).(*accessEntry); ok {
+		entry.user = session.UserID
+	}
+	return session
+}
@@ -0,0 +1,86 @@
+package http_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"roboserver/shared/requestid"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	var seen string
+	handler := s.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestid.From(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/robot", nil)
+	req.Header.Set(requestid.Header, "lb-7f3a.1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if seen != "lb-7f3a.1" || rec.Header().Get(requestid.Header) != "lb-7f3a.1" {
+		t.Errorf("Expected the caller's ID to be kept and echoed, got %q / %q", seen, rec.Header().Get(requestid.Header))
+	}
+
+	for _, header := range []string{"", "bad id\n"} {
+		req = httptest.NewRequest("GET", "/robot", nil)
+		if header != "" {
+			req.Header.Set(requestid.Header, header)
+		}
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if len(seen) != 32 || seen == header || rec.Header().Get(requestid.Header) != seen {
+			t.Errorf("Header %q: expected a generated ID, got %q / %q", header, seen, rec.Header().Get(requestid.Header))
+		}
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	saved, savedOut := shared.AppConfig.Server.AccessLog, accessLogOut
+	defer func() { shared.AppConfig.Server.AccessLog, accessLogOut = saved, savedOut }()
+	var out bytes.Buffer
+	accessLogOut = &out
+	shared.AppConfig.Server.AccessLog = true
+
+	s := newTestServer(&mockDBManager{})
+	s.router.Use(s.RequestIDMiddleware, s.AccessLogMiddleware)
+	s.router.Get("/robot/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		noteUser(r, &shared.Session{UserID: "alice"})
+		http.Error(w, "Not found", http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest("GET", "/robot/rover-1?fields=status", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	s.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON line, got %q: %v", out.String(), err)
+	}
+	want := map[string]any{
+		"request_id": "req-1",
+		"method":     "GET",
+		"path":       "/robot/rover-1",
+		"route":      "/robot/{uuid}",
+		"status":     float64(http.StatusNotFound),
+		"user":       "alice",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("Expected duration_ms in %v", entry)
+	}
+
+	out.Reset()
+	shared.AppConfig.Server.AccessLog = false
+	s.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/robot/rover-1", nil))
+	if out.Len() != 0 {
+		t.Errorf("Expected no access log when disabled, got %q", out.String())
+	}
+}
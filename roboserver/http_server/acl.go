@@ -0,0 +1,173 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+	"roboserver/database"
+	"roboserver/shared"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type sessionCtxKey struct{}
+
+// withSession stores the validated session on the request context so
+// downstream handlers don't re-validate the token.
+func withSession(r *http.Request, session *shared.Session) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionCtxKey{}, session))
+}
+
+// sessionFromRequest returns the session set by SessionValidationMiddleware, or nil.
+func sessionFromRequest(r *http.Request) *shared.Session {
+	session, _ := r.Context().Value(sessionCtxKey{}).(*shared.Session)
+	return session
+}
+
+// currentUser loads the account behind the request's session. For an API
+// key session the key's role replaces the user's, and a revoked key yields nil.
+func (h *HTTPServer_t) currentUser(r *http.Request) *database.User {
+	session := sessionFromRequest(r)
+	rds := h.db.Redis()
+	if session == nil || rds == nil {
+		return nil
+	}
+	user, err := rds.GetUser(r.Context(), session.UserID)
+	if err != nil {
+		return nil
+	}
+	if id, ok := apiKeyIDFromSession(session); ok {
+		key, err := rds.GetAPIKey(r.Context(), id)
+		if err != nil || key.Username != user.Username {
+			return nil
+		}
+		return key.Apply(user)
+	}
+	return user
+}
+
+// RobotAccessMiddleware rejects requests for a robot the current user has
+// not been granted (see RedisHandler.CanAccessRobot).
+func (h *HTTPServer_t) RobotAccessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rds := h.db.Redis()
+		if rds == nil {
+			http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		user := h.currentUser(r)
+		ok, err := rds.CanAccessRobot(r.Context(), user, chi.URLParam(r, "uuid"))
+		if err != nil {
+			http.Error(w, "Failed to check robot access", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeRobotStream checks that the session behind a ticket or JWT
+// stream may access robot uuid, writing the error response if not. Like
+// currentUser it applies an API key's role and rejects a revoked key, for
+// tickets minted from a key as much as for the key itself.
+func (h *HTTPServer_t) authorizeRobotStream(w http.ResponseWriter, r *http.Request, session *shared.Session, uuid string) bool {
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return false
+	}
+	user := h.currentUser(withSession(r, session))
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	ok, err := rds.CanAccessRobot(r.Context(), user, uuid)
+	if err != nil {
+		http.Error(w, "Failed to check robot access", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireAdmin writes 403 and returns false unless the current user is an admin.
+func (h *HTTPServer_t) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user := h.currentUser(r)
+	if user == nil || !user.IsAdmin() {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// getRobotACL lists principals granted access to a robot. Admin only.
+func (h *HTTPServer_t) getRobotACL(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	uuid := chi.URLParam(r, "uuid")
+
+	principals, err := h.db.Redis().GetRobotACL(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to get robot ACL", http.StatusInternalServerError)
+		return
+	}
+
+	sendResponseAsJSON(w, map[string]interface{}{
+		"uuid":       uuid,
+		"principals": principals,
+	}, http.StatusOK)
+}
+
+// grantRobotAccess adds a principal to a robot's ACL. Admin only.
+// Body: {"principal": "kids"} or {"principal": "role:family"}
+func (h *HTTPServer_t) grantRobotAccess(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	uuid := chi.URLParam(r, "uuid")
+
+	var body struct {
+		Principal string `json:"principal"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	principal := strings.TrimSpace(body.Principal)
+	if principal == "" {
+		http.Error(w, "principal is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.Redis().GrantRobotAccess(r.Context(), uuid, principal); err != nil {
+		http.Error(w, "Failed to grant access", http.StatusInternalServerError)
+		return
+	}
+
+	sendResponseAsJSON(w, map[string]string{"status": "granted", "uuid": uuid, "principal": principal}, http.StatusOK)
+}
+
+// revokeRobotAccess removes a principal from a robot's ACL. Admin only.
+func (h *HTTPServer_t) revokeRobotAccess(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	uuid := chi.URLParam(r, "uuid")
+	principal := chi.URLParam(r, "principal")
+
+	if err := h.db.Redis().RevokeRobotAccess(r.Context(), uuid, principal); err != nil {
+		http.Error(w, "Failed to revoke access", http.StatusInternalServerError)
+		return
+	}
+
+	sendResponseAsJSON(w, map[string]string{"status": "revoked", "uuid": uuid, "principal": principal}, http.StatusOK)
+}
@@ -0,0 +1,97 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"roboserver/database"
+	"roboserver/shared"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unreachableRedis is a handler whose every command fails, so no user can
+// be resolved and access checks refuse.
+func unreachableRedis(t *testing.T) *database.RedisHandler {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return &database.RedisHandler{Client: client}
+}
+
+func TestSessionFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/robot", nil)
+	if sessionFromRequest(req) != nil {
+		t.Error("Expected nil session on bare request")
+	}
+
+	req = withSession(req, &shared.Session{UserID: "kids", SessionID: "s1"})
+	session := sessionFromRequest(req)
+	if session == nil || session.UserID != "kids" {
+		t.Errorf("Expected session for kids, got %v", session)
+	}
+}
+
+func TestRobotAccessMiddleware_NilRedis(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	called := false
+	handler := s.RobotAccessMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest("GET", "/robot/lamp-1", nil)
+	req = addChiURLParam(req, "uuid", "lamp-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+	if called {
+		t.Error("Expected handler not to be called")
+	}
+}
+
+func TestGrantRobotAccess_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("POST", "/robot/lamp-1/acl", nil)
+	req = addChiURLParam(req, "uuid", "lamp-1")
+	rec := httptest.NewRecorder()
+
+	s.grantRobotAccess(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+	}
+}
+
+func TestHandlerRoutesCheckRobotAccess(t *testing.T) {
+	s := newTestServer(&mockDBManager{rds: unreachableRedis(t)})
+	s.router.Route("/handler", s.HandlerRoutes)
+	for _, tc := range []struct{ method, path string }{
+		{"GET", "/handler/lamp-1"},
+		{"POST", "/handler/lamp-1/start"},
+		{"POST", "/handler/lamp-1/kill"},
+	} {
+		req := withSession(httptest.NewRequest(tc.method, tc.path, nil), &shared.Session{UserID: "kids", SessionID: "s1"})
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403, got %d", tc.method, tc.path, rec.Code)
+		}
+	}
+}
+
+func TestStreamHandlerLogsChecksRobotAccess(t *testing.T) {
+	s := newTestServer(&mockDBManager{rds: unreachableRedis(t)})
+	req := httptest.NewRequest("GET", "/handler/lamp-1/logs", nil)
+	req = addChiURLParam(req, "uuid", "lamp-1")
+	rec := httptest.NewRecorder()
+	if s.authorizeRobotStream(rec, req, &shared.Session{UserID: "kids", SessionID: "s1"}, "lamp-1") {
+		t.Fatal("Expected access refused without a resolvable user")
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,136 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/metrics"
+	"roboserver/shared/registrations"
+	"roboserver/shared/tracked"
+	"roboserver/terminal"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *HTTPServer_t) AdminRoutes(r chi.Router) {
+	r.Get("/timeline", h.getTimeline)
+	r.Get("/cluster", h.getClusterStatus)
+	r.Get("/registration_failures", h.getRegistrationFailures)
+	r.Post("/telemetry/purge", h.postTelemetryPurge)
+	r.Get("/bans", h.getBans)
+	r.Delete("/bans/{kind}/{value}", h.deleteBan)
+	r.Get("/automation", h.getAutomation)
+	r.Post("/automation/reload", h.postAutomationReload)
+	r.Post("/backup", h.postBackup)
+	r.Post("/restore", h.postRestore)
+	r.Get("/goroutines", h.getGoroutines)
+	r.Get("/sse", h.getSSEClients)
+	r.Post("/script", h.postScript)
+}
+
+// getTimeline returns per-minute activity samples, oldest first. ?minutes=N
+// limits the response to the most recent N samples.
+func (h *HTTPServer_t) getTimeline(w http.ResponseWriter, r *http.Request) {
+	samples := metrics.Timeline()
+	if samples == nil {
+		samples = []metrics.Sample{}
+	}
+
+	if raw := r.URL.Query().Get("minutes"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "minutes must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if n < len(samples) {
+			samples = samples[len(samples)-n:]
+		}
+	}
+
+	sendResponseAsJSON(w, samples, http.StatusOK)
+}
+
+// getRegistrationFailures returns recent failed or rejected registration
+// attempts, newest first. ?limit=N (default 100). With
+// auth.persist_registration_failures the log comes from Redis and covers
+// every cluster node; otherwise it is this node's in-memory log.
+func (h *HTTPServer_t) getRegistrationFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var failures []registrations.Failure
+	if rds := h.db.Redis(); rds != nil && shared.AppConfig.Auth.PersistRegistrationFailures {
+		var err error
+		if failures, err = rds.GetRegistrationFailures(r.Context(), limit); err != nil {
+			http.Error(w, "Failed to get registration failures", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		failures = registrations.Recent(limit)
+	}
+	sendResponseAsJSON(w, failures, http.StatusOK)
+}
+
+// getGoroutines reports goroutine counts by component and open
+// per-connection resources (see shared/tracked), plus the handlers this
+// node runs, to spot leaks: a count that keeps growing while the fleet
+// doesn't. Admin only. ?stacks=true returns every goroutine's stack as
+// text instead.
+func (h *HTTPServer_t) getGoroutines(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	if r.URL.Query().Get("stacks") == "true" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		pprof.Lookup("goroutine").WriteTo(w, 1)
+		return
+	}
+	sendResponseAsJSON(w, struct {
+		tracked.Report
+		Handlers int `json:"handlers"`
+	}{tracked.Snapshot(), handler_engine.HandlerManager.Count()}, http.StatusOK)
+}
+
+// getSSEClients lists this node's /events streams with their queue depth,
+// bandwidth and low-priority events dropped while lagging.
+func (h *HTTPServer_t) getSSEClients(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	sendResponseAsJSON(w, h.sseManager.Stats(), http.StatusOK)
+}
+
+// postScript runs a newline-separated terminal command script, like the
+// terminal's run and batch commands, and returns the aggregated result.
+// Admin only. Body: {"script": "list\npending"}. Commands that read or write
+// files on the host or stop the server are refused.
+func (h *HTTPServer_t) postScript(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		Script string `json:"script"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if strings.TrimSpace(body.Script) == "" {
+		http.Error(w, "script is required", http.StatusBadRequest)
+		return
+	}
+	sendResponseAsJSON(w, terminal.RunScript(h.db, h.bus, body.Script), http.StatusOK)
+}
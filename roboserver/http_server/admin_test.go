@@ -0,0 +1,103 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared/metrics"
+	"roboserver/terminal"
+	"strings"
+	"testing"
+)
+
+func TestGetTimeline(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("GET", "/admin/timeline", nil)
+	rec := httptest.NewRecorder()
+	s.getTimeline(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var samples []metrics.Sample
+	if err := json.NewDecoder(rec.Body).Decode(&samples); err != nil {
+		t.Fatalf("Expected a JSON array: %v", err)
+	}
+}
+
+func TestGetTimeline_InvalidMinutes(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	for _, q := range []string{"abc", "0", "-5"} {
+		req := httptest.NewRequest("GET", "/admin/timeline?minutes="+q, nil)
+		rec := httptest.NewRecorder()
+		s.getTimeline(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("minutes=%s: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestGetClusterStatus_Standalone(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("GET", "/admin/cluster", nil)
+	rec := httptest.NewRecorder()
+	s.getClusterStatus(rec, req)
+
+	var status map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Expected a JSON object: %v", err)
+	}
+	if status["enabled"] != false || status["leader"] != true || status["node"] == "" {
+		t.Errorf("Unexpected standalone status: %v", status)
+	}
+}
+
+func TestGetRegistrationFailures_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("GET", "/admin/registration_failures", nil)
+	rec := httptest.NewRecorder()
+	s.getRegistrationFailures(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+	}
+}
+
+func TestGetGoroutines_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("GET", "/admin/goroutines", nil)
+	rec := httptest.NewRecorder()
+	s.getGoroutines(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+	}
+}
+
+func TestPostScript_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("POST", "/admin/script", strings.NewReader(`{"script":"list"}`))
+	rec := httptest.NewRecorder()
+	s.postScript(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+	}
+}
+
+func TestRunScriptRefusesHostCommands(t *testing.T) {
+	res := terminal.RunScript(&mockDBManager{}, nil, "# setup\nrun /etc/passwd\nbackup /tmp/x.rmbk\nhelp run\nexit\nhelp")
+	if res.Ran != 4 || res.Failed != 2 {
+		t.Errorf("Expected 4 run and 2 failed before exit, got %d and %d", res.Ran, res.Failed)
+	}
+	if !strings.Contains(res.Output, "run is only available in the terminal") || strings.Contains(res.Output, "root:") {
+		t.Errorf("Expected run refused without reading the file, got:\n%s", res.Output)
+	}
+}
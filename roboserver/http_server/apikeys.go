@@ -0,0 +1,174 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/auth"
+	"roboserver/database"
+	"roboserver/shared"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// API keys are long-lived bearer tokens for machine clients. A request with
+// "Authorization: Bearer rmk_..." acts as the key's owner, with the key's
+// role (if any) in place of the owner's. Keys are managed from a login
+// session only, never with another API key.
+
+// apiKeySessionPrefix marks the SessionID of a request authenticated by an
+// API key, so currentUser can apply the key's role.
+const apiKeySessionPrefix = "apikey:"
+
+const maxAPIKeyNameLength = 64
+
+// apiKeyView is an API key as returned to clients (never the hash).
+type apiKeyView struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Role      string `json:"role,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	Key       string `json:"key,omitempty"` // full key, only in the create response
+}
+
+func newAPIKeyView(k *database.APIKey) apiKeyView {
+	return apiKeyView{ID: k.ID, Name: k.Name, Role: k.Role, CreatedAt: k.CreatedAt}
+}
+
+// apiKeyIDFromSession returns the API key id behind a session, if any.
+func apiKeyIDFromSession(session *shared.Session) (string, bool) {
+	if session == nil {
+		return "", false
+	}
+	return strings.CutPrefix(session.SessionID, apiKeySessionPrefix)
+}
+
+// validateAPIKey checks an "rmk_" bearer token against Redis.
+func (h *HTTPServer_t) validateAPIKey(r *http.Request, token string) *shared.Session {
+	id, secret, ok := auth.ParseAPIKey(token)
+	rds := h.db.Redis()
+	if !ok || rds == nil {
+		return nil
+	}
+	key, err := rds.GetAPIKey(r.Context(), id)
+	if err != nil || !auth.CheckAPIKeySecret(secret, key.Hash) {
+		return nil
+	}
+	return &shared.Session{UserID: key.Username, SessionID: apiKeySessionPrefix + key.ID}
+}
+
+// requireLoginSession writes 403 and returns false for requests made with an
+// API key (key management, password changes).
+func requireLoginSession(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := apiKeyIDFromSession(sessionFromRequest(r)); ok {
+		http.Error(w, "Forbidden: requires a login session, not an API key", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// listAPIKeys returns the caller's API keys.
+func (h *HTTPServer_t) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !requireLoginSession(w, r) {
+		return
+	}
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.db.Redis().ListAPIKeys(r.Context(), user.Username)
+	if err != nil {
+		http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+		return
+	}
+	views := make([]apiKeyView, 0, len(keys))
+	for _, k := range keys {
+		views = append(views, newAPIKeyView(k))
+	}
+	sendResponseAsJSON(w, views, http.StatusOK)
+}
+
+// createAPIKey issues a new API key for the caller. The full key is only in
+// this response.
+// Body: {"name": "grafana", "role": "viewer"}
+func (h *HTTPServer_t) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireLoginSession(w, r) {
+		return
+	}
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		http.Error(w, "name is required (max 64 characters)", http.StatusBadRequest)
+		return
+	}
+	role := strings.TrimSpace(body.Role)
+	// Non-admins can't mint a key with more access than they have.
+	if role != "" && !user.IsAdmin() && role != user.Role {
+		http.Error(w, "Forbidden: cannot create a key for another role", http.StatusForbidden)
+		return
+	}
+
+	id, secret, full, err := auth.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+		return
+	}
+	key := &database.APIKey{
+		ID:        id,
+		Username:  user.Username,
+		Name:      name,
+		Role:      role,
+		Hash:      auth.HashAPIKeySecret(secret),
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := h.db.Redis().SetAPIKey(r.Context(), key); err != nil {
+		http.Error(w, "Failed to store API key", http.StatusInternalServerError)
+		return
+	}
+
+	shared.DebugPrint("AUTH: User %s created API key %s (%s)", user.Username, id, name)
+	view := newAPIKeyView(key)
+	view.Key = full
+	sendResponseAsJSON(w, view, http.StatusCreated)
+}
+
+// revokeAPIKey deletes one of the caller's API keys. Admins may revoke any key.
+func (h *HTTPServer_t) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireLoginSession(w, r) {
+		return
+	}
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rds := h.db.Redis()
+	key, err := rds.GetAPIKey(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || (key.Username != user.Username && !user.IsAdmin()) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err := rds.DeleteAPIKey(r.Context(), key); err != nil {
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	shared.DebugPrint("AUTH: User %s revoked API key %s", user.Username, key.ID)
+	sendResponseAsJSON(w, map[string]string{"status": "revoked", "id": key.ID}, http.StatusOK)
+}
@@ -30,7 +30,8 @@ func cleanupRateLimiter() {
 	ticker := time.NewTicker(10 * time.Minute)
 	for range ticker.C {
 		loginRateLimiter.mu.Lock()
-		cutoff := time.Now().Add(-loginWindow)
+		_, window := shared.LoginLimit()
+		cutoff := time.Now().Add(-window)
 		for ip, attempts := range loginRateLimiter.attempts {
 			valid := attempts[:0]
 			for _, t := range attempts {
@@ -48,18 +49,14 @@ func cleanupRateLimiter() {
 	}
 }
 
-const (
-	loginMaxAttempts = 5
-	loginWindow      = 5 * time.Minute
-)
-
 // checkLoginRate returns true if the IP has exceeded the login rate limit.
 func checkLoginRate(ip string) bool {
 	loginRateLimiter.mu.Lock()
 	defer loginRateLimiter.mu.Unlock()
 
+	maxAttempts, window := shared.LoginLimit()
 	now := time.Now()
-	cutoff := now.Add(-loginWindow)
+	cutoff := now.Add(-window)
 
 	// Filter out expired attempts
 	attempts := loginRateLimiter.attempts[ip]
@@ -71,7 +68,7 @@ func checkLoginRate(ip string) bool {
 	}
 	loginRateLimiter.attempts[ip] = valid
 
-	return len(valid) >= loginMaxAttempts
+	return len(valid) >= maxAttempts
 }
 
 func recordLoginAttempt(ip string) {
@@ -91,6 +88,12 @@ func (h *HTTPServer_t) AuthRoutes(r chi.Router) {
 	r.Group(func(r chi.Router) {
 		r.Use(h.SessionValidationMiddleware)
 		r.Post("/password", h.changePasswordHandler)
+		r.Get("/apikeys", h.listAPIKeys)
+		r.Post("/apikeys", h.createAPIKey)
+		r.Delete("/apikeys/{id}", h.revokeAPIKey)
+		r.Get("/sessions", h.listSessions)
+		r.Delete("/sessions", h.revokeOtherSessions)
+		r.Delete("/sessions/{id}", h.revokeSession)
 	})
 }
 
@@ -159,18 +162,42 @@ func (h *HTTPServer_t) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Store session in Redis for server-side invalidation
-	ttl := shared.AppConfig.Database.Redis.UserTTL()
-	if err := rds.SetUserSession(r.Context(), token, loginReq.Username, ttl); err != nil {
+	// Store session in Redis for server-side invalidation, indexed under the
+	// user for GET /auth/sessions. With an idle timeout the key expires when
+	// the session goes unused, and touchSession pushes that back.
+	maxAge, idle := sessionTimeouts()
+	ttl := maxAge
+	if idle > 0 {
+		ttl = idle
+	}
+	session := parseSessionFromToken(token)
+	if session == nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	userSession := newUserSession(r, loginReq.Username, session.SessionID, maxAge, idle)
+	if err := rds.AddUserSession(r.Context(), token, userSession, ttl); err != nil {
 		shared.DebugPrint("Failed to store user session: %v", err)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
+	if shared.AppConfig.Auth.SingleSession {
+		revoked, err := rds.RevokeOtherUserSessions(r.Context(), loginReq.Username, session.SessionID)
+		if err != nil {
+			shared.DebugPrint("Failed to end other sessions of %s: %v", loginReq.Username, err)
+		} else if len(revoked) > 0 {
+			shared.DebugPrint("AUTH: Login of %s ended %d other session(s)", loginReq.Username, len(revoked))
+		}
+	}
 
 	response := map[string]interface{}{
-		"status":  "success",
-		"message": "Logged in successfully",
-		"token":   token,
+		"status":     "success",
+		"message":    "Logged in successfully",
+		"token":      token,
+		"expires_at": userSession.ExpiresAt,
+	}
+	if userSession.IdleExpiresAt > 0 {
+		response["idle_timeout"] = int64(idle.Seconds())
 	}
 
 	shared.DebugPrint("AUTH: User %s logged in", loginReq.Username)
@@ -190,18 +217,26 @@ func (h *HTTPServer_t) logoutHandler(w http.ResponseWriter, r *http.Request) {
 	rds := h.db.Redis()
 	if rds != nil {
 		rds.RemoveUserSession(r.Context(), token)
+		if session := parseSessionFromToken(token); session != nil {
+			rds.RevokeUserSession(r.Context(), session.UserID, session.SessionID)
+			rds.ClearSSESubscriptions(r.Context(), session.SessionID)
+		}
 	}
 
 	sendJSONResponse(w, []byte(`{"status": "success", "message": "Logged out successfully"}`), http.StatusOK)
 }
 
 // validateSessionFull validates JWT and checks that the session still exists in Redis.
-// This prevents use of tokens after logout.
+// This prevents use of tokens after logout. API keys are checked against
+// their stored hash instead.
 func (h *HTTPServer_t) validateSessionFull(r *http.Request) *shared.Session {
 	token := extractRawToken(r)
 	if token == "" {
 		return nil
 	}
+	if auth.IsAPIKey(token) {
+		return noteUser(r, h.validateAPIKey(r, token))
+	}
 	session := parseSessionFromToken(token)
 	if session == nil {
 		return nil
@@ -212,8 +247,9 @@ func (h *HTTPServer_t) validateSessionFull(r *http.Request) *shared.Session {
 		if err != nil || username != session.UserID {
 			return nil
 		}
+		h.touchSession(r, session)
 	}
-	return session
+	return noteUser(r, session)
 }
 
 // extractRawToken pulls the raw JWT string from the request.
@@ -278,7 +314,7 @@ func (h *HTTPServer_t) issueTicketHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := rds.SetTicket(r.Context(), ticket, session.UserID, ticketTTL); err != nil {
+	if err := rds.SetTicket(r.Context(), ticket, session.UserID, session.SessionID, ticketTTL); err != nil {
 		http.Error(w, "Failed to store ticket", http.StatusInternalServerError)
 		return
 	}
@@ -293,6 +329,9 @@ func (h *HTTPServer_t) changePasswordHandler(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if !requireLoginSession(w, withSession(r, session)) {
+		return
+	}
 
 	var req struct {
 		CurrentPassword string `json:"current_password"`
@@ -357,12 +396,12 @@ func (h *HTTPServer_t) validateTicket(r *http.Request) *shared.Session {
 	if rds == nil {
 		return nil
 	}
-	username, err := rds.ConsumeTicket(r.Context(), ticket)
+	username, sessionID, err := rds.ConsumeTicket(r.Context(), ticket)
 	if err != nil || username == "" {
 		return nil
 	}
-	return &shared.Session{
+	return noteUser(r, &shared.Session{
 		UserID:    username,
-		SessionID: "ticket",
-	}
+		SessionID: sessionID,
+	})
 }
@@ -135,7 +135,8 @@ func TestLoginRateLimiter(t *testing.T) {
 	}
 
 	// Record max attempts
-	for i := 0; i < loginMaxAttempts; i++ {
+	maxAttempts, _ := shared.LoginLimit()
+	for i := 0; i < maxAttempts; i++ {
 		recordLoginAttempt(ip)
 	}
 
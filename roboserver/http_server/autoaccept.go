@@ -0,0 +1,75 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/autoaccept"
+)
+
+// getAutoAccept returns the registration auto-accept settings in force and
+// whether they come from the config or were set at runtime. Admin only.
+func (h *HTTPServer_t) getAutoAccept(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	s, err := handler_engine.AutoAcceptSettings(r.Context(), h.db.Redis())
+	if err != nil {
+		http.Error(w, "Failed to read auto-accept settings", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, s, http.StatusOK)
+}
+
+// putAutoAccept replaces the auto-accept settings on every node until
+// DELETE /register/auto_accept restores the configured ones. Admin only.
+// Body: {"policies": ["trusted_subnets"], "device_ids": [], "device_types": [],
+// "trusted_subnets": ["10.0.4.0/24"]}
+func (h *HTTPServer_t) putAutoAccept(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body shared.AutoAcceptConfig
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if _, err := autoaccept.Compile(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	s, err := handler_engine.SetAutoAccept(r.Context(), rds, body, h.currentUser(r).Username)
+	if err != nil {
+		http.Error(w, "Failed to store auto-accept settings", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, s, http.StatusOK)
+}
+
+// deleteAutoAccept drops the runtime auto-accept settings, so the
+// configured ones apply again. Admin only.
+func (h *HTTPServer_t) deleteAutoAccept(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	found, err := handler_engine.ResetAutoAccept(r.Context(), rds)
+	if err != nil {
+		http.Error(w, "Failed to reset auto-accept settings", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Auto-accept settings were not changed at runtime", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, map[string]string{"status": "reset"}, http.StatusOK)
+}
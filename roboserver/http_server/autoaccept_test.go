@@ -0,0 +1,31 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAutoAcceptEndpoints_RequireAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+	}{
+		{"get", "GET", s.getAutoAccept},
+		{"put", "PUT", s.putAutoAccept},
+		{"delete", "DELETE", s.deleteAutoAccept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/register/auto_accept", strings.NewReader(`{"policies": ["manual"]}`))
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+			}
+		})
+	}
+}
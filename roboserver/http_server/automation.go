@@ -0,0 +1,36 @@
+package http_server
+
+import (
+	"errors"
+	"net/http"
+	"roboserver/automation"
+)
+
+// getAutomation lists the automation scripts with their run and error
+// counts, including scripts that failed to load.
+func (h *HTTPServer_t) getAutomation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	sendResponseAsJSON(w, automation.Default.Status(), http.StatusOK)
+}
+
+// postAutomationReload reloads the scripts from automation.dir on this node.
+func (h *HTTPServer_t) postAutomationReload(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	n, err := automation.Default.Reload()
+	if errors.Is(err, automation.ErrDisabled) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, map[string]any{
+		"loaded":  n,
+		"scripts": automation.Default.Status(),
+	}, http.StatusOK)
+}
@@ -0,0 +1,99 @@
+package http_server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"roboserver/backup"
+	"roboserver/shared"
+	"strconv"
+	"time"
+)
+
+// postBackup downloads an encrypted backup archive. Admin only. Body
+// (optional): {"passphrase": "..."}, default backup.passphrase.
+func (h *HTTPServer_t) postBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if r.ContentLength != 0 {
+		if err := parseJSONRequest(r, &body); err != nil {
+			sendBodyError(w, err)
+			return
+		}
+	}
+	passphrase, err := backup.Passphrase(body.Passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.db == nil || h.db.Postgres() == nil || h.db.Redis() == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	archive, err := backup.Create(r.Context(), h.db)
+	if err != nil {
+		shared.DebugPrint("Backup failed: %v", err)
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	data, err := backup.Seal(archive, passphrase)
+	if err != nil {
+		http.Error(w, "Failed to encrypt backup", http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("robomesh-%s.rmbk", time.Unix(archive.CreatedAt, 0).UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// postRestore restores the archive sent as the request body (at most
+// limits.http_body). Admin only. The passphrase comes from the
+// X-Backup-Passphrase header, default backup.passphrase; ?config=true also
+// overwrites config.yaml.
+func (h *HTTPServer_t) postRestore(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	passphrase, err := backup.Passphrase(r.Header.Get("X-Backup-Passphrase"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Archive exceeds limits.http_body; restore it from the terminal or CLI", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	archive, err := backup.Open(data, passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.db == nil || h.db.Postgres() == nil || h.db.Redis() == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	opts := backup.RestoreOptions{Config: r.URL.Query().Get("config") == "true"}
+	sum, err := backup.Restore(r.Context(), h.db, h.bus, archive, opts)
+	if err != nil {
+		shared.DebugPrint("Restore failed: %v", err)
+		http.Error(w, "Restore failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	shared.DebugPrint("%s restored a backup from %s: %+v", h.currentUser(r).Username, time.Unix(archive.CreatedAt, 0).UTC().Format(time.RFC3339), sum)
+	sendResponseAsJSON(w, sum, http.StatusOK)
+}
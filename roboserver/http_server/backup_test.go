@@ -0,0 +1,21 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackupRestoreRequireAdmin(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	for name, handler := range map[string]http.HandlerFunc{
+		"/admin/backup":  h.postBackup,
+		"/admin/restore": h.postRestore,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, name, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 without an admin session, got %d", name, rec.Code)
+		}
+	}
+}
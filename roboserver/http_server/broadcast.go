@@ -0,0 +1,112 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/jobs"
+)
+
+// postBroadcast sends a message to the handler of every active robot the
+// caller can access, optionally narrowed by a filter, and reports the
+// outcome per robot. Unlike quick_action the message is sent as is.
+// With "async": true it runs as a background job and responds 202 with the
+// job; the report becomes the job's result (GET /jobs/{id}).
+// Body: {"message": "...", "filter": {"type": "...", "tags": [...], "zone": "..."}, "async": false}
+func (h *HTTPServer_t) postBroadcast(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Message string            `json:"message"`
+		Filter  quickActionFilter `json:"filter"`
+		Async   bool              `json:"async"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if body.Message == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+	if err := shared.CheckPayloadSize("handler", len(body.Message), shared.AppConfig.Limits.HandlerMessageBytes()); err != nil {
+		sendHandlerError(w, err)
+		return
+	}
+
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	uuids, err := selectRobots(r.Context(), rds, h.currentUser(r), &body.Filter)
+	if err != nil {
+		http.Error(w, "Failed to get active robots", http.StatusInternalServerError)
+		return
+	}
+	if body.Async {
+		createdBy := ""
+		if user := h.currentUser(r); user != nil {
+			createdBy = user.Username
+		}
+		job, err := jobs.Default.Submit("broadcast", createdBy, func(ctx context.Context, p *jobs.Progress) (any, error) {
+			return h.broadcast(ctx, rds, uuids, body.Message, p), nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		sendResponseAsJSON(w, job, http.StatusAccepted)
+		return
+	}
+	sendResponseAsJSON(w, h.broadcast(r.Context(), rds, uuids, body.Message, nil), http.StatusOK)
+}
+
+// broadcast sends message to uuids and returns the per-robot report,
+// counting each robot done on p (nil outside a job).
+func (h *HTTPServer_t) broadcast(ctx context.Context, rds *database.RedisHandler, uuids []string, message string, p *jobs.Progress) map[string]interface{} {
+	p.SetTotal(len(uuids))
+	targets, held := holdForMaintenance(ctx, rds, uuids, message)
+	selected := make(map[string]bool, len(targets))
+	for _, uuid := range targets {
+		selected[uuid] = true
+	}
+
+	sent := handler_engine.HandlerManager.Broadcast(ctx, message, "", func(s handler_engine.HandlerSnapshot) bool {
+		return selected[s.UUID]
+	})
+
+	results := make(map[string]quickActionResult, len(uuids))
+	failed := 0
+	for uuid, res := range held {
+		results[uuid] = res
+	}
+	p.Add(len(held) + len(sent))
+	for _, uuid := range targets {
+		res := quickActionResult{Status: "sent"}
+		if err, ok := sent[uuid]; !ok {
+			// Not running here; in a cluster it may be on another node.
+			if _, forwarded, ferr := h.forwardMessage(ctx, uuid, message, "", 0); forwarded && ferr == nil {
+				res.Status = "forwarded"
+			} else {
+				res = quickActionResult{Status: "error", Error: "No handler running for this robot"}
+			}
+			p.Add(1)
+		} else if err != nil {
+			_, text := handlerErrorStatus(err)
+			res = quickActionResult{Status: "error", Error: text}
+		}
+		if res.Status == "error" {
+			failed++
+		}
+		results[uuid] = res
+	}
+
+	return map[string]interface{}{
+		"matched": len(uuids),
+		"sent":    len(targets) - failed,
+		"held":    len(held),
+		"failed":  failed,
+		"results": results,
+	}
+}
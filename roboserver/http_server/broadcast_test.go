@@ -0,0 +1,39 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"strings"
+	"testing"
+)
+
+func TestPostBroadcast_Validation(t *testing.T) {
+	saved := shared.AppConfig.Limits.HandlerMessage
+	defer func() { shared.AppConfig.Limits.HandlerMessage = saved }()
+	shared.AppConfig.Limits.HandlerMessage = 16
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing message", `{"filter": {"type": "lamp"}}`, http.StatusBadRequest},
+		{"oversized message", `{"message": "this message is far too long"}`, http.StatusRequestEntityTooLarge},
+		{"nil redis", `{"message": "status"}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(&mockDBManager{})
+			req := httptest.NewRequest("POST", "/robot/broadcast", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			s.postBroadcast(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package http_server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"roboserver/cluster"
+	"roboserver/comms"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/tracing"
+	"time"
+)
+
+// forwardToRemoteHandler routes an API message to the cluster node holding
+// the robot's connection, when its handler isn't running here. It writes a
+// 202 and returns true if the message was forwarded. Delivery is fire and
+// forget: the owning node drops the message if its handler is busy or
+// holds another command's lock.
+func (h *HTTPServer_t) forwardToRemoteHandler(w http.ResponseWriter, r *http.Request, uuid, message, actor string, lock time.Duration) bool {
+	node, ok, err := h.forwardMessage(r.Context(), uuid, message, actor, lock)
+	if !ok {
+		return false
+	}
+	if err != nil {
+		if errors.Is(err, shared.ErrPayloadTooLarge) || errors.Is(err, handler_engine.ErrInvalidCommand) {
+			sendHandlerError(w, err)
+		} else {
+			http.Error(w, "Failed to forward message", http.StatusBadGateway)
+		}
+		return true
+	}
+	resp := map[string]string{"status": "forwarded", "uuid": uuid, "node": node}
+	if actor != "" {
+		resp["actor"] = actor
+	}
+	sendResponseAsJSON(w, resp, http.StatusAccepted)
+	return true
+}
+
+// forwardMessage publishes message for uuid's handler on another node. ok
+// is false when clustering is off or the robot isn't connected elsewhere.
+// lock is the command lock requested for it (see sendCommand).
+func (h *HTTPServer_t) forwardMessage(ctx context.Context, uuid, message, actor string, lock time.Duration) (node string, ok bool, err error) {
+	if !shared.AppConfig.Cluster.Enabled || h.bus == nil || h.db.Redis() == nil {
+		return "", false, nil
+	}
+	active, err := h.db.Redis().GetActiveRobot(ctx, uuid)
+	if err != nil || active.Node == "" || active.Node == shared.NodeID() {
+		return "", false, nil
+	}
+	if err := shared.CheckPayloadSize("handler", len(message), shared.AppConfig.Limits.HandlerMessageBytes()); err != nil {
+		return active.Node, true, err
+	}
+	// The node running the handler checks again; checking here as well
+	// lets the caller see why a command was refused.
+	if err := handler_engine.CheckCommand(active.DeviceType, message); err != nil {
+		return active.Node, true, err
+	}
+	msg := events.ForwardedMessage{Message: message, Actor: actor, LockMS: lock.Milliseconds(), TraceParent: tracing.Inject(ctx)}
+	return active.Node, true, comms.PublishContext(ctx, h.bus, events.HandlerIncoming(uuid), msg)
+}
+
+// getClusterStatus reports this node's id and the current leader.
+func (h *HTTPServer_t) getClusterStatus(w http.ResponseWriter, r *http.Request) {
+	status := map[string]any{
+		"enabled": shared.AppConfig.Cluster.Enabled,
+		"node":    shared.NodeID(),
+		"leader":  cluster.IsLeader(),
+	}
+	if shared.AppConfig.Cluster.Enabled && h.db.Redis() != nil {
+		leader, err := h.db.Redis().GetLeader(r.Context())
+		if err != nil {
+			http.Error(w, "Failed to read cluster leader", http.StatusInternalServerError)
+			return
+		}
+		status["leader_node"] = leader
+	}
+	sendResponseAsJSON(w, status, http.StatusOK)
+}
@@ -0,0 +1,129 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+	"roboserver/discovery"
+	"roboserver/shared/jobs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// discoveredRobot is a discovery candidate with whether its uuid is
+// already in the registry.
+type discoveredRobot struct {
+	discovery.Candidate
+	Provisionable bool `json:"provisionable"`
+	Registered    bool `json:"registered"`
+}
+
+// postDiscover scans the network for robots as a background job and
+// responds 202 with the job; its result lists what the scan found. Admin
+// only. Body (optional): {"cidrs": ["192.168.1.0/24"]} overrides
+// discovery.cidrs for this scan.
+func (h *HTTPServer_t) postDiscover(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		CIDRs []string `json:"cidrs"`
+	}
+	if r.ContentLength != 0 {
+		if err := parseJSONRequest(r, &body); err != nil {
+			sendBodyError(w, err)
+			return
+		}
+	}
+	opts := discovery.OptionsFromConfig()
+	if len(body.CIDRs) > 0 {
+		opts.CIDRs = body.CIDRs
+	}
+	// Check the subnets now, so a typo is a 400 instead of a failed job.
+	cidrs := opts.CIDRs
+	if len(cidrs) == 0 {
+		cidrs = discovery.LocalSubnets()
+	}
+	if _, err := discovery.Hosts(cidrs, opts.MaxHosts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job, err := jobs.Default.Submit("discovery_scan", h.currentUser(r).Username, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		found, err := discovery.Run(ctx, h.bus, opts, p)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"found": found}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	sendResponseAsJSON(w, job, http.StatusAccepted)
+}
+
+// getDiscovered lists the devices found by this node's scans within
+// discovery.keep, by address. Admin only, like the scan itself.
+func (h *HTTPServer_t) getDiscovered(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	sendResponseAsJSON(w, h.discovered(r.Context()), http.StatusOK)
+}
+
+// discovered returns the current discovery candidates, each marked with
+// whether its uuid is already registered.
+func (h *HTTPServer_t) discovered(ctx context.Context) []discoveredRobot {
+	pg := h.db.Postgres()
+	list := discovery.Found.List()
+	out := make([]discoveredRobot, 0, len(list))
+	for _, c := range list {
+		d := discoveredRobot{Candidate: c, Provisionable: c.Provisionable()}
+		if pg != nil && c.UUID != "" {
+			if rec, err := h.robotRecord(ctx, pg, c.UUID); err == nil && rec != nil {
+				d.Registered = true
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// provisionDiscovered provisions the device found at {ip} with the uuid,
+// device type and public key it reported, as POST /provision does. Admin
+// only. Body (optional): {"device_type": "..."} overrides the reported type.
+func (h *HTTPServer_t) provisionDiscovered(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	h.provisionCandidate(w, r, chi.URLParam(r, "ip"))
+}
+
+// provisionCandidate provisions the discovery candidate at ip and forgets
+// it once registered.
+func (h *HTTPServer_t) provisionCandidate(w http.ResponseWriter, r *http.Request, ip string) {
+	c, ok := discovery.Found.Lookup(ip)
+	if !ok {
+		http.Error(w, "No device discovered at that address", http.StatusNotFound)
+		return
+	}
+	var body struct {
+		DeviceType string `json:"device_type"`
+	}
+	if r.ContentLength != 0 {
+		if err := parseJSONRequest(r, &body); err != nil {
+			sendBodyError(w, err)
+			return
+		}
+	}
+	if body.DeviceType != "" {
+		c.DeviceType = body.DeviceType
+	}
+	if !c.Provisionable() {
+		http.Error(w, "Device did not report a uuid, device type and public key; it must register over TCP instead", http.StatusUnprocessableEntity)
+		return
+	}
+	if h.provision(w, r, ProvisionRequest{UUID: c.UUID, PublicKey: c.PublicKey, DeviceType: c.DeviceType}) {
+		discovery.Found.Remove(ip)
+	}
+}
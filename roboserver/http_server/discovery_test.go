@@ -0,0 +1,67 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/discovery"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoveredListAndProvision(t *testing.T) {
+	discovery.Found.Merge([]discovery.Candidate{
+		{IP: "10.9.0.7", Port: 5002, Via: "udp", UUID: "lamp-7", DeviceType: "lamp", PublicKey: "abcd"},
+		{IP: "10.9.0.8", Port: 5003, Via: "tcp", UUID: "rover-2"},
+	}, time.Now())
+	defer discovery.Found.Remove("10.9.0.7")
+	defer discovery.Found.Remove("10.9.0.8")
+	h := newTestServer(&mockDBManager{})
+
+	list := h.discovered(context.Background())
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 discovered robots, got %+v", list)
+	}
+	if !list[0].Provisionable || list[1].Provisionable || list[0].Registered {
+		t.Errorf("Unexpected flags: %+v", list)
+	}
+
+	for ip, want := range map[string]int{
+		"10.9.0.9": http.StatusNotFound,
+		"10.9.0.8": http.StatusUnprocessableEntity,
+		"10.9.0.7": http.StatusBadRequest, // "abcd" is not a valid public key
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/robot/discovered/"+ip+"/provision", nil)
+		rec := httptest.NewRecorder()
+		h.provisionCandidate(rec, req, ip)
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d (%s)", ip, want, rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
+
+func TestPostDiscoverRequiresAdmin(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	rec := httptest.NewRecorder()
+	h.postDiscover(rec, httptest.NewRequest(http.MethodPost, "/robot/discover", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without a user, got %d", rec.Code)
+	}
+}
+
+func TestDiscoveredEndpointsRequireAdmin(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	rec := httptest.NewRecorder()
+	h.getDiscovered(rec, httptest.NewRequest(http.MethodGet, "/robot/discovered", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET /robot/discovered: expected 403 without a user, got %d", rec.Code)
+	}
+
+	req := addChiURLParam(httptest.NewRequest(http.MethodPost, "/robot/discovered/10.9.0.7/provision", nil), "ip", "10.9.0.7")
+	rec = httptest.NewRecorder()
+	h.provisionDiscovered(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST /robot/discovered/{ip}/provision: expected 403 without a user, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/shared/msgpack"
+	"strconv"
+	"strings"
+)
+
+// responseEncoder encodes response bodies in one media type.
+type responseEncoder struct {
+	mediaType string
+	aliases   []string // other Accept names for the same encoding
+	encode    func(v any) ([]byte, error)
+}
+
+// responseEncoders are the media types negotiated from the Accept header
+// by handlers that use sendNegotiated; add an entry to support another.
+// The first is the default.
+var responseEncoders = []*responseEncoder{
+	{mediaType: "application/json", encode: encodeJSON},
+	{mediaType: "application/msgpack", aliases: []string{"application/x-msgpack"}, encode: msgpack.Marshal},
+}
+
+func (e *responseEncoder) matches(mediaType string) bool {
+	if mediaType == e.mediaType {
+		return true
+	}
+	for _, alias := range e.aliases {
+		if mediaType == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoder picks the encoder for an Accept header: the registered
+// type with the highest q value, earliest listed on ties. Wildcards, an
+// empty header and headers naming nothing registered get JSON, so clients
+// that don't negotiate are unaffected.
+func negotiateEncoder(accept string) *responseEncoder {
+	best, bestQ := responseEncoders[0], 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, enc := range responseEncoders {
+			if enc.matches(mediaType) {
+				best, bestQ = enc, q
+				break
+			}
+		}
+	}
+	return best
+}
+
+// sendNegotiated encodes data in the type the request's Accept header asks
+// for (JSON by default) and writes it with status.
+func sendNegotiated(w http.ResponseWriter, r *http.Request, data any, status int) {
+	enc := negotiateEncoder(r.Header.Get("Accept"))
+	body, err := enc.encode(data)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	writeEncodedBody(w, enc, body, status)
+}
+
+// writeEncodedBody writes a body already encoded by enc.
+func writeEncodedBody(w http.ResponseWriter, enc *responseEncoder, body []byte, status int) {
+	w.Header().Set("Content-Type", enc.mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(body)
+}
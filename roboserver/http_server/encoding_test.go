@@ -0,0 +1,41 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateEncoder(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                      "application/json",
+		"*/*":                                   "application/json",
+		"text/html":                             "application/json",
+		"application/msgpack":                   "application/msgpack",
+		"application/x-msgpack":                 "application/msgpack",
+		"application/json, application/msgpack": "application/json",
+		"application/json;q=0.5, application/msgpack":     "application/msgpack",
+		"application/msgpack;q=0, application/json;q=0.1": "application/json",
+	} {
+		if got := negotiateEncoder(accept).mediaType; got != want {
+			t.Errorf("Accept %q: expected %s, got %s", accept, want, got)
+		}
+	}
+}
+
+func TestSendNegotiatedMsgpack(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/robot/r1", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	rec := httptest.NewRecorder()
+	sendNegotiated(rec, req, map[string]any{"online": true}, http.StatusOK)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/msgpack" {
+		t.Errorf("Expected a msgpack content type, got %q", ct)
+	}
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Error("Expected Vary: Accept")
+	}
+	if got := rec.Body.Bytes(); string(got) != "\x81\xa6online\xc3" {
+		t.Errorf("Unexpected body % x", got)
+	}
+}
@@ -0,0 +1,155 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/database"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultEnergyDays = 30
+	maxEnergyDays     = 366
+)
+
+// energySince parses ?days=N (default 30, max 366) into the first day, as
+// YYYY-MM-DD in UTC, of the period it covers (today counts as one day).
+func energySince(r *http.Request, now time.Time) (string, bool) {
+	days := defaultEnergyDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxEnergyDays {
+			return "", false
+		}
+		days = n
+	}
+	return now.UTC().AddDate(0, 0, 1-days).Format(time.DateOnly), true
+}
+
+// getRobotEnergy returns a robot's daily energy use, oldest first, and the
+// period's total in watt-hours. ?days=N (default 30).
+func (h *HTTPServer_t) getRobotEnergy(w http.ResponseWriter, r *http.Request) {
+	pg := h.db.Postgres()
+	if pg == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+	since, ok := energySince(r, time.Now())
+	if !ok {
+		http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+		return
+	}
+
+	uuid := chi.URLParam(r, "uuid")
+	usage, err := pg.GetEnergyUsage(r.Context(), uuid, since)
+	if err != nil {
+		http.Error(w, "Failed to get energy usage", http.StatusInternalServerError)
+		return
+	}
+	if usage == nil {
+		usage = []database.EnergyUsage{}
+	}
+	var total float64
+	for _, u := range usage {
+		total += u.ReportedWh + u.EstimatedWh
+	}
+	sendResponseAsJSON(w, map[string]interface{}{
+		"uuid":     uuid,
+		"since":    since,
+		"days":     usage,
+		"total_wh": total,
+	}, http.StatusOK)
+}
+
+type energyDay struct {
+	Day    string  `json:"day"`
+	Wh     float64 `json:"wh"`
+	Robots int     `json:"robots"`
+}
+
+type energyRobot struct {
+	UUID       string  `json:"uuid"`
+	DeviceType string  `json:"device_type"`
+	Wh         float64 `json:"wh"`
+}
+
+// maxEnergyTopRobots is how many of the biggest consumers the fleet
+// summary lists.
+const maxEnergyTopRobots = 10
+
+// getFleetEnergy summarizes energy use across the robots the caller can
+// access: totals, per day, per device type and the biggest consumers.
+// ?days=N (default 30).
+func (h *HTTPServer_t) getFleetEnergy(w http.ResponseWriter, r *http.Request) {
+	pg, rds := h.db.Postgres(), h.db.Redis()
+	if pg == nil || rds == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+	since, ok := energySince(r, time.Now())
+	if !ok {
+		http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+		return
+	}
+	usage, err := pg.GetEnergyUsage(r.Context(), "", since)
+	if err != nil {
+		http.Error(w, "Failed to get energy usage", http.StatusInternalServerError)
+		return
+	}
+
+	user := h.currentUser(r)
+	access := make(map[string]bool)
+	var reported, estimated float64
+	var days []energyDay
+	byType := make(map[string]float64)
+	byRobot := make(map[string]*energyRobot)
+	for _, u := range usage {
+		allowed, seen := access[u.UUID]
+		if !seen {
+			allowed, _ = rds.CanAccessRobot(r.Context(), user, u.UUID)
+			access[u.UUID] = allowed
+		}
+		if !allowed {
+			continue
+		}
+		wh := u.ReportedWh + u.EstimatedWh
+		reported += u.ReportedWh
+		estimated += u.EstimatedWh
+		// usage is ordered by day, so a new day starts a new entry.
+		if len(days) == 0 || days[len(days)-1].Day != u.Day {
+			days = append(days, energyDay{Day: u.Day})
+		}
+		days[len(days)-1].Wh += wh
+		days[len(days)-1].Robots++
+		byType[u.DeviceType] += wh
+		if byRobot[u.UUID] == nil {
+			byRobot[u.UUID] = &energyRobot{UUID: u.UUID, DeviceType: u.DeviceType}
+		}
+		byRobot[u.UUID].Wh += wh
+	}
+
+	top := make([]energyRobot, 0, len(byRobot))
+	for _, robot := range byRobot {
+		top = append(top, *robot)
+	}
+	sort.Slice(top, func(i, j int) bool { return top[i].Wh > top[j].Wh })
+	if len(top) > maxEnergyTopRobots {
+		top = top[:maxEnergyTopRobots]
+	}
+	if days == nil {
+		days = []energyDay{}
+	}
+
+	sendResponseAsJSON(w, map[string]interface{}{
+		"since":        since,
+		"total_wh":     reported + estimated,
+		"reported_wh":  reported,
+		"estimated_wh": estimated,
+		"by_day":       days,
+		"by_type":      byType,
+		"top_robots":   top,
+	}, http.StatusOK)
+}
@@ -0,0 +1,41 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEnergySince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	cases := []struct {
+		query string
+		want  string
+		ok    bool
+	}{
+		{"", "2026-02-09", true},
+		{"?days=1", "2026-03-10", true},
+		{"?days=7", "2026-03-04", true},
+		{"?days=0", "", false},
+		{"?days=367", "", false},
+		{"?days=x", "", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/energy"+c.query, nil)
+		got, ok := energySince(req, now)
+		if ok != c.ok || got != c.want {
+			t.Errorf("%q: expected %q %v, got %q %v", c.query, c.want, c.ok, got, ok)
+		}
+	}
+}
+
+func TestGetRobotEnergy_NoDatabase(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := addChiURLParam(httptest.NewRequest("GET", "/robot/r1/energy", nil), "uuid", "r1")
+	rec := httptest.NewRecorder()
+	s.getRobotEnergy(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
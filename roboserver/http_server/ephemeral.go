@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"roboserver/auth"
 	"roboserver/database"
+	"roboserver/handler_engine"
 	"roboserver/shared"
 	"time"
 
@@ -26,8 +27,8 @@ type EphemeralRequest struct {
 // No PostgreSQL record or public key verification is required.
 func (h *HTTPServer_t) createEphemeralSession(w http.ResponseWriter, r *http.Request) {
 	var req EphemeralRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := parseJSONRequest(r, &req); err != nil {
+		sendBodyError(w, err)
 		return
 	}
 
@@ -36,9 +37,15 @@ func (h *HTTPServer_t) createEphemeralSession(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if err := handler_engine.ValidateRegistration(req.UUID, req.DeviceType, nil); err != nil {
+		sendRegistrationError(w, err)
+		return
+	}
+
 	if req.IP == "" {
 		req.IP = r.RemoteAddr
 	}
+	req.IP = shared.CanonicalIP(req.IP)
 
 	rds := h.db.Redis()
 	if rds == nil {
@@ -60,6 +67,17 @@ func (h *HTTPServer_t) createEphemeralSession(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	if err := auth.CheckBan(r.Context(), rds, req.UUID, req.IP); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	conflict, err := auth.CheckIPConflict(r.Context(), h.db.Postgres(), rds, req.UUID, req.IP, "")
+	handler_engine.ReportIPConflict(h.bus, conflict)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
 	sessionID := auth.GenerateSessionID()
 	jwt, err := auth.IssueSessionJWT(req.UUID, req.DeviceType, req.IP, sessionID)
 	if err != nil {
@@ -1,11 +1,19 @@
 package http_server
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"roboserver/database"
 	"roboserver/http_server/http_events"
 	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/requestid"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // eventsHandler handles SSE connections. Accepts either a single-use ticket (?ticket=...)
@@ -26,14 +34,49 @@ func (h *HTTPServer_t) eventsHandler(w http.ResponseWriter, r *http.Request) {
 	// URL example: /events?events=robot_status,door_open,sensor_data
 	eventNames := []string{}
 	if eventsParam := r.URL.Query().Get("events"); eventsParam != "" {
-		// Split comma-separated event names
-		eventNames = strings.Split(eventsParam, ",")
-		// Trim whitespace from each event name
-		for i, name := range eventNames {
-			eventNames[i] = strings.TrimSpace(name)
+		// Split comma-separated event names, trimming whitespace and dropping empties
+		for _, name := range strings.Split(eventsParam, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				eventNames = append(eventNames, name)
+			}
 		}
 	}
 
+	// Robot-scoped types need access to the robot.
+	user := h.currentUser(withSession(r, session))
+	if denied := h.forbiddenEvents(r.Context(), user, eventNames); len(denied) > 0 {
+		http.Error(w, "Forbidden: "+strings.Join(denied, ","), http.StatusForbidden)
+		return
+	}
+
+	// Optional coalescing window: /events?batch=200 packs events arriving
+	// within 200ms into one "batch" frame.
+	opts := http_events.ClientOptions{
+		Backpressure: http_events.Backpressure{
+			LagQueue:    shared.AppConfig.SSE.LagQueue,
+			EvictQueue:  shared.AppConfig.SSE.EvictQueue,
+			LowPriority: shared.AppConfig.SSE.LowPriority,
+		},
+		ExpiryWarning: shared.AppConfig.Auth.ExpiryWarning(),
+		RequestID:     requestid.From(r.Context()),
+	}
+	if batchParam := r.URL.Query().Get("batch"); batchParam != "" {
+		ms, err := strconv.Atoi(batchParam)
+		if err != nil || ms < 0 {
+			http.Error(w, "batch must be a non-negative number of milliseconds", http.StatusBadRequest)
+			return
+		}
+		opts.BatchWindow = time.Duration(ms) * time.Millisecond
+	}
+
+	if http_events.AcceptsGzip(r) {
+		gz := http_events.NewGzipResponseWriter(w)
+		// Deferred calls run after the wait for client.Stopped below, so
+		// the trailer is the last thing written.
+		defer gz.Close()
+		w = gz
+	}
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -50,33 +93,47 @@ func (h *HTTPServer_t) eventsHandler(w http.ResponseWriter, r *http.Request) {
 	eSess := http_events.NewEventSession(session)
 
 	// Create a session validator that periodically checks Redis for session validity.
-	// This closes the SSE connection if the user logs out or the session is revoked.
+	// This closes the SSE connection if the user logs out or the session is
+	// revoked or expires, and warns the client before it expires.
 	token := extractRawToken(r)
-	validator := func() bool {
-		if token == "" {
-			return false
-		}
-		rds := h.db.Redis()
-		if rds == nil {
-			return false
-		}
-		username, err := rds.GetUserSession(r.Context(), token)
-		return err == nil && username == session.UserID
+	validator := func() http_events.SessionState {
+		return h.sessionState(r.Context(), token, session)
 	}
 
-	client := h.sseManager.RegisterClient(eSess, w, validator)
+	client := h.sseManager.RegisterClient(eSess, w, validator, opts)
 
-	shared.DebugPrint("Registered new SSE client (user=%s) subscribed to %v", eSess.Session.UserID, eventNames)
+	shared.DebugPrint("Registered new SSE client (user=%s) subscribed to %v [%s]", eSess.Session.UserID, eventNames, opts.RequestID)
 
-	// Subscribe to specific events if provided
-	if len(eventNames) > 0 {
-		for _, eventName := range eventNames {
-			client.SubscribeToEvent(eventName)
+	// Restore subscriptions from a previous stream on this session, then
+	// persist any newly requested ones so the next reconnect gets them too.
+	if rds := h.db.Redis(); rds != nil && session.SessionID != "" {
+		if restored, err := rds.GetSSESubscriptions(r.Context(), session.SessionID); err == nil && len(restored) > 0 {
+			// Access may have been revoked since the subscription was made.
+			if denied := h.forbiddenEvents(r.Context(), user, restored); len(denied) > 0 {
+				shared.DebugPrint("Dropping %d SSE subscriptions user %s may no longer receive", len(denied), session.UserID)
+				rds.RemoveSSESubscriptions(r.Context(), session.SessionID, denied)
+				restored = slices.DeleteFunc(restored, func(t string) bool { return slices.Contains(denied, t) })
+			}
+			shared.DebugPrint("Restoring %d SSE subscriptions for user %s", len(restored), session.UserID)
+			eventNames = append(restored, eventNames...)
+		}
+		if err := rds.AddSSESubscriptions(r.Context(), session.SessionID, eventNames, shared.AppConfig.Database.Redis.UserTTL()); err != nil {
+			shared.DebugPrint("Failed to persist SSE subscriptions: %v", err)
 		}
 	}
 
-	<-r.Context().Done()
+	// Subscribe to specific events if provided
+	for _, eventName := range eventNames {
+		client.SubscribeToEvent(eventName)
+	}
+
+	select {
+	case <-r.Context().Done():
+		shared.DebugPrint("SSE client (user=%s) disconnected [%s]", eSess.Session.UserID, opts.RequestID)
+	case <-client.Done(): // session revoked or evicted for falling behind
+	}
 	h.sseManager.UnregisterClient(eSess)
+	<-client.Stopped() // never return while the stream is still being written
 }
 
 func (h *HTTPServer_t) eventsSubscribeHandler(w http.ResponseWriter, r *http.Request) {
@@ -88,7 +145,13 @@ func (h *HTTPServer_t) eventsSubscribeHandler(w http.ResponseWriter, r *http.Req
 
 	var eStruct http_events.EventStruct
 	if err := parseJSONRequest(r, &eStruct); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		sendBodyError(w, err)
+		return
+	}
+
+	user := h.currentUser(withSession(r, sess))
+	if denied := h.forbiddenEvents(r.Context(), user, eStruct.EventTypes); len(denied) > 0 {
+		http.Error(w, "Forbidden: "+strings.Join(denied, ","), http.StatusForbidden)
 		return
 	}
 
@@ -105,9 +168,36 @@ func (h *HTTPServer_t) eventsSubscribeHandler(w http.ResponseWriter, r *http.Req
 		client.SubscribeToEvent(eventType)
 	}
 
+	if rds := h.db.Redis(); rds != nil && sess.SessionID != "" {
+		if err := rds.AddSSESubscriptions(r.Context(), sess.SessionID, eStruct.EventTypes, shared.AppConfig.Database.Redis.UserTTL()); err != nil {
+			shared.DebugPrint("Failed to persist SSE subscriptions: %v", err)
+		}
+	}
+
 	sendResponseAsJSON(w, map[string]interface{}{"status": "subscribed", "events": eStruct.EventTypes}, http.StatusOK)
 }
 
+// forbiddenEvents returns the robot-scoped types in eventTypes (see
+// events.RobotOf) that user may not receive. Without a user or Redis every
+// robot-scoped type is refused.
+func (h *HTTPServer_t) forbiddenEvents(ctx context.Context, user *database.User, eventTypes []string) []string {
+	var denied []string
+	rds := h.db.Redis()
+	for _, eventType := range eventTypes {
+		uuid, ok := events.RobotOf(eventType)
+		if !ok {
+			continue
+		}
+		if rds != nil {
+			if allowed, err := rds.CanAccessRobot(ctx, user, uuid); err == nil && allowed {
+				continue
+			}
+		}
+		denied = append(denied, eventType)
+	}
+	return denied
+}
+
 func (h *HTTPServer_t) eventsUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
 	sess := h.validateSessionFull(r)
 	if sess == nil {
@@ -117,7 +207,7 @@ func (h *HTTPServer_t) eventsUnsubscribeHandler(w http.ResponseWriter, r *http.R
 
 	var eStruct http_events.EventStruct
 	if err := parseJSONRequest(r, &eStruct); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		sendBodyError(w, err)
 		return
 	}
 
@@ -134,6 +224,46 @@ func (h *HTTPServer_t) eventsUnsubscribeHandler(w http.ResponseWriter, r *http.R
 		client.UnsubscribeFromEvent(eventType)
 	}
 
+	if rds := h.db.Redis(); rds != nil && sess.SessionID != "" {
+		if err := rds.RemoveSSESubscriptions(r.Context(), sess.SessionID, eStruct.EventTypes); err != nil {
+			shared.DebugPrint("Failed to remove persisted SSE subscriptions: %v", err)
+		}
+	}
+
 	shared.DebugPrint("Client %v unsubscribed from events %v", client, eStruct.EventTypes)
 	sendResponseAsJSON(w, map[string]interface{}{"status": "unsubscribed", "events": eStruct.EventTypes}, http.StatusOK)
 }
+
+// eventTypesResponse documents the SSE envelope and every event type the
+// server publishes.
+type eventTypesResponse struct {
+	Envelope envelopeDoc      `json:"envelope"`
+	Types    []events.TypeDoc `json:"types"`
+}
+
+type envelopeDoc struct {
+	Description string                `json:"description"`
+	Example     http_events.SentEvent `json:"example"`
+	Batch       string                `json:"batch"`
+}
+
+// getEventTypes handles GET /events/types.
+func (h *HTTPServer_t) getEventTypes(w http.ResponseWriter, r *http.Request) {
+	types := events.Types()
+	example := http_events.SentEvent{Id: "1"}
+	for _, t := range types {
+		if t.Type == events.RobotTelemetry("{uuid}") {
+			data, _ := json.Marshal(t.Example)
+			example.Type, example.Data = events.RobotTelemetry("rover-7"), string(data)
+			break
+		}
+	}
+	sendResponseAsJSON(w, eventTypesResponse{
+		Envelope: envelopeDoc{
+			Description: "Each SSE frame is one line \"data: <envelope>\". The envelope's data field is the payload encoded as a JSON string: parse it a second time to get a value matching the type's schema.",
+			Example:     example,
+			Batch:       "With /events?batch=<ms>, frames of type \"" + http_events.EVENT_TYPE_BATCH + "\" carry a JSON array of envelopes in data.",
+		},
+		Types: types,
+	}, http.StatusOK)
+}
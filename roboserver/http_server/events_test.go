@@ -0,0 +1,64 @@
+package http_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/database"
+	"roboserver/shared/events"
+	"testing"
+)
+
+func TestGetEventTypes(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	w := httptest.NewRecorder()
+	h.getEventTypes(w, httptest.NewRequest(http.MethodGet, "/events/types", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Envelope struct {
+			Example struct{ Type, Data string }
+		}
+		Types []struct {
+			Type    string
+			Schema  map[string]any
+			Example any
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	byType := map[string]map[string]any{}
+	for _, typ := range resp.Types {
+		byType[typ.Type] = typ.Schema
+	}
+	telemetry, ok := byType[events.RobotTelemetry("{uuid}")]
+	if !ok {
+		t.Fatalf("Expected the telemetry event in %d types", len(resp.Types))
+	}
+	if props, _ := telemetry["properties"].(map[string]any); props["metrics"] == nil {
+		t.Errorf("Expected the telemetry schema to list metrics, got %v", telemetry)
+	}
+
+	// The envelope's data is itself JSON.
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(resp.Envelope.Example.Data), &payload); err != nil || payload["type"] != "env" {
+		t.Errorf("Expected a JSON-encoded telemetry example, got %q", resp.Envelope.Example.Data)
+	}
+}
+
+func TestForbiddenEvents(t *testing.T) {
+	s := newTestServer(&mockDBManager{rds: unreachableRedis(t)})
+	types := []string{"robot.lamp-1.telemetry", "handler.lamp-1.log", "robot.virtual_created", "job.updated"}
+	denied := s.forbiddenEvents(context.Background(), nil, types)
+	if len(denied) != 2 || denied[0] != "robot.lamp-1.telemetry" || denied[1] != "handler.lamp-1.log" {
+		t.Errorf("Expected only the robot-scoped types refused, got %v", denied)
+	}
+	admin := &database.User{Username: "root", Role: "admin"}
+	if denied := s.forbiddenEvents(context.Background(), admin, types); len(denied) != 0 {
+		t.Errorf("Expected an admin allowed everything, got %v", denied)
+	}
+}
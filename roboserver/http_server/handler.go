@@ -7,6 +7,7 @@ import (
 	"roboserver/auth"
 	"roboserver/handler_engine"
 	"roboserver/shared"
+	"roboserver/shared/events"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -14,9 +15,12 @@ import (
 func (h *HTTPServer_t) HandlerRoutes(r chi.Router) {
 	r.Get("/", h.listHandlers)
 	r.Get("/types", h.listHandlerTypes)
-	r.Get("/{uuid}", h.getHandlerStatus)
-	r.Post("/{uuid}/start", h.startHandler)
-	r.Post("/{uuid}/kill", h.killHandler)
+	r.Route("/{uuid}", func(r chi.Router) {
+		r.Use(h.RobotAccessMiddleware)
+		r.Get("/", h.getHandlerStatus)
+		r.Post("/start", h.startHandler)
+		r.Post("/kill", h.killHandler)
+	})
 	// Log streaming moved to semi-public route with ticket-based auth (see http_server.go)
 }
 
@@ -47,8 +51,17 @@ func (h *HTTPServer_t) getHandlerStatus(w http.ResponseWriter, r *http.Request)
 		"active": ok,
 	}
 	if ok {
-		resp["pid"] = hp.PID
-		resp["device_type"] = hp.DeviceType
+		snap := hp.Snapshot()
+		resp["pid"] = snap.PID
+		resp["device_type"] = snap.DeviceType
+		resp["ip"] = snap.IP
+		resp["connected"] = snap.Connected
+		resp["reconnecting"] = snap.Reconnecting
+		resp["queued_messages"] = snap.QueuedMessages
+		resp["forward_heartbeats"] = snap.ForwardHeartbeats
+		resp["queue_length"] = snap.QueueLength
+		resp["queue_capacity"] = snap.QueueCapacity
+		resp["queue_overflows"] = snap.QueueOverflows
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -143,6 +156,9 @@ func (h *HTTPServer_t) streamHandlerLogs(w http.ResponseWriter, r *http.Request)
 	}
 
 	uuid := chi.URLParam(r, "uuid")
+	if !h.authorizeRobotStream(w, r, session, uuid) {
+		return
+	}
 
 	if !handler_engine.HandlerManager.Has(uuid) {
 		http.Error(w, "No handler running for this robot", http.StatusNotFound)
@@ -164,7 +180,7 @@ func (h *HTTPServer_t) streamHandlerLogs(w http.ResponseWriter, r *http.Request)
 	logCh := make(chan []byte, 256)
 
 	// Subscribe to handler log events
-	topic := fmt.Sprintf("handler.%s.log", uuid)
+	topic := events.HandlerLog(uuid)
 	cancel, err := h.bus.SubscribeEvent(topic, func(eventType string, data any) {
 		jsonData, err := json.Marshal(data)
 		if err != nil {
@@ -2,11 +2,10 @@ package http_server
 
 import (
 	"encoding/json"
-	"fmt"
-	"net"
 	"net/http"
 	"roboserver/auth"
 	"roboserver/shared"
+	"roboserver/shared/events"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -31,24 +30,28 @@ func (h *HTTPServer_t) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 		Payload   string `json:"payload"`
 		Signature string `json:"signature"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if err := parseJSONRequest(r, &req); err != nil {
+		sendBodyError(w, err)
 		return
 	}
 
-	// Extract IP from request
-	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	ip := shared.CanonicalIP(r.RemoteAddr)
 
 	result, err := auth.ProcessHeartbeat(r.Context(), req.UUID, req.Payload, req.Signature, ip, pg, rds)
 	if err != nil {
-		shared.DebugPrint("HTTP heartbeat failed for %s: %v", req.UUID, err)
-		http.Error(w, "Heartbeat rejected", http.StatusUnauthorized)
+		shared.DebugPrint("HTTP heartbeat failed: %v", err)
+		status := robotErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			http.Error(w, "Failed to record heartbeat", status)
+			return
+		}
+		http.Error(w, "Heartbeat rejected", status)
 		return
 	}
 
 	// Publish heartbeat event
 	if h.bus != nil {
-		h.bus.PublishEvent(fmt.Sprintf("robot.%s.heartbeat", result.UUID), result)
+		h.bus.PublishEvent(events.RobotHeartbeat(result.UUID), result)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
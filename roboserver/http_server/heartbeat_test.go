@@ -1,8 +1,11 @@
 package http_server
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"roboserver/shared"
 	"strings"
 	"testing"
 )
@@ -36,3 +39,22 @@ func TestHeartbeat_InvalidJSON_NilDB(t *testing.T) {
 		t.Errorf("Expected 503 for nil DB, got %d", rec.Code)
 	}
 }
+
+func TestRobotErrorStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{shared.NewRobotError("heartbeat", "r1", "", shared.ErrRobotNotFound), http.StatusUnauthorized},
+		{shared.NewRobotError("heartbeat", "r1", "", shared.ErrRobotMismatch), http.StatusUnauthorized},
+		{shared.NewRobotError("heartbeat", "r1", "", shared.ErrRobotBlacklisted), http.StatusForbidden},
+		{shared.NewRobotError("heartbeat", "r1", "", fmt.Errorf("%w: got 1", shared.ErrStaleHeartbeat)), http.StatusConflict},
+		{shared.NewRobotError("heartbeat", "r1", "", errors.New("bad payload")), http.StatusBadRequest},
+		{errors.New("redis down"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if got := robotErrorStatus(c.err); got != c.want {
+			t.Errorf("robotErrorStatus(%v) = %d, want %d", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package http_events
+
+import (
+	"net/http"
+	"path"
+	"roboserver/comms"
+	"roboserver/shared"
+	"time"
+)
+
+// Backpressure decides what happens to a client that reads slower than
+// its events arrive. Depths count events queued for the client.
+type Backpressure struct {
+	// LagQueue is the depth at which the client gets a "lagging" event and
+	// LowPriority events stop being queued for it; 0 = off.
+	LagQueue int
+	// EvictQueue is the depth at which the client is disconnected; 0 = never.
+	EvictQueue int
+	// LowPriority lists event type globs ("*" matches any run of
+	// characters) skipped while the client is lagging.
+	LowPriority []string
+}
+
+// lowPriority reports whether eventType may be skipped for a lagging client.
+func (bp *Backpressure) lowPriority(eventType string) bool {
+	for _, pattern := range bp.LowPriority {
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LagNotice is the data of a "lagging" event: sent when a client starts
+// falling behind (Lagging true) and again once it has caught up.
+type LagNotice struct {
+	Lagging bool `json:"lagging"`
+	Queued  int  `json:"queued"`
+	// Dropped counts low-priority events skipped so far on this stream.
+	Dropped int64 `json:"dropped"`
+}
+
+// ClientStats describes one SSE stream for GET /admin/sse.
+type ClientStats struct {
+	User        string `json:"user"`
+	ConnectedAt int64  `json:"connected_at"` // Unix milliseconds
+	Queued      int    `json:"queued"`
+	EventsSent  int64  `json:"events_sent"`
+	BytesSent   int64  `json:"bytes_sent"`
+	// BytesPerSec is the write rate over the last full second, before
+	// gzip compression.
+	BytesPerSec int64 `json:"bytes_per_sec"`
+	Dropped     int64 `json:"dropped"`
+	Lagging     bool  `json:"lagging"`
+}
+
+// enqueue queues an event for the client, applying its backpressure rules.
+// It runs on the publisher's goroutine, so it never writes to the stream.
+func (client *EventsClient) enqueue(event *comms.Event) {
+	bp := &client.backpressure
+	depth := client.msgQueue.Size()
+	if bp.EvictQueue > 0 && depth >= bp.EvictQueue {
+		client.evict(depth)
+		return
+	}
+	if bp.LagQueue > 0 && depth >= bp.LagQueue {
+		if client.lagging.CompareAndSwap(false, true) {
+			client.lagChanged.Store(true)
+		}
+		if bp.lowPriority(event.Type) {
+			client.dropped.Add(1)
+			return
+		}
+	}
+	client.msgQueue.Enqueue(event)
+}
+
+// evict disconnects a client that fell EvictQueue events behind. A write
+// stuck on a stalled connection is cut short by the write deadline.
+func (client *EventsClient) evict(depth int) {
+	if !client.evicted.CompareAndSwap(false, true) {
+		return
+	}
+	shared.DebugPrint("SSE client (user=%s) evicted: %d events queued, %d B/s [%s]", client.Session.Session.UserID, depth, client.bytesPerSec.Load(), client.requestID)
+	http.NewResponseController(client.Writer).SetWriteDeadline(time.Now())
+	client.cleanup()
+}
+
+// checkLag sends a "lagging" event when the client started lagging, or
+// has caught up (queue down to half of LagQueue) since the last one. It
+// runs on the stream's goroutine before each write, so the notice goes out
+// ahead of the backlog.
+func (client *EventsClient) checkLag() {
+	if client.lagging.Load() && client.msgQueue.Size() <= client.backpressure.LagQueue/2 {
+		if client.lagging.CompareAndSwap(true, false) {
+			client.lagChanged.Store(true)
+		}
+	}
+	if !client.lagChanged.Swap(false) {
+		return
+	}
+	client.sendSSEEvent(EVENT_TYPE_LAGGING, LagNotice{
+		Lagging: client.lagging.Load(),
+		Queued:  client.msgQueue.Size(),
+		Dropped: client.dropped.Load(),
+	}, "")
+}
+
+// countBytes records n bytes written to the stream and updates the
+// per-second rate.
+func (client *EventsClient) countBytes(n int) {
+	client.bytesSent.Add(int64(n))
+	client.windowBytes += int64(n)
+	if elapsed := time.Since(client.windowStart); elapsed >= time.Second {
+		client.bytesPerSec.Store(int64(float64(client.windowBytes) / elapsed.Seconds()))
+		client.windowStart, client.windowBytes = time.Now(), 0
+	}
+}
+
+// Stats returns the client's traffic and queue counters.
+func (client *EventsClient) Stats() ClientStats {
+	return ClientStats{
+		User:        client.Session.Session.UserID,
+		ConnectedAt: client.Session.Timestamp,
+		Queued:      client.msgQueue.Size(),
+		EventsSent:  client.eventsSent.Load(),
+		BytesSent:   client.bytesSent.Load(),
+		BytesPerSec: client.bytesPerSec.Load(),
+		Dropped:     client.dropped.Load(),
+		Lagging:     client.lagging.Load(),
+	}
+}
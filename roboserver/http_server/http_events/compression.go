@@ -0,0 +1,62 @@
+package http_events
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// GzipResponseWriter compresses an SSE stream. Flush pushes the pending
+// compressed block through to the client so events aren't held back by the
+// gzip buffer.
+type GzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func NewGzipResponseWriter(w http.ResponseWriter) *GzipResponseWriter {
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &GzipResponseWriter{
+		ResponseWriter: w,
+		gz:             gzip.NewWriter(w),
+	}
+}
+
+func (g *GzipResponseWriter) Write(p []byte) (int, error) {
+	return g.gz.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (g *GzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// Close writes the gzip trailer. Call it once nothing writes to the stream.
+func (g *GzipResponseWriter) Close() error {
+	return g.gz.Close()
+}
+
+func (g *GzipResponseWriter) Flush() {
+	g.gz.Flush()
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// AcceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func AcceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if q := strings.ReplaceAll(param, " ", ""); q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -1,6 +1,18 @@
 package http_events
 
+import "time"
+
 const (
 	// Event types
-	EVENT_TYPE_SESSION_ID = "sessID" // Initial session ID event
+	EVENT_TYPE_SESSION_ID       = "sessID"           // Initial session ID event
+	EVENT_TYPE_BATCH            = "batch"            // Data is a JSON array of SentEvent envelopes
+	EVENT_TYPE_LAGGING          = "lagging"          // Data is a LagNotice
+	EVENT_TYPE_SESSION_EXPIRING = "session_expiring" // Data is a SessionExpiring
+)
+
+const (
+	// MAX_BATCH_WINDOW caps the client-requested coalescing window.
+	MAX_BATCH_WINDOW = 5 * time.Second
+	// MAX_BATCH_SIZE caps how many events are packed into one batch frame.
+	MAX_BATCH_SIZE = 500
 )
@@ -7,14 +7,31 @@ import (
 	"roboserver/comms"
 	"roboserver/shared"
 	"roboserver/shared/data_structures"
+	"roboserver/shared/tracked"
 	"roboserver/shared/utils"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-// SessionValidator is a function that returns false if the session is no longer valid.
-type SessionValidator func() bool
+// SessionValidator is called periodically to check the stream's session.
+type SessionValidator func() SessionState
+
+// SessionState is what a SessionValidator found.
+type SessionState struct {
+	Valid bool
+	// ExpiresAt is when the session will end unless renewed; zero if unknown.
+	ExpiresAt time.Time
+	// Reason is "idle" when ExpiresAt is the idle timeout, "absolute" when
+	// it is the session's maximum age.
+	Reason string
+}
+
+// SessionExpiring is the data of a "session_expiring" event.
+type SessionExpiring struct {
+	ExpiresAt int64  `json:"expires_at"` // Unix seconds
+	Reason    string `json:"reason"`     // "idle" or "absolute"
+}
 
 type EventsClient struct {
 	Writer  http.ResponseWriter
@@ -22,25 +39,71 @@ type EventsClient struct {
 	manager *EventsManager_t
 	done    chan struct{}
 
+	releaseDone func() // ends done's count in shared/tracked
+
 	// cancelFuncs tracks subscription cancellation functions by event type.
 	cancelFuncs map[string]func()
 	cancelMu    sync.Mutex
 
 	msgQueue         *data_structures.SafeQueue[*comms.Event] // Queue for outgoing messages
-	ended            atomic.Bool                               // Indicates if the client has ended
-	sessionValidator SessionValidator                          // Periodic session check
+	ended            atomic.Bool                              // Indicates if the client has ended
+	sessionValidator SessionValidator                         // Periodic session check
+	batchWindow      time.Duration                            // Coalesce events within this window; 0 disables
+	expiryWarning    time.Duration                            // Warn this long before the session expires; 0 disables
+	warnedExpiry     time.Time                                // ExpiresAt of the last session_expiring event
+	stopped          chan struct{}                            // closed when ReadMsgQueue returns
+	requestID        string                                   // of the request that opened the stream
+
+	// Slow-client accounting (see backpressure.go). windowStart and
+	// windowBytes belong to the ReadMsgQueue goroutine.
+	backpressure Backpressure
+	lagging      atomic.Bool
+	lagChanged   atomic.Bool // a "lagging" event is due
+	evicted      atomic.Bool
+	dropped      atomic.Int64
+	eventsSent   atomic.Int64
+	bytesSent    atomic.Int64
+	bytesPerSec  atomic.Int64
+	windowStart  time.Time
+	windowBytes  int64
+}
+
+// ClientOptions are per-connection stream settings negotiated on /events.
+type ClientOptions struct {
+	// BatchWindow coalesces events arriving within this window into a single
+	// "batch" SSE frame. Clamped to MAX_BATCH_WINDOW; zero sends one frame per event.
+	BatchWindow time.Duration
+	// Backpressure handles a client falling behind; zero = never.
+	Backpressure Backpressure
+	// ExpiryWarning is how long before its session expires the client gets
+	// a "session_expiring" event; zero = never.
+	ExpiryWarning time.Duration
+	// RequestID is the X-Request-ID of the request that opened the stream,
+	// included in the stream's log lines.
+	RequestID string
 }
 
-func NewEventsClient(sess *EventSession, w http.ResponseWriter, manager *EventsManager_t, validator SessionValidator) *EventsClient {
+func NewEventsClient(sess *EventSession, w http.ResponseWriter, manager *EventsManager_t, validator SessionValidator, opts ClientOptions) *EventsClient {
+	batchWindow := opts.BatchWindow
+	if batchWindow > MAX_BATCH_WINDOW {
+		batchWindow = MAX_BATCH_WINDOW
+	}
 	return &EventsClient{
 		Writer:           w,
 		Session:          *sess,
 		manager:          manager,
 		done:             make(chan struct{}),
+		releaseDone:      tracked.Open("sse.done"),
 		cancelFuncs:      make(map[string]func()),
 		msgQueue:         data_structures.NewSafeQueue[*comms.Event](true),
 		ended:            atomic.Bool{},
 		sessionValidator: validator,
+		batchWindow:      batchWindow,
+		expiryWarning:    opts.ExpiryWarning,
+		stopped:          make(chan struct{}),
+		requestID:        opts.RequestID,
+		backpressure:     opts.Backpressure,
+		windowStart:      time.Now(),
 	}
 }
 
@@ -50,13 +113,14 @@ func (client *EventsClient) Start() {
 	client.ended.Store(false)
 
 	if client.sessionValidator != nil {
-		go client.validateSessionLoop()
+		tracked.Go("sse", client.validateSessionLoop)
 	}
-	go client.ReadMsgQueue()
+	tracked.Go("sse", client.ReadMsgQueue)
 }
 
 // validateSessionLoop periodically checks if the user session is still valid.
-// If the session has been revoked (e.g. logout), the SSE connection is closed.
+// If the session has been revoked (e.g. logout) or has expired, the SSE
+// connection is closed.
 func (client *EventsClient) validateSessionLoop() {
 	ticker := time.NewTicker(sessionCheckInterval)
 	defer ticker.Stop()
@@ -69,20 +133,41 @@ func (client *EventsClient) validateSessionLoop() {
 			if client.ended.Load() {
 				return
 			}
-			if !client.sessionValidator() {
-				shared.DebugPrint("SSE session invalidated for user %s, closing connection", client.Session.Session.UserID)
+			state := client.sessionValidator()
+			if !state.Valid {
+				shared.DebugPrint("SSE session invalidated for user %s, closing connection [%s]", client.Session.Session.UserID, client.requestID)
 				client.cleanup()
 				return
 			}
+			client.warnExpiry(state, time.Now())
 		}
 	}
 }
 
+// warnExpiry queues a "session_expiring" event once the session is within
+// expiryWarning of ending. Renewal moves ExpiresAt, so the client is warned
+// again when the new deadline draws near.
+func (client *EventsClient) warnExpiry(state SessionState, now time.Time) {
+	if client.expiryWarning <= 0 || state.ExpiresAt.IsZero() || state.ExpiresAt.Equal(client.warnedExpiry) {
+		return
+	}
+	if state.ExpiresAt.Sub(now) > client.expiryWarning {
+		return
+	}
+	client.warnedExpiry = state.ExpiresAt
+	client.msgQueue.Enqueue(&comms.Event{
+		Type:      EVENT_TYPE_SESSION_EXPIRING,
+		Data:      SessionExpiring{ExpiresAt: state.ExpiresAt.Unix(), Reason: state.Reason},
+		ExpiresAt: state.ExpiresAt,
+	})
+}
+
 func (client *EventsClient) cleanup() {
 	if !client.ended.CompareAndSwap(false, true) {
 		return
 	}
 	utils.SafeCloseChannel(client.done)
+	client.releaseDone()
 	utils.SafeClose(client.msgQueue)
 	client.manager.clients.Delete(client.Session)
 
@@ -95,7 +180,19 @@ func (client *EventsClient) cleanup() {
 	client.cancelMu.Unlock()
 }
 
+// Done is closed when the client ends: unregistered, its session revoked
+// or evicted for falling behind.
+func (client *EventsClient) Done() <-chan struct{} {
+	return client.done
+}
+
+// Stopped is closed once the client has stopped writing to its stream.
+func (client *EventsClient) Stopped() <-chan struct{} {
+	return client.stopped
+}
+
 func (client *EventsClient) ReadMsgQueue() {
+	defer close(client.stopped)
 	defer client.cleanup()
 
 	eventID := 0
@@ -114,10 +211,64 @@ func (client *EventsClient) ReadMsgQueue() {
 			shared.DebugError(fmt.Errorf("received nil event from queue for client %v", client.Session))
 			continue
 		}
+		// A slow client can fall behind; events with a TTL that went stale
+		// in the queue are not worth sending.
+		if event.Expired(time.Now()) {
+			continue
+		}
+		client.checkLag()
+
+		if client.batchWindow <= 0 {
+			eventID++
+			client.sendSSEEvent(event.Type, event.Data, fmt.Sprintf("%d", eventID))
+			continue
+		}
 
-		eventID++
-		client.sendSSEEvent(event.Type, event.Data, fmt.Sprintf("%d", eventID))
+		batch := client.collectBatch(event)
+		envelopes := make([]SentEvent, 0, len(batch))
+		for _, e := range batch {
+			dataJSON, err := json.Marshal(e.Data)
+			if err != nil {
+				shared.DebugError(fmt.Errorf("failed to marshal event: %v", err))
+				continue
+			}
+			eventID++
+			envelopes = append(envelopes, SentEvent{Id: fmt.Sprintf("%d", eventID), Type: e.Type, Data: string(dataJSON)})
+		}
+		client.sendSSEEvent(EVENT_TYPE_BATCH, envelopes, fmt.Sprintf("%d", eventID))
+	}
+}
+
+// collectBatch gathers events that arrive within the batch window after
+// first, up to MAX_BATCH_SIZE, so a burst of sensor updates costs one write.
+func (client *EventsClient) collectBatch(first *comms.Event) []*comms.Event {
+	batch := []*comms.Event{first}
+
+	// stop releases the window goroutine when the batch fills before the
+	// timer fires.
+	window, stop := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	timer := time.NewTimer(client.batchWindow)
+	defer timer.Stop()
+	go func() {
+		select {
+		case <-timer.C:
+		case <-client.done:
+		case <-stop:
+		}
+		close(window)
+	}()
+
+	for len(batch) < MAX_BATCH_SIZE && !client.ended.Load() {
+		event, ok := client.msgQueue.Read(true, window)
+		if !ok {
+			break
+		}
+		if event != nil && !event.Expired(time.Now()) {
+			batch = append(batch, event)
+		}
 	}
+	return batch
 }
 
 // sendSSEEvent sends a properly formatted SSE event with optional event ID.
@@ -148,7 +299,9 @@ func (client *EventsClient) sendSSEEvent(eventType string, data interface{}, id
 		return
 	}
 
-	fmt.Fprintf(client.Writer, "data: %s\n\n", envelopeJSON)
+	n, _ := fmt.Fprintf(client.Writer, "data: %s\n\n", envelopeJSON)
+	client.countBytes(n)
+	client.eventsSent.Add(1)
 
 	if flusher, ok := client.Writer.(http.Flusher); ok {
 		flusher.Flush()
@@ -164,11 +317,11 @@ func (client *EventsClient) SubscribeToEvent(eventType string) {
 		return
 	}
 
-	cancel, err := client.manager.bus.SubscribeEvent(eventType, func(et string, data any) {
+	cancel, err := comms.SubscribeExpiring(client.manager.bus, eventType, func(event comms.Event) {
 		if client.ended.Load() {
 			return
 		}
-		client.msgQueue.Enqueue(&comms.Event{Type: et, Data: data})
+		client.enqueue(&event)
 	})
 	if err != nil {
 		shared.DebugError(fmt.Errorf("failed to subscribe to event %s: %v", eventType, err))
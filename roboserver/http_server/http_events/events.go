@@ -20,8 +20,8 @@ func NewEventsManager(bus comms.Bus) *EventsManager_t {
 
 // RegisterClient registers a new SSE client with the EventsManager.
 // The validator function is called periodically to check if the session is still valid.
-func (em *EventsManager_t) RegisterClient(sess *EventSession, w http.ResponseWriter, validator SessionValidator) *EventsClient {
-	client := NewEventsClient(sess, w, em, validator)
+func (em *EventsManager_t) RegisterClient(sess *EventSession, w http.ResponseWriter, validator SessionValidator, opts ClientOptions) *EventsClient {
+	client := NewEventsClient(sess, w, em, validator, opts)
 	oldClient, exists := em.clients.Pop(*sess)
 	if exists {
 		oldClient.cleanup() // Clean up old client resources
@@ -40,6 +40,17 @@ func (em *EventsManager_t) UnregisterClient(sess *EventSession) {
 	client.cleanup() // Clean up the client resources
 }
 
+// Stats returns the counters of every connected client.
+func (em *EventsManager_t) Stats() []ClientStats {
+	stats := []ClientStats{}
+	for _, sess := range em.clients.GetKeys() {
+		if client, ok := em.clients.Get(sess); ok && !client.ended.Load() {
+			stats = append(stats, client.Stats())
+		}
+	}
+	return stats
+}
+
 func (em *EventsManager_t) GetClient(sess *EventSession) (*EventsClient, bool) {
 	client, exists := em.clients.Get(*sess)
 	if !exists || client.ended.Load() {
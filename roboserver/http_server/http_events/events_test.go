@@ -1,9 +1,17 @@
 package http_events
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"roboserver/comms"
 	"roboserver/shared"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestSentEventSerialization(t *testing.T) {
@@ -114,3 +122,220 @@ func TestEventStruct(t *testing.T) {
 		t.Errorf("Expected 2 event types, got %d", len(decoded.EventTypes))
 	}
 }
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, gzip":     true,
+		"gzip;q=0":          false,
+		"br, gzip; q=0.5":   true,
+		"identity, deflate": false,
+	}
+	for header, want := range cases {
+		req := httptest.NewRequest("GET", "/events", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if got := AcceptsGzip(req); got != want {
+			t.Errorf("AcceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestGzipResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gw := NewGzipResponseWriter(rec)
+	fmt.Fprintf(gw, "data: hello\n\n")
+	gw.Flush()
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Expected Content-Encoding gzip")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	buf := make([]byte, 64)
+	n, _ := zr.Read(buf)
+	if string(buf[:n]) != "data: hello\n\n" {
+		t.Errorf("Expected flushed event, got %q", buf[:n])
+	}
+}
+
+func TestGzipResponseWriterClose(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gw := NewGzipResponseWriter(rec)
+	fmt.Fprintf(gw, "data: bye\n\n")
+	gw.Flush()
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to open gzip stream: %v", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil || string(body) != "data: bye\n\n" {
+		t.Errorf("Expected a complete gzip stream, got %q (%v)", body, err)
+	}
+}
+
+func TestCollectBatch(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	client := NewEventsClient(sess, httptest.NewRecorder(), em, nil, ClientOptions{BatchWindow: 50 * time.Millisecond})
+	defer client.cleanup()
+
+	client.msgQueue.Enqueue(&comms.Event{Type: "b", Data: 2})
+	client.msgQueue.Enqueue(&comms.Event{Type: "c", Data: 3})
+
+	batch := client.collectBatch(&comms.Event{Type: "a", Data: 1})
+	if len(batch) != 3 {
+		t.Fatalf("Expected 3 events in batch, got %d", len(batch))
+	}
+	if batch[0].Type != "a" || batch[2].Type != "c" {
+		t.Errorf("Expected events in arrival order, got %v", batch)
+	}
+}
+
+func TestCollectBatchFullReleasesWindow(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	client := NewEventsClient(sess, httptest.NewRecorder(), em, nil, ClientOptions{BatchWindow: time.Minute})
+	defer client.cleanup()
+
+	before := runtime.NumGoroutine()
+	for i := 1; i < MAX_BATCH_SIZE; i++ {
+		client.msgQueue.Enqueue(&comms.Event{Type: "t", Data: i})
+	}
+	if batch := client.collectBatch(&comms.Event{Type: "t", Data: 0}); len(batch) != MAX_BATCH_SIZE {
+		t.Fatalf("Expected a full batch of %d, got %d", MAX_BATCH_SIZE, len(batch))
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected the window goroutine to exit with a full batch, have %d goroutines (was %d)", n, before)
+	}
+}
+
+func TestCollectBatchSkipsExpired(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	client := NewEventsClient(sess, httptest.NewRecorder(), em, nil, ClientOptions{BatchWindow: 50 * time.Millisecond})
+	defer client.cleanup()
+
+	client.msgQueue.Enqueue(&comms.Event{Type: "door.prompt", Data: 2, ExpiresAt: time.Now().Add(-time.Second)})
+	client.msgQueue.Enqueue(&comms.Event{Type: "c", Data: 3, ExpiresAt: time.Now().Add(time.Minute)})
+
+	batch := client.collectBatch(&comms.Event{Type: "a", Data: 1})
+	if len(batch) != 2 || batch[1].Type != "c" {
+		t.Errorf("Expected the expired event left out of the batch, got %v", batch)
+	}
+}
+
+func TestBatchWindowClamped(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	client := NewEventsClient(sess, httptest.NewRecorder(), em, nil, ClientOptions{BatchWindow: time.Hour})
+	defer client.cleanup()
+
+	if client.batchWindow != MAX_BATCH_WINDOW {
+		t.Errorf("Expected batch window clamped to %v, got %v", MAX_BATCH_WINDOW, client.batchWindow)
+	}
+}
+
+func TestLaggingClientDropsLowPriorityEvents(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	rec := httptest.NewRecorder()
+	client := NewEventsClient(sess, rec, em, nil, ClientOptions{Backpressure: Backpressure{
+		LagQueue:    2,
+		EvictQueue:  10,
+		LowPriority: []string{"robot.*.heartbeat"},
+	}})
+	defer client.cleanup()
+
+	for i := 0; i < 2; i++ {
+		client.enqueue(&comms.Event{Type: "robot.r1.heartbeat"})
+	}
+	client.enqueue(&comms.Event{Type: "robot.r1.heartbeat"})
+	client.enqueue(&comms.Event{Type: "robot.r1.status"})
+	if stats := client.Stats(); !stats.Lagging || stats.Dropped != 1 || stats.Queued != 3 {
+		t.Fatalf("Expected the heartbeat dropped and the status queued while lagging, got %+v", stats)
+	}
+
+	client.checkLag()
+	var sent SentEvent
+	json.Unmarshal(bytes.TrimSuffix(bytes.TrimPrefix(rec.Body.Bytes(), []byte("data: ")), []byte("\n\n")), &sent)
+	var notice LagNotice
+	json.Unmarshal([]byte(sent.Data), &notice)
+	if sent.Type != EVENT_TYPE_LAGGING || !notice.Lagging || notice.Dropped != 1 {
+		t.Errorf("Expected a lagging notice, got %+v", sent)
+	}
+	if client.Stats().BytesSent != int64(rec.Body.Len()) {
+		t.Errorf("Expected %d bytes counted, got %d", rec.Body.Len(), client.Stats().BytesSent)
+	}
+
+	// Caught up: the next check announces it.
+	for client.msgQueue.Size() > 0 {
+		client.msgQueue.Dequeue()
+	}
+	rec.Body.Reset()
+	client.checkLag()
+	json.Unmarshal(bytes.TrimSuffix(bytes.TrimPrefix(rec.Body.Bytes(), []byte("data: ")), []byte("\n\n")), &sent)
+	json.Unmarshal([]byte(sent.Data), &notice)
+	if sent.Type != EVENT_TYPE_LAGGING || notice.Lagging {
+		t.Errorf("Expected a caught-up notice, got %+v", sent)
+	}
+}
+
+func TestClientEvictedWhenQueueFull(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	client := NewEventsClient(sess, httptest.NewRecorder(), em, nil, ClientOptions{Backpressure: Backpressure{EvictQueue: 3}})
+	em.clients.Set(*sess, client)
+
+	for i := 0; i < 4; i++ {
+		client.enqueue(&comms.Event{Type: "robot.r1.status"})
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("Expected the client to be evicted")
+	}
+	if _, ok := em.GetClient(sess); ok {
+		t.Error("Expected the evicted client to be unregistered")
+	}
+}
+
+func TestSessionExpiringWarning(t *testing.T) {
+	em := NewEventsManager(nil)
+	sess := NewEventSession(&shared.Session{UserID: "admin"})
+	client := NewEventsClient(sess, httptest.NewRecorder(), em, nil, ClientOptions{ExpiryWarning: 5 * time.Minute})
+	defer client.cleanup()
+
+	now := time.Now()
+	client.warnExpiry(SessionState{Valid: true, ExpiresAt: now.Add(time.Hour), Reason: "absolute"}, now)
+	if client.msgQueue.Size() != 0 {
+		t.Fatal("Expected no warning an hour before expiry")
+	}
+
+	deadline := now.Add(3 * time.Minute)
+	client.warnExpiry(SessionState{Valid: true, ExpiresAt: deadline, Reason: "idle"}, now)
+	client.warnExpiry(SessionState{Valid: true, ExpiresAt: deadline, Reason: "idle"}, now.Add(time.Minute))
+	if client.msgQueue.Size() != 1 {
+		t.Fatalf("Expected one warning per deadline, got %d", client.msgQueue.Size())
+	}
+	event, _ := client.msgQueue.Dequeue()
+	warning, ok := event.Data.(SessionExpiring)
+	if event.Type != EVENT_TYPE_SESSION_EXPIRING || !ok || warning.ExpiresAt != deadline.Unix() || warning.Reason != "idle" {
+		t.Errorf("Unexpected warning %+v", event)
+	}
+
+	// Renewed, then close to the new deadline again
+	client.warnExpiry(SessionState{Valid: true, ExpiresAt: deadline.Add(time.Minute), Reason: "idle"}, now.Add(2*time.Minute))
+	if client.msgQueue.Size() != 1 {
+		t.Error("Expected a new warning for the renewed deadline")
+	}
+}
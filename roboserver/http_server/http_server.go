@@ -9,10 +9,15 @@ import (
 	"roboserver/database"
 	"roboserver/http_server/http_events"
 	"roboserver/http_server/http_websocket"
+	"roboserver/presence"
 	"roboserver/shared"
+	"roboserver/shared/requestid"
+	"roboserver/shared/tracing"
+	"slices"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type HTTPServer_t struct {
@@ -23,6 +28,11 @@ type HTTPServer_t struct {
 	srv        *http.Server
 	sseManager *http_events.EventsManager_t
 	wsManager  *http_websocket.Manager
+	presence   *presence.Tracker_t
+	robotList  robotListCache
+
+	robotRecords robotRecordCache
+	exports      exportStore
 }
 
 func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
@@ -41,11 +51,19 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 		srv:        srv,
 		sseManager: http_events.NewEventsManager(bus),
 		wsManager:  http_websocket.NewManager(bus),
+		presence:   presence.Default,
+	}
+
+	if err := s.watchRecordChanges(ctx); err != nil {
+		shared.DebugPrint("Robot record cache invalidation unavailable: %v", err)
 	}
 
 	serverErr := make(chan error, 1)
 	go func() {
 		// Global middleware
+		s.router.Use(s.RequestIDMiddleware)
+		s.router.Use(s.AccessLogMiddleware)
+		s.router.Use(s.TracingMiddleware)
 		s.router.Use(s.LoggingMiddleware)
 		s.router.Use(s.CORSMiddleware)
 		s.router.Use(s.BodySizeLimitMiddleware)
@@ -58,17 +76,28 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 		// Semi-public: SSE GET accepts tickets (handles its own auth)
 		s.router.Get("/events", s.eventsHandler)
 		s.router.Get("/handler/{uuid}/logs", s.streamHandlerLogs) // ticket-based auth
+		s.router.Get("/robot/{uuid}/events", s.streamRobotEvents) // ticket-based auth
 
 		// Protected routes
 		s.router.Group(func(r chi.Router) {
 			r.Use(s.SessionValidationMiddleware)
 			r.Route("/robot", s.RobotRoutes)
+			r.Get("/events/types", s.getEventTypes)
 			r.Post("/events/subscribe", s.eventsSubscribeHandler)
 			r.Post("/events/unsubscribe", s.eventsUnsubscribeHandler)
 			r.Route("/provision", s.ProvisionRoutes)
 			r.Route("/ephemeral", s.EphemeralRoutes)
 			r.Route("/register", s.RegisterRoutes)
 			r.Route("/handler", s.HandlerRoutes)
+			r.Route("/presence", s.PresenceRoutes)
+			r.Route("/admin", s.AdminRoutes)
+			r.Route("/macro", s.MacroRoutes)
+			r.Route("/jobs", s.JobRoutes)
+			r.Route("/push", s.PushRoutes)
+			r.Post("/ingest", s.ingestReadings)
+			r.Get("/energy", s.getFleetEnergy)
+			r.Get("/zones", s.getZones)
+			r.Get("/zones/{zone}", s.getZone)
 			r.Get("/ws", s.wsHandler)
 		})
 
@@ -114,8 +143,23 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 }
 
 // wsHandler upgrades to WebSocket for bidirectional communication (event streaming, commands).
+// The robot ACL is checked for every robot the client subscribes to or sends to.
 func (s *HTTPServer_t) wsHandler(w http.ResponseWriter, r *http.Request) {
-	s.wsManager.HandleConnection(w, r)
+	rds := s.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	user := s.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.wsManager.HandleConnection(w, r, func(uuid string) bool {
+		// The request's context ends once the connection is upgraded.
+		ok, err := rds.CanAccessRobot(s.ctx, user, uuid)
+		return err == nil && ok
+	})
 }
 
 // SessionValidationMiddleware validates session for protected routes.
@@ -128,42 +172,73 @@ func (s *HTTPServer_t) SessionValidationMiddleware(next http.Handler) http.Handl
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withSession(r, session))
 	})
 }
 
 // LoggingMiddleware logs all requests
 func (s *HTTPServer_t) LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		shared.DebugPrint("%s %s from %s", r.Method, r.URL.Path, shared.RedactIP(r.RemoteAddr))
+		shared.DebugPrint("%s %s from %s [%s]", r.Method, r.URL.Path, shared.RedactIP(r.RemoteAddr), requestid.From(r.Context()))
 		next.ServeHTTP(w, r)
 	})
 }
 
-// BodySizeLimitMiddleware caps request bodies to prevent memory exhaustion
-// from oversized payloads. Applied globally; individual handlers can set
-// tighter limits as needed.
+// TracingMiddleware records a server span per request, continuing the
+// caller's trace when it sends a traceparent header. The span is named by
+// the matched route ("POST /robot/{uuid}/message") once routing is done.
+func (s *HTTPServer_t) TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tracing.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := tracing.Extract(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := tracing.StartSpan(ctx, tracing.KindServer, r.Method)
+		defer span.End()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttr("http.request.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("http.request.id", requestid.From(r.Context()))
+		span.SetAttr("http.response.status_code", status)
+		if status >= 500 {
+			span.SetFailed(http.StatusText(status))
+		}
+	})
+}
+
+// BodySizeLimitMiddleware caps request bodies at limits.http_body to prevent
+// memory exhaustion from oversized payloads. Applied globally; individual
+// handlers can set tighter limits as needed.
 func (s *HTTPServer_t) BodySizeLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		r.Body = http.MaxBytesReader(w, r.Body, int64(shared.AppConfig.Limits.HTTPBodyBytes()))
 		next.ServeHTTP(w, r)
 	})
 }
 
 // CORSMiddleware handles Cross-Origin Resource Sharing with origin whitelist.
+// The whitelist is read per request so a config reload takes effect at once.
 func (s *HTTPServer_t) CORSMiddleware(next http.Handler) http.Handler {
-	allowed := make(map[string]bool, len(shared.AppConfig.Server.AllowedOrigins))
-	for _, o := range shared.AppConfig.Server.AllowedOrigins {
-		allowed[o] = true
-	}
-
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 
-		if origin != "" && allowed[origin] {
+		if origin != "" && slices.Contains(shared.AllowedOrigins(), origin) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Request-ID, traceparent")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Max-Age", "86400")
 		}
@@ -1,11 +1,14 @@
 package http_websocket
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"roboserver/comms"
 	"roboserver/handler_engine"
 	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/tracked"
 	"sync"
 	"time"
 
@@ -17,7 +20,7 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
-		for _, allowed := range shared.AppConfig.Server.AllowedOrigins {
+		for _, allowed := range shared.AllowedOrigins() {
 			if origin == allowed {
 				return true
 			}
@@ -42,13 +45,20 @@ type OutgoingMessage struct {
 	Error string `json:"error,omitempty"`
 }
 
+// AccessFunc reports whether the connection's user may see or control the
+// robot uuid.
+type AccessFunc func(uuid string) bool
+
 // WSClient manages a single WebSocket connection.
 type WSClient struct {
-	conn    *websocket.Conn
-	bus     comms.Bus
-	send    chan []byte
-	done    chan struct{}
-	closeMu sync.Once
+	conn      *websocket.Conn
+	bus       comms.Bus
+	canAccess AccessFunc
+	send      chan []byte
+	done      chan struct{}
+	closeMu   sync.Once
+
+	releaseDone func() // ends done's count in shared/tracked
 
 	cancelMu    sync.Mutex
 	cancelFuncs map[string]func()
@@ -72,7 +82,9 @@ const (
 )
 
 // HandleConnection upgrades an HTTP request to a WebSocket connection.
-func (m *Manager) HandleConnection(w http.ResponseWriter, r *http.Request) {
+// canAccess guards every robot the client names: robot- and handler-scoped
+// subscriptions, send_to_robot and send_to_handler.
+func (m *Manager) HandleConnection(w http.ResponseWriter, r *http.Request, canAccess AccessFunc) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		shared.DebugPrint("WebSocket upgrade failed: %v", err)
@@ -82,20 +94,23 @@ func (m *Manager) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	client := &WSClient{
 		conn:        conn,
 		bus:         m.bus,
+		canAccess:   canAccess,
 		send:        make(chan []byte, 256),
 		done:        make(chan struct{}),
+		releaseDone: tracked.Open("websocket.done"),
 		cancelFuncs: make(map[string]func()),
 	}
 
 	m.clients.Store(client, true)
 
-	go client.writePump()
-	go client.readPump(m)
+	tracked.Go("websocket", client.writePump)
+	tracked.Go("websocket", func() { client.readPump(m) })
 }
 
 func (c *WSClient) close(m *Manager) {
 	c.closeMu.Do(func() {
 		close(c.done)
+		c.releaseDone()
 		c.conn.Close()
 		if m != nil {
 			m.clients.Delete(c)
@@ -186,6 +201,10 @@ func (c *WSClient) subscribe(eventType string) {
 		c.sendError("event type required")
 		return
 	}
+	if uuid, ok := events.RobotOf(eventType); ok && !c.canAccess(uuid) {
+		c.sendError("forbidden: " + eventType)
+		return
+	}
 
 	cancel, err := c.bus.SubscribeEvent(eventType, func(et string, data any) {
 		c.sendEvent(et, data)
@@ -223,6 +242,10 @@ func (c *WSClient) sendToRobot(uuid string, data json.RawMessage) {
 		c.sendError("uuid required")
 		return
 	}
+	if !c.canAccess(uuid) {
+		c.sendError("forbidden: robot " + uuid)
+		return
+	}
 
 	hp, ok := handler_engine.HandlerManager.Get(uuid)
 	if !ok {
@@ -230,7 +253,9 @@ func (c *WSClient) sendToRobot(uuid string, data json.RawMessage) {
 		return
 	}
 
-	if err := hp.SendToRobot(data); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), handler_engine.DefaultSendTimeout)
+	defer cancel()
+	if err := hp.SendToRobotContext(ctx, data); err != nil {
 		c.sendError("failed to send to robot: " + err.Error())
 		return
 	}
@@ -244,6 +269,10 @@ func (c *WSClient) sendToHandler(uuid string, data json.RawMessage) {
 		c.sendError("uuid required")
 		return
 	}
+	if !c.canAccess(uuid) {
+		c.sendError("forbidden: robot " + uuid)
+		return
+	}
 
 	hp, ok := handler_engine.HandlerManager.Get(uuid)
 	if !ok {
@@ -251,7 +280,13 @@ func (c *WSClient) sendToHandler(uuid string, data json.RawMessage) {
 		return
 	}
 
-	hp.SendIncoming(string(data))
+	// Waiting here holds up only this client's read loop
+	ctx, cancel := context.WithTimeout(context.Background(), handler_engine.DefaultSendTimeout)
+	defer cancel()
+	if err := hp.SendIncomingContext(ctx, string(data)); err != nil {
+		c.sendError("failed to send to handler " + uuid + ": " + err.Error())
+		return
+	}
 	c.sendAck("sent to handler " + uuid)
 }
 
@@ -23,12 +23,14 @@ func newTestBus() comms.Bus {
 	return comms.NewLocalBus(eb, nil)
 }
 
+func allowAll(string) bool { return true }
+
 func TestWebSocketManager_Connect(t *testing.T) {
 	bus := newTestBus()
 	manager := NewManager(bus)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		manager.HandleConnection(w, r)
+		manager.HandleConnection(w, r, allowAll)
 	}))
 	defer server.Close()
 
@@ -52,7 +54,7 @@ func TestWebSocketManager_SubscribeAndReceive(t *testing.T) {
 	manager := NewManager(bus)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		manager.HandleConnection(w, r)
+		manager.HandleConnection(w, r, allowAll)
 	}))
 	defer server.Close()
 
@@ -109,7 +111,7 @@ func TestWebSocketManager_InvalidAction(t *testing.T) {
 	manager := NewManager(bus)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		manager.HandleConnection(w, r)
+		manager.HandleConnection(w, r, allowAll)
 	}))
 	defer server.Close()
 
@@ -137,3 +139,42 @@ func TestWebSocketManager_InvalidAction(t *testing.T) {
 		t.Errorf("Expected error response, got %s", out.Type)
 	}
 }
+
+func TestWebSocketManager_RobotAccess(t *testing.T) {
+	manager := NewManager(newTestBus())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		manager.HandleConnection(w, r, func(uuid string) bool { return uuid == "mine" })
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": []string{"http://localhost"}})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	for _, tc := range []struct {
+		msg  IncomingMessage
+		want string
+	}{
+		{IncomingMessage{Action: "subscribe", Event: "robot.other.telemetry"}, "error"},
+		{IncomingMessage{Action: "subscribe", Event: "handler.other.log"}, "error"},
+		{IncomingMessage{Action: "send_to_robot", UUID: "other", Data: json.RawMessage(`{"command":"open"}`)}, "error"},
+		{IncomingMessage{Action: "send_to_handler", UUID: "other", Data: json.RawMessage(`"open"`)}, "error"},
+		{IncomingMessage{Action: "subscribe", Event: "robot.mine.telemetry"}, "ack"},
+	} {
+		data, _ := json.Marshal(tc.msg)
+		conn.WriteMessage(websocket.TextMessage, data)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, resp, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		var out OutgoingMessage
+		json.Unmarshal(resp, &out)
+		if out.Type != tc.want || (tc.want == "error" && !strings.HasPrefix(out.Error, "forbidden")) {
+			t.Errorf("%s %s%s: expected %s, got %+v", tc.msg.Action, tc.msg.Event, tc.msg.UUID, tc.want, out)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package http_server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"roboserver/auth"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/ingest"
+	"time"
+)
+
+// ingestResult is the response of POST /ingest.
+type ingestResult struct {
+	Accepted int               `json:"accepted"` // records ingested
+	Created  []string          `json:"created"`  // virtual robots created by this request
+	Rejected []ingest.Rejected `json:"rejected"`
+}
+
+// ingestReadings takes a gateway's batch of sensor readings. Only API keys
+// may post: the key ID marks the virtual robots the gateway owns. A device
+// seen for the first time becomes a virtual robot (ingest.device_type, no
+// handler of its own unless one is running) whose session lives for
+// ingest.ttl after its last reading. Each device's readings are grouped
+// into envelopes and sent down the DATA pipeline: a local handler, the
+// telemetry list in Redis and robot.{uuid}.telemetry.
+func (h *HTTPServer_t) ingestReadings(w http.ResponseWriter, r *http.Request) {
+	cfg := &shared.AppConfig.Ingest
+	if !cfg.Enabled {
+		http.Error(w, "Ingestion is disabled", http.StatusNotFound)
+		return
+	}
+	gateway, ok := apiKeyIDFromSession(sessionFromRequest(r))
+	if !ok {
+		http.Error(w, "Forbidden: ingestion requires an API key", http.StatusForbidden)
+		return
+	}
+	var records []ingest.Record
+	if err := parseJSONRequest(r, &records); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if len(records) == 0 {
+		http.Error(w, "No records", http.StatusBadRequest)
+		return
+	}
+	if cfg.MaxRecords > 0 && len(records) > cfg.MaxRecords {
+		http.Error(w, fmt.Sprintf("At most %d records per request", cfg.MaxRecords), http.StatusRequestEntityTooLarge)
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	readings, rejected := ingest.Group(records, time.Now())
+	result := ingestResult{Created: []string{}}
+	ip := shared.CanonicalIP(r.RemoteAddr)
+	devices := make(map[string]error)
+	for _, reading := range readings {
+		uuid := reading.DeviceID
+		if _, seen := devices[uuid]; seen {
+			continue
+		}
+		created, err := h.claimVirtualRobot(r, rds, uuid, gateway, ip)
+		if errors.Is(err, errRegistryUnavailable) {
+			http.Error(w, "Robot registry not available", http.StatusServiceUnavailable)
+			return
+		}
+		devices[uuid] = err
+		if created {
+			result.Created = append(result.Created, uuid)
+		}
+	}
+
+	handlers := &shared.AppConfig.Handlers
+	for _, reading := range readings {
+		uuid := reading.DeviceID
+		if err := devices[uuid]; err != nil {
+			rejected = append(rejected, reading.Reject(err)...)
+			continue
+		}
+
+		if hp, ok := handler_engine.HandlerManager.Get(uuid); ok {
+			hp.SendTelemetry(reading.Envelope)
+		}
+		if err := rds.AddTelemetry(r.Context(), uuid, reading.Envelope, handlers.TelemetryHistoryLen(), handlers.DataExpiry()); err != nil {
+			shared.DebugPrint("Failed to store ingested telemetry for %s: %v", uuid, err)
+		}
+		if h.bus != nil {
+			h.bus.PublishEvent(events.RobotTelemetry(uuid), reading.Envelope)
+		}
+		result.Accepted += len(reading.Records)
+	}
+
+	ingest.SortRejected(rejected)
+	result.Rejected = rejected
+	if result.Rejected == nil {
+		result.Rejected = []ingest.Rejected{}
+	}
+	sendResponseAsJSON(w, result, http.StatusOK)
+}
+
+// errRegistryUnavailable fails a whole ingest request when PostgreSQL can't
+// tell whether a new device ID belongs to a registered robot.
+var errRegistryUnavailable = errors.New("robot registry not available")
+
+// claimVirtualRobot makes sure uuid is a virtual robot reported by gateway,
+// creating it on first sight and otherwise extending its session. It
+// reports whether the robot was created. Creation only succeeds while the
+// device has no session, so two gateways (or the device itself) racing for
+// a new ID can't overwrite each other; the loser gets the ownership check.
+func (h *HTTPServer_t) claimVirtualRobot(r *http.Request, rds *database.RedisHandler, uuid, gateway, ip string) (bool, error) {
+	if !handler_engine.IsValidUUID(uuid) {
+		return false, errors.New("invalid device_id")
+	}
+	cfg := &shared.AppConfig.Ingest
+	if robot, _ := rds.GetActiveRobot(r.Context(), uuid); robot != nil {
+		return false, refreshVirtualRobot(r, rds, uuid, gateway, ip)
+	}
+
+	if pg := h.db.Postgres(); pg != nil {
+		registered, err := pg.GetRobotByUUID(r.Context(), uuid)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, errRegistryUnavailable
+		}
+		if registered != nil {
+			return false, errors.New("device_id belongs to a registered robot")
+		}
+	}
+	if err := auth.CheckBan(r.Context(), rds, uuid, ip); err != nil {
+		return false, err
+	}
+	robot := &database.ActiveRobot{
+		UUID:        uuid,
+		IP:          ip,
+		DeviceType:  cfg.DeviceType,
+		ConnectedAt: time.Now().Unix(),
+		Gateway:     gateway,
+	}
+	added, err := rds.AddActiveRobot(r.Context(), robot, cfg.TTLDuration())
+	if err != nil {
+		return false, errors.New("failed to store session")
+	}
+	if !added {
+		return false, refreshVirtualRobot(r, rds, uuid, gateway, ip)
+	}
+	shared.DebugPrint("Gateway %s created virtual robot %s", gateway, uuid)
+	if h.bus != nil {
+		h.bus.PublishEvent(events.RobotVirtualCreated, ingest.VirtualCreated{
+			UUID:       uuid,
+			DeviceType: cfg.DeviceType,
+			Gateway:    gateway,
+			IP:         ip,
+			Time:       robot.ConnectedAt,
+		})
+	}
+	return true, nil
+}
+
+// refreshVirtualRobot extends the session of a virtual robot gateway
+// already owns, and refuses one that is not its own. The ownership check
+// and the write are one transaction, so a session the device or another
+// gateway takes over in between is never overwritten.
+func refreshVirtualRobot(r *http.Request, rds *database.RedisHandler, uuid, gateway, ip string) error {
+	var refused error
+	err := rds.UpdateActiveRobot(r.Context(), uuid, shared.AppConfig.Ingest.TTLDuration(), func(robot *database.ActiveRobot) error {
+		if refused = checkVirtualOwner(robot, gateway); refused != nil {
+			return refused
+		}
+		robot.IP = ip
+		return nil
+	})
+	if refused != nil {
+		return refused
+	}
+	if err != nil {
+		return errors.New("failed to refresh session")
+	}
+	return nil
+}
+
+// checkVirtualOwner refuses a session that gateway did not create.
+func checkVirtualOwner(robot *database.ActiveRobot, gateway string) error {
+	switch {
+	case robot.Gateway == "":
+		return errors.New("device has a session of its own")
+	case robot.Gateway != gateway:
+		return errors.New("device is reported by another gateway")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package http_server
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/database"
+	"roboserver/shared"
+	"strings"
+	"testing"
+)
+
+func TestIngestReadings(t *testing.T) {
+	saved := shared.AppConfig.Ingest
+	defer func() { shared.AppConfig.Ingest = saved }()
+	s := newTestServer(&mockDBManager{})
+	body := `[{"device_id":"t3","metric":"temp_c","value":21.5}]`
+	post := func(session *shared.Session, body string) int {
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader(body))
+		if session != nil {
+			req = withSession(req, session)
+		}
+		rec := httptest.NewRecorder()
+		s.ingestReadings(rec, req)
+		return rec.Code
+	}
+	gateway := &shared.Session{UserID: "gw", SessionID: apiKeySessionPrefix + "0123456789abcdef"}
+
+	shared.AppConfig.Ingest.Enabled = false
+	if code := post(gateway, body); code != http.StatusNotFound {
+		t.Errorf("Disabled: expected 404, got %d", code)
+	}
+
+	shared.AppConfig.Ingest.Enabled = true
+	shared.AppConfig.Ingest.MaxRecords = 1
+	if code := post(&shared.Session{UserID: "alice", SessionID: "s1"}, body); code != http.StatusForbidden {
+		t.Errorf("Login session: expected 403, got %d", code)
+	}
+	if code := post(gateway, `{"device_id":"t3"}`); code != http.StatusBadRequest {
+		t.Errorf("Object body: expected 400, got %d", code)
+	}
+	if code := post(gateway, `[]`); code != http.StatusBadRequest {
+		t.Errorf("Empty batch: expected 400, got %d", code)
+	}
+	if code := post(gateway, `[{"device_id":"t3","metric":"a","value":1},{"device_id":"t3","metric":"b","value":2}]`); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Over max_records: expected 413, got %d", code)
+	}
+	if code := post(gateway, body); code != http.StatusServiceUnavailable {
+		t.Errorf("No Redis: expected 503, got %d", code)
+	}
+}
+
+func TestCheckVirtualOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		robot   *database.ActiveRobot
+		wantErr bool
+	}{
+		{"own", &database.ActiveRobot{UUID: "t3", Gateway: "gw"}, false},
+		{"other gateway", &database.ActiveRobot{UUID: "t3", Gateway: "gw2"}, true},
+		{"device session", &database.ActiveRobot{UUID: "t3"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkVirtualOwner(tt.robot, "gw"); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIngestReadings_RegistryUnavailable(t *testing.T) {
+	saved := shared.AppConfig.Ingest
+	defer func() { shared.AppConfig.Ingest = saved }()
+	shared.AppConfig.Ingest.Enabled = true
+	shared.AppConfig.Ingest.MaxRecords = 0
+
+	db, err := sql.Open("postgres", "postgres://nobody@127.0.0.1:1/none?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := newTestServer(&mockDBManager{pg: &database.PostgresHandler{DB: db}, rds: unreachableRedis(t)})
+
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader(`[{"device_id":"t3","metric":"temp_c","value":21.5}]`))
+	req = withSession(req, &shared.Session{UserID: "gw", SessionID: apiKeySessionPrefix + "0123456789abcdef"})
+	rec := httptest.NewRecorder()
+	s.ingestReadings(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the registry can't be read, got %d (%s)", rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+}
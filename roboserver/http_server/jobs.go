@@ -0,0 +1,135 @@
+package http_server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"roboserver/shared/jobs"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *HTTPServer_t) JobRoutes(r chi.Router) {
+	r.Get("/", h.listJobs)
+	r.Get("/{id}", h.getJob)
+	r.Delete("/{id}", h.cancelJob)
+}
+
+// listJobs returns background jobs on this node, newest first: every job
+// for an admin, otherwise the caller's own. ?limit=N (default 100).
+func (h *HTTPServer_t) listJobs(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	createdBy := user.Username
+	if user.IsAdmin() {
+		createdBy = ""
+	}
+	sendResponseAsJSON(w, jobs.Default.List(createdBy, limit), http.StatusOK)
+}
+
+// getJob returns a job's status, progress and, once finished, its result.
+func (h *HTTPServer_t) getJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.visibleJob(r, chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, job, http.StatusOK)
+}
+
+// cancelJob cancels a queued or running job. A running job reports
+// "cancelled" once it has stopped.
+func (h *HTTPServer_t) cancelJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := h.visibleJob(r, id); !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	job, err := jobs.Default.Cancel(id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		http.Error(w, "Job not found", http.StatusNotFound)
+	case errors.Is(err, jobs.ErrFinished):
+		http.Error(w, "Job has already finished", http.StatusConflict)
+	default:
+		sendResponseAsJSON(w, job, http.StatusOK)
+	}
+}
+
+// visibleJob looks up a job the caller may see: an admin sees every job,
+// anyone else only their own.
+func (h *HTTPServer_t) visibleJob(r *http.Request, id string) (jobs.Job, bool) {
+	job, ok := jobs.Default.Get(id)
+	if !ok {
+		return jobs.Job{}, false
+	}
+	user := h.currentUser(r)
+	if user == nil || (!user.IsAdmin() && job.CreatedBy != user.Username) {
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// postTelemetryPurge deletes stored DATA envelopes as a background job
+// (admin only) and responds 202 with the job.
+// Body (optional): {"uuids": ["..."]}; without uuids every robot's
+// telemetry is purged.
+func (h *HTTPServer_t) postTelemetryPurge(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	var body struct {
+		UUIDs []string `json:"uuids"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil && !errors.Is(err, io.EOF) {
+		sendBodyError(w, err)
+		return
+	}
+
+	job, err := jobs.Default.Submit("telemetry_purge", h.currentUser(r).Username, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		uuids := body.UUIDs
+		if len(uuids) == 0 {
+			var err error
+			if uuids, err = rds.GetTelemetryRobots(ctx); err != nil {
+				return nil, err
+			}
+		}
+		p.SetTotal(len(uuids))
+		purged := 0
+		for _, uuid := range uuids {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := rds.DeleteTelemetry(ctx, uuid); err != nil {
+				return map[string]int{"purged": purged}, err
+			}
+			purged++
+			p.Add(1)
+		}
+		return map[string]int{"purged": purged}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	sendResponseAsJSON(w, job, http.StatusAccepted)
+}
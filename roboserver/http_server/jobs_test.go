@@ -0,0 +1,39 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared/jobs"
+	"testing"
+)
+
+func TestGetJob_HiddenWithoutUser(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	job, err := jobs.Default.Submit("test", "alice", func(context.Context, *jobs.Progress) (any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	defer jobs.Default.Cancel(job.ID)
+
+	for _, id := range []string{job.ID, "missing"} {
+		req := addChiURLParam(httptest.NewRequest("GET", "/jobs/"+id, nil), "id", id)
+		rec := httptest.NewRecorder()
+		s.getJob(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", id, rec.Code)
+		}
+	}
+}
+
+func TestTelemetryPurge_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("POST", "/admin/telemetry/purge", nil)
+	rec := httptest.NewRecorder()
+	s.postTelemetryPurge(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package http_server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBanDuration caps the cooldown a disconnect can ban a robot for.
+const maxBanDuration = 30 * 24 * time.Hour
+
+// postRobotDisconnect force-disconnects a robot (admin only), optionally
+// banning its ID, and with ban_ip its address, from reconnecting for a
+// while.
+// Body (optional): {"reason": "misbehaving", "ban": "10m", "ban_ip": true}
+func (h *HTTPServer_t) postRobotDisconnect(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+		Ban    string `json:"ban"`
+		BanIP  bool   `json:"ban_ip"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil && !errors.Is(err, io.EOF) {
+		sendBodyError(w, err)
+		return
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if len(reason) > maxMaintenanceReasonLength {
+		http.Error(w, "reason is too long (max 256 characters)", http.StatusBadRequest)
+		return
+	}
+	var ban time.Duration
+	if body.Ban != "" {
+		d, err := time.ParseDuration(body.Ban)
+		if err != nil || d <= 0 || d > maxBanDuration {
+			http.Error(w, "ban must be a positive duration such as \"10m\" (max 720h)", http.StatusBadRequest)
+			return
+		}
+		ban = d
+	}
+	if body.BanIP && ban == 0 {
+		http.Error(w, "ban_ip requires ban", http.StatusBadRequest)
+		return
+	}
+
+	uuid := chi.URLParam(r, "uuid")
+	bans, err := handler_engine.Kick(r.Context(), h.bus, rds, uuid, handler_engine.KickOptions{
+		Reason: reason,
+		By:     h.currentUser(r).Username,
+		Ban:    ban,
+		BanIP:  body.BanIP,
+	})
+	if errors.Is(err, handler_engine.ErrNotConnected) {
+		http.Error(w, "Robot is not connected", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to disconnect robot", http.StatusInternalServerError)
+		return
+	}
+	if bans == nil {
+		bans = []*database.Ban{}
+	}
+	sendResponseAsJSON(w, map[string]interface{}{
+		"status": "disconnected",
+		"uuid":   uuid,
+		"bans":   bans,
+	}, http.StatusOK)
+}
+
+// getBans lists the device bans in force (admin only).
+func (h *HTTPServer_t) getBans(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	bans, err := rds.ListBans(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list bans", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, bans, http.StatusOK)
+}
+
+// deleteBan lifts a ban early (admin only). kind is "uuid" or "ip".
+func (h *HTTPServer_t) deleteBan(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	kind := chi.URLParam(r, "kind")
+	if kind != database.BanUUID && kind != database.BanIP {
+		http.Error(w, "kind must be uuid or ip", http.StatusBadRequest)
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	found, err := rds.RemoveBan(r.Context(), kind, chi.URLParam(r, "value"))
+	if err != nil {
+		http.Error(w, "Failed to remove ban", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Ban not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,22 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKickRoutes_RequireAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	for name, handler := range map[string]http.HandlerFunc{
+		"disconnect": s.postRobotDisconnect,
+		"list bans":  s.getBans,
+		"lift ban":   s.deleteBan,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/robot/r1/disconnect", nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package http_server
+
+import (
+	"io"
+	"net/http"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/macro"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MacroRoutes manages the shared macro store. Anyone signed in can list
+// macros; changing them is admin only.
+func (h *HTTPServer_t) MacroRoutes(r chi.Router) {
+	r.Get("/", h.listMacros)
+	r.Get("/{name}", h.getMacro)
+	r.Put("/{name}", h.putMacro)
+	r.Delete("/{name}", h.deleteMacro)
+}
+
+func (h *HTTPServer_t) listMacros(w http.ResponseWriter, r *http.Request) {
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	macros, err := rds.ListMacros(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list macros", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, macros, http.StatusOK)
+}
+
+func (h *HTTPServer_t) getMacro(w http.ResponseWriter, r *http.Request) {
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	m, err := rds.GetMacro(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, "Failed to get macro", http.StatusInternalServerError)
+		return
+	}
+	if m == nil {
+		http.Error(w, "Macro not found", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, m, http.StatusOK)
+}
+
+// putMacro creates or replaces a macro. Admin only.
+// Body: {"template": "{\"command\": \"set_interval\", \"seconds\": {seconds}}", "description": "..."}
+func (h *HTTPServer_t) putMacro(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		Template    string `json:"template"`
+		Description string `json:"description"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	m, err := macro.New(chi.URLParam(r, "name"), body.Template, body.Description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	if err := rds.SetMacro(r.Context(), m); err != nil {
+		shared.DebugPrint("Failed to store macro %s: %v", m.Name, err)
+		http.Error(w, "Failed to store macro", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, m, http.StatusOK)
+}
+
+// deleteMacro removes a macro. Admin only.
+func (h *HTTPServer_t) deleteMacro(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	existed, err := rds.DeleteMacro(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, "Failed to delete macro", http.StatusInternalServerError)
+		return
+	}
+	if !existed {
+		http.Error(w, "Macro not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runRobotMacro renders a macro and sends it to the robot's handler exactly
+// as POST /robot/{uuid}/message would.
+// Body (optional when the macro has no params): {"params": {"seconds": 30}}
+func (h *HTTPServer_t) runRobotMacro(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+
+	var body struct {
+		Params map[string]any `json:"params"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil && err != io.EOF {
+		sendBodyError(w, err)
+		return
+	}
+
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	m, err := rds.GetMacro(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, "Failed to get macro", http.StatusInternalServerError)
+		return
+	}
+	if m == nil {
+		http.Error(w, "Macro not found", http.StatusNotFound)
+		return
+	}
+	message, err := m.Render(body.Params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hp, ok := handler_engine.HandlerManager.Get(uuid)
+	if !ok {
+		if !h.forwardToRemoteHandler(w, r, uuid, message, "", 0) {
+			http.Error(w, "No handler running for this robot", http.StatusNotFound)
+		}
+		return
+	}
+	if err := sendToHandler(r.Context(), hp, message, ""); err != nil {
+		sendHandlerError(w, err)
+		return
+	}
+
+	sendResponseAsJSON(w, map[string]string{
+		"status":  "sent",
+		"uuid":    uuid,
+		"macro":   m.Name,
+		"message": message,
+	}, http.StatusOK)
+}
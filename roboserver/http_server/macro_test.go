@@ -0,0 +1,62 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPutMacro_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("PUT", "/macro/ping", strings.NewReader(`{"template": "ping"}`))
+	req = addChiURLParam(req, "name", "ping")
+	rec := httptest.NewRecorder()
+
+	s.putMacro(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+	}
+}
+
+func TestListMacros_NilRedis(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("GET", "/macro", nil)
+	rec := httptest.NewRecorder()
+
+	s.listMacros(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for nil Redis, got %d", rec.Code)
+	}
+}
+
+func TestRunRobotMacro(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"invalid JSON", `{"params": `, http.StatusBadRequest},
+		{"no body, nil Redis", ``, http.StatusServiceUnavailable},
+		{"params, nil Redis", `{"params": {"seconds": 30}}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/robot/r1/macro/set_interval", strings.NewReader(tt.body))
+			req = addChiURLParam(req, "name", "set_interval")
+			rec := httptest.NewRecorder()
+
+			s.runRobotMacro(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package http_server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared/maintenance"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxMaintenanceReasonLength = 256
+
+// postRobotMaintenance puts a robot into maintenance mode (admin only).
+// Body (optional): {"reason": "replacing gripper"}
+func (h *HTTPServer_t) postRobotMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil && !errors.Is(err, io.EOF) {
+		sendBodyError(w, err)
+		return
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if len(reason) > maxMaintenanceReasonLength {
+		http.Error(w, "reason is too long (max 256 characters)", http.StatusBadRequest)
+		return
+	}
+
+	info := maintenance.Info{
+		UUID:   chi.URLParam(r, "uuid"),
+		Reason: reason,
+		By:     h.currentUser(r).Username,
+		Since:  time.Now().Unix(),
+	}
+	if err := handler_engine.StartMaintenance(r.Context(), h.bus, rds, info); err != nil {
+		http.Error(w, "Failed to start maintenance", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, info, http.StatusOK)
+}
+
+// deleteRobotMaintenance ends a robot's maintenance mode (admin only) and
+// delivers the automated messages queued meanwhile.
+func (h *HTTPServer_t) deleteRobotMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	uuid := chi.URLParam(r, "uuid")
+	delivered, found, err := handler_engine.EndMaintenance(r.Context(), h.bus, rds, uuid)
+	if err != nil {
+		http.Error(w, "Failed to end maintenance", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Robot is not in maintenance", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, map[string]interface{}{
+		"status":    "ended",
+		"uuid":      uuid,
+		"delivered": delivered,
+	}, http.StatusOK)
+}
+
+// holdForMaintenance splits the targets of an automated message into those
+// to send to and those in maintenance, whose message is queued or
+// suppressed per maintenance.suppress.
+func holdForMaintenance(ctx context.Context, rds *database.RedisHandler, uuids []string, msg string) (send []string, held map[string]quickActionResult) {
+	held = make(map[string]quickActionResult)
+	for _, uuid := range uuids {
+		if status := handler_engine.HoldAutomated(ctx, rds, uuid, msg); status != "" {
+			held[uuid] = quickActionResult{Status: status, Error: "Robot is in maintenance"}
+			continue
+		}
+		send = append(send, uuid)
+	}
+	return send, held
+}
+
+// withMaintenance returns robot with its maintenance info filled in, copying
+// it only when there is something to add.
+func withMaintenance(robot *database.ActiveRobot) *database.ActiveRobot {
+	info, ok := maintenance.Registry.Get(robot.UUID)
+	if !ok {
+		return robot
+	}
+	annotated := *robot
+	annotated.Maintenance = &info
+	return &annotated
+}
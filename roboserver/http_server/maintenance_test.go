@@ -0,0 +1,57 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/database"
+	"roboserver/shared/maintenance"
+	"testing"
+)
+
+func TestRobotMaintenance_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	for _, method := range []string{"POST", "DELETE"} {
+		req := httptest.NewRequest(method, "/robot/r1/maintenance", nil)
+		rec := httptest.NewRecorder()
+		if method == "POST" {
+			s.postRobotMaintenance(rec, req)
+		} else {
+			s.deleteRobotMaintenance(rec, req)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", method, rec.Code)
+		}
+	}
+}
+
+func TestHoldForMaintenance(t *testing.T) {
+	maintenance.Registry.Set(maintenance.Info{UUID: "held-robot"})
+	defer maintenance.Registry.Clear("held-robot")
+
+	// Without Redis nothing can be queued, so the message is suppressed.
+	send, held := holdForMaintenance(context.Background(), nil, []string{"r1", "held-robot", "r2"}, "status")
+	if len(send) != 2 || send[0] != "r1" || send[1] != "r2" {
+		t.Errorf("Expected r1 and r2 to be sent to, got %v", send)
+	}
+	if res, ok := held["held-robot"]; !ok || res.Status != "suppressed" {
+		t.Errorf("Expected held-robot to be suppressed, got %+v", held)
+	}
+}
+
+func TestWithMaintenance(t *testing.T) {
+	robot := &database.ActiveRobot{UUID: "m1"}
+	if got := withMaintenance(robot); got != robot || got.Maintenance != nil {
+		t.Error("Expected a robot outside maintenance to be returned as is")
+	}
+
+	maintenance.Registry.Set(maintenance.Info{UUID: "m1", Reason: "calibration"})
+	defer maintenance.Registry.Clear("m1")
+	got := withMaintenance(robot)
+	if got.Maintenance == nil || got.Maintenance.Reason != "calibration" {
+		t.Errorf("Expected maintenance info, got %+v", got.Maintenance)
+	}
+	if robot.Maintenance != nil {
+		t.Error("Expected the original robot to be left unchanged")
+	}
+}
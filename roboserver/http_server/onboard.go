@@ -0,0 +1,150 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/auth"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/shadow"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultOnboardTTL is how long an onboarding code waits for its device
+// unless the request says otherwise.
+const defaultOnboardTTL = time.Hour
+
+// onboardStatus is the state of an onboarding code for GET /robot/onboard/{code}.
+type onboardStatus struct {
+	Code   string `json:"code"`
+	Status string `json:"status"` // "waiting" or "claimed"
+	// Pending is set while waiting: the code, its plan and expiry.
+	Pending *database.PairingCode `json:"pending,omitempty"`
+	// Claim is set once a robot registered with the code.
+	Claim *database.OnboardClaim `json:"claim,omitempty"`
+}
+
+// postOnboard starts onboarding a robot: it issues a one-time claim code
+// for a device of the expected type. The robot that registers with
+// "REGISTER <code>" is accepted without an operator and is named, labelled
+// and given its desired state in the same step. Admin only.
+// Body: {"device_type": "lamp", "name": "Dock 2 lamp", "zone": "warehouse-a",
+// "tags": ["floor-2"], "config": {"brightness": 80}, "ttl": "1h"}
+func (h *HTTPServer_t) postOnboard(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		DeviceType string         `json:"device_type"`
+		Name       string         `json:"name"`
+		Zone       string         `json:"zone"`
+		Tags       []string       `json:"tags"`
+		Config     map[string]any `json:"config"`
+		TTL        string         `json:"ttl"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if body.DeviceType == "" {
+		http.Error(w, "device_type is required", http.StatusBadRequest)
+		return
+	}
+	if err := handler_engine.ValidateDeviceType(body.DeviceType); err != nil {
+		sendRegistrationError(w, err)
+		return
+	}
+	plan := &database.OnboardPlan{
+		Name:   strings.TrimSpace(body.Name),
+		Zone:   strings.TrimSpace(body.Zone),
+		Config: body.Config,
+	}
+	if plan.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	for _, tag := range body.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			plan.Tags = append(plan.Tags, tag)
+		}
+	}
+	if len(plan.Config) > 0 {
+		if err := shadow.Validate(plan.Config); err != nil {
+			http.Error(w, "config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ttl := defaultOnboardTTL
+	if body.TTL != "" {
+		d, err := time.ParseDuration(body.TTL)
+		if err != nil || d <= 0 || d > maxPairingTTL {
+			http.Error(w, "ttl must be a duration up to 168h", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	code, err := auth.GeneratePairingCode()
+	if err != nil {
+		http.Error(w, "Failed to generate claim code", http.StatusInternalServerError)
+		return
+	}
+	user := h.currentUser(r)
+	pc := &database.PairingCode{
+		Code:       code,
+		DeviceType: body.DeviceType,
+		CreatedBy:  user.Username,
+		ExpiresAt:  time.Now().Add(ttl).Unix(),
+		Onboard:    plan,
+	}
+	if err := rds.SetPairingCode(r.Context(), pc, ttl); err != nil {
+		http.Error(w, "Failed to store claim code", http.StatusInternalServerError)
+		return
+	}
+
+	shared.DebugPrint("REGISTER: %s started onboarding %q (%s), valid %s", user.Username, plan.Name, body.DeviceType, ttl)
+	sendResponseAsJSON(w, pc, http.StatusCreated)
+}
+
+// getOnboard reports whether a robot has redeemed an onboarding code yet,
+// so a setup wizard can poll it (or watch robot.onboarded). Admin only.
+func (h *HTTPServer_t) getOnboard(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	code := auth.NormalizePairingCode(chi.URLParam(r, "code"))
+
+	claim, err := rds.GetOnboardClaim(r.Context(), code)
+	if err != nil {
+		http.Error(w, "Failed to load onboarding", http.StatusInternalServerError)
+		return
+	}
+	if claim != nil {
+		sendResponseAsJSON(w, onboardStatus{Code: code, Status: "claimed", Claim: claim}, http.StatusOK)
+		return
+	}
+
+	pc, err := rds.GetPairingCode(r.Context(), code)
+	if err != nil {
+		http.Error(w, "Failed to load onboarding", http.StatusInternalServerError)
+		return
+	}
+	if pc == nil || pc.Onboard == nil {
+		http.Error(w, "Onboarding not found or expired", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, onboardStatus{Code: code, Status: "waiting", Pending: pc}, http.StatusOK)
+}
@@ -0,0 +1,26 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOnboardEndpoints_RequireAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	for _, tc := range []struct {
+		method  string
+		handler http.HandlerFunc
+	}{
+		{"POST", s.postOnboard},
+		{"GET", s.getOnboard},
+	} {
+		req := httptest.NewRequest(tc.method, "/robot/onboard", strings.NewReader(`{"device_type": "lamp", "name": "Dock lamp"}`))
+		rec := httptest.NewRecorder()
+		tc.handler(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 without an admin session, got %d", tc.method, rec.Code)
+		}
+	}
+}
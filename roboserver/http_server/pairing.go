@@ -0,0 +1,128 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/auth"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultPairingTTL = 15 * time.Minute
+	maxPairingTTL     = 7 * 24 * time.Hour
+	maxPairingCodes   = 500
+)
+
+// createPairingCodes issues one-time pairing codes. A robot that sends
+// "REGISTER <code>" is accepted without an operator. Admin only.
+// Body: {"count": 10, "device_type": "lamp", "ttl": "1h"} (all optional)
+func (h *HTTPServer_t) createPairingCodes(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var body struct {
+		Count      int    `json:"count"`
+		DeviceType string `json:"device_type"`
+		TTL        string `json:"ttl"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if body.Count == 0 {
+		body.Count = 1
+	}
+	if body.Count < 0 || body.Count > maxPairingCodes {
+		http.Error(w, "count must be between 1 and 500", http.StatusBadRequest)
+		return
+	}
+	if body.DeviceType != "" {
+		if err := handler_engine.ValidateDeviceType(body.DeviceType); err != nil {
+			sendRegistrationError(w, err)
+			return
+		}
+	}
+	ttl := defaultPairingTTL
+	if body.TTL != "" {
+		d, err := time.ParseDuration(body.TTL)
+		if err != nil || d <= 0 || d > maxPairingTTL {
+			http.Error(w, "ttl must be a duration up to 168h", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	user := h.currentUser(r)
+	expiresAt := time.Now().Add(ttl).Unix()
+	codes := make([]*database.PairingCode, 0, body.Count)
+	for len(codes) < body.Count {
+		code, err := auth.GeneratePairingCode()
+		if err != nil {
+			http.Error(w, "Failed to generate pairing code", http.StatusInternalServerError)
+			return
+		}
+		pc := &database.PairingCode{Code: code, DeviceType: body.DeviceType, CreatedBy: user.Username, ExpiresAt: expiresAt}
+		if err := rds.SetPairingCode(r.Context(), pc, ttl); err != nil {
+			http.Error(w, "Failed to store pairing code", http.StatusInternalServerError)
+			return
+		}
+		codes = append(codes, pc)
+	}
+
+	shared.DebugPrint("REGISTER: %s issued %d pairing code(s), valid %s", user.Username, len(codes), ttl)
+	sendResponseAsJSON(w, codes, http.StatusCreated)
+}
+
+// listPairingCodes returns unused pairing codes. Admin only.
+func (h *HTTPServer_t) listPairingCodes(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	codes, err := rds.ListPairingCodes(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list pairing codes", http.StatusInternalServerError)
+		return
+	}
+	if codes == nil {
+		codes = []*database.PairingCode{}
+	}
+	sendResponseAsJSON(w, codes, http.StatusOK)
+}
+
+// revokePairingCode deletes an unused pairing code. Admin only.
+func (h *HTTPServer_t) revokePairingCode(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	code := auth.NormalizePairingCode(chi.URLParam(r, "code"))
+	ok, err := rds.DeletePairingCode(r.Context(), code)
+	if err != nil {
+		http.Error(w, "Failed to revoke pairing code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Pairing code not found", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, map[string]string{"status": "revoked", "code": code}, http.StatusOK)
+}
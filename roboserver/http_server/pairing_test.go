@@ -0,0 +1,31 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPairingCodeEndpoints_RequireAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+	}{
+		{"create", "POST", s.createPairingCodes},
+		{"list", "GET", s.listPairingCodes},
+		{"revoke", "DELETE", s.revokePairingCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/register/pairing", strings.NewReader(`{"count": 1}`))
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package http_server
+
+import (
+	"errors"
+	"net/http"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultPingTimeout = 5 * time.Second
+	maxPingTimeout     = 30 * time.Second
+	defaultPingSamples = 10
+)
+
+// getRobotPing sends a PING over the robot's TCP session now and returns
+// the measured round trip with the robot's latest RTT samples (newest
+// first): {uuid, rtt_ms, node, samples_ms, latency}. ?timeout= (default 5s,
+// max 30s) bounds the wait and ?samples= (default 10) the samples listed.
+// Unlike a quick action, nothing reaches the robot's handler.
+func (h *HTTPServer_t) getRobotPing(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	timeout := defaultPingTimeout
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 || d > maxPingTimeout {
+			http.Error(w, "timeout must be a duration up to 30s", http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+	n := defaultPingSamples
+	if raw := r.URL.Query().Get("samples"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			http.Error(w, "samples must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		n = min(v, database.MaxLatencySamples)
+	}
+
+	rds := h.db.Redis()
+	if rds == nil || h.bus == nil {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if active, err := rds.GetActiveRobot(r.Context(), uuid); err != nil || active == nil {
+		http.Error(w, "Robot is not connected", http.StatusNotFound)
+		return
+	}
+
+	reply, err := comms.Request(r.Context(), h.bus, events.RobotPing(uuid), nil, timeout)
+	switch {
+	case r.Context().Err() != nil:
+		return
+	case errors.Is(err, comms.ErrRequestTimeout):
+		// Only TCP sessions answer PINGs.
+		http.Error(w, "Robot did not answer the PING", http.StatusGatewayTimeout)
+		return
+	case err != nil:
+		http.Error(w, "Failed to ping robot", http.StatusInternalServerError)
+		return
+	}
+	result, ok := events.DecodePingResult(reply)
+	if !ok {
+		http.Error(w, "Failed to ping robot", http.StatusInternalServerError)
+		return
+	}
+
+	samples, err := rds.GetLatencySamples(r.Context(), uuid)
+	if err != nil {
+		shared.DebugPrint("Failed to read latency samples of %s: %v", uuid, err)
+	}
+	recent := make([]float64, 0, min(n, len(samples)))
+	for _, d := range samples[:min(n, len(samples))] {
+		recent = append(recent, float64(d.Microseconds())/1000)
+	}
+
+	sendResponseAsJSON(w, map[string]any{
+		"uuid":       uuid,
+		"rtt_ms":     result.RTTMs,
+		"node":       result.Node,
+		"samples_ms": recent,
+		"latency":    database.SummarizeLatency(samples, shared.AppConfig.Timeouts.LatencyDegradedThreshold()),
+	}, http.StatusOK)
+}
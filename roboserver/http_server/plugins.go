@@ -2,56 +2,46 @@ package http_server
 
 import (
 	"net/http"
-	"os"
 	"path/filepath"
-	"roboserver/shared"
+	"roboserver/handler_engine"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func (h *HTTPServer_t) PluginRoutes(r chi.Router) {
-	// Serve compiled handler frontend assets from handlers/{type}/dist/
-	basePath := shared.AppConfig.Handlers.BasePath
-	absBase, err := filepath.Abs(basePath)
-	if err != nil {
-		shared.DebugPrint("Failed to resolve handlers base path: %v", err)
-		return
-	}
-
-	// List available handler types
+	// List available handler types with a compiled frontend
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		entries, err := os.ReadDir(absBase)
-		if err != nil {
-			http.Error(w, "Failed to list plugins", http.StatusInternalServerError)
-			return
-		}
 		types := make([]string, 0)
-		for _, e := range entries {
-			if e.IsDir() && e.Name() != "_template" {
-				distPath := filepath.Join(absBase, e.Name(), "dist")
-				if _, err := os.Stat(distPath); err == nil {
-					types = append(types, e.Name())
-				}
+		for _, t := range handler_engine.ListRobotTypes() {
+			if t.Plugin {
+				types = append(types, t.Type)
 			}
 		}
 		sendResponseAsJSON(w, types, http.StatusOK)
 	})
 
 	// Serve static assets: /plugins/{type}/{file}
-	// Maps to: handlers/{type}/dist/{file}
+	// Maps to: {base_path or plugin dir}/{type}/dist/{file}
 	r.Get("/{type}/*", func(w http.ResponseWriter, r *http.Request) {
 		robotType := chi.URLParam(r, "type")
+		handlerDir, err := handler_engine.ResolveHandlerDir(robotType)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		distDir := filepath.Join(handlerDir, "dist")
+
 		// Get the wildcard path after /{type}/
 		filePath := chi.URLParam(r, "*")
 		if filePath == "" {
 			filePath = "index.js"
 		}
 
-		fullPath := filepath.Join(absBase, robotType, "dist", filePath)
+		fullPath := filepath.Join(distDir, filePath)
 		fullPath = filepath.Clean(fullPath)
 
-		// Security: ensure we're still within the handlers directory
-		if !isSubpath(absBase, fullPath) {
+		// Security: ensure we're still within the type's dist directory
+		if !isSubpath(distDir, fullPath) {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
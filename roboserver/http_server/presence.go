@@ -0,0 +1,70 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/presence"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *HTTPServer_t) PresenceRoutes(r chi.Router) {
+	r.Get("/", h.getPresence)
+	r.Post("/", h.updatePresence)
+}
+
+type PresenceUpdateRequest struct {
+	Subject   string   `json:"subject"` // phone or beacon identifier; defaults to the caller
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// updatePresence accepts a location update and returns any geofence
+// enter/leave transitions it triggered (also published on the event bus).
+// Users report their own presence (subject defaults to their username);
+// only admins may report for other subjects, e.g. a beacon gateway's key.
+func (h *HTTPServer_t) updatePresence(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req PresenceUpdateRequest
+	if err := parseJSONRequest(r, &req); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+
+	subject, ok := presence.SubjectFor(user, req.Subject)
+	if !ok {
+		http.Error(w, "Only admins can report presence for another subject", http.StatusForbidden)
+		return
+	}
+	req.Subject = subject
+	if req.Latitude == nil || req.Longitude == nil {
+		http.Error(w, "latitude and longitude are required", http.StatusBadRequest)
+		return
+	}
+	if !presence.ValidCoordinates(*req.Latitude, *req.Longitude) {
+		http.Error(w, "Coordinates out of range", http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := h.presence.Update(req.Subject, *req.Latitude, *req.Longitude)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	sendResponseAsJSON(w, map[string]interface{}{
+		"subject":     req.Subject,
+		"transitions": transitions,
+	}, http.StatusOK)
+}
+
+// getPresence lists configured geofences and who is currently inside each.
+func (h *HTTPServer_t) getPresence(w http.ResponseWriter, r *http.Request) {
+	sendResponseAsJSON(w, map[string]interface{}{
+		"geofences": h.presence.Geofences(),
+		"occupants": h.presence.Occupants(),
+	}, http.StatusOK)
+}
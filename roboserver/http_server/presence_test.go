@@ -0,0 +1,19 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePresence_RequiresUser(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("POST", "/presence", strings.NewReader(`{"subject": "bob", "latitude": 1, "longitude": 2}`))
+	rec := httptest.NewRecorder()
+	s.updatePresence(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a user, got %d", rec.Code)
+	}
+}
@@ -1,10 +1,17 @@
 package http_server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"roboserver/auth"
+	"roboserver/handler_engine"
 	"roboserver/shared"
+	"roboserver/shared/actuator"
+	"roboserver/shared/events"
+	"roboserver/shared/robot_status"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -15,47 +22,69 @@ func (h *HTTPServer_t) ProvisionRoutes(r chi.Router) {
 	r.Get("/{uuid}", h.getRobotRecord)
 	r.Post("/{uuid}/blacklist", h.blacklistRobot)
 	r.Get("/{uuid}/status", h.getRobotStatus)
+	r.Get("/{uuid}/schema", h.getRobotSchema)
+	r.Put("/{uuid}/schema", h.putRobotSchema)
 }
 
 type ProvisionRequest struct {
-	UUID       string `json:"uuid"`
-	PublicKey  string `json:"public_key"`
-	DeviceType string `json:"device_type"`
+	UUID       string          `json:"uuid"`
+	PublicKey  string          `json:"public_key"`
+	DeviceType string          `json:"device_type"`
+	Schema     json.RawMessage `json:"schema,omitempty"` // generic_actuator command schema
 }
 
 // provisionRobot registers a new robot's public key in PostgreSQL.
 func (h *HTTPServer_t) provisionRobot(w http.ResponseWriter, r *http.Request) {
 	var req ProvisionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := parseJSONRequest(r, &req); err != nil {
+		sendBodyError(w, err)
 		return
 	}
+	h.provision(w, r, req)
+}
 
+// provision validates and stores req, answering 201 when it is provisioned.
+func (h *HTTPServer_t) provision(w http.ResponseWriter, r *http.Request, req ProvisionRequest) bool {
 	if req.UUID == "" || req.PublicKey == "" || req.DeviceType == "" {
 		http.Error(w, "uuid, public_key, and device_type are required", http.StatusBadRequest)
-		return
+		return false
 	}
 
 	if !auth.IsValidPublicKey(req.PublicKey) {
 		http.Error(w, "Invalid public key format", http.StatusBadRequest)
-		return
+		return false
+	}
+
+	if err := handler_engine.ValidateRegistration(req.UUID, req.DeviceType, req.Schema); err != nil {
+		sendRegistrationError(w, err)
+		return false
 	}
 
 	pg := h.db.Postgres()
 	if pg == nil {
 		http.Error(w, "Database not available", http.StatusServiceUnavailable)
-		return
+		return false
 	}
 
 	if err := pg.RegisterRobot(r.Context(), req.UUID, req.PublicKey, req.DeviceType); err != nil {
 		shared.DebugPrint("Failed to provision robot: %v", err)
 		http.Error(w, "Failed to provision robot", http.StatusInternalServerError)
-		return
+		return false
+	}
+	h.recordChanged(events.RecordChange{UUID: req.UUID, Change: "registered"})
+
+	if len(req.Schema) > 0 {
+		if err := pg.SetRobotSchema(r.Context(), req.UUID, req.Schema); err != nil {
+			shared.DebugPrint("Failed to store schema for %s: %v", req.UUID, err)
+			http.Error(w, "Robot provisioned but schema could not be stored", http.StatusInternalServerError)
+			return false
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{"status": "provisioned", "uuid": req.UUID})
+	return true
 }
 
 // getRobotRecord returns the PostgreSQL record for a robot.
@@ -67,7 +96,7 @@ func (h *HTTPServer_t) getRobotRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	robot, err := pg.GetRobotByUUID(r.Context(), uuid)
+	robot, err := h.robotRecord(r.Context(), pg, uuid)
 	if err != nil {
 		http.Error(w, "Robot not found", http.StatusNotFound)
 		return
@@ -83,8 +112,8 @@ func (h *HTTPServer_t) blacklistRobot(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Blacklisted bool `json:"blacklisted"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := parseJSONRequest(r, &req); err != nil {
+		sendBodyError(w, err)
 		return
 	}
 
@@ -98,6 +127,7 @@ func (h *HTTPServer_t) blacklistRobot(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to update blacklist", http.StatusInternalServerError)
 		return
 	}
+	h.recordChanged(events.RecordChange{UUID: uuid, Change: "blacklist", Blacklisted: req.Blacklisted})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"uuid": uuid, "blacklisted": req.Blacklisted})
@@ -131,19 +161,87 @@ func (h *HTTPServer_t) getRobotStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	status := robot_status.Tracker.Get(uuid)
 	active, err := rds.GetActiveRobot(r.Context(), uuid)
 	if err != nil {
+		if status == robot_status.Unknown {
+			status = robot_status.Offline
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"uuid": uuid, "online": false})
+		json.NewEncoder(w).Encode(map[string]interface{}{"uuid": uuid, "online": false, "status": status})
 		return
 	}
+	if status == robot_status.Unknown {
+		status = robot_status.Online // connected through another instance
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"uuid":         uuid,
 		"online":       true,
+		"status":       status,
 		"ip":           active.IP,
 		"device_type":  active.DeviceType,
 		"connected_at": active.ConnectedAt,
 	})
 }
+
+// getRobotSchema returns the command schema uploaded for a generic_actuator robot.
+func (h *HTTPServer_t) getRobotSchema(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	pg := h.db.Postgres()
+	if pg == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	schema, err := pg.GetRobotSchema(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Robot not found", http.StatusNotFound)
+		return
+	}
+	if len(schema) == 0 {
+		http.Error(w, "No schema uploaded for this robot", http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(w, schema, http.StatusOK)
+}
+
+// putRobotSchema uploads or replaces a robot's command schema. Used for robots
+// that joined via REGISTER/PERSIST rather than POST /provision. The running
+// handler picks up the new schema on its next restart. Admin only: the
+// schema decides which commands the robot's handler accepts.
+func (h *HTTPServer_t) putRobotSchema(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	uuid := chi.URLParam(r, "uuid")
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, actuator.MaxSchemaBytes+1))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := actuator.Parse(raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pg := h.db.Postgres()
+	if pg == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := pg.SetRobotSchema(r.Context(), uuid, raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Robot not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to store schema", http.StatusInternalServerError)
+		return
+	}
+
+	sendResponseAsJSON(w, map[string]string{"status": "stored", "uuid": uuid}, http.StatusOK)
+}
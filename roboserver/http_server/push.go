@@ -0,0 +1,160 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/notify"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Push notification settings belong to the calling user: the devices
+// (phones) they registered and the rules choosing which events are pushed
+// to them. The push gateway (push/) delivers them.
+func (h *HTTPServer_t) PushRoutes(r chi.Router) {
+	r.Get("/devices", h.listPushDevices)
+	r.Post("/devices", h.addPushDevice)
+	r.Delete("/devices/{token}", h.removePushDevice)
+	r.Get("/prefs", h.getNotificationPrefs)
+	r.Put("/prefs", h.setNotificationPrefs)
+}
+
+// listPushDevices returns the caller's registered devices.
+func (h *HTTPServer_t) listPushDevices(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	devices, err := h.db.Redis().ListPushDevices(r.Context(), user.Username)
+	if err != nil {
+		http.Error(w, "Failed to list devices", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, devices, http.StatusOK)
+}
+
+// addPushDevice registers a device token for the caller. Registering a
+// token again updates its name.
+// Body: {"token": "...", "platform": "fcm", "name": "Pixel 8"}
+func (h *HTTPServer_t) addPushDevice(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var device notify.Device
+	if err := parseJSONRequest(r, &device); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	device.Name = strings.TrimSpace(device.Name)
+	if err := device.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rds := h.db.Redis()
+	devices, err := rds.ListPushDevices(r.Context(), user.Username)
+	if err != nil {
+		http.Error(w, "Failed to list devices", http.StatusInternalServerError)
+		return
+	}
+	device.AddedAt = time.Now().Unix()
+	registered := false
+	for _, d := range devices {
+		if d.Token == device.Token {
+			registered, device.AddedAt = true, d.AddedAt
+		}
+	}
+	if !registered && len(devices) >= notify.MaxDevices {
+		http.Error(w, "Too many devices registered; remove one first", http.StatusConflict)
+		return
+	}
+	if err := rds.AddPushDevice(r.Context(), user.Username, device); err != nil {
+		http.Error(w, "Failed to register device", http.StatusInternalServerError)
+		return
+	}
+
+	shared.DebugPrint("PUSH: User %s registered %s device %q", user.Username, device.Platform, device.Name)
+	status := http.StatusCreated
+	if registered {
+		status = http.StatusOK
+	}
+	sendResponseAsJSON(w, device, status)
+}
+
+// removePushDevice unregisters one of the caller's devices.
+func (h *HTTPServer_t) removePushDevice(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	removed, err := h.db.Redis().RemovePushDevice(r.Context(), user.Username, chi.URLParam(r, "token"))
+	if err != nil {
+		http.Error(w, "Failed to remove device", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, map[string]string{"status": "removed"}, http.StatusOK)
+}
+
+// getNotificationPrefs returns the caller's notification preferences
+// (disabled with no rules if never set).
+func (h *HTTPServer_t) getNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	prefs, err := h.db.Redis().GetNotificationPrefs(r.Context(), user.Username)
+	if err != nil {
+		http.Error(w, "Failed to load notification preferences", http.StatusInternalServerError)
+		return
+	}
+	if prefs == nil {
+		prefs = &notify.Prefs{Rules: []notify.Rule{}}
+	}
+	sendResponseAsJSON(w, prefs, http.StatusOK)
+}
+
+// setNotificationPrefs replaces the caller's notification preferences.
+// Body: {"enabled": true, "rules": [{"events": ["smart_lock.*"], "title":
+// "Lock {robot}", "body": "{state}"}], "quiet_hours": {"start": "22:00",
+// "end": "07:00", "timezone": "Europe/Berlin"}}
+func (h *HTTPServer_t) setNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var prefs notify.Prefs
+	if err := parseJSONRequest(r, &prefs); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if prefs.Rules == nil {
+		prefs.Rules = []notify.Rule{}
+	}
+	if err := prefs.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.db.Redis().SetNotificationPrefs(r.Context(), user.Username, &prefs); err != nil {
+		http.Error(w, "Failed to store notification preferences", http.StatusInternalServerError)
+		return
+	}
+	if h.bus != nil {
+		h.bus.PublishEvent(events.PushPrefsChanged, map[string]string{"username": user.Username})
+	}
+	sendResponseAsJSON(w, prefs, http.StatusOK)
+}
@@ -0,0 +1,31 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"strings"
+	"testing"
+)
+
+func TestPushRoutes_RequireUser(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		{s.listPushDevices, "GET", ""},
+		{s.addPushDevice, "POST", `{"token":"abc","platform":"fcm"}`},
+		{s.getNotificationPrefs, "GET", ""},
+		{s.setNotificationPrefs, "PUT", `{"enabled":true,"rules":[]}`},
+	} {
+		req := httptest.NewRequest(tc.method, "/push/devices", strings.NewReader(tc.body))
+		req = withSession(req, &shared.Session{UserID: "alice", SessionID: "s1"})
+		rec := httptest.NewRecorder()
+		tc.handler(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 without a resolvable user, got %d", tc.method, rec.Code)
+		}
+	}
+}
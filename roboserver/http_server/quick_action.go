@@ -0,0 +1,215 @@
+package http_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared/events"
+	"roboserver/shared/utils"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// quickActionWorkers bounds how many handlers a bulk quick action writes to
+// at once, so one slow handler can't hold up the rest and a large fleet
+// doesn't spawn a goroutine per robot.
+const quickActionWorkers = 8
+
+// quickActionFilter selects robots for a bulk quick action. Every set field
+// must match; tags must all be present.
+type quickActionFilter struct {
+	Type string   `json:"type"`
+	Tags []string `json:"tags"`
+	Zone string   `json:"zone"`
+}
+
+func (f *quickActionFilter) empty() bool {
+	return f.Type == "" && len(f.Tags) == 0 && f.Zone == ""
+}
+
+func (f *quickActionFilter) matchesLabels(labels *database.RobotLabels) bool {
+	return (f.Zone == "" || labels.Zone == f.Zone) && labels.HasTags(f.Tags)
+}
+
+type quickActionResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// postBulkQuickAction sends a quick_action command to the handler of every
+// active robot matching the filter and reports the outcome per robot.
+// Body: {"action": "beep", "filter": {"type": "...", "tags": [...], "zone": "..."}}
+func (h *HTTPServer_t) postBulkQuickAction(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Action string            `json:"action"`
+		Filter quickActionFilter `json:"filter"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	body.Action = strings.TrimSpace(body.Action)
+	if body.Action == "" {
+		http.Error(w, "action is required", http.StatusBadRequest)
+		return
+	}
+	if body.Filter.empty() {
+		http.Error(w, "filter must set type, tags or zone", http.StatusBadRequest)
+		return
+	}
+
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	uuids, err := selectRobots(r.Context(), rds, h.currentUser(r), &body.Filter)
+	if err != nil {
+		http.Error(w, "Failed to get active robots", http.StatusInternalServerError)
+		return
+	}
+
+	requestID := "qa-" + utils.GenerateRandomString(12)
+	msg, _ := json.Marshal(map[string]string{
+		"command":    "quick_action",
+		"action":     body.Action,
+		"request_id": requestID,
+	})
+
+	targets, held := holdForMaintenance(r.Context(), rds, uuids, string(msg))
+	results := runBounded(targets, quickActionWorkers, func(uuid string) quickActionResult {
+		return sendQuickAction(r.Context(), uuid, string(msg))
+	})
+	for uuid, res := range held {
+		results[uuid] = res
+	}
+
+	sendResponseAsJSON(w, map[string]interface{}{
+		"action":     body.Action,
+		"request_id": requestID,
+		"matched":    len(uuids),
+		"results":    results,
+	}, http.StatusOK)
+}
+
+// selectRobots returns the active robots user may access that match filter
+// (an empty filter matches every robot).
+func selectRobots(ctx context.Context, rds *database.RedisHandler, user *database.User, filter *quickActionFilter) ([]string, error) {
+	robots, err := rds.GetAllActiveRobots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var uuids []string
+	for _, robot := range robots {
+		if filter.Type != "" && robot.DeviceType != filter.Type {
+			continue
+		}
+		if ok, _ := rds.CanAccessRobot(ctx, user, robot.UUID); !ok {
+			continue
+		}
+		if filter.Zone != "" || len(filter.Tags) > 0 {
+			labels, err := rds.GetRobotLabels(ctx, robot.UUID)
+			if err != nil || !filter.matchesLabels(labels) {
+				continue
+			}
+		}
+		uuids = append(uuids, robot.UUID)
+	}
+	return uuids, nil
+}
+
+func sendQuickAction(ctx context.Context, uuid, msg string) quickActionResult {
+	hp, ok := handler_engine.HandlerManager.Get(uuid)
+	if !ok {
+		return quickActionResult{Status: "error", Error: "No handler running for this robot"}
+	}
+	if err := sendToHandler(ctx, hp, msg, ""); err != nil {
+		_, text := handlerErrorStatus(err)
+		return quickActionResult{Status: "error", Error: text}
+	}
+	return quickActionResult{Status: "sent"}
+}
+
+// runBounded calls fn for each uuid on at most workers goroutines and
+// collects the results by uuid.
+func runBounded(uuids []string, workers int, fn func(uuid string) quickActionResult) map[string]quickActionResult {
+	results := make(map[string]quickActionResult, len(uuids))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan string)
+	for i := 0; i < workers && i < len(uuids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for uuid := range jobs {
+				res := fn(uuid)
+				mu.Lock()
+				results[uuid] = res
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, uuid := range uuids {
+		jobs <- uuid
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// getRobotLabels returns a robot's tags and zone.
+func (h *HTTPServer_t) getRobotLabels(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	labels, err := rds.GetRobotLabels(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to get labels", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, labels, http.StatusOK)
+}
+
+// putRobotLabels replaces a robot's name, tags and zone. Admin only.
+// Body: {"name": "Dock 2 lamp", "tags": ["floor-2"], "zone": "warehouse-a"}
+func (h *HTTPServer_t) putRobotLabels(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	uuid := chi.URLParam(r, "uuid")
+
+	var labels database.RobotLabels
+	if err := parseJSONRequest(r, &labels); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	labels.Name = strings.TrimSpace(labels.Name)
+	labels.Zone = strings.TrimSpace(labels.Zone)
+	tags := labels.Tags[:0]
+	for _, tag := range labels.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	labels.Tags = tags
+
+	if err := h.db.Redis().SetRobotLabels(r.Context(), uuid, &labels); err != nil {
+		http.Error(w, "Failed to save labels", http.StatusInternalServerError)
+		return
+	}
+	if h.bus != nil {
+		h.bus.PublishEvent(events.RobotLabels(uuid), events.LabelsChanged{UUID: uuid, Name: labels.Name, Zone: labels.Zone, Tags: labels.Tags})
+	}
+	sendResponseAsJSON(w, labels, http.StatusOK)
+}
@@ -0,0 +1,106 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"roboserver/database"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostBulkQuickAction_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing action", `{"filter": {"type": "lamp"}}`, http.StatusBadRequest},
+		{"empty filter", `{"action": "beep", "filter": {}}`, http.StatusBadRequest},
+		{"nil redis", `{"action": "beep", "filter": {"tags": ["floor-2"]}}`, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(&mockDBManager{})
+			req := httptest.NewRequest("POST", "/robot/quick_action", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			s.postBulkQuickAction(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPutRobotLabels_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("PUT", "/robot/lamp-1/labels", strings.NewReader(`{"zone": "a"}`))
+	req = addChiURLParam(req, "uuid", "lamp-1")
+	rec := httptest.NewRecorder()
+
+	s.putRobotLabels(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+	}
+}
+
+func TestQuickActionFilter_MatchesLabels(t *testing.T) {
+	labels := &database.RobotLabels{Tags: []string{"floor-2", "cleaning"}, Zone: "warehouse-a"}
+	tests := []struct {
+		filter quickActionFilter
+		want   bool
+	}{
+		{quickActionFilter{Zone: "warehouse-a"}, true},
+		{quickActionFilter{Zone: "warehouse-b"}, false},
+		{quickActionFilter{Tags: []string{"cleaning"}}, true},
+		{quickActionFilter{Tags: []string{"cleaning", "floor-2"}, Zone: "warehouse-a"}, true},
+		{quickActionFilter{Tags: []string{"cleaning", "floor-3"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matchesLabels(labels); got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestRunBounded_LimitsConcurrency(t *testing.T) {
+	uuids := []string{"a", "b", "c", "d", "e", "f", "g"}
+	var running, peak atomic.Int32
+
+	results := runBounded(uuids, 3, func(uuid string) quickActionResult {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return quickActionResult{Status: "sent"}
+	})
+
+	if len(results) != len(uuids) {
+		t.Fatalf("Expected %d results, got %d", len(uuids), len(results))
+	}
+	for _, uuid := range uuids {
+		if results[uuid].Status != "sent" {
+			t.Errorf("Expected %s sent, got %+v", uuid, results[uuid])
+		}
+	}
+	if peak.Load() > 3 {
+		t.Errorf("Expected at most 3 concurrent calls, got %d", peak.Load())
+	}
+}
+
+func TestSendQuickAction_NoHandler(t *testing.T) {
+	res := sendQuickAction(t.Context(), "no-such-robot", `{}`)
+	if res.Status != "error" || res.Error == "" {
+		t.Errorf("Expected error result, got %+v", res)
+	}
+}
@@ -0,0 +1,221 @@
+package http_server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"roboserver/shared"
+	"roboserver/shared/jobs"
+	"roboserver/shared/telemetry"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errExportTooLarge = errors.New("export is larger than export.max_bytes")
+
+// readingsFormats maps ?format= to its content type and encoder.
+var readingsFormats = map[string]struct {
+	contentType string
+	write       func(io.Writer, []telemetry.Reading) error
+}{
+	"csv":     {"text/csv; charset=utf-8", telemetry.WriteCSV},
+	"parquet": {"application/vnd.apache.parquet", telemetry.WriteParquet},
+}
+
+// parseExportTime parses a from/to bound given as Unix milliseconds or
+// RFC 3339 ("" = open, returned as 0).
+func parseExportTime(raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil && ms >= 0 {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// getReadingsExport exports the robot's stored DATA readings, one row per
+// metric, oldest first. ?format=csv (default) or parquet; ?from= and ?to=
+// (Unix ms or RFC 3339, to exclusive) and ?type= narrow it. Up to
+// export.sync_rows rows are written straight to the response; larger
+// exports, or any with ?async=true, run as a background job whose file is
+// then downloaded from /readings/export/{job id}.
+func (h *HTTPServer_t) getReadingsExport(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	q := r.URL.Query()
+	formatName := q.Get("format")
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, ok := readingsFormats[formatName]
+	if !ok {
+		http.Error(w, "format must be csv or parquet", http.StatusBadRequest)
+		return
+	}
+	from, err := parseExportTime(q.Get("from"))
+	if err != nil {
+		http.Error(w, "from must be Unix milliseconds or RFC 3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseExportTime(q.Get("to"))
+	if err != nil {
+		http.Error(w, "to must be Unix milliseconds or RFC 3339", http.StatusBadRequest)
+		return
+	}
+	if to > 0 && to <= from {
+		http.Error(w, "to must be after from", http.StatusBadRequest)
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	envs, err := rds.GetTelemetry(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to get telemetry", http.StatusInternalServerError)
+		return
+	}
+	rows := telemetry.Readings(envs, q.Get("type"), from, to)
+	filename := fmt.Sprintf("%s-readings.%s", uuid, formatName)
+
+	if len(rows) <= shared.AppConfig.Export.SyncRows && q.Get("async") != "true" {
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := format.write(w, rows); err != nil {
+			shared.DebugPrint("Readings export for %s failed: %v", uuid, err)
+		}
+		return
+	}
+
+	createdBy := ""
+	if user := h.currentUser(r); user != nil {
+		createdBy = user.Username
+	}
+	job, err := jobs.Default.Submit("readings_export", createdBy, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		p.SetTotal(len(rows))
+		var buf bytes.Buffer
+		if err := format.write(&buf, rows); err != nil {
+			return nil, err
+		}
+		p.Add(len(rows))
+		if err := h.exports.put(p.ID(), exportFile{
+			uuid:        uuid,
+			filename:    filename,
+			contentType: format.contentType,
+			data:        buf.Bytes(),
+		}); err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"rows":     len(rows),
+			"bytes":    buf.Len(),
+			"download": fmt.Sprintf("/robot/%s/readings/export/%s", uuid, p.ID()),
+		}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	sendResponseAsJSON(w, job, http.StatusAccepted)
+}
+
+// getReadingsExportFile downloads the file of a finished export job.
+func (h *HTTPServer_t) getReadingsExportFile(w http.ResponseWriter, r *http.Request) {
+	uuid, id := chi.URLParam(r, "uuid"), chi.URLParam(r, "id")
+	job, ok := h.visibleJob(r, id)
+	if !ok || job.Type != "readings_export" {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	if !job.Finished() {
+		http.Error(w, "Export is not ready yet", http.StatusConflict)
+		return
+	}
+	file, ok := h.exports.get(id)
+	if !ok || file.uuid != uuid {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", file.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(file.data)))
+	w.Write(file.data)
+}
+
+type exportFile struct {
+	uuid        string
+	filename    string
+	contentType string
+	data        []byte
+	expires     time.Time
+}
+
+// exportStore holds the files of export jobs, keyed by job id, for
+// export.keep and up to export.max_bytes in total.
+type exportStore struct {
+	mu    sync.Mutex
+	files map[string]exportFile
+	order []string // ids, oldest first
+	size  int
+}
+
+func (s *exportStore) put(id string, f exportFile) error {
+	cfg := &shared.AppConfig.Export
+	if len(f.data) > cfg.MaxBytes {
+		return errExportTooLarge
+	}
+	now := time.Now()
+	f.expires = now.Add(cfg.KeepFor())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string]exportFile)
+	}
+	s.prune(now)
+	for len(s.order) > 0 && s.size+len(f.data) > cfg.MaxBytes {
+		s.drop(0)
+	}
+	s.files[id] = f
+	s.order = append(s.order, id)
+	s.size += len(f.data)
+	return nil
+}
+
+func (s *exportStore) get(id string) (exportFile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	f, ok := s.files[id]
+	return f, ok
+}
+
+// prune drops expired files. Caller holds s.mu.
+func (s *exportStore) prune(now time.Time) {
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if !now.Before(s.files[s.order[i]].expires) {
+			s.drop(i)
+		}
+	}
+}
+
+// drop removes the i'th oldest file. Caller holds s.mu.
+func (s *exportStore) drop(i int) {
+	id := s.order[i]
+	s.size -= len(s.files[id].data)
+	delete(s.files, id)
+	s.order = slices.Delete(s.order, i, i+1)
+}
@@ -0,0 +1,83 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"testing"
+)
+
+func TestParseExportTime(t *testing.T) {
+	for raw, want := range map[string]int64{
+		"":                     0,
+		"1718000000000":        1718000000000,
+		"2024-06-10T06:13:20Z": 1718000000000,
+	} {
+		if got, err := parseExportTime(raw); err != nil || got != want {
+			t.Errorf("parseExportTime(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"yesterday", "-5", "2024-06-10"} {
+		if _, err := parseExportTime(raw); err == nil {
+			t.Errorf("Expected an error for %q", raw)
+		}
+	}
+}
+
+func TestGetReadingsExportValidation(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	for query, want := range map[string]int{
+		"?format=xlsx":       http.StatusBadRequest,
+		"?from=soon":         http.StatusBadRequest,
+		"?from=2000&to=1000": http.StatusBadRequest,
+		"?format=parquet":    http.StatusServiceUnavailable, // no Redis
+		"?from=1000&to=2000": http.StatusServiceUnavailable,
+	} {
+		req := addChiURLParam(httptest.NewRequest("GET", "/robot/r1/readings/export"+query, nil), "uuid", "r1")
+		w := httptest.NewRecorder()
+		h.getReadingsExport(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d %s", query, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestExportStoreBoundsBytes(t *testing.T) {
+	saved := shared.AppConfig.Export
+	defer func() { shared.AppConfig.Export = saved }()
+	shared.AppConfig.Export.MaxBytes = 10
+	shared.AppConfig.Export.Keep = "1h"
+
+	var s exportStore
+	if err := s.put("big", exportFile{data: make([]byte, 11)}); err != errExportTooLarge {
+		t.Errorf("Expected errExportTooLarge, got %v", err)
+	}
+	s.put("a", exportFile{uuid: "r1", data: make([]byte, 6)})
+	s.put("b", exportFile{uuid: "r1", data: make([]byte, 4)})
+	s.put("c", exportFile{uuid: "r1", data: make([]byte, 3)})
+	if _, ok := s.get("a"); ok {
+		t.Error("Expected the oldest export to be dropped to make room")
+	}
+	if f, ok := s.get("c"); !ok || f.uuid != "r1" {
+		t.Errorf("Expected the newest export, got %+v %v", f, ok)
+	}
+	if s.size != 7 {
+		t.Errorf("Expected 7 bytes held, got %d", s.size)
+	}
+
+	shared.AppConfig.Export.Keep = "1ns"
+	s.put("d", exportFile{data: make([]byte, 1)})
+	if _, ok := s.get("d"); ok {
+		t.Error("Expected an expired export to be gone")
+	}
+}
+
+func TestGetReadingsExportFileUnknownJob(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	req := addChiURLParam(httptest.NewRequest("GET", "/robot/r1/readings/export/nope", nil), "id", "nope")
+	w := httptest.NewRecorder()
+	h.getReadingsExportFile(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown export, got %d", w.Code)
+	}
+}
@@ -11,6 +11,12 @@ import (
 func (h *HTTPServer_t) RegisterRoutes(r chi.Router) {
 	r.Post("/", h.respondToRegistration)
 	r.Get("/pending", h.getPendingRegistrations)
+	r.Get("/pairing", h.listPairingCodes)
+	r.Post("/pairing", h.createPairingCodes)
+	r.Delete("/pairing/{code}", h.revokePairingCode)
+	r.Get("/auto_accept", h.getAutoAccept)
+	r.Put("/auto_accept", h.putAutoAccept)
+	r.Delete("/auto_accept", h.deleteAutoAccept)
 }
 
 type RegistrationResponse struct {
@@ -22,8 +28,8 @@ type RegistrationResponse struct {
 // This is called by the frontend notification or terminal.
 func (h *HTTPServer_t) respondToRegistration(w http.ResponseWriter, r *http.Request) {
 	var req RegistrationResponse
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := parseJSONRequest(r, &req); err != nil {
+		sendBodyError(w, err)
 		return
 	}
 
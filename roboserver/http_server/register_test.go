@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"roboserver/database"
+	"roboserver/handler_engine"
 	"roboserver/shared"
 	"strings"
 	"testing"
@@ -15,6 +16,9 @@ import (
 
 func init() {
 	shared.AppConfig = shared.Config{
+		Handlers: shared.HandlersConfig{
+			BasePath: "../../handlers",
+		},
 		Auth: shared.AuthConfig{
 			JWTSecret:   "test-secret-for-http-tests",
 			JWTExpiry:   3600,
@@ -146,7 +150,7 @@ func TestProvisionRobot_NilDatabase(t *testing.T) {
 	s := newTestServer(&mockDBManager{pg: nil, rds: nil})
 
 	// Use a valid-length hex key (64 hex chars = 32 bytes Ed25519)
-	body := strings.NewReader(`{"uuid": "r1", "public_key": "aabbccdd11223344aabbccdd11223344aabbccdd11223344aabbccdd11223344", "device_type": "test"}`)
+	body := strings.NewReader(`{"uuid": "r1", "public_key": "aabbccdd11223344aabbccdd11223344aabbccdd11223344aabbccdd11223344", "device_type": "test_robot"}`)
 	req := httptest.NewRequest("POST", "/provision", body)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
@@ -158,6 +162,86 @@ func TestProvisionRobot_NilDatabase(t *testing.T) {
 	}
 }
 
+func TestProvisionRobot_InvalidSchema(t *testing.T) {
+	s := newTestServer(&mockDBManager{pg: nil, rds: nil})
+
+	body := strings.NewReader(`{"uuid": "r1", "public_key": "aabbccdd11223344aabbccdd11223344aabbccdd11223344aabbccdd11223344", "device_type": "generic_actuator", "schema": {"commands": {}}}`)
+	req := httptest.NewRequest("POST", "/provision", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	s.provisionRobot(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty schema, got %d", rec.Code)
+	}
+}
+
+func TestProvisionRobot_RegistrationErrors(t *testing.T) {
+	s := newTestServer(&mockDBManager{pg: nil, rds: nil})
+	key := "aabbccdd11223344aabbccdd11223344aabbccdd11223344aabbccdd11223344"
+
+	tests := []struct {
+		name       string
+		uuid       string
+		deviceType string
+		code       string
+	}{
+		{"uuid with slash", "robot/1", "test_robot", handler_engine.CodeInvalidUUID},
+		{"uuid with colon", "robot:1", "test_robot", handler_engine.CodeInvalidUUID},
+		{"malformed device type", "r1", "test robot", handler_engine.CodeInvalidDeviceType},
+		{"unknown device type", "r1", "toaster", handler_engine.CodeUnknownDeviceType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"uuid": tt.uuid, "public_key": key, "device_type": tt.deviceType})
+			req := httptest.NewRequest("POST", "/provision", strings.NewReader(string(body)))
+			rec := httptest.NewRecorder()
+
+			s.provisionRobot(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("Expected 400, got %d", rec.Code)
+			}
+			var resp map[string]any
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Expected JSON error body: %v", err)
+			}
+			if resp["code"] != tt.code {
+				t.Errorf("Expected code %s, got %q", tt.code, resp["code"])
+			}
+			if _, ok := resp["valid_types"]; ok != (tt.code == handler_engine.CodeUnknownDeviceType) {
+				t.Errorf("Expected valid_types only for an unknown device type, got %v", resp)
+			}
+		})
+	}
+}
+
+func TestPutRobotSchema_RequiresAdmin(t *testing.T) {
+	s := newTestServer(&mockDBManager{pg: nil, rds: nil})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid schema", `{"commands": {"bad name": {}}}`},
+		{"valid schema", `{"commands": {"stop": {}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/provision/r1/schema", strings.NewReader(tt.body))
+			req = addChiURLParam(req, "uuid", "r1")
+			rec := httptest.NewRecorder()
+
+			s.putRobotSchema(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("Expected 403 without an admin session, got %d", rec.Code)
+			}
+		})
+	}
+}
+
 func TestGetAllRegisteredRobots_NilDatabase(t *testing.T) {
 	s := newTestServer(&mockDBManager{pg: nil, rds: nil})
 	req := httptest.NewRequest("GET", "/provision", nil)
@@ -267,7 +351,7 @@ func TestEphemeralSession_MissingFields(t *testing.T) {
 func TestEphemeralSession_NilRedis(t *testing.T) {
 	s := newTestServer(&mockDBManager{pg: nil, rds: nil})
 
-	body := strings.NewReader(`{"uuid": "r1", "device_type": "test"}`)
+	body := strings.NewReader(`{"uuid": "r1", "device_type": "test_robot"}`)
 	req := httptest.NewRequest("POST", "/ephemeral", body)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
@@ -3,15 +3,59 @@ package http_server
 import (
 	"encoding/json"
 	"net/http"
+	"roboserver/database"
 	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/maintenance"
+	"roboserver/shared/robot_status"
 
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func (h *HTTPServer_t) RobotRoutes(r chi.Router) {
 	r.Get("/", h.getActiveRobots)
-	r.Get("/{uuid}", h.getRobotDetail)
-	r.Post("/{uuid}/message", h.sendRobotMessage)
+	r.Post("/quick_action", h.postBulkQuickAction)
+	r.Post("/broadcast", h.postBroadcast)
+	r.Get("/stream", h.streamRobotRegistry)
+	r.Get("/types", h.getRobotTypes)
+	r.Post("/discover", h.postDiscover)
+	r.Get("/discovered", h.getDiscovered)
+	r.Post("/discovered/{ip}/provision", h.provisionDiscovered)
+	r.Post("/onboard", h.postOnboard)
+	r.Get("/onboard/{code}", h.getOnboard)
+	r.Route("/{uuid}", func(r chi.Router) {
+		r.Use(h.RobotAccessMiddleware)
+		r.Get("/", h.getRobotDetail)
+		r.Post("/message", h.sendRobotMessage)
+		r.Post("/control", h.sendVerifiedRobotMessage)
+		r.Get("/schedule", h.getRobotSchedule)
+		r.Post("/schedule", h.postRobotSchedule)
+		r.Delete("/schedule/{id}", h.deleteRobotSchedule)
+		r.Post("/macro/{name}", h.runRobotMacro)
+		r.Get("/stats", h.getRobotStats)
+		r.Get("/ping", h.getRobotPing)
+		r.Post("/webrtc/offer", h.postWebRTCOffer)
+		r.Post("/webrtc/{session}/candidate", h.postWebRTCCandidate)
+		r.Delete("/webrtc/{session}", h.deleteWebRTCSession)
+		r.Get("/data/{key}", h.getRobotHandlerData)
+		r.Get("/telemetry", h.getRobotTelemetry)
+		r.Get("/readings/export", h.getReadingsExport)
+		r.Get("/readings/export/{id}", h.getReadingsExportFile)
+		r.Get("/state", h.getRobotState)
+		r.Get("/shadow", h.getRobotShadow)
+		r.Patch("/shadow", h.patchRobotShadow)
+		r.Delete("/shadow", h.deleteRobotShadow)
+		r.Get("/energy", h.getRobotEnergy)
+		r.Post("/maintenance", h.postRobotMaintenance)
+		r.Delete("/maintenance", h.deleteRobotMaintenance)
+		r.Post("/disconnect", h.postRobotDisconnect)
+		r.Get("/labels", h.getRobotLabels)
+		r.Put("/labels", h.putRobotLabels)
+		r.Get("/acl", h.getRobotACL)
+		r.Post("/acl", h.grantRobotAccess)
+		r.Delete("/acl/{principal}", h.revokeRobotAccess)
+	})
 }
 
 // getActiveRobots returns all currently active robots from Redis.
@@ -22,14 +66,36 @@ func (h *HTTPServer_t) getActiveRobots(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	robots, err := rds.GetAllActiveRobots(r.Context())
+	// Both counters only grow, so their sum changes whenever either does.
+	version := rds.ActiveRobotsVersion() + maintenance.Registry.Version()
+	enc := negotiateEncoder(r.Header.Get("Accept"))
+	robots, body, err := h.robotList.getAs(enc, version, func() ([]*database.ActiveRobot, error) {
+		robots, err := rds.GetAllActiveRobots(r.Context())
+		for i, robot := range robots {
+			robots[i] = withMaintenance(robot)
+		}
+		return robots, err
+	})
 	if err != nil {
 		http.Error(w, "Failed to get active robots", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(robots)
+	// Non-admin users only see robots they have been granted
+	if user := h.currentUser(r); user == nil || !user.IsAdmin() {
+		visible := make([]*database.ActiveRobot, 0, len(robots))
+		for _, robot := range robots {
+			if ok, _ := rds.CanAccessRobot(r.Context(), user, robot.UUID); ok {
+				visible = append(visible, robot)
+			}
+		}
+		if body, err = enc.encode(visible); err != nil {
+			http.Error(w, "Failed to encode robots", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeEncodedBody(w, enc, body, http.StatusOK)
 }
 
 // getRobotDetail returns a comprehensive view of a robot including active session,
@@ -46,14 +112,26 @@ func (h *HTTPServer_t) getRobotDetail(w http.ResponseWriter, r *http.Request) {
 		"uuid":   uuid,
 		"online": false,
 	}
+	status := robot_status.Tracker.Get(uuid)
 
 	// Active session info
 	if active, err := rds.GetActiveRobot(r.Context(), uuid); err == nil {
 		resp["online"] = true
 		resp["ip"] = active.IP
+		resp["ips"] = active.Addresses()
 		resp["device_type"] = active.DeviceType
 		resp["connected_at"] = active.ConnectedAt
 		resp["pid"] = active.PID
+		if status == robot_status.Unknown {
+			status = robot_status.Online // connected through another instance
+		}
+	}
+	if status == robot_status.Unknown {
+		status = robot_status.Offline
+	}
+	resp["status"] = status
+	if info, ok := maintenance.Registry.Get(uuid); ok {
+		resp["maintenance"] = info
 	}
 
 	// Heartbeat info (independent of handler)
@@ -62,16 +140,26 @@ func (h *HTTPServer_t) getRobotDetail(w http.ResponseWriter, r *http.Request) {
 			"last_seq":  hb.LastSeq,
 			"last_seen": hb.LastSeen,
 			"ip":        hb.IP,
+			"ips":       hb.Addresses(),
 		}
 	}
 
 	// Handler status
 	if hp, ok := handler_engine.HandlerManager.Get(uuid); ok {
-		resp["handler"] = map[string]interface{}{
-			"active":      true,
-			"pid":         hp.PID,
-			"device_type": hp.DeviceType,
+		snap := hp.Snapshot()
+		handler := map[string]interface{}{
+			"active":       true,
+			"pid":          snap.PID,
+			"device_type":  snap.DeviceType,
+			"connected":    snap.Connected,
+			"reconnecting": snap.Reconnecting,
+		}
+		cl := hp.CommandLock()
+		if cl.Serialize > 0 {
+			handler["serialize_commands"] = cl.Serialize.String()
 		}
+		handler["busy"] = cl.Holder != ""
+		resp["handler"] = handler
 	} else {
 		resp["handler"] = map[string]interface{}{
 			"active": false,
@@ -80,7 +168,7 @@ func (h *HTTPServer_t) getRobotDetail(w http.ResponseWriter, r *http.Request) {
 
 	// Registration info from PostgreSQL
 	if pg := h.db.Postgres(); pg != nil {
-		if robot, err := pg.GetRobotByUUID(r.Context(), uuid); err == nil {
+		if robot, err := h.robotRecord(r.Context(), pg, uuid); err == nil {
 			resp["registered"] = true
 			resp["registration"] = map[string]interface{}{
 				"device_type":    robot.DeviceType,
@@ -90,30 +178,42 @@ func (h *HTTPServer_t) getRobotDetail(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	sendNegotiated(w, r, resp, http.StatusOK)
 }
 
 // sendRobotMessage forwards a message from the HTTP API to a robot's handler process.
-// The handler receives it as an incoming message on stdin.
+// The handler receives it as an incoming message on stdin. An optional
+// "lock" duration holds the handler's command lock, so overlapping commands
+// get 409 until the handler reports command_done.
 func (h *HTTPServer_t) sendRobotMessage(w http.ResponseWriter, r *http.Request) {
 	uuid := chi.URLParam(r, "uuid")
 
 	var body struct {
 		Message string `json:"message"`
+		Lock    string `json:"lock"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	lock, err := parseCommandLock(body.Lock)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	hp, ok := handler_engine.HandlerManager.Get(uuid)
 	if !ok {
-		http.Error(w, "No handler running for this robot", http.StatusNotFound)
+		if !h.forwardToRemoteHandler(w, r, uuid, body.Message, "", lock) {
+			http.Error(w, "No handler running for this robot", http.StatusNotFound)
+		}
 		return
 	}
 
-	hp.SendIncoming(body.Message)
+	if err := sendCommand(r.Context(), hp, body.Message, "", lock); err != nil {
+		sendHandlerError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -121,3 +221,145 @@ func (h *HTTPServer_t) sendRobotMessage(w http.ResponseWriter, r *http.Request)
 		"uuid":   uuid,
 	})
 }
+
+// sendVerifiedRobotMessage is sendRobotMessage for sensitive actuations. The
+// caller must re-enter their password; the handler receives the message with
+// "actor" set to the verified username, which robots themselves cannot forge.
+// Body: {"password": "...", "message": "...", "lock": "30s"} (lock optional)
+func (h *HTTPServer_t) sendVerifiedRobotMessage(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+
+	var body struct {
+		Password string `json:"password"`
+		Message  string `json:"message"`
+		Lock     string `json:"lock"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if body.Password == "" || body.Message == "" {
+		http.Error(w, "password and message are required", http.StatusBadRequest)
+		return
+	}
+	lock, err := parseCommandLock(body.Lock)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Failed re-checks count against the login limits, per client IP and
+	// per account, so a stolen session or API key can't be used to guess
+	// the password here instead of at /auth/login.
+	ipKey := "control:ip:" + shared.CanonicalIP(r.RemoteAddr)
+	if checkLoginRate(ipKey) {
+		http.Error(w, "Too many password attempts. Try again later.", http.StatusTooManyRequests)
+		return
+	}
+	if len(body.Password) > 72 {
+		recordLoginAttempt(ipKey)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	if h.db.Redis() == nil {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	user := h.currentUser(r)
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userKey := "control:user:" + user.Username
+	if checkLoginRate(userKey) {
+		http.Error(w, "Too many password attempts. Try again later.", http.StatusTooManyRequests)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(body.Password)); err != nil {
+		recordLoginAttempt(ipKey)
+		recordLoginAttempt(userKey)
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	hp, ok := handler_engine.HandlerManager.Get(uuid)
+	if !ok {
+		if !h.forwardToRemoteHandler(w, r, uuid, body.Message, user.Username, lock) {
+			http.Error(w, "No handler running for this robot", http.StatusNotFound)
+		}
+		return
+	}
+
+	if err := sendCommand(r.Context(), hp, body.Message, user.Username, lock); err != nil {
+		sendHandlerError(w, err)
+		return
+	}
+	shared.DebugPrint("CONTROL: %s sent verified message to %s", user.Username, uuid)
+
+	sendResponseAsJSON(w, map[string]string{
+		"status": "sent",
+		"uuid":   uuid,
+		"actor":  user.Username,
+	}, http.StatusOK)
+}
+
+// getRobotTypes lists the device types robots can register as, for type
+// pickers: [{"type":"env_sensor","plugin":false}, ...].
+func (h *HTTPServer_t) getRobotTypes(w http.ResponseWriter, r *http.Request) {
+	sendResponseAsJSON(w, handler_engine.ListRobotTypes(), http.StatusOK)
+}
+
+// getRobotStats returns rolling link latency stats measured by server PINGs.
+func (h *HTTPServer_t) getRobotStats(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	samples, err := rds.GetLatencySamples(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to get latency stats", http.StatusInternalServerError)
+		return
+	}
+
+	clock, err := rds.GetClockOffset(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to get clock offset", http.StatusInternalServerError)
+		return
+	}
+
+	sendResponseAsJSON(w, map[string]interface{}{
+		"uuid":    uuid,
+		"latency": database.SummarizeLatency(samples, shared.AppConfig.Timeouts.LatencyDegradedThreshold()),
+		"clock":   clock,
+	}, http.StatusOK)
+}
+
+// getRobotHandlerData returns a value the robot's handler saved with
+// store_data (e.g. an env_sensor's "history"). The stored JSON is returned as-is.
+func (h *HTTPServer_t) getRobotHandlerData(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	key := chi.URLParam(r, "key")
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	val, err := rds.GetHandlerData(r.Context(), uuid, key)
+	if err != nil {
+		http.Error(w, "Failed to get handler data", http.StatusInternalServerError)
+		return
+	}
+	if val == "" {
+		http.Error(w, "No data stored under that key", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(val))
+}
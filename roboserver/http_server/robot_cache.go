@@ -0,0 +1,135 @@
+package http_server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"sync"
+	"time"
+)
+
+// robotRecordCache holds PostgreSQL robot records between detail page
+// polls, for database.postgres.robot_cache_ttl. Whoever changes a record
+// publishes events.RobotRecord, which drops it from every node's cache.
+// Robots that aren't registered are cached too, so polling an ephemeral
+// robot doesn't reach the database.
+type robotRecordCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRobot
+	gen     uint64 // bumped by invalidate, so a load racing it isn't stored
+}
+
+type cachedRobot struct {
+	robot   *database.RobotRecord // nil = not registered
+	expires time.Time
+}
+
+// get returns uuid's record, calling load on a miss. Like
+// PostgresHandler.GetRobotByUUID it fails with sql.ErrNoRows for a robot
+// that isn't registered. The record is a copy the caller may modify.
+func (c *robotRecordCache) get(uuid string, load func() (*database.RobotRecord, error)) (*database.RobotRecord, error) {
+	cfg := &shared.AppConfig.Database.Postgres
+	ttl := cfg.RobotCacheFor()
+	if ttl == 0 {
+		return load()
+	}
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[uuid]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return copyRobot(e.robot)
+	}
+
+	robot, err := load()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err // not cached: the next poll retries
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		if c.entries == nil {
+			c.entries = make(map[string]cachedRobot)
+		}
+		if _, ok := c.entries[uuid]; !ok && len(c.entries) >= max(cfg.RobotCacheSize, 1) {
+			c.evict(now, max(cfg.RobotCacheSize, 1))
+		}
+		c.entries[uuid] = cachedRobot{robot: robot, expires: now.Add(ttl)}
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return copyRobot(robot)
+}
+
+// invalidate drops uuid's cached record.
+func (c *robotRecordCache) invalidate(uuid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, uuid)
+	c.gen++
+}
+
+// evict makes room for one entry: expired entries go first, otherwise
+// arbitrary ones. Caller holds c.mu.
+func (c *robotRecordCache) evict(now time.Time, size int) {
+	for uuid, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, uuid)
+		}
+	}
+	for uuid := range c.entries {
+		if len(c.entries) < size {
+			return
+		}
+		delete(c.entries, uuid)
+	}
+}
+
+func copyRobot(robot *database.RobotRecord) (*database.RobotRecord, error) {
+	if robot == nil {
+		return nil, sql.ErrNoRows
+	}
+	r := *robot
+	return &r, nil
+}
+
+// robotRecord reads a robot's registry record through the cache.
+func (h *HTTPServer_t) robotRecord(ctx context.Context, pg *database.PostgresHandler, uuid string) (*database.RobotRecord, error) {
+	return h.robotRecords.get(uuid, func() (*database.RobotRecord, error) {
+		return pg.GetRobotByUUID(ctx, uuid)
+	})
+}
+
+// recordChanged announces a change to a robot's registry record, dropping
+// it from this and every other node's cache.
+func (h *HTTPServer_t) recordChanged(change events.RecordChange) {
+	h.robotRecords.invalidate(change.UUID)
+	if h.bus != nil {
+		h.bus.PublishEvent(events.RobotRecord(change.UUID), change)
+	}
+}
+
+// watchRecordChanges invalidates records changed by other nodes and by the
+// TCP PERSIST command until ctx is cancelled.
+func (h *HTTPServer_t) watchRecordChanges(ctx context.Context) error {
+	if h.bus == nil {
+		return nil
+	}
+	cancel, err := h.bus.SubscribeMatching(events.IsRobotRecord, func(eventType string, _ any) {
+		uuid, _, _ := events.ParseRobotTopic(eventType)
+		h.robotRecords.invalidate(uuid)
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+	return nil
+}
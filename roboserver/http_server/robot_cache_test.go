@@ -0,0 +1,94 @@
+package http_server
+
+import (
+	"database/sql"
+	"errors"
+	"roboserver/database"
+	"roboserver/shared"
+	"testing"
+)
+
+func withRobotCache(t *testing.T, ttl string, size int) {
+	t.Helper()
+	saved := shared.AppConfig.Database.Postgres
+	t.Cleanup(func() { shared.AppConfig.Database.Postgres = saved })
+	shared.AppConfig.Database.Postgres.RobotCacheTTL = ttl
+	shared.AppConfig.Database.Postgres.RobotCacheSize = size
+}
+
+func TestRobotRecordCacheHitAndInvalidate(t *testing.T) {
+	withRobotCache(t, "1m", 10)
+	var c robotRecordCache
+	loads := 0
+	load := func() (*database.RobotRecord, error) {
+		loads++
+		return &database.RobotRecord{UUID: "r1", DeviceType: "rover"}, nil
+	}
+
+	robot, err := c.get("r1", load)
+	if err != nil || robot.DeviceType != "rover" {
+		t.Fatalf("Unexpected first read: %+v, %v", robot, err)
+	}
+	robot.DeviceType = "changed by caller"
+	if robot, _ = c.get("r1", load); loads != 1 || robot.DeviceType != "rover" {
+		t.Errorf("Expected a cached copy, got %d loads, %+v", loads, robot)
+	}
+
+	c.invalidate("r1")
+	c.get("r1", load)
+	if loads != 2 {
+		t.Errorf("Expected a reload after invalidate, got %d loads", loads)
+	}
+}
+
+func TestRobotRecordCacheNotRegistered(t *testing.T) {
+	withRobotCache(t, "1m", 10)
+	var c robotRecordCache
+	loads := 0
+	missing := func() (*database.RobotRecord, error) {
+		loads++
+		return nil, sql.ErrNoRows
+	}
+
+	for range 2 {
+		if _, err := c.get("r1", missing); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Expected sql.ErrNoRows, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Expected an unregistered robot to be cached, got %d loads", loads)
+	}
+
+	failing := func() (*database.RobotRecord, error) {
+		loads++
+		return nil, errors.New("connection refused")
+	}
+	c.get("r2", failing)
+	c.get("r2", failing)
+	if loads != 3 {
+		t.Errorf("Expected database errors not to be cached, got %d loads", loads)
+	}
+}
+
+func TestRobotRecordCacheDisabledAndBounded(t *testing.T) {
+	withRobotCache(t, "0", 10)
+	var c robotRecordCache
+	loads := 0
+	load := func() (*database.RobotRecord, error) {
+		loads++
+		return &database.RobotRecord{}, nil
+	}
+	c.get("r1", load)
+	c.get("r1", load)
+	if loads != 2 {
+		t.Errorf("Expected robot_cache_ttl 0 to disable the cache, got %d loads", loads)
+	}
+
+	withRobotCache(t, "1m", 2)
+	for _, uuid := range []string{"a", "b", "c", "d"} {
+		c.get(uuid, load)
+	}
+	if n := len(c.entries); n > 2 {
+		t.Errorf("Expected at most robot_cache_size entries, got %d", n)
+	}
+}
@@ -0,0 +1,102 @@
+package http_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"roboserver/http_server/http_events"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// robotEventMatcher matches every event scoped to one robot: any event type
+// with a dot-separated segment equal to the UUID, which covers
+// robot.{uuid}.status/heartbeat/latency, handler.{uuid}.log and the
+// {type}.{uuid}.* events published by handlers.
+func robotEventMatcher(uuid string) func(string) bool {
+	return func(eventType string) bool {
+		for _, segment := range strings.Split(eventType, ".") {
+			if segment == uuid {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// streamRobotEvents opens an SSE stream of all events for one robot, so a
+// device page doesn't have to know each topic name. Frames use the same
+// envelope as /events. Like streamHandlerLogs it accepts a ticket
+// (?ticket=...) or a JWT, since browser EventSource cannot send headers.
+func (h *HTTPServer_t) streamRobotEvents(w http.ResponseWriter, r *http.Request) {
+	session := h.validateTicket(r)
+	if session == nil {
+		session = h.validateSessionFull(r)
+	}
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	uuid := chi.URLParam(r, "uuid")
+	if uuid == "" {
+		http.Error(w, "Robot UUID is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorizeRobotStream(w, r, session, uuid) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Channel events to serialize writes to the ResponseWriter
+	eventCh := make(chan http_events.SentEvent, 256)
+
+	cancel, err := h.bus.SubscribeMatching(robotEventMatcher(uuid), func(eventType string, data any) {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		select {
+		case eventCh <- http_events.SentEvent{Type: eventType, Data: string(jsonData)}:
+		default:
+			// Drop if channel is full to avoid blocking event bus
+		}
+	})
+	if err != nil {
+		http.Error(w, "Failed to subscribe to robot events", http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, ": streaming events for %s\n\n", uuid)
+	flusher.Flush()
+
+	var id uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-eventCh:
+			id++
+			event.Id = strconv.FormatUint(id, 10)
+			frame, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", frame)
+			flusher.Flush()
+		}
+	}
+}
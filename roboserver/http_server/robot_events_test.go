@@ -0,0 +1,39 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamRobotEvents_NoAuth(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("GET", "/robot/lamp-1/events", nil)
+	req = addChiURLParam(req, "uuid", "lamp-1")
+	rec := httptest.NewRecorder()
+
+	s.streamRobotEvents(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rec.Code)
+	}
+}
+
+func TestRobotEventMatcher(t *testing.T) {
+	match := robotEventMatcher("lamp-1")
+	cases := map[string]bool{
+		"robot.lamp-1.status":    true,
+		"robot.lamp-1.heartbeat": true,
+		"handler.lamp-1.log":     true,
+		"lamp.lamp-1.brightness": true,
+		"robot.lamp-10.status":   false,
+		"robot.lamp.status":      false,
+		"robot.registered":       false,
+		"webrtc.lamp-1x.answer":  false,
+	}
+	for eventType, want := range cases {
+		if got := match(eventType); got != want {
+			t.Errorf("match(%q) = %v, want %v", eventType, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package http_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"roboserver/database"
+	"sync"
+	"time"
+)
+
+// robotListMaxAge bounds how stale the cached robot list can get from
+// changes this node doesn't see: sessions on other cluster nodes and
+// sessions expiring by TTL.
+const robotListMaxAge = time.Second
+
+// robotListCache holds the active robot list and its encoded forms between
+// GET /robot calls. It is rebuilt when this node changes a session
+// (RedisHandler.ActiveRobotsVersion) or after robotListMaxAge.
+type robotListCache struct {
+	mu      sync.Mutex
+	version uint64
+	builtAt time.Time
+	robots  []*database.ActiveRobot
+	body    []byte            // robots encoded as JSON, served as is to admins
+	encoded map[string][]byte // other negotiated encodings, by media type, made on first use
+}
+
+// get returns the cached list and its JSON, calling load to rebuild it if
+// it is stale. Concurrent callers wait for one rebuild instead of each
+// hitting Redis.
+func (c *robotListCache) get(version uint64, load func() ([]*database.ActiveRobot, error)) ([]*database.ActiveRobot, []byte, error) {
+	return c.getAs(responseEncoders[0], version, load)
+}
+
+// getAs is get with the list encoded by enc.
+func (c *robotListCache) getAs(enc *responseEncoder, version uint64, load func() ([]*database.ActiveRobot, error)) ([]*database.ActiveRobot, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.body == nil || c.version != version || time.Since(c.builtAt) >= robotListMaxAge {
+		robots, err := load()
+		if err != nil {
+			return nil, nil, err
+		}
+		if robots == nil {
+			robots = []*database.ActiveRobot{}
+		}
+		body, err := encodeJSON(robots)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.version, c.builtAt, c.robots, c.body, c.encoded = version, time.Now(), robots, body, nil
+	}
+
+	if enc == responseEncoders[0] {
+		return c.robots, c.body, nil
+	}
+	if body, ok := c.encoded[enc.mediaType]; ok {
+		return c.robots, body, nil
+	}
+	body, err := enc.encode(c.robots)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.encoded == nil {
+		c.encoded = make(map[string][]byte)
+	}
+	c.encoded[enc.mediaType] = body
+	return c.robots, body, nil
+}
+
+var jsonBufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// encodeJSON encodes v through a pooled buffer and returns a copy of the
+// bytes, newline-terminated like json.Encoder output.
+func encodeJSON(v any) ([]byte, error) {
+	buf := jsonBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		jsonBufferPool.Put(buf)
+	}()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
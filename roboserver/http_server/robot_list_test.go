@@ -0,0 +1,73 @@
+package http_server
+
+import (
+	"fmt"
+	"roboserver/database"
+	"strings"
+	"testing"
+)
+
+func TestRobotListCacheRebuildsOnVersionChange(t *testing.T) {
+	var c robotListCache
+	loads := 0
+	load := func() ([]*database.ActiveRobot, error) {
+		loads++
+		return []*database.ActiveRobot{{UUID: fmt.Sprintf("r%d", loads)}}, nil
+	}
+
+	_, body, _ := c.get(1, load)
+	c.get(1, load)
+	if loads != 1 {
+		t.Fatalf("Expected one load for an unchanged version, got %d", loads)
+	}
+	if !strings.Contains(string(body), `"uuid":"r1"`) {
+		t.Errorf("Unexpected cached body: %s", body)
+	}
+
+	robots, body, _ := c.get(2, load)
+	if loads != 2 || robots[0].UUID != "r2" || !strings.Contains(string(body), `"uuid":"r2"`) {
+		t.Errorf("Expected a rebuild after a session change, got %d loads, %s", loads, body)
+	}
+}
+
+func TestRobotListCacheEmptyListIsArray(t *testing.T) {
+	var c robotListCache
+	_, body, err := c.get(0, func() ([]*database.ActiveRobot, error) { return nil, nil })
+	if err != nil || strings.TrimSpace(string(body)) != "[]" {
+		t.Errorf("Expected [], got %q (%v)", body, err)
+	}
+}
+
+func BenchmarkRobotListCacheHit(b *testing.B) {
+	robots := make([]*database.ActiveRobot, 2000)
+	for i := range robots {
+		robots[i] = &database.ActiveRobot{UUID: fmt.Sprintf("robot-%d", i), IP: "10.0.0.1", DeviceType: "lamp"}
+	}
+	var c robotListCache
+	load := func() ([]*database.ActiveRobot, error) { return robots, nil }
+	c.get(0, load)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.get(0, load)
+	}
+}
+
+func TestRobotListCacheEncodesOncePerType(t *testing.T) {
+	var c robotListCache
+	load := func() ([]*database.ActiveRobot, error) {
+		return []*database.ActiveRobot{{UUID: "r1"}}, nil
+	}
+	packer := negotiateEncoder("application/msgpack")
+	_, packed, err := c.getAs(packer, 1, load)
+	if err != nil || len(packed) == 0 || packed[0] != 0x91 {
+		t.Fatalf("Expected a msgpack array of one, got % x (%v)", packed, err)
+	}
+	_, again, _ := c.getAs(packer, 1, load)
+	if &again[0] != &packed[0] {
+		t.Error("Expected the msgpack body to be cached")
+	}
+	if _, body, _ := c.get(1, load); !strings.Contains(string(body), `"uuid":"r1"`) {
+		t.Errorf("Expected the JSON body alongside, got %s", body)
+	}
+}
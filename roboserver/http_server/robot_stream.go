@@ -0,0 +1,114 @@
+package http_server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/robot_status"
+	"time"
+)
+
+// GET /robot/stream writes the robot registry as NDJSON, one robot per
+// line, while reading it from PostgreSQL, so a registry of any size costs a
+// constant amount of memory. Lines are flushed in batches; a client that
+// stops reading for streamWriteTimeout is dropped instead of holding the
+// database cursor open.
+
+const (
+	streamBatch        = 100
+	streamWriteTimeout = 30 * time.Second
+	streamBufferSize   = 32 << 10
+)
+
+// robotStreamEntry is one line of GET /robot/stream.
+type robotStreamEntry struct {
+	UUID        string                   `json:"uuid"`
+	DeviceType  string                   `json:"device_type"`
+	Blacklisted bool                     `json:"blacklisted"`
+	CreatedAt   int64                    `json:"created_at"` // Unix seconds
+	Status      robot_status.RobotStatus `json:"status"`     // as tracked by this node
+}
+
+// streamRobotRegistry streams registered robots, oldest first.
+// ?device_type= limits it to one type. Non-admins only get robots they
+// have been granted.
+func (h *HTTPServer_t) streamRobotRegistry(w http.ResponseWriter, r *http.Request) {
+	pg := h.db.Postgres()
+	if pg == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+	visible := func(string) bool { return true }
+	if user := h.currentUser(r); user == nil || !user.IsAdmin() {
+		rds := h.db.Redis()
+		if rds == nil {
+			http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+			return
+		}
+		visible = func(uuid string) bool {
+			ok, _ := rds.CanAccessRobot(r.Context(), user, uuid)
+			return ok
+		}
+	}
+
+	deviceType := r.URL.Query().Get("device_type")
+	n, err := writeRobotStream(w, visible, func(fn func(*database.RobotRecord) error) error {
+		return pg.EachRobot(r.Context(), deviceType, fn)
+	})
+	if err != nil && r.Context().Err() == nil {
+		shared.DebugPrint("Robot stream ended after %d robots: %v", n, err)
+	}
+}
+
+// writeRobotStream writes the robots each yields that pass visible. It
+// returns how many it wrote. Once the response has started, a failure is
+// reported as a final {"error": ...} line, since the status is already sent.
+func writeRobotStream(w http.ResponseWriter, visible func(uuid string) bool, each func(func(*database.RobotRecord) error) error) (int, error) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+	enc := json.NewEncoder(bw)
+	// Not every ResponseWriter supports deadlines (e.g. in tests); without
+	// one the stream is only bounded by the request context.
+	rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return rc.Flush()
+	}
+
+	written := 0
+	err := each(func(robot *database.RobotRecord) error {
+		if !visible(robot.UUID) {
+			return nil
+		}
+		if err := enc.Encode(robotStreamEntry{
+			UUID:        robot.UUID,
+			DeviceType:  robot.DeviceType,
+			Blacklisted: robot.IsBlacklisted,
+			CreatedAt:   robot.CreatedAt.Unix(),
+			Status:      robot_status.Tracker.Get(robot.UUID),
+		}); err != nil {
+			return err
+		}
+		written++
+		if written%streamBatch == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		enc.Encode(map[string]string{"error": "stream aborted"})
+	}
+	if ferr := flush(); err == nil {
+		err = ferr
+	}
+	return written, err
+}
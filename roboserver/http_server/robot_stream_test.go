@@ -0,0 +1,98 @@
+package http_server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+func robotRecords(n int) func(func(*database.RobotRecord) error) error {
+	return func(fn func(*database.RobotRecord) error) error {
+		for i := range n {
+			rec := &database.RobotRecord{UUID: fmt.Sprintf("r%d", i), DeviceType: "rover", CreatedAt: time.Unix(int64(i), 0)}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func readStream(t *testing.T, body string) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("Invalid NDJSON line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestWriteRobotStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	evenOnly := func(uuid string) bool {
+		var i int
+		fmt.Sscanf(uuid, "r%d", &i)
+		return i%2 == 0
+	}
+
+	n, err := writeRobotStream(rec, evenOnly, robotRecords(250))
+	if err != nil || n != 125 {
+		t.Fatalf("Expected 125 robots written, got %d (%v)", n, err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected application/x-ndjson, got %s", ct)
+	}
+	lines := readStream(t, rec.Body.String())
+	if len(lines) != 125 {
+		t.Fatalf("Expected 125 lines, got %d", len(lines))
+	}
+	if lines[1]["uuid"] != "r2" || lines[1]["device_type"] != "rover" || lines[1]["created_at"] != float64(2) {
+		t.Errorf("Unexpected line %v", lines[1])
+	}
+	if _, ok := lines[0]["public_key"]; ok {
+		t.Error("Stream must not include public keys")
+	}
+}
+
+func TestWriteRobotStreamError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	failing := func(fn func(*database.RobotRecord) error) error {
+		if err := robotRecords(3)(fn); err != nil {
+			return err
+		}
+		return errors.New("connection reset")
+	}
+
+	n, err := writeRobotStream(rec, func(string) bool { return true }, failing)
+	if err == nil || n != 3 {
+		t.Fatalf("Expected an error after 3 robots, got %d (%v)", n, err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 (status sent before the error), got %d", rec.Code)
+	}
+	lines := readStream(t, rec.Body.String())
+	if len(lines) != 4 || lines[3]["error"] == nil {
+		t.Errorf("Expected 3 robots and an error line, got %v", lines)
+	}
+}
+
+func TestStreamRobotRegistryNoDatabase(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	rec := httptest.NewRecorder()
+	s.streamRobotRegistry(rec, httptest.NewRequest("GET", "/robot/stream", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
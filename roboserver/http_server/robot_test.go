@@ -0,0 +1,98 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"strings"
+	"testing"
+)
+
+func TestSendVerifiedRobotMessage_RequiresPassword(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("POST", "/robot/lock-1/control", strings.NewReader(`{"message":"unlock"}`))
+	req = addChiURLParam(req, "uuid", "lock-1")
+	rec := httptest.NewRecorder()
+
+	s.sendVerifiedRobotMessage(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without password, got %d", rec.Code)
+	}
+}
+
+func TestSendVerifiedRobotMessage_NilRedis(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("POST", "/robot/lock-1/control", strings.NewReader(`{"password":"secret123","message":"unlock"}`))
+	req = addChiURLParam(req, "uuid", "lock-1")
+	rec := httptest.NewRecorder()
+
+	s.sendVerifiedRobotMessage(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for nil Redis, got %d", rec.Code)
+	}
+}
+
+func TestSendVerifiedRobotMessage_RateLimited(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("POST", "/robot/lock-1/control", strings.NewReader(`{"password":"guess","message":"unlock"}`))
+	req.RemoteAddr = "198.51.100.7:4000"
+	req = addChiURLParam(req, "uuid", "lock-1")
+
+	key := "control:ip:198.51.100.7"
+	defer func() {
+		loginRateLimiter.mu.Lock()
+		delete(loginRateLimiter.attempts, key)
+		loginRateLimiter.mu.Unlock()
+	}()
+	maxAttempts, _ := shared.LoginLimit()
+	for i := 0; i < maxAttempts; i++ {
+		recordLoginAttempt(key)
+	}
+
+	rec := httptest.NewRecorder()
+	s.sendVerifiedRobotMessage(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 after too many failed password checks, got %d", rec.Code)
+	}
+}
+
+func TestGetRobotHandlerData_NilRedis(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	req := httptest.NewRequest("GET", "/robot/env-1/data/history", nil)
+	req = addChiURLParam(req, "key", "history")
+	rec := httptest.NewRecorder()
+
+	s.getRobotHandlerData(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for nil Redis, got %d", rec.Code)
+	}
+}
+
+func TestGetRobotTypes(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	rec := httptest.NewRecorder()
+	s.getRobotTypes(rec, httptest.NewRequest("GET", "/robot/types", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var types []handler_engine.RobotType
+	if err := json.NewDecoder(rec.Body).Decode(&types); err != nil {
+		t.Fatalf("Expected a JSON array: %v", err)
+	}
+	found := false
+	for _, rt := range types {
+		if rt.Type == handler_engine.TemplateType {
+			t.Error("Expected the handler template not to be listed")
+		}
+		found = found || rt.Type == "test_robot"
+	}
+	if !found {
+		t.Errorf("Expected test_robot to be listed, got %+v", types)
+	}
+}
@@ -0,0 +1,103 @@
+package http_server
+
+import (
+	"errors"
+	"net/http"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// postRobotSchedule schedules a message for the robot's handler.
+// Body: {"message": "LOCK", "after": "5m"} or {"message": "...", "at": <Unix ms>},
+// plus an optional "every": "1h" to repeat it until cancelled.
+func (h *HTTPServer_t) postRobotSchedule(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+
+	var body struct {
+		Message string `json:"message"`
+		At      int64  `json:"at"`
+		After   string `json:"after"`
+		Every   string `json:"every"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if (body.At == 0) == (body.After == "") {
+		http.Error(w, "exactly one of at and after is required", http.StatusBadRequest)
+		return
+	}
+	at := time.UnixMilli(body.At)
+	if body.After != "" {
+		d, err := time.ParseDuration(body.After)
+		if err != nil || d < 0 {
+			http.Error(w, "after must be a non-negative duration, e.g. \"5m\"", http.StatusBadRequest)
+			return
+		}
+		at = time.Now().Add(d)
+	}
+	var every time.Duration
+	if body.Every != "" {
+		d, err := time.ParseDuration(body.Every)
+		if err != nil || d <= 0 {
+			http.Error(w, "every must be a positive duration, e.g. \"1h\"", http.StatusBadRequest)
+			return
+		}
+		every = d
+	}
+
+	createdBy := ""
+	if user := h.currentUser(r); user != nil {
+		createdBy = user.Username
+	}
+	msg, err := handler_engine.Scheduler.SendMessageAt(uuid, body.Message, createdBy, at, every)
+	if err != nil {
+		sendScheduleError(w, err)
+		return
+	}
+	shared.DebugPrint("Scheduled message %s for %s at %s", msg.ID, uuid, time.UnixMilli(msg.At).Format(time.RFC3339))
+	sendResponseAsJSON(w, msg, http.StatusCreated)
+}
+
+// getRobotSchedule lists the robot's pending scheduled messages on this
+// node, soonest first.
+func (h *HTTPServer_t) getRobotSchedule(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	sendResponseAsJSON(w, map[string]any{
+		"uuid":      uuid,
+		"scheduled": handler_engine.Scheduler.List(uuid),
+	}, http.StatusOK)
+}
+
+// deleteRobotSchedule cancels one of the robot's scheduled messages.
+func (h *HTTPServer_t) deleteRobotSchedule(w http.ResponseWriter, r *http.Request) {
+	uuid, id := chi.URLParam(r, "uuid"), chi.URLParam(r, "id")
+	if msg, ok := handler_engine.Scheduler.Get(id); !ok || msg.UUID != uuid {
+		http.Error(w, "Scheduled message not found", http.StatusNotFound)
+		return
+	}
+	msg, err := handler_engine.Scheduler.Cancel(id)
+	if err != nil {
+		sendScheduleError(w, err)
+		return
+	}
+	sendResponseAsJSON(w, msg, http.StatusOK)
+}
+
+func sendScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, handler_engine.ErrInvalidSchedule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, handler_engine.ErrScheduleNotFound):
+		http.Error(w, "Scheduled message not found", http.StatusNotFound)
+	case errors.Is(err, shared.ErrPayloadTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, handler_engine.ErrScheduleFull), errors.Is(err, handler_engine.ErrSchedulerStopped):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "Failed to schedule message", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package http_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/handler_engine"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func scheduleRequest(method, uuid, id, body string) *http.Request {
+	r := httptest.NewRequest(method, "/robot/"+uuid+"/schedule", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("uuid", uuid)
+	if id != "" {
+		rctx.URLParams.Add("id", id)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestRobotSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler_engine.Scheduler.Start(ctx, nil, nil)
+	h := newTestServer(&mockDBManager{})
+
+	for _, body := range []string{
+		`{"message":"LOCK"}`,
+		`{"message":"LOCK","after":"5m","at":1718000000000}`,
+		`{"message":"LOCK","after":"soon"}`,
+		`{"message":"LOCK","after":"5m","every":"-1s"}`,
+		`{"message":"LOCK","after":"5m","every":"1ms"}`,
+	} {
+		w := httptest.NewRecorder()
+		h.postRobotSchedule(w, scheduleRequest(http.MethodPost, "door-1", "", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.postRobotSchedule(w, scheduleRequest(http.MethodPost, "door-1", "", `{"message":"LOCK","after":"5m","every":"1h"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var msg handler_engine.ScheduledMessage
+	json.Unmarshal(w.Body.Bytes(), &msg)
+	if msg.ID == "" || msg.UUID != "door-1" || msg.EveryMs != 3600000 {
+		t.Fatalf("Unexpected scheduled message %+v", msg)
+	}
+
+	w = httptest.NewRecorder()
+	h.getRobotSchedule(w, scheduleRequest(http.MethodGet, "door-1", "", ""))
+	if !strings.Contains(w.Body.String(), msg.ID) {
+		t.Errorf("Expected %s in the list, got %s", msg.ID, w.Body)
+	}
+
+	// Another robot's route can't cancel it.
+	w = httptest.NewRecorder()
+	h.deleteRobotSchedule(w, scheduleRequest(http.MethodDelete, "door-2", msg.ID, ""))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another robot, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.deleteRobotSchedule(w, scheduleRequest(http.MethodDelete, "door-1", msg.ID, ""))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if got := handler_engine.Scheduler.List("door-1"); len(got) != 0 {
+		t.Errorf("Expected no pending messages, got %+v", got)
+	}
+}
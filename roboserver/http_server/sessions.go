@@ -0,0 +1,214 @@
+package http_server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"roboserver/database"
+	"roboserver/http_server/http_events"
+	"roboserver/shared"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Login sessions are indexed per user in Redis so users can see where they
+// are logged in and end sessions remotely. A session's ID is its JWT's
+// token_id. Revoking deletes the session key, so the token fails
+// validateSessionFull at once and its SSE streams close within a minute.
+//
+// A session ends at its absolute expiry (auth.session_max_age) or, with
+// auth.session_idle_timeout, once it goes unused that long. Each request
+// slides the idle expiry forward (see touchSession), and SSE streams get a
+// session_expiring event shortly before either deadline.
+
+// sessionTouchInterval is how often a session's last-seen time is written
+// back to Redis while it is in use.
+const sessionTouchInterval = time.Minute
+
+const maxUserAgentLength = 256
+
+// sessionActivity remembers when this node last wrote each session's
+// last-seen time, so busy sessions cost one Redis write per interval.
+type sessionActivity struct {
+	mu        sync.Mutex
+	touched   map[string]time.Time
+	lastSweep time.Time
+}
+
+var sessionTouches = &sessionActivity{touched: make(map[string]time.Time)}
+
+// due reports whether id's last-seen time should be written at now, and if
+// so records the write.
+func (a *sessionActivity) due(id string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.lastSweep) >= 10*sessionTouchInterval {
+		for k, t := range a.touched {
+			if now.Sub(t) >= sessionTouchInterval {
+				delete(a.touched, k)
+			}
+		}
+		a.lastSweep = now
+	}
+	if t, ok := a.touched[id]; ok && now.Sub(t) < sessionTouchInterval {
+		return false
+	}
+	a.touched[id] = now
+	return true
+}
+
+// touchSession updates the session's last-seen time and renews its idle
+// expiry, at most once per sessionTouchInterval.
+func (h *HTTPServer_t) touchSession(r *http.Request, session *shared.Session) {
+	rds := h.db.Redis()
+	now := time.Now()
+	if rds == nil || session.SessionID == "" || !sessionTouches.due(session.SessionID, now) {
+		return
+	}
+	if err := rds.TouchUserSession(r.Context(), session.UserID, session.SessionID, now, shared.AppConfig.Auth.IdleTimeout()); err != nil {
+		shared.DebugPrint("Failed to update session activity for %s: %v", session.UserID, err)
+	}
+}
+
+// sessionTimeouts returns a new login session's absolute lifetime, capped by
+// database.redis.user_session_ttl, and its idle timeout (0 = none).
+func sessionTimeouts() (maxAge, idle time.Duration) {
+	maxAge = min(shared.AppConfig.Auth.MaxSessionAge(), shared.AppConfig.Database.Redis.UserTTL())
+	return maxAge, min(shared.AppConfig.Auth.IdleTimeout(), maxAge)
+}
+
+// newUserSession describes a session being created by a login request.
+func newUserSession(r *http.Request, username, id string, maxAge, idle time.Duration) *database.UserSession {
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	now := time.Now()
+	s := &database.UserSession{
+		ID:        id,
+		Username:  username,
+		IP:        ip,
+		UserAgent: ua,
+		CreatedAt: now.Unix(),
+		LastSeen:  now.Unix(),
+		ExpiresAt: now.Add(maxAge).Unix(),
+	}
+	if idle > 0 {
+		s.IdleExpiresAt = now.Add(idle).Unix()
+	}
+	return s
+}
+
+// sessionState reports whether token still belongs to session and when the
+// session will end, for the checks of its SSE streams.
+func (h *HTTPServer_t) sessionState(ctx context.Context, token string, session *shared.Session) http_events.SessionState {
+	rds := h.db.Redis()
+	if token == "" || rds == nil {
+		return http_events.SessionState{}
+	}
+	username, err := rds.GetUserSession(ctx, token)
+	if err != nil || username != session.UserID {
+		return http_events.SessionState{}
+	}
+	state := http_events.SessionState{Valid: true}
+	if info, err := rds.GetUserSessionInfo(ctx, session.UserID, session.SessionID); err == nil && info != nil {
+		state.ExpiresAt, state.Reason = info.Deadline()
+	}
+	return state
+}
+
+// sessionView is a session as listed to its user.
+type sessionView struct {
+	*database.UserSession
+	Current bool `json:"current"` // the session making the request
+}
+
+// sessionsUser returns whose sessions a request manages: the caller's, or
+// with ?user= another user's for admins. It writes the error response and
+// returns "" when the request may not proceed.
+func (h *HTTPServer_t) sessionsUser(w http.ResponseWriter, r *http.Request) string {
+	if !requireLoginSession(w, r) {
+		return ""
+	}
+	session := sessionFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return ""
+	}
+	if h.db.Redis() == nil {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return ""
+	}
+	if user := r.URL.Query().Get("user"); user != "" && user != session.UserID {
+		if !h.requireAdmin(w, r) {
+			return ""
+		}
+		return user
+	}
+	return session.UserID
+}
+
+// listSessions returns the user's login sessions, oldest first.
+func (h *HTTPServer_t) listSessions(w http.ResponseWriter, r *http.Request) {
+	username := h.sessionsUser(w, r)
+	if username == "" {
+		return
+	}
+	sessions, err := h.db.Redis().ListUserSessions(r.Context(), username)
+	if err != nil {
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+	current := sessionFromRequest(r).SessionID
+	views := make([]sessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, sessionView{UserSession: s, Current: s.ID == current})
+	}
+	sendResponseAsJSON(w, views, http.StatusOK)
+}
+
+// revokeSession ends one of the user's sessions.
+func (h *HTTPServer_t) revokeSession(w http.ResponseWriter, r *http.Request) {
+	username := h.sessionsUser(w, r)
+	if username == "" {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	found, err := h.db.Redis().RevokeUserSession(r.Context(), username, id)
+	if err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	shared.DebugPrint("AUTH: %s revoked session %s of %s", sessionFromRequest(r).UserID, id, username)
+	sendResponseAsJSON(w, map[string]string{"status": "revoked", "id": id}, http.StatusOK)
+}
+
+// revokeOtherSessions ends all of the user's sessions except the caller's
+// ("log out everywhere else").
+func (h *HTTPServer_t) revokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	username := h.sessionsUser(w, r)
+	if username == "" {
+		return
+	}
+	keep := ""
+	if session := sessionFromRequest(r); session.UserID == username {
+		keep = session.SessionID
+	}
+	revoked, err := h.db.Redis().RevokeOtherUserSessions(r.Context(), username, keep)
+	if err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	shared.DebugPrint("AUTH: %s revoked %d session(s) of %s", sessionFromRequest(r).UserID, len(revoked), username)
+	sendResponseAsJSON(w, map[string]any{"status": "revoked", "revoked": revoked}, http.StatusOK)
+}
@@ -0,0 +1,83 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"testing"
+	"time"
+)
+
+func TestSessionActivityDue(t *testing.T) {
+	a := &sessionActivity{touched: make(map[string]time.Time)}
+	now := time.Now()
+
+	if !a.due("s1", now) {
+		t.Fatal("First activity should be written")
+	}
+	if a.due("s1", now.Add(30*time.Second)) {
+		t.Error("Activity within the interval should not be written again")
+	}
+	if !a.due("s2", now.Add(30*time.Second)) {
+		t.Error("Sessions are throttled independently")
+	}
+	if !a.due("s1", now.Add(sessionTouchInterval)) {
+		t.Error("Activity after the interval should be written")
+	}
+
+	a.due("s3", now.Add(20*sessionTouchInterval))
+	if _, ok := a.touched["s2"]; ok || len(a.touched) != 1 {
+		t.Errorf("Old entries should be swept, have %v", a.touched)
+	}
+}
+
+func TestSessionEndpointsRequireLoginSession(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	for _, tc := range []struct {
+		name    string
+		session *shared.Session
+		want    int
+	}{
+		{"no session", nil, http.StatusUnauthorized},
+		{"api key", &shared.Session{UserID: "alice", SessionID: apiKeySessionPrefix + "k1"}, http.StatusForbidden},
+		{"no redis", &shared.Session{UserID: "alice", SessionID: "tok-1"}, http.StatusServiceUnavailable},
+	} {
+		req := httptest.NewRequest("GET", "/auth/sessions", nil)
+		if tc.session != nil {
+			req = withSession(req, tc.session)
+		}
+		rec := httptest.NewRecorder()
+		s.listSessions(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestNewUserSession(t *testing.T) {
+	req := httptest.NewRequest("POST", "/auth/login", nil)
+	req.RemoteAddr = "10.1.2.3:5555"
+	req.Header.Set("User-Agent", string(make([]byte, 1000)))
+
+	s := newUserSession(req, "alice", "tok-1", time.Hour, 0)
+	if s.IP != "10.1.2.3" || s.ID != "tok-1" || s.Username != "alice" {
+		t.Errorf("Unexpected session %+v", s)
+	}
+	if len(s.UserAgent) != maxUserAgentLength {
+		t.Errorf("User agent should be truncated to %d, got %d", maxUserAgentLength, len(s.UserAgent))
+	}
+	if s.ExpiresAt-s.CreatedAt != 3600 || s.LastSeen != s.CreatedAt || s.IdleExpiresAt != 0 {
+		t.Errorf("Unexpected times %+v", s)
+	}
+	if at, reason := s.Deadline(); at.Unix() != s.ExpiresAt || reason != "absolute" {
+		t.Errorf("Expected the absolute deadline, got %v %s", at, reason)
+	}
+
+	s = newUserSession(req, "alice", "tok-2", time.Hour, 10*time.Minute)
+	if s.IdleExpiresAt-s.CreatedAt != 600 {
+		t.Errorf("Expected idle expiry 10m after creation, got %+v", s)
+	}
+	if at, reason := s.Deadline(); at.Unix() != s.IdleExpiresAt || reason != "idle" {
+		t.Errorf("Expected the idle deadline, got %v %s", at, reason)
+	}
+}
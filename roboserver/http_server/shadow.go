@@ -0,0 +1,85 @@
+package http_server
+
+import (
+	"errors"
+	"net/http"
+	"roboserver/handler_engine"
+	"roboserver/shared/shadow"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getRobotShadow returns a robot's desired and reported state and the
+// delta between them.
+func (h *HTTPServer_t) getRobotShadow(w http.ResponseWriter, r *http.Request) {
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	s, err := rds.GetShadow(r.Context(), chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Failed to load shadow", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, handler_engine.ShadowDocument(s), http.StatusOK)
+}
+
+// patchRobotShadow merges into a robot's desired state; the delta reaches
+// the robot now if it is connected, otherwise when it reconnects.
+// Body: {"desired": {"target_c": 21.5, "fan": null}, "version": 7}
+// version is optional; when set, a shadow changed since gives 409.
+func (h *HTTPServer_t) patchRobotShadow(w http.ResponseWriter, r *http.Request) {
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body struct {
+		Desired map[string]any `json:"desired"`
+		Version int64          `json:"version"`
+	}
+	if err := parseJSONRequest(r, &body); err != nil {
+		sendBodyError(w, err)
+		return
+	}
+	if err := shadow.Validate(body.Desired); err != nil {
+		http.Error(w, "desired: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s, err := handler_engine.UpdateDesired(r.Context(), h.bus, rds, chi.URLParam(r, "uuid"), body.Desired, body.Version)
+	if errors.Is(err, shadow.ErrVersionConflict) {
+		http.Error(w, "Shadow changed since that version; reload it and retry", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update shadow", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, handler_engine.ShadowDocument(s), http.StatusOK)
+}
+
+// deleteRobotShadow forgets a robot's desired and reported state (admin
+// only).
+func (h *HTTPServer_t) deleteRobotShadow(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	found, err := handler_engine.DeleteShadow(r.Context(), h.bus, rds, chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Failed to delete shadow", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Robot has no shadow", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, map[string]string{"status": "deleted"}, http.StatusOK)
+}
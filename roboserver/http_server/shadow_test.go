@@ -0,0 +1,27 @@
+package http_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRobotShadow_RequiresRedis(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		method  string
+		body    string
+	}{
+		{s.getRobotShadow, "GET", ""},
+		{s.patchRobotShadow, "PATCH", `{"desired":{"target_c":21.5}}`},
+	} {
+		req := httptest.NewRequest(tc.method, "/robot/thermo-1/shadow", strings.NewReader(tc.body))
+		rec := httptest.NewRecorder()
+		tc.handler(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected 503 without Redis, got %d", tc.method, rec.Code)
+		}
+	}
+}
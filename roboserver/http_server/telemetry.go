@@ -0,0 +1,62 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/shared/telemetry"
+	"roboserver/statediff"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getRobotTelemetry returns the robot's stored DATA envelopes, newest first.
+// ?type=env keeps one envelope type; ?limit=N (default 100) caps the result.
+func (h *HTTPServer_t) getRobotTelemetry(w http.ResponseWriter, r *http.Request) {
+	rds := h.db.Redis()
+	if rds == nil {
+		http.Error(w, "Cache not available", http.StatusServiceUnavailable)
+		return
+	}
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	envs, err := rds.GetTelemetry(r.Context(), chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Failed to get telemetry", http.StatusInternalServerError)
+		return
+	}
+	sendResponseAsJSON(w, filterTelemetry(envs, r.URL.Query().Get("type"), limit), http.StatusOK)
+}
+
+// filterTelemetry keeps up to limit envelopes of envType ("" = any type).
+func filterTelemetry(envs []telemetry.Envelope, envType string, limit int) []telemetry.Envelope {
+	out := make([]telemetry.Envelope, 0, min(len(envs), limit))
+	for _, env := range envs {
+		if len(out) == limit {
+			break
+		}
+		if envType == "" || env.Type == envType {
+			out = append(out, env)
+		}
+	}
+	return out
+}
+
+// getRobotState returns the robot's current state as flattened fields and
+// the seq of the last robot.{uuid}.changed event applied to it. A client
+// loads it once, then applies changes with a greater seq.
+func (h *HTTPServer_t) getRobotState(w http.ResponseWriter, r *http.Request) {
+	state, ok := statediff.Engine.Snapshot(chi.URLParam(r, "uuid"))
+	if !ok {
+		http.Error(w, "No state recorded for robot", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, state, http.StatusOK)
+}
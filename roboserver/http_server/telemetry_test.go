@@ -0,0 +1,44 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared/telemetry"
+	"roboserver/statediff"
+	"testing"
+)
+
+func TestFilterTelemetry(t *testing.T) {
+	envs := []telemetry.Envelope{
+		{Type: "env", Seq: 4}, {Type: "battery", Seq: 3}, {Type: "env", Seq: 2}, {Type: "env", Seq: 1},
+	}
+	got := filterTelemetry(envs, "env", 2)
+	if len(got) != 2 || got[0].Seq != 4 || got[1].Seq != 2 {
+		t.Errorf("Expected the two newest env envelopes, got %+v", got)
+	}
+	if got := filterTelemetry(envs, "", 10); len(got) != 4 {
+		t.Errorf("Expected every envelope without a type filter, got %d", len(got))
+	}
+}
+
+func TestGetRobotState(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	statediff.Engine.Update("state-r1", "status", "online")
+	defer statediff.Engine.Forget("state-r1")
+
+	req := addChiURLParam(httptest.NewRequest("GET", "/robot/state-r1/state", nil), "uuid", "state-r1")
+	w := httptest.NewRecorder()
+	h.getRobotState(w, req)
+	var state statediff.State
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &state) != nil || state.Fields["status"] != "online" {
+		t.Fatalf("Expected the robot's state, got %d %s", w.Code, w.Body.String())
+	}
+
+	req = addChiURLParam(httptest.NewRequest("GET", "/robot/unknown/state", nil), "uuid", "unknown")
+	w = httptest.NewRecorder()
+	h.getRobotState(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a robot without state, got %d", w.Code)
+	}
+}
@@ -1,16 +1,16 @@
 package http_server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"roboserver/handler_engine"
 	"roboserver/shared"
+	"time"
 )
 
-// maxRequestBodySize limits JSON request bodies to 1 MB to prevent memory
-// exhaustion from oversized payloads. Individual endpoints can override this
-// by calling http.MaxBytesReader directly before decoding.
-const maxRequestBodySize = 1 << 20 // 1 MB
-
 func sendResponseAsJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -34,9 +34,133 @@ func sendJSONResponse(w http.ResponseWriter, data_json []byte, status int) {
 	w.Write(data_json)
 }
 
+// sendRegistrationError writes a 400 with the error's code so clients can
+// tell an invalid UUID from an unknown device type without parsing text.
+func sendRegistrationError(w http.ResponseWriter, err error) {
+	var regErr *handler_engine.RegistrationError
+	if !errors.As(err, &regErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := map[string]any{"error": regErr.Reason, "code": regErr.Code}
+	if regErr.ValidTypes != nil {
+		resp["valid_types"] = regErr.ValidTypes
+	}
+	sendResponseAsJSON(w, resp, http.StatusBadRequest)
+}
+
+// sendToHandler delivers an API message to a handler's stdin, waiting up to
+// handler_engine.DefaultSendTimeout (or until the client goes away) for a
+// busy handler to accept it.
+func sendToHandler(ctx context.Context, hp *handler_engine.HandlerProcess, message, actor string) error {
+	return sendCommand(ctx, hp, message, actor, 0)
+}
+
+// maxCommandLock caps the command lock an API caller can request.
+const maxCommandLock = 10 * time.Minute
+
+// sendCommand is sendToHandler for actuation requests that may hold the
+// handler's command lock for up to lock (0 = only in serialization mode).
+// A command sent while another holds it fails with ErrRobotBusy.
+func sendCommand(ctx context.Context, hp *handler_engine.HandlerProcess, message, actor string, lock time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, handler_engine.DefaultSendTimeout)
+	defer cancel()
+	return hp.SendCommandContext(ctx, message, actor, lock)
+}
+
+// parseCommandLock reads the optional "lock" duration of a message request
+// body ("" = no lock).
+func parseCommandLock(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d > maxCommandLock {
+		return 0, fmt.Errorf("lock must be a duration between 1ms and %v, e.g. \"30s\"", maxCommandLock)
+	}
+	return d, nil
+}
+
+// sendHandlerError maps a failed sendToHandler to an HTTP status.
+func sendHandlerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.Canceled) {
+		return // Client went away; nothing useful to send
+	}
+	var notAllowed *handler_engine.CommandNotAllowedError
+	if errors.As(err, &notAllowed) {
+		sendResponseAsJSON(w, map[string]any{
+			"error":       notAllowed.Error(),
+			"command":     notAllowed.Command,
+			"device_type": notAllowed.DeviceType,
+			"allowed":     notAllowed.Allowed,
+		}, http.StatusForbidden)
+		return
+	}
+	status, msg := handlerErrorStatus(err)
+	http.Error(w, msg, status)
+}
+
+// handlerErrorStatus returns the HTTP status and message for a failed
+// sendToHandler.
+func handlerErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, handler_engine.ErrHandlerStopped):
+		return http.StatusNotFound, "No handler running for this robot"
+	case errors.Is(err, handler_engine.ErrRobotBusy):
+		return http.StatusConflict, "Robot is busy with another command"
+	case errors.Is(err, handler_engine.ErrRobotOffline):
+		return http.StatusConflict, "Robot is not connected"
+	case errors.Is(err, shared.ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, handler_engine.ErrInvalidCommand):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, handler_engine.ErrQueueFull), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, "Handler is not accepting messages"
+	default:
+		return http.StatusInternalServerError, "Failed to send message"
+	}
+}
+
+// parseJSONRequest decodes the request body into v. A body cut off by
+// BodySizeLimitMiddleware comes back as a *shared.PayloadTooLargeError.
 func parseJSONRequest(r *http.Request, v interface{}) error {
 	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &shared.PayloadTooLargeError{Source: "http", Size: -1, Limit: int(tooLarge.Limit)}
+		}
 		return err
 	}
 	return nil
 }
+
+// robotErrorStatus maps a failed robot operation to an HTTP status:
+// 401 for credentials that don't check out, 403 for a blacklisted robot,
+// 409 for a replayed heartbeat, 400 for other robot errors and 500 for
+// errors not tied to a robot (storage failures).
+func robotErrorStatus(err error) int {
+	var re *shared.RobotError
+	switch {
+	case errors.Is(err, shared.ErrRobotNotFound),
+		errors.Is(err, shared.ErrInvalidSignature),
+		errors.Is(err, shared.ErrRobotMismatch):
+		return http.StatusUnauthorized
+	case errors.Is(err, shared.ErrRobotBlacklisted):
+		return http.StatusForbidden
+	case errors.Is(err, shared.ErrStaleHeartbeat):
+		return http.StatusConflict
+	case errors.As(err, &re):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// sendBodyError answers a failed parseJSONRequest: 413 for an oversized
+// body, otherwise 400 "Invalid request body".
+func sendBodyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, shared.ErrPayloadTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+}
@@ -2,11 +2,14 @@ package http_server
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"roboserver/handler_engine"
 	"roboserver/shared"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSendResponseAsJSON(t *testing.T) {
@@ -73,6 +76,22 @@ func TestParseJSONRequest_Invalid(t *testing.T) {
 	}
 }
 
+func TestParseJSONRequest_TooLarge(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "`+strings.Repeat("x", 64)+`"}`))
+	req.Body = http.MaxBytesReader(rec, req.Body, 16)
+
+	var result struct{}
+	err := parseJSONRequest(req, &result)
+	if !errors.Is(err, shared.ErrPayloadTooLarge) {
+		t.Fatalf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+	sendBodyError(rec, err)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", rec.Code)
+	}
+}
+
 func TestSessionValidationMiddleware_NoAuth(t *testing.T) {
 	s := newTestServer(&mockDBManager{})
 
@@ -127,3 +146,38 @@ func TestCORSMiddleware_DisallowedOrigin(t *testing.T) {
 		t.Errorf("Expected no ACAO header for disallowed origin, got %s", acao)
 	}
 }
+
+func TestParseCommandLock(t *testing.T) {
+	if d, err := parseCommandLock(""); err != nil || d != 0 {
+		t.Errorf("Expected no lock for an empty value, got %v %v", d, err)
+	}
+	if d, err := parseCommandLock("30s"); err != nil || d != 30*time.Second {
+		t.Errorf("Expected 30s, got %v %v", d, err)
+	}
+	for _, bad := range []string{"soon", "-1s", "1h"} {
+		if _, err := parseCommandLock(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestHandlerErrorStatusBusy(t *testing.T) {
+	if status, _ := handlerErrorStatus(handler_engine.ErrRobotBusy); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a busy robot, got %d", status)
+	}
+}
+
+func TestSendHandlerErrorListsAllowedCommands(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sendHandlerError(rec, &handler_engine.CommandNotAllowedError{DeviceType: "door", Command: "reboot", Allowed: []string{"lock", "unlock"}})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d", rec.Code)
+	}
+	var body struct {
+		Command string   `json:"command"`
+		Allowed []string `json:"allowed"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Command != "reboot" || len(body.Allowed) != 2 {
+		t.Errorf("Unexpected body %s (%v)", rec.Body.String(), err)
+	}
+}
@@ -0,0 +1,140 @@
+package http_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"roboserver/comms"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// WebRTC signaling relay; see handler_engine/webrtc.go for the protocol.
+// The browser posts its offer and gets the robot's answer in the response.
+// Trickled robot candidates are delivered on webrtc.{session}.candidate via
+// /events/subscribe. A 504 with "fallback": "relay" means the robot has no
+// WebRTC support (or is unreachable) and the client should use /message.
+
+// postWebRTCOffer forwards an SDP offer to the robot and waits for its answer.
+// Body: {"sdp": "v=0..."}
+func (h *HTTPServer_t) postWebRTCOffer(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+
+	var body struct {
+		SDP string `json:"sdp"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SDP == "" {
+		http.Error(w, "sdp is required", http.StatusBadRequest)
+		return
+	}
+	if h.bus == nil {
+		http.Error(w, "Event bus not available", http.StatusServiceUnavailable)
+		return
+	}
+	hp, ok := handler_engine.HandlerManager.Get(uuid)
+	if !ok {
+		http.Error(w, "No handler running for this robot", http.StatusNotFound)
+		return
+	}
+
+	session := handler_engine.NewSignalSession()
+
+	offer := handler_engine.Signal{Kind: handler_engine.SignalOffer, Session: session, SDP: body.SDP}
+	topic := handler_engine.SignalTopic(session, handler_engine.SignalAnswer)
+	answer, err := comms.Await(r.Context(), h.bus, topic, shared.AppConfig.Timeouts.WebRTCAnswerTimeout(),
+		func() error { return hp.SendSignal(offer) },
+		func(data any) bool {
+			sig, ok := decodeSignal(data)
+			return ok && sig.UUID == uuid
+		})
+	switch {
+	case r.Context().Err() != nil:
+		return
+	case errors.Is(err, comms.ErrRequestTimeout):
+		sendWebRTCFallback(w, session, "Robot did not answer the offer")
+		return
+	case err != nil:
+		sendWebRTCFallback(w, session, "Robot is not connected")
+		return
+	}
+
+	sig, _ := decodeSignal(answer)
+	sendResponseAsJSON(w, map[string]string{
+		"session": session,
+		"uuid":    uuid,
+		"sdp":     sig.SDP,
+	}, http.StatusOK)
+}
+
+// postWebRTCCandidate forwards a browser ICE candidate to the robot.
+// Body: {"candidate": {"candidate": "...", "sdpMid": "0", "sdpMLineIndex": 0}}
+func (h *HTTPServer_t) postWebRTCCandidate(w http.ResponseWriter, r *http.Request) {
+	h.forwardWebRTCSignal(w, r, handler_engine.SignalCandidate)
+}
+
+// deleteWebRTCSession tells the robot to tear down a peer connection.
+func (h *HTTPServer_t) deleteWebRTCSession(w http.ResponseWriter, r *http.Request) {
+	h.forwardWebRTCSignal(w, r, handler_engine.SignalClose)
+}
+
+func (h *HTTPServer_t) forwardWebRTCSignal(w http.ResponseWriter, r *http.Request, kind string) {
+	uuid := chi.URLParam(r, "uuid")
+	session := chi.URLParam(r, "session")
+	if !handler_engine.IsValidSignalSession(session) {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	sig := handler_engine.Signal{Kind: kind, Session: session}
+	if kind == handler_engine.SignalCandidate {
+		var body struct {
+			Candidate json.RawMessage `json:"candidate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Candidate) == 0 {
+			http.Error(w, "candidate is required", http.StatusBadRequest)
+			return
+		}
+		sig.Candidate = body.Candidate
+	}
+
+	hp, ok := handler_engine.HandlerManager.Get(uuid)
+	if !ok {
+		http.Error(w, "No handler running for this robot", http.StatusNotFound)
+		return
+	}
+	if err := hp.SendSignal(sig); err != nil {
+		http.Error(w, "Robot is not connected", http.StatusConflict)
+		return
+	}
+	sendResponseAsJSON(w, map[string]string{"status": "sent", "session": session}, http.StatusOK)
+}
+
+func sendWebRTCFallback(w http.ResponseWriter, session, reason string) {
+	sendResponseAsJSON(w, map[string]string{
+		"error":    reason,
+		"session":  session,
+		"fallback": "relay",
+	}, http.StatusGatewayTimeout)
+}
+
+// decodeSignal accepts a signal published in-process or decoded from Redis
+// pub/sub (a generic map).
+func decodeSignal(data any) (*handler_engine.Signal, bool) {
+	switch v := data.(type) {
+	case *handler_engine.Signal:
+		return v, true
+	case handler_engine.Signal:
+		return &v, true
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, false
+	}
+	var sig handler_engine.Signal
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return nil, false
+	}
+	return &sig, true
+}
@@ -0,0 +1,129 @@
+package http_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/comms"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/event_bus"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestPostWebRTCOffer_Validation(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	req := httptest.NewRequest("POST", "/robot/r1/webrtc/offer", strings.NewReader(`{}`))
+	req = addChiURLParam(req, "uuid", "r1")
+	rec := httptest.NewRecorder()
+	s.postWebRTCOffer(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without sdp, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/robot/r1/webrtc/offer", strings.NewReader(`{"sdp": "v=0"}`))
+	req = addChiURLParam(req, "uuid", "r1")
+	rec = httptest.NewRecorder()
+	s.postWebRTCOffer(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an event bus, got %d", rec.Code)
+	}
+}
+
+func TestPostWebRTCOffer_RelaysAnswer(t *testing.T) {
+	bus := comms.NewLocalBus(event_bus.NewEventBus(), nil)
+	s := newTestServer(&mockDBManager{})
+	s.bus = bus
+
+	// A robot that answers every offer on its connection
+	hp := &handler_engine.HandlerProcess{UUID: "webrtc-robot"}
+	hp.RobotSend = func(data []byte) error {
+		var offer handler_engine.Signal
+		if err := json.Unmarshal(data, &offer); err != nil || offer.Kind != handler_engine.SignalOffer {
+			t.Errorf("Expected an offer signal, got %s", data)
+			return nil
+		}
+		sig, _ := handler_engine.ParseRobotSignal(`WEBRTC {"kind":"answer","session":"`+offer.Session+`","sdp":"answer-sdp"}`, "webrtc-robot")
+		go bus.PublishEvent(handler_engine.SignalTopic(offer.Session, sig.Kind), sig)
+		return nil
+	}
+	handler_engine.HandlerManager.Register(hp)
+	defer handler_engine.HandlerManager.Unregister("webrtc-robot")
+
+	req := httptest.NewRequest("POST", "/robot/webrtc-robot/webrtc/offer", strings.NewReader(`{"sdp": "offer-sdp"}`))
+	req = addChiURLParam(req, "uuid", "webrtc-robot")
+	rec := httptest.NewRecorder()
+	s.postWebRTCOffer(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]string
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp["sdp"] != "answer-sdp" || !handler_engine.IsValidSignalSession(resp["session"]) {
+		t.Errorf("Unexpected answer response: %v", resp)
+	}
+}
+
+func TestPostWebRTCOffer_FallsBackWithoutAnswer(t *testing.T) {
+	saved := shared.AppConfig.Timeouts.WebRTCAnswer
+	defer func() { shared.AppConfig.Timeouts.WebRTCAnswer = saved }()
+	shared.AppConfig.Timeouts.WebRTCAnswer = "20ms"
+
+	s := newTestServer(&mockDBManager{})
+	s.bus = comms.NewLocalBus(event_bus.NewEventBus(), nil)
+
+	hp := &handler_engine.HandlerProcess{UUID: "plain-robot", RobotSend: func([]byte) error { return nil }}
+	handler_engine.HandlerManager.Register(hp)
+	defer handler_engine.HandlerManager.Unregister("plain-robot")
+
+	req := httptest.NewRequest("POST", "/robot/plain-robot/webrtc/offer", strings.NewReader(`{"sdp": "offer-sdp"}`))
+	req = addChiURLParam(req, "uuid", "plain-robot")
+	rec := httptest.NewRecorder()
+	s.postWebRTCOffer(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected 504, got %d", rec.Code)
+	}
+	var resp map[string]string
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp["fallback"] != "relay" {
+		t.Errorf("Expected relay fallback hint, got %v", resp)
+	}
+}
+
+func TestPostWebRTCCandidate_Validation(t *testing.T) {
+	s := newTestServer(&mockDBManager{})
+
+	tests := []struct {
+		name     string
+		session  string
+		body     string
+		expected int
+	}{
+		{"bad session", "nope", `{"candidate": {}}`, http.StatusBadRequest},
+		{"missing candidate", strings.Repeat("a", 32), `{}`, http.StatusBadRequest},
+		{"no handler", strings.Repeat("a", 32), `{"candidate": {"candidate": "x"}}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/robot/r1/webrtc/"+tt.session+"/candidate", strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("uuid", "no-such-robot")
+			rctx.URLParams.Add("session", tt.session)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rec := httptest.NewRecorder()
+
+			s.postWebRTCCandidate(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package http_server
+
+import (
+	"net/http"
+	"roboserver/zones"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getZones returns the current value of every zone aggregate, ordered by
+// zone and name: {aggregates: [...]}.
+func (h *HTTPServer_t) getZones(w http.ResponseWriter, r *http.Request) {
+	if !zones.Engine.Enabled() {
+		http.Error(w, "No zone aggregates configured", http.StatusNotFound)
+		return
+	}
+	values := zones.Engine.Values("")
+	if values == nil {
+		values = []zones.Value{}
+	}
+	sendResponseAsJSON(w, map[string]any{"aggregates": values}, http.StatusOK)
+}
+
+// getZone returns the aggregates of one zone: {zone, aggregates}. 404 if
+// the server knows no robot in the zone.
+func (h *HTTPServer_t) getZone(w http.ResponseWriter, r *http.Request) {
+	zone := chi.URLParam(r, "zone")
+	values := zones.Engine.Values(zone)
+	if len(values) == 0 {
+		http.Error(w, "No aggregates for zone", http.StatusNotFound)
+		return
+	}
+	sendResponseAsJSON(w, map[string]any{"zone": zone, "aggregates": values}, http.StatusOK)
+}
@@ -0,0 +1,46 @@
+package http_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"roboserver/statediff"
+	"roboserver/zones"
+	"testing"
+	"time"
+)
+
+func TestGetZones(t *testing.T) {
+	h := newTestServer(&mockDBManager{})
+	w := httptest.NewRecorder()
+	h.getZones(w, httptest.NewRequest("GET", "/zones", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without configured aggregates, got %d", w.Code)
+	}
+
+	aggs, _ := zones.Compile([]shared.ZoneAggregate{{Name: "motion", Field: "telemetry.motion.detected", Op: zones.OpAny}})
+	prev := zones.Engine
+	zones.Engine = zones.NewEngine(aggs)
+	defer func() { zones.Engine = prev }()
+	now := time.Now()
+	zones.Engine.SetZone("pir-1", "hall", now)
+	zones.Engine.Apply(statediff.Change{UUID: "pir-1", Changed: map[string]any{"telemetry.motion.detected": true}}, now)
+
+	req := addChiURLParam(httptest.NewRequest("GET", "/zones/hall", nil), "zone", "hall")
+	w = httptest.NewRecorder()
+	h.getZone(w, req)
+	var body struct {
+		Aggregates []zones.Value `json:"aggregates"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil || len(body.Aggregates) != 1 || body.Aggregates[0].Value != true {
+		t.Fatalf("Expected the hall's motion aggregate, got %d %s", w.Code, w.Body.String())
+	}
+
+	req = addChiURLParam(httptest.NewRequest("GET", "/zones/attic", nil), "zone", "attic")
+	w = httptest.NewRecorder()
+	h.getZone(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown zone, got %d", w.Code)
+	}
+}
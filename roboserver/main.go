@@ -5,17 +5,35 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"roboserver/automation"
+	"roboserver/backup"
+	"roboserver/cluster"
 	"roboserver/comms"
 	"roboserver/database"
+	"roboserver/energy"
+	"roboserver/exporter"
 	"roboserver/handler_engine"
 	"roboserver/http_server"
 	"roboserver/mqtt_server"
+	"roboserver/presence"
+	"roboserver/push"
 	"roboserver/shared"
+	"roboserver/shared/autoaccept"
 	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"roboserver/shared/jobs"
+	"roboserver/shared/lifecycle"
+	"roboserver/shared/metrics"
+	"roboserver/shared/tokencrypt"
+	"roboserver/shared/tracing"
 	"roboserver/shared/utils"
+	"roboserver/statediff"
 	"roboserver/tcp_server"
 	"roboserver/terminal"
 	"roboserver/udp_server"
+	"roboserver/zones"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
@@ -35,8 +53,36 @@ func main() {
 		panic(fmt.Sprintf("Error loading configuration: %v", err))
 	}
 
+	// Stored robot session tokens are encrypted when a key is configured.
+	tokenKey, err := shared.AppConfig.Auth.TokenEncryptionKey()
+	if err == nil {
+		err = tokencrypt.Configure(tokenKey, shared.AppConfig.Auth.TokenOldKeys)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Error loading token encryption key: %v", err))
+	}
+
+	// Spans are exported to the OTLP collector when tracing.enabled is set.
+	if err := tracing.Start(); err != nil {
+		panic(fmt.Sprintf("Error starting tracing: %v", err))
+	}
+
+	// "roboserver migrate [status]" manages the PostgreSQL schema and exits.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	// "roboserver backup [<file>]" and "roboserver restore [--config] <file>"
+	// write or restore an encrypted archive and exit.
+	if len(os.Args) > 1 && (os.Args[1] == "backup" || os.Args[1] == "restore") {
+		os.Exit(runBackup(os.Args[1], os.Args[2:]))
+	}
+
 	var wg sync.WaitGroup
 
+	// Device types come from handlers.base_path and handlers.plugin_dirs.
+	handler_engine.LogHandlerTypes()
+
 	shared.DebugPrint("Server is running on the following IPs:")
 	localIPs := utils.GetLocalIPs()
 	for _, ip := range localIPs {
@@ -48,74 +94,194 @@ func main() {
 	if eventBus == nil {
 		panic("Failed to initialize event bus")
 	}
+	for _, eventType := range shared.AppConfig.Events.Ordered {
+		eventBus.SetOrdered(eventType, true)
+	}
+	eventBus.SetLimit(shared.AppConfig.Events.MaxInFlight)
+	for eventType, limit := range shared.AppConfig.Events.TopicLimits {
+		eventBus.SetTopicLimit(eventType, limit)
+	}
+	if drop := shared.AppConfig.Events.Drop; len(drop) > 0 {
+		dropped := events.Matcher(drop)
+		eventBus.UsePublish(event_bus.FilterEvents(func(e event_bus.Event) bool { return !dropped(e.GetType()) }))
+	}
+	if slow := shared.AppConfig.Events.SlowHandlerThreshold(); slow > 0 {
+		eventBus.UseHandler(event_bus.LogSlowHandlers(slow))
+	}
+	if tracing.Enabled() {
+		eventBus.UseHandler(event_bus.TraceHandlers())
+	}
+
+	go metrics.Run(ctx, time.Minute, shared.AppConfig.Metrics.TimelineMinutes, handler_engine.HandlerManager.Count)
+
+	// Components stop in reverse dependency order on shutdown, each with its
+	// own timeout, so handlers flush and servers drain before the DB closes.
+	lc := lifecycle.NewLifecycle(shared.AppConfig.Timeouts.ShutdownTimeout())
+
+	// Tracing stops last, flushing the spans of everything before it.
+	lc.Register(lifecycle.Component{Name: "tracing", Stop: tracing.Stop})
 
-	// Initialize database manager (PostgreSQL + Redis)
-	dbManager, err := database.Start(ctx)
+	// Initialize database manager (PostgreSQL + Redis). It gets its own context
+	// so cancelling the root context doesn't pull it out from under servers
+	// that are still draining.
+	dbManager, err := database.StartJournaled(context.Background())
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize databases: %v", err))
 	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		if dbManager != nil {
+	lc.Register(lifecycle.Component{
+		Name:      "database",
+		DependsOn: []string{"tracing"},
+		Stop: func(context.Context) error {
 			dbManager.Stop()
-		}
-	}()
+			return nil
+		},
+	})
 
 	// Initialize communication bus (wraps event bus + Redis pub/sub)
 	var bus comms.Bus
 	if dbManager != nil && dbManager.Redis() != nil {
-		bus = comms.NewLocalBus(eventBus, dbManager.Redis())
-	}
-
-	// Start terminal server (for debugging)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := terminal.Start(ctx, bus, dbManager, cancel); err != nil {
-			shared.DebugError(err)
-			cancel()
+		local := comms.NewLocalBus(eventBus, dbManager.Redis())
+		// Multi-node deployments relay events so SSE clients on any node see
+		// them, and so API messages reach the node holding a robot's connection.
+		if shared.AppConfig.Events.Cluster || shared.AppConfig.Cluster.Enabled {
+			if err := local.EnableCluster(ctx, shared.AppConfig.Events.ClusterChannel, shared.AppConfig.Events.ClusterEvents); err != nil {
+				panic(fmt.Sprintf("Failed to enable cluster event fan-out: %v", err))
+			}
 		}
-	}()
+		bus = local
 
-	// Start HTTP server
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := http_server.Start(ctx, bus, dbManager); err != nil {
-			shared.DebugError(err)
-			cancel()
+		if err := handler_engine.WatchMaintenance(ctx, bus, dbManager.Redis()); err != nil {
+			panic(fmt.Sprintf("Failed to load maintenance mode: %v", err))
 		}
-	}()
+		if err := handler_engine.WatchIPConflicts(ctx, bus); err != nil {
+			panic(fmt.Sprintf("Invalid ip_conflict config: %v", err))
+		}
+		if _, err := autoaccept.Compile(shared.AppConfig.AutoAccept); err != nil {
+			panic(fmt.Sprintf("Invalid auto_accept config: %v", err))
+		}
+		if err := handler_engine.WatchKicks(ctx, bus); err != nil {
+			panic(fmt.Sprintf("Failed to watch robot kicks: %v", err))
+		}
+		if err := handler_engine.WatchRecordChanges(ctx, bus); err != nil {
+			panic(fmt.Sprintf("Failed to watch robot record changes: %v", err))
+		}
+		if shared.AppConfig.Database.Postgres.WatchChanges {
+			if err := handler_engine.PublishDatabaseChanges(ctx, bus); err != nil {
+				panic(fmt.Sprintf("Failed to watch PostgreSQL robot changes: %v", err))
+			}
+		}
+		handler_engine.WatchQueues(ctx, bus)
+		if shared.AppConfig.Events.StateDiff {
+			if err := statediff.Watch(ctx, bus, statediff.Engine); err != nil {
+				panic(fmt.Sprintf("Failed to start state diffs: %v", err))
+			}
+		}
+		if len(shared.AppConfig.Zones.Aggregates) > 0 {
+			aggs, err := zones.Compile(shared.AppConfig.Zones.Aggregates)
+			if err != nil {
+				panic(fmt.Sprintf("Invalid zones config: %v", err))
+			}
+			if !shared.AppConfig.Events.StateDiff {
+				shared.DebugPrint("zones.aggregates need events.state_diff; no robot state will reach them")
+			}
+			zones.Engine.SetAggregates(aggs)
+			if err := zones.Watch(ctx, bus, dbManager.Redis(), zones.Engine); err != nil {
+				panic(fmt.Sprintf("Failed to start zone aggregates: %v", err))
+			}
+		}
+		if shared.AppConfig.Energy.Enabled {
+			if err := energy.Watch(ctx, bus, dbManager.Postgres(), energy.NewMeter()); err != nil {
+				panic(fmt.Sprintf("Failed to start energy metering: %v", err))
+			}
+		}
+	}
 
-	// Start MQTT server
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := mqtt_server.Start(ctx, bus, dbManager); err != nil {
-			shared.DebugError(err)
-			cancel()
+	// Cluster mode: one node at a time is leader for cluster-wide work.
+	if shared.AppConfig.Cluster.Enabled {
+		if bus == nil {
+			panic("Cluster mode requires Redis")
 		}
-	}()
+		elector := cluster.NewElector(dbManager.Redis(), shared.NodeID(), shared.AppConfig.Cluster.LeaderTTLDuration(), func(leader bool) {
+			bus.PublishEvent(events.ClusterLeader, map[string]any{"node": shared.NodeID(), "leader": leader})
+		})
+		cluster.SetElector(elector)
+		go elector.Run(ctx)
+	}
 
-	// Start TCP server
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := tcp_server.Start(ctx, bus, dbManager); err != nil {
-			shared.DebugError(err)
-			cancel()
+	// Background jobs started from the HTTP API (async broadcasts, purges)
+	jobs.Default = jobs.NewManager(shared.AppConfig.Jobs.QueueSize, shared.AppConfig.Jobs.Keep)
+	jobs.Default.Start(ctx, shared.AppConfig.Jobs.Workers, func(job jobs.Job) {
+		if bus != nil {
+			bus.PublishEvent(events.JobUpdated, job)
 		}
-	}()
+	})
 
-	// Start UDP server
-	wg.Add(1)
+	// Geofence presence, shared by POST /presence and MQTT robomesh/presence/{subject}
+	presence.Default = presence.NewTracker(bus, shared.AppConfig.Presence)
+
+	// Delayed and recurring robot messages (POST /robot/{uuid}/schedule)
+	handler_engine.Scheduler.Start(ctx, bus, dbManager)
+
+	servers := []struct {
+		name  string
+		start func(ctx context.Context) error
+	}{
+		// Terminal server (for debugging); gets the root cancel so "shutdown" works
+		{"terminal", func(sctx context.Context) error { return terminal.Start(sctx, bus, dbManager, cancel) }},
+		{"http", func(sctx context.Context) error { return http_server.Start(sctx, bus, dbManager) }},
+		{"mqtt", func(sctx context.Context) error { return mqtt_server.Start(sctx, bus, dbManager) }},
+		{"tcp", func(sctx context.Context) error { return tcp_server.Start(sctx, bus, dbManager) }},
+		{"udp", func(sctx context.Context) error { return udp_server.Start(sctx, bus, dbManager) }},
+		{"exporter", func(sctx context.Context) error { return exporter.Start(sctx, eventBus) }},
+		{"automation", func(sctx context.Context) error { return automation.Start(sctx, eventBus, bus, dbManager) }},
+		{"push", func(sctx context.Context) error { return push.Start(sctx, eventBus, dbManager.Redis()) }},
+	}
+	serverNames := make([]string, 0, len(servers))
+	for _, srv := range servers {
+		startServer(lc, &wg, srv.name, []string{"database"}, cancel, srv.start)
+		serverNames = append(serverNames, srv.name)
+	}
+
+	// Handler processes run under the spawning server's context and still need
+	// the robot connection and DB to flush, so they are stopped first.
+	lc.Register(lifecycle.Component{
+		Name:      "handlers",
+		DependsOn: append(serverNames, "database"),
+		Stop: func(context.Context) error {
+			handler_engine.HandlerManager.StopAll("server_shutdown")
+			return nil
+		},
+	})
+
+	// Robots hear of the shutdown, with the expected downtime, before their
+	// handlers stop; the handlers' queues then drain for up to
+	// timeouts.shutdown_drain.
+	lc.Register(lifecycle.Component{
+		Name:      "shutdown_notice",
+		DependsOn: []string{"handlers"},
+		Stop: func(ctx context.Context) error {
+			n := handler_engine.HandlerManager.NotifyShutdown("server_shutdown", shared.AppConfig.Timeouts.DowntimeDuration())
+			shared.DebugPrint("Sent shutdown notice to %d robot(s)", n)
+			drainCtx, cancel := context.WithTimeout(ctx, shared.AppConfig.Timeouts.ShutdownDrainDuration())
+			defer cancel()
+			if pending := handler_engine.HandlerManager.Drain(drainCtx); pending > 0 {
+				shared.DebugPrint("%d handler queue(s) not drained before shutdown", pending)
+			}
+			return nil
+		},
+	})
+
+	// SIGHUP reloads runtime-adjustable settings without dropping connections.
+	hups := make(chan os.Signal, 1)
+	signal.Notify(hups, syscall.SIGHUP)
 	go func() {
-		defer wg.Done()
-		if err := udp_server.Start(ctx, bus, dbManager); err != nil {
-			shared.DebugError(err)
-			cancel()
+		for range hups {
+			changed, err := shared.ReloadConfig()
+			if err != nil {
+				shared.DebugPrint("Config reload failed: %v", err)
+				continue
+			}
+			shared.DebugPrint("Config reloaded; changed: %v", changed)
 		}
 	}()
 
@@ -131,8 +297,9 @@ func main() {
 
 	cancel()
 
-	// Stop all handler processes
-	handler_engine.HandlerManager.StopAll("server_shutdown")
+	if err := lc.Shutdown(context.Background()); err != nil {
+		shared.DebugPrint("Shutdown completed with errors: %v", err)
+	}
 
 	done := make(chan struct{})
 	go func() {
@@ -147,3 +314,148 @@ func main() {
 		shared.DebugPrint("Timeout waiting for servers to shut down, forcing exit.")
 	}
 }
+
+// startServer runs a blocking server Start function under its own context and
+// registers it with the lifecycle manager. A server that fails requests a
+// full shutdown via requestShutdown.
+func startServer(lc *lifecycle.Lifecycle_t, wg *sync.WaitGroup, name string, deps []string, requestShutdown context.CancelFunc, start func(ctx context.Context) error) {
+	srvCtx, srvCancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		if err := start(srvCtx); err != nil {
+			shared.DebugError(err)
+			requestShutdown()
+		}
+	}()
+
+	lc.Register(lifecycle.Component{
+		Name:      name,
+		DependsOn: deps,
+		Stop: func(ctx context.Context) error {
+			srvCancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// runMigrate applies pending migrations, or with "status" lists them, and
+// returns the process exit code.
+func runMigrate(args []string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	pg, err := database.NewPostgresHandler(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	defer pg.Close()
+
+	dir := shared.AppConfig.Database.Postgres.MigrationsDir
+	if len(args) > 0 && args[0] == "status" {
+		statuses, err := pg.MigrationStatuses(ctx, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+		for _, st := range statuses {
+			mark := "pending"
+			if st.Applied {
+				mark = "applied"
+			}
+			fmt.Printf("%-8s %s\n", mark, st.Name)
+		}
+		return 0
+	}
+
+	ran, err := pg.Migrate(ctx, dir)
+	for _, name := range ran {
+		fmt.Printf("applied  %s\n", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	if len(ran) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return 0
+}
+
+// runBackup writes a backup archive (cmd "backup") or restores one (cmd
+// "restore"), with the passphrase from backup.passphrase, and returns the
+// process exit code.
+func runBackup(cmd string, args []string) int {
+	fail := func(err error) int {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+		return 1
+	}
+	opts := backup.RestoreOptions{Config: slices.Contains(args, "--config")}
+	args = slices.DeleteFunc(args, func(a string) bool { return a == "--config" })
+	if len(args) > 1 || (cmd == "restore" && len(args) == 0) {
+		fmt.Fprintln(os.Stderr, "usage: roboserver backup [<file>] | roboserver restore [--config] <file>")
+		return 2
+	}
+	passphrase, err := backup.Passphrase("")
+	if err != nil {
+		return fail(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	db, err := database.Start(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer db.Stop()
+
+	if cmd == "restore" {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fail(err)
+		}
+		archive, err := backup.Open(data, passphrase)
+		if err != nil {
+			return fail(err)
+		}
+		sum, err := backup.Restore(ctx, db, nil, archive, opts)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Printf("restored %d robot(s), %d user(s), %d macro(s), %d automation script(s)\n",
+			sum.Robots, sum.Users, sum.Macros, sum.Automations)
+		if n := len(archive.Schedules); n > 0 {
+			fmt.Printf("%d scheduled message(s) not restored: restore from a running server to keep them\n", n)
+		}
+		return 0
+	}
+
+	archive, err := backup.Create(ctx, db)
+	if err != nil {
+		return fail(err)
+	}
+	data, err := backup.Seal(archive, passphrase)
+	if err != nil {
+		return fail(err)
+	}
+	path := filepath.Join(shared.AppConfig.Backup.Dir, fmt.Sprintf("robomesh-%s.rmbk", time.Unix(archive.CreatedAt, 0).UTC().Format("20060102-150405")))
+	if len(args) == 1 {
+		path = args[0]
+	} else if err := os.MkdirAll(shared.AppConfig.Backup.Dir, 0o700); err != nil {
+		return fail(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fail(err)
+	}
+	fmt.Printf("wrote %s (%d bytes)\n", path, len(data))
+	return 0
+}
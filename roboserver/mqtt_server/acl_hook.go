@@ -17,7 +17,9 @@ import (
 // Subscribe (read) rules:
 //   - robomesh/auth/{uuid}/response  → only if uuid == client ID
 //   - robomesh/heartbeat/{uuid}/response → only if uuid == client ID
+//   - robomesh/time/{uuid}/response  → only if uuid == client ID
 //   - robomesh/to_robot/{uuid}       → only if uuid == client ID
+//   - robomesh/presence/{subject}    → never (payloads carry API keys), nor any wildcard covering it
 //   - all other topics               → allowed (e.g. publishing to auth/heartbeat/message)
 type robotACLHook struct {
 	mqtt.HookBase
@@ -51,6 +53,10 @@ func (h *robotACLHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) boo
 	// For subscribes (reads), restrict sensitive per-robot topics
 	clientID := cl.ID
 
+	if coversPresence(topic) {
+		return false
+	}
+
 	// robomesh/auth/{uuid}/response — restrict to own UUID
 	if strings.HasPrefix(topic, "robomesh/auth/") && strings.HasSuffix(topic, "/response") {
 		uuid := strings.TrimPrefix(topic, "robomesh/auth/")
@@ -65,6 +71,13 @@ func (h *robotACLHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) boo
 		return uuid == clientID
 	}
 
+	// robomesh/time/{uuid}/response — restrict to own UUID
+	if strings.HasPrefix(topic, "robomesh/time/") && strings.HasSuffix(topic, "/response") {
+		uuid := strings.TrimPrefix(topic, "robomesh/time/")
+		uuid = strings.TrimSuffix(uuid, "/response")
+		return uuid == clientID
+	}
+
 	// robomesh/to_robot/{uuid} — restrict to own UUID
 	if strings.HasPrefix(topic, "robomesh/to_robot/") {
 		uuid := strings.TrimPrefix(topic, "robomesh/to_robot/")
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"roboserver/comms"
 	"roboserver/shared"
+	"roboserver/shared/events"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 	"github.com/mochi-mqtt/server/v2/packets"
@@ -39,7 +40,7 @@ func (h *eventBusBridgeHook) OnPublished(cl *mqtt.Client, pk packets.Packet) {
 	if len(topic) > len(prefix) && topic[:len(prefix)] == prefix {
 		eventType := topic[len(prefix):]
 		if h.bus != nil {
-			h.bus.PublishEvent("mqtt.message."+eventType, payload)
+			h.bus.PublishEvent(events.MQTTMessage(eventType), payload)
 			shared.DebugPrint("MQTT→EventBus: %s → mqtt.message.%s", topic, eventType)
 		}
 	}
@@ -0,0 +1,27 @@
+package mqtt_server
+
+import (
+	"roboserver/shared"
+	"roboserver/shared/events"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// handleKick disconnects the MQTT client of a kicked robot held by this
+// node, with reason code 0x98 (administrative action).
+func (s *MQTTServer_t) handleKick(_ string, data any) {
+	kick, ok := events.DecodeKick(data)
+	if !ok {
+		return
+	}
+	id, ok := s.clients.Load(kick.UUID)
+	if !ok {
+		return
+	}
+	cl, ok := s.server.Clients.Get(id.(string))
+	if !ok {
+		return
+	}
+	shared.DebugPrint("MQTT: disconnecting kicked robot %s", kick.UUID)
+	s.server.DisconnectClient(cl, packets.ErrAdministrativeAction)
+}
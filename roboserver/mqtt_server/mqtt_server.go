@@ -9,7 +9,11 @@ import (
 	"roboserver/database"
 	"roboserver/handler_engine"
 	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/timesync"
+	"roboserver/shared/tracked"
 	"strings"
+	"sync"
 	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
@@ -23,6 +27,8 @@ type MQTTServer_t struct {
 	bus    comms.Bus
 	db     database.DBManager
 	ctx    context.Context
+
+	clients sync.Map // robot UUID -> MQTT client ID, recorded at auth
 }
 
 // Start initializes and runs the MQTT broker.
@@ -30,8 +36,10 @@ type MQTTServer_t struct {
 //
 //	robomesh/auth/{uuid}          — Robot publishes auth request, server responds on robomesh/auth/{uuid}/response
 //	robomesh/heartbeat/{uuid}     — Robot publishes signed heartbeat
+//	robomesh/time/{uuid}          — Robot asks for the time, server responds on robomesh/time/{uuid}/response
 //	robomesh/message/{uuid}       — Robot publishes messages to its handler
 //	robomesh/to_robot/{uuid}      — Server publishes messages to a specific robot
+//	robomesh/presence/{subject}   — Phone or beacon gateway publishes a location (see presence.go)
 func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 	port := shared.AppConfig.Server.MQTTPort
 
@@ -80,6 +88,7 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 	// Subscribe to event bus for handler→robot messages and forward via MQTT
 	if bus != nil {
 		s.setupOutboundBridge()
+		s.bus.SubscribeEvent(events.RobotKicked, s.handleKick)
 	}
 
 	// Start server
@@ -104,7 +113,7 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 // and publishes them to the MQTT topic robomesh/to_robot/{uuid} so that
 // MQTT-connected robots receive messages from their handlers.
 func (s *MQTTServer_t) setupOutboundBridge() {
-	s.bus.SubscribeEvent("mqtt.to_robot", func(eventType string, data any) {
+	s.bus.SubscribeEvent(events.MQTTToRobot, func(eventType string, data any) {
 		msg, ok := data.(map[string]interface{})
 		if !ok {
 			return
@@ -163,17 +172,26 @@ type HeartbeatRequest struct {
 	Signature string `json:"signature"`
 }
 
+// TimeRequest is the JSON payload for robomesh/time/{uuid}: the robot's
+// clock in Unix ms when it sent the request, and the round trip it measured
+// on its previous request (0 when it has none). The server responds with a
+// timesync.Reply.
+type TimeRequest struct {
+	T1    int64   `json:"t1"`
+	RTTMs float64 `json:"rtt_ms,omitempty"`
+}
+
 // safeGo runs fn in a goroutine and recovers from any panic so a single
 // malformed MQTT payload can't crash the broker.
 func safeGo(label string, fn func()) {
-	go func() {
+	tracked.Go("mqtt", func() {
 		defer func() {
 			if r := recover(); r != nil {
 				shared.DebugPrint("MQTT %s panic: %v", label, r)
 			}
 		}()
 		fn()
-	}()
+	})
 }
 
 func (h *protocolHook) OnPublished(cl *mqtt.Client, pk packets.Packet) {
@@ -194,11 +212,24 @@ func (h *protocolHook) OnPublished(cl *mqtt.Client, pk packets.Packet) {
 			safeGo("heartbeat", func() { h.handleHeartbeat(uuid, payload, cl) })
 		}
 
+	case strings.HasPrefix(topic, "robomesh/time/"):
+		uuid := strings.TrimPrefix(topic, "robomesh/time/")
+		if uuid != "" && !strings.Contains(uuid, "/") {
+			received := time.Now()
+			safeGo("time", func() { h.handleTime(uuid, payload, received) })
+		}
+
 	case strings.HasPrefix(topic, "robomesh/message/"):
 		uuid := strings.TrimPrefix(topic, "robomesh/message/")
 		if uuid != "" && !strings.Contains(uuid, "/") {
 			safeGo("message", func() { h.handleMessage(uuid, payload) })
 		}
+
+	case strings.HasPrefix(topic, presencePrefix):
+		subject := strings.TrimPrefix(topic, presencePrefix)
+		if subject != "" && !strings.Contains(subject, "/") {
+			safeGo("presence", func() { h.handlePresence(subject, payload) })
+		}
 	}
 }
 
@@ -278,8 +309,19 @@ func (h *protocolHook) handleAuth(cl *mqtt.Client, uuid string, payload []byte)
 		return
 	}
 
+	ip := shared.CanonicalIP(cl.Net.Remote)
+	if err := robotauth.CheckBan(h.mqtt.ctx, rds, uuid, ip); err != nil {
+		h.publishJSON(responseTopic, AuthResponse{Status: "error", Error: err.Error()})
+		return
+	}
+	conflict, err := robotauth.CheckIPConflict(h.mqtt.ctx, pg, rds, uuid, ip, publicKey)
+	handler_engine.ReportIPConflict(h.mqtt.bus, conflict)
+	if err != nil {
+		h.publishJSON(responseTopic, AuthResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
 	// Issue JWT
-	ip := cl.Net.Remote
 	sessionID := robotauth.GenerateSessionID()
 	jwt, err := robotauth.IssueSessionJWT(uuid, deviceType, ip, sessionID)
 	if err != nil {
@@ -312,7 +354,7 @@ func (h *protocolHook) handleAuth(cl *mqtt.Client, uuid string, payload []byte)
 		existing.Reattach(robotSend, ip, sessionID)
 		shared.DebugPrint("MQTT: Robot %s reattached to existing handler (PID %d)", uuid, existing.PID)
 	} else if handler_engine.HandlerManager.TryStartSpawning(uuid) {
-		_, spawnErr := handler_engine.SpawnHandlerProcess(
+		_, spawnErr := handler_engine.SpawnSupervised(
 			h.mqtt.ctx,
 			uuid, deviceType, ip, sessionID,
 			pg, rds, h.mqtt.bus,
@@ -324,6 +366,7 @@ func (h *protocolHook) handleAuth(cl *mqtt.Client, uuid string, payload []byte)
 		}
 	}
 
+	h.mqtt.clients.Store(uuid, cl.ID)
 	shared.DebugPrint("MQTT: Robot %s authenticated successfully", uuid)
 	h.publishJSON(responseTopic, AuthResponse{Status: "ok", JWT: jwt})
 }
@@ -347,11 +390,11 @@ func (h *protocolHook) handleHeartbeat(uuid string, payload []byte, cl *mqtt.Cli
 		return
 	}
 
-	ip := cl.Net.Remote
+	ip := shared.CanonicalIP(cl.Net.Remote)
 
 	result, err := robotauth.ProcessHeartbeat(h.mqtt.ctx, uuid, req.Payload, req.Signature, ip, pg, rds)
 	if err != nil {
-		shared.DebugPrint("MQTT heartbeat failed for %s: %v", uuid, err)
+		shared.DebugPrint("MQTT heartbeat failed: %v", err)
 		responseTopic := fmt.Sprintf("robomesh/heartbeat/%s/response", uuid)
 		h.publishJSON(responseTopic, map[string]string{"status": "error", "error": "heartbeat rejected"})
 		return
@@ -359,13 +402,46 @@ func (h *protocolHook) handleHeartbeat(uuid string, payload []byte, cl *mqtt.Cli
 
 	// Publish heartbeat event for handlers with forward_heartbeats enabled
 	if h.mqtt.bus != nil {
-		h.mqtt.bus.PublishEvent(fmt.Sprintf("robot.%s.heartbeat", result.UUID), result)
+		h.mqtt.bus.PublishEvent(events.RobotHeartbeat(result.UUID), result)
 	}
 
 	responseTopic := fmt.Sprintf("robomesh/heartbeat/%s/response", uuid)
 	h.publishJSON(responseTopic, map[string]string{"status": "ok"})
 }
 
+// handleTime answers an SNTP-style time request from a robot with an active
+// session and records its clock offset (see shared/timesync).
+func (h *protocolHook) handleTime(uuid string, payload []byte, received time.Time) {
+	db := h.mqtt.db
+	if db == nil {
+		return
+	}
+	rds := db.Redis()
+	if rds == nil {
+		return
+	}
+	responseTopic := fmt.Sprintf("robomesh/time/%s/response", uuid)
+
+	var req TimeRequest
+	if err := json.Unmarshal(payload, &req); err != nil || req.T1 <= 0 || req.RTTMs < 0 {
+		h.publishJSON(responseTopic, map[string]string{"status": "error", "error": "invalid time request"})
+		return
+	}
+	if active, err := rds.GetActiveRobot(h.mqtt.ctx, uuid); active == nil || err != nil {
+		shared.DebugPrint("MQTT time request rejected: no active session for %s", uuid)
+		return
+	}
+
+	rtt := time.Duration(req.RTTMs * float64(time.Millisecond))
+	reply, err := timesync.Answer(h.mqtt.ctx, rds, uuid, req.T1, rtt, received, shared.AppConfig.TimeSync.KeepFor())
+	if err != nil {
+		shared.DebugPrint("MQTT: failed to record the clock offset of %s: %v", uuid, err)
+		h.publishJSON(responseTopic, map[string]string{"status": "error", "error": "time sync failed"})
+		return
+	}
+	h.publishJSON(responseTopic, reply)
+}
+
 // handleMessage forwards a message from an MQTT-connected robot to its handler.
 // Verifies the robot has an active session before forwarding.
 func (h *protocolHook) handleMessage(uuid string, payload []byte) {
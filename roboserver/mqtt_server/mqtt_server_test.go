@@ -99,6 +99,16 @@ func TestHeartbeatRequestSerialization(t *testing.T) {
 	}
 }
 
+func TestTimeRequestSerialization(t *testing.T) {
+	var req TimeRequest
+	if err := json.Unmarshal([]byte(`{"t1":1718000000000,"rtt_ms":42.5}`), &req); err != nil {
+		t.Fatalf("Failed to unmarshal TimeRequest: %v", err)
+	}
+	if req.T1 != 1718000000000 || req.RTTMs != 42.5 {
+		t.Errorf("Unexpected TimeRequest: %+v", req)
+	}
+}
+
 func TestAuthRequest_EmptySignature(t *testing.T) {
 	// When signature is empty, server should treat this as step 1 (nonce request)
 	req := AuthRequest{UUID: "robot-001"}
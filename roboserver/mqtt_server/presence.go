@@ -0,0 +1,100 @@
+package mqtt_server
+
+import (
+	"context"
+	"encoding/json"
+	robotauth "roboserver/auth"
+	"roboserver/database"
+	"roboserver/presence"
+	"roboserver/shared"
+	"strings"
+)
+
+const presencePrefix = "robomesh/presence/"
+
+// PresenceRequest is the JSON payload for robomesh/presence/{subject}: an
+// API key of the subject's user (or of an admin), and the location.
+type PresenceRequest struct {
+	Key       string   `json:"key"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// handlePresence feeds a location update into presence.Default, under the
+// same rules as POST /presence. Transitions are published on the event bus;
+// nothing is sent back over MQTT.
+func (h *protocolHook) handlePresence(subject string, payload []byte) {
+	db := h.mqtt.db
+	if db == nil {
+		return
+	}
+	rds := db.Redis()
+	if rds == nil {
+		return
+	}
+
+	var req PresenceRequest
+	if err := json.Unmarshal(payload, &req); err != nil || req.Latitude == nil || req.Longitude == nil {
+		shared.DebugPrint("MQTT presence: invalid payload for %s", subject)
+		return
+	}
+	if !presence.ValidCoordinates(*req.Latitude, *req.Longitude) {
+		shared.DebugPrint("MQTT presence: coordinates out of range for %s", subject)
+		return
+	}
+	user := apiKeyUser(h.mqtt.ctx, rds, req.Key)
+	if user == nil {
+		shared.DebugPrint("MQTT presence rejected: invalid API key for %s", subject)
+		return
+	}
+	if _, ok := presence.SubjectFor(user, subject); !ok {
+		shared.DebugPrint("MQTT presence rejected: %s may not report for %s", user.Username, subject)
+		return
+	}
+	if _, err := presence.Default.Update(subject, *req.Latitude, *req.Longitude); err != nil {
+		shared.DebugPrint("MQTT presence for %s: %v", subject, err)
+	}
+}
+
+// apiKeyUser resolves an "rmk_" API key to its owner with the key's role
+// applied, or nil if the key is malformed, unknown or revoked.
+func apiKeyUser(ctx context.Context, rds *database.RedisHandler, token string) *database.User {
+	id, secret, ok := robotauth.ParseAPIKey(token)
+	if !ok {
+		return nil
+	}
+	key, err := rds.GetAPIKey(ctx, id)
+	if err != nil || !robotauth.CheckAPIKeySecret(secret, key.Hash) {
+		return nil
+	}
+	user, err := rds.GetUser(ctx, key.Username)
+	if err != nil {
+		return nil
+	}
+	return key.Apply(user)
+}
+
+// coversPresence reports whether a subscription filter can match a
+// robomesh/presence/{subject} topic. Those payloads carry API keys, so no
+// client may subscribe to them.
+func coversPresence(filter string) bool {
+	if rest, ok := strings.CutPrefix(filter, "$share/"); ok {
+		if _, f, ok := strings.Cut(rest, "/"); ok {
+			filter = f
+		}
+	}
+	topic := []string{"robomesh", "presence", ""}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topic) {
+			return false
+		}
+		if level != "+" && i < 2 && level != topic[i] {
+			return false
+		}
+	}
+	return len(levels) == len(topic)
+}
@@ -0,0 +1,47 @@
+package mqtt_server
+
+import (
+	"testing"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+)
+
+func TestCoversPresence(t *testing.T) {
+	tests := map[string]bool{
+		"robomesh/presence/alice":            true,
+		"robomesh/presence/+":                true,
+		"robomesh/+/alice":                   true,
+		"+/+/+":                              true,
+		"robomesh/#":                         true,
+		"#":                                  true,
+		"$share/g/robomesh/presence/+":       true,
+		"robomesh/presence":                  false,
+		"robomesh/presence/alice/x":          false,
+		"robomesh/to_robot/robot-1":          false,
+		"robomesh/auth/robot-1/response":     false,
+		"$share/g/robomesh/to_robot/robot-1": false,
+	}
+	for filter, want := range tests {
+		if got := coversPresence(filter); got != want {
+			t.Errorf("coversPresence(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestACLRefusesPresenceSubscriptions(t *testing.T) {
+	h := &robotACLHook{}
+	cl := &mqtt.Client{ID: "robot-1"}
+
+	if h.OnACLCheck(cl, "robomesh/#", false) {
+		t.Error("Expected a wildcard covering presence topics to be refused")
+	}
+	if h.OnACLCheck(cl, "robomesh/presence/alice", false) {
+		t.Error("Expected delivery of a presence topic to be refused")
+	}
+	if !h.OnACLCheck(cl, "robomesh/presence/alice", true) {
+		t.Error("Expected publishing a presence update to be allowed")
+	}
+	if !h.OnACLCheck(cl, "robomesh/to_robot/robot-1", false) {
+		t.Error("Expected a robot to read its own to_robot topic")
+	}
+}
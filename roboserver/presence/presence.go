@@ -0,0 +1,173 @@
+package presence
+
+import (
+	"errors"
+	"math"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"sync"
+	"time"
+)
+
+const (
+	EventEnter = events.PresenceEnter
+	EventLeave = events.PresenceLeave
+)
+
+const earthRadiusMeters = 6371000.0
+
+// Transition is published on the bus whenever a subject crosses a geofence
+// boundary. Handlers react to it by subscribing to presence.enter/leave
+// (e.g. a smart lock handler unlocking when its owner arrives).
+type Transition struct {
+	Subject   string  `json:"subject"`
+	Geofence  string  `json:"geofence"`
+	Event     string  `json:"event"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+func init() {
+	for _, ev := range []string{EventEnter, EventLeave} {
+		events.Describe(events.Info{
+			Type:        ev,
+			Description: "A subject crossed a geofence boundary (presence.geofences).",
+			Example:     Transition{Subject: "alice", Geofence: "home", Event: ev, Latitude: 52.52, Longitude: 13.405, Timestamp: 1718000000},
+		})
+	}
+}
+
+// ErrTooManySubjects is returned by Update when a subject would enter a
+// geofence while presence.max_subjects others are already inside one.
+var ErrTooManySubjects = errors.New("too many subjects inside geofences")
+
+// Tracker_t remembers which geofences each subject is currently inside, so a
+// location update only produces events when that membership changes. Only
+// subjects inside at least one fence are kept.
+type Tracker_t struct {
+	bus         comms.Bus
+	fences      []shared.GeofenceConfig
+	maxSubjects int
+
+	mu     sync.Mutex
+	inside map[string]map[string]bool // subject -> geofence -> inside
+}
+
+// Default is the process-wide tracker fed by POST /presence and the MQTT
+// robomesh/presence/{subject} topic, replaced in main with one built from
+// the presence config before the servers start.
+var Default = NewTracker(nil, shared.PresenceConfig{})
+
+func NewTracker(bus comms.Bus, cfg shared.PresenceConfig) *Tracker_t {
+	return &Tracker_t{
+		bus:         bus,
+		fences:      cfg.Geofences,
+		maxSubjects: cfg.MaxSubjects,
+		inside:      make(map[string]map[string]bool),
+	}
+}
+
+// Update records a subject's location and returns (and publishes) any
+// enter/leave transitions it caused. The first update for a subject only
+// emits enter events for fences it starts inside.
+func (t *Tracker_t) Update(subject string, lat, lon float64) ([]Transition, error) {
+	now := time.Now().Unix()
+
+	t.mu.Lock()
+	state, known := t.inside[subject]
+	if !known {
+		state = make(map[string]bool)
+	}
+
+	var transitions []Transition
+	for _, f := range t.fences {
+		in := Distance(lat, lon, f.Latitude, f.Longitude) <= f.RadiusMeters
+		if in == state[f.Name] {
+			continue
+		}
+		event := EventLeave
+		if in {
+			event = EventEnter
+			state[f.Name] = true
+		} else {
+			delete(state, f.Name)
+		}
+		transitions = append(transitions, Transition{
+			Subject:   subject,
+			Geofence:  f.Name,
+			Event:     event,
+			Latitude:  lat,
+			Longitude: lon,
+			Timestamp: now,
+		})
+	}
+	switch {
+	case len(state) == 0:
+		delete(t.inside, subject)
+	case !known:
+		if t.maxSubjects > 0 && len(t.inside) >= t.maxSubjects {
+			t.mu.Unlock()
+			return nil, ErrTooManySubjects
+		}
+		t.inside[subject] = state
+	}
+	t.mu.Unlock()
+
+	if t.bus != nil {
+		for _, tr := range transitions {
+			if err := t.bus.PublishEvent(tr.Event, tr); err != nil {
+				shared.DebugPrint("Failed to publish %s for %s: %v", tr.Event, tr.Subject, err)
+			}
+		}
+	}
+	return transitions, nil
+}
+
+// SubjectFor resolves the subject of a location update reported by user:
+// their own username when none is given, and any subject for an admin.
+func SubjectFor(user *database.User, subject string) (string, bool) {
+	if subject == "" || subject == user.Username {
+		return user.Username, true
+	}
+	return subject, user.IsAdmin()
+}
+
+// ValidCoordinates reports whether lat and lon are a point on Earth.
+func ValidCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// Occupants returns the subjects currently inside each geofence.
+func (t *Tracker_t) Occupants() map[string][]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string][]string, len(t.fences))
+	for _, f := range t.fences {
+		result[f.Name] = []string{}
+	}
+	for subject, fences := range t.inside {
+		for name := range fences {
+			result[name] = append(result[name], subject)
+		}
+	}
+	return result
+}
+
+// Geofences returns the configured geofences.
+func (t *Tracker_t) Geofences() []shared.GeofenceConfig {
+	return t.fences
+}
+
+// Distance returns the great-circle distance in meters between two points.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,111 @@
+package presence
+
+import (
+	"roboserver/database"
+	"roboserver/shared"
+	"testing"
+)
+
+var testFences = []shared.GeofenceConfig{
+	{Name: "home", Latitude: 37.7749, Longitude: -122.4194, RadiusMeters: 100},
+}
+
+func TestDistance(t *testing.T) {
+	// ~111km per degree of latitude
+	d := Distance(0, 0, 1, 0)
+	if d < 110000 || d > 112000 {
+		t.Errorf("Expected ~111km, got %f", d)
+	}
+	if Distance(10, 10, 10, 10) != 0 {
+		t.Error("Expected zero distance for identical points")
+	}
+}
+
+func TestTrackerEnterLeave(t *testing.T) {
+	tr := NewTracker(nil, shared.PresenceConfig{Geofences: testFences})
+
+	// Far away: no transition
+	if got, _ := tr.Update("phone-1", 40.0, -120.0); len(got) != 0 {
+		t.Fatalf("Expected no transitions, got %v", got)
+	}
+
+	got, _ := tr.Update("phone-1", 37.7749, -122.4194)
+	if len(got) != 1 || got[0].Event != EventEnter || got[0].Geofence != "home" {
+		t.Fatalf("Expected enter home, got %v", got)
+	}
+
+	// Still inside: no duplicate enter
+	if got, _ := tr.Update("phone-1", 37.7750, -122.4194); len(got) != 0 {
+		t.Fatalf("Expected no transitions while inside, got %v", got)
+	}
+
+	got, _ = tr.Update("phone-1", 40.0, -120.0)
+	if len(got) != 1 || got[0].Event != EventLeave {
+		t.Fatalf("Expected leave home, got %v", got)
+	}
+}
+
+func TestTrackerOccupants(t *testing.T) {
+	tr := NewTracker(nil, shared.PresenceConfig{Geofences: testFences})
+	tr.Update("phone-1", 37.7749, -122.4194)
+	tr.Update("phone-2", 40.0, -120.0)
+
+	occ := tr.Occupants()
+	if len(occ["home"]) != 1 || occ["home"][0] != "phone-1" {
+		t.Errorf("Expected [phone-1] in home, got %v", occ["home"])
+	}
+}
+
+func TestTrackerForgetsSubjectsOutside(t *testing.T) {
+	tr := NewTracker(nil, shared.PresenceConfig{Geofences: testFences})
+	tr.Update("phone-1", 40.0, -120.0)
+	tr.Update("phone-2", 37.7749, -122.4194)
+	tr.Update("phone-2", 40.0, -120.0)
+
+	if len(tr.inside) != 0 {
+		t.Errorf("Expected no tracked subjects outside every fence, got %v", tr.inside)
+	}
+}
+
+func TestTrackerMaxSubjects(t *testing.T) {
+	tr := NewTracker(nil, shared.PresenceConfig{Geofences: testFences, MaxSubjects: 1})
+	if _, err := tr.Update("phone-1", 37.7749, -122.4194); err != nil {
+		t.Fatalf("Expected the first subject to be tracked, got %v", err)
+	}
+	if got, err := tr.Update("phone-2", 37.7749, -122.4194); err != ErrTooManySubjects || len(got) != 0 {
+		t.Fatalf("Expected ErrTooManySubjects, got %v, %v", got, err)
+	}
+	// A subject outside every fence takes no slot.
+	if _, err := tr.Update("phone-3", 40.0, -120.0); err != nil {
+		t.Errorf("Expected an update outside every fence to succeed, got %v", err)
+	}
+	// Once phone-1 leaves, its slot is free again.
+	tr.Update("phone-1", 40.0, -120.0)
+	if _, err := tr.Update("phone-2", 37.7749, -122.4194); err != nil {
+		t.Errorf("Expected a freed slot, got %v", err)
+	}
+}
+
+func TestSubjectFor(t *testing.T) {
+	alice := &database.User{Username: "alice", Role: "user"}
+	admin := &database.User{Username: "root", Role: "admin"}
+
+	tests := []struct {
+		user    *database.User
+		subject string
+		want    string
+		ok      bool
+	}{
+		{alice, "", "alice", true},
+		{alice, "alice", "alice", true},
+		{alice, "bob", "bob", false},
+		{admin, "beacon-7", "beacon-7", true},
+		{admin, "", "root", true},
+	}
+	for _, tt := range tests {
+		got, ok := SubjectFor(tt.user, tt.subject)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SubjectFor(%s, %q) = %q, %v; want %q, %v", tt.user.Username, tt.subject, got, ok, tt.want, tt.ok)
+		}
+	}
+}
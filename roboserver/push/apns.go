@@ -0,0 +1,147 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"roboserver/shared"
+	"sync"
+	"time"
+)
+
+const (
+	apnsProduction = "https://api.push.apple.com"
+	apnsSandbox    = "https://api.sandbox.push.apple.com"
+
+	// apnsTokenAge is how long a provider token is reused. APNs rejects
+	// tokens older than an hour and refreshes more frequent than every 20
+	// minutes.
+	apnsTokenAge = 40 * time.Minute
+)
+
+// apnsSender sends through the APNs HTTP/2 API with token authentication:
+// an ES256 JWT signed with the team's .p8 key.
+type apnsSender struct {
+	host   string
+	keyID  string
+	teamID string
+	topic  string
+	key    crypto.Signer
+	client *http.Client
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+}
+
+func newAPNsSender(cfg shared.APNsConfig) (*apnsSender, error) {
+	if cfg.KeyID == "" || cfg.TeamID == "" || cfg.Topic == "" {
+		return nil, fmt.Errorf("push: APNs needs key_id, team_id and topic")
+	}
+	raw, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("push: failed to read APNs key: %w", err)
+	}
+	key, err := parsePrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("push: APNs key: %w", err)
+	}
+	host := apnsProduction
+	if cfg.Sandbox {
+		host = apnsSandbox
+	}
+	return &apnsSender{
+		host:   host,
+		keyID:  cfg.KeyID,
+		teamID: cfg.TeamID,
+		topic:  cfg.Topic,
+		key:    key,
+		// APNs only speaks HTTP/2, which the default transport negotiates.
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// providerToken returns the current provider token, signing a new one when
+// it is apnsTokenAge old.
+func (a *apnsSender) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Since(a.issued) < apnsTokenAge {
+		return a.token, nil
+	}
+	now := time.Now()
+	token, err := signJWT(map[string]any{"kid": a.keyID}, map[string]any{"iss": a.teamID, "iat": now.Unix()}, a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issued = token, now
+	return token, nil
+}
+
+func (a *apnsSender) Send(ctx context.Context, token string, n Notification) error {
+	jwt, err := a.providerToken()
+	if err != nil {
+		return err
+	}
+
+	aps := map[string]any{
+		"alert": map[string]string{"title": n.Title, "body": n.Body},
+		"sound": "default",
+	}
+	priority := "5"
+	if n.Critical {
+		aps["interruption-level"] = "time-sensitive"
+		priority = "10"
+	}
+	body := map[string]any{"aps": aps}
+	for k, v := range n.Data {
+		if k != "aps" {
+			body[k] = v
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/3/device/"+url.PathEscape(token), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+jwt)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", priority)
+	if n.Collapse != "" {
+		req.Header.Set("apns-collapse-id", n.Collapse)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var reply struct {
+		Reason string `json:"reason"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	json.Unmarshal(raw, &reply)
+	switch {
+	case resp.StatusCode == http.StatusGone || reply.Reason == "BadDeviceToken" || reply.Reason == "Unregistered":
+		return ErrUnregistered
+	case reply.Reason == "ExpiredProviderToken":
+		a.mu.Lock()
+		a.token = ""
+		a.mu.Unlock()
+	}
+	return fmt.Errorf("apns: %s: %s", resp.Status, reply.Reason)
+}
@@ -0,0 +1,172 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fcmScope        = "https://www.googleapis.com/auth/firebase.messaging"
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// serviceAccount is the part of a Firebase service account JSON key the
+// sender needs.
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// fcmSender sends through the FCM HTTP v1 API as a service account: a
+// self-signed RS256 JWT is exchanged for an OAuth2 access token, which is
+// reused until shortly before it expires.
+type fcmSender struct {
+	endpoint string // messages:send URL
+	tokenURI string
+	email    string
+	key      crypto.Signer
+	client   *http.Client
+
+	mu      sync.Mutex
+	access  string
+	expires time.Time
+}
+
+func newFCMSender(credentialsFile string) (*fcmSender, error) {
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("push: failed to read FCM credentials: %w", err)
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(raw, &sa); err != nil {
+		return nil, fmt.Errorf("push: invalid FCM credentials: %w", err)
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("push: FCM credentials need project_id, client_email and private_key")
+	}
+	key, err := parsePrivateKey([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("push: FCM private key: %w", err)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = defaultTokenURI
+	}
+	return &fcmSender{
+		endpoint: "https://fcm.googleapis.com/v1/projects/" + url.PathEscape(sa.ProjectID) + "/messages:send",
+		tokenURI: sa.TokenURI,
+		email:    sa.ClientEmail,
+		key:      key,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// accessToken returns a valid OAuth2 access token, fetching a new one
+// when the cached one is about to expire.
+func (f *fcmSender) accessToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.access != "" && time.Now().Before(f.expires) {
+		return f.access, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(map[string]any{"typ": "JWT"}, map[string]any{
+		"iss":   f.email,
+		"scope": fcmScope,
+		"aud":   f.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}, f.key)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("fcm token exchange: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.AccessToken == "" {
+		return "", fmt.Errorf("fcm token exchange: invalid response")
+	}
+	f.access = body.AccessToken
+	f.expires = now.Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return f.access, nil
+}
+
+func (f *fcmSender) Send(ctx context.Context, token string, n Notification) error {
+	access, err := f.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	priority := "normal"
+	if n.Critical {
+		priority = "high"
+	}
+	message := map[string]any{
+		"token":        token,
+		"notification": map[string]string{"title": n.Title, "body": n.Body},
+		"data":         n.Data,
+		"android":      map[string]any{"priority": priority, "collapse_key": n.Collapse},
+	}
+	payload, err := json.Marshal(map[string]any{"message": message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+access)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || bytes.Contains(msg, []byte("UNREGISTERED")):
+		return ErrUnregistered
+	case resp.StatusCode == http.StatusUnauthorized:
+		// Fetch a new access token next time.
+		f.mu.Lock()
+		f.access = ""
+		f.mu.Unlock()
+	}
+	return fmt.Errorf("fcm: %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
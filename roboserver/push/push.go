@@ -0,0 +1,346 @@
+// Package push delivers mobile push notifications. Users register their
+// phones' device tokens and notification rules (shared/notify, stored in
+// Redis); the gateway matches published events against every user's rules
+// and sends the matches through Firebase Cloud Messaging or Apple Push
+// Notification service.
+//
+// A user is only notified of a robot's events if they can access the robot
+// (robot ACLs); events not about a robot go to admins only. A push is sent
+// at most once per user, rule, event type and robot within
+// push.dedup_window, across the cluster, and rules not marked critical are
+// held back during the user's quiet hours. Like the exporter, matching is
+// best effort: events that arrive while the queue is full are dropped.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"roboserver/shared/notify"
+	"slices"
+	"sync"
+	"time"
+)
+
+// reloadInterval is how often every user's preferences are reloaded, in
+// case a push.prefs_changed event was missed.
+const reloadInterval = time.Minute
+
+// maxCollapseKey is the longest collapse key APNs accepts, in bytes.
+const maxCollapseKey = 64
+
+// ErrUnregistered is returned by a Sender for a device token the platform
+// no longer accepts (app uninstalled, token rotated). The gateway then
+// unregisters the device.
+var ErrUnregistered = errors.New("push: device token is no longer registered")
+
+// Notification is one push as sent to a device.
+type Notification struct {
+	Title    string
+	Body     string
+	Data     map[string]string // passed to the app with the notification
+	Collapse string            // replaces an undelivered push with the same key
+	Critical bool              // deliver with high priority
+}
+
+// Sender delivers a notification to one device of its platform.
+type Sender interface {
+	Send(ctx context.Context, token string, n Notification) error
+}
+
+// Store holds users, their devices and preferences (database.RedisHandler).
+type Store interface {
+	ListNotificationUsers(ctx context.Context) ([]string, error)
+	GetNotificationPrefs(ctx context.Context, username string) (*notify.Prefs, error)
+	ListPushDevices(ctx context.Context, username string) ([]notify.Device, error)
+	RemovePushDevice(ctx context.Context, username, token string) (bool, error)
+	MarkPushSent(ctx context.Context, username, key string, window time.Duration) (bool, error)
+	GetUser(ctx context.Context, username string) (*database.User, error)
+	CanAccessRobot(ctx context.Context, user *database.User, uuid string) (bool, error)
+}
+
+type queuedEvent struct {
+	eventType string
+	data      any
+}
+
+// Gateway matches events against users' notification rules and sends the
+// resulting pushes.
+type Gateway struct {
+	store   Store
+	senders map[string]Sender // by platform
+	dedup   time.Duration
+	queue   chan queuedEvent
+	now     func() time.Time
+
+	mu    sync.RWMutex
+	prefs map[string]*notify.Prefs // enabled preferences with rules, by username
+	match func(eventType string) bool
+}
+
+// Start runs the gateway configured in shared.AppConfig.Push until ctx is
+// cancelled. It returns immediately when push is disabled.
+func Start(ctx context.Context, eb event_bus.EventBus, rds *database.RedisHandler) error {
+	cfg := shared.AppConfig.Push
+	if !cfg.Enabled {
+		return nil
+	}
+	if rds == nil {
+		return fmt.Errorf("push: notifications need Redis")
+	}
+
+	senders := make(map[string]Sender)
+	if cfg.FCM.CredentialsFile != "" {
+		s, err := newFCMSender(cfg.FCM.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		senders[notify.PlatformFCM] = s
+	}
+	if cfg.APNs.KeyFile != "" {
+		s, err := newAPNsSender(cfg.APNs)
+		if err != nil {
+			return err
+		}
+		senders[notify.PlatformAPNs] = s
+	}
+	if len(senders) == 0 {
+		return fmt.Errorf("push: neither push.fcm nor push.apns is configured")
+	}
+
+	g := New(rds, senders, cfg.Dedup(), cfg.Buffer)
+	if err := g.Reload(ctx); err != nil {
+		return fmt.Errorf("push: failed to load notification preferences: %w", err)
+	}
+	cancel := eb.Tap(g.handle)
+	defer cancel()
+
+	shared.DebugPrint("Push gateway started (%d platform(s), dedup %v)", len(senders), cfg.Dedup())
+	g.Run(ctx)
+	return nil
+}
+
+// New creates a gateway sending through senders (by platform), queueing
+// up to buffer events.
+func New(store Store, senders map[string]Sender, dedup time.Duration, buffer int) *Gateway {
+	if buffer <= 0 {
+		buffer = 1024
+	}
+	return &Gateway{
+		store:   store,
+		senders: senders,
+		dedup:   dedup,
+		queue:   make(chan queuedEvent, buffer),
+		now:     time.Now,
+		prefs:   make(map[string]*notify.Prefs),
+		match:   func(string) bool { return false },
+	}
+}
+
+// handle is the event bus tap; it runs on the publisher's goroutine, so it
+// only filters and enqueues.
+func (g *Gateway) handle(event event_bus.Event) {
+	eventType := event.GetType()
+	if eventType != events.PushPrefsChanged {
+		// Events relayed from other instances are pushed where they happened.
+		if event_bus.IsRemote(event) {
+			return
+		}
+		g.mu.RLock()
+		match := g.match
+		g.mu.RUnlock()
+		if !match(eventType) {
+			return
+		}
+	}
+	select {
+	case g.queue <- queuedEvent{eventType: eventType, data: event.GetData()}:
+	default:
+		shared.DebugPrint("Push queue full, dropping event: %s", eventType)
+	}
+}
+
+// Run processes queued events until ctx is cancelled.
+func (g *Gateway) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := g.Reload(ctx); err != nil {
+				shared.DebugPrint("Push: failed to reload notification preferences: %v", err)
+			}
+		case ev := <-g.queue:
+			if ev.eventType == events.PushPrefsChanged {
+				g.reloadUser(ctx, usernameOf(ev.data))
+				continue
+			}
+			g.process(ctx, ev)
+		}
+	}
+}
+
+// Reload loads every user's preferences.
+func (g *Gateway) Reload(ctx context.Context) error {
+	users, err := g.store.ListNotificationUsers(ctx)
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]*notify.Prefs, len(users))
+	for _, username := range users {
+		prefs, err := g.store.GetNotificationPrefs(ctx, username)
+		if err != nil {
+			return err
+		}
+		if active(prefs) {
+			loaded[username] = prefs
+		}
+	}
+	g.mu.Lock()
+	g.prefs = loaded
+	g.rebuildLocked()
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *Gateway) reloadUser(ctx context.Context, username string) {
+	if username == "" {
+		return
+	}
+	prefs, err := g.store.GetNotificationPrefs(ctx, username)
+	if err != nil {
+		shared.DebugPrint("Push: failed to reload preferences of %s: %v", username, err)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if active(prefs) {
+		g.prefs[username] = prefs
+	} else {
+		delete(g.prefs, username)
+	}
+	g.rebuildLocked()
+}
+
+// rebuildLocked recomputes the matcher of event types any user wants.
+func (g *Gateway) rebuildLocked() {
+	var patterns []string
+	for _, prefs := range g.prefs {
+		patterns = append(patterns, prefs.Patterns()...)
+	}
+	g.match = events.Matcher(patterns)
+}
+
+func active(prefs *notify.Prefs) bool {
+	return prefs != nil && prefs.Enabled && len(prefs.Rules) > 0
+}
+
+func usernameOf(data any) string {
+	switch v := data.(type) {
+	case map[string]string:
+		return v["username"]
+	case map[string]any:
+		name, _ := v["username"].(string)
+		return name
+	}
+	return ""
+}
+
+// process pushes one event to every user with a rule for it.
+func (g *Gateway) process(ctx context.Context, ev queuedEvent) {
+	robot := notify.RobotOf(ev.data)
+
+	g.mu.RLock()
+	users := maps.Clone(g.prefs)
+	g.mu.RUnlock()
+
+	for _, username := range slices.Sorted(maps.Keys(users)) {
+		prefs := users[username]
+		i := prefs.Match(ev.eventType, robot)
+		if i < 0 {
+			continue
+		}
+		rule := prefs.Rules[i]
+		if !rule.Critical && prefs.QuietHours.Active(g.now()) {
+			continue
+		}
+		if !g.allowed(ctx, username, robot) {
+			continue
+		}
+		first, err := g.store.MarkPushSent(ctx, username, fmt.Sprintf("%d:%s:%s", i, ev.eventType, robot), g.dedup)
+		if err != nil {
+			shared.DebugPrint("Push: dedup check for %s failed: %v", username, err)
+			continue
+		}
+		if !first {
+			continue
+		}
+		g.send(ctx, username, notification(rule, ev, robot))
+	}
+}
+
+// allowed reports whether a user may hear about a robot's events; events
+// not about a robot go to admins only.
+func (g *Gateway) allowed(ctx context.Context, username, robot string) bool {
+	user, err := g.store.GetUser(ctx, username)
+	if err != nil || user == nil {
+		return false
+	}
+	if robot == "" {
+		return user.IsAdmin()
+	}
+	ok, err := g.store.CanAccessRobot(ctx, user, robot)
+	return err == nil && ok
+}
+
+func notification(rule notify.Rule, ev queuedEvent, robot string) Notification {
+	title, body := rule.Title, rule.Body
+	if title == "" {
+		title = "{type}"
+	}
+	if body == "" && robot != "" {
+		body = "Robot {robot}"
+	}
+	collapse := ev.eventType + ":" + robot
+	if len(collapse) > maxCollapseKey {
+		collapse = collapse[:maxCollapseKey]
+	}
+	return Notification{
+		Title:    notify.Render(title, ev.eventType, robot, ev.data),
+		Body:     notify.Render(body, ev.eventType, robot, ev.data),
+		Data:     map[string]string{"type": ev.eventType, "robot": robot},
+		Collapse: collapse,
+		Critical: rule.Critical,
+	}
+}
+
+// send delivers a notification to each of a user's devices, unregistering
+// devices whose platform no longer accepts their token.
+func (g *Gateway) send(ctx context.Context, username string, n Notification) {
+	devices, err := g.store.ListPushDevices(ctx, username)
+	if err != nil {
+		shared.DebugPrint("Push: failed to list devices of %s: %v", username, err)
+		return
+	}
+	for _, d := range devices {
+		sender, ok := g.senders[d.Platform]
+		if !ok {
+			continue
+		}
+		err := sender.Send(ctx, d.Token, n)
+		switch {
+		case errors.Is(err, ErrUnregistered):
+			shared.DebugPrint("Push: unregistering %s device %q of %s", d.Platform, d.Name, username)
+			g.store.RemovePushDevice(ctx, username, d.Token)
+		case err != nil:
+			shared.DebugPrint("Push to %s (%s) failed: %v", username, d.Platform, err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package push
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"roboserver/database"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"roboserver/shared/notify"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	prefs   map[string]*notify.Prefs
+	devices map[string][]notify.Device
+	users   map[string]*database.User
+	acl     map[string][]string // robot -> usernames
+	sent    map[string]bool
+}
+
+func (m *memStore) ListNotificationUsers(ctx context.Context) ([]string, error) {
+	var users []string
+	for name := range m.prefs {
+		users = append(users, name)
+	}
+	return users, nil
+}
+
+func (m *memStore) GetNotificationPrefs(ctx context.Context, username string) (*notify.Prefs, error) {
+	return m.prefs[username], nil
+}
+
+func (m *memStore) ListPushDevices(ctx context.Context, username string) ([]notify.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.devices[username], nil
+}
+
+func (m *memStore) RemovePushDevice(ctx context.Context, username, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.devices[username][:0]
+	for _, d := range m.devices[username] {
+		if d.Token != token {
+			kept = append(kept, d)
+		}
+	}
+	m.devices[username] = kept
+	return true, nil
+}
+
+func (m *memStore) MarkPushSent(ctx context.Context, username, key string, window time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sent[username+"|"+key] {
+		return false, nil
+	}
+	m.sent[username+"|"+key] = true
+	return true, nil
+}
+
+func (m *memStore) GetUser(ctx context.Context, username string) (*database.User, error) {
+	if u, ok := m.users[username]; ok {
+		return u, nil
+	}
+	return nil, errors.New("no such user")
+}
+
+func (m *memStore) CanAccessRobot(ctx context.Context, user *database.User, uuid string) (bool, error) {
+	if user.IsAdmin() {
+		return true, nil
+	}
+	for _, name := range m.acl[uuid] {
+		if name == user.Username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type sentPush struct {
+	token string
+	n     Notification
+}
+
+type fakeSender struct {
+	sent []sentPush
+	gone map[string]bool
+}
+
+func (f *fakeSender) Send(ctx context.Context, token string, n Notification) error {
+	if f.gone[token] {
+		return ErrUnregistered
+	}
+	f.sent = append(f.sent, sentPush{token, n})
+	return nil
+}
+
+func TestGatewayPushesMatchingRules(t *testing.T) {
+	lockRule := notify.Rule{Events: []string{"smart_lock.*"}, Title: "Door {state}", Body: "{robot}"}
+	store := &memStore{
+		prefs: map[string]*notify.Prefs{
+			"alice": {Enabled: true, Rules: []notify.Rule{lockRule}},
+			"bob":   {Enabled: true, Rules: []notify.Rule{lockRule}}, // no access to the lock
+			"carol": {Enabled: true, Rules: []notify.Rule{lockRule}, QuietHours: &notify.QuietHours{Start: "00:00", End: "23:59"}},
+			"dave":  {Enabled: false, Rules: []notify.Rule{lockRule}},
+		},
+		devices: map[string][]notify.Device{
+			"alice": {{Token: "a1", Platform: notify.PlatformFCM}, {Token: "a2", Platform: notify.PlatformAPNs}, {Token: "old", Platform: notify.PlatformFCM}},
+			"bob":   {{Token: "b1", Platform: notify.PlatformFCM}},
+			"carol": {{Token: "c1", Platform: notify.PlatformFCM}},
+			"dave":  {{Token: "d1", Platform: notify.PlatformFCM}},
+		},
+		users: map[string]*database.User{
+			"alice": {Username: "alice", Role: "operator"},
+			"bob":   {Username: "bob", Role: "operator"},
+			"carol": {Username: "carol", Role: "operator"},
+			"dave":  {Username: "dave", Role: "operator"},
+		},
+		acl:  map[string][]string{"lock-1": {"alice", "carol", "dave"}},
+		sent: map[string]bool{},
+	}
+	fcm := &fakeSender{gone: map[string]bool{"old": true}}
+	apns := &fakeSender{}
+	g := New(store, map[string]Sender{notify.PlatformFCM: fcm, notify.PlatformAPNs: apns}, time.Minute, 8)
+	g.now = func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC) }
+	if err := g.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	g.handle(event_bus.NewDefaultEvent("robot.lock-1.heartbeat", map[string]any{"uuid": "lock-1"}))
+	g.handle(event_bus.NewDefaultEvent("smart_lock.lock-1.state", map[string]any{"uuid": "lock-1", "state": "open"}))
+	g.handle(event_bus.NewDefaultEvent("smart_lock.lock-1.state", map[string]any{"uuid": "lock-1", "state": "open"}))
+	if len(g.queue) != 2 {
+		t.Fatalf("Expected only events some rule wants to be queued, got %d", len(g.queue))
+	}
+	for len(g.queue) > 0 {
+		g.process(context.Background(), <-g.queue)
+	}
+
+	if len(fcm.sent) != 1 || fcm.sent[0].token != "a1" || len(apns.sent) != 1 || apns.sent[0].token != "a2" {
+		t.Fatalf("Expected one push to each of alice's devices, got fcm=%v apns=%v", fcm.sent, apns.sent)
+	}
+	if n := fcm.sent[0].n; n.Title != "Door open" || n.Body != "lock-1" || n.Data["type"] != "smart_lock.lock-1.state" {
+		t.Errorf("Unexpected notification: %+v", n)
+	}
+	if devices := store.devices["alice"]; len(devices) != 2 {
+		t.Errorf("Expected the unregistered token to be removed, got %v", devices)
+	}
+}
+
+func TestGatewayCriticalRulesIgnoreQuietHours(t *testing.T) {
+	store := &memStore{
+		prefs: map[string]*notify.Prefs{"admin": {
+			Enabled:    true,
+			Rules:      []notify.Rule{{Events: []string{"robot.ip_conflict"}, Critical: true}},
+			QuietHours: &notify.QuietHours{Start: "22:00", End: "07:00"},
+		}},
+		devices: map[string][]notify.Device{"admin": {{Token: "t", Platform: notify.PlatformFCM}}},
+		users:   map[string]*database.User{"admin": {Username: "admin", Role: database.RoleAdmin}},
+		sent:    map[string]bool{},
+	}
+	fcm := &fakeSender{}
+	g := New(store, map[string]Sender{notify.PlatformFCM: fcm}, time.Minute, 8)
+	g.now = func() time.Time { return time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC) }
+	g.Reload(context.Background())
+
+	g.process(context.Background(), queuedEvent{eventType: events.IPConflict, data: map[string]any{"ip": "10.0.0.5"}})
+	if len(fcm.sent) != 1 || !fcm.sent[0].n.Critical || fcm.sent[0].n.Title != events.IPConflict {
+		t.Errorf("Expected a critical push during quiet hours, got %v", fcm.sent)
+	}
+}
+
+func TestGatewayReloadsChangedPrefs(t *testing.T) {
+	store := &memStore{prefs: map[string]*notify.Prefs{}, sent: map[string]bool{}}
+	g := New(store, nil, time.Minute, 8)
+	g.Reload(context.Background())
+	if g.match("smart_lock.r1.state") {
+		t.Fatal("Expected no events matched without preferences")
+	}
+
+	store.prefs["alice"] = &notify.Prefs{Enabled: true, Rules: []notify.Rule{{Events: []string{"smart_lock.*"}}}}
+	g.handle(&event_bus.RemoteEvent{DefaultEvent: event_bus.DefaultEvent{Type: events.PushPrefsChanged, Data: map[string]any{"username": "alice"}}})
+	if len(g.queue) != 1 {
+		t.Fatalf("Expected the change to be queued, even from another node")
+	}
+	ev := <-g.queue
+	g.reloadUser(context.Background(), usernameOf(ev.data))
+	if !g.match("smart_lock.r1.state") {
+		t.Error("Expected alice's rule to be matched after the reload")
+	}
+}
+
+func TestAPNsSender(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var got *http.Request
+	var payload map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		json.NewDecoder(r.Body).Decode(&payload)
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		}
+	}))
+	defer srv.Close()
+
+	a := &apnsSender{host: srv.URL, keyID: "KEY123", teamID: "TEAM45", topic: "com.example.robomesh", key: key, client: srv.Client()}
+	err := a.Send(context.Background(), "abc123", Notification{Title: "Door open", Body: "lock-1", Collapse: "k", Critical: true, Data: map[string]string{"robot": "lock-1"}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got.URL.Path != "/3/device/abc123" || got.Header.Get("apns-topic") != "com.example.robomesh" || got.Header.Get("apns-priority") != "10" || got.Header.Get("apns-collapse-id") != "k" {
+		t.Errorf("Unexpected request: %s %v", got.URL.Path, got.Header)
+	}
+	if aps, _ := payload["aps"].(map[string]any); aps["interruption-level"] != "time-sensitive" || payload["robot"] != "lock-1" {
+		t.Errorf("Unexpected payload: %v", payload)
+	}
+
+	header, claims := verifyJWT(t, strings.TrimPrefix(got.Header.Get("Authorization"), "bearer "), &key.PublicKey)
+	if header["alg"] != "ES256" || header["kid"] != "KEY123" || claims["iss"] != "TEAM45" {
+		t.Errorf("Unexpected provider token: %v %v", header, claims)
+	}
+
+	if err := a.Send(context.Background(), "gone", Notification{}); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("Expected ErrUnregistered for a 410, got %v", err)
+	}
+}
+
+func TestFCMSender(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: mustPKCS8(t, key)})
+
+	exchanges := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			exchanges++
+			body, _ := io.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(body))
+			_, claims := verifyJWT(t, form.Get("assertion"), &key.PublicKey)
+			if claims["iss"] != "push@example.iam.gserviceaccount.com" || claims["aud"] != srv.URL+"/token" || claims["scope"] != fcmScope {
+				t.Errorf("Unexpected assertion claims: %v", claims)
+			}
+			w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600}`))
+		case "/v1/projects/robomesh/messages:send":
+			if r.Header.Get("Authorization") != "Bearer ya29.test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var body struct {
+				Message struct {
+					Token        string            `json:"token"`
+					Notification map[string]string `json:"notification"`
+				} `json:"message"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Message.Token == "gone" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+				return
+			}
+			if body.Message.Notification["title"] != "Door open" {
+				t.Errorf("Unexpected message: %+v", body.Message)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	creds, _ := json.Marshal(serviceAccount{ProjectID: "robomesh", ClientEmail: "push@example.iam.gserviceaccount.com", PrivateKey: string(pemKey), TokenURI: srv.URL + "/token"})
+	path := filepath.Join(t.TempDir(), "firebase.json")
+	os.WriteFile(path, creds, 0o600)
+	f, err := newFCMSender(path)
+	if err != nil {
+		t.Fatalf("newFCMSender failed: %v", err)
+	}
+	f.endpoint = srv.URL + "/v1/projects/robomesh/messages:send"
+
+	if err := f.Send(context.Background(), "tok", Notification{Title: "Door open"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := f.Send(context.Background(), "gone", Notification{Title: "Door open"}); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("Expected ErrUnregistered, got %v", err)
+	}
+	if exchanges != 1 {
+		t.Errorf("Expected the access token to be reused, got %d exchanges", exchanges)
+	}
+}
+
+func mustPKCS8(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// verifyJWT checks a token's RS256 or ES256 signature and returns its
+// header and claims.
+func verifyJWT(t *testing.T, token string, pub crypto.PublicKey) (header, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Malformed JWT %q", token)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			t.Fatal("Invalid RS256 signature")
+		}
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 || !ecdsa.Verify(k, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			t.Fatal("Invalid ES256 signature")
+		}
+	}
+	for i, dst := range []*map[string]any{&header, &claims} {
+		raw, _ := base64.RawURLEncoding.DecodeString(parts[i])
+		json.Unmarshal(raw, dst)
+	}
+	return header, claims
+}
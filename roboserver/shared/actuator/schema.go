@@ -0,0 +1,140 @@
+// Package actuator defines the declarative schema generic_actuator robots
+// upload at provisioning time. The schema lists the commands the device
+// accepts (with typed parameters) and the state fields it reports, so a
+// one-off device can be controlled without writing a dedicated handler.
+//
+// Example:
+//
+//	{
+//	  "commands": {
+//	    "set_speed": {"params": {"rpm": {"type": "integer", "min": 0, "max": 3000, "required": true}}},
+//	    "stop": {}
+//	  },
+//	  "state": {
+//	    "rpm":  {"type": "integer"},
+//	    "mode": {"type": "enum", "values": ["idle", "running"]}
+//	  }
+//	}
+package actuator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// MaxSchemaBytes bounds an uploaded schema; it is stored per robot in Postgres.
+const MaxSchemaBytes = 64 * 1024
+
+const (
+	maxCommands = 64
+	maxFields   = 64
+)
+
+// Field types understood by the generic_actuator handler.
+const (
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeString  = "string"
+	TypeEnum    = "enum"
+)
+
+var nameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+
+type Field struct {
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Values   []string `json:"values,omitempty"` // enum only
+}
+
+type Command struct {
+	Description string           `json:"description,omitempty"`
+	Params      map[string]Field `json:"params,omitempty"`
+}
+
+type Schema struct {
+	Commands map[string]Command `json:"commands"`
+	State    map[string]Field   `json:"state,omitempty"`
+}
+
+// Parse decodes and validates a schema. Unknown keys are rejected so typos
+// surface at upload time rather than as silently ignored constraints.
+func Parse(raw []byte) (*Schema, error) {
+	if len(raw) > MaxSchemaBytes {
+		return nil, fmt.Errorf("schema exceeds %d bytes", MaxSchemaBytes)
+	}
+
+	var s Schema
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks names, field types and bounds.
+func (s *Schema) Validate() error {
+	if len(s.Commands) == 0 {
+		return fmt.Errorf("schema must declare at least one command")
+	}
+	if len(s.Commands) > maxCommands {
+		return fmt.Errorf("schema declares more than %d commands", maxCommands)
+	}
+	if len(s.State) > maxFields {
+		return fmt.Errorf("schema declares more than %d state fields", maxFields)
+	}
+
+	for name, cmd := range s.Commands {
+		if !nameRe.MatchString(name) {
+			return fmt.Errorf("invalid command name %q", name)
+		}
+		if len(cmd.Params) > maxFields {
+			return fmt.Errorf("command %s declares more than %d params", name, maxFields)
+		}
+		for param, f := range cmd.Params {
+			if err := validateField(param, f); err != nil {
+				return fmt.Errorf("command %s: %w", name, err)
+			}
+		}
+	}
+	for name, f := range s.State {
+		if err := validateField(name, f); err != nil {
+			return fmt.Errorf("state: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateField(name string, f Field) error {
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid field name %q", name)
+	}
+	switch f.Type {
+	case TypeNumber, TypeInteger:
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			return fmt.Errorf("field %s: min exceeds max", name)
+		}
+	case TypeBoolean, TypeString:
+		if f.Min != nil || f.Max != nil {
+			return fmt.Errorf("field %s: min/max only apply to numeric fields", name)
+		}
+	case TypeEnum:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("field %s: enum needs values", name)
+		}
+	default:
+		return fmt.Errorf("field %s: unknown type %q", name, f.Type)
+	}
+	if f.Type != TypeEnum && len(f.Values) > 0 {
+		return fmt.Errorf("field %s: values only apply to enum fields", name)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package actuator
+
+import "testing"
+
+func TestParseValidSchema(t *testing.T) {
+	raw := []byte(`{
+		"commands": {
+			"set_speed": {"params": {"rpm": {"type": "integer", "min": 0, "max": 3000, "required": true}}},
+			"stop": {}
+		},
+		"state": {"mode": {"type": "enum", "values": ["idle", "running"]}}
+	}`)
+
+	s, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Expected valid schema, got %v", err)
+	}
+	if !s.Commands["set_speed"].Params["rpm"].Required {
+		t.Error("Expected rpm to be required")
+	}
+	if len(s.State["mode"].Values) != 2 {
+		t.Errorf("Expected 2 enum values, got %v", s.State["mode"].Values)
+	}
+}
+
+func TestParseRejectsInvalidSchemas(t *testing.T) {
+	cases := map[string]string{
+		"no commands":     `{"commands": {}}`,
+		"unknown key":     `{"commands": {"go": {}}, "extra": 1}`,
+		"bad name":        `{"commands": {"go now": {}}}`,
+		"unknown type":    `{"commands": {"go": {"params": {"x": {"type": "float"}}}}}`,
+		"min over max":    `{"commands": {"go": {"params": {"x": {"type": "number", "min": 5, "max": 1}}}}}`,
+		"enum no values":  `{"commands": {"go": {}}, "state": {"m": {"type": "enum"}}}`,
+		"bounds on bool":  `{"commands": {"go": {"params": {"b": {"type": "boolean", "max": 1}}}}}`,
+		"values non-enum": `{"commands": {"go": {"params": {"s": {"type": "string", "values": ["a"]}}}}}`,
+		"not json":        `{"commands":`,
+	}
+	for name, raw := range cases {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseRejectsOversizedSchema(t *testing.T) {
+	raw := make([]byte, MaxSchemaBytes+1)
+	if _, err := Parse(raw); err == nil {
+		t.Error("Expected error for oversized schema")
+	}
+}
@@ -0,0 +1,223 @@
+// Package adaptive recommends how often a robot should send each type of
+// DATA report. Many sensors report on a fixed, fast schedule whether or not
+// anything changes; the server watches the last window of reports of each
+// type and, when their metrics hold steady, asks the robot to report less
+// often, and when they swing, more often. While the node is busier than
+// adaptive_polling.max_message_rate every recommendation is stretched in
+// proportion. A recommendation reaches the robot as
+//
+//	INTERVAL <type> <milliseconds>
+//
+// which robots are free to ignore.
+package adaptive
+
+import (
+	"math"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/telemetry"
+	"slices"
+	"time"
+)
+
+// busyFactor is how many times the threshold a metric's variation must
+// reach before reports are asked to come faster.
+const busyFactor = 4
+
+// minChange is the least relative change worth pushing.
+const minChange = 0.25
+
+// Reasons for an adjustment.
+const (
+	ReasonSteady = "steady" // metrics held steady: slow down
+	ReasonBusy   = "busy"   // metrics swung: speed up
+	ReasonLoad   = "load"   // the node is overloaded: slow down
+	ReasonBounds = "bounds" // the robot reports outside min/max_interval
+)
+
+// Adjustment is the payload of robot.{uuid}.interval: a reporting interval
+// pushed to a robot for one report type.
+type Adjustment struct {
+	UUID       string  `json:"uuid"`
+	Type       string  `json:"type"`        // DATA envelope type
+	IntervalMs int64   `json:"interval_ms"` // recommended
+	PreviousMs int64   `json:"previous_ms"` // last recommended, or the observed interval
+	ObservedMs int64   `json:"observed_ms"` // median gap of the window
+	Variation  float64 `json:"variation"`   // of the busiest metric
+	Load       float64 `json:"load"`        // node message rate over max_message_rate; 0 = not considered
+	Reason     string  `json:"reason"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotInterval("{uuid}"),
+		Description: "The server asked a robot to send a type of DATA report at a new interval (adaptive_polling). variation is the relative variation of the busiest metric over the last window of reports.",
+		Example:     Adjustment{UUID: "env-3", Type: "env", IntervalMs: 20000, PreviousMs: 10000, ObservedMs: 10000, Variation: 0.004, Reason: ReasonSteady},
+	})
+}
+
+// Settings are the tuning of a Tracker.
+type Settings struct {
+	Min       time.Duration
+	Max       time.Duration
+	Window    int
+	Threshold float64
+	Cooldown  time.Duration
+	// MaxMessageRate is the node message rate (per second) above which
+	// intervals are stretched; 0 ignores load.
+	MaxMessageRate float64
+}
+
+// FromConfig reads the tracker settings from adaptive_polling.
+func FromConfig(cfg *shared.AdaptivePollingConfig) Settings {
+	s := Settings{
+		Min:            cfg.MinEvery(),
+		Max:            cfg.MaxEvery(),
+		Window:         cfg.Window,
+		Threshold:      cfg.Threshold,
+		Cooldown:       cfg.CooldownDuration(),
+		MaxMessageRate: cfg.MaxMessageRate,
+	}
+	if s.Window < 3 {
+		s.Window = 20
+	}
+	if s.Threshold <= 0 {
+		s.Threshold = 0.02
+	}
+	return s
+}
+
+// Applies reports whether robots of deviceType are sent recommendations.
+func Applies(cfg *shared.AdaptivePollingConfig, deviceType string) bool {
+	return cfg.Enabled && (len(cfg.DeviceTypes) == 0 || slices.Contains(cfg.DeviceTypes, deviceType))
+}
+
+// Tracker follows the reports of one robot connection. It is not safe for
+// concurrent use; a session feeds it from its read loop.
+type Tracker struct {
+	settings Settings
+	streams  map[string]*stream
+}
+
+type stream struct {
+	reports  []telemetry.Envelope
+	current  time.Duration // last recommended; 0 = none yet
+	pushedAt time.Time
+}
+
+func NewTracker(s Settings) *Tracker {
+	return &Tracker{settings: s, streams: make(map[string]*stream)}
+}
+
+// Observe records a report. load is the node's current message rate per
+// second. When the window of env's type is full and warrants a new
+// interval, it returns the adjustment to push (without UUID) and starts a
+// new window, so the next decision sees the robot's new pace.
+func (t *Tracker) Observe(env telemetry.Envelope, now time.Time, load float64) (Adjustment, bool) {
+	st := t.streams[env.Type]
+	if st == nil {
+		st = &stream{}
+		t.streams[env.Type] = st
+	}
+	st.reports = append(st.reports, env)
+	if len(st.reports) < t.settings.Window {
+		return Adjustment{}, false
+	}
+	if !st.pushedAt.IsZero() && now.Sub(st.pushedAt) < t.settings.Cooldown {
+		st.reports = st.reports[1:]
+		return Adjustment{}, false
+	}
+
+	adj, ok := t.decide(st, env.Type, load)
+	st.reports = st.reports[:0]
+	if ok {
+		st.current = time.Duration(adj.IntervalMs) * time.Millisecond
+		st.pushedAt = now
+	}
+	return adj, ok
+}
+
+// decide computes the interval a full window calls for.
+func (t *Tracker) decide(st *stream, envType string, load float64) (Adjustment, bool) {
+	s := t.settings
+	observed := medianGap(st.reports)
+	if observed <= 0 {
+		return Adjustment{}, false
+	}
+	base := st.current
+	if base == 0 {
+		base = observed
+	}
+
+	adj := Adjustment{Type: envType, ObservedMs: observed.Milliseconds(), PreviousMs: base.Milliseconds(), Variation: variation(st.reports)}
+	target := base
+	switch {
+	case adj.Variation < s.Threshold:
+		target, adj.Reason = base*2, ReasonSteady
+	case adj.Variation > busyFactor*s.Threshold:
+		target, adj.Reason = base/2, ReasonBusy
+	}
+	if s.MaxMessageRate > 0 {
+		adj.Load = math.Round(load/s.MaxMessageRate*100) / 100
+		if adj.Load > 1 {
+			target = time.Duration(float64(target) * adj.Load)
+			adj.Reason = ReasonLoad
+		}
+	}
+	if clamped := min(max(target, s.Min), s.Max); clamped != target || adj.Reason == "" {
+		target = clamped
+		if adj.Reason == "" {
+			adj.Reason = ReasonBounds
+		}
+	}
+	target = target.Round(100 * time.Millisecond)
+
+	if math.Abs(float64(target-base))/float64(base) < minChange {
+		return Adjustment{}, false
+	}
+	adj.IntervalMs = target.Milliseconds()
+	return adj, true
+}
+
+// medianGap returns the median time between consecutive reports.
+func medianGap(reports []telemetry.Envelope) time.Duration {
+	gaps := make([]int64, 0, len(reports)-1)
+	for i := 1; i < len(reports); i++ {
+		if gap := reports[i].Timestamp - reports[i-1].Timestamp; gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	slices.Sort(gaps)
+	return time.Duration(gaps[len(gaps)/2]) * time.Millisecond
+}
+
+// variation returns the largest relative variation (standard deviation
+// over the mean's magnitude, at least 1) among the metrics every report
+// carries. A metric around zero is judged by its absolute spread.
+func variation(reports []telemetry.Envelope) float64 {
+	var worst float64
+	for metric := range reports[0].Metrics {
+		var sum, sumSq float64
+		complete := true
+		for _, r := range reports {
+			v, ok := r.Metrics[metric]
+			if !ok {
+				complete = false
+				break
+			}
+			sum += v
+			sumSq += v * v
+		}
+		if !complete {
+			continue
+		}
+		n := float64(len(reports))
+		mean := sum / n
+		stddev := math.Sqrt(math.Max(sumSq/n-mean*mean, 0))
+		worst = math.Max(worst, stddev/math.Max(math.Abs(mean), 1))
+	}
+	return math.Round(worst*10000) / 10000
+}
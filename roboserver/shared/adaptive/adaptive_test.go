@@ -0,0 +1,130 @@
+package adaptive
+
+import (
+	"roboserver/shared"
+	"roboserver/shared/telemetry"
+	"testing"
+	"time"
+)
+
+var testSettings = Settings{Min: time.Second, Max: time.Minute, Window: 5, Threshold: 0.02, Cooldown: time.Minute}
+
+// feed sends a window of reports every gap, with metric values from values
+// (cycled), and returns the last Observe result.
+func feed(tr *Tracker, start time.Time, gap time.Duration, values []float64, load float64) (Adjustment, bool, time.Time) {
+	var adj Adjustment
+	var ok bool
+	now := start
+	for i := 0; i < tr.settings.Window; i++ {
+		env := telemetryEnv(now, values[i%len(values)])
+		adj, ok = tr.Observe(env, now, load)
+		now = now.Add(gap)
+	}
+	return adj, ok, now
+}
+
+func TestSteadyReportsSlowDown(t *testing.T) {
+	tr := NewTracker(testSettings)
+	adj, ok, _ := feed(tr, time.UnixMilli(1_000_000), 5*time.Second, []float64{21.5, 21.51}, 0)
+	if !ok {
+		t.Fatal("Expected an adjustment for steady reports")
+	}
+	if adj.IntervalMs != 10000 || adj.PreviousMs != 5000 || adj.ObservedMs != 5000 || adj.Reason != ReasonSteady {
+		t.Errorf("Unexpected adjustment %+v", adj)
+	}
+}
+
+func TestBusyReportsSpeedUp(t *testing.T) {
+	tr := NewTracker(testSettings)
+	adj, ok, _ := feed(tr, time.UnixMilli(1_000_000), 10*time.Second, []float64{10, 30}, 0)
+	if !ok || adj.IntervalMs != 5000 || adj.Reason != ReasonBusy {
+		t.Errorf("Expected a busy stream to be sped up to 5s, got %+v, %v", adj, ok)
+	}
+}
+
+func TestModerateReportsKeepTheirInterval(t *testing.T) {
+	tr := NewTracker(testSettings)
+	// Variation around 5%: between the threshold and four times it.
+	if adj, ok, _ := feed(tr, time.UnixMilli(1_000_000), 5*time.Second, []float64{20, 22}, 0); ok {
+		t.Errorf("Expected no adjustment, got %+v", adj)
+	}
+}
+
+func TestIntervalsStayWithinBounds(t *testing.T) {
+	tr := NewTracker(testSettings)
+	// A robot reporting five times a second is asked for min_interval
+	// even though its readings swing.
+	adj, ok, _ := feed(tr, time.UnixMilli(1_000_000), 200*time.Millisecond, []float64{10, 30}, 0)
+	if !ok || adj.IntervalMs != 1000 || adj.Reason != ReasonBusy {
+		t.Errorf("Expected the interval raised to 1s, got %+v, %v", adj, ok)
+	}
+
+	tr = NewTracker(testSettings)
+	adj, ok, _ = feed(tr, time.UnixMilli(1_000_000), 45*time.Second, []float64{5}, 0)
+	if !ok || adj.IntervalMs != 60000 {
+		t.Errorf("Expected the interval capped at 1m, got %+v, %v", adj, ok)
+	}
+}
+
+func TestLoadStretchesIntervals(t *testing.T) {
+	s := testSettings
+	s.MaxMessageRate = 100
+	tr := NewTracker(s)
+	adj, ok, _ := feed(tr, time.UnixMilli(1_000_000), 5*time.Second, []float64{20, 22}, 300)
+	if !ok || adj.IntervalMs != 15000 || adj.Load != 3 || adj.Reason != ReasonLoad {
+		t.Errorf("Expected the interval tripled under 3x load, got %+v, %v", adj, ok)
+	}
+}
+
+func TestCooldownAndNewWindow(t *testing.T) {
+	tr := NewTracker(testSettings)
+	start := time.UnixMilli(1_000_000)
+	_, ok, now := feed(tr, start, 5*time.Second, []float64{21.5}, 0)
+	if !ok {
+		t.Fatal("Expected a first adjustment")
+	}
+	// The robot follows: 10s reports, still steady, but within the cooldown.
+	if adj, ok, _ := feed(tr, now, 10*time.Second, []float64{21.5}, 0); ok {
+		t.Errorf("Expected no adjustment within the cooldown, got %+v", adj)
+	}
+	// After the cooldown the next decision builds on the pushed interval.
+	adj, ok := tr.Observe(telemetryEnv(start.Add(3*time.Minute), 21.5), start.Add(3*time.Minute), 0)
+	if !ok || adj.PreviousMs != 10000 || adj.IntervalMs != 20000 {
+		t.Errorf("Expected 10s doubled to 20s after the cooldown, got %+v, %v", adj, ok)
+	}
+}
+
+func TestTypesAreTrackedSeparately(t *testing.T) {
+	tr := NewTracker(testSettings)
+	now := time.UnixMilli(1_000_000)
+	for i := 0; i < testSettings.Window-1; i++ {
+		env := telemetryEnv(now, 1)
+		if _, ok := tr.Observe(env, now, 0); ok {
+			t.Fatal("Unexpected adjustment before the window filled")
+		}
+		env.Type = "power"
+		if _, ok := tr.Observe(env, now, 0); ok {
+			t.Fatal("Unexpected adjustment before the window filled")
+		}
+		now = now.Add(5 * time.Second)
+	}
+}
+
+func TestApplies(t *testing.T) {
+	cfg := &shared.AdaptivePollingConfig{}
+	if Applies(cfg, "lamp") {
+		t.Error("Expected adaptive polling off by default")
+	}
+	cfg.Enabled = true
+	if !Applies(cfg, "lamp") {
+		t.Error("Expected every type without device_types")
+	}
+	cfg.DeviceTypes = []string{"env_sensor"}
+	if Applies(cfg, "lamp") || !Applies(cfg, "env_sensor") {
+		t.Error("Expected only the listed device types")
+	}
+}
+
+func telemetryEnv(at time.Time, v float64) telemetry.Envelope {
+	return telemetry.Envelope{Type: "env", Metrics: map[string]float64{"temp_c": v}, Timestamp: at.UnixMilli()}
+}
@@ -0,0 +1,174 @@
+// Package autoaccept decides whether a REGISTER request is accepted without
+// an operator. Headless deployments can't wait out the registration
+// timeout for someone to approve each robot, so a site can admit robots
+// whose UUID it already knows, robots of listed device types, or robots
+// connecting from trusted subnets. Policies combine: a robot is accepted if
+// any of them admits it. The configured policies (auto_accept) can be
+// replaced at runtime; see handler_engine.AutoAcceptSettings.
+package autoaccept
+
+import (
+	"fmt"
+	"net/netip"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"strings"
+)
+
+// Policies (auto_accept.policies).
+const (
+	Manual         = "manual"          // every registration waits for an operator
+	KnownIDs       = "known_ids"       // accept the UUIDs in device_ids
+	DeviceTypes    = "device_types"    // accept the types in device_types
+	TrustedSubnets = "trusted_subnets" // accept addresses in trusted_subnets
+)
+
+// Sources of the settings in force.
+const (
+	SourceConfig  = "config"
+	SourceRuntime = "runtime"
+)
+
+// Settings are the auto-accept settings in force and where they came from.
+type Settings struct {
+	shared.AutoAcceptConfig
+	Source string `json:"source"`
+	By     string `json:"by,omitempty"` // who set them at runtime
+	At     int64  `json:"at,omitempty"` // Unix seconds
+}
+
+// Accepted is the payload of the robot.auto_accepted event.
+type Accepted struct {
+	UUID       string `json:"uuid"`
+	IP         string `json:"ip"`
+	DeviceType string `json:"device_type"`
+	Policy     string `json:"policy"`
+	Time       int64  `json:"time"` // Unix seconds
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotAutoAccepted,
+		Description: "A registration was accepted without an operator; policy is the auto_accept policy that admitted the robot.",
+		Example:     Accepted{UUID: "rover-9", IP: "10.0.4.17", DeviceType: "rover", Policy: TrustedSubnets, Time: 1718000000},
+	})
+}
+
+// Policy is a compiled set of auto-accept settings.
+type Policy struct {
+	policies []string
+	ids      map[string]bool
+	types    map[string]bool
+	subnets  []netip.Prefix
+}
+
+// Compile checks cfg. An enabled policy needs its list; manual can't be
+// combined with the others.
+func Compile(cfg shared.AutoAcceptConfig) (*Policy, error) {
+	p := &Policy{
+		ids:   make(map[string]bool, len(cfg.DeviceIDs)),
+		types: make(map[string]bool, len(cfg.DeviceTypes)),
+	}
+	for _, id := range cfg.DeviceIDs {
+		p.ids[id] = true
+	}
+	for _, t := range cfg.DeviceTypes {
+		p.types[t] = true
+	}
+	for _, s := range cfg.TrustedSubnets {
+		prefix, err := parseSubnet(s)
+		if err != nil {
+			return nil, err
+		}
+		p.subnets = append(p.subnets, prefix)
+	}
+
+	seen := make(map[string]bool, len(cfg.Policies))
+	for _, name := range cfg.Policies {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case Manual:
+			continue
+		case KnownIDs:
+			if len(p.ids) == 0 {
+				return nil, fmt.Errorf("auto_accept policy %s needs device_ids", name)
+			}
+		case DeviceTypes:
+			if len(p.types) == 0 {
+				return nil, fmt.Errorf("auto_accept policy %s needs device_types", name)
+			}
+		case TrustedSubnets:
+			if len(p.subnets) == 0 {
+				return nil, fmt.Errorf("auto_accept policy %s needs trusted_subnets", name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown auto_accept policy %q (want %s, %s, %s or %s)", name, Manual, KnownIDs, DeviceTypes, TrustedSubnets)
+		}
+		p.policies = append(p.policies, name)
+	}
+	if seen[Manual] && len(p.policies) > 0 {
+		return nil, fmt.Errorf("auto_accept policy %s can't be combined with %s", Manual, strings.Join(p.policies, ", "))
+	}
+	return p, nil
+}
+
+// parseSubnet reads a CIDR, or a single address as a one-address subnet.
+func parseSubnet(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid auto_accept subnet %q: %w", s, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid auto_accept subnet %q: %w", s, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Manual reports whether every registration waits for an operator.
+func (p *Policy) Manual() bool {
+	return len(p.policies) == 0
+}
+
+// Match returns the first policy that admits a robot registering as uuid
+// and deviceType from ip, or "" if it has to wait for an operator.
+func (p *Policy) Match(uuid, deviceType, ip string) string {
+	for _, name := range p.policies {
+		switch name {
+		case KnownIDs:
+			if p.ids[uuid] {
+				return name
+			}
+		case DeviceTypes:
+			if p.types[deviceType] {
+				return name
+			}
+		case TrustedSubnets:
+			if p.trusted(ip) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func (p *Policy) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(shared.CanonicalIP(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.WithZone("")
+	for _, prefix := range p.subnets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package autoaccept
+
+import (
+	"roboserver/shared"
+	"testing"
+)
+
+func TestCompileRejectsBadSettings(t *testing.T) {
+	cases := map[string]shared.AutoAcceptConfig{
+		"unknown policy":   {Policies: []string{"everyone"}},
+		"ids missing":      {Policies: []string{KnownIDs}},
+		"types missing":    {Policies: []string{DeviceTypes}},
+		"subnets missing":  {Policies: []string{TrustedSubnets}},
+		"bad subnet":       {Policies: []string{TrustedSubnets}, TrustedSubnets: []string{"10.0.0.0/33"}},
+		"bad address":      {TrustedSubnets: []string{"lab"}},
+		"manual and other": {Policies: []string{Manual, DeviceTypes}, DeviceTypes: []string{"lamp"}},
+	}
+	for name, cfg := range cases {
+		if _, err := Compile(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestManual(t *testing.T) {
+	for _, cfg := range []shared.AutoAcceptConfig{
+		{},
+		{Policies: []string{Manual}},
+		{Policies: []string{Manual}, DeviceTypes: []string{"lamp"}},
+	} {
+		p, err := Compile(cfg)
+		if err != nil {
+			t.Fatalf("Compile(%+v): %v", cfg, err)
+		}
+		if !p.Manual() {
+			t.Errorf("Compile(%+v) is not manual", cfg)
+		}
+		if got := p.Match("lamp-1", "lamp", "10.0.0.5"); got != "" {
+			t.Errorf("Manual policy admitted a robot via %q", got)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	p, err := Compile(shared.AutoAcceptConfig{
+		Policies:       []string{KnownIDs, DeviceTypes, TrustedSubnets},
+		DeviceIDs:      []string{"rover-1"},
+		DeviceTypes:    []string{"lamp"},
+		TrustedSubnets: []string{"10.0.4.0/24", "192.168.1.7", "fd00:1::/64"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		uuid, deviceType, ip, want string
+	}{
+		{"rover-1", "rover", "203.0.113.9", KnownIDs},
+		{"lamp-3", "lamp", "203.0.113.9", DeviceTypes},
+		{"rover-2", "rover", "10.0.4.17", TrustedSubnets},
+		{"rover-2", "rover", "10.0.4.17:5002", TrustedSubnets},
+		{"rover-2", "rover", "::ffff:10.0.4.17", TrustedSubnets},
+		{"rover-2", "rover", "192.168.1.7", TrustedSubnets},
+		{"rover-2", "rover", "fd00:1::42", TrustedSubnets},
+		{"rover-2", "rover", "192.168.1.8", ""},
+		{"rover-2", "rover", "10.0.5.1", ""},
+		{"rover-2", "rover", "", ""},
+	}
+	for _, c := range cases {
+		if got := p.Match(c.uuid, c.deviceType, c.ip); got != c.want {
+			t.Errorf("Match(%q, %q, %q) = %q, want %q", c.uuid, c.deviceType, c.ip, got, c.want)
+		}
+	}
+}
+
+func TestMatchOnlyEnabledPolicies(t *testing.T) {
+	// Lists of disabled policies are kept but don't admit anyone.
+	p, err := Compile(shared.AutoAcceptConfig{
+		Policies:    []string{DeviceTypes},
+		DeviceIDs:   []string{"rover-1"},
+		DeviceTypes: []string{"lamp"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Match("rover-1", "rover", "10.0.0.5"); got != "" {
+		t.Errorf("Disabled known_ids admitted rover-1 via %q", got)
+	}
+}
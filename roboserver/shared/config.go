@@ -1,10 +1,12 @@
 package shared
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -13,8 +15,9 @@ import (
 // AppConfig is the global application configuration singleton.
 var AppConfig Config
 
-// DEBUG_MODE controls debug logging throughout the server.
-var DEBUG_MODE = false
+// DEBUG_MODE controls debug logging throughout the server. It is atomic
+// because ReloadConfig can flip it while other goroutines are logging.
+var DEBUG_MODE atomic.Bool
 
 const (
 	EVENT_BUS_BUFFER_SIZE = 1000
@@ -27,15 +30,451 @@ type Config struct {
 	Auth     AuthConfig     `yaml:"auth"`
 	Handlers HandlersConfig `yaml:"handlers"`
 	Timeouts TimeoutsConfig `yaml:"timeouts"`
+	Presence PresenceConfig `yaml:"presence"`
+	Events   EventsConfig   `yaml:"events"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Exporter ExporterConfig `yaml:"exporter"`
+	Limits   LimitsConfig   `yaml:"limits"`
+	Cluster  ClusterConfig  `yaml:"cluster"`
+
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+	IPConflict  IPConflictConfig  `yaml:"ip_conflict"`
+	AutoAccept  AutoAcceptConfig  `yaml:"auto_accept"`
+	Jobs        JobsConfig        `yaml:"jobs"`
+	Energy      EnergyConfig      `yaml:"energy"`
+	Automation  AutomationConfig  `yaml:"automation"`
+	Schedule    ScheduleConfig    `yaml:"schedule"`
+	Export      ExportConfig      `yaml:"export"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Discovery   DiscoveryConfig   `yaml:"discovery"`
+	Backup      BackupConfig      `yaml:"backup"`
+	TimeSync    TimeSyncConfig    `yaml:"time_sync"`
+	Push        PushConfig        `yaml:"push"`
+	SSE         SSEConfig         `yaml:"sse"`
+	Zones       ZonesConfig       `yaml:"zones"`
+
+	AdaptivePolling AdaptivePollingConfig `yaml:"adaptive_polling"`
+	Ingest          IngestConfig          `yaml:"ingest"`
+}
+
+// IngestConfig sets up POST /ingest, through which gateways report
+// readings for the sensors behind them (see shared/ingest).
+type IngestConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	DeviceType string `yaml:"device_type"` // device type of the virtual robots created
+	MaxRecords int    `yaml:"max_records"` // records accepted per request
+	// TTL is how long a virtual robot stays active after its last reading.
+	TTL string `yaml:"ttl"`
+}
+
+// TTLDuration returns how long a virtual robot stays active without
+// readings (default 10m).
+func (c *IngestConfig) TTLDuration() time.Duration {
+	d, err := time.ParseDuration(c.TTL)
+	if err != nil || d <= 0 {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// AdaptivePollingConfig tunes the reporting interval the server recommends
+// to robots sending DATA (see shared/adaptive).
+type AdaptivePollingConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	DeviceTypes []string `yaml:"device_types"` // robots sent INTERVAL; empty = every type
+	MinInterval string   `yaml:"min_interval"` // shortest interval recommended
+	MaxInterval string   `yaml:"max_interval"` // longest interval recommended
+	Window      int      `yaml:"window"`       // reports of one type judged together
+	// Threshold is the relative variation (standard deviation over mean)
+	// below which a metric counts as steady. Reports whose busiest metric
+	// varies less are asked to slow down; above four times it, to speed up.
+	Threshold float64 `yaml:"threshold"`
+	// MaxMessageRate is the node's messages per second (GET /admin/timeline)
+	// above which every interval is stretched in proportion; 0 = ignore load.
+	MaxMessageRate float64 `yaml:"max_message_rate"`
+	Cooldown       string  `yaml:"cooldown"` // least time between two INTERVALs for one type
+}
+
+// MinEvery returns the shortest recommended interval (default 1s).
+func (a *AdaptivePollingConfig) MinEvery() time.Duration {
+	d, err := time.ParseDuration(a.MinInterval)
+	if err != nil || d < 100*time.Millisecond {
+		return time.Second
+	}
+	return d
+}
+
+// MaxEvery returns the longest recommended interval (default 5m), at least
+// MinEvery.
+func (a *AdaptivePollingConfig) MaxEvery() time.Duration {
+	d, err := time.ParseDuration(a.MaxInterval)
+	if err != nil || d <= 0 {
+		d = 5 * time.Minute
+	}
+	return max(d, a.MinEvery())
+}
+
+// CooldownDuration returns the least time between two recommendations for
+// one report type (default 1m).
+func (a *AdaptivePollingConfig) CooldownDuration() time.Duration {
+	d, err := time.ParseDuration(a.Cooldown)
+	if err != nil || d < 0 {
+		return time.Minute
+	}
+	return d
+}
+
+// ZonesConfig lists the aggregates computed over the robots of each zone
+// (see zones/).
+type ZonesConfig struct {
+	Aggregates []ZoneAggregate `yaml:"aggregates"`
+}
+
+// ZoneAggregate is one value computed per zone from a state field of its
+// robots and published on zone.{zone}.{name}.
+type ZoneAggregate struct {
+	Name  string `yaml:"name"`
+	Field string `yaml:"field"` // dotted state path, as in GET /robot/{uuid}/state
+	Op    string `yaml:"op"`    // any, all, none, count, sum, min, max or avg
+	// Equals is the value a robot must report to match for any, all, none
+	// and count; unset, a robot matches when its value is truthy.
+	Equals any `yaml:"equals"`
+}
+
+// AutomationConfig controls user Lua scripts run against the event bus
+// (see automation/).
+type AutomationConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Dir       string `yaml:"dir"`        // *.lua scripts, loaded at startup and on reload
+	Timeout   string `yaml:"timeout"`    // CPU budget of one script call (load or event callback)
+	CallStack int    `yaml:"call_stack"` // Lua call depth limit
+	MaxStack  int    `yaml:"max_stack"`  // Lua value stack (registry) limit, in slots
+	Queue     int    `yaml:"queue"`      // events waiting per script before new ones are dropped
+}
+
+// CallTimeout returns the time limit of one script call (default 100ms).
+func (a *AutomationConfig) CallTimeout() time.Duration {
+	d, err := time.ParseDuration(a.Timeout)
+	if err != nil || d <= 0 {
+		return 100 * time.Millisecond
+	}
+	return d
+}
+
+// EnergyConfig controls energy usage tracking (see energy/).
+type EnergyConfig struct {
+	Enabled       bool               `yaml:"enabled"`
+	TelemetryType string             `yaml:"telemetry_type"` // DATA envelope type carrying power draw
+	Metric        string             `yaml:"metric"`         // metric of that envelope, in watts
+	FlushInterval string             `yaml:"flush_interval"` // how often usage is written to PostgreSQL
+	EstimateWatts map[string]float64 `yaml:"estimate_watts"` // device type → nominal draw for robots that report none
+}
+
+// FlushEvery returns how often usage is flushed and estimates are taken
+// (default 1m).
+func (e *EnergyConfig) FlushEvery() time.Duration {
+	d, err := time.ParseDuration(e.FlushInterval)
+	if err != nil || d < time.Second {
+		return time.Minute
+	}
+	return d
+}
+
+// ScheduleConfig bounds delayed and recurring robot messages (see
+// handler_engine.Scheduler).
+type ScheduleConfig struct {
+	MaxPending  int    `yaml:"max_pending"`  // scheduled messages held at once; more are refused
+	MinInterval string `yaml:"min_interval"` // shortest repeat interval
+	MaxDelay    string `yaml:"max_delay"`    // furthest a delivery may be scheduled ahead
+}
+
+// MinEvery returns the shortest repeat interval (default 1s).
+func (c *ScheduleConfig) MinEvery() time.Duration {
+	d, err := time.ParseDuration(c.MinInterval)
+	if err != nil || d <= 0 {
+		return time.Second
+	}
+	return d
+}
+
+// MaxWait returns how far ahead a delivery may be scheduled (default 7 days).
+func (c *ScheduleConfig) MaxWait() time.Duration {
+	d, err := time.ParseDuration(c.MaxDelay)
+	if err != nil || d <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return d
+}
+
+// ExportConfig controls sensor reading exports
+// (GET /robot/{uuid}/readings/export).
+type ExportConfig struct {
+	SyncRows int    `yaml:"sync_rows"` // larger exports run as a background job
+	Keep     string `yaml:"keep"`      // how long a job's file can be downloaded
+	MaxBytes int    `yaml:"max_bytes"` // job files held in memory at once
+}
+
+// KeepFor returns how long a finished export stays downloadable (default 1h).
+func (c *ExportConfig) KeepFor() time.Duration {
+	d, err := time.ParseDuration(c.Keep)
+	if err != nil || d <= 0 {
+		return time.Hour
+	}
+	return d
+}
+
+// DiscoveryConfig controls network scans for unregistered robots
+// (POST /robot/discover, see the discovery package).
+type DiscoveryConfig struct {
+	CIDRs       []string `yaml:"cidrs"`       // subnets to probe; empty = the /24 of each local IPv4 address
+	UDPPort     int      `yaml:"udp_port"`    // port robots answer the beacon on; 0 = no beacon
+	TCPPorts    []int    `yaml:"tcp_ports"`   // ports probed with a DISCOVER line
+	Timeout     string   `yaml:"timeout"`     // per TCP probe, and how long beacon replies are awaited
+	Concurrency int      `yaml:"concurrency"` // TCP probes in flight
+	MaxHosts    int      `yaml:"max_hosts"`   // scans of more addresses are refused
+	Keep        string   `yaml:"keep"`        // how long a candidate not seen again stays listed
+}
+
+// ProbeTimeout returns how long a probe waits for an answer (default 500ms).
+func (c *DiscoveryConfig) ProbeTimeout() time.Duration {
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil || d <= 0 {
+		return 500 * time.Millisecond
+	}
+	return d
+}
+
+// KeepFor returns how long a discovered robot stays listed after it was
+// last seen (default 24h).
+func (c *DiscoveryConfig) KeepFor() time.Duration {
+	d, err := time.ParseDuration(c.Keep)
+	if err != nil || d <= 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// BackupConfig controls backup archives (see the backup package).
+type BackupConfig struct {
+	Dir        string `yaml:"dir"`        // where "backup" without a path writes archives
+	Passphrase string `yaml:"passphrase"` // used when a backup or restore isn't given one
+}
+
+// TimeSyncConfig controls the per-robot clock offsets learned from TIME
+// requests (see shared/timesync).
+type TimeSyncConfig struct {
+	Normalize bool   `yaml:"normalize"` // move DATA timestamps onto the server clock
+	Keep      string `yaml:"keep"`      // how long an offset is kept after the robot's last sync
+}
+
+// KeepFor returns how long a robot's clock offset is kept (default 24h).
+func (c *TimeSyncConfig) KeepFor() time.Duration {
+	d, err := time.ParseDuration(c.Keep)
+	if err != nil || d <= 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// PushConfig controls the mobile push gateway, which delivers users'
+// notification rules to their phones (see push/).
+type PushConfig struct {
+	Enabled     bool       `yaml:"enabled"`
+	DedupWindow string     `yaml:"dedup_window"` // one push per user, rule, event type and robot per window; 0 disables
+	Buffer      int        `yaml:"buffer"`       // events waiting to be matched before new ones are dropped
+	FCM         FCMConfig  `yaml:"fcm"`
+	APNs        APNsConfig `yaml:"apns"`
+}
+
+// FCMConfig sends through Firebase Cloud Messaging (HTTP v1 API).
+type FCMConfig struct {
+	CredentialsFile string `yaml:"credentials_file"` // Firebase service account JSON; empty disables FCM
+}
+
+// APNsConfig sends through Apple Push Notification service with token
+// (.p8 key) authentication.
+type APNsConfig struct {
+	KeyFile string `yaml:"key_file"` // .p8 signing key; empty disables APNs
+	KeyID   string `yaml:"key_id"`
+	TeamID  string `yaml:"team_id"`
+	Topic   string `yaml:"topic"`   // the app's bundle ID
+	Sandbox bool   `yaml:"sandbox"` // development environment, for debug builds
+}
+
+// Dedup returns the deduplication window (default 5m, 0 = off).
+func (p *PushConfig) Dedup() time.Duration {
+	d, err := time.ParseDuration(p.DedupWindow)
+	if err != nil || d < 0 {
+		return 5 * time.Minute
+	}
+	return d
+}
+
+// SSEConfig protects the server from SSE clients that read slower than
+// their events arrive (see http_server/http_events).
+type SSEConfig struct {
+	// LagQueue is the queue depth at which a client is told it is lagging
+	// and LowPriority events stop being queued for it; 0 = off.
+	LagQueue int `yaml:"lag_queue"`
+	// EvictQueue is the queue depth at which the client is disconnected;
+	// 0 = never.
+	EvictQueue int `yaml:"evict_queue"`
+	// LowPriority lists event type globs ("*" matches any run of
+	// characters, e.g. "robot.*.heartbeat") dropped for a lagging client.
+	LowPriority []string `yaml:"low_priority"`
+}
+
+// JobsConfig sizes the background job runner (see shared/jobs).
+type JobsConfig struct {
+	Workers   int `yaml:"workers"`    // jobs run at once
+	QueueSize int `yaml:"queue_size"` // jobs waiting for a worker; more are refused
+	Keep      int `yaml:"keep"`       // finished jobs kept for GET /jobs
+}
+
+// IPConflictConfig decides what happens when a robot starts a session from
+// an IP address another active robot holds (see shared/ipconflict).
+type IPConflictConfig struct {
+	Policy string `yaml:"policy"` // allow (default), reject-new, evict-old or require-token-match
+}
+
+// AutoAcceptConfig lets REGISTER requests through without an operator (see
+// shared/autoaccept). A robot is accepted if any listed policy admits it;
+// with none, or "manual", every registration waits for approval. The same
+// shape is stored when the policies are edited at runtime.
+type AutoAcceptConfig struct {
+	Policies       []string `yaml:"policies" json:"policies"`               // manual (default), known_ids, device_types, trusted_subnets
+	DeviceIDs      []string `yaml:"device_ids" json:"device_ids"`           // UUIDs admitted by known_ids
+	DeviceTypes    []string `yaml:"device_types" json:"device_types"`       // types admitted by device_types
+	TrustedSubnets []string `yaml:"trusted_subnets" json:"trusted_subnets"` // CIDRs (or addresses) admitted by trusted_subnets
+}
+
+// MaintenanceConfig controls automated messages (broadcasts, quick actions,
+// rules, schedules) for robots in maintenance mode.
+type MaintenanceConfig struct {
+	Suppress   string `yaml:"suppress"`    // queue (default) or drop
+	QueueLimit int    `yaml:"queue_limit"` // queued messages kept per robot; the oldest go first
+}
+
+// QueueLen returns how many suppressed messages are kept per robot
+// (default 100).
+func (m *MaintenanceConfig) QueueLen() int {
+	if m.QueueLimit <= 0 {
+		return 100
+	}
+	return m.QueueLimit
+}
+
+// ClusterConfig turns on multi-instance mode: one instance is elected
+// leader through Redis, and robot messages are routed to the instance that
+// holds the robot's connection.
+type ClusterConfig struct {
+	Enabled   bool   `yaml:"enabled"`    // Also turns on events.cluster fan-out
+	NodeID    string `yaml:"node_id"`    // Defaults to "<hostname>-<random>"
+	LeaderTTL string `yaml:"leader_ttl"` // How long a leader lock lasts without renewal
+}
+
+// LeaderTTLDuration returns the leader lock lifetime (default 15s).
+func (c *ClusterConfig) LeaderTTLDuration() time.Duration {
+	d, err := time.ParseDuration(c.LeaderTTL)
+	if err != nil || d < time.Second {
+		return 15 * time.Second
+	}
+	return d
 }
 
 type TimeoutsConfig struct {
 	Handshake      string `yaml:"handshake"`
 	ProcessKill    string `yaml:"process_kill"`
 	ReverseConnect string `yaml:"reverse_connect"`
+	Shutdown       string `yaml:"shutdown"`         // Per-component stop budget during graceful shutdown
+	PingInterval   string `yaml:"ping_interval"`    // Server-initiated PING on TCP sessions; 0 disables
+	LatencyDegrade string `yaml:"latency_degraded"` // Average RTT above which a link is flagged degraded
+	Registration   string `yaml:"registration"`     // How long a REGISTER waits for operator approval
+	ReconnectGrace string `yaml:"reconnect_grace"`  // How long a disconnected robot's handler waits for it; 0 = forever
+	WebRTCAnswer   string `yaml:"webrtc_answer"`    // How long a WebRTC offer waits for the robot's answer
+	ShutdownDrain  string `yaml:"shutdown_drain"`   // How long shutdown waits for handler queues to empty; 0 = don't wait
+	Downtime       string `yaml:"downtime"`         // Expected downtime announced to robots on shutdown; 0 = unknown
+}
+
+// DataExpiry returns the default store_data expiry, or 0 to keep data forever.
+func (h *HandlersConfig) DataExpiry() time.Duration {
+	d, err := time.ParseDuration(h.DataTTL)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// TelemetryHistoryLen returns how many DATA envelopes are kept per robot
+// (default 100).
+func (h *HandlersConfig) TelemetryHistoryLen() int {
+	if h.TelemetryHistory <= 0 {
+		return 100
+	}
+	return h.TelemetryHistory
+}
+
+// CommandLockTimeout returns how long a serialized command holds the lock
+// unless the handler reports command_done first (default 30s).
+func (h *HandlersConfig) CommandLockTimeout() time.Duration {
+	d, err := time.ParseDuration(h.CommandTimeout)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// CommandsFor returns the commands robots of deviceType accept and whether
+// handlers.allowed_commands restricts them at all.
+func (h *HandlersConfig) CommandsFor(deviceType string) ([]string, bool) {
+	allowed, ok := h.AllowedCommands[deviceType]
+	return allowed, ok
+}
+
+// QueueSizeFor returns the stdin queue size for a device type's handlers
+// (handlers.queue_sizes, then handlers.queue_size, default 256).
+func (h *HandlersConfig) QueueSizeFor(deviceType string) int {
+	if n := h.QueueSizes[deviceType]; n > 0 {
+		return n
+	}
+	if h.QueueSize > 0 {
+		return h.QueueSize
+	}
+	return 256
+}
+
+// QueueFullAlertAfter returns how long a handler queue must stay full before
+// handler.{uuid}.queue_full is published; 0 = never. Invalid values fall
+// back to 30s.
+func (h *HandlersConfig) QueueFullAlertAfter() time.Duration {
+	d, err := time.ParseDuration(h.QueueFullAlert)
+	if err != nil || d < 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+// RestartAttemptLimit returns how many times a handler start is tried in
+// total. It is at least 1.
+func (h *HandlersConfig) RestartAttemptLimit() int {
+	if h.RestartAttempts < 1 {
+		return 1
+	}
+	return h.RestartAttempts
+}
+
+// RestartBackoffDuration returns the delay before the first start retry.
+func (h *HandlersConfig) RestartBackoffDuration() time.Duration {
+	d, err := time.ParseDuration(h.RestartBackoff)
+	if err != nil || d <= 0 {
+		return 500 * time.Millisecond
+	}
+	return d
 }
 
 func (t *TimeoutsConfig) HandshakeTimeout() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
 	d, err := time.ParseDuration(t.Handshake)
 	if err != nil {
 		return 30 * time.Second
@@ -59,6 +498,73 @@ func (t *TimeoutsConfig) ReverseConnectTimeout() time.Duration {
 	return d
 }
 
+func (t *TimeoutsConfig) ShutdownTimeout() time.Duration {
+	d, err := time.ParseDuration(t.Shutdown)
+	if err != nil {
+		return 15 * time.Second
+	}
+	return d
+}
+
+// WebRTCAnswerTimeout bounds how long POST /robot/{uuid}/webrtc/offer waits
+// before telling the client to fall back to relayed messages.
+func (t *TimeoutsConfig) WebRTCAnswerTimeout() time.Duration {
+	d, err := time.ParseDuration(t.WebRTCAnswer)
+	if err != nil || d <= 0 {
+		return 5 * time.Second
+	}
+	return d
+}
+
+// ReconnectGraceDuration returns how long a handler outlives its robot's
+// connection. Zero (the default) keeps handlers until explicitly stopped.
+func (t *TimeoutsConfig) ReconnectGraceDuration() time.Duration {
+	d, err := time.ParseDuration(t.ReconnectGrace)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// ShutdownDrainDuration returns how long shutdown waits for the handlers'
+// stdin queues to empty before stopping them (default 5s).
+func (t *TimeoutsConfig) ShutdownDrainDuration() time.Duration {
+	d, err := time.ParseDuration(t.ShutdownDrain)
+	if err != nil || d < 0 {
+		return 5 * time.Second
+	}
+	return d
+}
+
+// DowntimeDuration returns the downtime announced to robots when the server
+// shuts down. Zero means unknown.
+func (t *TimeoutsConfig) DowntimeDuration() time.Duration {
+	d, err := time.ParseDuration(t.Downtime)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// PingIntervalDuration returns how often the server pings TCP sessions.
+// Zero (the default) disables pinging, since older robot firmware does not
+// answer PING.
+func (t *TimeoutsConfig) PingIntervalDuration() time.Duration {
+	d, err := time.ParseDuration(t.PingInterval)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (t *TimeoutsConfig) LatencyDegradedThreshold() time.Duration {
+	d, err := time.ParseDuration(t.LatencyDegrade)
+	if err != nil {
+		return 500 * time.Millisecond
+	}
+	return d
+}
+
 type ServerConfig struct {
 	HTTPPort       int       `yaml:"http_port"`
 	TCPPort        int       `yaml:"tcp_port"`
@@ -66,8 +572,39 @@ type ServerConfig struct {
 	MQTTPort       int       `yaml:"mqtt_port"`
 	TerminalPort   int       `yaml:"terminal_port"`
 	Debug          bool      `yaml:"debug"`
+	DebugRateLimit int       `yaml:"debug_rate_limit"` // DebugPrint lines per call site per second; 0 = unlimited
 	AllowedOrigins []string  `yaml:"allowed_origins"`
 	TLS            TLSConfig `yaml:"tls"`
+
+	// AccessLog writes one JSON line per HTTP request to stdout: request
+	// ID, method, route, status, bytes, duration and user.
+	AccessLog bool `yaml:"access_log"`
+
+	TCPMaxConnections int    `yaml:"tcp_max_connections"` // Concurrent TCP connections; 0 = unlimited
+	TCPCodec          string `yaml:"tcp_codec"`           // Default wire format: "line" or "length"
+
+	LoginMaxAttempts int    `yaml:"login_max_attempts"` // Failed logins per IP within login_window before 429
+	LoginWindow      string `yaml:"login_window"`
+
+	TerminalSSH TerminalSSHConfig `yaml:"terminal_ssh"`
+}
+
+// TerminalSSHConfig serves the admin terminal over SSH with public-key
+// auth, alongside (or instead of) the plain localhost TCP port.
+type TerminalSSHConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	Address            string `yaml:"address"`              // listen address
+	HostKeyFile        string `yaml:"host_key_file"`        // created (ed25519) if missing
+	AuthorizedKeysFile string `yaml:"authorized_keys_file"` // OpenSSH authorized_keys format
+	DisableTCP         bool   `yaml:"disable_tcp"`          // turn off the plain TCP terminal
+}
+
+// TCPCodecName returns the default TCP wire format, "line" when unset.
+func (s *ServerConfig) TCPCodecName() string {
+	if s.TCPCodec == "" {
+		return "line"
+	}
+	return s.TCPCodec
 }
 
 type TLSConfig struct {
@@ -91,6 +628,32 @@ type PostgresConfig struct {
 	MaxOpenConns    int    `yaml:"max_open_conns"`
 	MaxIdleConns    int    `yaml:"max_idle_conns"`
 	ConnMaxLifetime string `yaml:"conn_max_lifetime"`
+	MigrationsDir   string `yaml:"migrations_dir"`   // dbmate-format .sql files
+	AutoMigrate     bool   `yaml:"auto_migrate"`     // apply pending migrations on startup
+	RobotCacheTTL   string `yaml:"robot_cache_ttl"`  // how long the HTTP API may serve a cached robot record; "0" = no cache
+	RobotCacheSize  int    `yaml:"robot_cache_size"` // cached robot records
+	WatchChanges    bool   `yaml:"watch_changes"`    // publish robots table changes made outside the server (LISTEN robot_changes)
+
+	Journal PostgresJournalConfig `yaml:"journal"`
+}
+
+// PostgresJournalConfig lets the server start and keep writing while
+// PostgreSQL is unreachable: registry and energy writes are buffered in a
+// local file and replayed once the database is back.
+type PostgresJournalConfig struct {
+	Path         string `yaml:"path"`          // "" = off: the server needs PostgreSQL to start
+	SyncInterval string `yaml:"sync_interval"` // how often to retry PostgreSQL while offline
+	MaxEntries   int    `yaml:"max_entries"`   // buffered writes; more are refused
+}
+
+// SyncEvery returns how often an offline server retries PostgreSQL
+// (default 15s).
+func (j *PostgresJournalConfig) SyncEvery() time.Duration {
+	d, err := time.ParseDuration(j.SyncInterval)
+	if err != nil || d <= 0 {
+		return 15 * time.Second
+	}
+	return d
 }
 
 type RedisConfig struct {
@@ -106,10 +669,228 @@ type AuthConfig struct {
 	JWTSecret   string `yaml:"-"`
 	JWTExpiry   int    `yaml:"jwt_expiry"`
 	NonceLength int    `yaml:"nonce_length"`
+
+	// At-rest encryption of stored tokens (see shared/tokencrypt). The key
+	// comes from TOKEN_ENCRYPTION_KEY or a file a secret manager/KMS agent
+	// mounts at token_key_file; old keys are still accepted for decryption.
+	TokenKey     string   `yaml:"-"`
+	TokenKeyFile string   `yaml:"token_key_file"`
+	TokenOldKeys []string `yaml:"-"`
+
+	// Keep the failed registration log in Redis too, shared by cluster
+	// nodes and kept across restarts.
+	PersistRegistrationFailures bool `yaml:"persist_registration_failures"`
+
+	// Allow one login session per user: logging in ends the user's other
+	// sessions.
+	SingleSession bool `yaml:"single_session"`
+
+	// Login session timeouts. session_max_age is the absolute lifetime
+	// (empty = jwt_expiry); session_idle_timeout ends a session unused for
+	// that long, renewed by each request (0 = off); SSE streams get a
+	// session_expiring event session_expiry_warning before either ends.
+	SessionMaxAge        string `yaml:"session_max_age"`
+	SessionIdleTimeout   string `yaml:"session_idle_timeout"`
+	SessionExpiryWarning string `yaml:"session_expiry_warning"`
+}
+
+// MinSessionIdleTimeout is the shortest idle timeout honoured: activity is
+// written back at most once a minute, so a shorter one would end sessions
+// in use.
+const MinSessionIdleTimeout = 2 * time.Minute
+
+// MaxSessionAge returns the absolute lifetime of a login session.
+func (a *AuthConfig) MaxSessionAge() time.Duration {
+	if d, err := time.ParseDuration(a.SessionMaxAge); err == nil && d > 0 {
+		return d
+	}
+	return time.Duration(a.JWTExpiry) * time.Second
+}
+
+// IdleTimeout returns how long a login session may go unused; 0 = no limit.
+func (a *AuthConfig) IdleTimeout() time.Duration {
+	d, err := time.ParseDuration(a.SessionIdleTimeout)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return max(d, MinSessionIdleTimeout)
+}
+
+// ExpiryWarning returns how long before a session ends SSE streams are
+// warned; 0 = never.
+func (a *AuthConfig) ExpiryWarning() time.Duration {
+	d, err := time.ParseDuration(a.SessionExpiryWarning)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// TokenEncryptionKey returns the configured token key, reading
+// token_key_file when TOKEN_ENCRYPTION_KEY is unset. Empty means disabled.
+func (a *AuthConfig) TokenEncryptionKey() (string, error) {
+	if a.TokenKey != "" || a.TokenKeyFile == "" {
+		return a.TokenKey, nil
+	}
+	data, err := os.ReadFile(a.TokenKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token key file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
 }
 
 type HandlersConfig struct {
 	BasePath string `yaml:"base_path"`
+	DataTTL  string `yaml:"data_ttl"` // default expiry for store_data keys without their own "ttl"; empty or 0 = keep
+
+	// PluginDirs are more directories of {type}/start_handler.sh, for
+	// third-party robot types, searched after BasePath.
+	PluginDirs []string `yaml:"plugin_dirs"`
+
+	RestartAttempts int    `yaml:"restart_attempts"` // Start attempts before a robot's handler is given up on
+	RestartBackoff  string `yaml:"restart_backoff"`  // Delay before the first retry; doubles each time
+
+	TelemetryHistory int `yaml:"telemetry_history"` // DATA envelopes kept per robot in Redis
+
+	// Serialize lists device types whose handlers take one operator command
+	// at a time; a command sent while another holds the lock gets 409.
+	Serialize      []string `yaml:"serialize"`
+	CommandTimeout string   `yaml:"command_timeout"` // How long a command holds the lock without command_done
+
+	// AllowedCommands lists, per device type, the only commands operators
+	// may send its robots (see handler_engine.CheckCommand). Types not
+	// listed accept any command; a type listed with no commands accepts none.
+	AllowedCommands map[string][]string `yaml:"allowed_commands"`
+
+	// QueueSize bounds the messages waiting for a handler's stdin;
+	// QueueSizes overrides it per device type. Messages that don't fit are
+	// counted as overflows.
+	QueueSize  int            `yaml:"queue_size"`
+	QueueSizes map[string]int `yaml:"queue_sizes"`
+	// QueueFullAlert publishes handler.{uuid}.queue_full once a queue has
+	// stayed full this long; 0 = off.
+	QueueFullAlert string `yaml:"queue_full_alert"`
+}
+
+type PresenceConfig struct {
+	Geofences []GeofenceConfig `yaml:"geofences"`
+	// MaxSubjects caps how many subjects can be inside a geofence at once;
+	// updates that would add another are refused. 0 = no limit.
+	MaxSubjects int `yaml:"max_subjects"`
+}
+
+type EventsConfig struct {
+	// Ordered lists event types (or "prefix.*" patterns) delivered to each
+	// subscriber sequentially in publish order rather than concurrently.
+	Ordered []string `yaml:"ordered"`
+
+	// MaxInFlight caps the subscriber handlers running at once (and how far
+	// an ordered subscriber may fall behind); events over it are dropped.
+	MaxInFlight int `yaml:"max_in_flight"`
+	// TopicLimits caps concurrent handlers per event type or "prefix.*"
+	// pattern, within MaxInFlight.
+	TopicLimits map[string]int `yaml:"topic_limits"`
+
+	// Cluster relays events to other instances sharing the same Redis over
+	// pub/sub, so SSE and WebSocket clients on any node see every event.
+	Cluster        bool     `yaml:"cluster"`
+	ClusterChannel string   `yaml:"cluster_channel"`
+	ClusterEvents  []string `yaml:"cluster_events"` // exact types or "prefix.*"; empty = all
+
+	// Drop lists event types (or "prefix.*" patterns) discarded at publish,
+	// before taps, subscribers or the cluster relay see them.
+	Drop []string `yaml:"drop"`
+	// SlowHandler logs subscriber handler calls that take longer; empty = off.
+	SlowHandler string `yaml:"slow_handler"`
+	// StateDiff publishes robot.{uuid}.changed with only the fields of a
+	// robot's heartbeat, telemetry and status state that changed.
+	StateDiff bool `yaml:"state_diff"`
+}
+
+// SlowHandlerThreshold returns events.slow_handler, or 0 when unset.
+func (e *EventsConfig) SlowHandlerThreshold() time.Duration {
+	d, err := time.ParseDuration(e.SlowHandler)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+type MetricsConfig struct {
+	// TimelineMinutes is how many per-minute samples GET /admin/timeline keeps.
+	TimelineMinutes int `yaml:"timeline_minutes"`
+}
+
+// LimitsConfig caps message sizes so one oversized blob from a buggy robot
+// or client can't balloon memory or wedge a reader. Sizes are in bytes.
+type LimitsConfig struct {
+	TCPLine        int `yaml:"tcp_line"`        // One line on a TCP connection
+	HTTPBody       int `yaml:"http_body"`       // Any HTTP request body
+	HandlerMessage int `yaml:"handler_message"` // One incoming message written to a handler's stdin
+}
+
+// TCPLineBytes returns the TCP line limit (default 64 KB).
+func (l *LimitsConfig) TCPLineBytes() int {
+	if l.TCPLine <= 0 {
+		return 64 * 1024
+	}
+	return l.TCPLine
+}
+
+// LimitScanner caps the lines s will accept at TCPLineBytes; a longer line
+// stops it with bufio.ErrTooLong.
+func (l *LimitsConfig) LimitScanner(s *bufio.Scanner) {
+	limit := l.TCPLineBytes()
+	s.Buffer(make([]byte, 0, min(limit, bufio.MaxScanTokenSize)), limit)
+}
+
+// HTTPBodyBytes returns the HTTP request body limit (default 1 MB).
+func (l *LimitsConfig) HTTPBodyBytes() int {
+	if l.HTTPBody <= 0 {
+		return 1 << 20
+	}
+	return l.HTTPBody
+}
+
+// HandlerMessageBytes returns the limit on a single payload forwarded to a
+// handler (default 64 KB, matching the TCP line limit).
+func (l *LimitsConfig) HandlerMessageBytes() int {
+	if l.HandlerMessage <= 0 {
+		return 64 * 1024
+	}
+	return l.HandlerMessage
+}
+
+// ExporterConfig forwards selected event bus events to an external broker
+// for data pipelines.
+type ExporterConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Backend string   `yaml:"backend"` // "nats" or "kafka" (via a Kafka REST Proxy)
+	URL     string   `yaml:"url"`     // nats://[user:pass@]host:port, or the REST Proxy base URL
+	Topic   string   `yaml:"topic"`   // Kafka topic, or NATS subject prefix
+	Format  string   `yaml:"format"`  // "json" or "avro"
+	Events  []string `yaml:"events"`  // event types to export; "prefix.*" matches by prefix
+	Buffer  int      `yaml:"buffer"`  // events queued for export before new ones are dropped
+}
+
+// TracingConfig exports OpenTelemetry spans to an OTLP/HTTP collector (see
+// shared/tracing).
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint"`     // collector base URL; spans are posted to {endpoint}/v1/traces
+	ServiceName string            `yaml:"service_name"` // resource service.name
+	SampleRatio float64           `yaml:"sample_ratio"` // share of new traces recorded, 0-1; continued traces follow their caller
+	Buffer      int               `yaml:"buffer"`       // finished spans queued for export before new ones are dropped
+	Headers     map[string]string `yaml:"headers"`      // sent with every export, e.g. a vendor API key
+}
+
+// GeofenceConfig is a circular region; subjects (phones, beacons) entering or
+// leaving it produce presence.enter / presence.leave events.
+type GeofenceConfig struct {
+	Name         string  `yaml:"name"`
+	Latitude     float64 `yaml:"latitude"`
+	Longitude    float64 `yaml:"longitude"`
+	RadiusMeters float64 `yaml:"radius_meters"`
 }
 
 // DSN returns the PostgreSQL connection string.
@@ -150,6 +931,16 @@ func (p *PostgresConfig) ConnLifetime() time.Duration {
 	return d
 }
 
+// RobotCacheFor returns how long a cached robot record is served (default
+// 30s; 0 disables the cache).
+func (p *PostgresConfig) RobotCacheFor() time.Duration {
+	d, err := time.ParseDuration(p.RobotCacheTTL)
+	if err != nil || d < 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
 func defaultConfig() Config {
 	return Config{
 		Server: ServerConfig{
@@ -159,7 +950,21 @@ func defaultConfig() Config {
 			MQTTPort:       1883,
 			TerminalPort:   6000,
 			Debug:          false,
+			DebugRateLimit: 20,
 			AllowedOrigins: []string{"http://localhost:5173", "http://localhost:4173"},
+			AccessLog:      true,
+
+			TCPMaxConnections: 1024,
+			TCPCodec:          "line",
+
+			LoginMaxAttempts: 5,
+			LoginWindow:      "5m",
+
+			TerminalSSH: TerminalSSHConfig{
+				Address:            "127.0.0.1:6022",
+				HostKeyFile:        "terminal_host_key",
+				AuthorizedKeysFile: "terminal_authorized_keys",
+			},
 		},
 		Database: DatabaseConfig{
 			Postgres: PostgresConfig{
@@ -171,6 +976,13 @@ func defaultConfig() Config {
 				MaxOpenConns:    10,
 				MaxIdleConns:    5,
 				ConnMaxLifetime: "1h",
+				MigrationsDir:   "../db/migrations",
+				RobotCacheTTL:   "30s",
+				RobotCacheSize:  10000,
+				Journal: PostgresJournalConfig{
+					SyncInterval: "15s",
+					MaxEntries:   100000,
+				},
 			},
 			Redis: RedisConfig{
 				Host:           "localhost",
@@ -183,41 +995,185 @@ func defaultConfig() Config {
 		Auth: AuthConfig{
 			JWTExpiry:   3600,
 			NonceLength: 32,
+
+			SessionIdleTimeout:   "0s",
+			SessionExpiryWarning: "5m",
 		},
 		Handlers: HandlersConfig{
-			BasePath: "../handlers",
+			BasePath:        "../handlers",
+			RestartAttempts: 3,
+			RestartBackoff:  "500ms",
+
+			TelemetryHistory: 100,
+			CommandTimeout:   "30s",
+			QueueSize:        256,
+			QueueFullAlert:   "30s",
 		},
 		Timeouts: TimeoutsConfig{
 			Handshake:      "30s",
 			ProcessKill:    "10s",
 			ReverseConnect: "10s",
+			Shutdown:       "15s",
+			PingInterval:   "0s",
+			LatencyDegrade: "500ms",
+			Registration:   "5m",
+			ReconnectGrace: "0s",
+			WebRTCAnswer:   "5s",
+			ShutdownDrain:  "5s",
+			Downtime:       "30s",
+		},
+		Presence: PresenceConfig{
+			MaxSubjects: 10000,
+		},
+		Events: EventsConfig{
+			Ordered:        []string{"presence.*"},
+			MaxInFlight:    EVENT_BUS_BUFFER_SIZE,
+			ClusterChannel: "robomesh:events",
+			StateDiff:      true,
+		},
+		Metrics: MetricsConfig{
+			TimelineMinutes: 1440,
+		},
+		Exporter: ExporterConfig{
+			Backend: "nats",
+			URL:     "nats://localhost:4222",
+			Topic:   "robomesh",
+			Format:  "json",
+			Events:  []string{"robot.*"},
+			Buffer:  1024,
+		},
+		Limits: LimitsConfig{
+			TCPLine:        64 * 1024,
+			HTTPBody:       1 << 20,
+			HandlerMessage: 64 * 1024,
+		},
+		Cluster: ClusterConfig{
+			LeaderTTL: "15s",
+		},
+		Maintenance: MaintenanceConfig{
+			Suppress:   "queue",
+			QueueLimit: 100,
+		},
+		IPConflict: IPConflictConfig{
+			Policy: "allow",
+		},
+		AutoAccept: AutoAcceptConfig{
+			Policies: []string{"manual"},
+		},
+		Energy: EnergyConfig{
+			Enabled:       true,
+			TelemetryType: "power",
+			Metric:        "watts",
+			FlushInterval: "1m",
+		},
+		Automation: AutomationConfig{
+			Dir:       "./automations",
+			Timeout:   "100ms",
+			CallStack: 200,
+			MaxStack:  65536,
+			Queue:     256,
+		},
+		Jobs: JobsConfig{
+			Workers:   2,
+			QueueSize: 100,
+			Keep:      200,
+		},
+		Schedule: ScheduleConfig{
+			MaxPending:  1000,
+			MinInterval: "1s",
+			MaxDelay:    "168h",
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "roboserver",
+			SampleRatio: 1,
+			Buffer:      2048,
+		},
+		Discovery: DiscoveryConfig{
+			UDPPort:     5002,
+			Timeout:     "500ms",
+			Concurrency: 64,
+			MaxHosts:    1024,
+			Keep:        "24h",
+		},
+		Export: ExportConfig{
+			SyncRows: 10000,
+			Keep:     "1h",
+			MaxBytes: 64 << 20,
+		},
+		Backup: BackupConfig{
+			Dir: "./backups",
+		},
+		TimeSync: TimeSyncConfig{
+			Normalize: true,
+			Keep:      "24h",
+		},
+		Push: PushConfig{
+			DedupWindow: "5m",
+			Buffer:      1024,
+		},
+		AdaptivePolling: AdaptivePollingConfig{
+			MinInterval: "1s",
+			MaxInterval: "5m",
+			Window:      20,
+			Threshold:   0.02,
+			Cooldown:    "1m",
+		},
+		Ingest: IngestConfig{
+			DeviceType: "virtual_sensor",
+			MaxRecords: 1000,
+			TTL:        "10m",
+		},
+		SSE: SSEConfig{
+			LagQueue:    200,
+			EvictQueue:  1000,
+			LowPriority: []string{"robot.*.heartbeat", "robot.*.latency", "robot.*.telemetry", "robot.*.changed", "handler.*.log"},
 		},
 	}
 }
 
 // LoadConfig loads configuration with priority: defaults < YAML file < environment variables.
 func LoadConfig(path string) error {
-	AppConfig = defaultConfig()
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	AppConfig = cfg
+	DEBUG_MODE.Store(AppConfig.Server.Debug)
+	debugRateLimit.Store(int64(AppConfig.Server.DebugRateLimit))
+	configPath = path
+	return nil
+}
+
+// readConfig builds a Config from defaults, the file at path (if present) and
+// environment overrides.
+func readConfig(path string) (Config, error) {
+	cfg := defaultConfig()
 
 	if data, err := os.ReadFile(path); err == nil {
-		if err := yaml.Unmarshal(data, &AppConfig); err != nil {
-			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse config file %s: %w", path, err)
 		}
 	}
 
-	applyEnvOverrides(&AppConfig)
-	DEBUG_MODE = AppConfig.Server.Debug
-	return nil
+	applyEnvOverrides(&cfg)
+	return cfg, nil
 }
 
 func applyEnvOverrides(cfg *Config) {
 	// Server
 	envBool("DEBUG", &cfg.Server.Debug)
+	envInt("DEBUG_RATE_LIMIT", &cfg.Server.DebugRateLimit)
 	envInt("HTTP_PORT", &cfg.Server.HTTPPort)
+	envBool("HTTP_ACCESS_LOG", &cfg.Server.AccessLog)
 	envInt("TCP_PORT", &cfg.Server.TCPPort)
 	envInt("UDP_PORT", &cfg.Server.UDPPort)
 	envInt("MQTT_PORT", &cfg.Server.MQTTPort)
 	envInt("TERMINAL_PORT", &cfg.Server.TerminalPort)
+	envBool("TERMINAL_SSH_ENABLED", &cfg.Server.TerminalSSH.Enabled)
+	envStr("TERMINAL_SSH_ADDRESS", &cfg.Server.TerminalSSH.Address)
+	envStr("TERMINAL_SSH_HOST_KEY_FILE", &cfg.Server.TerminalSSH.HostKeyFile)
+	envStr("TERMINAL_SSH_AUTHORIZED_KEYS_FILE", &cfg.Server.TerminalSSH.AuthorizedKeysFile)
 
 	// PostgreSQL
 	envStr("POSTGRES_HOST", &cfg.Database.Postgres.Host)
@@ -226,6 +1182,13 @@ func applyEnvOverrides(cfg *Config) {
 	envStr("POSTGRES_PASSWORD", &cfg.Database.Postgres.Password)
 	envStr("POSTGRES_DB", &cfg.Database.Postgres.Database)
 	envStr("POSTGRES_SSL_MODE", &cfg.Database.Postgres.SSLMode)
+	envStr("POSTGRES_MIGRATIONS_DIR", &cfg.Database.Postgres.MigrationsDir)
+	envBool("POSTGRES_AUTO_MIGRATE", &cfg.Database.Postgres.AutoMigrate)
+	envStr("POSTGRES_ROBOT_CACHE_TTL", &cfg.Database.Postgres.RobotCacheTTL)
+	envBool("POSTGRES_WATCH_CHANGES", &cfg.Database.Postgres.WatchChanges)
+	envStr("POSTGRES_JOURNAL_PATH", &cfg.Database.Postgres.Journal.Path)
+	envStr("POSTGRES_JOURNAL_SYNC_INTERVAL", &cfg.Database.Postgres.Journal.SyncInterval)
+	envInt("POSTGRES_JOURNAL_MAX_ENTRIES", &cfg.Database.Postgres.Journal.MaxEntries)
 
 	// Redis
 	envStr("REDIS_HOST", &cfg.Database.Redis.Host)
@@ -236,9 +1199,24 @@ func applyEnvOverrides(cfg *Config) {
 	// Auth
 	envStr("JWT_SECRET", &cfg.Auth.JWTSecret)
 	envInt("JWT_EXPIRY", &cfg.Auth.JWTExpiry)
+	envStr("TOKEN_ENCRYPTION_KEY", &cfg.Auth.TokenKey)
+	envStr("TOKEN_ENCRYPTION_KEY_FILE", &cfg.Auth.TokenKeyFile)
+	envBool("AUTH_PERSIST_REGISTRATION_FAILURES", &cfg.Auth.PersistRegistrationFailures)
+	envBool("AUTH_SINGLE_SESSION", &cfg.Auth.SingleSession)
+	envStr("AUTH_SESSION_MAX_AGE", &cfg.Auth.SessionMaxAge)
+	envStr("AUTH_SESSION_IDLE_TIMEOUT", &cfg.Auth.SessionIdleTimeout)
+	envStr("AUTH_SESSION_EXPIRY_WARNING", &cfg.Auth.SessionExpiryWarning)
+	envCSV("TOKEN_ENCRYPTION_OLD_KEYS", &cfg.Auth.TokenOldKeys)
 
 	// Handlers
 	envStr("HANDLERS_BASE_PATH", &cfg.Handlers.BasePath)
+	envCSV("HANDLERS_PLUGIN_DIRS", &cfg.Handlers.PluginDirs)
+	envInt("HANDLERS_TELEMETRY_HISTORY", &cfg.Handlers.TelemetryHistory)
+	envCSV("HANDLERS_SERIALIZE", &cfg.Handlers.Serialize)
+	envStr("HANDLERS_COMMAND_TIMEOUT", &cfg.Handlers.CommandTimeout)
+	envStr("SHUTDOWN_DOWNTIME", &cfg.Timeouts.Downtime)
+	envInt("HANDLERS_QUEUE_SIZE", &cfg.Handlers.QueueSize)
+	envStr("HANDLERS_QUEUE_FULL_ALERT", &cfg.Handlers.QueueFullAlert)
 
 	// TLS
 	envBool("TLS_ENABLED", &cfg.Server.TLS.Enabled)
@@ -247,6 +1225,80 @@ func applyEnvOverrides(cfg *Config) {
 
 	// CORS
 	envCSV("ALLOWED_ORIGINS", &cfg.Server.AllowedOrigins)
+
+	// Event exporter
+	envBool("EXPORTER_ENABLED", &cfg.Exporter.Enabled)
+	envStr("EXPORTER_BACKEND", &cfg.Exporter.Backend)
+	envStr("EXPORTER_URL", &cfg.Exporter.URL)
+	envStr("EXPORTER_TOPIC", &cfg.Exporter.Topic)
+	envStr("EXPORTER_FORMAT", &cfg.Exporter.Format)
+	envCSV("EXPORTER_EVENTS", &cfg.Exporter.Events)
+
+	// Clustering
+	envBool("CLUSTER_ENABLED", &cfg.Cluster.Enabled)
+	envStr("NODE_ID", &cfg.Cluster.NodeID)
+
+	// Cluster event fan-out
+	envInt("EVENTS_MAX_IN_FLIGHT", &cfg.Events.MaxInFlight)
+	envBool("EVENTS_CLUSTER", &cfg.Events.Cluster)
+	envStr("EVENTS_CLUSTER_CHANNEL", &cfg.Events.ClusterChannel)
+	envCSV("EVENTS_CLUSTER_EVENTS", &cfg.Events.ClusterEvents)
+	envCSV("EVENTS_DROP", &cfg.Events.Drop)
+	envStr("EVENTS_SLOW_HANDLER", &cfg.Events.SlowHandler)
+	envBool("EVENTS_STATE_DIFF", &cfg.Events.StateDiff)
+
+	// Maintenance
+	envStr("MAINTENANCE_SUPPRESS", &cfg.Maintenance.Suppress)
+	envInt("MAINTENANCE_QUEUE_LIMIT", &cfg.Maintenance.QueueLimit)
+	envStr("IP_CONFLICT_POLICY", &cfg.IPConflict.Policy)
+	envCSV("AUTO_ACCEPT_POLICIES", &cfg.AutoAccept.Policies)
+	envCSV("AUTO_ACCEPT_DEVICE_IDS", &cfg.AutoAccept.DeviceIDs)
+	envCSV("AUTO_ACCEPT_DEVICE_TYPES", &cfg.AutoAccept.DeviceTypes)
+	envCSV("AUTO_ACCEPT_TRUSTED_SUBNETS", &cfg.AutoAccept.TrustedSubnets)
+	envInt("JOBS_WORKERS", &cfg.Jobs.Workers)
+	envInt("JOBS_QUEUE_SIZE", &cfg.Jobs.QueueSize)
+	envInt("JOBS_KEEP", &cfg.Jobs.Keep)
+	envInt("SCHEDULE_MAX_PENDING", &cfg.Schedule.MaxPending)
+	envInt("EXPORT_SYNC_ROWS", &cfg.Export.SyncRows)
+	envCSV("DISCOVERY_CIDRS", &cfg.Discovery.CIDRs)
+	envInt("DISCOVERY_UDP_PORT", &cfg.Discovery.UDPPort)
+	envStr("BACKUP_DIR", &cfg.Backup.Dir)
+	envStr("BACKUP_PASSPHRASE", &cfg.Backup.Passphrase)
+	envBool("TIME_SYNC_NORMALIZE", &cfg.TimeSync.Normalize)
+	envBool("PUSH_ENABLED", &cfg.Push.Enabled)
+	envStr("PUSH_FCM_CREDENTIALS_FILE", &cfg.Push.FCM.CredentialsFile)
+	envStr("PUSH_APNS_KEY_FILE", &cfg.Push.APNs.KeyFile)
+	envStr("PUSH_APNS_KEY_ID", &cfg.Push.APNs.KeyID)
+	envStr("PUSH_APNS_TEAM_ID", &cfg.Push.APNs.TeamID)
+	envStr("PUSH_APNS_TOPIC", &cfg.Push.APNs.Topic)
+	envBool("PUSH_APNS_SANDBOX", &cfg.Push.APNs.Sandbox)
+	envInt("SSE_LAG_QUEUE", &cfg.SSE.LagQueue)
+	envInt("SSE_EVICT_QUEUE", &cfg.SSE.EvictQueue)
+	envCSV("SSE_LOW_PRIORITY", &cfg.SSE.LowPriority)
+	envBool("TRACING_ENABLED", &cfg.Tracing.Enabled)
+	envStr("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.Tracing.Endpoint)
+	envStr("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	envBool("ENERGY_ENABLED", &cfg.Energy.Enabled)
+	envStr("ENERGY_FLUSH_INTERVAL", &cfg.Energy.FlushInterval)
+	envBool("ADAPTIVE_POLLING_ENABLED", &cfg.AdaptivePolling.Enabled)
+	envCSV("ADAPTIVE_POLLING_DEVICE_TYPES", &cfg.AdaptivePolling.DeviceTypes)
+	envStr("ADAPTIVE_POLLING_MIN_INTERVAL", &cfg.AdaptivePolling.MinInterval)
+	envStr("ADAPTIVE_POLLING_MAX_INTERVAL", &cfg.AdaptivePolling.MaxInterval)
+	envInt("ADAPTIVE_POLLING_WINDOW", &cfg.AdaptivePolling.Window)
+	envStr("ADAPTIVE_POLLING_COOLDOWN", &cfg.AdaptivePolling.Cooldown)
+	envBool("INGEST_ENABLED", &cfg.Ingest.Enabled)
+	envStr("INGEST_DEVICE_TYPE", &cfg.Ingest.DeviceType)
+	envInt("INGEST_MAX_RECORDS", &cfg.Ingest.MaxRecords)
+	envStr("INGEST_TTL", &cfg.Ingest.TTL)
+
+	envBool("AUTOMATION_ENABLED", &cfg.Automation.Enabled)
+	envStr("AUTOMATION_DIR", &cfg.Automation.Dir)
+	envStr("AUTOMATION_TIMEOUT", &cfg.Automation.Timeout)
+
+	// Size limits
+	envInt("LIMITS_TCP_LINE", &cfg.Limits.TCPLine)
+	envInt("LIMITS_HTTP_BODY", &cfg.Limits.HTTPBody)
+	envInt("LIMITS_HANDLER_MESSAGE", &cfg.Limits.HandlerMessage)
 }
 
 func envStr(key string, dst *string) {
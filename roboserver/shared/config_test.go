@@ -2,6 +2,8 @@ package shared
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -177,3 +179,110 @@ func TestConnLifetime(t *testing.T) {
 		t.Errorf("Expected default 1h conn lifetime, got %v", cfg.ConnLifetime())
 	}
 }
+
+func TestSessionTimeouts(t *testing.T) {
+	cfg := defaultConfig().Auth
+	if cfg.MaxSessionAge() != time.Hour {
+		t.Errorf("Expected max age to default to jwt_expiry, got %v", cfg.MaxSessionAge())
+	}
+	if cfg.IdleTimeout() != 0 {
+		t.Errorf("Expected no idle timeout by default, got %v", cfg.IdleTimeout())
+	}
+	if cfg.ExpiryWarning() != 5*time.Minute {
+		t.Errorf("Expected 5m expiry warning, got %v", cfg.ExpiryWarning())
+	}
+
+	cfg.SessionMaxAge = "12h"
+	cfg.SessionIdleTimeout = "30s"
+	if cfg.MaxSessionAge() != 12*time.Hour {
+		t.Errorf("Expected 12h max age, got %v", cfg.MaxSessionAge())
+	}
+	if cfg.IdleTimeout() != MinSessionIdleTimeout {
+		t.Errorf("Expected idle timeout raised to %v, got %v", MinSessionIdleTimeout, cfg.IdleTimeout())
+	}
+}
+
+func TestHandlersQueueConfig(t *testing.T) {
+	h := HandlersConfig{QueueSize: 64, QueueSizes: map[string]int{"camera": 1024}}
+	if n := h.QueueSizeFor("camera"); n != 1024 {
+		t.Errorf("Expected per-type size 1024, got %d", n)
+	}
+	if n := h.QueueSizeFor("lamp"); n != 64 {
+		t.Errorf("Expected default size 64, got %d", n)
+	}
+	if n := (&HandlersConfig{}).QueueSizeFor("lamp"); n != 256 {
+		t.Errorf("Expected fallback size 256, got %d", n)
+	}
+
+	for raw, want := range map[string]time.Duration{"": 30 * time.Second, "bogus": 30 * time.Second, "0": 0, "5s": 5 * time.Second} {
+		h := HandlersConfig{QueueFullAlert: raw}
+		if got := h.QueueFullAlertAfter(); got != want {
+			t.Errorf("QueueFullAlert %q: expected %v, got %v", raw, want, got)
+		}
+	}
+}
+
+func TestReloadConfigAppliesRuntimeSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("server:\n  http_port: 8080\n  debug: false\n"), 0o644)
+	if err := LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	defer DEBUG_MODE.Store(false)
+
+	os.WriteFile(path, []byte(`server:
+  http_port: 9999
+  debug: true
+  allowed_origins: ["https://ops.example"]
+  login_max_attempts: 3
+timeouts:
+  registration: 2m
+`), 0o644)
+
+	changed, err := ReloadConfig()
+	if err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+	want := []string{"server.debug", "server.allowed_origins", "server.login_max_attempts", "timeouts.registration"}
+	if strings.Join(changed, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected changed=%v, got %v", want, changed)
+	}
+
+	if !DEBUG_MODE.Load() {
+		t.Error("Expected debug logging enabled after reload")
+	}
+	if origins := AllowedOrigins(); len(origins) != 1 || origins[0] != "https://ops.example" {
+		t.Errorf("Expected reloaded origins, got %v", origins)
+	}
+	if attempts, _ := LoginLimit(); attempts != 3 {
+		t.Errorf("Expected 3 login attempts, got %d", attempts)
+	}
+	if d := AppConfig.Timeouts.RegistrationTimeout(); d != 2*time.Minute {
+		t.Errorf("Expected 2m registration timeout, got %v", d)
+	}
+	// Ports need a restart and must not change underneath running listeners.
+	if AppConfig.Server.HTTPPort != 8080 {
+		t.Errorf("Expected HTTP port to stay 8080, got %d", AppConfig.Server.HTTPPort)
+	}
+
+	changed, err = ReloadConfig()
+	if err != nil || len(changed) != 0 {
+		t.Errorf("Expected no-op reload, got changed=%v err=%v", changed, err)
+	}
+}
+
+func TestReloadConfigKeepsSettingsOnParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("server:\n  allowed_origins: [\"https://a.example\"]\n"), 0o644)
+	if err := LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	os.WriteFile(path, []byte("server: [not a map"), 0o644)
+	if _, err := ReloadConfig(); err == nil {
+		t.Fatal("Expected parse error")
+	}
+	if origins := AllowedOrigins(); len(origins) != 1 || origins[0] != "https://a.example" {
+		t.Errorf("Expected origins unchanged after failed reload, got %v", origins)
+	}
+}
@@ -0,0 +1,54 @@
+package data_structures
+
+import "sync"
+
+// RingBuffer keeps the most recent Cap() values pushed; older values are
+// overwritten. Safe for concurrent use.
+type RingBuffer[T any] struct {
+	mu    sync.RWMutex
+	items []T
+	next  int // slot the next Push writes
+	full  bool
+}
+
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingBuffer[T]{items: make([]T, capacity)}
+}
+
+func (r *RingBuffer[T]) Push(value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[r.next] = value
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *RingBuffer[T]) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.full {
+		return len(r.items)
+	}
+	return r.next
+}
+
+func (r *RingBuffer[T]) Cap() int {
+	return len(r.items)
+}
+
+// Snapshot returns the buffered values, oldest first.
+func (r *RingBuffer[T]) Snapshot() []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.full {
+		return append([]T(nil), r.items[:r.next]...)
+	}
+	out := make([]T, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	return append(out, r.items[:r.next]...)
+}
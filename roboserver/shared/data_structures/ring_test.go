@@ -0,0 +1,44 @@
+package data_structures
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRingBufferBeforeWrap(t *testing.T) {
+	r := NewRingBuffer[int](3)
+	if r.Len() != 0 || len(r.Snapshot()) != 0 {
+		t.Fatal("Expected empty ring buffer")
+	}
+	r.Push(1)
+	r.Push(2)
+	if got := r.Snapshot(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("Expected [1 2], got %v", got)
+	}
+	if r.Len() != 2 {
+		t.Errorf("Expected len 2, got %d", r.Len())
+	}
+}
+
+func TestRingBufferOverwritesOldest(t *testing.T) {
+	r := NewRingBuffer[int](3)
+	for i := 1; i <= 5; i++ {
+		r.Push(i)
+	}
+	if got := r.Snapshot(); !reflect.DeepEqual(got, []int{3, 4, 5}) {
+		t.Errorf("Expected [3 4 5], got %v", got)
+	}
+	if r.Len() != 3 || r.Cap() != 3 {
+		t.Errorf("Expected len=cap=3, got len=%d cap=%d", r.Len(), r.Cap())
+	}
+}
+
+func TestRingBufferSnapshotIsCopy(t *testing.T) {
+	r := NewRingBuffer[int](2)
+	r.Push(1)
+	snap := r.Snapshot()
+	snap[0] = 99
+	if got := r.Snapshot(); got[0] != 1 {
+		t.Errorf("Snapshot aliased internal storage: %v", got)
+	}
+}
@@ -1,130 +1,139 @@
 package data_structures
 
 import (
-	"roboserver/shared/utils"
+	"roboserver/shared/tracked"
 )
 
-// Maybe add switching between using the go routine and not using it
+// shrinkCap is the capacity above which an emptied queue gives its buffer
+// back, so a burst doesn't pin memory for the queue's lifetime.
+const shrinkCap = 1024
 
-// If using wait, the queue must be closed when done to avoid goroutine leaks
+// NewSafeQueue returns an empty FIFO queue. With useWait, Read can block
+// until a value arrives; such a queue should be closed when done so blocked
+// readers return. The queue is a ring buffer guarded by one mutex: no
+// operation starts a goroutine or allocates beyond growing the buffer.
 func NewSafeQueue[T any](useWait bool) *SafeQueue[T] {
-	q := &SafeQueue[T]{
-		head: &Node[T]{}, // Dummy head node
-		tail: &Node[T]{}, // Dummy tail node
-	}
-	q.head.next = q.tail
-	q.tail.prev = q.head
-	q.len.Store(0)
-	q.useWait = useWait
+	q := &SafeQueue[T]{useWait: useWait}
+	q.release = tracked.Open("safe_queue")
 	if useWait {
-		q.nextCh = make(chan bool)
-		q.readValCh = make(chan bool)
-		q.notifyCh = make(chan bool, 1)
+		q.ready = make(chan struct{}, 1)
 		q.done = make(chan struct{})
-		go q.startNotify()
 	}
 	return q
 }
 
 func (q *SafeQueue[T]) Enqueue(value T) {
-	q.tail.AddLeft(value)
-	if q.len.Add(1) == 1 && q.useWait {
-		q.notifyCh <- true
+	q.mu.Lock()
+	if q.count == len(q.items) {
+		q.grow()
 	}
+	q.items[(q.head+q.count)%len(q.items)] = value
+	q.count++
+	q.mu.Unlock()
+	q.signal()
 }
 
+// Dequeue removes and returns the oldest value, or returns false at once if
+// the queue is empty.
 func (q *SafeQueue[T]) Dequeue() (T, bool) {
-	if q.useWait {
-		return q.Read(false)
-	}
-	defer q.len.Add(-1)
-	return q.dequeue()
+	return q.Read(false)
 }
 
-// Read blocks until a value is available in the queue, then returns it.
-// This is similar to receiving from a channel.
-// Setting wait to false will return immediately if no value is available.
-// Only the first end channel is used, if provided. End channel is not used if wait is false.
+// Read returns the oldest value. With wait (on a useWait queue) it blocks
+// until a value is available, like receiving from a channel, and returns
+// false once the queue is closed and empty or the first end channel, if
+// given, is closed. Without wait, or on a queue made without useWait, it
+// returns false at once if the queue is empty.
 func (q *SafeQueue[T]) Read(wait bool, end ...<-chan struct{}) (T, bool) {
-	if !q.useWait {
-		return q.Dequeue()
+	var endCh <-chan struct{}
+	if len(end) > 0 {
+		endCh = end[0]
 	}
-	if wait {
-		if len(end) == 0 {
-			<-q.nextCh
-			return q.readSuccess()
-		} else {
-			select {
-			case <-q.nextCh:
-				return q.readSuccess()
-			case <-end[0]:
-				var zero T
-				return zero, false // Return zero value if end channel is closed
-			}
+	for {
+		if value, ok := q.pop(); ok {
+			return value, true
+		}
+		var zero T
+		if !wait || !q.useWait {
+			return zero, false
 		}
-	} else {
 		select {
-		case <-q.nextCh:
-			return q.readSuccess()
-		default:
-			var zero T
-			return zero, false // No value available, return zero value
+		case <-q.ready:
+		case <-q.done:
+			return q.pop()
+		case <-endCh:
+			return zero, false
 		}
 	}
 }
 
-func (q *SafeQueue[T]) readSuccess() (T, bool) {
-	defer func() {
-		q.readValCh <- true // Notify that the value was read
-	}()
-	q.len.Add(-1) // Decrement length after reading
-	return q.dequeue()
-}
-
-// helper to dequeue an item from the queue
-func (q *SafeQueue[T]) dequeue() (T, bool) {
-	ch, done := q.head.manageRightLocks()
-	<-ch
-	defer utils.SafeCloseChannel(done)
-	n := q.head.next
-	if n == q.tail {
-		var zero T
-		return zero, false // Queue is empty
+// pop removes the oldest value. If more are left it wakes another reader,
+// since Enqueue only leaves one wake-up token however many values it adds.
+func (q *SafeQueue[T]) pop() (T, bool) {
+	var zero T
+	q.mu.Lock()
+	if q.count == 0 {
+		q.mu.Unlock()
+		return zero, false
+	}
+	value := q.items[q.head]
+	q.items[q.head] = zero // drop the reference for the GC
+	q.head = (q.head + 1) % len(q.items)
+	q.count--
+	remaining := q.count
+	if remaining == 0 {
+		q.head = 0
+		if len(q.items) > shrinkCap {
+			q.items = nil
+		}
+	}
+	q.mu.Unlock()
+	if remaining > 0 {
+		q.signal()
 	}
-	chl, donel := n.manageRightLocks()
-	<-chl
-	defer utils.SafeCloseChannel(donel)
-	value := n._RemoveSelf()
 	return value, true
 }
 
-func (q *SafeQueue[T]) startNotify() {
-	for {
-		select {
-		case <-q.done:
-			return
-		case <-q.notifyCh:
-		}
-		for q.len.Load() > 0 {
-			select {
-			case <-q.done:
-				return
-			default:
-				q.nextCh <- true
-				<-q.readValCh // Notify that the value was read
-			}
-		}
+// signal leaves a wake-up token for a blocked reader, if there is no token
+// already.
+func (q *SafeQueue[T]) signal() {
+	if !q.useWait {
+		return
+	}
+	select {
+	case q.ready <- struct{}{}:
+	default:
 	}
 }
 
+// grow doubles the buffer, moving the values to its start in order. The
+// caller holds q.mu.
+func (q *SafeQueue[T]) grow() {
+	items := make([]T, max(2*len(q.items), 8))
+	n := copy(items, q.items[q.head:])
+	copy(items[n:], q.items[:q.head])
+	q.items = items
+	q.head = 0
+}
+
+// Close wakes blocked readers; they return the values still queued, then
+// false. Closing again is a no-op.
 func (q *SafeQueue[T]) Close() error {
-	utils.SafeCloseChannel(q.done)
-	utils.SafeCloseChannel(q.nextCh)
-	utils.SafeCloseChannel(q.notifyCh)
-	utils.SafeCloseChannel(q.readValCh)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	if q.done != nil {
+		close(q.done)
+	}
+	q.release()
 	return nil
 }
 
 func (q *SafeQueue[T]) Size() int {
-	return int(q.len.Load())
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
 }
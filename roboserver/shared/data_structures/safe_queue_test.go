@@ -1,6 +1,8 @@
 package data_structures
 
 import (
+	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -230,14 +232,14 @@ func TestSafeQueueConcurrentEnqueueDequeue(t *testing.T) {
 	numGoroutines := 10
 	itemsPerGoroutine := 100
 
-	var enqueued, dequeued int64
+	var enqueued, dequeued atomic.Int64
 
 	// Start enqueuers
 	for i := 0; i < numGoroutines; i++ {
 		go func(start int) {
 			for j := 0; j < itemsPerGoroutine; j++ {
 				q.Enqueue(start*itemsPerGoroutine + j)
-				enqueued++
+				enqueued.Add(1)
 			}
 		}(i)
 	}
@@ -248,7 +250,7 @@ func TestSafeQueueConcurrentEnqueueDequeue(t *testing.T) {
 			for j := 0; j < itemsPerGoroutine; j++ {
 				for {
 					if _, ok := q.Dequeue(); ok {
-						dequeued++
+						dequeued.Add(1)
 						break
 					}
 					time.Sleep(1 * time.Millisecond) // Brief pause before retry
@@ -265,9 +267,9 @@ func TestSafeQueueConcurrentEnqueueDequeue(t *testing.T) {
 	for {
 		select {
 		case <-timeout:
-			t.Fatalf("Timeout: enqueued %d, dequeued %d", enqueued, dequeued)
+			t.Fatalf("Timeout: enqueued %d, dequeued %d", enqueued.Load(), dequeued.Load())
 		case <-ticker.C:
-			if dequeued == int64(numGoroutines*itemsPerGoroutine) {
+			if dequeued.Load() == int64(numGoroutines*itemsPerGoroutine) {
 				return // Test passed
 			}
 		}
@@ -337,3 +339,133 @@ func TestSafeQueueWaitVsNonWaitBehavior(t *testing.T) {
 		t.Errorf("Expected Dequeue to work for wait queue, got: %d, ok: %t", value, ok)
 	}
 }
+
+func TestSafeQueueCloseWakesReaders(t *testing.T) {
+	q := NewSafeQueue[int](true)
+	q.Enqueue(1)
+
+	results := make(chan bool, 2)
+	for range 2 {
+		go func() {
+			value, ok := q.Read(true)
+			results <- ok && value == 1
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	q.Close()
+
+	got := 0
+	for range 2 {
+		select {
+		case ok := <-results:
+			if ok {
+				got++
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected Close to wake a blocked reader")
+		}
+	}
+	if got != 1 {
+		t.Errorf("Expected exactly one reader to get the queued value, got %d", got)
+	}
+	if _, ok := q.Read(true); ok {
+		t.Error("Expected Read on a closed, empty queue to return false")
+	}
+}
+
+func TestSafeQueueWrapAround(t *testing.T) {
+	q := NewSafeQueue[int](false)
+	next, want := 0, 0
+	// Interleave so the ring wraps before and while it grows.
+	for round := 1; round <= 50; round++ {
+		for range round {
+			q.Enqueue(next)
+			next++
+		}
+		for range round / 2 {
+			if value, ok := q.Dequeue(); !ok || value != want {
+				t.Fatalf("Expected %d, got %d (ok=%v)", want, value, ok)
+			}
+			want++
+		}
+	}
+	for q.Size() > 0 {
+		if value, _ := q.Dequeue(); value != want {
+			t.Fatalf("Expected %d, got %d", want, value)
+		}
+		want++
+	}
+	if want != next {
+		t.Errorf("Expected %d values, got %d", next, want)
+	}
+}
+
+func TestSafeQueueStartsNoGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	queues := make([]*SafeQueue[int], 100)
+	for i := range queues {
+		queues[i] = NewSafeQueue[int](true)
+		queues[i].Enqueue(i)
+		queues[i].Read(true)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no goroutines per queue, went from %d to %d", before, after)
+	}
+	for _, q := range queues {
+		q.Close()
+	}
+}
+
+// BenchmarkSafeQueueSSE is the SSE client's pattern: bus handlers enqueue
+// events while one writer goroutine blocks in Read with a done channel.
+func BenchmarkSafeQueueSSE(b *testing.B) {
+	q := NewSafeQueue[*int](true)
+	defer q.Close()
+	done := make(chan struct{})
+	read := make(chan struct{})
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, ok := q.Read(true, done); !ok {
+				break
+			}
+		}
+		close(read)
+	}()
+
+	v := 1
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(&v)
+	}
+	<-read
+}
+
+// BenchmarkSafeQueueSSEParallel is BenchmarkSafeQueueSSE with many
+// publishers enqueueing at once.
+func BenchmarkSafeQueueSSEParallel(b *testing.B) {
+	q := NewSafeQueue[int](true)
+	defer q.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			if _, ok := q.Read(true, done); !ok {
+				return
+			}
+		}
+	}()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Enqueue(1)
+		}
+	})
+}
+
+func BenchmarkSafeQueueEnqueueDequeue(b *testing.B) {
+	q := NewSafeQueue[int](false)
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(i)
+		q.Dequeue()
+	}
+}
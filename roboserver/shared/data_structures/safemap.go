@@ -64,22 +64,39 @@ func (sm *SafeMap[K, V]) GetKeys() []K {
 	return keys
 }
 
-// DeleteIfEmpty removes the key if the value implements IsEmpty() and returns true
-// Returns false if key doesn't exist, value doesn't implement IsEmpty(), or value is not empty
-func (sm *SafeMap[K, V]) DeleteIfEmpty(key K) bool {
+// Compute replaces the value of key with what fn returns, holding the map's
+// lock throughout. fn gets the current value and whether key exists; it
+// returns the new value and whether to keep it (false deletes key). Nothing
+// else touches the entry until fn returns, so a value can be changed and
+// then kept or dropped depending on its new state, without racing another
+// Compute that would recreate or empty it. fn must not call back into sm.
+func (sm *SafeMap[K, V]) Compute(key K, fn func(val V, exists bool) (V, bool)) (V, bool) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.m == nil {
+		sm.m = make(map[K]V)
+	}
+	val, exists := sm.m[key]
+	val, keep := fn(val, exists)
+	if keep {
+		sm.m[key] = val
+	} else {
+		delete(sm.m, key)
+	}
+	return val, keep
+}
 
-	if val, ok := sm.m[key]; ok {
-		// Check if the value implements the IsEmpty() method
-		if emptyable, hasMethod := any(val).(interface{ IsEmpty() bool }); hasMethod {
-			if emptyable.IsEmpty() {
-				delete(sm.m, key)
-				return true
-			}
+// Upsert applies update to the value of key, first storing newValue() if
+// key is missing, as one step under the map's lock. It returns the value.
+func (sm *SafeMap[K, V]) Upsert(key K, newValue func() V, update func(V)) V {
+	val, _ := sm.Compute(key, func(val V, exists bool) (V, bool) {
+		if !exists {
+			val = newValue()
 		}
-	}
-	return false
+		update(val)
+		return val, true
+	})
+	return val
 }
 
 func (sm *SafeMap[K, V]) IsEmpty() bool {
@@ -195,6 +195,73 @@ func TestSafeMapConcurrentGetOrDefault(t *testing.T) {
 	}
 }
 
+func TestSafeMapCompute(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+
+	val, kept := sm.Compute("a", func(v int, exists bool) (int, bool) {
+		if exists {
+			t.Error("Expected a to be missing")
+		}
+		return 1, true
+	})
+	if val != 1 || !kept {
+		t.Errorf("Compute = %d, %v; want 1, true", val, kept)
+	}
+	sm.Compute("a", func(v int, exists bool) (int, bool) { return v + 1, true })
+	if v, _ := sm.Get("a"); v != 2 {
+		t.Errorf("Expected a = 2, got %d", v)
+	}
+
+	// Returning false deletes the key.
+	if _, kept := sm.Compute("a", func(v int, exists bool) (int, bool) { return v, false }); kept {
+		t.Error("Expected a to be dropped")
+	}
+	if _, ok := sm.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+}
+
+// TestSafeMapUpsertRacesRemoval keeps adding to and emptying a set under one
+// key. Each adder's value must survive: removal of the emptied set and the
+// next Upsert can't interleave.
+func TestSafeMapUpsertRacesRemoval(t *testing.T) {
+	sm := NewSafeMap[string, *SafeSet[int]]()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(id int) {
+			defer wg.Done()
+			sm.Upsert("k", NewSafeSet[int], func(set *SafeSet[int]) { set.Add(id) })
+		}(i)
+		go func(id int) {
+			defer wg.Done()
+			tmp := 1000 + id
+			sm.Upsert("k", NewSafeSet[int], func(set *SafeSet[int]) { set.Add(tmp) })
+			sm.Compute("k", func(set *SafeSet[int], exists bool) (*SafeSet[int], bool) {
+				if !exists {
+					return nil, false
+				}
+				set.Remove(tmp)
+				return set, !set.IsEmpty()
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	set, ok := sm.Get("k")
+	if !ok {
+		t.Fatal("Expected the set to remain")
+	}
+	for i := 0; i < 50; i++ {
+		if !set.Contains(i) {
+			t.Errorf("Lost %d", i)
+		}
+	}
+	if len(set.Snapshot()) != 50 {
+		t.Errorf("Expected 50 members, got %d", len(set.Snapshot()))
+	}
+}
+
 func TestSafeMapConcurrentDeletes(t *testing.T) {
 	sm := NewSafeMap[int, string]()
 
@@ -2,7 +2,6 @@ package data_structures
 
 import (
 	"sync"
-	"sync/atomic"
 )
 
 type Node[T any] struct {
@@ -27,15 +26,16 @@ type SafeMap[K comparable, V any] struct {
 }
 
 type SafeQueue[T any] struct {
-	head *Node[T]
-	tail *Node[T]
-	len  atomic.Int64
+	mu     sync.Mutex
+	items  []T // ring buffer; count values from head
+	head   int
+	count  int
+	closed bool
 
-	useWait   bool
-	nextCh    chan bool
-	readValCh chan bool
-	notifyCh  chan bool
-	done      chan struct{}
+	useWait bool
+	ready   chan struct{} // wake-up token for blocked readers (buffer 1)
+	done    chan struct{} // closed by Close
+	release func()        // ends the queue's count in shared/tracked
 }
 
 // This Set is a thread-safe data structure that allows multiple values of the same type to be stored.
@@ -13,6 +13,8 @@
 // - Clean function name extraction
 // - Package-aware formatting
 // - Conditional panic behavior for development vs production
+// - Per-call-site rate limiting (server.debug_rate_limit) for DebugPrint and
+//   DebugPrintWithPackage, with a "suppressed N similar messages" summary
 // - Color-coded output for different log levels
 
 // shared/debug.go
@@ -20,6 +22,7 @@ package shared
 
 import (
 	"log"
+	"net/netip"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -49,7 +52,7 @@ const (
 
 // TempDebugPrint can be used for temporary debug messages that include file/line info.
 func TempDebugPrint(format string, args ...interface{}) {
-	if !DEBUG_MODE {
+	if !DEBUG_MODE.Load() {
 		return
 	}
 
@@ -72,7 +75,7 @@ func TempDebugPrint(format string, args ...interface{}) {
 
 // DebugPrint automatically gets file, line, and function info
 func DebugPrint(format string, args ...interface{}) {
-	if !DEBUG_MODE {
+	if !DEBUG_MODE.Load() {
 		return
 	}
 
@@ -82,6 +85,9 @@ func DebugPrint(format string, args ...interface{}) {
 		log.Printf(ColorCyan+"DEBUG: "+format+ColorReset+"\n", args...)
 		return
 	}
+	if !sampleDebug(pc, file, line) {
+		return
+	}
 
 	// Get just the filename (not full path)
 	filename := filepath.Base(file)
@@ -96,7 +102,7 @@ func DebugPrint(format string, args ...interface{}) {
 
 // DebugError prints an error message with file/line info
 func DebugError(err error) {
-	if !DEBUG_MODE {
+	if !DEBUG_MODE.Load() {
 		log.Printf(ColorRed+"ERROR: %v"+ColorReset+"\n", err)
 		return
 	}
@@ -115,7 +121,7 @@ func DebugError(err error) {
 }
 
 func DebugErrorf(format string, args ...interface{}) {
-	if !DEBUG_MODE {
+	if !DEBUG_MODE.Load() {
 		log.Printf(ColorRed+"ERROR: "+format+ColorReset+"\n", args...)
 		return
 	}
@@ -135,7 +141,7 @@ func DebugErrorf(format string, args ...interface{}) {
 
 // DebugPrintWithPackage shows package/file:line format
 func DebugPrintWithPackage(format string, args ...interface{}) {
-	if !DEBUG_MODE {
+	if !DEBUG_MODE.Load() {
 		return
 	}
 
@@ -145,6 +151,9 @@ func DebugPrintWithPackage(format string, args ...interface{}) {
 		log.Printf(ColorBlue+"DEBUG: "+format+ColorReset, args...)
 		return
 	}
+	if !sampleDebug(pc, file, line) {
+		return
+	}
 
 	// Get package and file
 	packagePath := getPackageFromFile(file)
@@ -160,7 +169,7 @@ func DebugPrintWithPackage(format string, args ...interface{}) {
 func DebugPanic(format string, args ...interface{}) {
 	// Use runtime.Caller(1) to get the caller of DebugPanic
 	pc, file, line, ok := runtime.Caller(1)
-	if !ok || !DEBUG_MODE {
+	if !ok || !DEBUG_MODE.Load() {
 		log.Fatalf(ColorBoldRed+"FATAL: "+format+ColorReset, args...)
 		return
 	}
@@ -183,8 +192,13 @@ func RedactToken(token string) string {
 	return token[:8] + "..." + token[len(token)-4:]
 }
 
-// RedactIP masks the last octet of an IPv4 address for privacy.
+// RedactIP masks the last octet of an IPv4 address, or all but the /48
+// prefix of an IPv6 address, for privacy.
 func RedactIP(ip string) string {
+	if addr, err := netip.ParseAddr(CanonicalIP(ip)); err == nil && addr.Is6() {
+		prefix, _ := addr.WithZone("").Prefix(48)
+		return prefix.Addr().String() + "***"
+	}
 	lastDot := strings.LastIndex(ip, ".")
 	if lastDot < 0 {
 		return ip // Not IPv4 or no dots — return as-is
@@ -0,0 +1,67 @@
+package shared
+
+import (
+	"log"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// debugRateLimit is how many lines one DebugPrint call site may log per
+// second (server.debug_rate_limit; 0 = unlimited). Like DEBUG_MODE it is
+// atomic because ReloadConfig can change it while others are logging.
+var debugRateLimit atomic.Int64
+
+// debugSite counts one call site's lines in the current one-second window.
+type debugSite struct {
+	window     time.Time
+	count      int64
+	suppressed int
+}
+
+var (
+	debugSitesMu sync.Mutex
+	debugSites   = make(map[uintptr]*debugSite)
+)
+
+// allowDebug reports whether the call site pc may log at now. When a new
+// window starts it also returns how many of the site's lines the previous
+// windows dropped, so the caller can log a summary first.
+func allowDebug(pc uintptr, now time.Time) (ok bool, suppressed int) {
+	limit := debugRateLimit.Load()
+	if limit <= 0 {
+		return true, 0
+	}
+	debugSitesMu.Lock()
+	defer debugSitesMu.Unlock()
+	site, found := debugSites[pc]
+	if !found {
+		site = &debugSite{window: now}
+		debugSites[pc] = site
+	}
+	if now.Sub(site.window) >= time.Second {
+		site.window = now
+		site.count = 0
+		suppressed, site.suppressed = site.suppressed, 0
+	}
+	if site.count >= limit {
+		site.suppressed++
+		return false, 0
+	}
+	site.count++
+	return true, suppressed
+}
+
+// sampleDebug applies the rate limit to a debug line from pc, logging the
+// "suppressed N similar messages" summary when one is due. It returns
+// false if the line should be dropped.
+func sampleDebug(pc uintptr, file string, line int) bool {
+	ok, suppressed := allowDebug(pc, time.Now())
+	if suppressed > 0 {
+		funcName := getShortFuncName(runtime.FuncForPC(pc).Name())
+		log.Printf(ColorGray+"[%s:%d %s]: suppressed %d similar messages"+ColorReset+"\n", filepath.Base(file), line, funcName, suppressed)
+	}
+	return ok
+}
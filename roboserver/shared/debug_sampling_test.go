@@ -0,0 +1,44 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowDebugLimitsPerCallSite(t *testing.T) {
+	debugRateLimit.Store(2)
+	defer debugRateLimit.Store(0)
+
+	now := time.Now()
+	const site, other = uintptr(0x1001), uintptr(0x1002)
+	for i := 0; i < 2; i++ {
+		if ok, _ := allowDebug(site, now); !ok {
+			t.Fatalf("Expected line %d within the limit to be logged", i+1)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if ok, _ := allowDebug(site, now); ok {
+			t.Fatal("Expected lines over the limit to be suppressed")
+		}
+	}
+	if ok, _ := allowDebug(other, now); !ok {
+		t.Error("Expected another call site to have its own budget")
+	}
+
+	ok, suppressed := allowDebug(site, now.Add(time.Second))
+	if !ok || suppressed != 3 {
+		t.Errorf("Expected the next window to log and report 3 suppressed, got %v %d", ok, suppressed)
+	}
+	if _, suppressed := allowDebug(site, now.Add(time.Second)); suppressed != 0 {
+		t.Errorf("Expected the summary to be reported once, got %d", suppressed)
+	}
+}
+
+func TestAllowDebugUnlimited(t *testing.T) {
+	debugRateLimit.Store(0)
+	for i := 0; i < 100; i++ {
+		if ok, _ := allowDebug(0x2001, time.Now()); !ok {
+			t.Fatal("Expected no limit when debug_rate_limit is 0")
+		}
+	}
+}
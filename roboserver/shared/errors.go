@@ -1,6 +1,84 @@
 package shared
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Authentication Errors
 var ErrUnauthorized = errors.New("unauthorized access")
+
+// ErrPayloadTooLarge is matched (via errors.Is) by every PayloadTooLargeError.
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// PayloadTooLargeError reports a message rejected by one of the limits.*
+// size caps. Source names where it arrived: "tcp", "http" or "handler".
+type PayloadTooLargeError struct {
+	Source string
+	Size   int // bytes received, or -1 when reading stopped at the limit
+	Limit  int
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	if e.Size < 0 {
+		return fmt.Sprintf("%s payload exceeds %d bytes", e.Source, e.Limit)
+	}
+	return fmt.Sprintf("%s payload of %d bytes exceeds %d bytes", e.Source, e.Size, e.Limit)
+}
+
+func (e *PayloadTooLargeError) Is(target error) bool {
+	return target == ErrPayloadTooLarge
+}
+
+// CheckPayloadSize returns a *PayloadTooLargeError if size exceeds limit.
+// A limit of 0 or less disables the check.
+func CheckPayloadSize(source string, size, limit int) error {
+	if limit > 0 && size > limit {
+		return &PayloadTooLargeError{Source: source, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// Robot errors. They reach callers wrapped in a *RobotError naming the robot
+// and operation; match them with errors.Is.
+var (
+	ErrRobotNotFound    = errors.New("unknown robot")
+	ErrRobotBlacklisted = errors.New("robot is blacklisted")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrStaleHeartbeat   = errors.New("stale heartbeat sequence")
+	// ErrRobotMismatch: the credential presented (a session JWT) belongs to
+	// a different robot than the one claimed.
+	ErrRobotMismatch = errors.New("credential belongs to a different robot")
+)
+
+// RobotError is a failure tied to one robot: Op names what was being done
+// ("handshake", "heartbeat", "udp message"), DeviceID and IP identify the
+// robot and where the request came from. Err is usually one of the robot
+// sentinels above, possibly wrapped with detail.
+type RobotError struct {
+	Op       string
+	DeviceID string
+	IP       string
+	Err      error
+}
+
+// NewRobotError wraps err with the robot it refers to. It returns nil for a
+// nil err.
+func NewRobotError(op, deviceID, ip string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RobotError{Op: op, DeviceID: deviceID, IP: ip, Err: err}
+}
+
+func (e *RobotError) Error() string {
+	msg := e.Op + " " + e.DeviceID
+	if e.IP != "" {
+		msg += " from " + RedactIP(e.IP)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *RobotError) Unwrap() error {
+	return e.Err
+}
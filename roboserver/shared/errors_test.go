@@ -0,0 +1,31 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRobotError(t *testing.T) {
+	err := NewRobotError("heartbeat", "robot-1", "10.0.0.42", fmt.Errorf("%w: got 3, last was 5", ErrStaleHeartbeat))
+
+	if !errors.Is(err, ErrStaleHeartbeat) {
+		t.Errorf("errors.Is should match the wrapped sentinel")
+	}
+	if errors.Is(err, ErrRobotMismatch) {
+		t.Errorf("errors.Is matched an unrelated sentinel")
+	}
+	var re *RobotError
+	if !errors.As(fmt.Errorf("outer: %w", err), &re) || re.DeviceID != "robot-1" {
+		t.Fatalf("errors.As should find the RobotError, got %v", re)
+	}
+
+	want := "heartbeat robot-1 from 10.0.0.***: stale heartbeat sequence: got 3, last was 5"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	if NewRobotError("heartbeat", "robot-1", "", nil) != nil {
+		t.Errorf("NewRobotError(nil) should return nil")
+	}
+}
@@ -1,5 +1,7 @@
 package event_bus
 
+import "time"
+
 func NewDefaultEvent(eventType string, data interface{}) *DefaultEvent {
 	return &DefaultEvent{
 		Type: eventType,
@@ -14,3 +16,44 @@ func (e *DefaultEvent) GetType() string {
 func (e *DefaultEvent) GetData() interface{} {
 	return e.Data
 }
+
+// TraceParentOf returns the traceparent an event was published with, or "".
+func TraceParentOf(event Event) string {
+	if e, ok := event.(interface{ traceParent() string }); ok {
+		return e.traceParent()
+	}
+	return ""
+}
+
+func (e *DefaultEvent) traceParent() string { return e.TraceParent }
+
+// ExpiresAt returns when an event goes stale, or the zero time if it
+// never does.
+func ExpiresAt(event Event) time.Time {
+	if e, ok := event.(interface{ expiresAt() time.Time }); ok {
+		return e.expiresAt()
+	}
+	return time.Time{}
+}
+
+// Expired reports whether an event has gone stale by now.
+func Expired(event Event, now time.Time) bool {
+	expires := ExpiresAt(event)
+	return !expires.IsZero() && !now.Before(expires)
+}
+
+func (e *DefaultEvent) expiresAt() time.Time { return e.ExpiresAt }
+
+// RemoteEvent is an event received from another server instance (see
+// comms cluster fan-out). It is delivered like any other event; consumers
+// that must act once per cluster, such as the exporter, skip it.
+type RemoteEvent struct {
+	DefaultEvent
+	Node string // instance that published it
+}
+
+// IsRemote reports whether an event came from another server instance.
+func IsRemote(event Event) bool {
+	_, ok := event.(*RemoteEvent)
+	return ok
+}
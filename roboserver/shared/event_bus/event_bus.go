@@ -3,20 +3,45 @@ package event_bus
 import (
 	"roboserver/shared"
 	"roboserver/shared/data_structures"
-	"sync/atomic"
+	"roboserver/shared/metrics"
+	"time"
 )
 
-// inFlight counts concurrent handler goroutines. Publishers drop events
-// rather than block once we hit EVENT_BUS_BUFFER_SIZE, so a slow/stuck
-// subscriber can never stall the publish path (and therefore the server's
-// network goroutines that call into it).
-var inFlight atomic.Int64
-
 func NewEventBus() EventBus {
 	return &EventBus_t{
 		subscriptions: data_structures.NewSafeMap[string, *data_structures.SafeSet[Subscriber]](),
 		handlers:      data_structures.NewSafeMap[Subscriber, *data_structures.SafeMap[string, SubscriberHandler]](),
+		queues:        data_structures.NewSafeMap[Subscriber, *subscriberQueue](),
+	}
+}
+
+// SetOrdered marks an event type (or "prefix.*" pattern) as ordered: each
+// subscriber then receives those events one at a time, in publish order,
+// from a per-subscriber FIFO worker instead of a goroutine per event.
+func (eb *EventBus_t) SetOrdered(eventType string, ordered bool) {
+	if eventType == "" {
+		return
+	}
+	eb.ordered.set(eventType, ordered)
+}
+
+// SetLimit caps the handler goroutines this bus runs at once for
+// unordered events, and how far behind each subscriber's ordered queue may
+// fall; limit <= 0 restores shared.EVENT_BUS_BUFFER_SIZE. Events over the
+// cap are dropped.
+func (eb *EventBus_t) SetLimit(limit int) {
+	eb.limits.max.Store(int64(max(limit, 0)))
+}
+
+// SetTopicLimit caps the handler goroutines running at once for an event
+// type (or for all types matching a "prefix.*" pattern, together), within
+// the bus-wide limit; limit <= 0 removes the cap. An exact type's cap wins
+// over a pattern's, and a longer prefix over a shorter one.
+func (eb *EventBus_t) SetTopicLimit(eventType string, limit int) {
+	if eventType == "" {
+		return
 	}
+	eb.limits.setTopic(eventType, limit)
 }
 
 func (eb *EventBus_t) Subscribe(eventType string, subscriber *Subscriber, handler SubscriberHandler) *Subscriber {
@@ -24,13 +49,15 @@ func (eb *EventBus_t) Subscribe(eventType string, subscriber *Subscriber, handle
 		subscriber = NewSubscriber()
 	}
 
-	// Store the handler function — GetOrDefault returns the existing or newly-inserted map,
-	// then we set the handler on it. No retry loop needed: if a concurrent Unsubscribe
-	// removes the entry, re-subscribing is the caller's responsibility.
-	eb.handlers.GetOrDefault(*subscriber, data_structures.NewSafeMap[string, SubscriberHandler]()).Set(eventType, handler)
-
-	// Add subscriber to set
-	eb.subscriptions.GetOrDefault(eventType, data_structures.NewSafeSet[Subscriber]()).Add(*subscriber)
+	// Each step runs under the outer map's lock, so an Unsubscribe that
+	// empties and removes the same entry can't drop the handler or the
+	// set member added here.
+	eb.handlers.Upsert(*subscriber, data_structures.NewSafeMap[string, SubscriberHandler], func(handlers *data_structures.SafeMap[string, SubscriberHandler]) {
+		handlers.Set(eventType, handler)
+	})
+	eb.subscriptions.Upsert(eventType, data_structures.NewSafeSet[Subscriber], func(set *data_structures.SafeSet[Subscriber]) {
+		set.Add(*subscriber)
+	})
 
 	return subscriber
 }
@@ -52,14 +79,28 @@ func (eb *EventBus_t) Unsubscribe(eventType string, subscriber *Subscriber) {
 		return
 	}
 
-	// Remove subscriber from multiset
-	if multiset, ok := eb.subscriptions.Get(eventType); ok {
-		multiset.Remove(*subscriber)
-		eb.subscriptions.DeleteIfEmpty(eventType)
-	}
-	if handlers, ok := eb.handlers.Get(*subscriber); ok {
+	// Remove the subscriber from the set, and the set once empty, in one
+	// step: a Subscribe between the two would otherwise join a set that
+	// is no longer in the map.
+	eb.subscriptions.Compute(eventType, func(set *data_structures.SafeSet[Subscriber], exists bool) (*data_structures.SafeSet[Subscriber], bool) {
+		if !exists {
+			return nil, false
+		}
+		set.Remove(*subscriber)
+		return set, !set.IsEmpty()
+	})
+	hadHandlers := false
+	_, kept := eb.handlers.Compute(*subscriber, func(handlers *data_structures.SafeMap[string, SubscriberHandler], exists bool) (*data_structures.SafeMap[string, SubscriberHandler], bool) {
+		if !exists {
+			return nil, false
+		}
+		hadHandlers = true
 		handlers.Delete(eventType)
-		eb.handlers.DeleteIfEmpty(*subscriber)
+		return handlers, !handlers.IsEmpty()
+	})
+	if hadHandlers && !kept {
+		// Any events still queued finish on the running worker.
+		eb.queues.Delete(*subscriber)
 	}
 }
 
@@ -67,30 +108,49 @@ func (eb *EventBus_t) Publish(event Event) {
 	if event == nil || event.GetType() == "" {
 		return
 	}
+	eb.middleware.wrapPublish(eb.dispatch)(event)
+}
+
+// dispatch delivers an event that made it through the publish middleware.
+func (eb *EventBus_t) dispatch(event Event) {
+	if event == nil || event.GetType() == "" {
+		return
+	}
 
 	eventType := event.GetType()
+	if Expired(event, time.Now()) {
+		shared.DebugPrint("Dropping expired event: %s", eventType)
+		return
+	}
 
 	shared.DebugPrint("Publishing event: %s", eventType)
+	metrics.RecordEvent()
+	eb.taps.deliver(event)
+
+	ordered := eb.ordered.matches(eventType)
 
 	if subscribers, ok := eb.subscriptions.Get(eventType); ok {
 		for _, sub := range subscribers.Snapshot() {
 			if mp, ok := eb.handlers.Get(sub); ok {
 				if handler, ok := mp.Get(eventType); ok {
+					handler = eb.middleware.wrapHandler(handler)
+					if ordered {
+						q := eb.queues.GetOrDefault(sub, &subscriberQueue{})
+						if !q.enqueue(handler, event, eb.limits.limit()) {
+							shared.DebugPrint("Ordered queue full for subscriber %s, dropping event: %s", sub.ID, eventType)
+						}
+						continue
+					}
 					// Non-blocking backpressure: drop rather than stall the publisher
 					// (which is usually a network goroutine).
-					if inFlight.Load() >= int64(shared.EVENT_BUS_BUFFER_SIZE) {
+					topic, ok := eb.limits.acquire(eventType)
+					if !ok {
 						shared.DebugPrint("Event bus saturated, dropping event: %s", eventType)
 						continue
 					}
-					inFlight.Add(1)
 					go func() {
-						defer func() {
-							inFlight.Add(-1)
-							if r := recover(); r != nil {
-								shared.DebugPrint("Event handler panic on %s: %v", eventType, r)
-							}
-						}()
-						handler(event)
+						defer eb.limits.release(topic)
+						deliver(handler, event)
 					}()
 				} else {
 					subCopy := sub
@@ -20,4 +20,27 @@ type EventBus interface {
 	Publish(event Event)
 
 	PublishData(eventType string, data interface{})
+
+	// SetOrdered declares whether events of a type (or "prefix.*" pattern)
+	// are delivered to each subscriber sequentially in publish order.
+	SetOrdered(eventType string, ordered bool)
+
+	// SetLimit caps the handler goroutines running at once on this bus
+	// (<= 0 = shared.EVENT_BUS_BUFFER_SIZE). Events over it are dropped.
+	SetLimit(limit int)
+
+	// SetTopicLimit caps the handler goroutines running at once for an
+	// event type or "prefix.*" pattern (<= 0 removes the cap).
+	SetTopicLimit(eventType string, limit int)
+
+	// Tap registers a handler that synchronously sees every published event,
+	// whatever its type. The handler must not block. Returns a cancel func.
+	Tap(handler SubscriberHandler) (cancel func())
+
+	// UsePublish adds middleware around Publish (logging, filtering,
+	// enrichment). The first added runs first.
+	UsePublish(mw ...PublishMiddleware)
+
+	// UseHandler adds middleware around every subscriber handler call.
+	UseHandler(mw ...HandlerMiddleware)
 }
@@ -344,6 +344,55 @@ func TestEventBusConcurrentSubscribeUnsubscribe(t *testing.T) {
 	// Test passes if no races or panics occurred
 }
 
+// TestEventBusSubscribeSurvivesConcurrentUnsubscribe churns subscribers
+// on a type while others subscribe to it; every subscriber still registered
+// at the end must receive the next event.
+func TestEventBusSubscribeSurvivesConcurrentUnsubscribe(t *testing.T) {
+	eb := NewEventBus()
+	var received atomic.Int64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			churn := NewSubscriber()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				eb.Subscribe("churn_event", churn, func(Event) {})
+				eb.Unsubscribe("churn_event", churn)
+			}
+		}()
+	}
+
+	const subscribers = 200
+	var subWG sync.WaitGroup
+	for i := 0; i < subscribers; i++ {
+		subWG.Add(1)
+		go func() {
+			defer subWG.Done()
+			eb.Subscribe("churn_event", nil, func(Event) { received.Add(1) })
+		}()
+	}
+	subWG.Wait()
+	close(stop)
+	wg.Wait()
+
+	eb.Publish(&TestEvent{eventType: "churn_event", data: "x"})
+	deadline := time.Now().Add(time.Second)
+	for received.Load() < subscribers && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := received.Load(); got != subscribers {
+		t.Errorf("Expected %d deliveries, got %d", subscribers, got)
+	}
+}
+
 // Performance tests
 func TestEventBusPerformance(t *testing.T) {
 	eb := NewEventBus()
@@ -494,3 +543,295 @@ func TestEventBusRobotScenarios(t *testing.T) {
 		t.Errorf("Expected 1 robot_removed event, got %d", robotRemovedCount)
 	}
 }
+
+func TestOrderedDeliveryPreservesPublishOrder(t *testing.T) {
+	eb := NewEventBus()
+	eb.SetOrdered("robot_added", true)
+	eb.SetOrdered("robot_removed", true)
+
+	var mu sync.Mutex
+	var got []string
+	done := make(chan struct{})
+	const n = 200
+
+	sub := NewSubscriber()
+	record := func(event Event) {
+		mu.Lock()
+		got = append(got, fmt.Sprintf("%s:%v", event.GetType(), event.GetData()))
+		if len(got) == n {
+			close(done)
+		}
+		mu.Unlock()
+	}
+	eb.Subscribe("robot_added", sub, record)
+	eb.Subscribe("robot_removed", sub, record)
+
+	for i := 0; i < n/2; i++ {
+		eb.Publish(&TestEvent{eventType: "robot_added", data: i})
+		eb.Publish(&TestEvent{eventType: "robot_removed", data: i})
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out; received %d of %d events", len(got), n)
+	}
+
+	for i := 0; i < n/2; i++ {
+		if got[2*i] != fmt.Sprintf("robot_added:%d", i) || got[2*i+1] != fmt.Sprintf("robot_removed:%d", i) {
+			t.Fatalf("Out of order at %d: %v", i, got[2*i:2*i+2])
+		}
+	}
+}
+
+func TestOrderedDeliveryIsSequential(t *testing.T) {
+	eb := NewEventBus()
+	eb.SetOrdered("presence.*", true)
+
+	var active, maxActive atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(20)
+	eb.Subscribe("presence.enter", nil, func(event Event) {
+		defer wg.Done()
+		if cur := active.Add(1); cur > maxActive.Load() {
+			maxActive.Store(cur)
+		}
+		time.Sleep(time.Millisecond)
+		active.Add(-1)
+	})
+
+	for i := 0; i < 20; i++ {
+		eb.PublishData("presence.enter", i)
+	}
+	wg.Wait()
+
+	if maxActive.Load() != 1 {
+		t.Errorf("Expected one handler at a time for ordered events, saw %d", maxActive.Load())
+	}
+}
+
+func TestOrderedPatternCanBeCleared(t *testing.T) {
+	var o orderedTypes
+	o.set("robot.*", true)
+	o.set("exact", true)
+	if !o.matches("robot.abc.heartbeat") || !o.matches("exact") {
+		t.Fatal("Expected prefix and exact patterns to match")
+	}
+	if o.matches("robots") || o.matches("exact.more") {
+		t.Error("Expected non-matching types to stay unordered")
+	}
+
+	o.set("robot.*", false)
+	o.set("exact", false)
+	if o.matches("robot.abc.heartbeat") || o.matches("exact") {
+		t.Error("Expected cleared patterns to stop matching")
+	}
+}
+
+func TestOrderedHandlerPanicDoesNotStopQueue(t *testing.T) {
+	eb := NewEventBus()
+	eb.SetOrdered("flaky", true)
+
+	received := make(chan int, 2)
+	eb.Subscribe("flaky", nil, func(event Event) {
+		if event.GetData() == 0 {
+			panic("boom")
+		}
+		received <- event.GetData().(int)
+	})
+
+	eb.PublishData("flaky", 0)
+	eb.PublishData("flaky", 1)
+
+	select {
+	case v := <-received:
+		if v != 1 {
+			t.Errorf("Expected 1, got %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected queue to keep delivering after a panic")
+	}
+}
+
+func TestTapSeesEveryEventUntilCancelled(t *testing.T) {
+	eb := NewEventBus()
+
+	var seen []string
+	cancel := eb.Tap(func(event Event) {
+		seen = append(seen, event.GetType())
+	})
+
+	// No subscribers: taps still see the events, synchronously
+	eb.PublishData("robot.r1.heartbeat", 1)
+	eb.PublishData("presence.enter", 2)
+	cancel()
+	eb.PublishData("robot.r1.heartbeat", 3)
+
+	if len(seen) != 2 || seen[0] != "robot.r1.heartbeat" || seen[1] != "presence.enter" {
+		t.Errorf("Expected tap to see 2 events before cancel, got %v", seen)
+	}
+}
+
+func TestPublishMiddlewareFiltersAndEnriches(t *testing.T) {
+	eb := NewEventBus()
+	var order []string
+	eb.UsePublish(
+		func(next PublishFunc) PublishFunc {
+			return func(event Event) {
+				order = append(order, "outer")
+				next(event)
+			}
+		},
+		FilterEvents(func(event Event) bool { return event.GetType() != "noisy" }),
+		func(next PublishFunc) PublishFunc {
+			return func(event Event) {
+				next(NewDefaultEvent(event.GetType(), fmt.Sprintf("%v+enriched", event.GetData())))
+			}
+		},
+	)
+
+	var seen []string
+	eb.Tap(func(event Event) { seen = append(seen, fmt.Sprint(event.GetData())) })
+	eb.PublishData("noisy", "a")
+	eb.PublishData("robot.r1.status", "b")
+
+	if len(order) != 2 {
+		t.Errorf("Expected the outer middleware to see both events, got %v", order)
+	}
+	if len(seen) != 1 || seen[0] != "b+enriched" {
+		t.Errorf("Expected only the enriched robot event, got %v", seen)
+	}
+}
+
+func TestHandlerMiddlewareWrapsDelivery(t *testing.T) {
+	eb := NewEventBus()
+	done := make(chan string, 1)
+	eb.Subscribe("test_event", nil, func(Event) {})
+	eb.UseHandler(TimeHandlers(func(eventType string, _ time.Duration) { done <- eventType }))
+
+	eb.PublishData("test_event", 1)
+	select {
+	case got := <-done:
+		if got != "test_event" {
+			t.Errorf("Expected test_event, got %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected handler middleware to run for an existing subscriber")
+	}
+}
+
+func TestExpiredEventsAreDropped(t *testing.T) {
+	eb := NewEventBus()
+	var seen []string
+	cancel := eb.Tap(func(event Event) { seen = append(seen, event.GetType()) })
+	eb.Publish(&DefaultEvent{Type: "door.prompt", Data: 0, ExpiresAt: time.Now().Add(-time.Second)})
+	cancel()
+	if len(seen) != 0 {
+		t.Errorf("Expected an already expired event not to be published, got %v", seen)
+	}
+
+	// An ordered event that goes stale behind a slow handler is skipped.
+	eb.SetOrdered("door.*", true)
+	release := make(chan struct{})
+	got := make(chan any, 3)
+	eb.Subscribe("door.prompt", nil, func(event Event) {
+		if event.GetData() == 1 {
+			<-release
+		}
+		got <- event.GetData()
+	})
+	eb.Publish(&DefaultEvent{Type: "door.prompt", Data: 1})
+	eb.Publish(&DefaultEvent{Type: "door.prompt", Data: 2, ExpiresAt: time.Now().Add(20 * time.Millisecond)})
+	eb.Publish(&DefaultEvent{Type: "door.prompt", Data: 3, ExpiresAt: time.Now().Add(time.Minute)})
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for _, want := range []any{1, 3} {
+		select {
+		case data := <-got:
+			if data != want {
+				t.Errorf("Expected %v, got %v", want, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event %v to be delivered", want)
+		}
+	}
+}
+
+func TestLimitsArePerBus(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	busy := NewEventBus()
+	busy.SetLimit(1)
+	started := make(chan struct{}, 2)
+	busy.Subscribe("job", nil, func(event Event) {
+		started <- struct{}{}
+		<-release
+	})
+	busy.Publish(&DefaultEvent{Type: "job"})
+	<-started
+	busy.Publish(&DefaultEvent{Type: "job"})
+	select {
+	case <-started:
+		t.Fatal("Expected the second event to be dropped once the bus limit is used up")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A saturated bus doesn't throttle an independent one.
+	other := NewEventBus()
+	got := make(chan struct{}, 1)
+	other.Subscribe("job", nil, func(event Event) { got <- struct{}{} })
+	other.Publish(&DefaultEvent{Type: "job"})
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatal("Expected an independent bus to deliver")
+	}
+}
+
+func TestTopicLimitDropsOnlyThatTopic(t *testing.T) {
+	eb := NewEventBus()
+	eb.SetTopicLimit("robot.*", 1)
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan string, 4)
+	handler := func(event Event) {
+		started <- event.GetType()
+		<-release
+	}
+	eb.Subscribe("robot.a.status", nil, handler)
+	eb.Subscribe("robot.b.status", nil, handler)
+	eb.Subscribe("system.tick", nil, handler)
+
+	eb.Publish(&DefaultEvent{Type: "robot.a.status"})
+	if got := <-started; got != "robot.a.status" {
+		t.Fatalf("Expected robot.a.status, got %s", got)
+	}
+	// robot.b.status shares the robot.* budget, which is used up.
+	eb.Publish(&DefaultEvent{Type: "robot.b.status"})
+	eb.Publish(&DefaultEvent{Type: "system.tick"})
+	select {
+	case got := <-started:
+		if got != "system.tick" {
+			t.Fatalf("Expected only system.tick to be delivered, got %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a topic without a limit to still deliver")
+	}
+	select {
+	case got := <-started:
+		t.Fatalf("Expected %s to be dropped", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Removing the limit lets the topic through again.
+	eb.SetTopicLimit("robot.*", 0)
+	eb.Publish(&DefaultEvent{Type: "robot.b.status"})
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Expected robot.b.status once its limit was removed")
+	}
+}
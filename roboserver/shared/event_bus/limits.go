@@ -0,0 +1,113 @@
+package event_bus
+
+import (
+	"roboserver/shared"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// limits caps the handler goroutines one EventBus_t runs at once: in total
+// (SetLimit, default shared.EVENT_BUS_BUFFER_SIZE) and per topic
+// (SetTopicLimit). Publishers drop events rather than block once a budget
+// is used up, so a slow or stuck subscriber can never stall the publish
+// path (and therefore the server's network goroutines that call into it).
+// Every bus has its own budgets, so independent buses don't throttle each
+// other.
+type limits struct {
+	max      atomic.Int64 // bus-wide cap; 0 = shared.EVENT_BUS_BUFFER_SIZE
+	inFlight atomic.Int64 // handler goroutines running
+
+	mu       sync.RWMutex
+	exact    map[string]*topicLimit
+	prefixes []*topicLimit // longest prefix first
+}
+
+// topicLimit is the budget of one exact type or "prefix.*" pattern. Every
+// event type the pattern matches draws from the same budget.
+type topicLimit struct {
+	prefix   string // set for "prefix.*" patterns
+	max      int64
+	inFlight atomic.Int64
+}
+
+func (l *limits) limit() int64 {
+	if n := l.max.Load(); n > 0 {
+		return n
+	}
+	return int64(shared.EVENT_BUS_BUFFER_SIZE)
+}
+
+// setTopic sets the budget of an exact type or "prefix.*" pattern; limit
+// <= 0 removes it. Handlers already running keep counting against the
+// budget they started under.
+func (l *limits) setTopic(pattern string, limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		kept := l.prefixes[:0]
+		for _, t := range l.prefixes {
+			if t.prefix != prefix {
+				kept = append(kept, t)
+			}
+		}
+		l.prefixes = kept
+		if limit > 0 {
+			l.prefixes = append(l.prefixes, &topicLimit{prefix: prefix, max: int64(limit)})
+			sort.SliceStable(l.prefixes, func(i, j int) bool { return len(l.prefixes[i].prefix) > len(l.prefixes[j].prefix) })
+		}
+		return
+	}
+
+	if limit <= 0 {
+		delete(l.exact, pattern)
+		return
+	}
+	if l.exact == nil {
+		l.exact = make(map[string]*topicLimit)
+	}
+	l.exact[pattern] = &topicLimit{max: int64(limit)}
+}
+
+// topic returns the budget that applies to eventType: its exact entry,
+// else the longest matching prefix, else nil.
+func (l *limits) topic(eventType string) *topicLimit {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if t, ok := l.exact[eventType]; ok {
+		return t
+	}
+	for _, t := range l.prefixes {
+		if strings.HasPrefix(eventType, t.prefix) {
+			return t
+		}
+	}
+	return nil
+}
+
+// acquire takes a slot for one handler goroutine of eventType from both
+// the bus-wide and the topic budget. It returns false, taking nothing, if
+// either is used up. A successful acquire is undone with release(t).
+func (l *limits) acquire(eventType string) (t *topicLimit, ok bool) {
+	if l.inFlight.Add(1) > l.limit() {
+		l.inFlight.Add(-1)
+		return nil, false
+	}
+	t = l.topic(eventType)
+	if t != nil && t.inFlight.Add(1) > t.max {
+		t.inFlight.Add(-1)
+		l.inFlight.Add(-1)
+		return nil, false
+	}
+	return t, true
+}
+
+func (l *limits) release(t *topicLimit) {
+	if t != nil {
+		t.inFlight.Add(-1)
+	}
+	l.inFlight.Add(-1)
+}
@@ -0,0 +1,114 @@
+package event_bus
+
+import (
+	"context"
+	"roboserver/shared"
+	"roboserver/shared/tracing"
+	"sync"
+	"time"
+)
+
+// PublishFunc hands an event to the bus for delivery.
+type PublishFunc func(event Event)
+
+// PublishMiddleware wraps Publish, like HTTP middleware wraps a handler. It
+// sees every event before taps and subscribers do, and may log it, replace
+// it (enrichment) or drop it by not calling next.
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// HandlerMiddleware wraps every subscriber handler call, on the goroutine
+// that runs the handler.
+type HandlerMiddleware func(next SubscriberHandler) SubscriberHandler
+
+// middlewareChain holds the middleware registered with UsePublish and
+// UseHandler. The first registered is the outermost.
+type middlewareChain struct {
+	mu      sync.RWMutex
+	publish []PublishMiddleware
+	handler []HandlerMiddleware
+}
+
+func (c *middlewareChain) wrapPublish(final PublishFunc) PublishFunc {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.publish) - 1; i >= 0; i-- {
+		final = c.publish[i](final)
+	}
+	return final
+}
+
+func (c *middlewareChain) wrapHandler(handler SubscriberHandler) SubscriberHandler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.handler) - 1; i >= 0; i-- {
+		handler = c.handler[i](handler)
+	}
+	return handler
+}
+
+// UsePublish appends publish middleware. It applies to events published
+// after the call.
+func (eb *EventBus_t) UsePublish(mw ...PublishMiddleware) {
+	eb.middleware.mu.Lock()
+	defer eb.middleware.mu.Unlock()
+	eb.middleware.publish = append(eb.middleware.publish, mw...)
+}
+
+// UseHandler appends handler middleware. It applies to every delivery
+// after the call, including to existing subscribers.
+func (eb *EventBus_t) UseHandler(mw ...HandlerMiddleware) {
+	eb.middleware.mu.Lock()
+	defer eb.middleware.mu.Unlock()
+	eb.middleware.handler = append(eb.middleware.handler, mw...)
+}
+
+// FilterEvents drops events for which keep returns false.
+func FilterEvents(keep func(Event) bool) PublishMiddleware {
+	return func(next PublishFunc) PublishFunc {
+		return func(event Event) {
+			if keep(event) {
+				next(event)
+			}
+		}
+	}
+}
+
+// TimeHandlers reports how long each handler call took.
+func TimeHandlers(report func(eventType string, took time.Duration)) HandlerMiddleware {
+	return func(next SubscriberHandler) SubscriberHandler {
+		return func(event Event) {
+			start := time.Now()
+			defer func() { report(event.GetType(), time.Since(start)) }()
+			next(event)
+		}
+	}
+}
+
+// LogSlowHandlers logs handler calls that take longer than threshold.
+func LogSlowHandlers(threshold time.Duration) HandlerMiddleware {
+	return TimeHandlers(func(eventType string, took time.Duration) {
+		if took > threshold {
+			shared.DebugPrint("Slow event handler: %s took %v", eventType, took)
+		}
+	})
+}
+
+// TraceHandlers records a consumer span around each handler call for an
+// event published as part of a trace, as a child of the publishing span.
+// Events without a trace are handled untraced.
+func TraceHandlers() HandlerMiddleware {
+	return func(next SubscriberHandler) SubscriberHandler {
+		return func(event Event) {
+			tp := TraceParentOf(event)
+			if tp == "" {
+				next(event)
+				return
+			}
+			_, span := tracing.StartSpan(tracing.Extract(context.Background(), tp), tracing.KindConsumer, "bus.handle")
+			span.SetAttr("event.type", event.GetType())
+			span.SetAttr("event.remote", IsRemote(event))
+			defer span.End()
+			next(event)
+		}
+	}
+}
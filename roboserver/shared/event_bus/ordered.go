@@ -0,0 +1,125 @@
+package event_bus
+
+import (
+	"roboserver/shared"
+	"strings"
+	"sync"
+	"time"
+)
+
+// orderedTypes holds event types declared ordered via SetOrdered. Patterns
+// ending in ".*" match any event type with that prefix (e.g. "robot.*").
+type orderedTypes struct {
+	mu       sync.RWMutex
+	exact    map[string]bool
+	prefixes []string
+}
+
+func (o *orderedTypes) set(pattern string, ordered bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		kept := o.prefixes[:0]
+		for _, p := range o.prefixes {
+			if p != prefix {
+				kept = append(kept, p)
+			}
+		}
+		o.prefixes = kept
+		if ordered {
+			o.prefixes = append(o.prefixes, prefix)
+		}
+		return
+	}
+
+	if o.exact == nil {
+		o.exact = make(map[string]bool)
+	}
+	if ordered {
+		o.exact[pattern] = true
+	} else {
+		delete(o.exact, pattern)
+	}
+}
+
+func (o *orderedTypes) matches(eventType string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.exact[eventType] {
+		return true
+	}
+	for _, p := range o.prefixes {
+		if strings.HasPrefix(eventType, p) {
+			return true
+		}
+	}
+	return false
+}
+
+type queuedEvent struct {
+	handler SubscriberHandler
+	event   Event
+}
+
+// subscriberQueue delivers a subscriber's ordered events one at a time, in
+// publish order. The worker goroutine exits when the queue drains and is
+// restarted by the next enqueue, so idle subscribers cost nothing.
+type subscriberQueue struct {
+	mu      sync.Mutex
+	pending []queuedEvent
+	running bool
+}
+
+// enqueue appends an event, dropping it if the subscriber is already limit
+// events behind (the bus's SetLimit, same backpressure as unordered
+// delivery).
+func (q *subscriberQueue) enqueue(handler SubscriberHandler, event Event, limit int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if int64(len(q.pending)) >= limit {
+		return false
+	}
+	q.pending = append(q.pending, queuedEvent{handler: handler, event: event})
+	if !q.running {
+		q.running = true
+		go q.drain()
+	}
+	return true
+}
+
+func (q *subscriberQueue) drain() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.pending = nil
+			q.mu.Unlock()
+			return
+		}
+		next := q.pending[0]
+		q.pending[0] = queuedEvent{}
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		deliver(next.handler, next.event)
+	}
+}
+
+// deliver runs a handler, containing panics so one bad subscriber can't take
+// down the worker (or, for unordered events, the process). An event that
+// expired while it waited for a worker is dropped.
+func deliver(handler SubscriberHandler, event Event) {
+	if Expired(event, time.Now()) {
+		shared.DebugPrint("Event %s expired before delivery, dropping", event.GetType())
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			shared.DebugPrint("Event handler panic on %s: %v", event.GetType(), r)
+		}
+	}()
+	handler(event)
+}
@@ -0,0 +1,42 @@
+package event_bus
+
+import "sync"
+
+// tapSet holds handlers that see every published event regardless of type,
+// e.g. an exporter forwarding "robot.*" to an external broker.
+type tapSet struct {
+	mu     sync.RWMutex
+	nextID uint64
+	taps   map[uint64]SubscriberHandler
+}
+
+func (t *tapSet) add(handler SubscriberHandler) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.taps == nil {
+		t.taps = make(map[uint64]SubscriberHandler)
+	}
+	t.nextID++
+	id := t.nextID
+	t.taps[id] = handler
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.taps, id)
+	}
+}
+
+func (t *tapSet) deliver(event Event) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, handler := range t.taps {
+		handler(event)
+	}
+}
+
+// Tap registers a handler called synchronously on the publisher's goroutine
+// for every event. It must not block; hand the event to a buffered channel.
+// Returns a function that removes the tap.
+func (eb *EventBus_t) Tap(handler SubscriberHandler) (cancel func()) {
+	return eb.taps.add(handler)
+}
@@ -1,12 +1,20 @@
 package event_bus
 
-import "roboserver/shared/data_structures"
+import (
+	"roboserver/shared/data_structures"
+	"time"
+)
 
 // If an event has 0 subscribers, it is removed from the EventBus.
 // Publishing to an event with no subscribers is a no-op.
 type EventBus_t struct {
 	subscriptions *data_structures.SafeMap[string, *data_structures.SafeSet[Subscriber]]                    // event type -> subscribers
 	handlers      *data_structures.SafeMap[Subscriber, *data_structures.SafeMap[string, SubscriberHandler]] // Subscriber -> event -> handler function
+	ordered       orderedTypes                                                                              // event types delivered in order
+	queues        *data_structures.SafeMap[Subscriber, *subscriberQueue]                                    // Subscriber -> FIFO for ordered events
+	taps          tapSet                                                                                    // handlers that see every event
+	middleware    middlewareChain                                                                           // UsePublish / UseHandler
+	limits        limits                                                                                    // concurrent handler budgets (SetLimit / SetTopicLimit)
 }
 
 type Subscriber struct {
@@ -25,4 +33,10 @@ type Event interface {
 type DefaultEvent struct {
 	Type string
 	Data interface{}
+	// TraceParent is the W3C traceparent of the span that published the
+	// event, if it was published as part of a trace (see TraceHandlers).
+	TraceParent string
+	// ExpiresAt, if set, is when the event goes stale. From then on it is
+	// dropped instead of delivered, wherever it is still queued.
+	ExpiresAt time.Time
 }
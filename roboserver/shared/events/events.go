@@ -0,0 +1,370 @@
+// Package events names the built-in event bus topics. Producers and
+// consumers build topics through these helpers instead of formatting
+// strings themselves, so a typo or a field containing a dot can't silently
+// split one topic into two.
+//
+// Robot-scoped topics are "<namespace>.<uuid>.<kind>". UUIDs are validated
+// to [a-zA-Z0-9_-] at registration, so the uuid is always exactly one
+// segment (see GET /robot/{uuid}/events, which relies on that).
+package events
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Fixed event types.
+const (
+	RobotRegistering = "robot.registering"
+	PresenceEnter    = "presence.enter"
+	PresenceLeave    = "presence.leave"
+	MQTTToRobot      = "mqtt.to_robot"
+	// HandlerEvent is used when a handler publishes without a method name.
+	HandlerEvent = "handler_event"
+	// ClusterLeader announces leadership changes in a cluster.
+	ClusterLeader = "cluster.leader"
+	// IPConflict reports a robot starting a session from an IP address
+	// another active robot holds (payload ipconflict.Conflict).
+	IPConflict = "robot.ip_conflict"
+	// RobotKicked tells every node to drop a robot's connection and stop
+	// its handler (payload Kick).
+	RobotKicked = "robot.kicked"
+	// JobUpdated carries a background job whose status changed (jobs.Job).
+	JobUpdated = "job.updated"
+	// PushPrefsChanged announces that a user's notification preferences
+	// changed (payload {"username": ...}), so push gateways reload them.
+	PushPrefsChanged = "push.prefs_changed"
+	// RobotDiscovered reports a device found by a network scan that was not
+	// listed before (payload discovery.Candidate).
+	RobotDiscovered = "robot.discovered"
+	// RobotOnboarded reports a robot that registered with an onboarding
+	// code and was set up from its plan (payload Onboarded).
+	RobotOnboarded = "robot.onboarded"
+	// RobotAutoAccepted reports a registration accepted by an auto_accept
+	// policy instead of an operator (payload autoaccept.Accepted).
+	RobotAutoAccepted = "robot.auto_accepted"
+	// RobotVirtualCreated reports a virtual robot created for a device a
+	// gateway reported through POST /ingest (payload ingest.VirtualCreated).
+	RobotVirtualCreated = "robot.virtual_created"
+)
+
+// Namespaces and kinds of robot-scoped event types.
+const (
+	robotNamespace   = "robot"
+	handlerNamespace = "handler"
+	webrtcNamespace  = "webrtc"
+	zoneNamespace    = "zone"
+	mqttNamespace    = "mqtt.message"
+)
+
+func join(segments ...string) string {
+	return strings.Join(segments, ".")
+}
+
+// RobotHeartbeat carries verified heartbeats for a robot.
+func RobotHeartbeat(uuid string) string { return join(robotNamespace, uuid, "heartbeat") }
+
+// RobotLatency carries PING/PONG round-trip samples for a robot.
+func RobotLatency(uuid string) string { return join(robotNamespace, uuid, "latency") }
+
+// RobotPing asks the node holding a robot's TCP session to PING it now
+// (a comms.Request; the reply is a PingResult).
+func RobotPing(uuid string) string { return join(robotNamespace, uuid, "ping") }
+
+// IsRobotPing reports whether eventType is a RobotPing topic.
+func IsRobotPing(eventType string) bool {
+	_, kind, ok := ParseRobotTopic(eventType)
+	return ok && kind == "ping"
+}
+
+// RobotStatus carries robot_status transitions for a robot.
+func RobotStatus(uuid string) string { return join(robotNamespace, uuid, "status") }
+
+// RobotMaintenance announces a robot entering or leaving maintenance mode
+// (payload maintenance.Event).
+func RobotMaintenance(uuid string) string { return join(robotNamespace, uuid, "maintenance") }
+
+// IsRobotMaintenance reports whether eventType is a RobotMaintenance topic.
+func IsRobotMaintenance(eventType string) bool {
+	return strings.HasPrefix(eventType, robotNamespace+".") && strings.HasSuffix(eventType, ".maintenance")
+}
+
+// IsRobotRecord reports whether eventType is a RobotRecord topic.
+func IsRobotRecord(eventType string) bool {
+	return strings.HasPrefix(eventType, robotNamespace+".") && strings.HasSuffix(eventType, ".record")
+}
+
+// RobotChanged carries the fields of a robot's state that changed (payload
+// statediff.Change).
+func RobotChanged(uuid string) string { return join(robotNamespace, uuid, "changed") }
+
+// RobotShadow announces a change to a robot's shadow state (payload
+// shadow.Event).
+func RobotShadow(uuid string) string { return join(robotNamespace, uuid, "shadow") }
+
+// IsRobotShadow reports whether eventType is a RobotShadow topic.
+func IsRobotShadow(eventType string) bool {
+	return strings.HasPrefix(eventType, robotNamespace+".") && strings.HasSuffix(eventType, ".shadow")
+}
+
+// RobotRecord announces a change to a robot's registry record in
+// PostgreSQL (payload RecordChange), so caches of it can drop the record.
+func RobotRecord(uuid string) string { return join(robotNamespace, uuid, "record") }
+
+// RobotLabels announces a change to a robot's name, tags or zone (payload
+// LabelsChanged).
+func RobotLabels(uuid string) string { return join(robotNamespace, uuid, "labels") }
+
+// ParseRobotTopic splits a robot-scoped topic "robot.<uuid>.<kind>" into
+// its uuid and kind.
+func ParseRobotTopic(eventType string) (uuid, kind string, ok bool) {
+	rest, ok := strings.CutPrefix(eventType, robotNamespace+".")
+	if !ok {
+		return "", "", false
+	}
+	uuid, kind, ok = strings.Cut(rest, ".")
+	if !ok || uuid == "" || kind == "" || strings.Contains(kind, ".") {
+		return "", "", false
+	}
+	return uuid, kind, true
+}
+
+// RobotOf returns the robot a robot- or handler-scoped topic
+// ("robot.<uuid>.<...>" or "handler.<uuid>.<...>") is about, for access
+// checks on subscriptions. Namespace-wide types such as
+// "robot.virtual_created" are not scoped to a robot.
+func RobotOf(eventType string) (string, bool) {
+	for _, ns := range []string{robotNamespace, handlerNamespace} {
+		rest, ok := strings.CutPrefix(eventType, ns+".")
+		if !ok {
+			continue
+		}
+		uuid, kind, ok := strings.Cut(rest, ".")
+		return uuid, ok && uuid != "" && kind != ""
+	}
+	return "", false
+}
+
+// RobotTelemetry carries a robot's DATA reports (payload telemetry.Envelope).
+func RobotTelemetry(uuid string) string { return join(robotNamespace, uuid, "telemetry") }
+
+// RobotInterval announces a reporting interval pushed to a robot (payload
+// adaptive.Adjustment).
+func RobotInterval(uuid string) string { return join(robotNamespace, uuid, "interval") }
+
+// HandlerLog carries a handler's stdout/stderr lines.
+func HandlerLog(uuid string) string { return join(handlerNamespace, uuid, "log") }
+
+// HandlerRestart announces a retried handler start.
+func HandlerRestart(uuid string) string { return join(handlerNamespace, uuid, "restart") }
+
+// HandlerFailed announces that a handler could not be started at all.
+func HandlerFailed(uuid string) string { return join(handlerNamespace, uuid, "failed") }
+
+// HandlerQueueFull announces a handler whose stdin queue has stayed full
+// for handlers.queue_full_alert.
+func HandlerQueueFull(uuid string) string { return join(handlerNamespace, uuid, "queue_full") }
+
+// HandlerMessage is delivered to a robot's handler as an event message.
+func HandlerMessage(uuid string) string { return join(handlerNamespace, uuid, "message") }
+
+// HandlerIncoming carries API messages routed to a handler running on
+// another cluster node (payload ForwardedMessage).
+func HandlerIncoming(uuid string) string { return join(handlerNamespace, uuid, "incoming") }
+
+// IsHandlerIncoming reports whether eventType is a HandlerIncoming topic.
+func IsHandlerIncoming(eventType string) bool {
+	return strings.HasPrefix(eventType, handlerNamespace+".") && strings.HasSuffix(eventType, ".incoming")
+}
+
+// WebRTCSignal carries robot signaling messages of one kind for a session.
+func WebRTCSignal(session, kind string) string { return join(webrtcNamespace, session, kind) }
+
+// ZoneAggregate carries the new value of a zone aggregate (payload
+// zones.Value). Zone and aggregate names are single segments.
+func ZoneAggregate(zone, name string) string { return join(zoneNamespace, zone, name) }
+
+// MQTTMessage carries payloads robots publish on robomesh/message/{name}.
+func MQTTMessage(name string) string { return join(mqttNamespace, name) }
+
+// Registration is the payload of RobotRegistering.
+type Registration struct {
+	DeviceID  string `json:"device_id"`
+	IP        string `json:"ip"`
+	RobotType string `json:"robot_type"`
+}
+
+// Register returns the event type and payload announcing a pending
+// registration, for use as bus.PublishEvent(events.Register(...)). The
+// payload is a JSON string, as frontends have always received it.
+func Register(deviceID, ip, deviceType string) (string, any) {
+	data, _ := json.Marshal(Registration{DeviceID: deviceID, IP: ip, RobotType: deviceType})
+	return RobotRegistering, string(data)
+}
+
+// ForwardedMessage is the payload of HandlerIncoming: a message for the
+// handler's stdin and the verified user it came from, if any.
+type ForwardedMessage struct {
+	Message string `json:"message"`
+	Actor   string `json:"actor,omitempty"`
+	LockMS  int64  `json:"lock_ms,omitempty"` // command lock requested by the API caller
+	// TraceParent continues the API request's trace on the receiving node.
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+// RecordChange is the payload of RobotRecord.
+type RecordChange struct {
+	UUID string `json:"uuid"`
+	// Change is "registered", "blacklist", "restored", or for writes made
+	// outside the server, "updated" or "deleted".
+	Change      string `json:"change"`
+	Blacklisted bool   `json:"blacklisted,omitempty"` // the flag after the change
+	// Source is "database" for a change picked up from PostgreSQL
+	// notifications rather than made through the server.
+	Source string `json:"source,omitempty"`
+}
+
+// LabelsChanged is the payload of RobotLabels: the robot's labels after
+// the change.
+type LabelsChanged struct {
+	UUID string   `json:"uuid"`
+	Name string   `json:"name,omitempty"`
+	Zone string   `json:"zone,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// PingResult answers a RobotPing request.
+type PingResult struct {
+	UUID  string  `json:"uuid"`
+	RTTMs float64 `json:"rtt_ms"`
+	Node  string  `json:"node,omitempty"` // cluster node holding the session
+}
+
+// Onboarded is the payload of RobotOnboarded.
+type Onboarded struct {
+	Code       string `json:"code"`
+	UUID       string `json:"uuid"`
+	DeviceType string `json:"device_type"`
+	Name       string `json:"name"`
+	Zone       string `json:"zone,omitempty"`
+	By         string `json:"by"` // who created the code
+	Error      string `json:"error,omitempty"`
+}
+
+// Kick is the payload of RobotKicked.
+type Kick struct {
+	UUID   string `json:"uuid"`
+	Reason string `json:"reason,omitempty"`
+	By     string `json:"by,omitempty"`
+}
+
+// DecodeKick reads a Kick published locally or relayed from another node.
+func DecodeKick(data any) (Kick, bool) {
+	switch v := data.(type) {
+	case Kick:
+		return v, v.UUID != ""
+	case map[string]any:
+		uuid, _ := v["uuid"].(string)
+		reason, _ := v["reason"].(string)
+		by, _ := v["by"].(string)
+		return Kick{UUID: uuid, Reason: reason, By: by}, uuid != ""
+	}
+	return Kick{}, false
+}
+
+// DecodeRecordChange reads a RecordChange published locally or relayed from
+// another node (where it arrives as decoded JSON).
+func DecodeRecordChange(data any) (RecordChange, bool) {
+	switch v := data.(type) {
+	case RecordChange:
+		return v, v.UUID != ""
+	case map[string]any:
+		uuid, _ := v["uuid"].(string)
+		change, _ := v["change"].(string)
+		blacklisted, _ := v["blacklisted"].(bool)
+		source, _ := v["source"].(string)
+		return RecordChange{UUID: uuid, Change: change, Blacklisted: blacklisted, Source: source}, uuid != ""
+	}
+	return RecordChange{}, false
+}
+
+// DecodeLabelsChanged reads a LabelsChanged published locally or relayed
+// from another node.
+func DecodeLabelsChanged(data any) (LabelsChanged, bool) {
+	switch v := data.(type) {
+	case LabelsChanged:
+		return v, v.UUID != ""
+	case map[string]any:
+		uuid, _ := v["uuid"].(string)
+		name, _ := v["name"].(string)
+		zone, _ := v["zone"].(string)
+		var tags []string
+		if raw, ok := v["tags"].([]any); ok {
+			for _, t := range raw {
+				if s, ok := t.(string); ok {
+					tags = append(tags, s)
+				}
+			}
+		}
+		return LabelsChanged{UUID: uuid, Name: name, Zone: zone, Tags: tags}, uuid != ""
+	}
+	return LabelsChanged{}, false
+}
+
+// DecodePingResult reads a PingResult published locally or relayed from
+// another node (where it arrives as decoded JSON).
+func DecodePingResult(data any) (PingResult, bool) {
+	switch v := data.(type) {
+	case PingResult:
+		return v, true
+	case map[string]any:
+		uuid, _ := v["uuid"].(string)
+		rtt, ok := v["rtt_ms"].(float64)
+		node, _ := v["node"].(string)
+		return PingResult{UUID: uuid, RTTMs: rtt, Node: node}, ok
+	}
+	return PingResult{}, false
+}
+
+// DecodeForwardedMessage reads a ForwardedMessage published locally or
+// relayed from another node (where it arrives as decoded JSON).
+func DecodeForwardedMessage(data any) (ForwardedMessage, bool) {
+	switch v := data.(type) {
+	case ForwardedMessage:
+		return v, true
+	case *ForwardedMessage:
+		return *v, v != nil
+	case map[string]any:
+		msg, ok := v["message"].(string)
+		actor, _ := v["actor"].(string)
+		lock, _ := v["lock_ms"].(float64)
+		tp, _ := v["traceparent"].(string)
+		return ForwardedMessage{Message: msg, Actor: actor, LockMS: int64(lock), TraceParent: tp}, ok
+	}
+	return ForwardedMessage{}, false
+}
+
+// Matcher returns a func reporting whether an event type matches any of
+// patterns: exact types, or "prefix.*" ("*" alone matches everything).
+func Matcher(patterns []string) func(eventType string) bool {
+	exact := make(map[string]bool)
+	var prefixes []string
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			prefixes = append(prefixes, prefix)
+		} else {
+			exact[p] = true
+		}
+	}
+	return func(eventType string) bool {
+		if exact[eventType] {
+			return true
+		}
+		for _, p := range prefixes {
+			if strings.HasPrefix(eventType, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
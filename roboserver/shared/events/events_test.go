@@ -0,0 +1,227 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTopics(t *testing.T) {
+	tests := map[string]string{
+		RobotHeartbeat("lamp-1"):      "robot.lamp-1.heartbeat",
+		RobotLatency("lamp-1"):        "robot.lamp-1.latency",
+		RobotStatus("lamp-1"):         "robot.lamp-1.status",
+		RobotInterval("lamp-1"):       "robot.lamp-1.interval",
+		HandlerLog("lamp-1"):          "handler.lamp-1.log",
+		HandlerMessage("lamp-1"):      "handler.lamp-1.message",
+		HandlerIncoming("lamp-1"):     "handler.lamp-1.incoming",
+		WebRTCSignal("abc", "answer"): "webrtc.abc.answer",
+		MQTTMessage("telemetry"):      "mqtt.message.telemetry",
+	}
+	for got, want := range tests {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	eventType, data := Register("lamp-1", "10.0.0.5", "lamp")
+	if eventType != RobotRegistering {
+		t.Errorf("Expected %s, got %s", RobotRegistering, eventType)
+	}
+	s, ok := data.(string)
+	if !ok {
+		t.Fatalf("Expected JSON string payload, got %T", data)
+	}
+	var reg Registration
+	if err := json.Unmarshal([]byte(s), &reg); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if reg != (Registration{DeviceID: "lamp-1", IP: "10.0.0.5", RobotType: "lamp"}) {
+		t.Errorf("Unexpected payload: %+v", reg)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	match := Matcher([]string{"robot.*", "presence.enter"})
+	for eventType, want := range map[string]bool{
+		"robot.r1.status": true,
+		"presence.enter":  true,
+		"presence.leave":  false,
+		"handler.r1.log":  false,
+	} {
+		if got := match(eventType); got != want {
+			t.Errorf("match(%q) = %v, want %v", eventType, got, want)
+		}
+	}
+	if !Matcher([]string{"*"})("anything") {
+		t.Error(`Expected "*" to match everything`)
+	}
+}
+
+func TestDecodeForwardedMessage(t *testing.T) {
+	want := ForwardedMessage{Message: "unlock", Actor: "alice"}
+	if got, ok := DecodeForwardedMessage(want); !ok || got != want {
+		t.Errorf("Expected local payload to decode, got %+v", got)
+	}
+	// Relayed from another node, the payload arrives as decoded JSON.
+	var relayed any
+	raw, _ := json.Marshal(want)
+	json.Unmarshal(raw, &relayed)
+	if got, ok := DecodeForwardedMessage(relayed); !ok || got != want {
+		t.Errorf("Expected relayed payload to decode, got %+v", got)
+	}
+	if _, ok := DecodeForwardedMessage(map[string]any{"actor": "alice"}); ok {
+		t.Error("Expected a payload without a message to be rejected")
+	}
+}
+
+func TestIsHandlerIncoming(t *testing.T) {
+	if !IsHandlerIncoming(HandlerIncoming("r1")) || IsHandlerIncoming(HandlerLog("r1")) {
+		t.Error("IsHandlerIncoming misclassified a topic")
+	}
+}
+
+func TestParseRobotTopic(t *testing.T) {
+	uuid, kind, ok := ParseRobotTopic(RobotTelemetry("lamp-1"))
+	if !ok || uuid != "lamp-1" || kind != "telemetry" {
+		t.Errorf("got %q %q %v", uuid, kind, ok)
+	}
+	for _, bad := range []string{HandlerLog("lamp-1"), "robot.lamp-1", "robot..status", "robot.lamp-1.a.b"} {
+		if _, _, ok := ParseRobotTopic(bad); ok {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRobotOf(t *testing.T) {
+	for topic, want := range map[string]string{
+		RobotTelemetry("lamp-1"):          "lamp-1",
+		RobotPing("lamp-1") + ".reply.ab": "lamp-1",
+		HandlerLog("lamp-1"):              "lamp-1",
+	} {
+		if got, ok := RobotOf(topic); !ok || got != want {
+			t.Errorf("%s: got %q %v", topic, got, ok)
+		}
+	}
+	for _, topic := range []string{RobotVirtualCreated, "robot..status", "job.updated", ZoneAggregate("lab", "motion")} {
+		if uuid, ok := RobotOf(topic); ok {
+			t.Errorf("Expected %q not robot-scoped, got %q", topic, uuid)
+		}
+	}
+}
+
+func TestDecodePingResult(t *testing.T) {
+	if !IsRobotPing(RobotPing("r1")) || IsRobotPing(RobotPing("r1")+".reply.abc") {
+		t.Error("Expected only the ping request topic to match")
+	}
+	got, ok := DecodePingResult(map[string]any{"uuid": "r1", "rtt_ms": 12.5, "node": "n1"})
+	if !ok || got != (PingResult{UUID: "r1", RTTMs: 12.5, Node: "n1"}) {
+		t.Errorf("got %+v %v", got, ok)
+	}
+	if _, ok := DecodePingResult(map[string]any{"uuid": "r1"}); ok {
+		t.Error("Expected a result without rtt_ms to be rejected")
+	}
+}
+
+func TestDecodeLabelsChanged(t *testing.T) {
+	got, ok := DecodeLabelsChanged(map[string]any{"uuid": "lamp-1", "zone": "hall", "tags": []any{"a", "b"}})
+	if !ok || got.Zone != "hall" || len(got.Tags) != 2 {
+		t.Errorf("got %+v %v", got, ok)
+	}
+	if _, ok := DecodeLabelsChanged(map[string]any{"zone": "hall"}); ok {
+		t.Error("Expected labels without uuid to be rejected")
+	}
+}
+
+func TestDecodeKick(t *testing.T) {
+	want := Kick{UUID: "r1", Reason: "misbehaving", By: "admin"}
+	if got, ok := DecodeKick(want); !ok || got != want {
+		t.Errorf("Expected local payload to decode, got %+v", got)
+	}
+	var relayed any
+	raw, _ := json.Marshal(want)
+	json.Unmarshal(raw, &relayed)
+	if got, ok := DecodeKick(relayed); !ok || got != want {
+		t.Errorf("Expected relayed payload to decode, got %+v", got)
+	}
+	if _, ok := DecodeKick(map[string]any{"reason": "x"}); ok {
+		t.Error("Expected a payload without a uuid to be rejected")
+	}
+}
+
+func TestDecodeRecordChange(t *testing.T) {
+	want := RecordChange{UUID: "r1", Change: "blacklist", Blacklisted: true, Source: "database"}
+	if got, ok := DecodeRecordChange(want); !ok || got != want {
+		t.Errorf("Expected local payload to decode, got %+v", got)
+	}
+	var relayed any
+	raw, _ := json.Marshal(want)
+	json.Unmarshal(raw, &relayed)
+	if got, ok := DecodeRecordChange(relayed); !ok || got != want {
+		t.Errorf("Expected relayed payload to decode, got %+v", got)
+	}
+}
+
+type schemaBase struct {
+	ID string `json:"id"`
+}
+
+type schemaSample struct {
+	schemaBase
+	Name     string             `json:"name"`
+	Count    int                `json:"count,omitempty"`
+	Tags     []string           `json:"tags"`
+	Metrics  map[string]float64 `json:"metrics"`
+	Raw      json.RawMessage    `json:"raw,omitempty"`
+	Next     *schemaSample      `json:"next,omitempty"`
+	Untagged bool
+	Hidden   string `json:"-"`
+	private  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf(&schemaSample{})
+	if s.Type != "object" {
+		t.Fatalf("Expected an object, got %+v", s)
+	}
+	want := map[string]string{"id": "string", "name": "string", "count": "integer", "tags": "array", "metrics": "object", "raw": "", "next": "", "Untagged": "boolean"}
+	if len(s.Properties) != len(want) {
+		t.Errorf("Expected properties %v, got %v", want, s.Properties)
+	}
+	for name, typ := range want {
+		if p, ok := s.Properties[name]; !ok || p.Type != typ {
+			t.Errorf("Property %s: expected type %q, got %+v", name, typ, p)
+		}
+	}
+	if s.Properties["tags"].Items.Type != "string" || s.Properties["metrics"].AdditionalProperties.Type != "number" {
+		t.Errorf("Expected element schemas, got %+v %+v", s.Properties["tags"], s.Properties["metrics"])
+	}
+	required := strings.Join(s.Required, ",")
+	if required != "id,name,tags,metrics,Untagged" {
+		t.Errorf("Unexpected required fields %s", required)
+	}
+}
+
+func TestTypesDescribed(t *testing.T) {
+	types := Types()
+	seen := map[string]bool{}
+	for _, doc := range types {
+		seen[doc.Type] = true
+		if doc.Description == "" || doc.Schema == nil {
+			t.Errorf("Type %s is missing documentation: %+v", doc.Type, doc)
+		}
+	}
+	for _, typ := range []string{RobotRegistering, RobotKicked, HandlerIncoming("{uuid}")} {
+		if !seen[typ] {
+			t.Errorf("Expected %s to be described", typ)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected describing a type twice to panic")
+		}
+	}()
+	Describe(Info{Type: RobotKicked})
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Info documents an event type for GET /events/types. Packages describe the
+// events they publish from init, next to the payload type.
+type Info struct {
+	// Type is the event type, with {placeholders} for variable segments
+	// (e.g. RobotTelemetry("{uuid}")), or a "prefix.*" pattern.
+	Type        string
+	Description string
+	// Example is a representative payload; its Go type gives the schema.
+	Example any
+}
+
+// TypeDoc is the documentation of one event type as served to clients.
+type TypeDoc struct {
+	Type        string  `json:"type"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+	Example     any     `json:"example"`
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = map[string]Info{}
+)
+
+// Describe registers the documentation of an event type. Describing a type
+// twice panics, as two packages claiming one topic is a bug.
+func Describe(info Info) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	if _, ok := types[info.Type]; ok {
+		panic(fmt.Sprintf("events: %s described twice", info.Type))
+	}
+	types[info.Type] = info
+}
+
+// Types returns every described event type, sorted by type.
+func Types() []TypeDoc {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	out := make([]TypeDoc, 0, len(types))
+	for _, info := range types {
+		out = append(out, TypeDoc{
+			Type:        info.Type,
+			Description: info.Description,
+			Schema:      SchemaOf(info.Example),
+			Example:     info.Example,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+func init() {
+	Describe(Info{
+		Type:        RobotRegistering,
+		Description: "A robot asked to register and awaits approval. The payload is a JSON-encoded string of {device_id, ip, robot_type}.",
+		Example:     payloadOf(Register("rover-7", "10.0.0.5", "rover")),
+	})
+	Describe(Info{
+		Type:        RobotRecord("{uuid}"),
+		Description: "A robot's registry record was created (registered), its blacklist flag changed (blacklist), a backup overwrote it (restored), or a write outside the server updated or deleted it (updated, deleted; source database, with database.postgres.watch_changes). Every node stops the handler of a deleted or blacklisted robot.",
+		Example:     RecordChange{UUID: "rover-7", Change: "blacklist", Blacklisted: true},
+	})
+	Describe(Info{
+		Type:        RobotLabels("{uuid}"),
+		Description: "A robot's name, tags or zone changed; the payload holds the labels after the change.",
+		Example:     LabelsChanged{UUID: "lamp-12", Name: "Dock 2 lamp", Zone: "warehouse-a", Tags: []string{"floor-2"}},
+	})
+	Describe(Info{
+		Type:        RobotKicked,
+		Description: "An operator dropped a robot's connection; every node stops its handler.",
+		Example:     Kick{UUID: "rover-7", Reason: "firmware update", By: "admin"},
+	})
+	Describe(Info{
+		Type:        RobotOnboarded,
+		Description: "A robot registered with an onboarding code and was named, labelled and given its desired state in the same step. error is set if part of that setup failed.",
+		Example:     Onboarded{Code: "K7QM2XW9PA", UUID: "lamp-12", DeviceType: "lamp", Name: "Dock 2 lamp", Zone: "warehouse-a", By: "admin"},
+	})
+	Describe(Info{
+		Type:        HandlerIncoming("{uuid}"),
+		Description: "An API message for a handler running on another cluster node.",
+		Example:     ForwardedMessage{Message: `{"cmd":"dock"}`, Actor: "alice"},
+	})
+	Describe(Info{
+		Type:        HandlerMessage("{uuid}"),
+		Description: "Any payload published here is delivered to the robot's handler as an event message.",
+		Example:     map[string]any{"cmd": "dock"},
+	})
+	Describe(Info{
+		Type:        HandlerEvent,
+		Description: "Published by a handler that emits an event without a method name; the payload is the handler's.",
+		Example:     map[string]any{"door": "open"},
+	})
+	Describe(Info{
+		Type:        MQTTToRobot,
+		Description: "Sends payload to the MQTT robot uuid on robomesh/to_robot/{uuid}.",
+		Example:     map[string]any{"uuid": "rover-7", "payload": map[string]any{"cmd": "dock"}},
+	})
+	Describe(Info{
+		Type:        MQTTMessage("{name}"),
+		Description: "The raw payload an MQTT robot published on robomesh/message/{name}.",
+		Example:     `{"door":"open"}`,
+	})
+	Describe(Info{
+		Type:        ClusterLeader,
+		Description: "A cluster node gained or lost leadership.",
+		Example:     map[string]any{"node": "node-a", "leader": true},
+	})
+}
+
+// payloadOf drops the event type from a (type, payload) pair.
+func payloadOf(_ string, data any) any { return data }
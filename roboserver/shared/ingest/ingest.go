@@ -0,0 +1,138 @@
+// Package ingest turns the records a gateway posts to POST /ingest into
+// telemetry envelopes. A gateway sits in front of sensors that can't hold a
+// TCP session of their own and reports their readings in bulk:
+//
+//	[{"device_id":"greenhouse-t3","metric":"temp_c","value":21.5,"ts":1718000000000}, ...]
+//
+// Readings of one device taken at the same time become one envelope of type
+// "sensor", so they reach handlers, the sensor store and the event bus the
+// same way a robot's DATA report does.
+package ingest
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"roboserver/shared/events"
+	"roboserver/shared/telemetry"
+	"slices"
+	"time"
+)
+
+// EnvelopeType is the telemetry type of every ingested envelope.
+const EnvelopeType = "sensor"
+
+// Record is one reading as the gateway sends it. TS is Unix milliseconds;
+// 0 means now.
+type Record struct {
+	DeviceID string  `json:"device_id"`
+	Metric   string  `json:"metric"`
+	Value    float64 `json:"value"`
+	TS       int64   `json:"ts"`
+}
+
+// Reading is the envelope built for one device and timestamp, with the
+// indexes of the records it came from.
+type Reading struct {
+	DeviceID string
+	Envelope telemetry.Envelope
+	Records  []int
+}
+
+// Rejected reports a record that was not ingested.
+type Rejected struct {
+	Index    int    `json:"index"`
+	DeviceID string `json:"device_id,omitempty"`
+	Error    string `json:"error"`
+}
+
+// VirtualCreated is the payload of robot.virtual_created.
+type VirtualCreated struct {
+	UUID       string `json:"uuid"`
+	DeviceType string `json:"device_type"`
+	Gateway    string `json:"gateway"` // API key ID the gateway used
+	IP         string `json:"ip"`
+	Time       int64  `json:"time"` // Unix seconds
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotVirtualCreated,
+		Description: "A gateway reported a device ID for the first time through POST /ingest, and a virtual robot was created for it. Its readings follow on robot.{uuid}.telemetry.",
+		Example:     VirtualCreated{UUID: "greenhouse-t3", DeviceType: "virtual_sensor", Gateway: "k3f9a1", IP: "10.0.4.2", Time: 1718000000},
+	})
+}
+
+// Group validates records and merges the readings of each device taken at
+// the same time into one envelope. Readings come back ordered by device and
+// then timestamp; a metric repeated within one envelope keeps its last value.
+func Group(records []Record, now time.Time) ([]Reading, []Rejected) {
+	type key struct {
+		device string
+		ts     int64
+	}
+	var rejected []Rejected
+	groups := make(map[key]*Reading)
+	for i, rec := range records {
+		if err := check(rec); err != nil {
+			rejected = append(rejected, Rejected{Index: i, DeviceID: rec.DeviceID, Error: err.Error()})
+			continue
+		}
+		ts := rec.TS
+		if ts == 0 {
+			ts = now.UnixMilli()
+		}
+		k := key{rec.DeviceID, ts}
+		g := groups[k]
+		if g == nil {
+			g = &Reading{
+				DeviceID: rec.DeviceID,
+				Envelope: telemetry.Envelope{Type: EnvelopeType, Metrics: make(map[string]float64), Timestamp: ts},
+			}
+			groups[k] = g
+		}
+		if _, ok := g.Envelope.Metrics[rec.Metric]; !ok && len(g.Envelope.Metrics) == telemetry.MaxMetrics {
+			rejected = append(rejected, Rejected{Index: i, DeviceID: rec.DeviceID, Error: fmt.Sprintf("more than %d metrics at one timestamp", telemetry.MaxMetrics)})
+			continue
+		}
+		g.Envelope.Metrics[rec.Metric] = rec.Value
+		g.Records = append(g.Records, i)
+	}
+
+	readings := make([]Reading, 0, len(groups))
+	for _, g := range groups {
+		readings = append(readings, *g)
+	}
+	slices.SortFunc(readings, func(a, b Reading) int {
+		return cmp.Or(cmp.Compare(a.DeviceID, b.DeviceID), cmp.Compare(a.Envelope.Timestamp, b.Envelope.Timestamp))
+	})
+	return readings, rejected
+}
+
+// Reject marks every record of r as rejected with err.
+func (r Reading) Reject(err error) []Rejected {
+	out := make([]Rejected, len(r.Records))
+	for i, idx := range r.Records {
+		out[i] = Rejected{Index: idx, DeviceID: r.DeviceID, Error: err.Error()}
+	}
+	return out
+}
+
+func check(rec Record) error {
+	switch {
+	case rec.DeviceID == "":
+		return fmt.Errorf("device_id is required")
+	case rec.Metric == "":
+		return fmt.Errorf("metric is required")
+	case math.IsNaN(rec.Value) || math.IsInf(rec.Value, 0):
+		return fmt.Errorf("value is not a finite number")
+	case rec.TS < 0:
+		return fmt.Errorf("ts must be Unix milliseconds")
+	}
+	return nil
+}
+
+// SortRejected orders rejections by record index.
+func SortRejected(rejected []Rejected) {
+	slices.SortFunc(rejected, func(a, b Rejected) int { return cmp.Compare(a.Index, b.Index) })
+}
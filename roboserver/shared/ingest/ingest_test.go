@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	now := time.UnixMilli(1718000005000)
+	records := []Record{
+		{DeviceID: "t3", Metric: "temp_c", Value: 21.5, TS: 1718000000000},
+		{DeviceID: "t3", Metric: "humidity", Value: 40, TS: 1718000000000},
+		{DeviceID: "t1", Metric: "temp_c", Value: 19},
+		{DeviceID: "", Metric: "temp_c", Value: 1},
+		{DeviceID: "t3", Metric: "temp_c", Value: math.NaN()},
+		{DeviceID: "t3", Metric: "temp_c", Value: 22, TS: 1718000001000},
+		{DeviceID: "t3", Metric: "", Value: 1},
+		{DeviceID: "t3", Metric: "temp_c", Value: 1, TS: -1},
+	}
+	readings, rejected := Group(records, now)
+
+	if len(readings) != 3 {
+		t.Fatalf("Expected 3 envelopes, got %+v", readings)
+	}
+	first := readings[0]
+	if first.DeviceID != "t1" || first.Envelope.Timestamp != now.UnixMilli() || first.Envelope.Type != EnvelopeType {
+		t.Errorf("Expected t1 stamped now first, got %+v", first)
+	}
+	merged := readings[1]
+	if merged.DeviceID != "t3" || len(merged.Envelope.Metrics) != 2 || merged.Envelope.Metrics["humidity"] != 40 {
+		t.Errorf("Expected t3's readings at one timestamp merged, got %+v", merged)
+	}
+	if len(merged.Records) != 2 || merged.Records[0] != 0 || merged.Records[1] != 1 {
+		t.Errorf("Expected record indexes [0 1], got %v", merged.Records)
+	}
+	if readings[2].Envelope.Timestamp != 1718000001000 {
+		t.Errorf("Expected the later t3 reading last, got %+v", readings[2])
+	}
+
+	want := []int{3, 4, 6, 7}
+	if len(rejected) != len(want) {
+		t.Fatalf("Expected %d rejections, got %+v", len(want), rejected)
+	}
+	for i, idx := range want {
+		if rejected[i].Index != idx || rejected[i].Error == "" {
+			t.Errorf("Rejection %d = %+v, want index %d", i, rejected[i], idx)
+		}
+	}
+}
+
+func TestReadingReject(t *testing.T) {
+	readings, _ := Group([]Record{
+		{DeviceID: "bad id", Metric: "a", Value: 1, TS: 5},
+		{DeviceID: "bad id", Metric: "b", Value: 2, TS: 5},
+	}, time.Now())
+	rejected := readings[0].Reject(errString("invalid device_id"))
+	if len(rejected) != 2 || rejected[1].Index != 1 || rejected[1].DeviceID != "bad id" || rejected[1].Error != "invalid device_id" {
+		t.Errorf("Expected both records rejected, got %+v", rejected)
+	}
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
@@ -0,0 +1,90 @@
+// Package ipconflict decides what happens when a robot starts a session
+// from an IP address another active robot already holds. Robots behind one
+// NAT gateway legitimately share an address, so the default policy only
+// reports the conflict; stricter sites reject the newcomer, evict the old
+// session, or accept the newcomer only if it holds the same key as the
+// robot it replaces.
+package ipconflict
+
+import (
+	"errors"
+	"fmt"
+	"roboserver/shared/events"
+)
+
+// Policies (ip_conflict.policy).
+const (
+	Allow             = "allow"               // keep both, report the conflict
+	RejectNew         = "reject-new"          // refuse the new session
+	EvictOld          = "evict-old"           // end the old robot's session
+	RequireTokenMatch = "require-token-match" // evict if the keys match, otherwise reject
+)
+
+// Actions taken on a conflict.
+const (
+	Allowed  = "allowed"
+	Rejected = "rejected"
+	Evicted  = "evicted"
+)
+
+// Conflict is the payload of the robot.ip_conflict event.
+type Conflict struct {
+	IP       string `json:"ip"`
+	UUID     string `json:"uuid"`     // robot starting a session
+	Existing string `json:"existing"` // robot that held the address
+	Policy   string `json:"policy"`
+	Action   string `json:"action"`
+	Time     int64  `json:"time"` // Unix seconds
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.IPConflict,
+		Description: "A robot started a session from an address another active robot holds; action is what ip_conflict.policy did about it.",
+		Example:     Conflict{IP: "10.0.0.5", UUID: "rover-8", Existing: "rover-7", Policy: EvictOld, Action: Evicted, Time: 1718000000},
+	})
+}
+
+// ErrConflict is matched (via errors.Is) by every *Error.
+var ErrConflict = errors.New("ip address is held by another robot")
+
+// Error is returned when the policy rejects a new session.
+type Error struct {
+	Conflict Conflict
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ip %s is held by robot %s", e.Conflict.IP, e.Conflict.Existing)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ParsePolicy validates ip_conflict.policy ("" = allow).
+func ParsePolicy(s string) (string, error) {
+	switch s {
+	case "":
+		return Allow, nil
+	case Allow, RejectNew, EvictOld, RequireTokenMatch:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown ip_conflict policy %q (want allow, reject-new, evict-old or require-token-match)", s)
+}
+
+// Resolve returns the action policy takes for a conflict. keysMatch
+// reports whether the new robot's public key is the old robot's.
+func Resolve(policy string, keysMatch bool) string {
+	switch policy {
+	case RejectNew:
+		return Rejected
+	case EvictOld:
+		return Evicted
+	case RequireTokenMatch:
+		if keysMatch {
+			return Evicted
+		}
+		return Rejected
+	}
+	return Allowed
+}
@@ -0,0 +1,54 @@
+package ipconflict
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                    Allow,
+		"allow":               Allow,
+		"reject-new":          RejectNew,
+		"evict-old":           EvictOld,
+		"require-token-match": RequireTokenMatch,
+	} {
+		if got, err := ParsePolicy(in); err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("evict"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cases := []struct {
+		policy    string
+		keysMatch bool
+		want      string
+	}{
+		{Allow, false, Allowed},
+		{RejectNew, true, Rejected},
+		{EvictOld, false, Evicted},
+		{RequireTokenMatch, true, Evicted},
+		{RequireTokenMatch, false, Rejected},
+	}
+	for _, c := range cases {
+		if got := Resolve(c.policy, c.keysMatch); got != c.want {
+			t.Errorf("Resolve(%q, %v) = %q, want %q", c.policy, c.keysMatch, got, c.want)
+		}
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := fmt.Errorf("session refused: %w", &Error{Conflict: Conflict{IP: "10.0.0.5", Existing: "r1"}})
+	if !errors.Is(err, ErrConflict) {
+		t.Error("Expected a wrapped *Error to match ErrConflict")
+	}
+	var conflict *Error
+	if !errors.As(err, &conflict) || conflict.Conflict.Existing != "r1" {
+		t.Errorf("Expected errors.As to recover the conflict, got %+v", conflict)
+	}
+}
@@ -0,0 +1,323 @@
+// Package jobs runs long operations (bulk broadcasts, telemetry purges) on
+// a small worker pool instead of inside the HTTP request that started them.
+// Submit returns a queued Job at once; callers poll GET /jobs/{id} for its
+// progress and result, or cancel it with DELETE /jobs/{id}. Jobs live in
+// this node's memory: they do not survive a restart and are not visible
+// from other cluster nodes.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"roboserver/shared/events"
+	"sync"
+	"time"
+)
+
+// Job statuses.
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+	Cancelled = "cancelled"
+)
+
+var (
+	ErrQueueFull = errors.New("job queue is full")
+	ErrNotFound  = errors.New("job not found")
+	ErrFinished  = errors.New("job has already finished")
+)
+
+// Job is a snapshot of a job's state.
+type Job struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	Done       int    `json:"done"`            // work items finished so far
+	Total      int    `json:"total,omitempty"` // 0 = unknown
+	Error      string `json:"error,omitempty"`
+	Result     any    `json:"result,omitempty"`
+	CreatedAt  int64  `json:"created_at"` // Unix seconds
+	StartedAt  int64  `json:"started_at,omitempty"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.JobUpdated,
+		Description: "A background job was queued, started or finished.",
+		Example:     Job{ID: "9f86d081884c7d65", Type: "broadcast", Status: Running, CreatedBy: "alice", Done: 12, Total: 40, CreatedAt: 1718000000, StartedAt: 1718000001},
+	})
+}
+
+// Finished reports whether the job has stopped for good.
+func (j Job) Finished() bool {
+	return j.Status == Succeeded || j.Status == Failed || j.Status == Cancelled
+}
+
+// Func is the work of a job. It reports progress through p and should
+// return promptly once ctx is cancelled.
+type Func func(ctx context.Context, p *Progress) (any, error)
+
+// Progress lets a running job report how far it got.
+type Progress struct {
+	m  *Manager
+	id string
+}
+
+// ID returns the id of the job, for work that names its output after it
+// ("" for a nil Progress).
+func (p *Progress) ID() string {
+	if p == nil {
+		return ""
+	}
+	return p.id
+}
+
+// SetTotal sets the number of work items the job expects to finish. A nil
+// Progress ignores it, so work shared with synchronous callers can report
+// unconditionally.
+func (p *Progress) SetTotal(n int) {
+	if p == nil {
+		return
+	}
+	p.m.update(p.id, func(j *Job) { j.Total = n })
+}
+
+// Add marks n more work items as finished.
+func (p *Progress) Add(n int) {
+	if p == nil {
+		return
+	}
+	p.m.update(p.id, func(j *Job) { j.Done += n })
+}
+
+type entry struct {
+	job    Job
+	fn     Func
+	cancel context.CancelFunc // set while running
+}
+
+// Manager queues jobs and runs them on its workers.
+type Manager struct {
+	mu     sync.Mutex
+	jobs   map[string]*entry
+	order  []string // job ids, oldest first
+	queue  chan string
+	keep   int
+	notify func(Job)
+}
+
+// Default is the process-wide job manager, sized by the jobs config in
+// main before its workers start.
+var Default = NewManager(100, 200)
+
+// NewManager returns a manager that queues up to queueSize jobs and keeps
+// the last keep finished ones for GET /jobs.
+func NewManager(queueSize, keep int) *Manager {
+	return &Manager{
+		jobs:  make(map[string]*entry),
+		queue: make(chan string, max(queueSize, 1)),
+		keep:  max(keep, 1),
+	}
+}
+
+// Start runs workers goroutines until ctx is cancelled, which also cancels
+// the jobs they are running. notify (optional) is called with every status
+// change; it must not block.
+func (m *Manager) Start(ctx context.Context, workers int, notify func(Job)) {
+	m.mu.Lock()
+	m.notify = notify
+	m.mu.Unlock()
+	for range max(workers, 1) {
+		go m.work(ctx)
+	}
+}
+
+// Submit queues fn as a job of the given type on behalf of createdBy.
+func (m *Manager) Submit(jobType, createdBy string, fn Func) (Job, error) {
+	e := &entry{
+		job: Job{
+			ID:        newID(),
+			Type:      jobType,
+			Status:    Queued,
+			CreatedBy: createdBy,
+			CreatedAt: time.Now().Unix(),
+		},
+		fn: fn,
+	}
+	m.mu.Lock()
+	select {
+	case m.queue <- e.job.ID:
+	default:
+		m.mu.Unlock()
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[e.job.ID] = e
+	m.order = append(m.order, e.job.ID)
+	m.prune()
+	job, notify := e.job, m.notify
+	m.mu.Unlock()
+
+	if notify != nil {
+		notify(job)
+	}
+	return job, nil
+}
+
+// Get returns a job by id.
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return e.job, true
+}
+
+// List returns jobs newest first, only those createdBy submitted unless it
+// is "" (limit <= 0 = all).
+func (m *Manager) List(createdBy string, limit int) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Job{}
+	for i := len(m.order) - 1; i >= 0; i-- {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		job := m.jobs[m.order[i]].job
+		if createdBy == "" || job.CreatedBy == createdBy {
+			out = append(out, job)
+		}
+	}
+	return out
+}
+
+// Cancel stops a queued or running job. A running job ends once its Func
+// notices the cancelled context.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	e, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+	if e.job.Finished() {
+		job := e.job
+		m.mu.Unlock()
+		return job, ErrFinished
+	}
+	if e.cancel != nil {
+		e.cancel()
+	}
+	// A running job keeps its status until its Func returns; a queued one
+	// is skipped by the worker that dequeues it.
+	var notify func(Job)
+	if e.job.Status == Queued {
+		e.job.Status = Cancelled
+		e.job.FinishedAt = time.Now().Unix()
+		notify = m.notify
+	}
+	job := e.job
+	m.mu.Unlock()
+
+	if notify != nil {
+		notify(job)
+	}
+	return job, nil
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(ctx, id)
+		}
+	}
+}
+
+func (m *Manager) run(parent context.Context, id string) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	m.mu.Lock()
+	e, ok := m.jobs[id]
+	if !ok || e.job.Status != Queued {
+		m.mu.Unlock()
+		return
+	}
+	e.cancel = cancel
+	e.job.Status = Running
+	e.job.StartedAt = time.Now().Unix()
+	job, notify := e.job, m.notify
+	m.mu.Unlock()
+	if notify != nil {
+		notify(job)
+	}
+
+	result, err := e.fn(ctx, &Progress{m: m, id: id})
+
+	m.mu.Lock()
+	e.cancel = nil
+	e.job.Result = result
+	e.job.FinishedAt = time.Now().Unix()
+	switch {
+	case ctx.Err() != nil:
+		e.job.Status = Cancelled
+	case err != nil:
+		e.job.Status = Failed
+		e.job.Error = err.Error()
+	default:
+		e.job.Status = Succeeded
+	}
+	job, notify = e.job, m.notify
+	m.mu.Unlock()
+	if notify != nil {
+		notify(job)
+	}
+}
+
+func (m *Manager) update(id string, fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.jobs[id]; ok {
+		fn(&e.job)
+	}
+}
+
+// prune drops the oldest finished jobs beyond keep. Queued and running
+// jobs are never dropped. Caller holds m.mu.
+func (m *Manager) prune() {
+	finished := 0
+	for _, id := range m.order {
+		if m.jobs[id].job.Finished() {
+			finished++
+		}
+	}
+	if finished <= m.keep {
+		return
+	}
+	kept := m.order[:0]
+	for _, id := range m.order {
+		if finished > m.keep && m.jobs[id].job.Finished() {
+			delete(m.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	m.order = kept
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls m until job id satisfies done or the test times out.
+func waitFor(t *testing.T, m *Manager, id string, done func(Job) bool) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := m.Get(id); ok && done(job) {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("Timed out waiting for job %s, last state %+v", id, job)
+	return job
+}
+
+func TestJobRunsToCompletion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewManager(10, 10)
+	m.Start(ctx, 1, nil)
+
+	job, err := m.Submit("count", "alice", func(ctx context.Context, p *Progress) (any, error) {
+		p.SetTotal(3)
+		p.Add(3)
+		return p.ID(), nil
+	})
+	if err != nil || job.Status != Queued {
+		t.Fatalf("Expected a queued job, got %+v %v", job, err)
+	}
+
+	job = waitFor(t, m, job.ID, Job.Finished)
+	if job.Status != Succeeded || job.Result != job.ID || job.Done != 3 || job.Total != 3 {
+		t.Errorf("Expected a succeeded job with progress 3/3, got %+v", job)
+	}
+	if job.StartedAt == 0 || job.FinishedAt == 0 {
+		t.Errorf("Expected start and finish times, got %+v", job)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewManager(10, 10)
+	m.Start(ctx, 1, nil)
+
+	job, _ := m.Submit("fail", "", func(context.Context, *Progress) (any, error) {
+		return nil, errors.New("boom")
+	})
+	job = waitFor(t, m, job.ID, Job.Finished)
+	if job.Status != Failed || job.Error != "boom" {
+		t.Errorf("Expected a failed job, got %+v", job)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewManager(10, 10)
+	m.Start(ctx, 1, nil)
+
+	job, _ := m.Submit("wait", "", func(ctx context.Context, _ *Progress) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	waitFor(t, m, job.ID, func(j Job) bool { return j.Status == Running })
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	job = waitFor(t, m, job.ID, Job.Finished)
+	if job.Status != Cancelled {
+		t.Errorf("Expected a cancelled job, got %+v", job)
+	}
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected ErrFinished for a second cancel, got %v", err)
+	}
+	if _, err := m.Cancel("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	m := NewManager(10, 10) // no workers: jobs stay queued
+	ran := false
+	job, _ := m.Submit("never", "", func(context.Context, *Progress) (any, error) {
+		ran = true
+		return nil, nil
+	})
+	job, err := m.Cancel(job.ID)
+	if err != nil || job.Status != Cancelled {
+		t.Fatalf("Expected the queued job to be cancelled, got %+v %v", job, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.run(ctx, job.ID)
+	if ran {
+		t.Error("Expected a cancelled job not to run")
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	m := NewManager(1, 10)
+	noop := func(context.Context, *Progress) (any, error) { return nil, nil }
+	if _, err := m.Submit("a", "", noop); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if _, err := m.Submit("b", "", noop); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestListAndPrune(t *testing.T) {
+	m := NewManager(10, 1)
+	noop := func(context.Context, *Progress) (any, error) { return nil, nil }
+	first, _ := m.Submit("a", "alice", noop)
+	second, _ := m.Submit("b", "bob", noop)
+	m.Cancel(first.ID)
+	m.Cancel(second.ID)
+
+	if got := m.List("", 0); len(got) != 2 || got[0].ID != second.ID {
+		t.Fatalf("Expected both jobs newest first, got %+v", got)
+	}
+	if got := m.List("alice", 0); len(got) != 1 || got[0].ID != first.ID {
+		t.Errorf("Expected only alice's job, got %+v", got)
+	}
+
+	// Keep is 1, so the next submission drops the oldest finished job.
+	m.Submit("c", "", noop)
+	if _, ok := m.Get(first.ID); ok {
+		t.Error("Expected the oldest finished job to be pruned")
+	}
+	if _, ok := m.Get(second.ID); !ok {
+		t.Error("Expected the newest finished job to be kept")
+	}
+}
@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"roboserver/shared"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Component is a long-lived piece of the server that needs an orderly stop.
+// DependsOn lists the names of components this one uses while running; a
+// component is always stopped before anything it depends on, so e.g. the
+// database outlives every server that writes to it.
+type Component struct {
+	Name      string
+	DependsOn []string
+	Stop      func(ctx context.Context) error
+	Timeout   time.Duration // Zero falls back to the manager's default
+}
+
+type Lifecycle_t struct {
+	mu             sync.Mutex
+	components     map[string]*Component
+	order          []string // registration order, keeps shutdown deterministic
+	defaultTimeout time.Duration
+}
+
+func NewLifecycle(defaultTimeout time.Duration) *Lifecycle_t {
+	return &Lifecycle_t{
+		components:     make(map[string]*Component),
+		defaultTimeout: defaultTimeout,
+	}
+}
+
+// Register adds a component. Dependencies may be registered later; they are
+// only resolved when the stop order is computed.
+func (l *Lifecycle_t) Register(c Component) error {
+	if c.Name == "" {
+		return fmt.Errorf("component name is required")
+	}
+	if c.Stop == nil {
+		return fmt.Errorf("component %s has no stop function", c.Name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, exists := l.components[c.Name]; exists {
+		return fmt.Errorf("component %s already registered", c.Name)
+	}
+	l.components[c.Name] = &c
+	l.order = append(l.order, c.Name)
+	return nil
+}
+
+// StopOrder returns component names in the order they will be stopped:
+// dependents first, dependencies last. Fails on unknown dependencies or cycles.
+func (l *Lifecycle_t) StopOrder() ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopOrderLocked()
+}
+
+func (l *Lifecycle_t) stopOrderLocked() ([]string, error) {
+	// dependents[x] = components that depend on x
+	dependents := make(map[string][]string, len(l.components))
+	for _, name := range l.order {
+		for _, dep := range l.components[name].DependsOn {
+			if _, ok := l.components[dep]; !ok {
+				return nil, fmt.Errorf("component %s depends on unknown component %s", name, dep)
+			}
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	// Kahn's algorithm on the reversed graph: a component is ready to stop
+	// once every component depending on it has been stopped.
+	remaining := make(map[string]int, len(l.components))
+	index := make(map[string]int, len(l.order))
+	for i, name := range l.order {
+		remaining[name] = len(dependents[name])
+		index[name] = i
+	}
+
+	var ready []string
+	for _, name := range l.order {
+		if remaining[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	result := make([]string, 0, len(l.order))
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		result = append(result, name)
+
+		for _, dep := range l.components[name].DependsOn {
+			remaining[dep]--
+			if remaining[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+		sort.SliceStable(ready, func(i, j int) bool { return index[ready[i]] < index[ready[j]] })
+	}
+
+	if len(result) != len(l.order) {
+		return nil, fmt.Errorf("dependency cycle between components")
+	}
+	return result, nil
+}
+
+// Shutdown stops every component in dependency order. Each component gets its
+// own timeout; one that overruns or fails is logged and shutdown moves on, so
+// a wedged server can't keep the database open forever. Returns the first error.
+func (l *Lifecycle_t) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	order, err := l.stopOrderLocked()
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	components := make([]*Component, len(order))
+	for i, name := range order {
+		components[i] = l.components[name]
+	}
+	l.mu.Unlock()
+
+	var firstErr error
+	for _, c := range components {
+		if err := l.stopComponent(ctx, c); err != nil {
+			shared.DebugPrint("Shutdown: %s: %v", c.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (l *Lifecycle_t) stopComponent(ctx context.Context, c *Component) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = l.defaultTimeout
+	}
+	stopCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	shared.DebugPrint("Shutdown: stopping %s", c.Name)
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic during stop: %v", r)
+			}
+		}()
+		done <- c.Stop(stopCtx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-stopCtx.Done():
+		return fmt.Errorf("%s did not stop within %s", c.Name, timeout)
+	}
+}
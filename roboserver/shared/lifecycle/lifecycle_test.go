@@ -0,0 +1,120 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func noopStop(context.Context) error { return nil }
+
+func TestStopOrderDependentsFirst(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	lc.Register(Component{Name: "database", Stop: noopStop})
+	lc.Register(Component{Name: "tcp", DependsOn: []string{"database"}, Stop: noopStop})
+	lc.Register(Component{Name: "handlers", DependsOn: []string{"tcp", "database"}, Stop: noopStop})
+
+	order, err := lc.StopOrder()
+	if err != nil {
+		t.Fatalf("StopOrder failed: %v", err)
+	}
+	expected := []string{"handlers", "tcp", "database"}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestStopOrderUnknownDependency(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	lc.Register(Component{Name: "tcp", DependsOn: []string{"database"}, Stop: noopStop})
+
+	if _, err := lc.StopOrder(); err == nil {
+		t.Error("Expected error for unknown dependency")
+	}
+}
+
+func TestStopOrderCycle(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	lc.Register(Component{Name: "a", DependsOn: []string{"b"}, Stop: noopStop})
+	lc.Register(Component{Name: "b", DependsOn: []string{"a"}, Stop: noopStop})
+
+	if _, err := lc.StopOrder(); err == nil {
+		t.Error("Expected error for dependency cycle")
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	if err := lc.Register(Component{Name: "a", Stop: noopStop}); err != nil {
+		t.Fatalf("First register failed: %v", err)
+	}
+	if err := lc.Register(Component{Name: "a", Stop: noopStop}); err == nil {
+		t.Error("Expected error for duplicate component")
+	}
+}
+
+func TestShutdownRunsInOrder(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	var mu sync.Mutex
+	var stopped []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	lc.Register(Component{Name: "database", Stop: record("database")})
+	lc.Register(Component{Name: "http", DependsOn: []string{"database"}, Stop: record("http")})
+
+	if err := lc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if len(stopped) != 2 || stopped[0] != "http" || stopped[1] != "database" {
+		t.Errorf("Expected [http database], got %v", stopped)
+	}
+}
+
+func TestShutdownTimeoutContinues(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	dbStopped := false
+	lc.Register(Component{Name: "database", Stop: func(context.Context) error {
+		dbStopped = true
+		return nil
+	}})
+	lc.Register(Component{
+		Name:      "stuck",
+		DependsOn: []string{"database"},
+		Timeout:   20 * time.Millisecond,
+		Stop: func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	err := lc.Shutdown(context.Background())
+	if err == nil {
+		t.Error("Expected timeout error from stuck component")
+	}
+	if !dbStopped {
+		t.Error("Expected database to be stopped after stuck component timed out")
+	}
+}
+
+func TestShutdownReturnsFirstError(t *testing.T) {
+	lc := NewLifecycle(time.Second)
+	boom := errors.New("boom")
+	lc.Register(Component{Name: "a", Stop: func(context.Context) error { return boom }})
+
+	if err := lc.Shutdown(context.Background()); !errors.Is(err, boom) {
+		t.Errorf("Expected boom, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package macro implements named robot message templates. A template is the
+// message text with {param} placeholders, e.g.
+//
+//	{"command": "set_interval", "seconds": {seconds}}
+//
+// Only a brace pair around an identifier is a placeholder, so the braces of a
+// JSON template pass through untouched.
+package macro
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxTemplateBytes bounds a stored template; rendered messages go through the
+// same handler stdin path as POST /robot/{uuid}/message.
+const MaxTemplateBytes = 16 * 1024
+
+var (
+	nameRe        = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	placeholderRe = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+)
+
+type Macro struct {
+	Name        string   `json:"name"`
+	Template    string   `json:"template"`
+	Description string   `json:"description,omitempty"`
+	Params      []string `json:"params"` // derived from Template
+}
+
+// New validates a macro and fills in its parameter list.
+func New(name, template, description string) (*Macro, error) {
+	if !nameRe.MatchString(name) {
+		return nil, fmt.Errorf("macro name must be 1-64 characters of letters, digits, hyphens or underscores")
+	}
+	if strings.TrimSpace(template) == "" {
+		return nil, fmt.Errorf("template is required")
+	}
+	if len(template) > MaxTemplateBytes {
+		return nil, fmt.Errorf("template exceeds %d bytes", MaxTemplateBytes)
+	}
+	return &Macro{Name: name, Template: template, Description: description, Params: Params(template)}, nil
+}
+
+// Params lists the distinct placeholders in a template, sorted.
+func Params(template string) []string {
+	seen := make(map[string]bool)
+	params := []string{}
+	for _, m := range placeholderRe.FindAllStringSubmatch(template, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			params = append(params, m[1])
+		}
+	}
+	sort.Strings(params)
+	return params
+}
+
+// Render substitutes params into the template. Every placeholder must be
+// supplied and no extras are accepted. Numbers and booleans are inserted as
+// JSON literals; strings are JSON-escaped (without quotes) so a value placed
+// inside a quoted JSON string can't break out of it.
+func (m *Macro) Render(params map[string]any) (string, error) {
+	var missing, unknown []string
+	wanted := make(map[string]bool, len(m.Params))
+	for _, p := range m.Params {
+		wanted[p] = true
+		if _, ok := params[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	for p := range params {
+		if !wanted[p] {
+			unknown = append(unknown, p)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing params: %s", strings.Join(missing, ", "))
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("unknown params: %s", strings.Join(unknown, ", "))
+	}
+
+	values := make(map[string]string, len(params))
+	for name, v := range params {
+		s, err := formatValue(v)
+		if err != nil {
+			return "", fmt.Errorf("param %s: %w", name, err)
+		}
+		values[name] = s
+	}
+	return placeholderRe.ReplaceAllStringFunc(m.Template, func(ph string) string {
+		return values[ph[1:len(ph)-1]]
+	}), nil
+}
+
+func formatValue(v any) (string, error) {
+	switch val := v.(type) {
+	case string:
+		quoted, _ := json.Marshal(val)
+		return string(quoted[1 : len(quoted)-1]), nil
+	case float64, bool, json.Number, int:
+		return fmt.Sprint(val), nil
+	default:
+		return "", fmt.Errorf("must be a string, number or boolean")
+	}
+}
@@ -0,0 +1,80 @@
+package macro
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	got := Params(`{"command": "move", "x": {x}, "y": {y}, "again": {x}, "literal": "{not json}"}`)
+	if want := []string{"x", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := Params("ping"); len(got) != 0 {
+		t.Errorf("Expected no params, got %v", got)
+	}
+}
+
+func TestRenderJSONTemplate(t *testing.T) {
+	m, err := New("set_interval", `{"command": "set_interval", "seconds": {seconds}, "label": "{label}"}`, "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	out, err := m.Render(map[string]any{"seconds": float64(30), "label": `quote " and \ slash`})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Rendered template is not valid JSON: %v (%s)", err, out)
+	}
+	if decoded["seconds"] != float64(30) {
+		t.Errorf("Expected seconds=30, got %v", decoded["seconds"])
+	}
+	if decoded["label"] != `quote " and \ slash` {
+		t.Errorf("Expected label to round-trip, got %v", decoded["label"])
+	}
+}
+
+func TestRenderPlainTemplate(t *testing.T) {
+	m, _ := New("interval", "set_interval {seconds}", "")
+	out, err := m.Render(map[string]any{"seconds": float64(5)})
+	if err != nil || out != "set_interval 5" {
+		t.Errorf("Expected 'set_interval 5', got %q (err=%v)", out, err)
+	}
+}
+
+func TestRenderParamErrors(t *testing.T) {
+	m, _ := New("move", `{"x": {x}, "y": {y}}`, "")
+
+	tests := []struct {
+		name   string
+		params map[string]any
+	}{
+		{"missing", map[string]any{"x": float64(1)}},
+		{"unknown", map[string]any{"x": float64(1), "y": float64(2), "z": float64(3)}},
+		{"object value", map[string]any{"x": float64(1), "y": map[string]any{"a": 1}}},
+		{"null value", map[string]any{"x": float64(1), "y": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Render(tt.params); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	if _, err := New("bad name", "x", ""); err == nil {
+		t.Error("Expected error for invalid name")
+	}
+	if _, err := New("ok", "   ", ""); err == nil {
+		t.Error("Expected error for empty template")
+	}
+	if _, err := New("ok", string(make([]byte, MaxTemplateBytes+1)), ""); err == nil {
+		t.Error("Expected error for oversized template")
+	}
+}
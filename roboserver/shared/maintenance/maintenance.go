@@ -0,0 +1,174 @@
+// Package maintenance tracks robots an operator has put into maintenance
+// mode. While a robot is in maintenance:
+//
+//   - automated messages (broadcasts, quick actions, rules, schedules) are
+//     queued or dropped per maintenance.suppress instead of delivered;
+//     messages an operator sends to that one robot still go through
+//   - status and latency events carry "maintenance": true so monitoring
+//     can mute alerts for it
+//   - robot JSON has a "maintenance" object
+//
+// Redis (hash "maintenance") is the source of truth; Registry is this
+// node's copy, kept in sync by robot.{uuid}.maintenance events.
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"roboserver/shared/events"
+	"sync"
+	"sync/atomic"
+)
+
+// Info describes a robot's maintenance window.
+type Info struct {
+	UUID   string `json:"uuid"`
+	Reason string `json:"reason,omitempty"`
+	By     string `json:"by,omitempty"` // operator who started it
+	Since  int64  `json:"since"`        // Unix seconds
+}
+
+// Event is published on robot.{uuid}.maintenance when maintenance starts
+// (Active, with Info) or ends.
+type Event struct {
+	UUID   string `json:"uuid"`
+	Active bool   `json:"active"`
+	Info   *Info  `json:"info,omitempty"`
+	// Delivered counts queued messages sent when maintenance ended.
+	Delivered int `json:"delivered,omitempty"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotMaintenance("{uuid}"),
+		Description: "A robot entered maintenance (active, with info) or left it (delivered counts the queued messages then sent).",
+		Example:     Event{UUID: "rover-7", Active: true, Info: &Info{UUID: "rover-7", Reason: "battery swap", By: "alice", Since: 1718000000}},
+	})
+}
+
+// DecodeEvent reads an Event published locally or relayed from another
+// node (where it arrives as decoded JSON).
+func DecodeEvent(data any) (Event, bool) {
+	switch v := data.(type) {
+	case Event:
+		return v, true
+	case *Event:
+		if v == nil {
+			return Event{}, false
+		}
+		return *v, true
+	case map[string]any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return Event{}, false
+		}
+		var ev Event
+		if json.Unmarshal(raw, &ev) != nil || ev.UUID == "" {
+			return Event{}, false
+		}
+		return ev, true
+	}
+	return Event{}, false
+}
+
+// Suppression policies for automated messages.
+const (
+	Queue = "queue" // held in Redis and delivered when maintenance ends
+	Drop  = "drop"
+)
+
+// ParsePolicy validates maintenance.suppress ("" = queue).
+func ParsePolicy(s string) (string, error) {
+	switch s {
+	case "", Queue:
+		return Queue, nil
+	case Drop:
+		return Drop, nil
+	}
+	return "", fmt.Errorf("unknown maintenance suppress policy %q (want queue or drop)", s)
+}
+
+// Registry_t is the set of robots in maintenance as seen by this node.
+type Registry_t struct {
+	mu      sync.RWMutex
+	robots  map[string]Info
+	version atomic.Uint64
+}
+
+// Registry is the process-wide maintenance table.
+var Registry = NewRegistry()
+
+func NewRegistry() *Registry_t {
+	return &Registry_t{robots: make(map[string]Info)}
+}
+
+// Get returns a robot's maintenance info, if it is in maintenance.
+func (r *Registry_t) Get(uuid string) (Info, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.robots[uuid]
+	return info, ok
+}
+
+// Active reports whether a robot is in maintenance.
+func (r *Registry_t) Active(uuid string) bool {
+	_, ok := r.Get(uuid)
+	return ok
+}
+
+// All returns every robot in maintenance.
+func (r *Registry_t) All() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Info, 0, len(r.robots))
+	for _, info := range r.robots {
+		out = append(out, info)
+	}
+	return out
+}
+
+// Set records info.UUID as in maintenance.
+func (r *Registry_t) Set(info Info) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.robots[info.UUID] = info
+	r.version.Add(1)
+}
+
+// Clear takes a robot out of maintenance and reports whether it was in it.
+func (r *Registry_t) Clear(uuid string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.robots[uuid]; !ok {
+		return false
+	}
+	delete(r.robots, uuid)
+	r.version.Add(1)
+	return true
+}
+
+// Replace swaps in the full set, e.g. as loaded from Redis at startup.
+func (r *Registry_t) Replace(infos []Info) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.robots = make(map[string]Info, len(infos))
+	for _, info := range infos {
+		r.robots[info.UUID] = info
+	}
+	r.version.Add(1)
+}
+
+// Apply updates the registry from an Event.
+func (r *Registry_t) Apply(ev Event) {
+	if ev.Active && ev.Info != nil {
+		r.Set(*ev.Info)
+	} else if !ev.Active {
+		r.Clear(ev.UUID)
+	}
+}
+
+// Version changes whenever the registry does, so callers can cache
+// anything derived from it.
+func (r *Registry_t) Version() uint64 {
+	return r.version.Load()
+}
@@ -0,0 +1,69 @@
+package maintenance
+
+import "testing"
+
+func TestRegistrySetClear(t *testing.T) {
+	r := NewRegistry()
+	v := r.Version()
+
+	r.Set(Info{UUID: "r1", Reason: "motor swap"})
+	if info, ok := r.Get("r1"); !ok || info.Reason != "motor swap" {
+		t.Fatalf("Expected r1 in maintenance, got %+v %v", info, ok)
+	}
+	if r.Version() == v {
+		t.Error("Expected Set to change the version")
+	}
+
+	if !r.Clear("r1") || r.Active("r1") {
+		t.Error("Expected Clear to take r1 out of maintenance")
+	}
+	if r.Clear("r1") {
+		t.Error("Expected a second Clear to report false")
+	}
+}
+
+func TestRegistryApply(t *testing.T) {
+	r := NewRegistry()
+	r.Apply(Event{UUID: "r1", Active: true, Info: &Info{UUID: "r1"}})
+	if !r.Active("r1") {
+		t.Fatal("Expected an active event to set maintenance")
+	}
+	r.Apply(Event{UUID: "r1"})
+	if r.Active("r1") {
+		t.Error("Expected an inactive event to clear maintenance")
+	}
+}
+
+func TestRegistryReplace(t *testing.T) {
+	r := NewRegistry()
+	r.Set(Info{UUID: "old"})
+	r.Replace([]Info{{UUID: "r1"}, {UUID: "r2"}})
+	if r.Active("old") || !r.Active("r1") || len(r.All()) != 2 {
+		t.Errorf("Expected exactly r1 and r2, got %+v", r.All())
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for in, want := range map[string]string{"": Queue, "queue": Queue, "drop": Drop} {
+		if got, err := ParsePolicy(in); err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("defer"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	relayed := map[string]any{"uuid": "r1", "active": true, "info": map[string]any{"uuid": "r1", "reason": "calibration", "since": float64(10)}}
+	ev, ok := DecodeEvent(relayed)
+	if !ok || !ev.Active || ev.Info == nil || ev.Info.Reason != "calibration" {
+		t.Errorf("Expected a decoded active event, got %+v %v", ev, ok)
+	}
+	if _, ok := DecodeEvent(&Event{UUID: "r1"}); !ok {
+		t.Error("Expected a local *Event to decode")
+	}
+	if _, ok := DecodeEvent("nope"); ok {
+		t.Error("Expected a string payload to be rejected")
+	}
+}
@@ -0,0 +1,116 @@
+// Package metrics keeps a small in-memory history of server activity — one
+// sample per minute — so the UI can draw sparklines without a Prometheus
+// stack. History is lost on restart.
+package metrics
+
+import (
+	"context"
+	"math"
+	"roboserver/shared/data_structures"
+	"sync/atomic"
+	"time"
+)
+
+// Sample aggregates one interval (normally a minute).
+type Sample struct {
+	Time         int64   `json:"time"` // unix seconds at the end of the interval
+	RobotsOnline int     `json:"robots_online"`
+	MsgsPerSec   float64 `json:"msgs_per_sec"`
+	EventsPerSec float64 `json:"events_per_sec"`
+	// QueueOverflows counts messages that found a handler's stdin queue
+	// full during the interval.
+	QueueOverflows int64 `json:"queue_overflows"`
+}
+
+var (
+	messages  atomic.Int64
+	events    atomic.Int64
+	overflows atomic.Int64
+
+	timeline atomic.Pointer[data_structures.RingBuffer[Sample]]
+	latest   atomic.Pointer[Sample]
+)
+
+// RecordMessage counts one robot message passing through a handler (either
+// direction).
+func RecordMessage() {
+	messages.Add(1)
+}
+
+// RecordEvent counts one event published on the event bus.
+func RecordEvent() {
+	events.Add(1)
+}
+
+// RecordQueueOverflow counts one message that did not fit in a handler's
+// stdin queue.
+func RecordQueueOverflow() {
+	overflows.Add(1)
+}
+
+// Timeline returns recorded samples, oldest first. It is empty until Run has
+// completed its first interval.
+func Timeline() []Sample {
+	if tl := timeline.Load(); tl != nil {
+		return tl.Snapshot()
+	}
+	return nil
+}
+
+// Latest returns the most recent sample, false until Run has completed its
+// first interval.
+func Latest() (Sample, bool) {
+	if s := latest.Load(); s != nil {
+		return *s, true
+	}
+	return Sample{}, false
+}
+
+// Run samples the counters every interval, keeping the last `keep` samples,
+// until ctx is cancelled. robotsOnline is called once per interval.
+func Run(ctx context.Context, interval time.Duration, keep int, robotsOnline func() int) {
+	tl := data_structures.NewRingBuffer[Sample](keep)
+	timeline.Store(tl)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	messages.Swap(0)
+	events.Swap(0)
+	overflows.Swap(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sample := takeSample(now, now.Sub(last), robotsOnline)
+			tl.Push(sample)
+			latest.Store(&sample)
+			last = now
+		}
+	}
+}
+
+// takeSample resets the counters and converts them to per-second rates.
+func takeSample(now time.Time, elapsed time.Duration, robotsOnline func() int) Sample {
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	s := Sample{
+		Time:         now.Unix(),
+		MsgsPerSec:   rate(messages.Swap(0), secs),
+		EventsPerSec: rate(events.Swap(0), secs),
+
+		QueueOverflows: overflows.Swap(0),
+	}
+	if robotsOnline != nil {
+		s.RobotsOnline = robotsOnline()
+	}
+	return s
+}
+
+func rate(count int64, secs float64) float64 {
+	return math.Round(float64(count)/secs*100) / 100
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTakeSampleRatesAndReset(t *testing.T) {
+	messages.Swap(0)
+	events.Swap(0)
+	for i := 0; i < 120; i++ {
+		RecordMessage()
+	}
+	for i := 0; i < 30; i++ {
+		RecordEvent()
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	s := takeSample(now, time.Minute, func() int { return 4 })
+
+	if s.Time != now.Unix() {
+		t.Errorf("Expected time %d, got %d", now.Unix(), s.Time)
+	}
+	if s.MsgsPerSec != 2 {
+		t.Errorf("Expected 2 msgs/sec, got %v", s.MsgsPerSec)
+	}
+	if s.EventsPerSec != 0.5 {
+		t.Errorf("Expected 0.5 events/sec, got %v", s.EventsPerSec)
+	}
+	if s.RobotsOnline != 4 {
+		t.Errorf("Expected 4 robots online, got %d", s.RobotsOnline)
+	}
+
+	next := takeSample(now.Add(time.Minute), time.Minute, nil)
+	if next.MsgsPerSec != 0 || next.EventsPerSec != 0 {
+		t.Errorf("Expected counters reset after sampling, got %+v", next)
+	}
+}
+
+func TestRunKeepsBoundedTimeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, 5*time.Millisecond, 3, func() int { return 1 })
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for len(Timeline()) < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("Timeline never filled, got %d samples", len(Timeline()))
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	samples := Timeline()
+	if len(samples) != 3 {
+		t.Fatalf("Expected timeline capped at 3 samples, got %d", len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time < samples[i-1].Time {
+			t.Errorf("Samples out of order: %+v", samples)
+		}
+	}
+	if latest, ok := Latest(); !ok || latest != samples[len(samples)-1] {
+		t.Errorf("Latest() = %+v, %v; want the newest sample %+v", latest, ok, samples[len(samples)-1])
+	}
+}
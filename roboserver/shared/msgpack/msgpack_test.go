@@ -0,0 +1,50 @@
+package msgpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	type robot struct {
+		UUID   string  `json:"uuid"`
+		Online bool    `json:"online"`
+		PID    int     `json:"pid,omitempty"`
+		Temp   float64 `json:"temp"`
+		Tags   []any   `json:"tags"`
+	}
+	got, err := Marshal(robot{UUID: "r1", Online: true, Temp: 1.5, Tags: []any{-1, 300, nil}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := []byte{
+		0x84, // map of 4 (pid omitted), sorted keys
+		0xa6, 'o', 'n', 'l', 'i', 'n', 'e', 0xc3,
+		0xa4, 't', 'a', 'g', 's', 0x93, 0xff, 0xcd, 0x01, 0x2c, 0xc0,
+		0xa4, 't', 'e', 'm', 'p', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa4, 'u', 'u', 'i', 'd', 0xa2, 'r', '1',
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Unexpected encoding:\n got % x\nwant % x", got, want)
+	}
+}
+
+func TestMarshalSizes(t *testing.T) {
+	for _, tc := range []struct {
+		v      any
+		prefix []byte
+	}{
+		{int64(-129), []byte{0xd1, 0xff, 0x7f}},
+		{int64(1) << 40, []byte{0xcf}},
+		{uint64(1) << 63, []byte{0xcf, 0x80}},
+		{strings.Repeat("x", 40), []byte{0xd9, 40}},
+		{strings.Repeat("x", 300), []byte{0xda, 0x01, 0x2c}},
+		{make([]int, 20), []byte{0xdc, 0x00, 0x14}},
+	} {
+		got, err := Marshal(tc.v)
+		if err != nil || !bytes.HasPrefix(got, tc.prefix) {
+			t.Errorf("%T: expected prefix % x, got % x (%v)", tc.v, tc.prefix, got[:min(len(got), 4)], err)
+		}
+	}
+}
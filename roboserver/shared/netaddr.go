@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// CanonicalIP returns the IP of addr in one canonical form, so an address
+// always compares equal to itself however it was written: a port is
+// stripped ("10.0.0.5:4312", "[2001:db8::5]:4312"), IPv4-mapped IPv6
+// addresses become IPv4, and IPv6 is lowercased and zero-compressed. An
+// IPv6 zone ("fe80::1%eth0") is kept. Anything that doesn't parse as an IP
+// is returned without its port.
+func CanonicalIP(addr string) string {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	return ip.Unmap().String()
+}
+
+// AddIP returns ips with ip (canonicalized) moved to the front, keeping at
+// most max addresses. Empty addresses are not added.
+func AddIP(ips []string, ip string, max int) []string {
+	ip = CanonicalIP(ip)
+	if ip == "" {
+		return ips
+	}
+	out := make([]string, 0, min(len(ips)+1, max))
+	out = append(out, ip)
+	for _, existing := range ips {
+		if len(out) == max {
+			break
+		}
+		if existing != ip {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
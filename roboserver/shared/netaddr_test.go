@@ -0,0 +1,55 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalIP(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.5:4312":          "10.0.0.5",
+		"10.0.0.5":               "10.0.0.5",
+		"[fe80::1]:9000":         "fe80::1",
+		"fe80::1":                "fe80::1",
+		"[fe80::1%eth0]:9000":    "fe80::1%eth0",
+		"2001:DB8:0:0:0:0:0:5":   "2001:db8::5",
+		"::ffff:10.0.0.5":        "10.0.0.5",
+		"[::ffff:10.0.0.5]:4312": "10.0.0.5",
+		"robot.local:4312":       "robot.local",
+		"":                       "",
+	} {
+		if got := CanonicalIP(in); got != want {
+			t.Errorf("CanonicalIP(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAddIP(t *testing.T) {
+	ips := AddIP(nil, "10.0.0.5:4312", 3)
+	ips = AddIP(ips, "2001:db8::5", 3)
+	ips = AddIP(ips, "::ffff:10.0.0.5", 3)
+	if want := []string{"10.0.0.5", "2001:db8::5"}; !reflect.DeepEqual(ips, want) {
+		t.Fatalf("got %v, want %v", ips, want)
+	}
+	ips = AddIP(ips, "10.0.0.6", 3)
+	ips = AddIP(ips, "10.0.0.7", 3)
+	if want := []string{"10.0.0.7", "10.0.0.6", "10.0.0.5"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("Expected the oldest address to be dropped, got %v", ips)
+	}
+	if got := AddIP(ips, "", 3); !reflect.DeepEqual(got, ips) {
+		t.Errorf("Expected an empty address to be ignored, got %v", got)
+	}
+}
+
+func TestRedactIP(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.5":            "10.0.0.***",
+		"2001:db8:1:2::5":     "2001:db8:1::***",
+		"[fe80::1%eth0]:9000": "fe80::***",
+		"not-an-ip":           "not-an-ip",
+	} {
+		if got := RedactIP(in); got != want {
+			t.Errorf("RedactIP(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
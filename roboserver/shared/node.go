@@ -0,0 +1,23 @@
+package shared
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"sync"
+)
+
+// NodeID identifies this server instance in a cluster. It is cluster.node_id
+// when set, otherwise "<hostname>-<random>", fixed for the process lifetime.
+var NodeID = sync.OnceValue(func() string {
+	if AppConfig.Cluster.NodeID != "" {
+		return AppConfig.Cluster.NodeID
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "node"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+})
@@ -0,0 +1,248 @@
+// Package notify holds users' push notification settings: the phones they
+// registered and the rules choosing which events are pushed to them, with
+// quiet hours. The push gateway (push/) delivers them; the settings are
+// stored per user in Redis.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"roboserver/shared/events"
+	"strings"
+	"time"
+)
+
+// Push platforms a device token belongs to.
+const (
+	PlatformFCM  = "fcm"  // Firebase Cloud Messaging (Android, web)
+	PlatformAPNs = "apns" // Apple Push Notification service
+)
+
+// Limits on one user's settings.
+const (
+	MaxDevices     = 10
+	MaxRules       = 50
+	MaxTokenLength = 4096
+)
+
+// Device is a phone (or browser) registered for push notifications.
+type Device struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+	Name     string `json:"name,omitempty"`
+	AddedAt  int64  `json:"added_at"`
+}
+
+// Validate checks a device before it is registered.
+func (d *Device) Validate() error {
+	if d.Platform != PlatformFCM && d.Platform != PlatformAPNs {
+		return fmt.Errorf("platform must be %q or %q", PlatformFCM, PlatformAPNs)
+	}
+	if d.Token == "" || len(d.Token) > MaxTokenLength || strings.ContainsAny(d.Token, " \t\r\n/") {
+		return fmt.Errorf("token is required (max %d characters, no whitespace or slashes)", MaxTokenLength)
+	}
+	return nil
+}
+
+// Rule pushes events of the listed types. Title and Body may use
+// placeholders: {type} is the event type, {robot} the robot's UUID and
+// {name} any top-level field of the event payload. Empty, the title is the
+// event type and the body the robot.
+type Rule struct {
+	Events   []string `json:"events"`             // event types; "prefix.*" matches by prefix
+	Robots   []string `json:"robots,omitempty"`   // only events of these robots; empty = any the user can access
+	Title    string   `json:"title,omitempty"`    // notification title template
+	Body     string   `json:"body,omitempty"`     // notification body template
+	Critical bool     `json:"critical,omitempty"` // also pushed during quiet hours
+}
+
+// Matches reports whether the rule covers an event type from robot (""
+// for events not scoped to a robot).
+func (r *Rule) Matches(eventType, robot string) bool {
+	if !events.Matcher(r.Events)(eventType) {
+		return false
+	}
+	if len(r.Robots) == 0 {
+		return true
+	}
+	for _, uuid := range r.Robots {
+		if uuid == robot {
+			return true
+		}
+	}
+	return false
+}
+
+// QuietHours is a daily window, in a time zone, during which only critical
+// rules push. A window may cross midnight ("22:00" to "07:00").
+type QuietHours struct {
+	Start    string `json:"start"`              // "HH:MM"
+	End      string `json:"end"`                // "HH:MM"
+	Timezone string `json:"timezone,omitempty"` // IANA name; default UTC
+}
+
+// Validate checks the window's times and time zone.
+func (q *QuietHours) Validate() error {
+	if _, err := clock(q.Start); err != nil {
+		return fmt.Errorf("quiet_hours.start: %w", err)
+	}
+	if _, err := clock(q.End); err != nil {
+		return fmt.Errorf("quiet_hours.end: %w", err)
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("quiet_hours.timezone: unknown time zone %q", q.Timezone)
+	}
+	return nil
+}
+
+// Active reports whether t falls inside the window. An invalid window is
+// never active.
+func (q *QuietHours) Active(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	start, err1 := clock(q.Start)
+	end, err2 := clock(q.End)
+	loc, err3 := time.LoadLocation(q.Timezone)
+	if err1 != nil || err2 != nil || err3 != nil || start == end {
+		return false
+	}
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// clock parses "HH:MM" into minutes after midnight.
+func clock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Prefs are one user's notification preferences.
+type Prefs struct {
+	Enabled    bool        `json:"enabled"`
+	Rules      []Rule      `json:"rules"`
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+}
+
+// Validate checks preferences before they are stored.
+func (p *Prefs) Validate() error {
+	if len(p.Rules) > MaxRules {
+		return fmt.Errorf("at most %d rules", MaxRules)
+	}
+	for i, r := range p.Rules {
+		if len(r.Events) == 0 {
+			return fmt.Errorf("rules[%d]: events is required", i)
+		}
+		for _, e := range r.Events {
+			if e == "" || e == "*" {
+				return fmt.Errorf("rules[%d]: event patterns must name a type or prefix", i)
+			}
+		}
+	}
+	if p.QuietHours != nil {
+		return p.QuietHours.Validate()
+	}
+	return nil
+}
+
+// Match returns the index of the first rule covering an event, or -1.
+func (p *Prefs) Match(eventType, robot string) int {
+	for i := range p.Rules {
+		if p.Rules[i].Matches(eventType, robot) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Patterns returns every event pattern of the rules.
+func (p *Prefs) Patterns() []string {
+	var patterns []string
+	for _, r := range p.Rules {
+		patterns = append(patterns, r.Events...)
+	}
+	return patterns
+}
+
+// RobotOf returns the robot an event is about: the "uuid" field of its
+// payload, or "" if it has none.
+func RobotOf(data any) string {
+	if fields := fieldsOf(data); fields != nil {
+		if uuid, ok := fields["uuid"].(string); ok {
+			return uuid
+		}
+	}
+	return ""
+}
+
+// Render fills a title or body template for an event.
+func Render(template, eventType, robot string, data any) string {
+	fields := fieldsOf(data)
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(template, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(template[open:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(template[:open])
+		name := template[open+1 : open+end]
+		switch name {
+		case "type":
+			b.WriteString(eventType)
+		case "robot":
+			b.WriteString(robot)
+		default:
+			if v, ok := fields[name]; ok {
+				b.WriteString(fieldString(v))
+			} else {
+				b.WriteString(template[open : open+end+1])
+			}
+		}
+		template = template[open+end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// fieldsOf returns an event payload's top-level fields, decoding structs
+// through their JSON encoding.
+func fieldsOf(data any) map[string]any {
+	if m, ok := data.(map[string]any); ok {
+		return m
+	}
+	if m, ok := data.(map[string]string); ok {
+		fields := make(map[string]any, len(m))
+		for k, v := range m {
+			fields[k] = v
+		}
+		return fields
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if json.Unmarshal(raw, &fields) != nil {
+		return nil
+	}
+	return fields
+}
+
+func fieldString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
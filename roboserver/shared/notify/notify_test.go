@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2026, 3, 1, hour, min, 0, 0, time.UTC) }
+	overnight := &QuietHours{Start: "22:00", End: "07:00"}
+	daytime := &QuietHours{Start: "09:30", End: "17:00"}
+	cases := []struct {
+		q    *QuietHours
+		t    time.Time
+		want bool
+	}{
+		{overnight, at(23, 0), true},
+		{overnight, at(3, 0), true},
+		{overnight, at(7, 0), false},
+		{overnight, at(12, 0), false},
+		{daytime, at(9, 30), true},
+		{daytime, at(9, 29), false},
+		{daytime, at(17, 0), false},
+		{nil, at(3, 0), false},
+		{&QuietHours{Start: "22:00", End: "07:00", Timezone: "America/New_York"}, at(3, 0), true}, // 22:00 EST
+		{&QuietHours{Start: "22:00", End: "07:00", Timezone: "America/New_York"}, at(12, 0), false},
+	}
+	for i, c := range cases {
+		if got := c.q.Active(c.t); got != c.want {
+			t.Errorf("case %d: Active(%s) = %v, want %v", i, c.t.Format("15:04"), got, c.want)
+		}
+	}
+}
+
+func TestPrefsValidate(t *testing.T) {
+	valid := Prefs{Enabled: true, Rules: []Rule{{Events: []string{"smart_lock.*"}}}, QuietHours: &QuietHours{Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid prefs, got %v", err)
+	}
+	for name, p := range map[string]Prefs{
+		"no events":     {Rules: []Rule{{Title: "x"}}},
+		"catch-all":     {Rules: []Rule{{Events: []string{"*"}}}},
+		"bad start":     {QuietHours: &QuietHours{Start: "25:00", End: "07:00"}},
+		"bad time zone": {QuietHours: &QuietHours{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"}},
+		"too many":      {Rules: make([]Rule, MaxRules+1)},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPrefsMatch(t *testing.T) {
+	p := Prefs{Rules: []Rule{
+		{Events: []string{"smart_lock.*"}, Robots: []string{"front-door"}, Critical: true},
+		{Events: []string{"smart_lock.*", "robot.ip_conflict"}},
+	}}
+	if i := p.Match("smart_lock.front-door.state", "front-door"); i != 0 {
+		t.Errorf("Expected the robot-scoped rule, got %d", i)
+	}
+	if i := p.Match("smart_lock.garage.state", "garage"); i != 1 {
+		t.Errorf("Expected the general rule, got %d", i)
+	}
+	if i := p.Match("robot.heartbeat", "garage"); i != -1 {
+		t.Errorf("Expected no rule, got %d", i)
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := map[string]any{"uuid": "lock-1", "state": "open", "battery": 12}
+	got := Render("{robot} is {state} ({battery}%) {missing} {type}", "smart_lock.lock-1.state", RobotOf(data), data)
+	if want := "lock-1 is open (12%) {missing} smart_lock.lock-1.state"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	type payload struct {
+		UUID string `json:"uuid"`
+	}
+	if robot := RobotOf(payload{UUID: "r2"}); robot != "r2" {
+		t.Errorf("Expected the uuid of a struct payload, got %q", robot)
+	}
+}
+
+func TestDeviceValidate(t *testing.T) {
+	if err := (&Device{Token: "abc", Platform: PlatformAPNs}).Validate(); err != nil {
+		t.Errorf("Expected a valid device, got %v", err)
+	}
+	for _, d := range []Device{{Token: "abc", Platform: "sms"}, {Platform: PlatformFCM}, {Token: "a/b", Platform: PlatformFCM}} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", d)
+		}
+	}
+}
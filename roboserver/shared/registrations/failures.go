@@ -0,0 +1,47 @@
+// Package registrations keeps a log of failed and rejected robot
+// registration attempts, so operators can spot misconfigured or hostile
+// devices. The log is in memory (last MaxFailures attempts, lost on
+// restart); auth.persist_registration_failures also keeps it in Redis.
+package registrations
+
+import (
+	"roboserver/shared/data_structures"
+	"time"
+)
+
+// MaxFailures is how many attempts the in-memory log (and the Redis copy)
+// keeps.
+const MaxFailures = 1000
+
+// Failure is one registration attempt that did not end in a session.
+type Failure struct {
+	DeviceID   string `json:"device_id,omitempty"` // empty if the robot never sent a UUID
+	IP         string `json:"ip"`
+	DeviceType string `json:"device_type,omitempty"`
+	Reason     string `json:"reason"` // protocol error code, e.g. UUID_ALREADY_ACTIVE or REJECTED
+	Time       int64  `json:"time"`   // unix seconds
+}
+
+var failures = data_structures.NewRingBuffer[Failure](MaxFailures)
+
+// Record adds a failure to the in-memory log, stamping Time if unset.
+func Record(f Failure) Failure {
+	if f.Time == 0 {
+		f.Time = time.Now().Unix()
+	}
+	failures.Push(f)
+	return f
+}
+
+// Recent returns up to limit failures, newest first (limit <= 0 = all).
+func Recent(limit int) []Failure {
+	all := failures.Snapshot()
+	out := make([]Failure, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, all[i])
+	}
+	return out
+}
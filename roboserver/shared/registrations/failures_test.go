@@ -0,0 +1,20 @@
+package registrations
+
+import "testing"
+
+func TestRecentNewestFirst(t *testing.T) {
+	Record(Failure{DeviceID: "a", Reason: "REJECTED"})
+	Record(Failure{DeviceID: "b", Reason: "REGISTRATION_TIMEOUT"})
+	Record(Failure{DeviceID: "c", Reason: "INVALID_UUID"})
+
+	got := Recent(2)
+	if len(got) != 2 || got[0].DeviceID != "c" || got[1].DeviceID != "b" {
+		t.Fatalf("Expected [c b], got %+v", got)
+	}
+	if got[0].Time == 0 {
+		t.Error("Expected Record to stamp the time")
+	}
+	if len(Recent(0)) < 3 {
+		t.Error("Expected limit 0 to return every failure")
+	}
+}
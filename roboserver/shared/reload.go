@@ -0,0 +1,95 @@
+package shared
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// configMu guards the AppConfig fields ReloadConfig may change at runtime.
+// Everything else in AppConfig is fixed once LoadConfig returns.
+var configMu sync.RWMutex
+
+// configPath is the file LoadConfig read, re-read by ReloadConfig.
+var configPath string
+
+// AllowedOrigins returns the current CORS/WebSocket origin whitelist.
+func AllowedOrigins() []string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return AppConfig.Server.AllowedOrigins
+}
+
+// LoginLimit returns how many failed logins an IP may make per window.
+func LoginLimit() (maxAttempts int, window time.Duration) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	window, err := time.ParseDuration(AppConfig.Server.LoginWindow)
+	if err != nil || window <= 0 {
+		window = 5 * time.Minute
+	}
+	maxAttempts = AppConfig.Server.LoginMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	return maxAttempts, window
+}
+
+func (t *TimeoutsConfig) RegistrationTimeout() time.Duration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	d, err := time.ParseDuration(t.Registration)
+	if err != nil || d <= 0 {
+		return 5 * time.Minute
+	}
+	return d
+}
+
+// ReloadConfig re-reads the config file and environment and applies the
+// settings that are safe to change on a running server: debug logging and
+// its rate limit, CORS origins, login rate limits and the
+// handshake/registration timeouts. Other changes (ports, databases, TLS, ...)
+// still need a restart. It returns the names of the settings that changed.
+func ReloadConfig() ([]string, error) {
+	next, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	cur := &AppConfig
+	var changed []string
+	if cur.Server.Debug != next.Server.Debug {
+		cur.Server.Debug = next.Server.Debug
+		DEBUG_MODE.Store(next.Server.Debug)
+		changed = append(changed, "server.debug")
+	}
+	if cur.Server.DebugRateLimit != next.Server.DebugRateLimit {
+		cur.Server.DebugRateLimit = next.Server.DebugRateLimit
+		debugRateLimit.Store(int64(next.Server.DebugRateLimit))
+		changed = append(changed, "server.debug_rate_limit")
+	}
+	if !slices.Equal(cur.Server.AllowedOrigins, next.Server.AllowedOrigins) {
+		cur.Server.AllowedOrigins = next.Server.AllowedOrigins
+		changed = append(changed, "server.allowed_origins")
+	}
+	if cur.Server.LoginMaxAttempts != next.Server.LoginMaxAttempts {
+		cur.Server.LoginMaxAttempts = next.Server.LoginMaxAttempts
+		changed = append(changed, "server.login_max_attempts")
+	}
+	if cur.Server.LoginWindow != next.Server.LoginWindow {
+		cur.Server.LoginWindow = next.Server.LoginWindow
+		changed = append(changed, "server.login_window")
+	}
+	if cur.Timeouts.Handshake != next.Timeouts.Handshake {
+		cur.Timeouts.Handshake = next.Timeouts.Handshake
+		changed = append(changed, "timeouts.handshake")
+	}
+	if cur.Timeouts.Registration != next.Timeouts.Registration {
+		cur.Timeouts.Registration = next.Timeouts.Registration
+		changed = append(changed, "timeouts.registration")
+	}
+	return changed, nil
+}
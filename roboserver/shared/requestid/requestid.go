@@ -0,0 +1,43 @@
+// Package requestid carries the ID of the HTTP request behind a piece of
+// work, so log lines from the API, SSE streams and the access log can be
+// matched up. Callers may supply their own ID in the X-Request-ID header;
+// otherwise one is generated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// Header is the request and response header carrying the ID.
+const Header = "X-Request-ID"
+
+// validRe limits caller-supplied IDs to what is safe to log and echo.
+var validRe = regexp.MustCompile(`^[a-zA-Z0-9._:/+=-]{1,128}$`)
+
+type ctxKey struct{}
+
+// New returns a random 32-character hex ID.
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether a caller-supplied ID can be used as is.
+func Valid(id string) bool {
+	return validRe.MatchString(id)
+}
+
+// With returns ctx carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// From returns the ID ctx carries, or "".
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
@@ -0,0 +1,40 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	if len(a) != 32 || !Valid(a) {
+		t.Errorf("New() = %q, want 32 valid characters", a)
+	}
+	if a == b {
+		t.Error("Expected two IDs to differ")
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, id := range []string{"abc", "7f3c9a2e-1b4d-4c8e-9f00-123456789abc", "lb-01:42/7", strings.Repeat("a", 128)} {
+		if !Valid(id) {
+			t.Errorf("Valid(%q) = false", id)
+		}
+	}
+	for _, id := range []string{"", strings.Repeat("a", 129), "two words", "x\ny", `"quoted"`} {
+		if Valid(id) {
+			t.Errorf("Valid(%q) = true", id)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if From(ctx) != "" {
+		t.Error("Expected no ID on a bare context")
+	}
+	if got := From(With(ctx, "req-1")); got != "req-1" {
+		t.Errorf("From(With(ctx, req-1)) = %q", got)
+	}
+}
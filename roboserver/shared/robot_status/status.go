@@ -0,0 +1,151 @@
+// Package robot_status defines the robot lifecycle states and the
+// transitions allowed between them:
+//
+//	registering → online ⇄ busy
+//	any → offline, any → error, offline/error → online | registering
+//
+// The server drives registering/online/offline from connection events;
+// handlers report busy, online (idle again) and error.
+package robot_status
+
+import (
+	"errors"
+	"fmt"
+	"roboserver/shared/events"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RobotStatus uint8
+
+const (
+	Unknown RobotStatus = iota // never seen by this instance
+	Registering
+	Online
+	Busy
+	Offline
+	Error
+)
+
+var names = [...]string{"unknown", "registering", "online", "busy", "offline", "error"}
+
+// aliases are legacy names still accepted by Parse.
+var aliases = map[string]RobotStatus{"connected": Online, "active": Online}
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+func (s RobotStatus) String() string {
+	if int(s) < len(names) {
+		return names[s]
+	}
+	return fmt.Sprintf("RobotStatus(%d)", s)
+}
+
+// Parse converts a status name (case-insensitive) to a RobotStatus.
+func Parse(name string) (RobotStatus, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range names {
+		if n == name {
+			return RobotStatus(i), nil
+		}
+	}
+	if s, ok := aliases[name]; ok {
+		return s, nil
+	}
+	return Unknown, fmt.Errorf("unknown robot status %q", name)
+}
+
+func (s RobotStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *RobotStatus) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// CanTransitionTo reports whether moving from s to next is allowed. Staying
+// in the same state is not a transition.
+func (s RobotStatus) CanTransitionTo(next RobotStatus) bool {
+	if next == Unknown || next == s {
+		return false
+	}
+	switch s {
+	case Unknown:
+		return true
+	case Registering:
+		return next == Online || next == Offline || next == Error
+	case Online:
+		return next == Busy || next == Offline || next == Error
+	case Busy:
+		return next == Online || next == Offline || next == Error
+	case Offline, Error:
+		return next == Online || next == Registering || next == Offline || next == Error
+	}
+	return false
+}
+
+// Transition is published on robot.{uuid}.status whenever a status changes.
+type Transition struct {
+	UUID   string      `json:"uuid"`
+	From   RobotStatus `json:"from"`
+	To     RobotStatus `json:"to"`
+	Reason string      `json:"reason,omitempty"`
+	Time   int64       `json:"time"`
+	// Maintenance is set while the robot is in maintenance mode, so
+	// monitoring can mute offline/error alerts for it.
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotStatus("{uuid}"),
+		Description: "A robot's status changed. from and to are unknown, registering, online, busy, offline or error.",
+		Example:     Transition{UUID: "rover-7", From: Online, To: Busy, Reason: "handler busy", Time: 1718000000},
+	})
+}
+
+func EventType(uuid string) string {
+	return events.RobotStatus(uuid)
+}
+
+// Tracker_t holds the current status of each robot seen by this instance.
+type Tracker_t struct {
+	mu       sync.RWMutex
+	statuses map[string]RobotStatus
+}
+
+// Tracker is the process-wide status table.
+var Tracker = NewTracker()
+
+func NewTracker() *Tracker_t {
+	return &Tracker_t{statuses: make(map[string]RobotStatus)}
+}
+
+func (t *Tracker_t) Get(uuid string) RobotStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.statuses[uuid]
+}
+
+// Set moves a robot to a new status. It returns the transition to publish,
+// nil if the robot is already in that status, or ErrInvalidTransition.
+func (t *Tracker_t) Set(uuid string, to RobotStatus, reason string) (*Transition, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	from := t.statuses[uuid]
+	if from == to {
+		return nil, nil
+	}
+	if !from.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+	}
+	t.statuses[uuid] = to
+	return &Transition{UUID: uuid, From: from, To: to, Reason: reason, Time: time.Now().Unix()}, nil
+}
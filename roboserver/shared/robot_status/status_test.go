@@ -0,0 +1,70 @@
+package robot_status
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseAndJSON(t *testing.T) {
+	for name, want := range map[string]RobotStatus{"online": Online, "BUSY": Busy, "connected": Online, "active": Online} {
+		got, err := Parse(name)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := Parse("sleeping"); err == nil {
+		t.Error("Expected error for unknown status")
+	}
+
+	data, _ := json.Marshal(Transition{UUID: "r1", From: Online, To: Busy})
+	var decoded map[string]any
+	json.Unmarshal(data, &decoded)
+	if decoded["from"] != "online" || decoded["to"] != "busy" {
+		t.Errorf("Expected statuses marshaled as names, got %s", data)
+	}
+
+	var s RobotStatus
+	if err := json.Unmarshal([]byte(`"offline"`), &s); err != nil || s != Offline {
+		t.Errorf("Expected offline, got %v (%v)", s, err)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	allowed := [][2]RobotStatus{
+		{Unknown, Registering}, {Registering, Online}, {Online, Busy}, {Busy, Online},
+		{Busy, Offline}, {Online, Error}, {Error, Online}, {Offline, Online}, {Offline, Registering},
+	}
+	for _, tr := range allowed {
+		if !tr[0].CanTransitionTo(tr[1]) {
+			t.Errorf("Expected %s → %s to be allowed", tr[0], tr[1])
+		}
+	}
+	denied := [][2]RobotStatus{
+		{Registering, Busy}, {Offline, Busy}, {Online, Registering}, {Online, Online}, {Online, Unknown},
+	}
+	for _, tr := range denied {
+		if tr[0].CanTransitionTo(tr[1]) {
+			t.Errorf("Expected %s → %s to be rejected", tr[0], tr[1])
+		}
+	}
+}
+
+func TestTrackerSet(t *testing.T) {
+	tr := NewTracker()
+
+	got, err := tr.Set("r1", Online, "connected")
+	if err != nil || got == nil || got.From != Unknown || got.To != Online || got.Reason != "connected" {
+		t.Fatalf("Unexpected first transition: %+v, %v", got, err)
+	}
+	if got, err := tr.Set("r1", Online, "again"); got != nil || err != nil {
+		t.Errorf("Expected no-op for same status, got %+v, %v", got, err)
+	}
+	tr.Set("r1", Offline, "tcp_closed")
+	if _, err := tr.Set("r1", Busy, "handler"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition for offline → busy, got %v", err)
+	}
+	if tr.Get("r1") != Offline {
+		t.Errorf("Expected status unchanged after rejected transition, got %s", tr.Get("r1"))
+	}
+}
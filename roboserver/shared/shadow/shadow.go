@@ -0,0 +1,184 @@
+// Package shadow keeps a virtual copy ("shadow") of each robot's state:
+// the state operators want (desired) next to the state the robot last
+// reported. The difference between them is the delta, which is pushed to
+// the robot's handler when desired state changes and again whenever the
+// robot connects, so changes made while a robot is offline are applied
+// once it is back.
+//
+// Both documents are JSON objects updated with merge patches: fields in a
+// patch replace the stored ones, nested objects merge, and null removes a
+// field. Redis (robot:{uuid}:shadow) is the source of truth.
+package shadow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"roboserver/shared/events"
+)
+
+// ErrVersionConflict is returned for an update made against a version the
+// shadow has since moved past.
+var ErrVersionConflict = errors.New("shadow version changed")
+
+// MaxDepth bounds how deeply a shadow's objects may nest.
+const MaxDepth = 8
+
+// Sources of a shadow update.
+const (
+	SourceDesired  = "desired"  // an operator changed desired state
+	SourceReported = "reported" // the robot (its handler) reported state
+	SourceDeleted  = "deleted"  // the shadow was removed
+)
+
+// Shadow is a robot's desired and reported state.
+type Shadow struct {
+	UUID     string         `json:"uuid"`
+	Desired  map[string]any `json:"desired"`
+	Reported map[string]any `json:"reported"`
+	// Version grows by one with every update, so a client can make a
+	// change conditional on the version it read.
+	Version    int64 `json:"version"`
+	DesiredAt  int64 `json:"desired_at,omitempty"`  // Unix seconds
+	ReportedAt int64 `json:"reported_at,omitempty"` // Unix seconds
+}
+
+// New returns an empty shadow for uuid.
+func New(uuid string) *Shadow {
+	return &Shadow{UUID: uuid, Desired: map[string]any{}, Reported: map[string]any{}}
+}
+
+// Delta returns the desired fields the reported state doesn't match yet,
+// or nil when the robot is in sync.
+func (s *Shadow) Delta() map[string]any {
+	return Delta(s.Desired, s.Reported)
+}
+
+// Event is published on robot.{uuid}.shadow after every update.
+type Event struct {
+	UUID    string         `json:"uuid"`
+	Source  string         `json:"source"`
+	Version int64          `json:"version"`
+	Delta   map[string]any `json:"delta,omitempty"`
+	Shadow  *Shadow        `json:"shadow,omitempty"`
+}
+
+func init() {
+	s := &Shadow{UUID: "thermo-3", Desired: map[string]any{"target_c": 21.5}, Reported: map[string]any{"target_c": 19.0}, Version: 7, DesiredAt: 1718000000, ReportedAt: 1717999000}
+	events.Describe(events.Info{
+		Type:        events.RobotShadow("{uuid}"),
+		Description: "A robot's shadow changed: an operator updated desired state (source desired) or the robot reported state (source reported). delta is what the robot has yet to apply.",
+		Example:     Event{UUID: s.UUID, Source: SourceDesired, Version: s.Version, Delta: s.Delta(), Shadow: s},
+	})
+}
+
+// DecodeEvent reads an Event published locally or relayed from another
+// node (where it arrives as decoded JSON).
+func DecodeEvent(data any) (Event, bool) {
+	switch v := data.(type) {
+	case Event:
+		return v, true
+	case *Event:
+		if v == nil {
+			return Event{}, false
+		}
+		return *v, true
+	case map[string]any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return Event{}, false
+		}
+		var ev Event
+		if json.Unmarshal(raw, &ev) != nil || ev.UUID == "" {
+			return Event{}, false
+		}
+		return ev, true
+	}
+	return Event{}, false
+}
+
+// Validate checks that a patch is a JSON object of bounded depth.
+func Validate(patch map[string]any) error {
+	if patch == nil {
+		return fmt.Errorf("state must be a JSON object")
+	}
+	if depth(patch) > MaxDepth {
+		return fmt.Errorf("state nests deeper than %d levels", MaxDepth)
+	}
+	return nil
+}
+
+func depth(v any) int {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return 0
+	}
+	deepest := 0
+	for _, child := range m {
+		deepest = max(deepest, depth(child))
+	}
+	return deepest + 1
+}
+
+// Merge applies a merge patch to doc in place and returns it (a new map if
+// doc is nil). Null fields are removed; objects merge recursively; any other
+// value replaces the stored one.
+func Merge(doc, patch map[string]any) map[string]any {
+	if doc == nil {
+		doc = make(map[string]any, len(patch))
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		if sub, ok := v.(map[string]any); ok {
+			existing, _ := doc[k].(map[string]any)
+			doc[k] = Merge(existing, sub)
+			continue
+		}
+		doc[k] = v
+	}
+	return doc
+}
+
+// Delta returns the fields of desired whose value differs from reported,
+// descending into objects present in both. Fields only in reported are
+// ignored. It returns nil when there is no difference.
+func Delta(desired, reported map[string]any) map[string]any {
+	var delta map[string]any
+	for k, want := range desired {
+		have, ok := reported[k]
+		if wantObj, isObj := want.(map[string]any); isObj && ok {
+			if haveObj, isObj := have.(map[string]any); isObj {
+				if sub := Delta(wantObj, haveObj); sub != nil {
+					if delta == nil {
+						delta = map[string]any{}
+					}
+					delta[k] = sub
+				}
+				continue
+			}
+		}
+		if ok && equal(want, have) {
+			continue
+		}
+		if delta == nil {
+			delta = map[string]any{}
+		}
+		delta[k] = want
+	}
+	return delta
+}
+
+// equal compares JSON values, treating numbers of any Go type alike (a
+// patch decoded from JSON holds float64, one built in Go may hold int).
+func equal(a, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	ra, errA := json.Marshal(a)
+	rb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ra) == string(rb)
+}
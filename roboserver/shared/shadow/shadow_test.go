@@ -0,0 +1,77 @@
+package shadow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	doc := map[string]any{"mode": "auto", "fan": 2.0, "led": map[string]any{"color": "red", "level": 5.0}}
+	Merge(doc, map[string]any{"fan": nil, "led": map[string]any{"color": "blue"}, "target_c": 21.5})
+	want := map[string]any{"mode": "auto", "led": map[string]any{"color": "blue", "level": 5.0}, "target_c": 21.5}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("Merge = %v, want %v", doc, want)
+	}
+
+	if got := Merge(nil, map[string]any{"a": 1.0, "b": nil}); !reflect.DeepEqual(got, map[string]any{"a": 1.0}) {
+		t.Errorf("Merge into nil = %v", got)
+	}
+}
+
+func TestDelta(t *testing.T) {
+	desired := map[string]any{
+		"target_c": 21.5,
+		"mode":     "eco",
+		"speed":    3, // set from Go, reported from JSON
+		"led":      map[string]any{"color": "blue", "level": 5.0},
+		"zones":    []any{"a", "b"},
+	}
+	reported := map[string]any{
+		"target_c": 19.0,
+		"mode":     "eco",
+		"speed":    3.0,
+		"led":      map[string]any{"color": "red", "level": 5.0},
+		"zones":    []any{"a", "b"},
+		"battery":  80.0,
+	}
+	want := map[string]any{"target_c": 21.5, "led": map[string]any{"color": "blue"}}
+	if got := Delta(desired, reported); !reflect.DeepEqual(got, want) {
+		t.Errorf("Delta = %v, want %v", got, want)
+	}
+
+	if got := Delta(map[string]any{"mode": "eco"}, map[string]any{"mode": "eco"}); got != nil {
+		t.Errorf("Expected nil delta when in sync, got %v", got)
+	}
+	if got := Delta(map[string]any{"led": map[string]any{"on": true}}, map[string]any{"led": "off"}); !reflect.DeepEqual(got, map[string]any{"led": map[string]any{"on": true}}) {
+		t.Errorf("Expected a whole object when reported isn't one, got %v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(nil); err == nil {
+		t.Error("Expected an error for a missing object")
+	}
+	deep := map[string]any{}
+	node := deep
+	for range MaxDepth {
+		child := map[string]any{}
+		node["x"] = child
+		node = child
+	}
+	if err := Validate(deep); err == nil {
+		t.Errorf("Expected an error past %d levels", MaxDepth)
+	}
+	if err := Validate(map[string]any{"a": map[string]any{"b": 1.0}}); err != nil {
+		t.Errorf("Expected a shallow patch to pass, got %v", err)
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	ev, ok := DecodeEvent(map[string]any{"uuid": "r1", "source": SourceDesired, "version": 4.0, "delta": map[string]any{"on": true}})
+	if !ok || ev.UUID != "r1" || ev.Version != 4 || ev.Delta["on"] != true {
+		t.Errorf("DecodeEvent = %+v, %v", ev, ok)
+	}
+	if _, ok := DecodeEvent("nope"); ok {
+		t.Error("Expected a non-event payload to be rejected")
+	}
+}
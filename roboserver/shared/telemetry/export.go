@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Reading is one metric of one envelope, the row of an export.
+type Reading struct {
+	Timestamp int64 // Unix milliseconds on the robot's clock
+	Type      string
+	Seq       uint64
+	Metric    string
+	Value     float64
+}
+
+// Readings flattens the envelopes of envType ("" = any type) with
+// from <= timestamp < to (Unix ms; 0 leaves that end open) into readings,
+// oldest first and metrics by name within an envelope.
+func Readings(envs []Envelope, envType string, from, to int64) []Reading {
+	kept := make([]Envelope, 0, len(envs))
+	n := 0
+	for _, env := range envs {
+		if envType != "" && env.Type != envType {
+			continue
+		}
+		if env.Timestamp < from || (to > 0 && env.Timestamp >= to) {
+			continue
+		}
+		kept = append(kept, env)
+		n += len(env.Metrics)
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Timestamp < kept[j].Timestamp })
+
+	out := make([]Reading, 0, n)
+	for _, env := range kept {
+		start := len(out)
+		for metric, v := range env.Metrics {
+			out = append(out, Reading{Timestamp: env.Timestamp, Type: env.Type, Seq: env.Seq, Metric: metric, Value: v})
+		}
+		added := out[start:]
+		sort.Slice(added, func(i, j int) bool { return added[i].Metric < added[j].Metric })
+	}
+	return out
+}
+
+// WriteCSV writes readings as CSV with a header row. time repeats the
+// timestamp as RFC 3339 in UTC for spreadsheets.
+func WriteCSV(w io.Writer, rows []Reading) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "time", "type", "seq", "metric", "value"})
+	for _, r := range rows {
+		cw.Write([]string{
+			strconv.FormatInt(r.Timestamp, 10),
+			time.UnixMilli(r.Timestamp).UTC().Format(time.RFC3339Nano),
+			r.Type,
+			strconv.FormatUint(r.Seq, 10),
+			r.Metric,
+			strconv.FormatFloat(r.Value, 'g', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
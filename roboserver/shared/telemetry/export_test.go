@@ -0,0 +1,156 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+var exportEnvs = []Envelope{ // newest first, as stored
+	{Type: "env", Metrics: map[string]float64{"temp_c": 22, "humidity": 41}, Timestamp: 3000, Seq: 3},
+	{Type: "battery", Metrics: map[string]float64{"volts": 12.1}, Timestamp: 2000, Seq: 2},
+	{Type: "env", Metrics: map[string]float64{"temp_c": 21.5, "humidity": 40}, Timestamp: 1000, Seq: 1},
+}
+
+func TestReadings(t *testing.T) {
+	rows := Readings(exportEnvs, "", 0, 0)
+	if len(rows) != 5 {
+		t.Fatalf("Expected 5 readings, got %+v", rows)
+	}
+	if rows[0] != (Reading{Timestamp: 1000, Type: "env", Seq: 1, Metric: "humidity", Value: 40}) || rows[4].Metric != "temp_c" || rows[4].Timestamp != 3000 {
+		t.Errorf("Expected readings oldest first, metrics by name: %+v", rows)
+	}
+
+	rows = Readings(exportEnvs, "env", 1000, 3000)
+	if len(rows) != 2 || rows[0].Timestamp != 1000 || rows[1].Timestamp != 1000 {
+		t.Errorf("Expected from to be inclusive, to exclusive and type filtered: %+v", rows)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, Readings(exportEnvs, "battery", 0, 0)); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	want := "timestamp,time,type,seq,metric,value\n2000,1970-01-01T00:00:02Z,battery,2,volts,12.1\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected CSV:\n%s", buf.String())
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	rows := Readings(exportEnvs, "", 0, 0)
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, rows); err != nil {
+		t.Fatalf("WriteParquet failed: %v", err)
+	}
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("Missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := readCompactStruct(t, bytes.NewReader(file[len(file)-8-footerLen:len(file)-8]))
+
+	if meta[3] != int64(len(rows)) {
+		t.Errorf("Expected num_rows %d, got %v", len(rows), meta[3])
+	}
+	var names []string
+	for _, el := range meta[2].([]any)[1:] {
+		names = append(names, string(el.(map[int16]any)[4].([]byte)))
+	}
+	if strings.Join(names, ",") != "timestamp,type,seq,metric,value" {
+		t.Errorf("Unexpected schema: %v", names)
+	}
+
+	group := meta[4].([]any)[0].(map[int16]any)
+	columns := group[1].([]any)
+	for i, c := range columns {
+		colMeta := c.(map[int16]any)[3].(map[int16]any)
+		offset := colMeta[9].(int64)
+		r := bytes.NewReader(file[offset:])
+		page := readCompactStruct(t, r)
+		if page[5].(map[int16]any)[1] != int64(len(rows)) {
+			t.Errorf("Column %s: expected %d values, got %v", names[i], len(rows), page)
+		}
+		values := make([]byte, page[3].(int64))
+		r.Read(values)
+		switch names[i] {
+		case "value":
+			if v := math.Float64frombits(binary.LittleEndian.Uint64(values[8:])); v != rows[1].Value {
+				t.Errorf("Expected second value %v, got %v", rows[1].Value, v)
+			}
+		case "metric":
+			if n := binary.LittleEndian.Uint32(values); string(values[4:4+n]) != rows[0].Metric {
+				t.Errorf("Expected first metric %q, got %q", rows[0].Metric, values[4:4+n])
+			}
+		}
+	}
+}
+
+func TestWriteParquetEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, nil); err != nil {
+		t.Fatalf("WriteParquet failed: %v", err)
+	}
+	file := buf.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := readCompactStruct(t, bytes.NewReader(file[len(file)-8-footerLen:len(file)-8]))
+	if meta[3] != int64(0) || len(meta[4].([]any)) != 0 {
+		t.Errorf("Expected no rows and no row groups, got %v", meta)
+	}
+}
+
+// readCompactStruct decodes a Thrift compact struct into field id → value:
+// int64 for integers, []byte for binary, []any for lists and
+// map[int16]any for structs.
+func readCompactStruct(t *testing.T, r *bytes.Reader) map[int16]any {
+	t.Helper()
+	out := map[int16]any{}
+	var id int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("Truncated struct: %v", err)
+		}
+		if b == 0 {
+			return out
+		}
+		if d := int16(b >> 4); d != 0 {
+			id += d
+		} else {
+			v, _ := binary.ReadVarint(r)
+			id = int16(v)
+		}
+		out[id] = readCompactValue(t, r, b&0x0f)
+	}
+}
+
+func readCompactValue(t *testing.T, r *bytes.Reader, typ byte) any {
+	switch typ {
+	case 5, 6:
+		v, _ := binary.ReadVarint(r)
+		return v
+	case 8:
+		n, _ := binary.ReadUvarint(r)
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	case 9:
+		h, _ := r.ReadByte()
+		n := uint64(h >> 4)
+		if n == 15 {
+			n, _ = binary.ReadUvarint(r)
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = readCompactValue(t, r, h&0x0f)
+		}
+		return list
+	case 12:
+		return readCompactStruct(t, r)
+	}
+	t.Fatalf("Unexpected compact type %d", typ)
+	return nil
+}
@@ -0,0 +1,261 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Parquet physical and converted types, encodings and page types used by
+// WriteParquet (see parquet-format's parquet.thrift).
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetRequired = 0
+	parquetPlain    = 0
+	parquetRLE      = 3
+	parquetDataPage = 0
+)
+
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32 // -1 = none
+	values    func(rows []Reading) []byte
+}
+
+// parquetColumns are the columns of an export, in the order of WriteCSV's
+// (without the redundant time column).
+var parquetColumns = []parquetColumn{
+	{"timestamp", parquetInt64, parquetTimestampMillis, func(rows []Reading) []byte {
+		buf := make([]byte, 0, 8*len(rows))
+		for _, r := range rows {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(r.Timestamp))
+		}
+		return buf
+	}},
+	{"type", parquetByteArray, parquetUTF8, func(rows []Reading) []byte {
+		var buf []byte
+		for _, r := range rows {
+			buf = appendParquetString(buf, r.Type)
+		}
+		return buf
+	}},
+	{"seq", parquetInt64, -1, func(rows []Reading) []byte {
+		buf := make([]byte, 0, 8*len(rows))
+		for _, r := range rows {
+			buf = binary.LittleEndian.AppendUint64(buf, r.Seq)
+		}
+		return buf
+	}},
+	{"metric", parquetByteArray, parquetUTF8, func(rows []Reading) []byte {
+		var buf []byte
+		for _, r := range rows {
+			buf = appendParquetString(buf, r.Metric)
+		}
+		return buf
+	}},
+	{"value", parquetDouble, -1, func(rows []Reading) []byte {
+		buf := make([]byte, 0, 8*len(rows))
+		for _, r := range rows {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(r.Value))
+		}
+		return buf
+	}},
+}
+
+func appendParquetString(buf []byte, s string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+// WriteParquet writes readings as an uncompressed Parquet file: one row
+// group with a single PLAIN-encoded data page per column, all columns
+// required. Columns are written one at a time, so only one is held in
+// memory besides rows.
+func WriteParquet(w io.Writer, rows []Reading) error {
+	cw := &countingWriter{w: w}
+	cw.Write([]byte("PAR1"))
+
+	type chunk struct{ offset, size int64 }
+	var chunks []chunk
+	if len(rows) > 0 {
+		for _, col := range parquetColumns {
+			values := col.values(rows)
+			header := parquetPageHeader(len(rows), len(values))
+			offset := cw.n
+			cw.Write(header)
+			cw.Write(values)
+			chunks = append(chunks, chunk{offset, int64(len(header) + len(values))})
+		}
+	}
+
+	// FileMetaData
+	var t compactWriter
+	t.begin()
+	t.i32(1, 1) // version
+	t.list(2, thriftStruct, len(parquetColumns)+1)
+	t.begin() // schema root
+	t.binary(4, "reading")
+	t.i32(5, int32(len(parquetColumns)))
+	t.end()
+	for _, col := range parquetColumns {
+		t.begin()
+		t.i32(1, col.physical)
+		t.i32(3, parquetRequired)
+		t.binary(4, col.name)
+		if col.converted >= 0 {
+			t.i32(6, col.converted)
+		}
+		t.end()
+	}
+	t.i64(3, int64(len(rows)))
+	if len(chunks) == 0 {
+		t.list(4, thriftStruct, 0)
+	} else {
+		t.list(4, thriftStruct, 1)
+		t.begin() // RowGroup
+		t.list(1, thriftStruct, len(chunks))
+		var total int64
+		for i, col := range parquetColumns {
+			c := chunks[i]
+			total += c.size
+			t.begin() // ColumnChunk
+			t.i64(2, c.offset)
+			t.field(3, thriftStruct)
+			t.begin() // ColumnMetaData
+			t.i32(1, col.physical)
+			t.list(2, thriftI32, 2)
+			t.appendI32(parquetPlain)
+			t.appendI32(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.appendBinary(col.name)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, int64(len(rows)))
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, c.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, total)
+		t.i64(3, int64(len(rows)))
+		t.end()
+	}
+	t.binary(6, "roboserver")
+	t.end()
+
+	cw.Write(t.buf)
+	cw.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(t.buf))))
+	cw.Write([]byte("PAR1"))
+	return cw.err
+}
+
+// parquetPageHeader encodes the PageHeader of a PLAIN data page without
+// definition or repetition levels.
+func parquetPageHeader(numValues, size int) []byte {
+	var t compactWriter
+	t.begin()
+	t.i32(1, parquetDataPage)
+	t.i32(2, int32(size)) // uncompressed_page_size
+	t.i32(3, int32(size)) // compressed_page_size
+	t.field(5, thriftStruct)
+	t.begin() // DataPageHeader
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetPlain)
+	t.i32(3, parquetRLE)
+	t.i32(4, parquetRLE)
+	t.end()
+	t.end()
+	return t.buf
+}
+
+// Thrift compact protocol field types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// compactWriter encodes the Thrift compact protocol, enough for Parquet
+// metadata. begin and end bracket every struct, including the outermost.
+type compactWriter struct {
+	buf  []byte
+	last []int16 // last field id written in each open struct
+}
+
+func (t *compactWriter) begin() { t.last = append(t.last, 0) }
+
+func (t *compactWriter) end() {
+	t.buf = append(t.buf, 0) // stop field
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *compactWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		t.buf = append(t.buf, byte(d)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	*last = id
+}
+
+func (t *compactWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.appendI32(v)
+}
+
+func (t *compactWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v) // zigzag, as compact i64s are
+}
+
+func (t *compactWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.appendBinary(s)
+}
+
+// list starts a list field of n elements, which follow as appendI32,
+// appendBinary or begin/end calls.
+func (t *compactWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xf0|elem)
+		t.buf = binary.AppendUvarint(t.buf, uint64(n))
+	}
+}
+
+func (t *compactWriter) appendI32(v int32) { t.buf = binary.AppendVarint(t.buf, int64(v)) }
+
+func (t *compactWriter) appendBinary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// countingWriter tracks the file offset and keeps the first write error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,97 @@
+// Package telemetry defines the envelope robots use to report measurements
+// with the TCP "DATA <json>" command:
+//
+//	DATA {"type":"env","metrics":{"temp_c":21.5,"humidity":40},"timestamp":1718000000000,"seq":42}
+//
+// Every robot type reports the same shape, so the server can route it to the
+// handler, the sensor store and the event bus without knowing the robot.
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"roboserver/shared/events"
+	"time"
+)
+
+// MaxMetrics bounds the metrics in one envelope.
+const MaxMetrics = 256
+
+var typeRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ErrInvalid wraps every validation error from Parse.
+var ErrInvalid = errors.New("invalid telemetry")
+
+// Envelope is one telemetry report. Timestamp is Unix milliseconds on the
+// robot's clock (filled with the server time when omitted); Seq is a
+// per-connection counter the robot increments, 0 meaning "not sequenced".
+type Envelope struct {
+	Type      string             `json:"type"`
+	Metrics   map[string]float64 `json:"metrics"`
+	Timestamp int64              `json:"timestamp"`
+	Seq       uint64             `json:"seq,omitempty"`
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotTelemetry("{uuid}"),
+		Description: "A validated DATA report from a robot. timestamp is Unix milliseconds on the robot's clock.",
+		Example:     Envelope{Type: "env", Metrics: map[string]float64{"temp_c": 21.5, "humidity": 40}, Timestamp: 1718000000000, Seq: 42},
+	})
+}
+
+// Parse decodes and validates an envelope.
+func Parse(data []byte) (Envelope, error) {
+	return ParseSynced(data, 0)
+}
+
+// ParseSynced is Parse for a robot whose clock is offsetMs ahead of the
+// server's (see shared/timesync): a timestamp the robot sent is moved onto
+// the server clock.
+func ParseSynced(data []byte, offsetMs int64) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !typeRe.MatchString(env.Type) {
+		return Envelope{}, fmt.Errorf("%w: type must be 1-64 letters, digits, hyphens or underscores", ErrInvalid)
+	}
+	if len(env.Metrics) == 0 || len(env.Metrics) > MaxMetrics {
+		return Envelope{}, fmt.Errorf("%w: metrics must have 1-%d entries", ErrInvalid, MaxMetrics)
+	}
+	for name, v := range env.Metrics {
+		if name == "" || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Envelope{}, fmt.Errorf("%w: metric %q is not a finite number", ErrInvalid, name)
+		}
+	}
+	if env.Timestamp < 0 {
+		return Envelope{}, fmt.Errorf("%w: timestamp must be Unix milliseconds", ErrInvalid)
+	}
+	if env.Timestamp == 0 {
+		env.Timestamp = time.Now().UnixMilli()
+	} else {
+		env.Timestamp -= offsetMs
+	}
+	return env, nil
+}
+
+// Sequencer drops duplicate and out-of-order envelopes on one connection.
+// Envelopes without a seq are always accepted.
+type Sequencer struct {
+	last uint64
+}
+
+// Accept reports whether env is new, and records its seq.
+func (s *Sequencer) Accept(env Envelope) bool {
+	if env.Seq == 0 {
+		return true
+	}
+	if env.Seq <= s.last {
+		return false
+	}
+	s.last = env.Seq
+	return true
+}
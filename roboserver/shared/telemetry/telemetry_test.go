@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	env, err := Parse([]byte(`{"type":"env","metrics":{"temp_c":21.5,"humidity":40},"timestamp":1718000000000,"seq":7}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if env.Type != "env" || env.Metrics["temp_c"] != 21.5 || env.Timestamp != 1718000000000 || env.Seq != 7 {
+		t.Errorf("Unexpected envelope: %+v", env)
+	}
+}
+
+func TestParseFillsTimestamp(t *testing.T) {
+	env, err := Parse([]byte(`{"type":"battery","metrics":{"volts":12.1}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if env.Timestamp <= 0 {
+		t.Errorf("Expected a server timestamp, got %d", env.Timestamp)
+	}
+}
+
+func TestParseSynced(t *testing.T) {
+	env, err := ParseSynced([]byte(`{"type":"env","metrics":{"x":1},"timestamp":1718000005000}`), 5000)
+	if err != nil {
+		t.Fatalf("ParseSynced failed: %v", err)
+	}
+	if env.Timestamp != 1718000000000 {
+		t.Errorf("Expected the timestamp on the server clock, got %d", env.Timestamp)
+	}
+
+	before := time.Now().UnixMilli()
+	env, err = ParseSynced([]byte(`{"type":"env","metrics":{"x":1}}`), 5000)
+	if err != nil {
+		t.Fatalf("ParseSynced failed: %v", err)
+	}
+	if env.Timestamp < before {
+		t.Errorf("Expected a filled server timestamp to be left alone, got %d", env.Timestamp)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`not json`,
+		`{"metrics":{"x":1}}`,
+		`{"type":"a.b","metrics":{"x":1}}`,
+		`{"type":"env","metrics":{}}`,
+		`{"type":"env","metrics":{"x":"warm"}}`,
+		`{"type":"env","metrics":{"x":1},"timestamp":-5}`,
+	} {
+		if _, err := Parse([]byte(raw)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected ErrInvalid for %s, got %v", raw, err)
+		}
+	}
+}
+
+func TestSequencer(t *testing.T) {
+	var s Sequencer
+	for _, tc := range []struct {
+		seq  uint64
+		want bool
+	}{{1, true}, {2, true}, {2, false}, {1, false}, {0, true}, {5, true}, {3, false}} {
+		if got := s.Accept(Envelope{Seq: tc.seq}); got != tc.want {
+			t.Errorf("Accept(seq %d) = %v, want %v", tc.seq, got, tc.want)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte(`{"type":"env","metrics":{"temp_c":21.5},"timestamp":1718000000000,"seq":7}`))
+	f.Add([]byte(`{"type":"env","metrics":{"x":1e309}}`))
+	f.Add([]byte(`{"type":"../etc","metrics":{"x":1}}`))
+	f.Add([]byte(`{"type":"env","metrics":{"":1},"timestamp":-1}`))
+	f.Add([]byte(`[]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		env, err := Parse(data)
+		if err != nil {
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("error %v does not wrap ErrInvalid", err)
+			}
+			return
+		}
+		// A valid envelope survives a round trip unchanged.
+		raw, err := json.Marshal(env)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		again, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("re-parsing %s failed: %v", raw, err)
+		}
+		if again.Type != env.Type || again.Timestamp != env.Timestamp || again.Seq != env.Seq || len(again.Metrics) != len(env.Metrics) {
+			t.Fatalf("round trip changed the envelope: %+v != %+v", again, env)
+		}
+	})
+}
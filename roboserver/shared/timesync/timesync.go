@@ -0,0 +1,108 @@
+// Package timesync estimates how far each robot's clock is from the
+// server's, so timestamps robots report can be moved onto the server clock.
+//
+// A robot asks for the time with its own clock reading t1 (Unix ms); the
+// server answers with t1, the time it received the request (t2) and the
+// time it replied (t3), as in SNTP. The robot can set its clock from that
+// reply; the server meanwhile records one offset sample
+//
+//	offset = t1 + rtt/2 - t2
+//
+// where rtt is the round trip the robot measured on its previous request,
+// or else the server's own PING estimate. Samples are smoothed per robot.
+package timesync
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Smoothing is the weight of a new sample in the running offset.
+const Smoothing = 0.25
+
+// StepThreshold is how far a sample may be from the running offset before
+// it replaces it instead of being averaged in: the robot's clock was set.
+const StepThreshold = 5 * time.Second
+
+// Reply answers a time request:
+//
+//	{"t1":1718000000000,"t2":1718000000412,"t3":1718000000413,"offset_ms":-411}
+//
+// offset_ms is the server's current estimate of the robot's clock minus
+// its own, after this request.
+type Reply struct {
+	T1       int64 `json:"t1"`
+	T2       int64 `json:"t2"`
+	T3       int64 `json:"t3"`
+	OffsetMs int64 `json:"offset_ms"`
+}
+
+// Offset is what the server knows about one robot's clock.
+type Offset struct {
+	OffsetMs  int64   `json:"offset_ms"` // robot clock minus server clock
+	RTTMs     float64 `json:"rtt_ms"`    // round trip of the last sample
+	Samples   int     `json:"samples"`
+	UpdatedAt int64   `json:"updated_at"` // Unix ms, server clock
+}
+
+// Sample is the offset a request implies: t1 on the robot clock, received
+// at t2 on the server clock, having spent half of rtt in flight.
+func Sample(t1 int64, t2 time.Time, rtt time.Duration) int64 {
+	return t1 + rtt.Milliseconds()/2 - t2.UnixMilli()
+}
+
+// Add folds a sample into the offset. The first sample, and one at least
+// StepThreshold from the running offset, replace it.
+func (o *Offset) Add(sample int64, rtt time.Duration, now time.Time) {
+	diff := sample - o.OffsetMs
+	if o.Samples == 0 || math.Abs(float64(diff)) >= float64(StepThreshold.Milliseconds()) {
+		o.OffsetMs = sample
+	} else {
+		o.OffsetMs += int64(math.Round(float64(diff) * Smoothing))
+	}
+	o.RTTMs = float64(rtt.Microseconds()) / 1000
+	o.Samples++
+	o.UpdatedAt = now.UnixMilli()
+}
+
+// ServerTime moves a robot timestamp (Unix ms) onto the server clock. A nil
+// offset leaves it as is.
+func (o *Offset) ServerTime(robotMs int64) int64 {
+	if o == nil {
+		return robotMs
+	}
+	return robotMs - o.OffsetMs
+}
+
+// Store keeps robots' offsets (database.RedisHandler).
+type Store interface {
+	GetLatencySamples(ctx context.Context, uuid string) ([]time.Duration, error)
+	RecordClockSample(ctx context.Context, uuid string, sample int64, rtt time.Duration, ttl time.Duration) (*Offset, error)
+}
+
+// Answer records the sample of a request the robot sent at t1 and the
+// server received at received, and returns the reply. rtt is the robot's
+// measured round trip; 0 falls back to the average of its PING samples.
+// The offset is kept for keep after the request.
+func Answer(ctx context.Context, store Store, uuid string, t1 int64, rtt time.Duration, received time.Time, keep time.Duration) (Reply, error) {
+	if rtt <= 0 {
+		if samples, err := store.GetLatencySamples(ctx, uuid); err == nil && len(samples) > 0 {
+			var total time.Duration
+			for _, s := range samples {
+				total += s
+			}
+			rtt = total / time.Duration(len(samples))
+		}
+	}
+	o, err := store.RecordClockSample(ctx, uuid, Sample(t1, received, rtt), rtt, keep)
+	if err != nil {
+		return Reply{}, err
+	}
+	return Reply{
+		T1:       t1,
+		T2:       received.UnixMilli(),
+		T3:       time.Now().UnixMilli(),
+		OffsetMs: o.OffsetMs,
+	}, nil
+}
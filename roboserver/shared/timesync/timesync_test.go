@@ -0,0 +1,86 @@
+package timesync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSample(t *testing.T) {
+	t2 := time.UnixMilli(1718000000000)
+	if got := Sample(1718000003000, t2, 200*time.Millisecond); got != 3100 {
+		t.Errorf("Expected offset 3100, got %d", got)
+	}
+	if got := Sample(1717999999000, t2, 0); got != -1000 {
+		t.Errorf("Expected offset -1000, got %d", got)
+	}
+}
+
+func TestOffsetAdd(t *testing.T) {
+	now := time.UnixMilli(1718000000000)
+	var o Offset
+	o.Add(1000, 40*time.Millisecond, now)
+	if o.OffsetMs != 1000 || o.Samples != 1 || o.RTTMs != 40 || o.UpdatedAt != now.UnixMilli() {
+		t.Fatalf("Expected the first sample to set the offset, got %+v", o)
+	}
+
+	o.Add(1400, 40*time.Millisecond, now)
+	if o.OffsetMs != 1100 {
+		t.Errorf("Expected the sample smoothed to 1100, got %d", o.OffsetMs)
+	}
+
+	o.Add(-60000, 40*time.Millisecond, now)
+	if o.OffsetMs != -60000 || o.Samples != 3 {
+		t.Errorf("Expected a clock step to replace the offset, got %+v", o)
+	}
+}
+
+func TestServerTime(t *testing.T) {
+	o := &Offset{OffsetMs: 2500}
+	if got := o.ServerTime(1718000002500); got != 1718000000000 {
+		t.Errorf("Expected 1718000000000, got %d", got)
+	}
+	var none *Offset
+	if got := none.ServerTime(42); got != 42 {
+		t.Errorf("Expected a nil offset to leave the timestamp, got %d", got)
+	}
+}
+
+type memStore struct {
+	samples []time.Duration
+	offset  *Offset
+}
+
+func (m *memStore) GetLatencySamples(ctx context.Context, uuid string) ([]time.Duration, error) {
+	return m.samples, nil
+}
+
+func (m *memStore) RecordClockSample(ctx context.Context, uuid string, sample int64, rtt time.Duration, ttl time.Duration) (*Offset, error) {
+	if m.offset == nil {
+		m.offset = &Offset{}
+	}
+	m.offset.Add(sample, rtt, time.Now())
+	return m.offset, nil
+}
+
+func TestAnswerUsesPingRTT(t *testing.T) {
+	store := &memStore{samples: []time.Duration{100 * time.Millisecond, 300 * time.Millisecond}}
+	received := time.UnixMilli(1718000000000)
+	reply, err := Answer(context.Background(), store, "rover-7", 1718000001000, 0, received, time.Hour)
+	if err != nil {
+		t.Fatalf("Answer failed: %v", err)
+	}
+	if reply.T1 != 1718000001000 || reply.T2 != received.UnixMilli() || reply.T3 < reply.T2 {
+		t.Errorf("Unexpected reply: %+v", reply)
+	}
+	if reply.OffsetMs != 1100 || store.offset.RTTMs != 200 {
+		t.Errorf("Expected offset 1100 over the 200ms PING average, got %+v", store.offset)
+	}
+
+	if _, err := Answer(context.Background(), store, "rover-7", 1718000001000, 20*time.Millisecond, received, time.Hour); err != nil {
+		t.Fatalf("Answer failed: %v", err)
+	}
+	if store.offset.RTTMs != 20 {
+		t.Errorf("Expected the robot's RTT to be used, got %+v", store.offset)
+	}
+}
@@ -0,0 +1,190 @@
+// Package tokencrypt encrypts secrets kept at rest (robot session JWTs in
+// Redis, user API keys) with AES-256-GCM.
+//
+// Ciphertexts are "enc:v1:<key id>:<base64 nonce+sealed>", where the key id
+// is derived from the key so values sealed under a retired key can still be
+// opened while it is listed in the old keys. Values without the "enc:" prefix
+// are legacy plaintext: Open returns them as-is and reports them stale so the
+// caller can rewrite them (lazy migration). With no key configured, Seal is
+// the identity and nothing is encrypted.
+package tokencrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const prefix = "enc:v1:"
+
+// ErrUnknownKey is returned for ciphertext sealed under a key that is
+// neither the current key nor one of the old keys.
+var ErrUnknownKey = errors.New("token sealed with an unknown key")
+
+type keyring struct {
+	primaryID string
+	aeads     map[string]cipher.AEAD
+}
+
+var (
+	mu   sync.RWMutex
+	ring *keyring
+)
+
+// Configure installs the current key and any old keys still accepted for
+// decryption. Keys are 32 bytes, given as 64 hex characters or base64. An
+// empty current key disables encryption.
+func Configure(current string, old []string) error {
+	if current == "" {
+		mu.Lock()
+		ring = nil
+		mu.Unlock()
+		return nil
+	}
+
+	kr := &keyring{aeads: make(map[string]cipher.AEAD)}
+	for i, encoded := range append([]string{current}, old...) {
+		id, aead, err := newAEAD(encoded)
+		if err != nil {
+			return fmt.Errorf("token key %d: %w", i, err)
+		}
+		if i == 0 {
+			kr.primaryID = id
+		}
+		kr.aeads[id] = aead
+	}
+
+	mu.Lock()
+	ring = kr
+	mu.Unlock()
+	return nil
+}
+
+// Rotate makes newKey the current key, keeping the previous keys for
+// decryption until the values sealed under them have been re-sealed.
+func Rotate(newKey string) error {
+	id, aead, err := newAEAD(newKey)
+	if err != nil {
+		return fmt.Errorf("token key: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	kr := &keyring{primaryID: id, aeads: map[string]cipher.AEAD{id: aead}}
+	if ring != nil {
+		for oldID, oldAEAD := range ring.aeads {
+			if oldID != id {
+				kr.aeads[oldID] = oldAEAD
+			}
+		}
+	}
+	ring = kr
+	return nil
+}
+
+// KeyID returns the id of the current key, or "" when encryption is off.
+func KeyID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if ring == nil {
+		return ""
+	}
+	return ring.primaryID
+}
+
+// Enabled reports whether a key is configured.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return ring != nil
+}
+
+// Seal encrypts plaintext under the current key. Empty strings stay empty.
+func Seal(plaintext string) (string, error) {
+	mu.RLock()
+	kr := ring
+	mu.RUnlock()
+	if kr == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	aead := kr.aeads[kr.primaryID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(kr.primaryID))
+	return prefix + kr.primaryID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal. stale is true when the value
+// should be re-sealed: it is legacy plaintext while encryption is enabled,
+// or it was sealed under an old key.
+func Open(value string) (plaintext string, stale bool, err error) {
+	mu.RLock()
+	kr := ring
+	mu.RUnlock()
+
+	if !strings.HasPrefix(value, prefix) {
+		return value, kr != nil && value != "", nil
+	}
+	if kr == nil {
+		return "", false, ErrUnknownKey
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", false, errors.New("malformed sealed token")
+	}
+	aead, ok := kr.aeads[id]
+	if !ok {
+		return "", false, ErrUnknownKey
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", false, errors.New("malformed sealed token")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(plain), id != kr.primaryID, nil
+}
+
+func newAEAD(encoded string) (string, cipher.AEAD, error) {
+	key, err := decodeKey(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4]), aead, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	if len(encoded) == 64 {
+		if key, err := hex.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("must be 32 bytes as hex or base64")
+	}
+	return key, nil
+}
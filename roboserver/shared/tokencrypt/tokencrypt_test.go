@@ -0,0 +1,98 @@
+package tokencrypt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	keyA = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	keyB = "IBY3dCB0b2tlbiBrZXkgZm9yIHRlc3RzISEhISEhISE=" // base64, 32 bytes
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	if err := Configure(keyA, nil); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	defer Configure("", nil)
+
+	sealed, err := Seal("jwt-token")
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !strings.HasPrefix(sealed, prefix) || strings.Contains(sealed, "jwt-token") {
+		t.Fatalf("Expected an opaque sealed value, got %q", sealed)
+	}
+	plain, stale, err := Open(sealed)
+	if err != nil || plain != "jwt-token" || stale {
+		t.Errorf("Expected jwt-token (fresh), got %q stale=%v err=%v", plain, stale, err)
+	}
+}
+
+func TestOpenLegacyPlaintextIsStale(t *testing.T) {
+	if err := Configure(keyA, nil); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	defer Configure("", nil)
+
+	plain, stale, err := Open("plain-jwt")
+	if err != nil || plain != "plain-jwt" || !stale {
+		t.Errorf("Expected plaintext passed through as stale, got %q stale=%v err=%v", plain, stale, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	if err := Configure(keyA, nil); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	defer Configure("", nil)
+	sealed, _ := Seal("secret")
+
+	// New current key, old key kept for decryption: value opens but is stale.
+	if err := Configure(keyB, []string{keyA}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	plain, stale, err := Open(sealed)
+	if err != nil || plain != "secret" || !stale {
+		t.Errorf("Expected old-key value to open as stale, got %q stale=%v err=%v", plain, stale, err)
+	}
+
+	// Old key dropped: value can no longer be opened.
+	Configure(keyB, nil)
+	if _, _, err := Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestRotateKeepsPreviousKey(t *testing.T) {
+	Configure(keyA, nil)
+	defer Configure("", nil)
+	sealed, _ := Seal("secret")
+	before := KeyID()
+
+	if err := Rotate(keyB); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if KeyID() == before {
+		t.Error("Expected the current key to change")
+	}
+	if plain, stale, err := Open(sealed); err != nil || plain != "secret" || !stale {
+		t.Errorf("Expected pre-rotation value to open as stale, got %q stale=%v err=%v", plain, stale, err)
+	}
+}
+
+func TestDisabledIsIdentity(t *testing.T) {
+	Configure("", nil)
+	sealed, _ := Seal("jwt")
+	plain, stale, err := Open(sealed)
+	if sealed != "jwt" || plain != "jwt" || stale || err != nil {
+		t.Errorf("Expected passthrough when disabled, got %q/%q stale=%v err=%v", sealed, plain, stale, err)
+	}
+}
+
+func TestConfigureRejectsBadKey(t *testing.T) {
+	if err := Configure("short", nil); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+}
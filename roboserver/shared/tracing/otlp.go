@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"roboserver/shared"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxBatch      = 512
+	flushInterval = 5 * time.Second
+)
+
+// active is the running export pipeline; nil while tracing is off.
+var active atomic.Pointer[pipeline]
+
+type pipeline struct {
+	endpoint    string
+	headers     map[string]string
+	service     string
+	sampleRatio float64
+	client      *http.Client
+	queue       chan *Span
+	dropped     atomic.Int64
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// Start enables tracing as configured in shared.AppConfig.Tracing and
+// exports finished spans in the background until Stop. It does nothing
+// when tracing is disabled.
+func Start() error {
+	cfg := shared.AppConfig.Tracing
+	if !cfg.Enabled {
+		return nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("tracing: endpoint must be an http(s) URL, got %q", cfg.Endpoint)
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return fmt.Errorf("tracing: sample_ratio must be between 0 and 1")
+	}
+	p := &pipeline{
+		endpoint:    strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces",
+		headers:     cfg.Headers,
+		service:     cfg.ServiceName,
+		sampleRatio: cfg.SampleRatio,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		queue:       make(chan *Span, max(cfg.Buffer, 1)),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if !active.CompareAndSwap(nil, p) {
+		return fmt.Errorf("tracing: already started")
+	}
+	go p.run()
+	shared.DebugPrint("Exporting traces to %s (sample ratio %g)", p.endpoint, p.sampleRatio)
+	return nil
+}
+
+// Stop disables tracing and exports the spans still queued, giving up
+// when ctx is done.
+func Stop(ctx context.Context) error {
+	p := active.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	close(p.stop)
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue queues a finished span, dropping it if the queue is full so a
+// slow collector never stalls the code being traced.
+func (p *pipeline) enqueue(s *Span) {
+	select {
+	case p.queue <- s:
+	default:
+		if p.dropped.Add(1)%1000 == 1 {
+			shared.DebugPrint("Trace export queue full, dropping spans")
+		}
+	}
+}
+
+func (p *pipeline) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, maxBatch)
+	flush := func() {
+		if len(batch) > 0 {
+			p.send(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case s := <-p.queue:
+			if batch = append(batch, s); len(batch) == maxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-p.stop:
+			for {
+				select {
+				case s := <-p.queue:
+					if batch = append(batch, s); len(batch) == maxBatch {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a batch to the collector. Failed batches are dropped.
+func (p *pipeline) send(batch []*Span) {
+	body, err := json.Marshal(p.encode(batch))
+	if err != nil {
+		shared.DebugPrint("Trace export: cannot encode spans: %v", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		shared.DebugPrint("Trace export failed: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		shared.DebugPrint("Trace export failed: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		shared.DebugPrint("Trace export rejected: %s", resp.Status)
+	}
+}
+
+// OTLP JSON encoding (ExportTraceServiceRequest). Ids are hex strings and
+// 64-bit integers decimal strings, per the OTLP JSON mapping.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitempty"`
+		Status            otlpStatus `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func (p *pipeline) encode(batch []*Span) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			span.Attributes = append(span.Attributes, attr(a.key, a.value))
+		}
+		if s.failed {
+			span.Status = otlpStatus{Code: 2, Message: s.errText}
+		}
+		s.mu.Unlock()
+		spans = append(spans, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttr{
+			attr("service.name", p.service),
+			attr("service.instance.id", shared.NodeID()),
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "roboserver"}, Spans: spans}},
+	}}}
+}
+
+func attr(key string, v any) otlpAttr {
+	var value map[string]any
+	switch v := v.(type) {
+	case string:
+		value = map[string]any{"stringValue": v}
+	case bool:
+		value = map[string]any{"boolValue": v}
+	case int:
+		value = map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case uint64:
+		value = map[string]any{"intValue": strconv.FormatUint(v, 10)}
+	case float64:
+		value = map[string]any{"doubleValue": v}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpAttr{Key: key, Value: value}
+}
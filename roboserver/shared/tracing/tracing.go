@@ -0,0 +1,244 @@
+// Package tracing records OpenTelemetry spans and exports them to an OTLP
+// collector (OTLP/HTTP with JSON bodies), so a command can be followed from
+// its HTTP request through the event bus and cluster relay to the handler
+// that sends it to the robot. It implements the small part of OpenTelemetry
+// the server uses instead of pulling in the SDK, like the exporter's Kafka
+// REST sink.
+//
+// Spans are cheap no-ops until Start enables tracing: StartSpan returns a
+// nil *Span, whose methods do nothing. Trace context crosses process
+// boundaries as a W3C traceparent string (Inject, Extract): the HTTP
+// traceparent header, cluster relay envelopes, forwarded API messages and
+// handler messages.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Kind is an OTLP span kind.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2 // handling a request: HTTP, a robot's TCP line
+	KindClient   Kind = 3 // calling out: database, robot
+	KindProducer Kind = 4 // publishing an event
+	KindConsumer Kind = 5 // handling an event
+)
+
+// SpanContext identifies a span within its trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether sc has non-zero trace and span ids.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Span is one timed operation. A nil *Span, or one that was not sampled,
+// records nothing.
+type Span struct {
+	sc        SpanContext
+	recording bool
+
+	mu      sync.Mutex
+	parent  [8]byte
+	name    string
+	kind    Kind
+	start   time.Time
+	end     time.Time
+	attrs   []attribute
+	errText string
+	failed  bool
+	ended   bool
+}
+
+type attribute struct {
+	key   string
+	value any
+}
+
+type ctxKey struct{}
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool { return active.Load() != nil }
+
+// StartSpan starts a span as a child of the span in ctx (if any) and
+// returns a context carrying it. End must be called on the span.
+func StartSpan(ctx context.Context, kind Kind, name string) (context.Context, *Span) {
+	p := active.Load()
+	if p == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	if parent := SpanContextFrom(ctx); parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = rand.Float64() < p.sampleRatio
+	}
+	s.sc.SpanID = newSpanID()
+	s.recording = s.sc.Sampled
+	return context.WithValue(ctx, ctxKey{}, s), s
+}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(ctxKey{}).(*Span)
+	return s
+}
+
+// SpanContextFrom returns the context of the span in ctx (invalid if none).
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	return SpanContext{}
+}
+
+// Context returns the span's SpanContext.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName renames the span, e.g. once an HTTP request has been routed.
+func (s *Span) SetName(name string) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttr records an attribute. Values are strings, integers, floats or
+// bools; anything else is recorded as its fmt.Sprint form.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{key, value})
+	s.mu.Unlock()
+}
+
+// SetError marks the span failed with err. A nil err does nothing, so
+// callers can pass whatever the traced operation returned.
+func (s *Span) SetError(err error) {
+	if s == nil || !s.recording || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed, s.errText = true, err.Error()
+	s.mu.Unlock()
+}
+
+// SetFailed marks the span failed with a message, for failures that are
+// not Go errors (a 5xx response, an ERROR line to a robot).
+func (s *Span) SetFailed(msg string) {
+	s.SetError(errors.New(msg))
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, time.Now()
+	s.mu.Unlock()
+	if p := active.Load(); p != nil {
+		p.enqueue(s)
+	}
+}
+
+// Inject returns the W3C traceparent of the span in ctx ("" if none), to
+// carry the trace to another process or node.
+func Inject(ctx context.Context) string {
+	sc := SpanContextFrom(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// Extract returns ctx with traceparent as the parent of the next span
+// started from it. An empty or malformed traceparent returns ctx unchanged.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	sc, ok := ParseTraceParent(traceparent)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, &Span{sc: sc})
+}
+
+// ParseTraceParent parses a W3C traceparent header value.
+func ParseTraceParent(v string) (SpanContext, bool) {
+	// version-traceid-parentid-flags, e.g. 00-<32 hex>-<16 hex>-01
+	if len(v) < 55 || v[2] != '-' || v[35] != '-' || v[52] != '-' || (len(v) > 55 && v[55] != '-') {
+		return SpanContext{}, false
+	}
+	version, err := hex.DecodeString(v[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(v) != 55) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if !decodeLowerHex(sc.TraceID[:], v[3:35]) || !decodeLowerHex(sc.SpanID[:], v[36:52]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(v[53:55])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// decodeLowerHex decodes s into dst; W3C ids are lowercase hex only.
+func decodeLowerHex(dst []byte, s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newTraceID() (id [16]byte) {
+	for id == [16]byte{} {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() (id [8]byte) {
+	for id == [8]byte{} {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
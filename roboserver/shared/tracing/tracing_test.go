@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"roboserver/shared"
+	"testing"
+	"time"
+)
+
+const testParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	sc, ok := ParseTraceParent(testParent)
+	if !ok || !sc.Sampled || sc.TraceID[0] != 0x4b || sc.SpanID[7] != 0xb7 {
+		t.Fatalf("Failed to parse %s: %+v", testParent, sc)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceParent(bad); ok {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+	if got := Inject(Extract(context.Background(), testParent)); got != testParent {
+		t.Errorf("Expected the traceparent to round-trip, got %q", got)
+	}
+}
+
+func TestDisabledIsNoOp(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), KindServer, "noop")
+	if span != nil {
+		t.Fatal("Expected a nil span while tracing is off")
+	}
+	span.SetAttr("k", "v")
+	span.SetError(errors.New("boom"))
+	span.End()
+	if Inject(ctx) != "" {
+		t.Error("Expected no traceparent without a span")
+	}
+}
+
+func TestExport(t *testing.T) {
+	received := make(chan otlpRequest, 4)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("Unexpected export request %s with headers %v", r.URL.Path, r.Header)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Bad OTLP body: %v", err)
+		}
+		received <- req
+	}))
+	defer collector.Close()
+
+	saved := shared.AppConfig.Tracing
+	defer func() { shared.AppConfig.Tracing = saved }()
+	shared.AppConfig.Tracing = shared.TracingConfig{
+		Enabled:     true,
+		Endpoint:    collector.URL,
+		ServiceName: "roboserver-test",
+		SampleRatio: 1,
+		Buffer:      16,
+		Headers:     map[string]string{"X-Api-Key": "secret"},
+	}
+	if err := Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	ctx, parent := StartSpan(Extract(context.Background(), testParent), KindServer, "GET /robots")
+	_, child := StartSpan(ctx, KindClient, "postgres query")
+	child.SetAttr("db.system", "postgresql")
+	child.SetError(errors.New("timeout"))
+	child.End()
+	parent.End()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Stop(stopCtx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if Enabled() {
+		t.Error("Expected tracing to be off after Stop")
+	}
+
+	var req otlpRequest
+	select {
+	case req = <-received:
+	default:
+		t.Fatal("Expected Stop to flush the queued spans")
+	}
+	rs := req.ResourceSpans[0]
+	if rs.Resource.Attributes[0].Value["stringValue"] != "roboserver-test" {
+		t.Errorf("Unexpected resource: %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", spans)
+	}
+	c, p := spans[0], spans[1]
+	if p.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || p.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to continue the remote trace: %+v", p)
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID {
+		t.Errorf("Expected the client span to be a child of the server span: %+v", c)
+	}
+	if c.Status.Code != 2 || c.Status.Message != "timeout" || c.Attributes[0].Key != "db.system" {
+		t.Errorf("Expected the child's error and attribute to be exported: %+v", c)
+	}
+}
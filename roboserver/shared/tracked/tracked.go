@@ -0,0 +1,86 @@
+// Package tracked counts the goroutines and per-connection resources the
+// server starts, by the component that started them, so leaks show up as
+// counts that keep growing (GET /admin/goroutines):
+//
+//	tracked.Go("sse", client.ReadMsgQueue)
+//
+//	release := tracked.Open("sse.done")
+//	defer release()
+//
+// Only long-lived goroutines are labeled; the rest of the runtime's count
+// is reported as unlabeled.
+package tracked
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	mu         sync.Mutex
+	goroutines = make(map[string]*atomic.Int64)
+	resources  = make(map[string]*atomic.Int64)
+)
+
+func counter(m map[string]*atomic.Int64, name string) *atomic.Int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	c, ok := m[name]
+	if !ok {
+		c = new(atomic.Int64)
+		m[name] = c
+	}
+	return c
+}
+
+// Go runs fn in a goroutine counted under component until fn returns.
+func Go(component string, fn func()) {
+	c := counter(goroutines, component)
+	c.Add(1)
+	go func() {
+		defer c.Add(-1)
+		fn()
+	}()
+}
+
+// Open counts one open resource of kind (a channel closed on disconnect, a
+// queue) and returns the function that releases it. Calling release more
+// than once counts once.
+func Open(kind string) (release func()) {
+	c := counter(resources, kind)
+	c.Add(1)
+	var once sync.Once
+	return func() { once.Do(func() { c.Add(-1) }) }
+}
+
+// Report is a snapshot of the counts.
+type Report struct {
+	Goroutines int              `json:"goroutines"` // every goroutine in the process
+	Unlabeled  int              `json:"unlabeled"`  // goroutines not started with Go
+	Components map[string]int64 `json:"components"` // running goroutines by component
+	Resources  map[string]int64 `json:"resources"`  // open resources by kind
+}
+
+// Snapshot returns the current counts. Components and kinds that were
+// seen once stay listed, at 0 when nothing is running or open.
+func Snapshot() Report {
+	r := Report{
+		Goroutines: runtime.NumGoroutine(),
+		Components: make(map[string]int64),
+		Resources:  make(map[string]int64),
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	labeled := 0
+	for name, c := range goroutines {
+		n := c.Load()
+		r.Components[name] = n
+		labeled += int(n)
+	}
+	for kind, c := range resources {
+		r.Resources[kind] = c.Load()
+	}
+	r.Unlabeled = max(r.Goroutines-labeled, 0)
+	return r
+}
@@ -0,0 +1,47 @@
+package tracked
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGoCountsUntilReturn(t *testing.T) {
+	stop := make(chan struct{})
+	started := make(chan struct{})
+	Go("test.worker", func() {
+		close(started)
+		<-stop
+	})
+	<-started
+	if n := Snapshot().Components["test.worker"]; n != 1 {
+		t.Fatalf("Expected 1 running, got %d", n)
+	}
+
+	close(stop)
+	for i := 0; i < 1000 && Snapshot().Components["test.worker"] != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := Snapshot().Components["test.worker"]; n != 0 {
+		t.Errorf("Expected 0 running after return, got %d", n)
+	}
+}
+
+func TestOpenReleasesOnce(t *testing.T) {
+	release := Open("test.chan")
+	Open("test.chan")
+	if n := Snapshot().Resources["test.chan"]; n != 2 {
+		t.Fatalf("Expected 2 open, got %d", n)
+	}
+	release()
+	release()
+	if n := Snapshot().Resources["test.chan"]; n != 1 {
+		t.Errorf("Expected a double release to count once, got %d open", n)
+	}
+}
+
+func TestSnapshotUnlabeled(t *testing.T) {
+	r := Snapshot()
+	if r.Goroutines <= 0 || r.Unlabeled > r.Goroutines {
+		t.Errorf("Unexpected report: %+v", r)
+	}
+}
@@ -0,0 +1,267 @@
+// Package statediff turns the robot state carried by heartbeats, telemetry
+// and status transitions into changelog events. Each robot's state is a
+// flat map of dotted field paths ("heartbeat.data.battery",
+// "telemetry.env.temp_c", "status"); every update is compared with the
+// previous one and only the fields that changed are published on
+// robot.{uuid}.changed. A frontend loads the full state once from
+// GET /robot/{uuid}/state and applies the changes, instead of receiving
+// whole heartbeat and telemetry payloads for chatty sensors.
+package statediff
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"roboserver/auth"
+	"roboserver/comms"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/robot_status"
+	"roboserver/shared/telemetry"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Change is the payload of robot.{uuid}.changed. Seq increases by one per
+// change for a robot, so a client that sees a gap reloads the full state.
+type Change struct {
+	UUID string `json:"uuid"`
+	Seq  uint64 `json:"seq"`
+	// Full is set on a robot's first change: Changed is then its whole state.
+	Full      bool           `json:"full,omitempty"`
+	Changed   map[string]any `json:"changed,omitempty"`
+	Removed   []string       `json:"removed,omitempty"`
+	Timestamp int64          `json:"timestamp"` // Unix milliseconds
+}
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotChanged("{uuid}"),
+		Description: "Fields of a robot's state that changed. A gap in seq means changes were missed: reload the full state.",
+		Example:     Change{UUID: "rover-7", Seq: 18, Changed: map[string]any{"status": "busy"}, Removed: []string{"maintenance"}, Timestamp: 1718000000000},
+	})
+}
+
+// DecodeChange reads a Change published locally or relayed from another
+// node (where it arrives as decoded JSON).
+func DecodeChange(data any) (Change, bool) {
+	switch v := data.(type) {
+	case Change:
+		return v, v.UUID != ""
+	case map[string]any:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return Change{}, false
+		}
+		var c Change
+		if json.Unmarshal(raw, &c) != nil {
+			return Change{}, false
+		}
+		return c, c.UUID != ""
+	}
+	return Change{}, false
+}
+
+// State is a robot's current state as of change Seq.
+type State struct {
+	UUID   string         `json:"uuid"`
+	Seq    uint64         `json:"seq"`
+	Fields map[string]any `json:"fields"`
+}
+
+type robotState struct {
+	fields map[string]any
+	seq    uint64
+}
+
+// Engine_t keeps the last state of every robot seen on this node.
+type Engine_t struct {
+	mu     sync.Mutex
+	robots map[string]*robotState
+}
+
+// Engine is the process-wide diff engine.
+var Engine = NewEngine()
+
+func NewEngine() *Engine_t {
+	return &Engine_t{robots: make(map[string]*robotState)}
+}
+
+// Update replaces the section of a robot's state (every field at or below
+// the dotted path section) with value, flattening nested maps into dotted
+// fields. It returns the resulting Change, or false if nothing changed.
+func (e *Engine_t) Update(uuid, section string, value any) (Change, bool) {
+	next := make(map[string]any)
+	flatten(section, value, next)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rs, known := e.robots[uuid]
+	if !known {
+		rs = &robotState{fields: make(map[string]any)}
+		e.robots[uuid] = rs
+	}
+
+	changed := make(map[string]any)
+	for field, v := range next {
+		if old, ok := rs.fields[field]; !ok || !reflect.DeepEqual(old, v) {
+			changed[field] = v
+			rs.fields[field] = v
+		}
+	}
+	var removed []string
+	for field := range rs.fields {
+		if inSection(field, section) {
+			if _, ok := next[field]; !ok {
+				removed = append(removed, field)
+				delete(rs.fields, field)
+			}
+		}
+	}
+	if known && len(changed) == 0 && len(removed) == 0 {
+		return Change{}, false
+	}
+	sort.Strings(removed)
+
+	rs.seq++
+	return Change{
+		UUID:      uuid,
+		Seq:       rs.seq,
+		Full:      !known,
+		Changed:   changed,
+		Removed:   removed,
+		Timestamp: time.Now().UnixMilli(),
+	}, true
+}
+
+// Snapshot returns a copy of a robot's current state.
+func (e *Engine_t) Snapshot(uuid string) (State, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rs, ok := e.robots[uuid]
+	if !ok {
+		return State{}, false
+	}
+	fields := make(map[string]any, len(rs.fields))
+	for k, v := range rs.fields {
+		fields[k] = v
+	}
+	return State{UUID: uuid, Seq: rs.seq, Fields: fields}, true
+}
+
+// Forget drops a robot's state; its next update is a full change again.
+func (e *Engine_t) Forget(uuid string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.robots, uuid)
+}
+
+func inSection(field, section string) bool {
+	return field == section || strings.HasPrefix(field, section+".")
+}
+
+// flatten writes value into out under prefix, one field per leaf. Empty
+// maps and non-map values (including slices) are leaves.
+func flatten(prefix string, value any, out map[string]any) {
+	m, ok := value.(map[string]any)
+	if !ok || len(m) == 0 {
+		out[prefix] = value
+		return
+	}
+	for k, v := range m {
+		flatten(prefix+"."+k, v, out)
+	}
+}
+
+// updateQueueSize bounds state updates waiting to be diffed; beyond it
+// updates are dropped and the next one for the robot catches up.
+const updateQueueSize = 1024
+
+type update struct {
+	uuid    string
+	section string
+	value   any
+}
+
+// Watch diffs the robot state events published on this node and publishes
+// robot.{uuid}.changed for each change, until ctx is cancelled. Events
+// relayed from other cluster nodes are skipped: they arrive as decoded JSON
+// rather than typed payloads, and the node that produced them publishes
+// their changes itself.
+func Watch(ctx context.Context, bus comms.Bus, e *Engine_t) error {
+	queue := make(chan update, updateQueueSize)
+	// The matching handler runs on the publisher's goroutine, so diffing
+	// and publishing happen on a worker instead.
+	cancel, err := bus.SubscribeMatching(isStateEvent, func(eventType string, data any) {
+		u, ok := decodeUpdate(eventType, data)
+		if !ok {
+			return
+		}
+		select {
+		case queue <- u:
+		default:
+			shared.DebugPrint("State diff queue full, dropping %s update for %s", u.section, u.uuid)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case u := <-queue:
+				if change, ok := e.Update(u.uuid, u.section, u.value); ok {
+					bus.PublishEvent(events.RobotChanged(u.uuid), change)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func isStateEvent(eventType string) bool {
+	_, kind, ok := events.ParseRobotTopic(eventType)
+	return ok && (kind == "heartbeat" || kind == "telemetry" || kind == "status")
+}
+
+// decodeUpdate maps a locally published state event to the section of the
+// robot's state it replaces.
+func decodeUpdate(eventType string, data any) (update, bool) {
+	uuid, _, ok := events.ParseRobotTopic(eventType)
+	if !ok {
+		return update{}, false
+	}
+	switch v := data.(type) {
+	case *auth.HeartbeatResult:
+		if v == nil {
+			return update{}, false
+		}
+		state := map[string]any{"ip": v.IP}
+		if v.Payload != nil && len(v.Payload.ExtraData) > 0 {
+			var extra any
+			if json.Unmarshal(v.Payload.ExtraData, &extra) == nil {
+				state["data"] = extra
+			}
+		}
+		return update{uuid: uuid, section: "heartbeat", value: state}, true
+	case telemetry.Envelope:
+		metrics := make(map[string]any, len(v.Metrics))
+		for name, value := range v.Metrics {
+			metrics[name] = value
+		}
+		return update{uuid: uuid, section: "telemetry." + v.Type, value: metrics}, true
+	case *robot_status.Transition:
+		if v == nil {
+			return update{}, false
+		}
+		return update{uuid: uuid, section: "status", value: string(v.To)}, true
+	}
+	return update{}, false
+}
@@ -0,0 +1,112 @@
+package statediff
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"roboserver/auth"
+	"roboserver/comms"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"roboserver/shared/telemetry"
+	"testing"
+	"time"
+)
+
+func TestUpdateFirstChangeIsFull(t *testing.T) {
+	e := NewEngine()
+	change, ok := e.Update("r1", "heartbeat", map[string]any{"ip": "10.0.0.2", "data": map[string]any{"battery": 80.0}})
+	if !ok || !change.Full || change.Seq != 1 {
+		t.Fatalf("Expected a full first change, got %+v %v", change, ok)
+	}
+	want := map[string]any{"heartbeat.ip": "10.0.0.2", "heartbeat.data.battery": 80.0}
+	if !reflect.DeepEqual(change.Changed, want) {
+		t.Errorf("Changed = %v, want %v", change.Changed, want)
+	}
+}
+
+func TestUpdateOnlyChangedFields(t *testing.T) {
+	e := NewEngine()
+	e.Update("r1", "heartbeat", map[string]any{"ip": "10.0.0.2", "data": map[string]any{"battery": 80.0, "mode": "idle"}})
+
+	if _, ok := e.Update("r1", "heartbeat", map[string]any{"ip": "10.0.0.2", "data": map[string]any{"battery": 80.0, "mode": "idle"}}); ok {
+		t.Error("Expected no change for an identical state")
+	}
+
+	change, ok := e.Update("r1", "heartbeat", map[string]any{"ip": "10.0.0.2", "data": map[string]any{"battery": 79.0}})
+	if !ok || change.Full || change.Seq != 2 {
+		t.Fatalf("Expected a second, partial change, got %+v %v", change, ok)
+	}
+	if !reflect.DeepEqual(change.Changed, map[string]any{"heartbeat.data.battery": 79.0}) {
+		t.Errorf("Changed = %v", change.Changed)
+	}
+	if !reflect.DeepEqual(change.Removed, []string{"heartbeat.data.mode"}) {
+		t.Errorf("Removed = %v", change.Removed)
+	}
+}
+
+func TestUpdateLeavesOtherSections(t *testing.T) {
+	e := NewEngine()
+	e.Update("r1", "status", "online")
+	e.Update("r1", "telemetry.env", map[string]any{"temp_c": 21.5})
+	change, ok := e.Update("r1", "telemetry.battery", map[string]any{"volts": 12.1})
+	if !ok || len(change.Removed) != 0 {
+		t.Fatalf("Expected a new section to remove nothing, got %+v", change)
+	}
+
+	state, ok := e.Snapshot("r1")
+	if !ok || state.Seq != 3 || len(state.Fields) != 3 || state.Fields["status"] != "online" {
+		t.Errorf("Unexpected snapshot %+v", state)
+	}
+
+	e.Forget("r1")
+	if _, ok := e.Snapshot("r1"); ok {
+		t.Error("Expected Forget to drop the robot's state")
+	}
+}
+
+func TestWatchPublishesChanges(t *testing.T) {
+	bus := comms.NewLocalBus(event_bus.NewEventBus(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := NewEngine()
+	if err := Watch(ctx, bus, e); err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan Change, 4)
+	bus.SubscribeMatching(func(et string) bool { return et == events.RobotChanged("r1") }, func(_ string, data any) {
+		got <- data.(Change)
+	})
+
+	hb := &auth.HeartbeatResult{UUID: "r1", IP: "10.0.0.2", Payload: &auth.HeartbeatPayload{ExtraData: json.RawMessage(`{"battery":80}`)}}
+	bus.PublishEvent(events.RobotHeartbeat("r1"), hb)
+	bus.PublishEvent(events.RobotHeartbeat("r1"), hb)
+	bus.PublishEvent(events.RobotTelemetry("r1"), telemetry.Envelope{Type: "env", Metrics: map[string]float64{"temp_c": 21.5}})
+	// Relayed from another node: skipped.
+	bus.PublishEvent(events.RobotHeartbeat("r1"), map[string]any{"UUID": "r1", "IP": "10.0.0.9"})
+
+	first := <-got
+	if !first.Full || first.Changed["heartbeat.data.battery"] != 80.0 {
+		t.Errorf("Unexpected first change %+v", first)
+	}
+	second := <-got
+	if second.Seq != 2 || len(second.Changed) != 1 || second.Changed["telemetry.env.temp_c"] != 21.5 {
+		t.Errorf("Unexpected second change %+v", second)
+	}
+	select {
+	case extra := <-got:
+		t.Errorf("Expected no further changes, got %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDecodeChange(t *testing.T) {
+	relayed := map[string]any{"uuid": "r1", "seq": 3.0, "changed": map[string]any{"status": "busy"}, "removed": []any{"maintenance"}}
+	c, ok := DecodeChange(relayed)
+	if !ok || c.Seq != 3 || c.Changed["status"] != "busy" || len(c.Removed) != 1 {
+		t.Errorf("got %+v %v", c, ok)
+	}
+	if _, ok := DecodeChange(map[string]any{"seq": 1.0}); ok {
+		t.Error("Expected a change without uuid to be rejected")
+	}
+}
@@ -0,0 +1,176 @@
+package tcp_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"roboserver/shared"
+	"sort"
+	"sync"
+)
+
+// Codec is a wire format for robot connections. The protocol itself (AUTH,
+// REGISTER, session messages, ERROR replies) is the same for every codec;
+// a codec only decides how one message is framed on the stream.
+type Codec interface {
+	// Name is what robots send in "CODEC <name>" and server.tcp_codec uses.
+	Name() string
+	// Split cuts one message from the incoming stream (a bufio.SplitFunc).
+	// Frames over limits.tcp_line must return bufio.ErrTooLong.
+	Split(data []byte, atEOF bool) (advance int, token []byte, err error)
+	// Encode frames one outgoing message (without its trailing newline).
+	Encode(msg []byte) []byte
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+// RegisterCodec makes a codec available to server.tcp_codec and CODEC
+// negotiation. Registering a name twice replaces the earlier codec.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown TCP codec %q", name)
+	}
+	return c, nil
+}
+
+// CodecNames lists the registered codecs, sorted.
+func CodecNames() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterCodec(LineCodec{})
+	RegisterCodec(LengthPrefixedCodec{})
+}
+
+// LineCodec is the default newline-delimited text format.
+type LineCodec struct{}
+
+func (LineCodec) Name() string { return "line" }
+
+func (LineCodec) Split(data []byte, atEOF bool) (int, []byte, error) {
+	return bufio.ScanLines(data, atEOF)
+}
+
+func (LineCodec) Encode(msg []byte) []byte {
+	return append(msg, '\n')
+}
+
+// LengthPrefixedCodec frames each message as a 4-byte big-endian length
+// followed by that many bytes, so payloads (typically JSON) may contain
+// newlines. The length counts the payload only.
+type LengthPrefixedCodec struct{}
+
+func (LengthPrefixedCodec) Name() string { return "length" }
+
+func (LengthPrefixedCodec) Split(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < 4 {
+		if atEOF && len(data) > 0 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	n := int64(binary.BigEndian.Uint32(data))
+	if n+4 > int64(shared.AppConfig.Limits.TCPLineBytes()) {
+		// Fail before buffering a frame we would reject anyway.
+		return 0, nil, bufio.ErrTooLong
+	}
+	if int64(len(data)) < n+4 {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	return int(n + 4), data[4 : n+4], nil
+}
+
+func (LengthPrefixedCodec) Encode(msg []byte) []byte {
+	out := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(out, uint32(len(msg)))
+	copy(out[4:], msg)
+	return out
+}
+
+// newCodecScanner reads conn one codec frame at a time, bounded by
+// limits.tcp_line.
+func newCodecScanner(conn net.Conn, codec Codec) *bufio.Scanner {
+	scanner := bufio.NewScanner(conn)
+	// Frames over limits.tcp_line stop the scanner with bufio.ErrTooLong,
+	// which is reported to the robot before the connection closes.
+	shared.AppConfig.Limits.LimitScanner(scanner)
+	scanner.Split(codec.Split)
+	return scanner
+}
+
+// codecConn encodes every Write as one frame. The server writes whole
+// newline-terminated messages, so the newline is stripped before encoding
+// and the rest of the code stays codec-agnostic.
+type codecConn struct {
+	net.Conn
+	codec Codec
+}
+
+// withCodec wraps conn so writes use codec. Line connections are returned
+// unwrapped.
+func withCodec(conn net.Conn, codec Codec) net.Conn {
+	if cc, ok := conn.(*codecConn); ok {
+		conn = cc.Conn
+	}
+	if _, ok := codec.(LineCodec); ok {
+		return conn
+	}
+	return &codecConn{Conn: conn, codec: codec}
+}
+
+func (c *codecConn) Write(p []byte) (int, error) {
+	msg := bytes.TrimSuffix(p, []byte("\n"))
+	if _, err := c.Conn.Write(c.codec.Encode(msg)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// defaultCodec returns the codec configured by server.tcp_codec.
+func (s *TCPServer_t) defaultCodec() Codec {
+	if s.codec != nil {
+		return s.codec
+	}
+	return LineCodec{}
+}
+
+// negotiateCodec handles "CODEC <name>" sent before AUTH/REGISTER. The reply
+// goes out in the current format; the robot must wait for CODEC_OK before
+// sending frames in the new one.
+func (s *TCPServer_t) negotiateCodec(conn net.Conn, name string) (Codec, bool) {
+	codec, err := LookupCodec(name)
+	if err != nil {
+		conn.Write([]byte("ERROR UNKNOWN_CODEC\n"))
+		return nil, false
+	}
+	conn.Write([]byte("CODEC_OK " + codec.Name() + "\n"))
+	shared.DebugPrint("TCP %s switched to codec %s", conn.RemoteAddr(), codec.Name())
+	return codec, true
+}
@@ -0,0 +1,110 @@
+package tcp_server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFrame reads one length-prefixed frame with a timeout.
+func readFrame(conn net.Conn, timeout time.Duration) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return "", err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+func TestLengthPrefixedCodecRoundTrip(t *testing.T) {
+	codec := LengthPrefixedCodec{}
+	msg := `{"cmd":"move",` + "\n" + `"x":1}`
+	stream := append(codec.Encode([]byte(msg)), codec.Encode([]byte("PONG 1"))...)
+
+	scanner := bufio.NewScanner(bytes.NewReader(stream))
+	scanner.Split(codec.Split)
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if len(got) != 2 || got[0] != msg || got[1] != "PONG 1" {
+		t.Errorf("Expected both frames intact, got %q (err %v)", got, scanner.Err())
+	}
+}
+
+func TestLengthPrefixedCodecRejectsOversizedFrame(t *testing.T) {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], 1<<30)
+	_, _, err := LengthPrefixedCodec{}.Split(header[:], false)
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Expected bufio.ErrTooLong, got %v", err)
+	}
+}
+
+func TestLengthPrefixedCodecTruncatedFrame(t *testing.T) {
+	frame := LengthPrefixedCodec{}.Encode([]byte("hello"))
+	if _, _, err := (LengthPrefixedCodec{}).Split(frame[:6], true); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestLookupCodec(t *testing.T) {
+	if _, err := LookupCodec("line"); err != nil {
+		t.Errorf("Expected line codec to be registered: %v", err)
+	}
+	if _, err := LookupCodec("protobuf"); err == nil {
+		t.Error("Expected an error for an unregistered codec")
+	}
+}
+
+func TestHandleConnectionNegotiatesCodec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &TCPServer_t{bus: &mockBus{}, db: &mockDBManager{}, main_context: ctx}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go s.handleConnection(serverConn)
+
+	sendLine(clientConn, "CODEC length")
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil || line != "CODEC_OK length" {
+		t.Fatalf("Expected CODEC_OK length, got %q (%v)", line, err)
+	}
+
+	go clientConn.Write(LengthPrefixedCodec{}.Encode([]byte("FOOBAR")))
+	reply, err := readFrame(clientConn, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read framed reply: %v", err)
+	}
+	if reply != "ERROR EXPECTED_AUTH_OR_REGISTER" {
+		t.Errorf("Expected a framed ERROR without newline, got %q", reply)
+	}
+}
+
+func TestHandleConnectionRejectsUnknownCodec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &TCPServer_t{bus: &mockBus{}, db: &mockDBManager{}, main_context: ctx}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go s.handleConnection(serverConn)
+
+	sendLine(clientConn, "CODEC carrier-pigeon")
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil || !strings.HasPrefix(line, "ERROR UNKNOWN_CODEC") {
+		t.Errorf("Expected ERROR UNKNOWN_CODEC, got %q (%v)", line, err)
+	}
+}
@@ -0,0 +1,115 @@
+package tcp_server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fuzz targets for the parsing of untrusted robot input. Seeds live in
+// testdata/fuzz/<target>; run one with e.g.
+//
+//	go test ./tcp_server -run '^$' -fuzz FuzzServeConnection -fuzztime 1m
+
+func FuzzLengthPrefixedSplit(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 2, 'h', 'i'}, true)
+	f.Add([]byte{0, 0, 0, 0}, false)
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 'x'}, false)
+	f.Add([]byte{0, 0, 1}, true)
+
+	var codec LengthPrefixedCodec
+	f.Fuzz(func(t *testing.T, data []byte, atEOF bool) {
+		advance, token, err := codec.Split(data, atEOF)
+		if err != nil {
+			if advance != 0 || token != nil {
+				t.Fatalf("error %v with advance %d and a token", err, advance)
+			}
+			return
+		}
+		if advance < 0 || advance > len(data) {
+			t.Fatalf("advance %d outside 0..%d", advance, len(data))
+		}
+		if token == nil {
+			if advance != 0 {
+				t.Fatalf("advanced %d without a token", advance)
+			}
+			return
+		}
+		if advance != len(token)+4 || !bytes.Equal(data[4:advance], token) {
+			t.Fatalf("token %q does not match frame of %d bytes", token, advance)
+		}
+		if !bytes.Equal(codec.Encode(token), data[:advance]) {
+			t.Fatalf("frame does not re-encode to itself")
+		}
+	})
+}
+
+func FuzzParseHeartbeat(f *testing.F) {
+	f.Add(`HEARTBEAT robot-1 {"seq":1,"ts":1718000000} abcdef`)
+	f.Add(`HEARTBEAT robot-1 {"note":"with spaces"} abcdef`)
+	f.Add(`HEARTBEAT robot-1`)
+	f.Add(`HEARTBEAT  {} sig`)
+	f.Add(`HEARTBEAT ../../etc {} sig`)
+	f.Add(`HEARTBEAT robot-1 {} `)
+
+	f.Fuzz(func(t *testing.T, message string) {
+		uuid, payload, signature, ok := parseHeartbeat(message)
+		if !ok {
+			return
+		}
+		if uuid == "" || payload == "" || signature == "" || strings.ContainsAny(uuid+signature, " ") {
+			t.Fatalf("bad fields uuid=%q payload=%q signature=%q", uuid, payload, signature)
+		}
+		if got := "HEARTBEAT " + uuid + " " + payload + " " + signature; got != message {
+			t.Fatalf("fields do not rebuild the message: %q != %q", got, message)
+		}
+	})
+}
+
+// FuzzServeConnection feeds arbitrary bytes to a connection before
+// authentication, with no database, and checks the server neither panics
+// nor hangs.
+func FuzzServeConnection(f *testing.F) {
+	for _, seed := range []string{
+		"AUTH\n",
+		"REGISTER\nrobot-1\nrover\nkey\n",
+		"REGISTER ABCD-EFGH\n",
+		"CODEC length\n\x00\x00\x00\x04AUTH",
+		"CODEC bogus\nFOO\n",
+		"HEARTBEAT robot-1 {} sig\nHEARTBEAT\nPING\n",
+		"\n\n   \n",
+		strings.Repeat("A", 4096) + "\n",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s := &TCPServer_t{bus: &mockBus{}, db: &mockDBManager{}, main_context: ctx}
+
+		client, server := net.Pipe()
+		defer client.Close()
+		go func() {
+			client.Write(input)
+			client.Close()
+		}()
+		go io.Copy(io.Discard, client)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer server.Close()
+			s.serveConnection(server)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection still being served after its input ended: %q", input)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package tcp_server
+
+import (
+	"net"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"sync"
+	"time"
+)
+
+// sessions maps robot UUIDs to their session's connection and pinger, so a
+// kick can close it and a ping request can reach it.
+type sessions struct {
+	mu    sync.Mutex
+	conns map[string]session
+}
+
+type session struct {
+	conn net.Conn
+	ping *pinger
+}
+
+func (s *sessions) add(uuid string, conn net.Conn, ping *pinger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[string]session)
+	}
+	s.conns[uuid] = session{conn: conn, ping: ping}
+}
+
+// remove forgets uuid's session if it is still conn; a reconnect may have
+// replaced it already.
+func (s *sessions) remove(uuid string, conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns[uuid].conn == conn {
+		delete(s.conns, uuid)
+	}
+}
+
+func (s *sessions) get(uuid string) (net.Conn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.conns[uuid]
+	return sess.conn, ok
+}
+
+func (s *sessions) getSession(uuid string) (session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.conns[uuid]
+	return sess, ok
+}
+
+// closeAll closes every session's connection and returns how many there
+// were.
+func (s *sessions) closeAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.conns {
+		sess.conn.Close()
+	}
+	return len(s.conns)
+}
+
+// handleKick closes the session of a kicked robot held by this node. The
+// robot is told why before the connection closes:
+//
+//	KICKED <reason>
+func (s *TCPServer_t) handleKick(_ string, data any) {
+	kick, ok := events.DecodeKick(data)
+	if !ok {
+		return
+	}
+	conn, ok := s.sessions.get(kick.UUID)
+	if !ok {
+		return
+	}
+	shared.DebugPrint("Closing TCP session of kicked robot %s", kick.UUID)
+	go func() {
+		reason := kick.Reason
+		if reason == "" {
+			reason = "kicked"
+		}
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write([]byte("KICKED " + reason + "\n"))
+		conn.Close()
+	}()
+}
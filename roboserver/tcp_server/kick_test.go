@@ -0,0 +1,95 @@
+package tcp_server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"roboserver/comms"
+	"roboserver/shared/event_bus"
+	"roboserver/shared/events"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionsRemoveKeepsNewerConn(t *testing.T) {
+	var s sessions
+	old, _ := net.Pipe()
+	newer, _ := net.Pipe()
+	s.add("r1", old, nil)
+	s.add("r1", newer, nil)
+	s.remove("r1", old)
+	if conn, ok := s.get("r1"); !ok || conn != newer {
+		t.Fatal("Expected the reconnected session to survive the old one's cleanup")
+	}
+	s.remove("r1", newer)
+	if _, ok := s.get("r1"); ok {
+		t.Error("Expected the session to be removed")
+	}
+}
+
+func TestHandleKickClosesSession(t *testing.T) {
+	server, client := net.Pipe()
+	s := &TCPServer_t{}
+	s.sessions.add("r1", server, nil)
+	s.handleKick(events.RobotKicked, events.Kick{UUID: "r1", Reason: "misbehaving"})
+
+	line, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "KICKED misbehaving" {
+		t.Fatalf("Expected KICKED line, got %q (%v)", line, err)
+	}
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+}
+
+func TestPingRequestMeasuresRoundTrip(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	bus := comms.NewLocalBus(event_bus.NewEventBus(), nil)
+	s := &TCPServer_t{bus: bus}
+	p := &pinger{}
+	s.sessions.add("r1", server, p)
+	cancel, _ := bus.SubscribeMatching(events.IsRobotPing, s.handlePingRequest)
+	defer cancel()
+
+	// The robot answers the requested PING.
+	go func() {
+		line, err := bufio.NewReader(client).ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "PING ") {
+			return
+		}
+		s.handleSessionPong("PONG "+strings.TrimSpace(strings.TrimPrefix(line, "PING ")), "r1", p, nil)
+	}()
+
+	reply, err := comms.Request(context.Background(), bus, events.RobotPing("r1"), nil, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Expected a ping reply, got %v", err)
+	}
+	result, ok := events.DecodePingResult(reply)
+	if !ok || result.UUID != "r1" || result.RTTMs < 0 {
+		t.Errorf("Unexpected ping result %+v", reply)
+	}
+	if len(p.requested) != 0 {
+		t.Error("Expected the answered PING to be forgotten")
+	}
+
+	// Robots without a session here are left to their node.
+	if _, err := comms.Request(context.Background(), bus, events.RobotPing("r2"), nil, 20*time.Millisecond); !errors.Is(err, comms.ErrRequestTimeout) {
+		t.Errorf("Expected a timeout for an unknown robot, got %v", err)
+	}
+}
+
+func TestPingerDropsStaleRequests(t *testing.T) {
+	p := &pinger{}
+	now := time.Now()
+	stale, _ := p.request(comms.RequestEvent{ID: "a"}, now.Add(-2*requestedPingTTL))
+	fresh, _ := p.request(comms.RequestEvent{ID: "b"}, now)
+	if _, _, ok := p.answer(stale, now); ok {
+		t.Error("Expected a PING older than the TTL to be dropped")
+	}
+	if _, req, ok := p.answer(fresh, now); !ok || req == nil || req.ID != "b" {
+		t.Errorf("Expected the fresh PING to match its request, got %v", req)
+	}
+}
@@ -0,0 +1,106 @@
+package tcp_server
+
+import (
+	"net"
+	"roboserver/shared"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats is a snapshot of the TCP server's connection counters.
+type ConnStats struct {
+	Active       int64 `json:"active"`
+	Accepted     int64 `json:"accepted"`
+	Refused      int64 `json:"refused"` // turned away because the server was at tcp_max_connections
+	AcceptErrors int64 `json:"accept_errors"`
+	Limit        int   `json:"limit"` // 0 = unlimited
+}
+
+var (
+	statActive       atomic.Int64
+	statAccepted     atomic.Int64
+	statRefused      atomic.Int64
+	statAcceptErrors atomic.Int64
+	statLimit        atomic.Int64
+)
+
+// Stats returns the current connection counters. Counters are process-wide
+// and survive server restarts within the process.
+func Stats() ConnStats {
+	return ConnStats{
+		Active:       statActive.Load(),
+		Accepted:     statAccepted.Load(),
+		Refused:      statRefused.Load(),
+		AcceptErrors: statAcceptErrors.Load(),
+		Limit:        int(statLimit.Load()),
+	}
+}
+
+// connLimiter caps concurrent connections with a counting semaphore. A nil
+// limiter admits everything.
+type connLimiter struct {
+	slots chan struct{}
+}
+
+func newConnLimiter(max int) *connLimiter {
+	statLimit.Store(int64(max))
+	if max <= 0 {
+		return nil
+	}
+	return &connLimiter{slots: make(chan struct{}, max)}
+}
+
+// tryAcquire takes a slot without blocking. Refusing immediately (rather than
+// leaving the client in the kernel backlog) lets well-behaved robots back off
+// and keeps a connection storm from pinning file descriptors.
+func (l *connLimiter) tryAcquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *connLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// refuse tells the client why it is being dropped and closes the connection.
+func refuse(conn net.Conn) {
+	statRefused.Add(1)
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("ERROR SERVER_BUSY\n"))
+	conn.Close()
+}
+
+// nextAcceptBackoff doubles the delay after a failed Accept, starting at 5ms
+// and capped at 1s, so a persistent failure (e.g. fd exhaustion) doesn't
+// hot-loop the CPU.
+func nextAcceptBackoff(prev time.Duration) time.Duration {
+	if prev == 0 {
+		return 5 * time.Millisecond
+	}
+	if prev < time.Second {
+		prev *= 2
+	}
+	if prev > time.Second {
+		prev = time.Second
+	}
+	return prev
+}
+
+// rejectOversizedLine reports a line longer than limits.tcp_line. The
+// scanner can't resync mid-line, so the caller closes the connection after.
+func (s *TCPServer_t) rejectOversizedLine(conn net.Conn) {
+	limit := shared.AppConfig.Limits.TCPLineBytes()
+	shared.DebugPrint("TCP %s: %v", conn.RemoteAddr(), &shared.PayloadTooLargeError{Source: "tcp", Size: -1, Limit: limit})
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("ERROR MESSAGE_TOO_LARGE\n"))
+}
@@ -0,0 +1,67 @@
+package tcp_server
+
+import (
+	"context"
+	"errors"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"time"
+)
+
+// onboardClaimTTL is how long the record of a redeemed onboarding code is
+// kept for GET /robot/onboard/{code}.
+const onboardClaimTTL = 24 * time.Hour
+
+// completeOnboarding sets up a robot that registered with an onboarding
+// code: its name, zone and tags, and the plan's config as desired shadow
+// state. A failed step doesn't undo the registration; it is recorded on the
+// claim and the robot.onboarded event instead.
+func (s *TCPServer_t) completeOnboarding(pc *database.PairingCode, uuid, deviceType string) {
+	plan := pc.Onboard
+	rds := s.db.Redis()
+	ctx, cancel := context.WithTimeout(s.main_context, 5*time.Second)
+	defer cancel()
+
+	var errs []error
+	labels := &database.RobotLabels{Name: plan.Name, Zone: plan.Zone, Tags: plan.Tags}
+	if err := rds.SetRobotLabels(ctx, uuid, labels); err != nil {
+		errs = append(errs, err)
+	} else if s.bus != nil {
+		s.bus.PublishEvent(events.RobotLabels(uuid), events.LabelsChanged{UUID: uuid, Name: plan.Name, Zone: plan.Zone, Tags: plan.Tags})
+	}
+	if len(plan.Config) > 0 {
+		if _, err := handler_engine.UpdateDesired(ctx, s.bus, rds, uuid, plan.Config, 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	claim := &database.OnboardClaim{
+		Code:       pc.Code,
+		UUID:       uuid,
+		DeviceType: deviceType,
+		Name:       plan.Name,
+		ClaimedAt:  time.Now().Unix(),
+	}
+	if err := errors.Join(errs...); err != nil {
+		claim.Error = err.Error()
+		shared.DebugPrint("Onboarding %s as %q incomplete: %v", uuid, plan.Name, err)
+	} else {
+		shared.DebugPrint("Robot %s onboarded as %q (zone %q)", uuid, plan.Name, plan.Zone)
+	}
+	if err := rds.SetOnboardClaim(ctx, claim, onboardClaimTTL); err != nil {
+		shared.DebugPrint("Failed to record onboarding of %s: %v", uuid, err)
+	}
+	if s.bus != nil {
+		s.bus.PublishEvent(events.RobotOnboarded, events.Onboarded{
+			Code:       pc.Code,
+			UUID:       uuid,
+			DeviceType: deviceType,
+			Name:       plan.Name,
+			Zone:       plan.Zone,
+			By:         pc.CreatedBy,
+			Error:      claim.Error,
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package tcp_server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"roboserver/auth"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/maintenance"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.RobotLatency("{uuid}"),
+		Description: "Round-trip times of a robot's recent PING/PONG exchanges.",
+		Example:     database.LatencyStats{Samples: 10, LastMs: 12.5, MinMs: 8.1, MaxMs: 40.2, AvgMs: 14.3, P95Ms: 38.0},
+	})
+	events.Describe(events.Info{
+		Type:        events.RobotPing("{uuid}"),
+		Description: "Request to PING a robot over its TCP session now; the node holding the session replies with the round trip.",
+		Example:     events.PingResult{UUID: "rover-7", RTTMs: 12.5, Node: "node-a"},
+	})
+	events.Describe(events.Info{
+		Type:        events.RobotHeartbeat("{uuid}"),
+		Description: "A verified signed heartbeat, from any transport. Field names are not lowercased.",
+		Example:     auth.HeartbeatResult{UUID: "rover-7", IP: "10.0.0.5", Payload: &auth.HeartbeatPayload{Seq: 42, TTL: 60}},
+	})
+}
+
+// pinger tracks the outstanding PINGs of a TCP session. A new interval
+// PING replaces an unanswered one, so a lost PONG simply drops that sample.
+// PINGs sent for a ping request are tracked separately until answered or
+// requestedPingTTL has passed.
+type pinger struct {
+	mu        sync.Mutex
+	nonce     string
+	sentAt    time.Time
+	requested map[string]requestedPing // by nonce
+}
+
+type requestedPing struct {
+	req    comms.RequestEvent
+	sentAt time.Time
+}
+
+// requestedPingTTL bounds how long a requested PING waits for its PONG,
+// past the longest timeout GET /robot/{uuid}/ping accepts.
+const requestedPingTTL = time.Minute
+
+// request records a PING sent for req and returns its nonce.
+func (p *pinger) request(req comms.RequestEvent, now time.Time) (string, error) {
+	nonce, err := auth.GenerateNonce()
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n, r := range p.requested {
+		if now.Sub(r.sentAt) > requestedPingTTL {
+			delete(p.requested, n)
+		}
+	}
+	if p.requested == nil {
+		p.requested = make(map[string]requestedPing)
+	}
+	p.requested[nonce] = requestedPing{req: req, sentAt: now}
+	return nonce, nil
+}
+
+// answer matches a PONG nonce to an outstanding PING, returning the RTT and,
+// for a requested PING, its request.
+func (p *pinger) answer(nonce string, now time.Time) (time.Duration, *comms.RequestEvent, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.nonce != "" && nonce == p.nonce {
+		p.nonce = ""
+		return now.Sub(p.sentAt), nil, true
+	}
+	if r, ok := p.requested[nonce]; ok {
+		delete(p.requested, nonce)
+		return now.Sub(r.sentAt), &r.req, true
+	}
+	return 0, nil, false
+}
+
+// pingLoop sends "PING <nonce>" at the configured interval until ctx ends.
+// The robot answers with "PONG <nonce>", which handleSessionPong consumes.
+func (s *TCPServer_t) pingLoop(ctx context.Context, conn net.Conn, p *pinger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		nonce, err := auth.GenerateNonce()
+		if err != nil {
+			continue
+		}
+		p.mu.Lock()
+		p.nonce = nonce
+		p.sentAt = time.Now()
+		p.mu.Unlock()
+
+		if _, err := conn.Write([]byte(fmt.Sprintf("PING %s\n", nonce))); err != nil {
+			return
+		}
+	}
+}
+
+// handlePingRequest answers a RobotPing request for a robot whose session
+// this node holds: it sends a PING, and handleSessionPong replies when the
+// PONG arrives. Requests for other robots are left to their node.
+func (s *TCPServer_t) handlePingRequest(eventType string, data any) {
+	uuid, _, _ := events.ParseRobotTopic(eventType)
+	req, ok := comms.DecodeRequestEvent(data)
+	if !ok {
+		return
+	}
+	sess, ok := s.sessions.getSession(uuid)
+	if !ok || sess.ping == nil {
+		return
+	}
+	nonce, err := sess.ping.request(req, time.Now())
+	if err != nil {
+		return
+	}
+	// Don't hold up the publisher on a slow connection.
+	go sess.conn.Write([]byte(fmt.Sprintf("PING %s\n", nonce)))
+}
+
+// handleSessionPong records the RTT for a PONG matching an outstanding PING
+// and publishes it on robot.{uuid}.latency; a requested PING also gets its
+// reply. Stale or unknown PONGs are ignored.
+func (s *TCPServer_t) handleSessionPong(line, uuid string, p *pinger, rds *database.RedisHandler) {
+	nonce := strings.TrimSpace(strings.TrimPrefix(line, "PONG"))
+
+	rtt, req, ok := p.answer(nonce, time.Now())
+	if !ok {
+		return
+	}
+	if req != nil && s.bus != nil {
+		comms.Reply(s.bus, *req, events.PingResult{UUID: uuid, RTTMs: float64(rtt.Microseconds()) / 1000, Node: shared.NodeID()})
+	}
+
+	if rds == nil {
+		return
+	}
+	if err := rds.RecordLatency(s.main_context, uuid, rtt, shared.AppConfig.Database.Redis.UserTTL()); err != nil {
+		shared.DebugPrint("Failed to record latency for %s: %v", uuid, err)
+		return
+	}
+
+	if s.bus != nil {
+		samples, err := rds.GetLatencySamples(s.main_context, uuid)
+		if err != nil {
+			return
+		}
+		stats := database.SummarizeLatency(samples, shared.AppConfig.Timeouts.LatencyDegradedThreshold())
+		stats.Maintenance = maintenance.Registry.Active(uuid)
+		s.bus.PublishEvent(events.RobotLatency(uuid), stats)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -13,26 +12,37 @@ import (
 	"roboserver/database"
 	"roboserver/handler_engine"
 	"roboserver/shared"
+	"roboserver/shared/adaptive"
+	"roboserver/shared/autoaccept"
+	"roboserver/shared/events"
+	"roboserver/shared/ipconflict"
+	"roboserver/shared/registrations"
+	"roboserver/shared/robot_status"
+	"roboserver/shared/telemetry"
+	"roboserver/shared/tracing"
+	"roboserver/shared/tracked"
 	"strings"
 	"time"
 )
 
-// MaxTCPMessageSize is the maximum allowed size for a single TCP message line.
-// Prevents memory exhaustion from maliciously large payloads.
-const MaxTCPMessageSize = 64 * 1024 // 64 KB
-
 type TCPServer_t struct {
 	bus          comms.Bus
 	db           database.DBManager
 	listener     net.Listener
 	main_context context.Context
+	codec        Codec // server.tcp_codec; robots may switch with CODEC <name>
+	sessions     sessions
 }
 
 func Start(ctx context.Context, bus comms.Bus, dbManager database.DBManager) error {
 	port := shared.AppConfig.Server.TCPPort
 
+	codec, err := LookupCodec(shared.AppConfig.Server.TCPCodecName())
+	if err != nil {
+		shared.DebugPanic("Invalid server.tcp_codec: %v", err)
+	}
+
 	var listener net.Listener
-	var err error
 
 	if shared.AppConfig.Server.TLS.Enabled {
 		cert, tlsErr := tls.LoadX509KeyPair(
@@ -60,8 +70,22 @@ func Start(ctx context.Context, bus comms.Bus, dbManager database.DBManager) err
 		db:           dbManager,
 		listener:     listener,
 		main_context: ctx,
+		codec:        codec,
 	}
 
+	if cancel, err := bus.SubscribeEvent(events.RobotKicked, s.handleKick); err != nil {
+		shared.DebugPrint("TCP server not watching kicks: %v", err)
+	} else {
+		defer cancel()
+	}
+	if cancel, err := bus.SubscribeMatching(events.IsRobotPing, s.handlePingRequest); err != nil {
+		shared.DebugPrint("TCP server not answering ping requests: %v", err)
+	} else {
+		defer cancel()
+	}
+
+	limiter := newConnLimiter(shared.AppConfig.Server.TCPMaxConnections)
+
 	go func() {
 		shared.DebugPrint("TCP server listening on port %d", port)
 		var backoff time.Duration
@@ -76,13 +100,8 @@ func Start(ctx context.Context, bus comms.Bus, dbManager database.DBManager) err
 					return
 				default:
 				}
-				// Transient error: back off exponentially up to 1s so we don't
-				// burn CPU hot-looping on a persistent failure (e.g. fd exhaustion).
-				if backoff == 0 {
-					backoff = 5 * time.Millisecond
-				} else if backoff < time.Second {
-					backoff *= 2
-				}
+				statAcceptErrors.Add(1)
+				backoff = nextAcceptBackoff(backoff)
 				shared.DebugPrint("TCP accept error: %v (retrying in %s)", err, backoff)
 				select {
 				case <-time.After(backoff):
@@ -92,8 +111,21 @@ func Start(ctx context.Context, bus comms.Bus, dbManager database.DBManager) err
 				continue
 			}
 			backoff = 0
+			if !limiter.tryAcquire() {
+				shared.DebugPrint("Refusing connection from %s: at connection limit", conn.RemoteAddr())
+				refuse(conn)
+				continue
+			}
+			statAccepted.Add(1)
+			statActive.Add(1)
 			shared.DebugPrint("Accepted connection from %s", conn.RemoteAddr())
-			go s.handleConnection(conn)
+			tracked.Go("tcp.conn", func() {
+				defer func() {
+					statActive.Add(-1)
+					limiter.release()
+				}()
+				s.handleConnection(conn)
+			})
 		}
 	}()
 
@@ -103,28 +135,28 @@ func Start(ctx context.Context, bus comms.Bus, dbManager database.DBManager) err
 		shared.DebugPrint("Error shutting down TCP server:", err)
 		return fmt.Errorf("error shutting down TCP server: %w", err)
 	}
+	// Robots were sent the shutdown notice and their handlers have stopped;
+	// close the sessions so they see the disconnect now.
+	if n := s.sessions.closeAll(); n > 0 {
+		shared.DebugPrint("Closed %d TCP session(s)", n)
+	}
 	shared.DebugPrint("TCP server has shut down gracefully.")
 	return nil
 }
 
 // remoteIP safely extracts the IP from any net.Conn without panicking on
-// unexpected address types (e.g. Unix sockets, TLS wrappers in tests).
+// unexpected address types (e.g. Unix sockets, TLS wrappers in tests). IPv6
+// addresses keep their zone and IPv4-mapped ones become IPv4.
 func remoteIP(conn net.Conn) string {
 	addr := conn.RemoteAddr()
 	if addr == nil {
 		return ""
 	}
-	if tcp, ok := addr.(*net.TCPAddr); ok {
-		return tcp.IP.String()
-	}
-	host, _, err := net.SplitHostPort(addr.String())
-	if err != nil {
-		return addr.String()
-	}
-	return host
+	return shared.CanonicalIP(addr.String())
 }
 
-// handleConnection dispatches to AUTH or REGISTER based on the first command.
+// handleConnection serves one robot connection and closes it. A panic while
+// handling untrusted input only drops that connection.
 func (s *TCPServer_t) handleConnection(conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -132,9 +164,16 @@ func (s *TCPServer_t) handleConnection(conn net.Conn) {
 		}
 		conn.Close()
 	}()
+	s.serveConnection(conn)
+}
 
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxTCPMessageSize)
+// serveConnection dispatches to AUTH or REGISTER based on the first command.
+// A CODEC command before that switches the connection's wire format. It is
+// separate from handleConnection so fuzz tests see panics.
+func (s *TCPServer_t) serveConnection(conn net.Conn) {
+	codec := s.defaultCodec()
+	conn = withCodec(conn, codec)
+	scanner := newCodecScanner(conn, codec)
 
 	for scanner.Scan() {
 		message := strings.TrimSpace(scanner.Text())
@@ -144,11 +183,20 @@ func (s *TCPServer_t) handleConnection(conn net.Conn) {
 		shared.DebugPrint("Received: %s from %s", message, conn.RemoteAddr())
 
 		switch {
+		case strings.HasPrefix(message, "CODEC "):
+			if next, ok := s.negotiateCodec(conn, strings.TrimSpace(strings.TrimPrefix(message, "CODEC "))); ok {
+				// A fresh scanner: the split func is fixed once scanning starts.
+				conn = withCodec(conn, next)
+				scanner = newCodecScanner(conn, next)
+			}
 		case message == "AUTH":
 			s.handleAuthAndSession(conn, scanner)
 			return
 		case message == "REGISTER":
-			s.handleRegisterAndSession(conn, scanner)
+			s.handleRegisterAndSession(conn, scanner, "")
+			return
+		case strings.HasPrefix(message, "REGISTER "):
+			s.handleRegisterAndSession(conn, scanner, auth.NormalizePairingCode(strings.TrimPrefix(message, "REGISTER ")))
 			return
 		case strings.HasPrefix(message, "HEARTBEAT "):
 			s.handleHeartbeat(conn, message)
@@ -162,24 +210,58 @@ func (s *TCPServer_t) handleConnection(conn net.Conn) {
 
 	if err := scanner.Err(); err != nil {
 		shared.DebugPrint("Error reading from connection: %v", err)
+		if errors.Is(err, bufio.ErrTooLong) {
+			s.rejectOversizedLine(conn)
+		}
 	}
 }
 
-// readHandshakeInput sends a prompt to the robot, waits for a response with a timeout, and returns the trimmed response.
-func (s *TCPServer_t) readHandshakeInput(conn net.Conn, scanner *bufio.Scanner, prompt string, emptyError string) (string, bool) {
+// readHandshakeInput sends a prompt to the robot, waits for a response with a
+// timeout, and returns the trimmed response. On failure it returns the
+// reason instead: emptyError (also sent to the robot) or DISCONNECTED.
+func (s *TCPServer_t) readHandshakeInput(conn net.Conn, scanner *bufio.Scanner, prompt string, emptyError string) (val string, failure string) {
 	conn.Write([]byte(prompt + "\n"))
 	conn.SetReadDeadline(time.Now().Add(shared.AppConfig.Timeouts.HandshakeTimeout()))
 	if !scanner.Scan() {
-		return "", false
+		return "", "DISCONNECTED"
 	}
-	val := strings.TrimSpace(scanner.Text())
+	val = strings.TrimSpace(scanner.Text())
 	if val == "" {
 		conn.Write([]byte("ERROR " + emptyError + "\n"))
-		return "", false
+		return "", emptyError
+	}
+	return val, ""
+}
+
+// writeRegistrationError reports a rejected registration field as
+// "ERROR <CODE>", falling back to REGISTRATION_FAILED for untyped errors.
+// An unknown device type is followed by the valid ones:
+// "ERROR UNKNOWN_DEVICE_TYPE env_sensor,rover". It returns the code.
+func writeRegistrationError(conn net.Conn, err error) string {
+	code, detail := "REGISTRATION_FAILED", ""
+	var regErr *handler_engine.RegistrationError
+	if errors.As(err, &regErr) {
+		code = regErr.Code
+		if len(regErr.ValidTypes) > 0 {
+			detail = " " + strings.Join(regErr.ValidTypes, ",")
+		}
 	}
-	return val, true
+	conn.Write([]byte("ERROR " + code + detail + "\n"))
+	return code
 }
 
+// recordRegistrationFailure adds a failed REGISTER to the failed
+// registration log, and to Redis with auth.persist_registration_failures.
+func (s *TCPServer_t) recordRegistrationFailure(f registrations.Failure) {
+	f = registrations.Record(f)
+	shared.DebugPrint("Registration from %s (%s) failed: %s", f.IP, f.DeviceID, f.Reason)
+	if !shared.AppConfig.Auth.PersistRegistrationFailures || s.db == nil || s.db.Redis() == nil {
+		return
+	}
+	if err := s.db.Redis().AddRegistrationFailure(s.main_context, f); err != nil {
+		shared.DebugPrint("Failed to persist registration failure: %v", err)
+	}
+}
 
 // handleAuthAndSession performs the cryptographic handshake against PostgreSQL,
 // spawns a handler process, and enters session mode.
@@ -201,9 +283,14 @@ func (s *TCPServer_t) handleAuthAndSession(conn net.Conn, scanner *bufio.Scanner
 	// Reuse the outer scanner so any already-buffered bytes aren't lost.
 	result, err := auth.PerformHandshakeWithScanner(s.main_context, conn, scanner, pg, rds)
 	if err != nil {
+		var conflict *ipconflict.Error
+		if errors.As(err, &conflict) {
+			handler_engine.ReportIPConflict(s.bus, &conflict.Conflict)
+		}
 		shared.DebugPrint("Handshake failed: %v", err)
 		return
 	}
+	handler_engine.ReportIPConflict(s.bus, result.IPConflict)
 
 	shared.DebugPrint("Robot %s (%s) authenticated, spawning handler", result.UUID, result.DeviceType)
 	s.enterSessionMode(conn, scanner, result, true)
@@ -211,18 +298,22 @@ func (s *TCPServer_t) handleAuthAndSession(conn net.Conn, scanner *bufio.Scanner
 
 // handleRegisterAndSession collects robot info, waits for user approval via
 // Redis pub/sub, then enters session mode if accepted. The robot is stored
-// only in Redis (ephemeral) unless it later sends PERSIST.
+// only in Redis (ephemeral) unless it later sends PERSIST. A robot that
+// sent "REGISTER <pairing code>" with a valid code, or that an auto_accept
+// policy admits, skips the approval wait.
 //
 // Protocol:
+//   Robot:  REGISTER [<pairing_code>]
 //   Server: REGISTER_CHALLENGE
 //   Robot:  UUID
 //   Server: SEND_DEVICE_TYPE
 //   Robot:  <device_type>
 //   Server: SEND_PUBLIC_KEY
 //   Robot:  <public_key_hex>
-//   Server: REGISTER_PENDING (waiting for user approval)
+//   Server: REGISTER_PENDING (waiting for user approval; not sent when
+//           paired or auto-accepted)
 //   Server: REGISTER_OK <jwt>  |  REGISTER_REJECTED
-func (s *TCPServer_t) handleRegisterAndSession(conn net.Conn, scanner *bufio.Scanner) {
+func (s *TCPServer_t) handleRegisterAndSession(conn net.Conn, scanner *bufio.Scanner, pairingCode string) {
 	rds := s.db.Redis()
 	pg := s.db.Postgres()
 	if rds == nil {
@@ -231,103 +322,97 @@ func (s *TCPServer_t) handleRegisterAndSession(conn net.Conn, scanner *bufio.Sca
 	}
 
 	ip := remoteIP(conn)
+	attempt := registrations.Failure{IP: ip}
+	fail := func(reason string) {
+		attempt.Reason = reason
+		s.recordRegistrationFailure(attempt)
+	}
+	reject := func(code string) {
+		conn.Write([]byte("ERROR " + code + "\n"))
+		fail(code)
+	}
 
 	// Step 1: Collect UUID
-	uuid, ok := s.readHandshakeInput(conn, scanner, "REGISTER_CHALLENGE", "EMPTY_UUID")
-	if !ok {
+	uuid, failure := s.readHandshakeInput(conn, scanner, "REGISTER_CHALLENGE", "EMPTY_UUID")
+	if failure != "" {
+		fail(failure)
+		return
+	}
+	attempt.DeviceID = uuid
+	if err := handler_engine.ValidateUUID(uuid); err != nil {
+		fail(writeRegistrationError(conn, err))
+		return
+	}
+	if err := auth.CheckBan(s.main_context, rds, uuid, ip); err != nil {
+		reject("BANNED")
 		return
 	}
 
 	// Check if UUID already exists in PostgreSQL (permanently registered)
 	if pg != nil {
 		if existing, _ := pg.GetRobotByUUID(s.main_context, uuid); existing != nil {
-			conn.Write([]byte("ERROR UUID_ALREADY_REGISTERED\n"))
+			reject("UUID_ALREADY_REGISTERED")
 			return
 		}
 	}
 
 	// Check if UUID already has an active session in Redis
 	if active, _ := rds.GetActiveRobot(s.main_context, uuid); active != nil {
-		conn.Write([]byte("ERROR UUID_ALREADY_ACTIVE\n"))
+		reject("UUID_ALREADY_ACTIVE")
 		return
 	}
 
 	// Check if UUID already has a pending registration
 	if pending, _ := rds.GetPendingRobot(s.main_context, uuid); pending != nil {
-		conn.Write([]byte("ERROR UUID_ALREADY_PENDING\n"))
+		reject("UUID_ALREADY_PENDING")
 		return
 	}
 
 	// Step 2: Collect device type
-	deviceType, ok := s.readHandshakeInput(conn, scanner, "SEND_DEVICE_TYPE", "EMPTY_DEVICE_TYPE")
-	if !ok {
+	deviceType, failure := s.readHandshakeInput(conn, scanner, "SEND_DEVICE_TYPE", "EMPTY_DEVICE_TYPE")
+	if failure != "" {
+		fail(failure)
 		return
 	}
-	if !handler_engine.IsValidDeviceType(deviceType) {
-		conn.Write([]byte("ERROR INVALID_DEVICE_TYPE\n"))
+	attempt.DeviceType = deviceType
+	if err := handler_engine.ValidateDeviceType(deviceType); err != nil {
+		fail(writeRegistrationError(conn, err))
 		return
 	}
 
 	// Step 3: Collect public key
-	publicKey, ok := s.readHandshakeInput(conn, scanner, "SEND_PUBLIC_KEY", "EMPTY_PUBLIC_KEY")
-	if !ok {
+	publicKey, failure := s.readHandshakeInput(conn, scanner, "SEND_PUBLIC_KEY", "EMPTY_PUBLIC_KEY")
+	if failure != "" {
+		fail(failure)
 		return
 	}
 
 	// Clear read deadline for the wait phase
 	conn.SetReadDeadline(time.Time{})
 
-	// Step 4: Store as pending in Redis
-	pending := &database.PendingRobot{
-		UUID:        uuid,
-		IP:          ip,
-		DeviceType:  deviceType,
-		PublicKey:   publicKey,
-		RequestedAt: time.Now().Unix(),
+	// Steps 4-6: approval, by pairing code, an auto_accept policy or an
+	// operator
+	var pairing *database.PairingCode
+	if pairingCode != "" {
+		pairing, failure = s.redeemPairingCode(conn, pairingCode, uuid, deviceType)
+	} else if policy := handler_engine.AutoAcceptPolicy(s.main_context, rds, uuid, deviceType, ip); policy != "" {
+		s.reportAutoAccepted(uuid, ip, deviceType, policy)
+	} else {
+		failure = s.awaitRegistrationApproval(conn, uuid, ip, deviceType, publicKey)
 	}
-
-	pendingTTL := 5 * time.Minute // Pending registrations expire after 5 minutes
-	if err := rds.SetPendingRobot(s.main_context, pending, pendingTTL); err != nil {
-		shared.DebugPrint("Failed to store pending robot %s: %v", uuid, err)
-		conn.Write([]byte("ERROR REGISTRATION_FAILED\n"))
+	if failure != "" {
+		fail(failure)
 		return
 	}
 
-	// Step 5: Publish event for frontend/terminal notification
-	eventData, err := json.Marshal(map[string]string{
-		"device_id":  uuid,
-		"ip":         ip,
-		"robot_type": deviceType,
-	})
-	if err != nil {
-		shared.DebugPrint("Failed to marshal registration event for %s: %v", uuid, err)
-	} else if s.bus != nil {
-		s.bus.PublishEvent("robot.registering", string(eventData))
-	}
-
-	conn.Write([]byte("REGISTER_PENDING\n"))
-	shared.DebugPrint("Robot %s pending registration approval", uuid)
-
-	// Step 6: Wait for accept/reject via comms bus (Redis pub/sub in local mode)
-	waitCtx, waitCancel := context.WithTimeout(s.main_context, pendingTTL)
-	defer waitCancel()
-
-	accepted, err := s.bus.WaitForRegistrationResponse(waitCtx, uuid)
-	rds.RemovePendingRobot(s.main_context, uuid)
-
+	// Step 7: Accepted — apply the IP conflict policy, issue JWT, store as
+	// active in Redis
+	conflict, err := auth.CheckIPConflict(s.main_context, pg, rds, uuid, ip, publicKey)
+	handler_engine.ReportIPConflict(s.bus, conflict)
 	if err != nil {
-		shared.DebugPrint("Registration wait expired for %s: %v", uuid, err)
-		conn.Write([]byte("ERROR REGISTRATION_TIMEOUT\n"))
+		reject("IP_CONFLICT")
 		return
 	}
-
-	if !accepted {
-		shared.DebugPrint("Robot %s registration rejected", uuid)
-		conn.Write([]byte("REGISTER_REJECTED\n"))
-		return
-	}
-
-	// Step 7: Accepted — issue JWT, store as active in Redis
 	sessionID := auth.GenerateSessionID()
 	jwt, err := auth.IssueSessionJWT(uuid, deviceType, ip, sessionID)
 	if err != nil {
@@ -353,6 +438,12 @@ func (s *TCPServer_t) handleRegisterAndSession(conn net.Conn, scanner *bufio.Sca
 		shared.DebugPrint("Failed to store public key for %s: %v", uuid, err)
 	}
 
+	// An onboarding code names, labels and configures the robot before
+	// its handler starts, so the handler sees the desired state on connect.
+	if pairing != nil && pairing.Onboard != nil {
+		s.completeOnboarding(pairing, uuid, deviceType)
+	}
+
 	conn.Write([]byte(fmt.Sprintf("REGISTER_OK %s\n", jwt)))
 	shared.DebugPrint("Robot %s registration accepted, entering session mode", uuid)
 
@@ -366,6 +457,95 @@ func (s *TCPServer_t) handleRegisterAndSession(conn net.Conn, scanner *bufio.Sca
 	s.enterSessionMode(conn, scanner, result, false)
 }
 
+// awaitRegistrationApproval stores the robot as pending and blocks until
+// an operator accepts or rejects it. It returns "" if accepted, otherwise
+// the failure reason (the robot has already been told).
+func (s *TCPServer_t) awaitRegistrationApproval(conn net.Conn, uuid, ip, deviceType, publicKey string) string {
+	rds := s.db.Redis()
+
+	// Step 4: Store as pending in Redis
+	pending := &database.PendingRobot{
+		UUID:        uuid,
+		IP:          ip,
+		DeviceType:  deviceType,
+		PublicKey:   publicKey,
+		RequestedAt: time.Now().Unix(),
+	}
+
+	pendingTTL := shared.AppConfig.Timeouts.RegistrationTimeout()
+	if err := rds.SetPendingRobot(s.main_context, pending, pendingTTL); err != nil {
+		shared.DebugPrint("Failed to store pending robot %s: %v", uuid, err)
+		conn.Write([]byte("ERROR REGISTRATION_FAILED\n"))
+		return "REGISTRATION_FAILED"
+	}
+
+	// Step 5: Publish event for frontend/terminal notification
+	if s.bus != nil {
+		s.bus.PublishEvent(events.Register(uuid, ip, deviceType))
+	}
+
+	conn.Write([]byte("REGISTER_PENDING\n"))
+	shared.DebugPrint("Robot %s pending registration approval", uuid)
+	s.setRobotStatus(uuid, robot_status.Registering, "register")
+
+	// Step 6: Wait for accept/reject via comms bus (Redis pub/sub in local mode)
+	waitCtx, waitCancel := context.WithTimeout(s.main_context, pendingTTL)
+	defer waitCancel()
+
+	accepted, err := s.bus.WaitForRegistrationResponse(waitCtx, uuid)
+	rds.RemovePendingRobot(s.main_context, uuid)
+
+	if err != nil {
+		shared.DebugPrint("Registration wait expired for %s: %v", uuid, err)
+		conn.Write([]byte("ERROR REGISTRATION_TIMEOUT\n"))
+		s.setRobotStatus(uuid, robot_status.Offline, "registration_timeout")
+		return "REGISTRATION_TIMEOUT"
+	}
+
+	if !accepted {
+		shared.DebugPrint("Robot %s registration rejected", uuid)
+		conn.Write([]byte("REGISTER_REJECTED\n"))
+		s.setRobotStatus(uuid, robot_status.Offline, "registration_rejected")
+		return "REJECTED"
+	}
+	return ""
+}
+
+// reportAutoAccepted announces a registration an auto_accept policy let
+// through in place of an operator.
+func (s *TCPServer_t) reportAutoAccepted(uuid, ip, deviceType, policy string) {
+	shared.DebugPrint("Robot %s auto-accepted by policy %s", uuid, policy)
+	if s.bus != nil {
+		s.bus.PublishEvent(events.RobotAutoAccepted, autoaccept.Accepted{
+			UUID:       uuid,
+			IP:         ip,
+			DeviceType: deviceType,
+			Policy:     policy,
+			Time:       time.Now().Unix(),
+		})
+	}
+}
+
+// redeemPairingCode consumes a pairing code in place of operator approval.
+// It returns the code and "" if it admits this robot, otherwise the failure
+// reason (the robot has already been told). A code is spent even when it is
+// rejected for the wrong type.
+func (s *TCPServer_t) redeemPairingCode(conn net.Conn, code, uuid, deviceType string) (*database.PairingCode, string) {
+	pc, err := s.db.Redis().ConsumePairingCode(s.main_context, code)
+	if err != nil {
+		shared.DebugPrint("Robot %s presented an invalid pairing code", uuid)
+		conn.Write([]byte("ERROR INVALID_PAIRING_CODE\n"))
+		return nil, "INVALID_PAIRING_CODE"
+	}
+	if pc.DeviceType != "" && pc.DeviceType != deviceType {
+		shared.DebugPrint("Robot %s used a pairing code for %s as %s", uuid, pc.DeviceType, deviceType)
+		conn.Write([]byte("ERROR PAIRING_CODE_WRONG_TYPE\n"))
+		return nil, "PAIRING_CODE_WRONG_TYPE"
+	}
+	shared.DebugPrint("Robot %s registered with a pairing code from %s", uuid, pc.CreatedBy)
+	return pc, ""
+}
+
 // enterSessionMode either reattaches an existing handler or spawns a new one,
 // then forwards all subsequent TCP lines to the handler.
 // If isPersisted is false, the robot was registered via REGISTER and can send
@@ -390,10 +570,10 @@ func (s *TCPServer_t) enterSessionMode(conn net.Conn, scanner *bufio.Scanner, re
 	} else if handler_engine.HandlerManager.TryStartSpawning(result.UUID) {
 		var err error
 		func() {
-			// Release spawning flag even if SpawnHandlerProcess panics, so
+			// Release spawning flag even if SpawnSupervised panics, so
 			// other connections for the same UUID aren't permanently wedged.
 			defer handler_engine.HandlerManager.FinishSpawning(result.UUID)
-			hp, err = handler_engine.SpawnHandlerProcess(
+			hp, err = handler_engine.SpawnSupervised(
 				s.main_context,
 				result.UUID, result.DeviceType, result.IP, result.SessionID,
 				pg, rds, s.bus,
@@ -438,10 +618,29 @@ func (s *TCPServer_t) enterSessionMode(conn net.Conn, scanner *bufio.Scanner, re
 		}
 	}
 
+	// Optional link latency measurement; stops when the session ends.
+	// GET /robot/{uuid}/ping reaches the pinger through s.sessions.
+	sessCtx, sessCancel := context.WithCancel(s.main_context)
+	defer sessCancel()
+	ping := &pinger{}
+
+	s.sessions.add(result.UUID, conn, ping)
+	defer s.sessions.remove(result.UUID, conn)
+
 	persisted := isPersisted
+	if interval := shared.AppConfig.Timeouts.PingIntervalDuration(); interval > 0 {
+		tracked.Go("tcp.ping", func() { s.pingLoop(sessCtx, conn, ping, interval) })
+	}
+
+	var dataSeq telemetry.Sequencer
+	clock := s.loadClockOffset(result.UUID, rds)
+	var adapt *adaptive.Tracker
+	if cfg := &shared.AppConfig.AdaptivePolling; adaptive.Applies(cfg, result.DeviceType) {
+		adapt = adaptive.NewTracker(adaptive.FromConfig(cfg))
+	}
 
 	// Session mode: forward all incoming TCP lines to the handler process,
-	// but intercept PERSIST commands.
+	// but intercept PERSIST, PONG, WEBRTC, DATA and TIME commands.
 	for scanner.Scan() {
 		select {
 		case <-s.main_context.Done():
@@ -450,19 +649,46 @@ func (s *TCPServer_t) enterSessionMode(conn net.Conn, scanner *bufio.Scanner, re
 		default:
 		}
 
+		received := time.Now()
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 
+		ctx, span := traceLine(s.main_context, result.UUID, line)
+		switch {
 		// Intercept PERSIST command
-		if line == "PERSIST" && !persisted {
+		case line == "PERSIST" && !persisted:
 			s.handlePersist(conn, result, rds, pg)
 			persisted = true
-			continue
-		}
 
-		hp.SendIncoming(line)
+		// Intercept PONG replies to server-initiated PINGs
+		case strings.HasPrefix(line, "PONG "):
+			s.handleSessionPong(line, result.UUID, ping, rds)
+
+		// Intercept WebRTC answers/candidates for browser signaling
+		case strings.HasPrefix(line, "WEBRTC "):
+			s.handleWebRTCSignal(conn, line, result.UUID)
+
+		// Structured telemetry
+		case strings.HasPrefix(line, "DATA "):
+			s.handleData(conn, line, result.UUID, hp, &dataSeq, clock, adapt)
+
+		// Clock synchronization
+		case line == "TIME" || strings.HasPrefix(line, "TIME "):
+			clock = s.handleTime(conn, line, result.UUID, rds, received, clock)
+
+		default:
+			err := hp.SendIncomingTraced(ctx, line)
+			if errors.Is(err, shared.ErrPayloadTooLarge) {
+				conn.Write([]byte("ERROR MESSAGE_TOO_LARGE\n"))
+			}
+			span.SetError(err)
+		}
+		span.End()
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		s.rejectOversizedLine(conn)
 	}
 
 	// Connection closed — notify handler but don't kill it (Phase 3 keeps it alive)
@@ -470,9 +696,40 @@ func (s *TCPServer_t) enterSessionMode(conn net.Conn, scanner *bufio.Scanner, re
 	hp.SendDisconnect("tcp_closed")
 }
 
+// traceLine starts the span of a session line: "tcp <COMMAND>" for the
+// commands the server intercepts, "tcp message" for lines bound for the
+// handler.
+func traceLine(ctx context.Context, uuid, line string) (context.Context, *tracing.Span) {
+	name := "tcp message"
+	for _, cmd := range []string{"PERSIST", "PONG", "WEBRTC", "DATA", "TIME"} {
+		if line == cmd || strings.HasPrefix(line, cmd+" ") {
+			name = "tcp " + cmd
+			break
+		}
+	}
+	ctx, span := tracing.StartSpan(ctx, tracing.KindServer, name)
+	span.SetAttr("robot.uuid", uuid)
+	return ctx, span
+}
+
+func (s *TCPServer_t) setRobotStatus(uuid string, to robot_status.RobotStatus, reason string) {
+	if err := handler_engine.SetRobotStatus(s.bus, uuid, to, reason); err != nil {
+		shared.DebugPrint("Robot %s status: %v", uuid, err)
+	}
+}
+
 // handleHeartbeat processes a HEARTBEAT command.
 // Format: HEARTBEAT <UUID> <signedPayloadJSON> <signatureHex>
 func (s *TCPServer_t) handleHeartbeat(conn net.Conn, message string) {
+	uuid, payloadJSON, signature, ok := parseHeartbeat(message)
+	if !ok {
+		conn.Write([]byte("ERROR INVALID_HEARTBEAT_FORMAT\n"))
+		return
+	}
+	ctx, span := tracing.StartSpan(s.main_context, tracing.KindServer, "tcp HEARTBEAT")
+	span.SetAttr("robot.uuid", uuid)
+	defer span.End()
+
 	if s.db == nil {
 		conn.Write([]byte("ERROR NO_DATABASE\n"))
 		return
@@ -484,44 +741,41 @@ func (s *TCPServer_t) handleHeartbeat(conn net.Conn, message string) {
 		conn.Write([]byte("ERROR NO_DATABASE\n"))
 		return
 	}
-
-	// Parse: HEARTBEAT <UUID> <payloadJSON> <signatureHex>
-	// Use SplitN 3 to keep the payload+signature together, then split from the
-	// right since signature (hex) never contains spaces but JSON payloads can.
-	parts := strings.SplitN(message, " ", 3)
-	if len(parts) != 3 {
-		conn.Write([]byte("ERROR INVALID_HEARTBEAT_FORMAT\n"))
-		return
-	}
-
-	uuid := parts[1]
-	rest := parts[2] // "<payloadJSON> <signatureHex>"
-
-	lastSpace := strings.LastIndex(rest, " ")
-	if lastSpace == -1 {
-		conn.Write([]byte("ERROR INVALID_HEARTBEAT_FORMAT\n"))
-		return
-	}
-
-	payloadJSON := rest[:lastSpace]
-	signature := rest[lastSpace+1:]
 	ip := remoteIP(conn)
 
-	result, err := auth.ProcessHeartbeat(s.main_context, uuid, payloadJSON, signature, ip, pg, rds)
+	result, err := auth.ProcessHeartbeat(ctx, uuid, payloadJSON, signature, ip, pg, rds)
 	if err != nil {
-		shared.DebugPrint("Heartbeat failed for %s: %v", uuid, err)
+		span.SetError(err)
+		shared.DebugPrint("Heartbeat failed: %v", err)
 		conn.Write([]byte("ERROR HEARTBEAT_REJECTED\n"))
 		return
 	}
 
 	// Publish heartbeat event for any listeners (e.g., handlers with forward_heartbeats)
 	if s.bus != nil {
-		s.bus.PublishEvent(fmt.Sprintf("robot.%s.heartbeat", result.UUID), result)
+		s.bus.PublishEvent(events.RobotHeartbeat(result.UUID), result)
 	}
 
 	conn.Write([]byte("HEARTBEAT_OK\n"))
 }
 
+// parseHeartbeat splits "HEARTBEAT <UUID> <payloadJSON> <signatureHex>".
+// The UUID and signature never contain spaces but the JSON payload can, so
+// the payload is everything between them. ok is false for a malformed line
+// or an invalid UUID.
+func parseHeartbeat(message string) (uuid, payloadJSON, signature string, ok bool) {
+	parts := strings.SplitN(message, " ", 3)
+	if len(parts) != 3 || parts[0] != "HEARTBEAT" {
+		return "", "", "", false
+	}
+	uuid, rest := parts[1], parts[2]
+	lastSpace := strings.LastIndex(rest, " ")
+	if lastSpace <= 0 || lastSpace == len(rest)-1 || !handler_engine.IsValidUUID(uuid) {
+		return "", "", "", false
+	}
+	return uuid, rest[:lastSpace], rest[lastSpace+1:], true
+}
+
 // heartbeatLoop keeps reading heartbeat messages on a persistent connection.
 func (s *TCPServer_t) heartbeatLoop(conn net.Conn, scanner *bufio.Scanner) {
 	for scanner.Scan() {
@@ -542,6 +796,9 @@ func (s *TCPServer_t) heartbeatLoop(conn net.Conn, scanner *bufio.Scanner) {
 			conn.Write([]byte("ERROR EXPECTED_HEARTBEAT\n"))
 		}
 	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		s.rejectOversizedLine(conn)
+	}
 }
 
 // handlePersist copies a robot's data from the active Redis session into
@@ -575,6 +832,9 @@ func (s *TCPServer_t) handlePersist(conn net.Conn, result *auth.HandshakeResult,
 		return
 	}
 
+	if s.bus != nil {
+		s.bus.PublishEvent(events.RobotRecord(result.UUID), events.RecordChange{UUID: result.UUID, Change: "registered"})
+	}
 	shared.DebugPrint("Robot %s persisted to PostgreSQL", result.UUID)
 	conn.Write([]byte("PERSIST_OK\n"))
 }
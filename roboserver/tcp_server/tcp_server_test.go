@@ -3,9 +3,11 @@ package tcp_server
 import (
 	"bufio"
 	"context"
+	"errors"
 	"net"
 	"roboserver/comms"
 	"roboserver/database"
+	"roboserver/handler_engine"
 	"roboserver/shared"
 	"strings"
 	"testing"
@@ -54,6 +56,9 @@ func (b *mockBus) PublishRegistrationResponse(_ context.Context, _ string, _ boo
 func (b *mockBus) WaitForRegistrationResponse(_ context.Context, _ string) (bool, error) {
 	return false, nil
 }
+func (b *mockBus) SubscribeMatching(func(string) bool, comms.EventHandler) (func(), error) {
+	return func() {}, nil
+}
 
 // readLine reads a single \n-terminated line from a connection with a timeout.
 func readLine(conn net.Conn, timeout time.Duration) (string, error) {
@@ -102,6 +107,33 @@ func TestHandleConnectionRejectsUnknownCommand(t *testing.T) {
 	}
 }
 
+func TestHandleConnectionRejectsOversizedLine(t *testing.T) {
+	shared.AppConfig.Limits.TCPLine = 1024
+	defer func() { shared.AppConfig.Limits.TCPLine = 0 }()
+
+	s := &TCPServer_t{
+		bus:          &mockBus{},
+		db:           &mockDBManager{},
+		main_context: context.Background(),
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go s.handleConnection(serverConn)
+
+	// The server stops reading mid-line, so write from another goroutine.
+	go clientConn.Write([]byte(strings.Repeat("x", 4096) + "\n"))
+
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if line != "ERROR MESSAGE_TOO_LARGE" {
+		t.Errorf("Expected ERROR MESSAGE_TOO_LARGE, got: %s", line)
+	}
+}
+
 func TestHandleConnectionRejectsEmptyLines(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -325,3 +357,165 @@ func TestServerContextCancellation(t *testing.T) {
 		t.Error("handleConnection did not return after context cancellation")
 	}
 }
+
+func TestPingLoopSendsPingAndPongClearsNonce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &TCPServer_t{
+		bus:          &mockBus{},
+		db:           &mockDBManager{},
+		main_context: ctx,
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	p := &pinger{}
+	go s.pingLoop(ctx, serverConn, p, 10*time.Millisecond)
+
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil || !strings.HasPrefix(line, "PING ") {
+		t.Fatalf("Expected PING line, got %q (err=%v)", line, err)
+	}
+	cancel()
+
+	// A PONG with the wrong nonce is ignored
+	nonce := strings.TrimPrefix(line, "PING ")
+	p.mu.Lock()
+	p.nonce = nonce // pin the nonce in case the loop fired again
+	p.mu.Unlock()
+	s.handleSessionPong("PONG bogus", "robot-1", p, nil)
+	if p.nonce == "" {
+		t.Error("Expected mismatched PONG to be ignored")
+	}
+
+	s.handleSessionPong("PONG "+nonce, "robot-1", p, nil)
+	if p.nonce != "" {
+		t.Error("Expected matching PONG to clear outstanding nonce")
+	}
+}
+
+func TestConnLimiter(t *testing.T) {
+	l := newConnLimiter(2)
+	if !l.tryAcquire() || !l.tryAcquire() {
+		t.Fatal("Expected two slots to be available")
+	}
+	if l.tryAcquire() {
+		t.Error("Expected third acquire to fail at the limit")
+	}
+	l.release()
+	if !l.tryAcquire() {
+		t.Error("Expected acquire to succeed after release")
+	}
+
+	unlimited := newConnLimiter(0)
+	for i := 0; i < 10; i++ {
+		if !unlimited.tryAcquire() {
+			t.Fatal("Expected unlimited limiter to always admit")
+		}
+	}
+	unlimited.release()
+}
+
+func TestRefuseWritesServerBusy(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	before := Stats().Refused
+	go refuse(server)
+
+	line, err := readLine(client, time.Second)
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if line != "ERROR SERVER_BUSY" {
+		t.Errorf("Expected ERROR SERVER_BUSY, got %q", line)
+	}
+	if Stats().Refused != before+1 {
+		t.Errorf("Expected refused counter to increase by 1, got %d -> %d", before, Stats().Refused)
+	}
+}
+
+func TestWriteRegistrationError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{handler_engine.ValidateUUID("bad/uuid"), "ERROR INVALID_UUID"},
+		{handler_engine.ValidateDeviceType("bad type"), "ERROR INVALID_DEVICE_TYPE"},
+		{errors.New("boom"), "ERROR REGISTRATION_FAILED"},
+	}
+	for _, tt := range tests {
+		server, client := net.Pipe()
+		go func() {
+			writeRegistrationError(server, tt.err)
+			server.Close()
+		}()
+
+		line, err := readLine(client, time.Second)
+		client.Close()
+		if err != nil {
+			t.Fatalf("Read error: %v", err)
+		}
+		if line != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, line)
+		}
+	}
+}
+
+func TestNextAcceptBackoff(t *testing.T) {
+	d := nextAcceptBackoff(0)
+	if d != 5*time.Millisecond {
+		t.Errorf("Expected 5ms initial backoff, got %s", d)
+	}
+	for i := 0; i < 20; i++ {
+		d = nextAcceptBackoff(d)
+	}
+	if d != time.Second {
+		t.Errorf("Expected backoff capped at 1s, got %s", d)
+	}
+}
+
+func TestRegisterWithPairingCodeDispatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &TCPServer_t{bus: &mockBus{}, db: &mockDBManager{}, main_context: ctx}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go s.handleConnection(serverConn)
+
+	sendLine(clientConn, "REGISTER abcde-fghjk")
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if line != "ERROR NO_DATABASE" {
+		t.Errorf("Expected REGISTER <code> to start registration, got: %s", line)
+	}
+}
+
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestRemoteIPHandlesIPv6(t *testing.T) {
+	for _, c := range []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 4312}, "10.0.0.5"},
+		{&net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.5"), Port: 4312}, "10.0.0.5"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::5"), Port: 4312}, "2001:db8::5"},
+		{&net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 4312, Zone: "eth0"}, "fe80::1%eth0"},
+	} {
+		if got := remoteIP(addrConn{remote: c.addr}); got != c.want {
+			t.Errorf("remoteIP(%s) = %q, want %q", c.addr, got, c.want)
+		}
+	}
+}
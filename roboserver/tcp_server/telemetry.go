@@ -0,0 +1,75 @@
+package tcp_server
+
+import (
+	"fmt"
+	"net"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/adaptive"
+	"roboserver/shared/events"
+	"roboserver/shared/metrics"
+	"roboserver/shared/telemetry"
+	"roboserver/shared/timesync"
+	"strings"
+	"time"
+)
+
+// handleData processes "DATA <json>" in session mode: a telemetry.Envelope
+// goes to the handler, the robot's telemetry list in Redis and the event bus
+// (robot.{uuid}.telemetry). Duplicate or out-of-order seq numbers are dropped.
+// A timestamp the robot sent is moved onto the server clock when its clock
+// offset is known (nil when not, or when time_sync.normalize is off). adapt
+// (nil unless adaptive_polling applies to the robot) may answer with an
+// INTERVAL recommendation.
+func (s *TCPServer_t) handleData(conn net.Conn, line, uuid string, hp *handler_engine.HandlerProcess, seq *telemetry.Sequencer, clock *timesync.Offset, adapt *adaptive.Tracker) {
+	var offset int64
+	if clock != nil {
+		offset = clock.OffsetMs
+	}
+	env, err := telemetry.ParseSynced([]byte(strings.TrimPrefix(line, "DATA ")), offset)
+	if err != nil {
+		shared.DebugPrint("Invalid DATA from %s: %v", uuid, err)
+		conn.Write([]byte("ERROR INVALID_DATA\n"))
+		return
+	}
+	if !seq.Accept(env) {
+		shared.DebugPrint("Dropping DATA seq %d from %s (duplicate or out of order)", env.Seq, uuid)
+		return
+	}
+
+	hp.SendTelemetry(env)
+
+	if rds := s.db.Redis(); rds != nil {
+		cfg := &shared.AppConfig.Handlers
+		if err := rds.AddTelemetry(s.main_context, uuid, env, cfg.TelemetryHistoryLen(), cfg.DataExpiry()); err != nil {
+			shared.DebugPrint("Failed to store telemetry for %s: %v", uuid, err)
+		}
+	}
+	if s.bus != nil {
+		s.bus.PublishEvent(events.RobotTelemetry(uuid), env)
+	}
+	if adapt != nil {
+		s.adjustInterval(conn, uuid, adapt, env)
+	}
+}
+
+// adjustInterval feeds env to the session's tracker and pushes the
+// reporting interval it recommends, if any, as "INTERVAL <type> <ms>".
+func (s *TCPServer_t) adjustInterval(conn net.Conn, uuid string, adapt *adaptive.Tracker, env telemetry.Envelope) {
+	var load float64
+	if sample, ok := metrics.Latest(); ok {
+		load = sample.MsgsPerSec
+	}
+	adj, ok := adapt.Observe(env, time.Now(), load)
+	if !ok {
+		return
+	}
+	adj.UUID = uuid
+	shared.DebugPrint("Asking %s to send %s every %dms (was %dms, %s)", uuid, adj.Type, adj.IntervalMs, adj.PreviousMs, adj.Reason)
+	if _, err := conn.Write([]byte(fmt.Sprintf("INTERVAL %s %d\n", adj.Type, adj.IntervalMs))); err != nil {
+		return
+	}
+	if s.bus != nil {
+		s.bus.PublishEvent(events.RobotInterval(uuid), adj)
+	}
+}
@@ -0,0 +1,90 @@
+package tcp_server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"roboserver/handler_engine"
+	"roboserver/shared/adaptive"
+	"roboserver/shared/events"
+	"roboserver/shared/telemetry"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingBus records published event types.
+type recordingBus struct {
+	mockBus
+	published chan string
+}
+
+func (b *recordingBus) PublishEvent(eventType string, _ any) error {
+	b.published <- eventType
+	return nil
+}
+
+func TestHandleDataPublishesTelemetry(t *testing.T) {
+	bus := &recordingBus{published: make(chan string, 4)}
+	s := &TCPServer_t{bus: bus, db: &mockDBManager{}, main_context: context.Background()}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	hp := &handler_engine.HandlerProcess{UUID: "r1"}
+	var seq telemetry.Sequencer
+
+	s.handleData(serverConn, `DATA {"type":"env","metrics":{"temp_c":21.5},"seq":2}`, "r1", hp, &seq, nil, nil)
+	if got := <-bus.published; got != events.RobotTelemetry("r1") {
+		t.Errorf("Expected %s, got %s", events.RobotTelemetry("r1"), got)
+	}
+
+	// A repeated seq is dropped without a reply.
+	s.handleData(serverConn, `DATA {"type":"env","metrics":{"temp_c":21.5},"seq":2}`, "r1", hp, &seq, nil, nil)
+	if len(bus.published) != 0 {
+		t.Error("Expected a duplicate seq to be dropped")
+	}
+}
+
+func TestHandleDataRejectsInvalidEnvelope(t *testing.T) {
+	s := &TCPServer_t{bus: &mockBus{}, db: &mockDBManager{}, main_context: context.Background()}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	hp := &handler_engine.HandlerProcess{UUID: "r1"}
+
+	go s.handleData(serverConn, `DATA {"metrics":{}}`, "r1", hp, &telemetry.Sequencer{}, nil, nil)
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil || !strings.HasPrefix(line, "ERROR INVALID_DATA") {
+		t.Errorf("Expected ERROR INVALID_DATA, got %q (%v)", line, err)
+	}
+}
+
+func TestHandleDataPushesInterval(t *testing.T) {
+	bus := &recordingBus{published: make(chan string, 8)}
+	s := &TCPServer_t{bus: bus, db: &mockDBManager{}, main_context: context.Background()}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	hp := &handler_engine.HandlerProcess{UUID: "r1"}
+	adapt := adaptive.NewTracker(adaptive.Settings{Min: time.Second, Max: time.Minute, Window: 3, Threshold: 0.02})
+
+	go func() {
+		var seq telemetry.Sequencer
+		for i := 1; i <= 3; i++ {
+			line := fmt.Sprintf(`DATA {"type":"env","metrics":{"temp_c":21.5},"timestamp":%d}`, int64(i)*5000)
+			s.handleData(serverConn, line, "r1", hp, &seq, nil, adapt)
+		}
+	}()
+	line, err := readLine(clientConn, 2*time.Second)
+	if err != nil || line != "INTERVAL env 10000" {
+		t.Fatalf("Expected INTERVAL env 10000 for a steady reading, got %q (%v)", line, err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case got := <-bus.published:
+			if got == events.RobotInterval("r1") {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("Expected %s to be published", events.RobotInterval("r1"))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tcp_server
+
+import (
+	"fmt"
+	"net"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/timesync"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadClockOffset returns the robot's clock offset from an earlier session,
+// or nil when unknown or time_sync.normalize is off.
+func (s *TCPServer_t) loadClockOffset(uuid string, rds *database.RedisHandler) *timesync.Offset {
+	if rds == nil || !shared.AppConfig.TimeSync.Normalize {
+		return nil
+	}
+	o, err := rds.GetClockOffset(s.main_context, uuid)
+	if err != nil {
+		shared.DebugPrint("Failed to load the clock offset of %s: %v", uuid, err)
+	}
+	return o
+}
+
+// handleTime answers "TIME <t1_ms> [<rtt_ms>]" in session mode with
+// "TIME <t1> <t2> <t3> <offset_ms>" (see shared/timesync) and records the
+// robot's clock offset. rtt_ms is the round trip the robot measured on its
+// previous TIME exchange, when it has one. It returns the offset DATA
+// timestamps are normalized with from now on: clock, unless the exchange
+// succeeded and time_sync.normalize is on.
+func (s *TCPServer_t) handleTime(conn net.Conn, line, uuid string, rds *database.RedisHandler, received time.Time, clock *timesync.Offset) *timesync.Offset {
+	fields := strings.Fields(strings.TrimPrefix(line, "TIME"))
+	if len(fields) < 1 || len(fields) > 2 {
+		conn.Write([]byte("ERROR INVALID_TIME\n"))
+		return clock
+	}
+	t1, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || t1 <= 0 {
+		conn.Write([]byte("ERROR INVALID_TIME\n"))
+		return clock
+	}
+	var rtt time.Duration
+	if len(fields) == 2 {
+		ms, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || ms < 0 {
+			conn.Write([]byte("ERROR INVALID_TIME\n"))
+			return clock
+		}
+		rtt = time.Duration(ms * float64(time.Millisecond))
+	}
+	if rds == nil {
+		conn.Write([]byte("ERROR NO_DATABASE\n"))
+		return clock
+	}
+
+	reply, err := timesync.Answer(s.main_context, rds, uuid, t1, rtt, received, shared.AppConfig.TimeSync.KeepFor())
+	if err != nil {
+		shared.DebugPrint("Failed to record the clock offset of %s: %v", uuid, err)
+		conn.Write([]byte("ERROR TIME_FAILED\n"))
+		return clock
+	}
+	conn.Write([]byte(fmt.Sprintf("TIME %d %d %d %d\n", reply.T1, reply.T2, reply.T3, reply.OffsetMs)))
+	if !shared.AppConfig.TimeSync.Normalize {
+		return nil
+	}
+	return &timesync.Offset{OffsetMs: reply.OffsetMs}
+}
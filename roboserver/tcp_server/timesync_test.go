@@ -0,0 +1,29 @@
+package tcp_server
+
+import (
+	"context"
+	"net"
+	"roboserver/shared/timesync"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleTimeRejectsInvalid(t *testing.T) {
+	s := &TCPServer_t{bus: &mockBus{}, db: &mockDBManager{}, main_context: context.Background()}
+	clock := &timesync.Offset{OffsetMs: 250}
+
+	for _, line := range []string{"TIME", "TIME soon", "TIME -5", "TIME 1718000000000 fast", "TIME 1 2 3"} {
+		clientConn, serverConn := net.Pipe()
+		got := make(chan *timesync.Offset, 1)
+		go func() { got <- s.handleTime(serverConn, line, "r1", nil, time.Now(), clock) }()
+		reply, err := readLine(clientConn, 2*time.Second)
+		if err != nil || !strings.HasPrefix(reply, "ERROR INVALID_TIME") {
+			t.Errorf("%q: expected ERROR INVALID_TIME, got %q (%v)", line, reply, err)
+		}
+		if o := <-got; o != clock {
+			t.Errorf("%q: expected the previous offset to be kept, got %+v", line, o)
+		}
+		clientConn.Close()
+	}
+}
@@ -0,0 +1,23 @@
+package tcp_server
+
+import (
+	"net"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+)
+
+// handleWebRTCSignal publishes a robot's "WEBRTC <json>" answer or ICE
+// candidate on webrtc.{session}.{kind}, where the HTTP signaling endpoints
+// and browser SSE subscriptions pick it up.
+func (s *TCPServer_t) handleWebRTCSignal(conn net.Conn, line, uuid string) {
+	sig, err := handler_engine.ParseRobotSignal(line, uuid)
+	if err != nil {
+		shared.DebugPrint("Invalid WEBRTC signal from %s: %v", uuid, err)
+		conn.Write([]byte("ERROR INVALID_SIGNAL\n"))
+		return
+	}
+	if s.bus == nil {
+		return
+	}
+	s.bus.PublishEvent(handler_engine.SignalTopic(sig.Session, sig.Kind), sig)
+}
@@ -0,0 +1,89 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"roboserver/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// aclCommand manages per-robot access lists. The terminal itself is
+// localhost-only and acts with admin rights.
+func aclCommand(ctx *CommandContext, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: acl list|grant|revoke <uuid> [principal]")
+	}
+
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	action, uuid := args[0], args[1]
+	switch action {
+	case "list":
+		principals, err := rds.GetRobotACL(context.Background(), uuid)
+		if err != nil {
+			return fmt.Errorf("failed to get ACL: %w", err)
+		}
+		if len(principals) == 0 {
+			ctx.Conn.Write([]byte(fmt.Sprintf("No ACL entries for %s (admins only).\n", uuid)))
+			return nil
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("ACL for %s:\n", uuid)))
+		for _, p := range principals {
+			ctx.Conn.Write([]byte(fmt.Sprintf("  %s\n", p)))
+		}
+	case "grant", "revoke":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: acl %s <uuid> <username|role:name>", action)
+		}
+		principal := args[2]
+		if action == "grant" {
+			if err := rds.GrantRobotAccess(context.Background(), uuid, principal); err != nil {
+				return fmt.Errorf("failed to grant access: %w", err)
+			}
+			ctx.Conn.Write([]byte(fmt.Sprintf("Granted %s access to %s\n", principal, uuid)))
+		} else {
+			if err := rds.RevokeRobotAccess(context.Background(), uuid, principal); err != nil {
+				return fmt.Errorf("failed to revoke access: %w", err)
+			}
+			ctx.Conn.Write([]byte(fmt.Sprintf("Revoked %s access to %s\n", principal, uuid)))
+		}
+	default:
+		return fmt.Errorf("usage: acl list|grant|revoke <uuid> [principal]")
+	}
+	return nil
+}
+
+// userAddCommand creates or replaces a user account with a role. Non-admin
+// roles only see robots granted to them via "acl grant".
+func userAddCommand(ctx *CommandContext, args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: useradd <username> <password> <role>")
+	}
+
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	username, password, role := args[0], args[1], args[2]
+	if len(password) < 8 || len(password) > 72 {
+		return fmt.Errorf("password must be 8-72 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &database.User{Username: username, PasswordHash: string(hash), Role: role}
+	if err := rds.SetUser(context.Background(), user); err != nil {
+		return fmt.Errorf("failed to store user: %w", err)
+	}
+
+	ctx.Conn.Write([]byte(fmt.Sprintf("User %s saved with role %s\n", username, role)))
+	return nil
+}
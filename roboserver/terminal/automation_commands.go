@@ -0,0 +1,47 @@
+package terminal
+
+import (
+	"fmt"
+	"roboserver/automation"
+	"strings"
+	"time"
+)
+
+// automationCommand lists the automation scripts or reloads them from
+// automation.dir.
+func automationCommand(ctx *CommandContext, args []string) error {
+	if len(args) > 0 && args[0] == "reload" {
+		n, err := automation.Default.Reload()
+		if err != nil {
+			return fmt.Errorf("failed to reload automation: %w", err)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Loaded %d automation script(s).\n", n)))
+	} else if len(args) > 0 && args[0] != "list" {
+		return fmt.Errorf("usage: automation [list] | reload")
+	}
+
+	scripts := automation.Default.Status()
+	if ctx.JSON {
+		return ctx.writeJSON(scripts)
+	}
+	if len(scripts) == 0 {
+		ctx.Conn.Write([]byte("No automation scripts loaded.\n"))
+		return nil
+	}
+	lines := make([]string, 0, len(scripts))
+	for _, s := range scripts {
+		if s.LoadError != "" {
+			lines = append(lines, fmt.Sprintf("  %s  FAILED TO LOAD: %s", s.Name, s.LoadError))
+			continue
+		}
+		line := fmt.Sprintf("  %s  on=%s  runs=%d  errors=%d  dropped=%d",
+			s.Name, strings.Join(s.Events, ","), s.Runs, s.Errors, s.Dropped)
+		if s.LastError != "" {
+			line += fmt.Sprintf("  last_error=%q at %s", s.LastError, time.Unix(s.LastErrorAt, 0).Format(time.RFC3339))
+		}
+		lines = append(lines, line)
+	}
+	ctx.Conn.Write([]byte("Automation scripts:\n"))
+	ctx.writeLines(lines)
+	return nil
+}
@@ -0,0 +1,102 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"roboserver/backup"
+	"roboserver/shared"
+	"slices"
+	"time"
+)
+
+// backupCommand writes an encrypted backup archive to path, by default a
+// timestamped file in backup.dir.
+func backupCommand(ctx *CommandContext, args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("usage: backup [<path> [<passphrase>]]")
+	}
+	var path, given string
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if len(args) > 1 {
+		given = args[1]
+	}
+	passphrase, err := backup.Passphrase(given)
+	if err != nil {
+		return err
+	}
+
+	bctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	archive, err := backup.Create(bctx, ctx.DB)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	data, err := backup.Seal(archive, passphrase)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	if path == "" {
+		dir := shared.AppConfig.Backup.Dir
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		path = filepath.Join(dir, fmt.Sprintf("robomesh-%s.rmbk", time.Unix(archive.CreatedAt, 0).UTC().Format("20060102-150405")))
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	sum := archive.Summary()
+	if ctx.JSON {
+		return ctx.writeJSON(map[string]any{"path": path, "bytes": len(data), "contents": sum})
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Wrote %s (%d bytes): %d robot(s), %d user(s), %d macro(s), %d automation script(s), %d scheduled message(s).\n",
+		path, len(data), sum.Robots, sum.Users, sum.Macros, sum.Automations, sum.Schedules)))
+	return nil
+}
+
+// restoreCommand restores a backup archive; --config also overwrites
+// config.yaml.
+func restoreCommand(ctx *CommandContext, args []string) error {
+	opts := backup.RestoreOptions{Config: slices.Contains(args, "--config")}
+	args = slices.DeleteFunc(slices.Clone(args), func(a string) bool { return a == "--config" })
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: restore <path> [<passphrase>] [--config]")
+	}
+	var given string
+	if len(args) > 1 {
+		given = args[1]
+	}
+	passphrase, err := backup.Passphrase(given)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	archive, err := backup.Open(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	rctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	sum, err := backup.Restore(rctx, ctx.DB, ctx.Bus, archive, opts)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+	if ctx.JSON {
+		return ctx.writeJSON(sum)
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Restored backup from %s: %d robot(s), %d user(s), %d macro(s), %d automation script(s), %d scheduled message(s) (%d skipped).\n",
+		time.Unix(archive.CreatedAt, 0).Format(time.RFC3339), sum.Robots, sum.Users, sum.Macros, sum.Automations, sum.Schedules, sum.Skipped)))
+	if sum.Config {
+		ctx.Conn.Write([]byte("config.yaml was replaced; run 'reload' or restart to apply it.\n"))
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package terminal
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"roboserver/comms"
 	"roboserver/database"
+	"slices"
+	"strings"
+	"sync"
 )
 
 // CommandFunc represents a terminal command function
@@ -16,6 +21,7 @@ type CommandInfo struct {
 	Name        string
 	Description string
 	Usage       string
+	Aliases     []string // other names that run the command, e.g. "ls" for list
 	Handler     CommandFunc
 }
 
@@ -26,48 +32,130 @@ type CommandContext struct {
 	Bus           comms.Bus
 	Cancel        context.CancelFunc
 	Subscriptions map[string]func() // event type → cancel
+	Input         *bufio.Scanner    // connection reader, used by batch mode
+	JSON          bool              // the running command was given --json
+	PageSize      int               // lines per page on this session; 0 = default, -1 = off
+	Remote        bool              // script sent over HTTP: host-only commands are refused
+
+	scriptDepth int // nesting level of run/batch scripts
 }
 
-// CommandRegistry holds all registered commands
+// ErrCommandExists is returned when a command name or alias is already taken.
+var ErrCommandExists = errors.New("command already registered")
+
+// CommandRegistry holds all registered commands. It is safe for concurrent
+// use, so commands can be added or removed while sessions are running.
 type CommandRegistry struct {
+	mu       sync.RWMutex
 	commands map[string]*CommandInfo
+	aliases  map[string]string // alias → command name
 }
 
-var DefaultRegistry = &CommandRegistry{
-	commands: make(map[string]*CommandInfo),
+var DefaultRegistry = NewCommandRegistry()
+
+// NewCommandRegistry returns an empty registry.
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]*CommandInfo),
+		aliases:  make(map[string]string),
+	}
 }
 
-// RegisterCommand registers a new command
-func RegisterCommand(name, description, usage string, handler CommandFunc) {
-	DefaultRegistry.commands[name] = &CommandInfo{
+// RegisterCommand registers a new command in DefaultRegistry. Registering a
+// name or alias twice panics, as two commands claiming one name is a bug;
+// code that adds commands at runtime uses DefaultRegistry.Register instead.
+func RegisterCommand(name, description, usage string, handler CommandFunc, aliases ...string) {
+	err := DefaultRegistry.Register(&CommandInfo{
 		Name:        name,
 		Description: description,
 		Usage:       usage,
+		Aliases:     aliases,
 		Handler:     handler,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("terminal: %v", err))
+	}
+}
+
+// Register adds a command and its aliases. It fails with ErrCommandExists,
+// registering nothing, if any of the names is already a command or alias.
+func (r *CommandRegistry) Register(cmd *CommandInfo) error {
+	if cmd.Name == "" || cmd.Handler == nil {
+		return fmt.Errorf("command needs a name and a handler")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for i, name := range names {
+		if r.taken(name) || slices.Contains(names[:i], name) {
+			return fmt.Errorf("%w: %s", ErrCommandExists, name)
+		}
+	}
+	r.commands[cmd.Name] = cmd
+	for _, alias := range cmd.Aliases {
+		r.aliases[alias] = cmd.Name
 	}
+	return nil
 }
 
-// GetCommand retrieves a command by name
+// Unregister removes a command and its aliases, reporting whether it was
+// registered. An alias does not name the command here.
+func (r *CommandRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cmd, ok := r.commands[name]
+	if !ok {
+		return false
+	}
+	delete(r.commands, name)
+	for _, alias := range cmd.Aliases {
+		delete(r.aliases, alias)
+	}
+	return true
+}
+
+// taken reports whether name is a command or alias. Caller holds r.mu.
+func (r *CommandRegistry) taken(name string) bool {
+	_, isCommand := r.commands[name]
+	_, isAlias := r.aliases[name]
+	return isCommand || isAlias
+}
+
+// GetCommand retrieves a command by name or alias
 func (r *CommandRegistry) GetCommand(name string) (*CommandInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if target, ok := r.aliases[name]; ok {
+		name = target
+	}
 	cmd, exists := r.commands[name]
 	return cmd, exists
 }
 
-// ListCommands returns all registered commands
+// ListCommands returns all registered commands, sorted by name
 func (r *CommandRegistry) ListCommands() []*CommandInfo {
-	var commands []*CommandInfo
+	r.mu.RLock()
+	commands := make([]*CommandInfo, 0, len(r.commands))
 	for _, cmd := range r.commands {
 		commands = append(commands, cmd)
 	}
+	r.mu.RUnlock()
+	slices.SortFunc(commands, func(a, b *CommandInfo) int { return strings.Compare(a.Name, b.Name) })
 	return commands
 }
 
-// ExecuteCommand executes a command by name
+// ExecuteCommand executes a command by name. A --json argument is removed
+// and sets ctx.JSON for the duration of the command.
 func (r *CommandRegistry) ExecuteCommand(ctx *CommandContext, name string, args []string) error {
 	cmd, exists := r.GetCommand(name)
 	if !exists {
 		return fmt.Errorf("unknown command: %s", name)
 	}
 
+	args, asJSON := stripJSONFlag(args)
+	prev := ctx.JSON
+	ctx.JSON = asJSON
+	defer func() { ctx.JSON = prev }()
+
 	return cmd.Handler(ctx, args)
 }
@@ -1,17 +1,40 @@
 package terminal
 
 func init() {
-	RegisterCommand("list", "List active robots (from Redis)", "list", listActiveCommand)
+	RegisterCommand("list", "List active robots (from Redis)", "list", listActiveCommand, "ls")
 	RegisterCommand("robots", "List registered robots (from PostgreSQL)", "robots", listRegisteredCommand)
 	RegisterCommand("pending", "List pending robot registrations", "pending", pendingCommand)
-	RegisterCommand("accept", "Accept a pending robot registration", "accept <uuid>", acceptCommand)
-	RegisterCommand("reject", "Reject a pending robot registration", "reject <uuid>", rejectCommand)
+	RegisterCommand("accept", "Accept pending robot registrations", "accept [<uuid|index|all>...]", acceptCommand)
+	RegisterCommand("approve", "Accept pending robot registrations (interactive without arguments)", "approve [<uuid|index|all>...]", acceptCommand)
+	RegisterCommand("reject", "Reject pending robot registrations (interactive without arguments)", "reject [<uuid|index|all>...]", rejectCommand)
+	RegisterCommand("autoaccept", "Show or change the registration auto-accept policies", "autoaccept [show] | set policies|ids|types|subnets=<a,b,...>... | reset", autoacceptCommand)
+	RegisterCommand("regfailures", "List recent failed or rejected registrations", "regfailures [<count>]", regfailuresCommand)
+	RegisterCommand("maintenance", "List robots in maintenance mode or turn it on/off", "maintenance [list] | on <uuid> [reason...] | off <uuid>", maintenanceCommand)
+	RegisterCommand("shadow", "Show a robot's desired/reported state or change desired state", "shadow get <uuid> | set <uuid> <json> | clear <uuid>", shadowCommand)
+	RegisterCommand("kick", "Force-disconnect a robot, optionally banning it for a while", "kick <uuid> [<ban_duration> [ip]] [reason...]", kickCommand)
+	RegisterCommand("bans", "List device bans or lift one", "bans [list] | lift uuid|ip <value>", bansCommand)
+	RegisterCommand("schedule", "List, add or cancel delayed and recurring robot messages", "schedule [list [<uuid>]] | after|every <uuid> <duration> <message...> | cancel <id>", scheduleCommand)
+	RegisterCommand("automation", "List automation scripts or reload them", "automation [list] | reload", automationCommand)
+	RegisterCommand("backup", "Write an encrypted backup archive", "backup [<path> [<passphrase>]]", backupCommand)
+	RegisterCommand("restore", "Restore a backup archive", "restore <path> [<passphrase>] [--config]", restoreCommand)
+	RegisterCommand("send", "Send a command to a robot's handler and print its reply", "send <uuid> <command> [json-payload] [--nowait]", sendCommand)
 	RegisterCommand("stop", "Stop the program or robot", "stop program|<robot_id>", stopCommand)
 	RegisterCommand("help", "Show available commands", "help [command]", helpCommand)
 	RegisterCommand("status", "Get robot status", "status <uuid>", statusCommand)
+	RegisterCommand("watch", "Live view of a robot's status, or of all active robots, until Enter", "watch <uuid|all> [interval] [--plain]", watchCommand)
+	RegisterCommand("tcpstats", "Show TCP connection counters", "tcpstats", tcpStatsCommand)
+	RegisterCommand("reload", "Reload runtime-adjustable settings from config", "reload", reloadCommand)
 	RegisterCommand("exit", "Exit terminal session", "exit", exitCommand)
-	RegisterCommand("quit", "Exit terminal session", "quit", quitCommand)
+	RegisterCommand("quit", "Exit terminal session", "quit", quitCommand, "q")
 	RegisterCommand("subscribe", "Subscribe to robot events", "subscribe <event_type>", subscribeCommand)
 	RegisterCommand("unsubscribe", "Unsubscribe from robot events", "unsubscribe <event_type>", unsubscribeCommand)
 	RegisterCommand("publish", "Publish an event to robots", "publish <event_type> <data>", publishCommand)
+	RegisterCommand("run", "Run a newline-separated command script", "run <script_path>", runCommand)
+	RegisterCommand("acl", "Manage per-robot access lists", "acl list|grant|revoke <uuid> [username|role:name]", aclCommand)
+	RegisterCommand("useradd", "Create or replace a user account", "useradd <username> <password> <role>", userAddCommand)
+	RegisterCommand("sessions", "List a user's login sessions or revoke them", "sessions <username> [revoke <id>|all]", sessionsCommand)
+	RegisterCommand("tokenkey", "Show or rotate the stored-token encryption key", "tokenkey status|rotate [new_key]", tokenKeyCommand)
+	RegisterCommand("macro", "Manage and run message macros", "macro list|set|delete|run ...", macroCommand)
+	RegisterCommand("page", "Show or set this session's page size for long listings", "page [<lines>|off]", pageCommand)
+	RegisterCommand("batch", "Read commands until 'end', then run them as a script", "batch", batchCommand)
 }
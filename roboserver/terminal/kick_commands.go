@@ -0,0 +1,102 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"strings"
+	"time"
+)
+
+const kickUsage = "usage: kick <uuid> [<ban_duration> [ip]] [reason...]"
+
+// kickCommand force-disconnects a robot. A duration after the UUID (e.g.
+// "10m") bans the robot from reconnecting for that long; "ip" after it
+// bans its address too.
+func kickCommand(ctx *CommandContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(kickUsage)
+	}
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	uuid, rest := args[0], args[1:]
+	opts := handler_engine.KickOptions{By: "terminal"}
+	if len(rest) > 0 {
+		if d, err := time.ParseDuration(rest[0]); err == nil {
+			if d <= 0 {
+				return fmt.Errorf("ban duration must be positive")
+			}
+			opts.Ban = d
+			rest = rest[1:]
+			if len(rest) > 0 && rest[0] == "ip" {
+				opts.BanIP = true
+				rest = rest[1:]
+			}
+		}
+	}
+	opts.Reason = strings.Join(rest, " ")
+
+	bans, err := handler_engine.Kick(context.Background(), ctx.Bus, rds, uuid, opts)
+	if errors.Is(err, handler_engine.ErrNotConnected) {
+		return fmt.Errorf("robot %s is not connected", uuid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to disconnect robot: %w", err)
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Robot %s disconnected.\n", uuid)))
+	for _, b := range bans {
+		ctx.Conn.Write([]byte(fmt.Sprintf("Banned %s %s until %s.\n", b.Kind, b.Value, time.Unix(b.Until, 0).Format(time.RFC3339))))
+	}
+	return nil
+}
+
+// bansCommand lists device bans or lifts one early.
+func bansCommand(ctx *CommandContext, args []string) error {
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+	if len(args) == 0 || args[0] == "list" {
+		bans, err := rds.ListBans(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to list bans: %w", err)
+		}
+		if ctx.JSON {
+			return ctx.writeJSON(bans)
+		}
+		if len(bans) == 0 {
+			ctx.Conn.Write([]byte("No bans in force.\n"))
+			return nil
+		}
+		lines := make([]string, 0, len(bans))
+		for _, b := range bans {
+			reason := b.Reason
+			if reason == "" {
+				reason = "no reason given"
+			}
+			lines = append(lines, fmt.Sprintf("  %s %s  until=%s  by=%s  reason=%s",
+				b.Kind, b.Value, time.Unix(b.Until, 0).Format(time.RFC3339), b.By, reason))
+		}
+		ctx.Conn.Write([]byte("Bans in force:\n"))
+		ctx.writeLines(lines)
+		return nil
+	}
+
+	if len(args) != 3 || args[0] != "lift" || (args[1] != database.BanUUID && args[1] != database.BanIP) {
+		return fmt.Errorf("usage: bans [list] | lift uuid|ip <value>")
+	}
+	found, err := rds.RemoveBan(context.Background(), args[1], args[2])
+	if err != nil {
+		return fmt.Errorf("failed to lift ban: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no ban on %s %s", args[1], args[2])
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Ban on %s %s lifted.\n", args[1], args[2])))
+	return nil
+}
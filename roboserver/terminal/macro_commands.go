@@ -0,0 +1,120 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"roboserver/handler_engine"
+	"roboserver/shared/macro"
+	"strings"
+)
+
+const macroUsage = "usage: macro list | set <name> <template...> | delete <name> | run <uuid> <name> [param=value ...]"
+
+// macroCommand manages the shared macro store and runs macros against robots.
+// Templates are joined from the remaining words, so "macro set ping ping now"
+// stores "ping now".
+func macroCommand(ctx *CommandContext, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf(macroUsage)
+	}
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+	bg := context.Background()
+
+	switch args[0] {
+	case "list":
+		macros, err := rds.ListMacros(bg)
+		if err != nil {
+			return fmt.Errorf("failed to list macros: %w", err)
+		}
+		if len(macros) == 0 {
+			ctx.Conn.Write([]byte("No macros defined.\n"))
+			return nil
+		}
+		for _, m := range macros {
+			ctx.Conn.Write([]byte(fmt.Sprintf("  %-20s params=[%s]  %s\n", m.Name, strings.Join(m.Params, ","), m.Template)))
+		}
+	case "set":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: macro set <name> <template...>")
+		}
+		m, err := macro.New(args[1], strings.Join(args[2:], " "), "")
+		if err != nil {
+			return err
+		}
+		if err := rds.SetMacro(bg, m); err != nil {
+			return fmt.Errorf("failed to store macro: %w", err)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Saved macro %s (params: %s)\n", m.Name, strings.Join(m.Params, ", "))))
+	case "delete":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: macro delete <name>")
+		}
+		existed, err := rds.DeleteMacro(bg, args[1])
+		if err != nil {
+			return fmt.Errorf("failed to delete macro: %w", err)
+		}
+		if !existed {
+			return fmt.Errorf("macro %s not found", args[1])
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Deleted macro %s\n", args[1])))
+	case "run":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: macro run <uuid> <name> [param=value ...]")
+		}
+		uuid, name := args[1], args[2]
+		params, err := parseMacroParams(args[3:])
+		if err != nil {
+			return err
+		}
+		m, err := rds.GetMacro(bg, name)
+		if err != nil {
+			return fmt.Errorf("failed to get macro: %w", err)
+		}
+		if m == nil {
+			return fmt.Errorf("macro %s not found", name)
+		}
+		message, err := m.Render(params)
+		if err != nil {
+			return err
+		}
+		hp, ok := handler_engine.HandlerManager.Get(uuid)
+		if !ok {
+			return fmt.Errorf("no handler running for %s", uuid)
+		}
+		sendCtx, cancel := context.WithTimeout(bg, handler_engine.DefaultSendTimeout)
+		defer cancel()
+		if err := hp.SendIncomingContext(sendCtx, message); err != nil {
+			return fmt.Errorf("failed to send to %s: %w", uuid, err)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Sent to %s: %s\n", uuid, message)))
+	default:
+		return fmt.Errorf(macroUsage)
+	}
+	return nil
+}
+
+// parseMacroParams turns param=value words into render params. Values that
+// parse as JSON numbers or booleans keep that type; anything else is a string.
+func parseMacroParams(words []string) (map[string]any, error) {
+	params := make(map[string]any, len(words))
+	for _, w := range words {
+		key, val, ok := strings.Cut(w, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected param=value, got %q", w)
+		}
+		var parsed any
+		if err := json.Unmarshal([]byte(val), &parsed); err == nil {
+			switch parsed.(type) {
+			case float64, bool:
+				params[key] = parsed
+				continue
+			}
+		}
+		params[key] = val
+	}
+	return params, nil
+}
@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"roboserver/handler_engine"
+	"roboserver/shared/maintenance"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maintenanceCommand lists robots in maintenance mode or puts one in or
+// out of it. Ending maintenance delivers the automated messages held
+// meanwhile.
+func maintenanceCommand(ctx *CommandContext, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		infos := maintenance.Registry.All()
+		sort.Slice(infos, func(i, j int) bool { return infos[i].UUID < infos[j].UUID })
+		if ctx.JSON {
+			return ctx.writeJSON(infos)
+		}
+		if len(infos) == 0 {
+			ctx.Conn.Write([]byte("No robots in maintenance.\n"))
+			return nil
+		}
+		lines := make([]string, 0, len(infos))
+		for _, info := range infos {
+			lines = append(lines, fmt.Sprintf("  %s  since=%s  by=%s  reason=%s",
+				info.UUID, time.Unix(info.Since, 0).Format(time.RFC3339), info.By, maintenanceReason(info)))
+		}
+		ctx.Conn.Write([]byte("Robots in maintenance:\n"))
+		ctx.writeLines(lines)
+		return nil
+	}
+
+	if len(args) < 2 {
+		return fmt.Errorf("usage: maintenance [list] | on <uuid> [reason...] | off <uuid>")
+	}
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	action, uuid := args[0], args[1]
+	switch action {
+	case "on":
+		info := maintenance.Info{
+			UUID:   uuid,
+			Reason: strings.Join(args[2:], " "),
+			By:     "terminal",
+			Since:  time.Now().Unix(),
+		}
+		if err := handler_engine.StartMaintenance(context.Background(), ctx.Bus, rds, info); err != nil {
+			return fmt.Errorf("failed to start maintenance: %w", err)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Robot %s is now in maintenance.\n", uuid)))
+	case "off":
+		delivered, found, err := handler_engine.EndMaintenance(context.Background(), ctx.Bus, rds, uuid)
+		if err != nil {
+			return fmt.Errorf("failed to end maintenance: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("robot %s is not in maintenance", uuid)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Robot %s left maintenance; delivered %d held message(s).\n", uuid, delivered)))
+	default:
+		return fmt.Errorf("usage: maintenance [list] | on <uuid> [reason...] | off <uuid>")
+	}
+	return nil
+}
+
+func maintenanceReason(info maintenance.Info) string {
+	if info.Reason == "" {
+		return "no reason given"
+	}
+	return info.Reason
+}
@@ -0,0 +1,91 @@
+package terminal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonFlag switches a command's output to a single JSON document so the
+// terminal can be scripted (e.g. `echo 'list --json' | nc 127.0.0.1 6000`).
+const jsonFlag = "--json"
+
+// defaultPageSize is how many lines a long listing shows before pausing on
+// an interactive session.
+const defaultPageSize = 20
+
+// stripJSONFlag removes --json from args and reports whether it was there.
+func stripJSONFlag(args []string) ([]string, bool) {
+	out := args[:0:0]
+	found := false
+	for _, a := range args {
+		if a == jsonFlag {
+			found = true
+			continue
+		}
+		out = append(out, a)
+	}
+	return out, found
+}
+
+// writeJSON writes v as one line of JSON.
+func (ctx *CommandContext) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	ctx.Conn.Write(append(data, '\n'))
+	return nil
+}
+
+// paging reports whether long output should pause for the user: only on an
+// interactive session, outside run/batch scripts and JSON output.
+func (ctx *CommandContext) paging() bool {
+	return ctx.Input != nil && ctx.scriptDepth == 0 && !ctx.JSON && ctx.PageSize >= 0
+}
+
+// writeLines writes lines, pausing after each page on interactive sessions.
+// At the prompt, Enter shows the next page and "q" stops.
+func (ctx *CommandContext) writeLines(lines []string) {
+	size := ctx.PageSize
+	if size == 0 {
+		size = defaultPageSize
+	}
+	for i, line := range lines {
+		if ctx.paging() && i > 0 && i%size == 0 {
+			ctx.Conn.Write([]byte(fmt.Sprintf("-- More (%d/%d) -- Enter for more, q to quit: ", i, len(lines))))
+			if !ctx.Input.Scan() || strings.TrimSpace(ctx.Input.Text()) == "q" {
+				return
+			}
+		}
+		ctx.Conn.Write([]byte(line + "\n"))
+	}
+}
+
+// pageCommand sets this session's page size, or turns paging off.
+func pageCommand(ctx *CommandContext, args []string) error {
+	if len(args) < 1 {
+		switch {
+		case ctx.PageSize < 0:
+			ctx.Conn.Write([]byte("Paging is off.\n"))
+		case ctx.PageSize == 0:
+			ctx.Conn.Write([]byte(fmt.Sprintf("Page size: %d lines\n", defaultPageSize)))
+		default:
+			ctx.Conn.Write([]byte(fmt.Sprintf("Page size: %d lines\n", ctx.PageSize)))
+		}
+		return nil
+	}
+	if args[0] == "off" {
+		ctx.PageSize = -1
+		ctx.Conn.Write([]byte("Paging is off.\n"))
+		return nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return fmt.Errorf("usage: page <lines>|off")
+	}
+	ctx.PageSize = n
+	ctx.Conn.Write([]byte(fmt.Sprintf("Page size: %d lines\n", n)))
+	return nil
+}
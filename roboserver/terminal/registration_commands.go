@@ -0,0 +1,323 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"roboserver/database"
+	"roboserver/handler_engine"
+	"roboserver/shared"
+	"roboserver/shared/autoaccept"
+	"roboserver/shared/registrations"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listPending returns pending registrations oldest first, so the indexes
+// shown by "pending" are stable while robots keep arriving.
+func listPending(ctx *CommandContext) ([]*database.PendingRobot, error) {
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return nil, fmt.Errorf("redis not available")
+	}
+	pending, err := rds.GetAllPendingRobots(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending robots: %w", err)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].RequestedAt != pending[j].RequestedAt {
+			return pending[i].RequestedAt < pending[j].RequestedAt
+		}
+		return pending[i].UUID < pending[j].UUID
+	})
+	return pending, nil
+}
+
+func writePending(ctx *CommandContext, pending []*database.PendingRobot) {
+	ctx.Conn.Write([]byte("Pending registrations:\n"))
+	lines := make([]string, 0, len(pending))
+	for i, r := range pending {
+		waiting := ""
+		if r.RequestedAt > 0 {
+			waiting = fmt.Sprintf("  waiting=%s", time.Since(time.Unix(r.RequestedAt, 0)).Round(time.Second))
+		}
+		lines = append(lines, fmt.Sprintf("  [%d] %s  type=%s  ip=%s  key=%s...%s",
+			i+1, r.UUID, r.DeviceType, r.IP, truncate(r.PublicKey, 16), waiting))
+	}
+	ctx.writeLines(lines)
+}
+
+// pendingCommand lists all robots awaiting registration approval.
+func pendingCommand(ctx *CommandContext, args []string) error {
+	pending, err := listPending(ctx)
+	if err != nil {
+		return err
+	}
+	if ctx.JSON {
+		if pending == nil {
+			pending = []*database.PendingRobot{}
+		}
+		return ctx.writeJSON(pending)
+	}
+	if len(pending) == 0 {
+		ctx.Conn.Write([]byte("No pending registrations.\n"))
+		return nil
+	}
+	writePending(ctx, pending)
+	return nil
+}
+
+// acceptCommand approves pending registrations by UUID or "pending" index,
+// or interactively when called without arguments.
+func acceptCommand(ctx *CommandContext, args []string) error {
+	return decideRegistrations(ctx, args, true)
+}
+
+// rejectCommand denies pending registrations; same forms as acceptCommand.
+func rejectCommand(ctx *CommandContext, args []string) error {
+	return decideRegistrations(ctx, args, false)
+}
+
+func decideRegistrations(ctx *CommandContext, args []string, accept bool) error {
+	verb := "approve"
+	if !accept {
+		verb = "reject"
+	}
+
+	pending, err := listPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		// Interactive prompts read the next line from the connection, which
+		// inside run/batch would be the script's next command.
+		if ctx.Input == nil || ctx.scriptDepth > 0 {
+			return fmt.Errorf("usage: %s <uuid|index|all>...", verb)
+		}
+		if len(pending) == 0 {
+			ctx.Conn.Write([]byte("No pending registrations.\n"))
+			return nil
+		}
+		writePending(ctx, pending)
+		ctx.Conn.Write([]byte(fmt.Sprintf("Select robots to %s (indexes or uuids, 'all'; empty to cancel): ", verb)))
+		if !ctx.Input.Scan() {
+			return fmt.Errorf("connection closed")
+		}
+		args = strings.Fields(ctx.Input.Text())
+		if len(args) == 0 {
+			ctx.Conn.Write([]byte("Cancelled.\n"))
+			return nil
+		}
+	}
+
+	uuids, err := selectPending(pending, args)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, uuid := range uuids {
+		if err := respondToPending(ctx, uuid, accept); err != nil {
+			ctx.Conn.Write([]byte(fmt.Sprintf("  %s: %v\n", uuid, err)))
+			failed++
+			continue
+		}
+		if accept {
+			ctx.Conn.Write([]byte(fmt.Sprintf("Accepted robot %s\n", uuid)))
+		} else {
+			ctx.Conn.Write([]byte(fmt.Sprintf("Rejected robot %s\n", uuid)))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d registrations could not be updated", failed, len(uuids))
+	}
+	return nil
+}
+
+// selectPending resolves 1-based indexes, UUIDs and "all" against the
+// pending list. A UUID not in the list is passed through so a registration
+// that arrived after the listing can still be decided.
+func selectPending(pending []*database.PendingRobot, args []string) ([]string, error) {
+	var uuids []string
+	seen := make(map[string]bool)
+	add := func(uuid string) {
+		if !seen[uuid] {
+			seen[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == "all" {
+			for _, r := range pending {
+				add(r.UUID)
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n < 1 || n > len(pending) {
+				return nil, fmt.Errorf("index %d out of range (1-%d)", n, len(pending))
+			}
+			add(pending[n-1].UUID)
+			continue
+		}
+		add(arg)
+	}
+	if len(uuids) == 0 {
+		return nil, fmt.Errorf("no pending registrations selected")
+	}
+	return uuids, nil
+}
+
+// respondToPending publishes the decision the same way POST /register does,
+// unblocking the robot's REGISTER wait.
+func respondToPending(ctx *CommandContext, uuid string, accept bool) error {
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+	if _, err := rds.GetPendingRobot(context.Background(), uuid); err != nil {
+		return fmt.Errorf("no pending registration found")
+	}
+	if err := ctx.Bus.PublishRegistrationResponse(context.Background(), uuid, accept); err != nil {
+		return fmt.Errorf("failed to publish decision: %w", err)
+	}
+	return nil
+}
+
+// regfailuresCommand lists recent failed or rejected registration attempts,
+// newest first. Usage: regfailures [<count>]
+func regfailuresCommand(ctx *CommandContext, args []string) error {
+	limit := 20
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("count must be a positive integer")
+		}
+		limit = n
+	}
+
+	var failures []registrations.Failure
+	if rds := ctx.DB.Redis(); rds != nil && shared.AppConfig.Auth.PersistRegistrationFailures {
+		var err error
+		if failures, err = rds.GetRegistrationFailures(context.Background(), limit); err != nil {
+			return fmt.Errorf("failed to get registration failures: %w", err)
+		}
+	} else {
+		failures = registrations.Recent(limit)
+	}
+
+	if ctx.JSON {
+		return ctx.writeJSON(failures)
+	}
+	if len(failures) == 0 {
+		ctx.Conn.Write([]byte("No failed registrations.\n"))
+		return nil
+	}
+	ctx.Conn.Write([]byte("Failed registrations (newest first):\n"))
+	lines := make([]string, 0, len(failures))
+	for _, f := range failures {
+		device := f.DeviceID
+		if device == "" {
+			device = "-"
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %-24s  ip=%s  type=%s  %s",
+			time.Unix(f.Time, 0).Format("2006-01-02 15:04:05"), f.Reason, f.IP, f.DeviceType, device))
+	}
+	ctx.writeLines(lines)
+	return nil
+}
+
+const autoAcceptUsage = "usage: autoaccept [show] | set policies|ids|types|subnets=<a,b,...>... | reset"
+
+// autoacceptCommand shows the registration auto-accept settings, edits
+// them at runtime or restores the configured ones. "set" changes only the
+// lists it names; an empty value clears a list.
+func autoacceptCommand(ctx *CommandContext, args []string) error {
+	rds := ctx.DB.Redis()
+	if len(args) == 0 || args[0] == "show" {
+		s, err := handler_engine.AutoAcceptSettings(context.Background(), rds)
+		if err != nil {
+			return fmt.Errorf("failed to read auto-accept settings: %w", err)
+		}
+		if ctx.JSON {
+			return ctx.writeJSON(s)
+		}
+		writeAutoAccept(ctx, s)
+		return nil
+	}
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) < 2 {
+			return fmt.Errorf(autoAcceptUsage)
+		}
+		cur, err := handler_engine.AutoAcceptSettings(context.Background(), rds)
+		if err != nil {
+			return fmt.Errorf("failed to read auto-accept settings: %w", err)
+		}
+		cfg := cur.AutoAcceptConfig
+		for _, arg := range args[1:] {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf(autoAcceptUsage)
+			}
+			var list []string
+			if value != "" {
+				list = strings.Split(value, ",")
+			}
+			switch key {
+			case "policies":
+				cfg.Policies = list
+			case "ids":
+				cfg.DeviceIDs = list
+			case "types":
+				cfg.DeviceTypes = list
+			case "subnets":
+				cfg.TrustedSubnets = list
+			default:
+				return fmt.Errorf(autoAcceptUsage)
+			}
+		}
+		s, err := handler_engine.SetAutoAccept(context.Background(), rds, cfg, "terminal")
+		if err != nil {
+			return err
+		}
+		writeAutoAccept(ctx, s)
+	case "reset":
+		found, err := handler_engine.ResetAutoAccept(context.Background(), rds)
+		if err != nil {
+			return fmt.Errorf("failed to reset auto-accept settings: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("auto-accept settings were not changed at runtime")
+		}
+		ctx.Conn.Write([]byte("Auto-accept settings reset to the config.\n"))
+	default:
+		return fmt.Errorf(autoAcceptUsage)
+	}
+	return nil
+}
+
+func writeAutoAccept(ctx *CommandContext, s autoaccept.Settings) {
+	policies := strings.Join(s.Policies, ", ")
+	if policies == "" {
+		policies = autoaccept.Manual
+	}
+	source := s.Source
+	if s.By != "" {
+		source = fmt.Sprintf("%s (by %s at %s)", s.Source, s.By, time.Unix(s.At, 0).Format(time.RFC3339))
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Auto-accept policies: %s\n", policies)))
+	ctx.writeLines([]string{
+		"  source:  " + source,
+		"  ids:     " + strings.Join(s.DeviceIDs, ", "),
+		"  types:   " + strings.Join(s.DeviceTypes, ", "),
+		"  subnets: " + strings.Join(s.TrustedSubnets, ", "),
+	})
+}
@@ -3,6 +3,11 @@ package terminal
 import (
 	"context"
 	"fmt"
+	"roboserver/shared"
+	"roboserver/shared/maintenance"
+	"roboserver/tcp_server"
+	"strings"
+	"time"
 )
 
 // listActiveCommand lists all currently active robots from Redis.
@@ -18,15 +23,40 @@ func listActiveCommand(ctx *CommandContext, args []string) error {
 		return fmt.Errorf("failed to get active robots: %w", err)
 	}
 
+	if ctx.JSON {
+		// Built field by field so the session JWT never reaches the output.
+		out := make([]map[string]any, 0, len(robots))
+		for _, r := range robots {
+			entry := map[string]any{
+				"uuid":         r.UUID,
+				"device_type":  r.DeviceType,
+				"ip":           r.IP,
+				"pid":          r.PID,
+				"connected_at": r.ConnectedAt,
+			}
+			if info, ok := maintenance.Registry.Get(r.UUID); ok {
+				entry["maintenance"] = info
+			}
+			out = append(out, entry)
+		}
+		return ctx.writeJSON(out)
+	}
+
 	if len(robots) == 0 {
 		ctx.Conn.Write([]byte("No active robots.\n"))
 		return nil
 	}
 
 	ctx.Conn.Write([]byte("Active robots:\n"))
+	lines := make([]string, 0, len(robots))
 	for _, r := range robots {
-		ctx.Conn.Write([]byte(fmt.Sprintf("  %s  type=%s  ip=%s  pid=%d\n", r.UUID, r.DeviceType, r.IP, r.PID)))
+		line := fmt.Sprintf("  %s  type=%s  ip=%s  pid=%d", r.UUID, r.DeviceType, r.IP, r.PID)
+		if maintenance.Registry.Active(r.UUID) {
+			line += "  [MAINTENANCE]"
+		}
+		lines = append(lines, line)
 	}
+	ctx.writeLines(lines)
 	return nil
 }
 
@@ -43,19 +73,34 @@ func listRegisteredCommand(ctx *CommandContext, args []string) error {
 		return fmt.Errorf("failed to get registered robots: %w", err)
 	}
 
+	if ctx.JSON {
+		out := make([]map[string]any, 0, len(robots))
+		for _, r := range robots {
+			out = append(out, map[string]any{
+				"uuid":           r.UUID,
+				"device_type":    r.DeviceType,
+				"is_blacklisted": r.IsBlacklisted,
+				"created_at":     r.CreatedAt,
+			})
+		}
+		return ctx.writeJSON(out)
+	}
+
 	if len(robots) == 0 {
 		ctx.Conn.Write([]byte("No registered robots.\n"))
 		return nil
 	}
 
 	ctx.Conn.Write([]byte("Registered robots:\n"))
+	lines := make([]string, 0, len(robots))
 	for _, r := range robots {
 		bl := ""
 		if r.IsBlacklisted {
 			bl = " [BLACKLISTED]"
 		}
-		ctx.Conn.Write([]byte(fmt.Sprintf("  %s  type=%s%s\n", r.UUID, r.DeviceType, bl)))
+		lines = append(lines, fmt.Sprintf("  %s  type=%s%s", r.UUID, r.DeviceType, bl))
 	}
+	ctx.writeLines(lines)
 	return nil
 }
 
@@ -78,10 +123,16 @@ func stopCommand(ctx *CommandContext, args []string) error {
 func helpCommand(ctx *CommandContext, args []string) error {
 	if len(args) == 0 {
 		ctx.Conn.Write([]byte("Available commands:\n"))
+		var lines []string
 		for _, cmd := range DefaultRegistry.ListCommands() {
-			ctx.Conn.Write([]byte(fmt.Sprintf("  %-12s - %s\n", cmd.Name, cmd.Description)))
+			desc := cmd.Description
+			if len(cmd.Aliases) > 0 {
+				desc += " (alias " + strings.Join(cmd.Aliases, ", ") + ")"
+			}
+			lines = append(lines, fmt.Sprintf("  %-12s - %s", cmd.Name, desc))
 		}
-		ctx.Conn.Write([]byte("\nUse 'help <command>' for detailed usage.\n"))
+		ctx.writeLines(lines)
+		ctx.Conn.Write([]byte("\nUse 'help <command>' for detailed usage. Add --json to list/robots/pending/status/tcpstats for machine-readable output.\n"))
 		return nil
 	}
 
@@ -91,6 +142,9 @@ func helpCommand(ctx *CommandContext, args []string) error {
 	}
 
 	ctx.Conn.Write([]byte(fmt.Sprintf("Command: %s\n", cmd.Name)))
+	if len(cmd.Aliases) > 0 {
+		ctx.Conn.Write([]byte(fmt.Sprintf("Aliases: %s\n", strings.Join(cmd.Aliases, ", "))))
+	}
 	ctx.Conn.Write([]byte(fmt.Sprintf("Description: %s\n", cmd.Description)))
 	ctx.Conn.Write([]byte(fmt.Sprintf("Usage: %s\n", cmd.Usage)))
 	return nil
@@ -108,13 +162,30 @@ func statusCommand(ctx *CommandContext, args []string) error {
 	}
 
 	active, err := rds.GetActiveRobot(context.Background(), uuid)
+	if ctx.JSON {
+		out := map[string]any{"uuid": uuid, "online": err == nil}
+		if err == nil {
+			out["ip"] = active.IP
+			out["ips"] = active.Addresses()
+			out["device_type"] = active.DeviceType
+			out["pid"] = active.PID
+		}
+		if info, ok := maintenance.Registry.Get(uuid); ok {
+			out["maintenance"] = info
+		}
+		return ctx.writeJSON(out)
+	}
+	if info, ok := maintenance.Registry.Get(uuid); ok {
+		ctx.Conn.Write([]byte(fmt.Sprintf("Robot %s is in MAINTENANCE since %s (%s)\n",
+			uuid, time.Unix(info.Since, 0).Format(time.RFC3339), maintenanceReason(info))))
+	}
 	if err != nil {
 		ctx.Conn.Write([]byte(fmt.Sprintf("Robot %s: offline\n", uuid)))
 		return nil
 	}
 
 	ctx.Conn.Write([]byte(fmt.Sprintf("Robot %s: online  ip=%s  type=%s  pid=%d\n",
-		uuid, active.IP, active.DeviceType, active.PID)))
+		uuid, strings.Join(active.Addresses(), ","), active.DeviceType, active.PID)))
 	return nil
 }
 
@@ -127,83 +198,39 @@ func quitCommand(ctx *CommandContext, args []string) error {
 	return exitCommand(ctx, args)
 }
 
-// pendingCommand lists all robots awaiting registration approval.
-func pendingCommand(ctx *CommandContext, args []string) error {
-	rds := ctx.DB.Redis()
-	if rds == nil {
-		ctx.Conn.Write([]byte("Redis not available.\n"))
-		return nil
-	}
-
-	pending, err := rds.GetAllPendingRobots(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to get pending robots: %w", err)
-	}
-
-	if len(pending) == 0 {
-		ctx.Conn.Write([]byte("No pending registrations.\n"))
-		return nil
-	}
-
-	ctx.Conn.Write([]byte("Pending registrations:\n"))
-	for _, r := range pending {
-		ctx.Conn.Write([]byte(fmt.Sprintf("  %s  type=%s  ip=%s  key=%s...\n",
-			r.UUID, r.DeviceType, r.IP, truncate(r.PublicKey, 16))))
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
-	return nil
+	return s[:n]
 }
 
-// acceptCommand accepts a pending robot registration.
-func acceptCommand(ctx *CommandContext, args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: accept <uuid>")
-	}
-	uuid := args[0]
-	rds := ctx.DB.Redis()
-	if rds == nil {
-		return fmt.Errorf("redis not available")
+// tcpStatsCommand prints the TCP server's connection counters.
+func tcpStatsCommand(ctx *CommandContext, args []string) error {
+	st := tcp_server.Stats()
+	if ctx.JSON {
+		return ctx.writeJSON(st)
 	}
-
-	_, err := rds.GetPendingRobot(context.Background(), uuid)
-	if err != nil {
-		return fmt.Errorf("no pending registration found for %s", uuid)
+	limit := "unlimited"
+	if st.Limit > 0 {
+		limit = fmt.Sprintf("%d", st.Limit)
 	}
-
-	if err := ctx.Bus.PublishRegistrationResponse(context.Background(), uuid, true); err != nil {
-		return fmt.Errorf("failed to accept: %w", err)
-	}
-
-	ctx.Conn.Write([]byte(fmt.Sprintf("Accepted robot %s\n", uuid)))
+	ctx.Conn.Write([]byte(fmt.Sprintf("TCP connections: active=%d limit=%s accepted=%d refused=%d accept_errors=%d\n",
+		st.Active, limit, st.Accepted, st.Refused, st.AcceptErrors)))
 	return nil
 }
 
-// rejectCommand rejects a pending robot registration.
-func rejectCommand(ctx *CommandContext, args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: reject <uuid>")
-	}
-	uuid := args[0]
-	rds := ctx.DB.Redis()
-	if rds == nil {
-		return fmt.Errorf("redis not available")
-	}
-
-	_, err := rds.GetPendingRobot(context.Background(), uuid)
+// reloadCommand re-reads config.yaml and the environment, applying the
+// settings that can change without a restart (same as sending SIGHUP).
+func reloadCommand(ctx *CommandContext, args []string) error {
+	changed, err := shared.ReloadConfig()
 	if err != nil {
-		return fmt.Errorf("no pending registration found for %s", uuid)
+		return fmt.Errorf("reload failed: %w", err)
 	}
-
-	if err := ctx.Bus.PublishRegistrationResponse(context.Background(), uuid, false); err != nil {
-		return fmt.Errorf("failed to reject: %w", err)
+	if len(changed) == 0 {
+		ctx.Conn.Write([]byte("Configuration reloaded; no reloadable settings changed.\n"))
+		return nil
 	}
-
-	ctx.Conn.Write([]byte(fmt.Sprintf("Rejected robot %s\n", uuid)))
+	ctx.Conn.Write([]byte(fmt.Sprintf("Configuration reloaded; changed: %s\n", strings.Join(changed, ", "))))
 	return nil
 }
-
-func truncate(s string, n int) string {
-	if len(s) <= n {
-		return s
-	}
-	return s[:n]
-}
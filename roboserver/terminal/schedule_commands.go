@@ -0,0 +1,82 @@
+package terminal
+
+import (
+	"fmt"
+	"roboserver/handler_engine"
+	"strings"
+	"time"
+)
+
+const scheduleUsage = "usage: schedule [list [<uuid>]] | after <uuid> <delay> <message...> | every <uuid> <interval> <message...> | cancel <id>"
+
+// scheduleCommand lists, adds or cancels delayed and recurring robot
+// messages. "every" first delivers one interval from now.
+func scheduleCommand(ctx *CommandContext, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		uuid := ""
+		if len(args) > 1 {
+			uuid = args[1]
+		}
+		return writeSchedule(ctx, handler_engine.Scheduler.List(uuid))
+	}
+
+	switch args[0] {
+	case "after", "every":
+		if len(args) < 4 {
+			return fmt.Errorf(scheduleUsage)
+		}
+		d, err := time.ParseDuration(args[2])
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid duration %q", args[2])
+		}
+		var every time.Duration
+		if args[0] == "every" {
+			every = d
+		}
+		msg, err := handler_engine.Scheduler.SendMessageAfter(args[1], strings.Join(args[3:], " "), "terminal", d, every)
+		if err != nil {
+			return fmt.Errorf("failed to schedule message: %w", err)
+		}
+		if ctx.JSON {
+			return ctx.writeJSON(msg)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Scheduled %s for %s at %s.\n", msg.ID, msg.UUID, time.UnixMilli(msg.At).Format(time.RFC3339))))
+	case "cancel":
+		if len(args) != 2 {
+			return fmt.Errorf(scheduleUsage)
+		}
+		msg, err := handler_engine.Scheduler.Cancel(args[1])
+		if err != nil {
+			return err
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Cancelled %s for %s.\n", msg.ID, msg.UUID)))
+	default:
+		return fmt.Errorf(scheduleUsage)
+	}
+	return nil
+}
+
+func writeSchedule(ctx *CommandContext, scheduled []handler_engine.ScheduledMessage) error {
+	if ctx.JSON {
+		return ctx.writeJSON(scheduled)
+	}
+	if len(scheduled) == 0 {
+		ctx.Conn.Write([]byte("No scheduled messages.\n"))
+		return nil
+	}
+	lines := make([]string, 0, len(scheduled))
+	for _, m := range scheduled {
+		line := fmt.Sprintf("  %s  %s  at=%s", m.ID, m.UUID, time.UnixMilli(m.At).Format(time.RFC3339))
+		if m.EveryMs > 0 {
+			line += fmt.Sprintf("  every=%s", time.Duration(m.EveryMs)*time.Millisecond)
+		}
+		line += fmt.Sprintf("  runs=%d  by=%s  message=%q", m.Runs, m.CreatedBy, m.Message)
+		if m.LastError != "" {
+			line += fmt.Sprintf("  last_error=%q", m.LastError)
+		}
+		lines = append(lines, line)
+	}
+	ctx.Conn.Write([]byte("Scheduled messages:\n"))
+	ctx.writeLines(lines)
+	return nil
+}
@@ -0,0 +1,192 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"roboserver/comms"
+	"roboserver/database"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxScriptDepth bounds nested "run" calls so a script that runs itself
+// can't recurse forever.
+const maxScriptDepth = 4
+
+// hostOnlyCommands read or write files on the server host, or stop it.
+// Scripts sent over HTTP may not use them: the terminal itself only listens
+// on localhost.
+var hostOnlyCommands = []string{"run", "backup", "restore", "stop"}
+
+// runCommand executes a newline-separated command script from a file on the
+// server host. Blank lines and lines starting with '#' are skipped.
+func runCommand(ctx *CommandContext, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: run <script_path>")
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open script: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read script: %w", err)
+	}
+
+	_, _, err = executeScript(ctx, lines)
+	return err
+}
+
+// batchCommand reads commands from the connection until a line containing
+// only "end", then runs them as one script. Lets a client pipe a scenario in
+// and get a single aggregated result:
+//
+//	printf 'batch\nlist\npending\nend\nexit\n' | nc 127.0.0.1 6000
+func batchCommand(ctx *CommandContext, args []string) error {
+	if ctx.Input == nil {
+		return fmt.Errorf("batch input not available on this connection")
+	}
+
+	var lines []string
+	for {
+		if !ctx.Input.Scan() {
+			return fmt.Errorf("connection closed before 'end'")
+		}
+		line := ctx.Input.Text()
+		if strings.TrimSpace(line) == "end" {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	_, _, err := executeScript(ctx, lines)
+	return err
+}
+
+// ScriptResult is the outcome of RunScript: how many commands ran and
+// failed, and everything they printed.
+type ScriptResult struct {
+	Ran    int    `json:"ran"`
+	Failed int    `json:"failed"`
+	Output string `json:"output"`
+}
+
+// RunScript runs a newline-separated command script outside a terminal
+// session, for POST /admin/script. Commands that need an interactive
+// session fail as they do in batch mode, and host-only commands are
+// refused. Event subscriptions the script makes end with it.
+func RunScript(db database.DBManager, bus comms.Bus, script string) ScriptResult {
+	out := &scriptConn{}
+	ctx := &CommandContext{
+		Conn:          out,
+		DB:            db,
+		Bus:           bus,
+		Cancel:        func() {},
+		Subscriptions: make(map[string]func()),
+		Remote:        true,
+	}
+	ran, failed, _ := executeScript(ctx, strings.Split(script, "\n"))
+	for topic, cancel := range ctx.Subscriptions {
+		cancel()
+		delete(ctx.Subscriptions, topic)
+	}
+	return ScriptResult{Ran: ran, Failed: failed, Output: out.String()}
+}
+
+// executeScript runs each command line sequentially, continuing past
+// failures, and finishes with a summary of how many commands succeeded.
+// It returns how many commands ran and failed.
+func executeScript(ctx *CommandContext, lines []string) (ran, failed int, err error) {
+	if ctx.scriptDepth >= maxScriptDepth {
+		return 0, 0, fmt.Errorf("script nesting too deep (max %d)", maxScriptDepth)
+	}
+	ctx.scriptDepth++
+	defer func() { ctx.scriptDepth-- }()
+
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		ran++
+		ctx.Conn.Write([]byte(fmt.Sprintf("[%d] %s\n", i+1, line)))
+
+		err := refuseHostOnly(ctx, fields[0])
+		if err == nil {
+			err = DefaultRegistry.ExecuteCommand(ctx, fields[0], fields[1:])
+		}
+		if err != nil {
+			if err.Error() == "exit" {
+				ctx.Conn.Write([]byte(fmt.Sprintf("Script finished: %d run, %d failed (stopped by exit)\n", ran, failed)))
+				return ran, failed, err
+			}
+			failed++
+			ctx.Conn.Write([]byte(fmt.Sprintf("[%d] Error: %v\n", i+1, err)))
+		}
+	}
+
+	ctx.Conn.Write([]byte(fmt.Sprintf("Script finished: %d run, %d failed\n", ran, failed)))
+	if failed > 0 {
+		return ran, failed, fmt.Errorf("%d of %d commands failed", failed, ran)
+	}
+	return ran, failed, nil
+}
+
+// refuseHostOnly rejects a host-only command, by name or alias, in a
+// script sent over HTTP.
+func refuseHostOnly(ctx *CommandContext, name string) error {
+	if !ctx.Remote {
+		return nil
+	}
+	if cmd, ok := DefaultRegistry.GetCommand(name); ok && slices.Contains(hostOnlyCommands, cmd.Name) {
+		return fmt.Errorf("%s is only available in the terminal", cmd.Name)
+	}
+	return nil
+}
+
+// scriptConn collects the output of a RunScript script. Writes may come
+// from event subscriptions as well as the script, so they are serialized.
+type scriptConn struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *scriptConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+func (c *scriptConn) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+func (c *scriptConn) Read([]byte) (int, error)         { return 0, io.EOF }
+func (c *scriptConn) SetDeadline(time.Time) error      { return nil }
+func (c *scriptConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *scriptConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *scriptConn) LocalAddr() net.Addr              { return scriptAddr{} }
+func (c *scriptConn) RemoteAddr() net.Addr             { return scriptAddr{} }
+func (c *scriptConn) Close() error                     { return nil }
+
+type scriptAddr struct{}
+
+func (scriptAddr) Network() string { return "http" }
+func (scriptAddr) String() string  { return "http-script" }
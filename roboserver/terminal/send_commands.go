@@ -0,0 +1,76 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"roboserver/comms"
+	"roboserver/handler_engine"
+	"strings"
+	"time"
+)
+
+const sendUsage = "usage: send <uuid> <command> [json-payload] [--nowait]"
+
+// sendReplyTimeout is how long send waits for the handler's reply.
+const sendReplyTimeout = 10 * time.Second
+
+// sendCommand sends a command message to a robot's handler and prints its
+// reply. The JSON payload, if given, must be an object; its fields are sent
+// alongside "command" and "request_id". With --nowait it only sends.
+func sendCommand(ctx *CommandContext, args []string) error {
+	wait := true
+	words := args[:0:0]
+	for _, a := range args {
+		if a == "--nowait" {
+			wait = false
+			continue
+		}
+		words = append(words, a)
+	}
+	if len(words) < 2 {
+		return fmt.Errorf(sendUsage)
+	}
+	uuid, command := words[0], words[1]
+
+	var params map[string]any
+	if len(words) > 2 {
+		if err := json.Unmarshal([]byte(strings.Join(words[2:], " ")), &params); err != nil || params == nil {
+			return fmt.Errorf("payload must be a JSON object")
+		}
+	}
+
+	hp, ok := handler_engine.HandlerManager.Get(uuid)
+	if !ok {
+		return fmt.Errorf("no handler running for %s", uuid)
+	}
+	timeout := sendReplyTimeout
+	if !wait {
+		timeout = 0
+	}
+	reply, err := hp.Call(context.Background(), command, params, timeout)
+	switch {
+	case errors.Is(err, comms.ErrRequestTimeout):
+		return fmt.Errorf("sent to %s, but no reply within %s", uuid, sendReplyTimeout)
+	case err != nil:
+		return fmt.Errorf("failed to send to %s: %w", uuid, err)
+	}
+
+	if reply == nil {
+		ctx.Conn.Write([]byte(fmt.Sprintf("Sent %s to %s\n", command, uuid)))
+		return nil
+	}
+	if ctx.JSON {
+		return ctx.writeJSON(reply)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("%s replied: %s", uuid, reply.Error)
+	}
+	data, err := json.MarshalIndent(reply.Data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode reply: %w", err)
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Reply from %s:\n%s\n", uuid, data)))
+	return nil
+}
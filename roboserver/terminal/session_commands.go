@@ -0,0 +1,61 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const sessionsUsage = "usage: sessions <username> [revoke <id>|all]"
+
+// sessionsCommand lists a user's login sessions or ends them.
+func sessionsCommand(ctx *CommandContext, args []string) error {
+	if len(args) != 1 && (len(args) != 3 || args[1] != "revoke") {
+		return fmt.Errorf(sessionsUsage)
+	}
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+	username := args[0]
+
+	if len(args) == 3 {
+		if args[2] == "all" {
+			revoked, err := rds.RevokeOtherUserSessions(context.Background(), username, "")
+			if err != nil {
+				return fmt.Errorf("failed to revoke sessions: %w", err)
+			}
+			ctx.Conn.Write([]byte(fmt.Sprintf("Revoked %d session(s) of %s.\n", len(revoked), username)))
+			return nil
+		}
+		found, err := rds.RevokeUserSession(context.Background(), username, args[2])
+		if err != nil {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("no session %s for %s", args[2], username)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Session %s of %s revoked.\n", args[2], username)))
+		return nil
+	}
+
+	sessions, err := rds.ListUserSessions(context.Background(), username)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if ctx.JSON {
+		return ctx.writeJSON(sessions)
+	}
+	if len(sessions) == 0 {
+		ctx.Conn.Write([]byte(fmt.Sprintf("No active sessions for %s.\n", username)))
+		return nil
+	}
+	lines := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		lines = append(lines, fmt.Sprintf("  %s  ip=%s  since=%s  last_seen=%s  agent=%q",
+			s.ID, s.IP, time.Unix(s.CreatedAt, 0).Format(time.RFC3339), time.Unix(s.LastSeen, 0).Format(time.RFC3339), s.UserAgent))
+	}
+	ctx.Conn.Write([]byte(fmt.Sprintf("Sessions of %s:\n", username)))
+	ctx.writeLines(lines)
+	return nil
+}
@@ -0,0 +1,72 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"roboserver/handler_engine"
+	"roboserver/shared/shadow"
+	"strings"
+)
+
+const shadowUsage = "usage: shadow get <uuid> | set <uuid> <json> | clear <uuid>"
+
+// shadowCommand shows a robot's shadow, merges JSON into its desired state
+// or deletes it.
+func shadowCommand(ctx *CommandContext, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf(shadowUsage)
+	}
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	action, uuid := args[0], args[1]
+	switch action {
+	case "get":
+		s, err := rds.GetShadow(context.Background(), uuid)
+		if err != nil {
+			return fmt.Errorf("failed to load shadow: %w", err)
+		}
+		if ctx.JSON {
+			return ctx.writeJSON(handler_engine.ShadowDocument(s))
+		}
+		ctx.Conn.Write([]byte(formatShadow(s)))
+	case "set":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: shadow set <uuid> <json>")
+		}
+		var patch map[string]any
+		if err := json.Unmarshal([]byte(strings.Join(args[2:], " ")), &patch); err != nil || patch == nil {
+			return fmt.Errorf("desired state must be a JSON object")
+		}
+		s, err := handler_engine.UpdateDesired(context.Background(), ctx.Bus, rds, uuid, patch, 0)
+		if err != nil {
+			return fmt.Errorf("failed to update shadow: %w", err)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Shadow of %s is at version %d; %d field(s) pending.\n", uuid, s.Version, len(s.Delta()))))
+	case "clear":
+		found, err := handler_engine.DeleteShadow(context.Background(), ctx.Bus, rds, uuid)
+		if err != nil {
+			return fmt.Errorf("failed to delete shadow: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("robot %s has no shadow", uuid)
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Deleted the shadow of %s.\n", uuid)))
+	default:
+		return fmt.Errorf(shadowUsage)
+	}
+	return nil
+}
+
+func formatShadow(s *shadow.Shadow) string {
+	desired, _ := json.Marshal(s.Desired)
+	reported, _ := json.Marshal(s.Reported)
+	delta := []byte("in sync")
+	if d := s.Delta(); d != nil {
+		delta, _ = json.Marshal(d)
+	}
+	return fmt.Sprintf("Shadow of %s (version %d):\n  desired:  %s\n  reported: %s\n  delta:    %s\n", s.UUID, s.Version, desired, reported, delta)
+}
@@ -0,0 +1,160 @@
+package terminal
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// newSSHServer builds the SSH terminal from server.terminal_ssh. Every
+// session runs the same command loop as the TCP terminal; only keys listed in
+// the authorized_keys file may log in.
+func newSSHServer(ctx context.Context, bus comms.Bus, db database.DBManager, cancel context.CancelFunc) (*ssh.Server, error) {
+	cfg := shared.AppConfig.Server.TerminalSSH
+
+	authorized, err := loadAuthorizedKeys(cfg.AuthorizedKeysFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := loadOrCreateHostKey(cfg.HostKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &ssh.Server{
+		Addr: cfg.Address,
+		Handler: func(s ssh.Session) {
+			shared.DebugPrint("Accepted SSH terminal session for %s from %s", s.User(), s.RemoteAddr())
+			handleConnection(ctx, newSSHConn(s), bus, db, cancel)
+		},
+		PublicKeyHandler: func(_ ssh.Context, key ssh.PublicKey) bool {
+			for _, k := range authorized {
+				if ssh.KeysEqual(k, key) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	srv.AddHostKey(signer)
+	return srv, nil
+}
+
+// loadAuthorizedKeys parses an OpenSSH authorized_keys file. An empty or
+// missing file is an error: the SSH terminal never runs without keys.
+func loadAuthorizedKeys(path string) ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading terminal authorized keys: %w", err)
+	}
+	var keys []ssh.PublicKey
+	for len(data) > 0 {
+		key, _, _, rest, err := gossh.ParseAuthorizedKey(data)
+		if err != nil {
+			break // no more keys (comments and blank lines are skipped)
+		}
+		keys = append(keys, key)
+		data = rest
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in terminal authorized keys file %s", path)
+	}
+	return keys, nil
+}
+
+// loadOrCreateHostKey reads the host key, generating an ed25519 key on first
+// start so the fingerprint stays stable across restarts.
+func loadOrCreateHostKey(path string) (gossh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating terminal host key: %w", err)
+		}
+		block, err := gossh.MarshalPrivateKey(priv, "robomesh terminal")
+		if err != nil {
+			return nil, fmt.Errorf("encoding terminal host key: %w", err)
+		}
+		data = pem.EncodeToMemory(block)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("writing terminal host key: %w", err)
+		}
+		shared.DebugPrint("Generated terminal SSH host key %s", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading terminal host key: %w", err)
+	}
+
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing terminal host key: %w", err)
+	}
+	return signer, nil
+}
+
+// sshConn lets handleConnection treat an SSH session as a net.Conn. With a
+// PTY, input goes through a line editor (echo, backspace, history) and
+// output gets CRLF line endings; without one (e.g. "ssh host < script")
+// the session is read and written as-is.
+type sshConn struct {
+	ssh.Session
+	rw io.ReadWriter
+}
+
+func newSSHConn(s ssh.Session) *sshConn {
+	c := &sshConn{Session: s, rw: s}
+	if _, winCh, isPty := s.Pty(); isPty {
+		t := term.NewTerminal(s, "")
+		c.rw = &lineReader{t: t}
+		go func() {
+			for win := range winCh {
+				t.SetSize(win.Width, win.Height)
+			}
+		}()
+	}
+	return c
+}
+
+func (c *sshConn) Read(p []byte) (int, error)       { return c.rw.Read(p) }
+func (c *sshConn) Write(p []byte) (int, error)      { return c.rw.Write(p) }
+func (c *sshConn) SetDeadline(time.Time) error      { return nil }
+func (c *sshConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *sshConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *sshConn) LocalAddr() net.Addr              { return c.Session.LocalAddr() }
+func (c *sshConn) RemoteAddr() net.Addr             { return c.Session.RemoteAddr() }
+func (c *sshConn) Close() error                     { return c.Session.Close() }
+
+// lineReader adapts term.Terminal's ReadLine to io.Reader, one line (with
+// its newline) at a time.
+type lineReader struct {
+	t       *term.Terminal
+	pending []byte
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	if len(l.pending) == 0 {
+		line, err := l.t.ReadLine()
+		if err != nil {
+			return 0, err
+		}
+		l.pending = []byte(line + "\n")
+	}
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+	return n, nil
+}
+
+func (l *lineReader) Write(p []byte) (int, error) { return l.t.Write(p) }
@@ -3,21 +3,47 @@ package terminal
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"roboserver/comms"
 	"roboserver/database"
 	"roboserver/shared"
+	"roboserver/shared/tracked"
+	"slices"
 	"strings"
+
+	"github.com/gliderlabs/ssh"
 )
 
 /* For debugging and testing purposes, this terminal server allows direct interaction via TCP connections. */
 func Start(ctx context.Context, bus comms.Bus, db database.DBManager, cancel context.CancelFunc) error {
+	sshCfg := shared.AppConfig.Server.TerminalSSH
+	if sshCfg.Enabled {
+		srv, err := newSSHServer(ctx, bus, db, cancel)
+		if err != nil {
+			return fmt.Errorf("error starting SSH terminal: %w", err)
+		}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+				shared.DebugPrint("SSH terminal stopped: %v", err)
+			}
+		}()
+		defer srv.Close()
+		shared.DebugPrint("SSH terminal listening on %s", sshCfg.Address)
+	}
+	if sshCfg.Enabled && sshCfg.DisableTCP {
+		<-ctx.Done()
+		shared.DebugPrint("SSH terminal has shut down.")
+		return nil
+	}
+
 	port := shared.AppConfig.Server.TerminalPort
 
 	// Bind to localhost only — the terminal has no authentication and provides
 	// full admin access (shutdown, accept/reject registrations, list robots).
-	// Exposing it on all interfaces would be a critical security issue.
+	// Exposing it on all interfaces would be a critical security issue; use
+	// server.terminal_ssh for remote access.
 	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
 	if err != nil {
 		return fmt.Errorf("error starting terminal server: %w", err)
@@ -39,7 +65,7 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager, cancel con
 				}
 			}
 			shared.DebugPrint("Accepted terminal connection from %s", conn.RemoteAddr())
-			go handleConnection(ctx, conn, bus, db, cancel)
+			tracked.Go("terminal", func() { handleConnection(ctx, conn, bus, db, cancel) })
 		}
 	}()
 
@@ -57,12 +83,15 @@ func handleConnection(ctx context.Context, conn net.Conn, bus comms.Bus, db data
 	defer conn.Close()
 	shared.DebugPrint("Handling terminal connection from %s", conn.RemoteAddr())
 
+	scanner := bufio.NewScanner(conn)
+
 	cmdCtx := &CommandContext{
 		Conn:          conn,
 		DB:            db,
 		Bus:           bus,
 		Cancel:        cancel,
 		Subscriptions: make(map[string]func()),
+		Input:         scanner,
 	}
 
 	// Ensure all event subscriptions are cancelled when the connection closes
@@ -77,8 +106,6 @@ func handleConnection(ctx context.Context, conn net.Conn, bus comms.Bus, db data
 	conn.Write([]byte("Type 'help' for available commands.\n"))
 	conn.Write([]byte("> "))
 
-	scanner := bufio.NewScanner(conn)
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -115,7 +142,11 @@ func handleConnection(ctx context.Context, conn net.Conn, bus comms.Bus, db data
 				if err.Error() == "exit" {
 					return
 				}
-				conn.Write([]byte(fmt.Sprintf("Error: %v\n", err)))
+				if slices.Contains(commandArgs, jsonFlag) {
+					cmdCtx.writeJSON(map[string]string{"error": err.Error()})
+				} else {
+					conn.Write([]byte(fmt.Sprintf("Error: %v\n", err)))
+				}
 			}
 
 			conn.Write([]byte("> "))
@@ -0,0 +1,69 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"roboserver/shared"
+	"roboserver/shared/tokencrypt"
+)
+
+// tokenKeyCommand shows or rotates the key that encrypts stored session
+// tokens. "rotate" takes the new key as an argument, or re-reads
+// auth.token_key_file when a secret manager has replaced the file, and then
+// re-seals every stored token under it.
+func tokenKeyCommand(ctx *CommandContext, args []string) error {
+	if len(args) == 0 || args[0] == "status" {
+		if !tokencrypt.Enabled() {
+			ctx.Conn.Write([]byte("Token encryption is off (set TOKEN_ENCRYPTION_KEY or auth.token_key_file).\n"))
+			return nil
+		}
+		ctx.Conn.Write([]byte(fmt.Sprintf("Token encryption is on, current key id %s\n", tokencrypt.KeyID())))
+		return nil
+	}
+	if args[0] != "rotate" {
+		return fmt.Errorf("usage: tokenkey status|rotate [new_key]")
+	}
+
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	var newKey string
+	if len(args) > 1 {
+		newKey = args[1]
+	} else {
+		auth := shared.AppConfig.Auth
+		auth.TokenKey = "" // force a fresh read of the key file
+		key, err := auth.TokenEncryptionKey()
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return fmt.Errorf("usage: tokenkey rotate <new_key> (no auth.token_key_file configured)")
+		}
+		newKey = key
+	}
+
+	previous := tokencrypt.KeyID()
+	if err := tokencrypt.Rotate(newKey); err != nil {
+		return err
+	}
+	n, err := rds.ResealActiveRobots(context.Background())
+	if err != nil {
+		return fmt.Errorf("rotated to key %s but re-sealing failed after %d sessions: %w", tokencrypt.KeyID(), n, err)
+	}
+
+	ctx.Conn.Write([]byte(fmt.Sprintf("Rotated token key %s -> %s, re-sealed %d sessions.\n", orNone(previous), tokencrypt.KeyID(), n)))
+	if len(args) > 1 {
+		ctx.Conn.Write([]byte("Set TOKEN_ENCRYPTION_KEY to the new key (and the old one in TOKEN_ENCRYPTION_OLD_KEYS) before restarting.\n"))
+	}
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,273 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"roboserver/database"
+	"roboserver/shared/events"
+	"roboserver/shared/maintenance"
+	"roboserver/shared/robot_status"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const watchUsage = "usage: watch <uuid|all> [interval] [--plain]"
+
+// defaultWatchInterval is the least time between two redraws of watch.
+const defaultWatchInterval = time.Second
+
+// ansiClear moves the cursor home and clears the screen.
+const ansiClear = "\x1b[H\x1b[2J"
+
+// watchKinds are the robot event kinds that redraw watch.
+var watchKinds = []string{"status", "heartbeat", "latency", "maintenance", "changed", "telemetry", "labels"}
+
+// watchRobot is what watch has learned about a robot from its events.
+type watchRobot struct {
+	Status    string  `json:"status,omitempty"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	LastEvent string  `json:"last_event,omitempty"`
+	LastAt    int64   `json:"last_at,omitempty"` // Unix seconds
+}
+
+// watchState collects robot events between redraws. The bus calls observe
+// on the publisher's goroutine, so it only records and signals.
+type watchState struct {
+	mu     sync.Mutex
+	robots map[string]*watchRobot
+	dirty  chan struct{}
+}
+
+func (w *watchState) observe(eventType string, data any) {
+	uuid, kind, _ := events.ParseRobotTopic(eventType)
+	w.mu.Lock()
+	r := w.robots[uuid]
+	if r == nil {
+		r = &watchRobot{}
+		w.robots[uuid] = r
+	}
+	r.LastEvent, r.LastAt = kind, time.Now().Unix()
+	switch kind {
+	case "status":
+		var tr struct {
+			To string `json:"to"`
+		}
+		if decodeEventData(data, &tr) == nil && tr.To != "" {
+			r.Status = tr.To
+		}
+	case "latency":
+		var stats database.LatencyStats
+		if decodeEventData(data, &stats) == nil && stats.Samples > 0 {
+			r.LatencyMs = stats.LastMs
+		}
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// decodeEventData reads an event payload into v, whether it arrived as the
+// publisher's struct or, from another cluster node, as decoded JSON.
+func decodeEventData(data any, v any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// watchCommand redraws the status of one robot, or of all active robots,
+// whenever one of their events arrives, at most once per interval. It runs
+// until the user presses Enter or the connection closes.
+func watchCommand(ctx *CommandContext, args []string) error {
+	plain := slices.Contains(args, "--plain")
+	args = slices.DeleteFunc(slices.Clone(args), func(a string) bool { return a == "--plain" })
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf(watchUsage)
+	}
+	if ctx.Input == nil || ctx.scriptDepth > 0 {
+		return fmt.Errorf("watch needs an interactive session")
+	}
+	rds := ctx.DB.Redis()
+	if rds == nil {
+		return fmt.Errorf("redis not available")
+	}
+	target := args[0]
+	interval := defaultWatchInterval
+	if len(args) == 2 {
+		d, err := time.ParseDuration(args[1])
+		if err != nil || d < 100*time.Millisecond {
+			return fmt.Errorf("interval must be a duration of at least 100ms")
+		}
+		interval = d
+	}
+
+	state := &watchState{robots: make(map[string]*watchRobot), dirty: make(chan struct{}, 1)}
+	cancel, err := ctx.Bus.SubscribeMatching(func(eventType string) bool {
+		uuid, kind, ok := events.ParseRobotTopic(eventType)
+		return ok && (target == "all" || uuid == target) && slices.Contains(watchKinds, kind)
+	}, state.observe)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer cancel()
+
+	// Any line from the user ends the watch. The scanner is ours until the
+	// reader returns, so watch waits for it before handing the session back.
+	stop := make(chan struct{})
+	go func() {
+		ctx.Input.Scan()
+		close(stop)
+	}()
+	defer func() { <-stop }()
+
+	redraw := func() {
+		frame, err := watchFrame(rds, state, target)
+		if err != nil {
+			frame = map[string]any{"target": target, "error": err.Error()}
+		}
+		switch {
+		case ctx.JSON:
+			ctx.writeJSON(frame)
+		case plain:
+			ctx.Conn.Write([]byte("\n" + strings.Join(renderWatch(frame, time.Now()), "\n") + "\n"))
+		default:
+			ctx.Conn.Write([]byte(ansiClear + strings.Join(renderWatch(frame, time.Now()), "\n") + "\n"))
+		}
+	}
+
+	redraw()
+	throttle := time.NewTimer(interval)
+	defer throttle.Stop()
+	pending := false
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-state.dirty:
+			pending = true
+		case <-throttle.C:
+			if pending {
+				redraw()
+				pending = false
+			}
+			throttle.Reset(interval)
+		}
+	}
+}
+
+// watchFrame gathers one view of the watched robots: their sessions from
+// Redis merged with what their events said.
+func watchFrame(rds *database.RedisHandler, state *watchState, target string) (map[string]any, error) {
+	var active []*database.ActiveRobot
+	if target == "all" {
+		robots, err := rds.GetAllActiveRobots(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get active robots: %w", err)
+		}
+		active = robots
+	} else if r, err := rds.GetActiveRobot(context.Background(), target); err == nil {
+		active = append(active, r)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	rows := make([]map[string]any, 0, len(active))
+	seen := make(map[string]bool)
+	add := func(uuid string, session *database.ActiveRobot) {
+		seen[uuid] = true
+		row := map[string]any{"uuid": uuid, "online": session != nil}
+		if session != nil {
+			row["device_type"] = session.DeviceType
+			row["ip"] = session.IP
+		}
+		status := robot_status.Tracker.Get(uuid)
+		if ev, ok := state.robots[uuid]; ok {
+			row["events"] = *ev
+			if ev.Status != "" {
+				row["status"] = ev.Status
+			}
+		}
+		if _, ok := row["status"]; !ok && status != robot_status.Unknown {
+			row["status"] = status.String()
+		}
+		if info, ok := maintenance.Registry.Get(uuid); ok {
+			row["maintenance"] = info
+		}
+		rows = append(rows, row)
+	}
+	for _, r := range active {
+		add(r.UUID, r)
+	}
+	// Robots that reported and then went away stay listed as offline.
+	if target == "all" {
+		for uuid := range state.robots {
+			if !seen[uuid] {
+				add(uuid, nil)
+			}
+		}
+	} else if !seen[target] {
+		add(target, nil)
+	}
+	slices.SortFunc(rows, func(a, b map[string]any) int {
+		return strings.Compare(a["uuid"].(string), b["uuid"].(string))
+	})
+	return map[string]any{"target": target, "time": time.Now().Unix(), "robots": rows}, nil
+}
+
+// renderWatch formats a frame for the terminal.
+func renderWatch(frame map[string]any, now time.Time) []string {
+	header := fmt.Sprintf("Watching %v  %s  (press Enter to stop)", frame["target"], now.Format(time.TimeOnly))
+	if msg, ok := frame["error"]; ok {
+		return []string{header, "", fmt.Sprintf("Error: %v", msg)}
+	}
+	rows, _ := frame["robots"].([]map[string]any)
+	lines := []string{header, ""}
+	if len(rows) == 0 {
+		return append(lines, "No active robots.")
+	}
+	lines = append(lines, fmt.Sprintf("  %-24s %-12s %-16s %-9s %-12s %s", "UUID", "STATUS", "IP", "LATENCY", "TYPE", "LAST EVENT"))
+	for _, row := range rows {
+		status, _ := row["status"].(string)
+		if status == "" {
+			status = "offline"
+			if row["online"] == true {
+				status = "online"
+			}
+		}
+		if _, ok := row["maintenance"]; ok {
+			status += "*"
+		}
+		latency, last := "-", "-"
+		if ev, ok := row["events"].(watchRobot); ok {
+			if ev.LatencyMs > 0 {
+				latency = fmt.Sprintf("%.1fms", ev.LatencyMs)
+			}
+			if ev.LastAt > 0 {
+				last = fmt.Sprintf("%s %s ago", ev.LastEvent, now.Sub(time.Unix(ev.LastAt, 0)).Round(time.Second))
+			}
+		}
+		ip, _ := row["ip"].(string)
+		deviceType, _ := row["device_type"].(string)
+		lines = append(lines, fmt.Sprintf("  %-24s %-12s %-16s %-9s %-12s %s", row["uuid"], status, orDash(ip), latency, orDash(deviceType), last))
+	}
+	for _, row := range rows {
+		if _, ok := row["maintenance"]; ok {
+			return append(lines, "", "* in maintenance")
+		}
+	}
+	return lines
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
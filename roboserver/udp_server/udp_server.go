@@ -3,6 +3,7 @@ package udp_server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"roboserver/auth"
@@ -10,6 +11,8 @@ import (
 	"roboserver/database"
 	"roboserver/handler_engine"
 	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/shared/tracked"
 	"strings"
 	"time"
 )
@@ -98,7 +101,7 @@ func Start(ctx context.Context, bus comms.Bus, db database.DBManager) error {
 			// Copy packet data before handing off to goroutine
 			packet := make([]byte, n)
 			copy(packet, buf[:n])
-			go s.handlePacket(packet, remoteAddr)
+			tracked.Go("udp", func() { s.handlePacket(packet, remoteAddr) })
 		}
 	}()
 
@@ -204,7 +207,17 @@ func (s *UDPServer_t) handleAuth(addr *net.UDPAddr, pkt *UDPPacket) {
 		return
 	}
 
-	ip := addr.IP.String()
+	ip := shared.CanonicalIP(addr.String())
+	if err := auth.CheckBan(s.ctx, rds, uuid, ip); err != nil {
+		s.sendResponse(addr, &UDPResponse{Type: "auth_response", Status: "error", Error: err.Error()})
+		return
+	}
+	conflict, err := auth.CheckIPConflict(s.ctx, pg, rds, uuid, ip, publicKey)
+	handler_engine.ReportIPConflict(s.bus, conflict)
+	if err != nil {
+		s.sendResponse(addr, &UDPResponse{Type: "auth_response", Status: "error", Error: err.Error()})
+		return
+	}
 	sessionID := auth.GenerateSessionID()
 	jwt, err := auth.IssueSessionJWT(uuid, deviceType, ip, sessionID)
 	if err != nil {
@@ -235,7 +248,7 @@ func (s *UDPServer_t) handleAuth(addr *net.UDPAddr, pkt *UDPPacket) {
 		var spawnErr error
 		func() {
 			defer handler_engine.HandlerManager.FinishSpawning(uuid)
-			_, spawnErr = handler_engine.SpawnHandlerProcess(
+			_, spawnErr = handler_engine.SpawnSupervised(
 				s.ctx,
 				uuid, deviceType, ip, sessionID,
 				pg, rds, s.bus,
@@ -300,18 +313,18 @@ func (s *UDPServer_t) handleHeartbeat(addr *net.UDPAddr, pkt *UDPPacket) {
 		return
 	}
 
-	ip := addr.IP.String()
+	ip := shared.CanonicalIP(addr.String())
 	payloadJSON := string(pkt.Payload)
 
 	result, err := auth.ProcessHeartbeat(s.ctx, pkt.UUID, payloadJSON, pkt.Signature, ip, pg, rds)
 	if err != nil {
-		shared.DebugPrint("UDP heartbeat failed for %s: %v", pkt.UUID, err)
+		shared.DebugPrint("UDP heartbeat failed: %v", err)
 		s.sendResponse(addr, &UDPResponse{Type: "heartbeat_response", Status: "error", Error: "heartbeat rejected"})
 		return
 	}
 
 	if s.bus != nil {
-		s.bus.PublishEvent(fmt.Sprintf("robot.%s.heartbeat", result.UUID), result)
+		s.bus.PublishEvent(events.RobotHeartbeat(result.UUID), result)
 	}
 
 	s.sendResponse(addr, &UDPResponse{Type: "heartbeat_response", Status: "ok"})
@@ -325,8 +338,8 @@ func (s *UDPServer_t) handleMessage(addr *net.UDPAddr, pkt *UDPPacket) {
 	}
 
 	// Validate JWT and ensure it matches the claimed UUID
-	claims, err := auth.ValidateSessionJWT(pkt.JWT)
-	if err != nil || claims.Sub != pkt.UUID {
+	if _, err := auth.ValidateRobotJWT("udp message", pkt.JWT, pkt.UUID, addr.IP.String()); err != nil {
+		shared.DebugPrint("UDP message rejected: %v", err)
 		s.sendResponse(addr, &UDPResponse{Type: "message_response", Status: "error", Error: "invalid or mismatched JWT"})
 		return
 	}
@@ -337,7 +350,16 @@ func (s *UDPServer_t) handleMessage(addr *net.UDPAddr, pkt *UDPPacket) {
 		return
 	}
 
-	hp.SendIncoming(string(pkt.Payload))
+	if err := hp.SendIncoming(string(pkt.Payload)); err != nil {
+		msg := "message too large"
+		if errors.Is(err, handler_engine.ErrQueueFull) {
+			msg = "handler busy"
+		} else if errors.Is(err, handler_engine.ErrHandlerStopped) {
+			msg = "no handler running"
+		}
+		s.sendResponse(addr, &UDPResponse{Type: "message_response", Status: "error", Error: msg})
+		return
+	}
 	s.sendResponse(addr, &UDPResponse{Type: "message_response", Status: "ok"})
 }
 
@@ -0,0 +1,116 @@
+package zones
+
+import (
+	"context"
+	"roboserver/cluster"
+	"roboserver/comms"
+	"roboserver/database"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/statediff"
+	"time"
+)
+
+// queueSize bounds events waiting to be folded in; beyond it they are
+// dropped and the robot's next change catches up.
+const queueSize = 1024
+
+type input struct {
+	eventType string
+	data      any
+}
+
+// Watch feeds e the state changes, label changes and deletions of every
+// robot until ctx is cancelled, and publishes the aggregates that changed.
+// A robot's zone is read from rds the first time it is seen. Changes
+// relayed from other cluster nodes are folded in too, so every node holds
+// the whole fleet's aggregates, but only the leader publishes them.
+func Watch(ctx context.Context, bus comms.Bus, rds *database.RedisHandler, e *Engine_t) error {
+	queue := make(chan input, queueSize)
+	cancel, err := bus.SubscribeMatching(isZoneInput, func(eventType string, data any) {
+		select {
+		case queue <- input{eventType, data}:
+		default:
+			shared.DebugPrint("Zone aggregate queue full, dropping %s", eventType)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case in := <-queue:
+				changed := e.handle(ctx, rds, in, time.Now())
+				if !cluster.IsLeader() {
+					continue
+				}
+				for _, v := range changed {
+					bus.PublishEvent(events.ZoneAggregate(v.Zone, v.Name), v)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func isZoneInput(eventType string) bool {
+	_, kind, ok := events.ParseRobotTopic(eventType)
+	return ok && (kind == "changed" || kind == "labels" || kind == "record")
+}
+
+// handle folds one event into e.
+func (e *Engine_t) handle(ctx context.Context, rds *database.RedisHandler, in input, now time.Time) []Value {
+	_, kind, _ := events.ParseRobotTopic(in.eventType)
+	switch kind {
+	case "changed":
+		c, ok := statediff.DecodeChange(in.data)
+		if !ok {
+			return nil
+		}
+		var out []Value
+		if !e.Known(c.UUID) {
+			out = e.SetZone(c.UUID, lookupZone(ctx, rds, c.UUID), now)
+		}
+		return append(out, e.Apply(c, now)...)
+	case "labels":
+		l, ok := events.DecodeLabelsChanged(in.data)
+		if !ok {
+			return nil
+		}
+		return e.SetZone(l.UUID, l.Zone, now)
+	case "record":
+		rc, ok := events.DecodeRecordChange(in.data)
+		if !ok {
+			return nil
+		}
+		switch {
+		case rc.Change == "deleted":
+			return e.Forget(rc.UUID, now)
+		case rc.Change == "restored" && e.Known(rc.UUID):
+			// A backup may have brought other labels.
+			return e.SetZone(rc.UUID, lookupZone(ctx, rds, rc.UUID), now)
+		}
+	}
+	return nil
+}
+
+// lookupZone reads a robot's zone label, "" if it has none or Redis
+// can't tell.
+func lookupZone(ctx context.Context, rds *database.RedisHandler, uuid string) string {
+	if rds == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	labels, err := rds.GetRobotLabels(ctx, uuid)
+	if err != nil {
+		shared.DebugPrint("Failed to read the zone of %s: %v", uuid, err)
+		return ""
+	}
+	return labels.Zone
+}
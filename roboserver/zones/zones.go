@@ -0,0 +1,412 @@
+// Package zones computes aggregates over the robots of each zone (the zone
+// label set with PUT /robot/{uuid}/labels), so a rule can react to "any
+// motion in living_room" or "all doors in perimeter locked" through one
+// event instead of enumerating every device. Aggregates are configured as
+//
+//	zones:
+//	  aggregates:
+//	    - {name: motion, field: telemetry.motion.detected, op: any}
+//
+// Each is recalculated from the robot.{uuid}.changed events of the zone's
+// robots (see statediff/), and a result that changed is published on
+// zone.{zone}.{name}. Robots whose status is offline don't count.
+package zones
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/statediff"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Aggregate operations. any, all, none and count test each robot's value
+// against the aggregate's equals value (or for truthiness); sum, min, max
+// and avg take numbers.
+const (
+	OpAny   = "any"
+	OpAll   = "all"
+	OpNone  = "none"
+	OpCount = "count"
+	OpSum   = "sum"
+	OpMin   = "min"
+	OpMax   = "max"
+	OpAvg   = "avg"
+)
+
+// nameRe limits zone and aggregate names to one event topic segment.
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// statusField is the state path of a robot's status (see statediff).
+const statusField = "status"
+
+func init() {
+	events.Describe(events.Info{
+		Type:        events.ZoneAggregate("{zone}", "{name}"),
+		Description: "A zone aggregate (zones.aggregates) changed. members counts the zone's online robots reporting the field, matching those that matched it.",
+		Example:     Value{Zone: "perimeter", Name: "locked", Op: OpAll, Field: "heartbeat.data.lock", Value: false, Members: 4, Matching: 3, Timestamp: 1718000000000},
+	})
+}
+
+// Aggregate is a compiled zones.aggregates entry.
+type Aggregate struct {
+	Name      string
+	Field     string
+	Op        string
+	Equals    any
+	hasEquals bool
+}
+
+// Compile checks the configured aggregates.
+func Compile(cfg []shared.ZoneAggregate) ([]Aggregate, error) {
+	aggs := make([]Aggregate, 0, len(cfg))
+	seen := make(map[string]bool, len(cfg))
+	for _, c := range cfg {
+		if !nameRe.MatchString(c.Name) {
+			return nil, fmt.Errorf("zone aggregate name %q must match %s", c.Name, nameRe)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("zone aggregate %q defined twice", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Field == "" {
+			return nil, fmt.Errorf("zone aggregate %q has no field", c.Name)
+		}
+		switch c.Op {
+		case OpAny, OpAll, OpNone, OpCount:
+		case OpSum, OpMin, OpMax, OpAvg:
+			if c.Equals != nil {
+				return nil, fmt.Errorf("zone aggregate %q: equals does not apply to %s", c.Name, c.Op)
+			}
+		default:
+			return nil, fmt.Errorf("zone aggregate %q: unknown op %q", c.Name, c.Op)
+		}
+		aggs = append(aggs, Aggregate{Name: c.Name, Field: c.Field, Op: c.Op, Equals: c.Equals, hasEquals: c.Equals != nil})
+	}
+	return aggs, nil
+}
+
+// Value is the payload of zone.{zone}.{name}: an aggregate's result over
+// the zone's online robots that report its field.
+type Value struct {
+	Zone  string `json:"zone"`
+	Name  string `json:"name"`
+	Op    string `json:"op"`
+	Field string `json:"field"`
+	// Value is a bool for any, all and none, and a number otherwise; nil
+	// for min, max and avg without members. all is false without members.
+	Value     any   `json:"value"`
+	Members   int   `json:"members"`
+	Matching  int   `json:"matching"`  // any, all, none and count only
+	Timestamp int64 `json:"timestamp"` // Unix milliseconds of the last change
+}
+
+type member struct {
+	zone   string
+	fields map[string]any // the aggregated fields and status only
+}
+
+// Engine_t keeps the aggregated fields of every robot it has seen and the
+// last value of each zone's aggregates.
+type Engine_t struct {
+	mu         sync.Mutex
+	aggregates []Aggregate
+	watched    map[string]bool // fields the aggregates read
+	robots     map[string]*member
+	zones      map[string]map[string]*member // zone → uuid → member
+	values     map[string]map[string]Value   // zone → aggregate name → value
+}
+
+// Engine is the process-wide zone engine, configured at startup.
+var Engine = NewEngine(nil)
+
+func NewEngine(aggs []Aggregate) *Engine_t {
+	e := &Engine_t{
+		robots: make(map[string]*member),
+		zones:  make(map[string]map[string]*member),
+		values: make(map[string]map[string]Value),
+	}
+	e.SetAggregates(aggs)
+	return e
+}
+
+// SetAggregates replaces the computed aggregates. Call it before the engine
+// sees any change.
+func (e *Engine_t) SetAggregates(aggs []Aggregate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.aggregates = aggs
+	e.watched = map[string]bool{statusField: true}
+	for _, a := range aggs {
+		e.watched[a.Field] = true
+	}
+}
+
+// Enabled reports whether any aggregate is configured.
+func (e *Engine_t) Enabled() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.aggregates) > 0
+}
+
+// Known reports whether the engine has placed the robot in a zone (or in
+// none) yet.
+func (e *Engine_t) Known(uuid string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.robots[uuid]
+	return ok
+}
+
+// SetZone moves a robot to zone ("" = no zone) and returns the aggregates
+// of its old and new zone that changed.
+func (e *Engine_t) SetZone(uuid, zone string, now time.Time) []Value {
+	if zone != "" && !nameRe.MatchString(zone) {
+		zone = "" // can't be named in a topic
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m, ok := e.robots[uuid]
+	if !ok {
+		m = &member{fields: make(map[string]any)}
+		e.robots[uuid] = m
+	} else if m.zone == zone {
+		return nil
+	}
+	old := m.zone
+	e.leave(uuid, m)
+	m.zone = zone
+	if zone != "" {
+		if e.zones[zone] == nil {
+			e.zones[zone] = make(map[string]*member)
+		}
+		e.zones[zone][uuid] = m
+	}
+	var out []Value
+	if ok && old != "" {
+		out = append(out, e.recompute(old, now)...)
+	}
+	if zone != "" {
+		out = append(out, e.recompute(zone, now)...)
+	}
+	return out
+}
+
+// Apply folds a robot's state change into its fields and returns the
+// aggregates of its zone that changed. The robot must have been placed
+// with SetZone.
+func (e *Engine_t) Apply(c statediff.Change, now time.Time) []Value {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m, ok := e.robots[c.UUID]
+	if !ok {
+		return nil
+	}
+	touched := false
+	if c.Full {
+		touched = len(m.fields) > 0
+		clear(m.fields)
+	}
+	for field, v := range c.Changed {
+		if e.watched[field] {
+			m.fields[field] = v
+			touched = true
+		}
+	}
+	for _, field := range c.Removed {
+		if _, ok := m.fields[field]; ok {
+			delete(m.fields, field)
+			touched = true
+		}
+	}
+	if !touched || m.zone == "" {
+		return nil
+	}
+	return e.recompute(m.zone, now)
+}
+
+// Forget drops a robot and returns the aggregates of its zone that changed.
+func (e *Engine_t) Forget(uuid string, now time.Time) []Value {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m, ok := e.robots[uuid]
+	if !ok {
+		return nil
+	}
+	delete(e.robots, uuid)
+	e.leave(uuid, m)
+	if m.zone == "" {
+		return nil
+	}
+	return e.recompute(m.zone, now)
+}
+
+// Values returns the current aggregates of zone, or of every zone when
+// zone is "", ordered by zone and name.
+func (e *Engine_t) Values(zone string) []Value {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []Value
+	for z, byName := range e.values {
+		if zone != "" && z != zone {
+			continue
+		}
+		for _, v := range byName {
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Zone != out[j].Zone {
+			return out[i].Zone < out[j].Zone
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// leave removes a robot from its zone's members. Caller holds e.mu.
+func (e *Engine_t) leave(uuid string, m *member) {
+	if members := e.zones[m.zone]; members != nil {
+		delete(members, uuid)
+		if len(members) == 0 {
+			delete(e.zones, m.zone)
+		}
+	}
+}
+
+// recompute evaluates zone's aggregates and returns those whose result
+// changed. A zone left without robots is dropped once its final values are
+// returned. Caller holds e.mu.
+func (e *Engine_t) recompute(zone string, now time.Time) []Value {
+	members := e.zones[zone]
+	prev := e.values[zone]
+	next := make(map[string]Value, len(e.aggregates))
+	var out []Value
+	for _, a := range e.aggregates {
+		v := a.evaluate(members)
+		v.Zone = zone
+		old, seen := prev[a.Name]
+		if seen && sameResult(old, v) {
+			next[a.Name] = old
+			continue
+		}
+		v.Timestamp = now.UnixMilli()
+		next[a.Name] = v
+		// A robot joining a new zone doesn't announce empty results.
+		if seen || v.Members > 0 {
+			out = append(out, v)
+		}
+	}
+	if len(members) == 0 {
+		delete(e.values, zone)
+	} else {
+		e.values[zone] = next
+	}
+	return out
+}
+
+func sameResult(a, b Value) bool {
+	return a.Members == b.Members && a.Matching == b.Matching && reflect.DeepEqual(a.Value, b.Value)
+}
+
+// evaluate computes the aggregate over members.
+func (a *Aggregate) evaluate(members map[string]*member) Value {
+	v := Value{Name: a.Name, Op: a.Op, Field: a.Field}
+	var sum float64
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, m := range members {
+		if m.fields[statusField] == "offline" {
+			continue
+		}
+		raw, ok := m.fields[a.Field]
+		if !ok {
+			continue
+		}
+		switch a.Op {
+		case OpSum, OpMin, OpMax, OpAvg:
+			n, ok := toFloat(raw)
+			if !ok {
+				continue
+			}
+			sum += n
+			minV, maxV = math.Min(minV, n), math.Max(maxV, n)
+		default:
+			if a.matches(raw) {
+				v.Matching++
+			}
+		}
+		v.Members++
+	}
+
+	switch a.Op {
+	case OpAny:
+		v.Value = v.Matching > 0
+	case OpAll:
+		v.Value = v.Members > 0 && v.Matching == v.Members
+	case OpNone:
+		v.Value = v.Matching == 0
+	case OpCount:
+		v.Value = float64(v.Matching)
+	case OpSum:
+		v.Value = sum
+	case OpMin:
+		if v.Members > 0 {
+			v.Value = minV
+		}
+	case OpMax:
+		if v.Members > 0 {
+			v.Value = maxV
+		}
+	case OpAvg:
+		if v.Members > 0 {
+			v.Value = sum / float64(v.Members)
+		}
+	}
+	return v
+}
+
+// matches reports whether a robot's value counts for any, all, none and
+// count: equal to Equals if set (numbers compare by value), else truthy.
+func (a *Aggregate) matches(raw any) bool {
+	if a.hasEquals {
+		if x, ok := toFloat(raw); ok {
+			if y, ok := toFloat(a.Equals); ok {
+				return x == y
+			}
+		}
+		return reflect.DeepEqual(raw, a.Equals)
+	}
+	switch v := raw.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != "" && v != "false" && v != "0"
+	}
+	if n, ok := toFloat(raw); ok {
+		return n != 0
+	}
+	return true
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
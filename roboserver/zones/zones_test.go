@@ -0,0 +1,148 @@
+package zones
+
+import (
+	"context"
+	"roboserver/shared"
+	"roboserver/shared/events"
+	"roboserver/statediff"
+	"testing"
+	"time"
+)
+
+func testEngine(t *testing.T, cfg ...shared.ZoneAggregate) *Engine_t {
+	t.Helper()
+	aggs, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return NewEngine(aggs)
+}
+
+func change(uuid string, fields map[string]any) statediff.Change {
+	return statediff.Change{UUID: uuid, Changed: fields}
+}
+
+func valueOf(values []Value, zone, name string) (Value, bool) {
+	for _, v := range values {
+		if v.Zone == zone && v.Name == name {
+			return v, true
+		}
+	}
+	return Value{}, false
+}
+
+func TestCompileRejectsBadAggregates(t *testing.T) {
+	for _, cfg := range []shared.ZoneAggregate{
+		{Name: "a.b", Field: "status", Op: OpAny},
+		{Name: "x", Op: OpAny},
+		{Name: "x", Field: "status", Op: "median"},
+		{Name: "x", Field: "status", Op: OpSum, Equals: 1},
+	} {
+		if _, err := Compile([]shared.ZoneAggregate{cfg}); err == nil {
+			t.Errorf("Expected %+v to be rejected", cfg)
+		}
+	}
+	if _, err := Compile([]shared.ZoneAggregate{{Name: "x", Field: "f", Op: OpAny}, {Name: "x", Field: "g", Op: OpAll}}); err == nil {
+		t.Error("Expected duplicate names to be rejected")
+	}
+}
+
+func TestAnyMotionInZone(t *testing.T) {
+	e := testEngine(t, shared.ZoneAggregate{Name: "motion", Field: "telemetry.motion.detected", Op: OpAny})
+	now := time.Now()
+	e.SetZone("pir-1", "living_room", now)
+	e.SetZone("pir-2", "living_room", now)
+
+	out := e.Apply(change("pir-1", map[string]any{"telemetry.motion.detected": 0.0}), now)
+	if v, ok := valueOf(out, "living_room", "motion"); !ok || v.Value != false || v.Members != 1 {
+		t.Fatalf("Expected no motion from one member, got %+v", out)
+	}
+	out = e.Apply(change("pir-2", map[string]any{"telemetry.motion.detected": 1.0}), now)
+	if v, ok := valueOf(out, "living_room", "motion"); !ok || v.Value != true || v.Matching != 1 {
+		t.Fatalf("Expected motion, got %+v", out)
+	}
+	// Unrelated fields don't recompute.
+	if out := e.Apply(change("pir-2", map[string]any{"heartbeat.ip": "10.0.0.9"}), now); len(out) != 0 {
+		t.Errorf("Expected no change for an unwatched field, got %+v", out)
+	}
+	// Same result, no event.
+	if out := e.Apply(change("pir-2", map[string]any{"telemetry.motion.detected": 2.0}), now); len(out) != 0 {
+		t.Errorf("Expected an unchanged result to stay quiet, got %+v", out)
+	}
+	// An offline sensor no longer counts.
+	out = e.Apply(change("pir-2", map[string]any{"status": "offline"}), now)
+	if v, ok := valueOf(out, "living_room", "motion"); !ok || v.Value != false || v.Members != 1 {
+		t.Errorf("Expected offline robot to be left out, got %+v", out)
+	}
+}
+
+func TestAllDoorsLocked(t *testing.T) {
+	e := testEngine(t, shared.ZoneAggregate{Name: "locked", Field: "heartbeat.data.lock", Op: OpAll, Equals: "locked"})
+	now := time.Now()
+	for _, uuid := range []string{"door-1", "door-2"} {
+		e.SetZone(uuid, "perimeter", now)
+		e.Apply(change(uuid, map[string]any{"heartbeat.data.lock": "locked"}), now)
+	}
+	if v, _ := valueOf(e.Values("perimeter"), "perimeter", "locked"); v.Value != true || v.Members != 2 {
+		t.Fatalf("Expected all locked, got %+v", v)
+	}
+	out := e.Apply(change("door-2", map[string]any{"heartbeat.data.lock": "open"}), now)
+	if v, ok := valueOf(out, "perimeter", "locked"); !ok || v.Value != false || v.Matching != 1 {
+		t.Fatalf("Expected an open door to break all, got %+v", out)
+	}
+	// Moving the open door out leaves the zone locked again.
+	out = e.SetZone("door-2", "garage", now)
+	if v, ok := valueOf(out, "perimeter", "locked"); !ok || v.Value != true {
+		t.Errorf("Expected perimeter locked after the move, got %+v", out)
+	}
+	if v, ok := valueOf(out, "garage", "locked"); !ok || v.Value != false || v.Members != 1 {
+		t.Errorf("Expected garage to gain the open door, got %+v", out)
+	}
+}
+
+func TestNumericAggregates(t *testing.T) {
+	e := testEngine(t,
+		shared.ZoneAggregate{Name: "total", Field: "telemetry.power.watts", Op: OpSum},
+		shared.ZoneAggregate{Name: "peak", Field: "telemetry.power.watts", Op: OpMax},
+		shared.ZoneAggregate{Name: "mean", Field: "telemetry.power.watts", Op: OpAvg},
+		shared.ZoneAggregate{Name: "busy", Field: "status", Op: OpCount, Equals: "busy"},
+	)
+	now := time.Now()
+	e.SetZone("r1", "dock", now)
+	e.SetZone("r2", "dock", now)
+	e.Apply(change("r1", map[string]any{"telemetry.power.watts": 10.0, "status": "busy"}), now)
+	e.Apply(change("r2", map[string]any{"telemetry.power.watts": 30.0, "status": "online"}), now)
+
+	values := e.Values("dock")
+	want := map[string]any{"total": 40.0, "peak": 30.0, "mean": 20.0, "busy": 1.0}
+	for name, w := range want {
+		if v, _ := valueOf(values, "dock", name); v.Value != w {
+			t.Errorf("%s = %v, want %v", name, v.Value, w)
+		}
+	}
+	e.Forget("r1", now)
+	e.Forget("r2", now)
+	if len(e.Values("dock")) != 0 {
+		t.Error("Expected an empty zone to be dropped")
+	}
+}
+
+func TestHandleReadsZoneAndLabels(t *testing.T) {
+	e := testEngine(t, shared.ZoneAggregate{Name: "motion", Field: "telemetry.motion.detected", Op: OpAny})
+	ctx := context.Background()
+	now := time.Now()
+
+	// Without Redis a new robot has no zone until its labels change.
+	e.handle(ctx, nil, input{events.RobotChanged("pir-1"), statediff.Change{UUID: "pir-1", Full: true, Changed: map[string]any{"telemetry.motion.detected": true}}}, now)
+	if len(e.Values("")) != 0 {
+		t.Fatal("Expected no zone without labels")
+	}
+	out := e.handle(ctx, nil, input{events.RobotLabels("pir-1"), map[string]any{"uuid": "pir-1", "zone": "hall"}}, now)
+	if v, ok := valueOf(out, "hall", "motion"); !ok || v.Value != true {
+		t.Fatalf("Expected the relabelled robot to count in its zone, got %+v", out)
+	}
+	out = e.handle(ctx, nil, input{events.RobotRecord("pir-1"), events.RecordChange{UUID: "pir-1", Change: "deleted"}}, now)
+	if v, ok := valueOf(out, "hall", "motion"); !ok || v.Value != false || v.Members != 0 {
+		t.Errorf("Expected the deleted robot to leave its zone, got %+v", out)
+	}
+}
@@ -299,6 +299,10 @@ class RobotClient:
             while not self._recv_stop.is_set():
                 try:
                     line = self._recv_line()
+                    # Answer server latency probes without surfacing them
+                    if line.startswith("PING "):
+                        self._send_line("PONG " + line[5:])
+                        continue
                     if self._message_callback:
                         self._message_callback(line)
                 except (ConnectionError, OSError):